@@ -16,11 +16,31 @@ limitations under the License.
 
 package meta
 
+import (
+	"time"
+)
+
 const (
 	// ReconcileRequestAnnotation is the annotation used for triggering a reconciliation
 	// outside of a defined schedule. The value is interpreted as a token, and any change
 	// in value SHOULD trigger a reconciliation.
 	ReconcileRequestAnnotation string = "reconcile.fluxcd.io/requestedAt"
+
+	// ReconcileRequestExpiryAnnotation is the annotation used for setting an expiry on the
+	// ReconcileRequestAnnotation. The value is an RFC3339 timestamp, after which the
+	// reconcile request SHOULD be ignored.
+	ReconcileRequestExpiryAnnotation string = "reconcile.fluxcd.io/requestExpiresAt"
+
+	// ForceRequestAnnotation is the annotation used for triggering a one-off forced
+	// reconciliation, for example the recreation of objects with immutable field changes.
+	// The value is interpreted as a token, and any change in value SHOULD trigger a
+	// forced reconciliation.
+	ForceRequestAnnotation string = "reconcile.fluxcd.io/forceAt"
+
+	// ForceRequestExpiryAnnotation is the annotation used for setting an expiry on the
+	// ForceRequestAnnotation. The value is an RFC3339 timestamp, after which the
+	// force request SHOULD be ignored.
+	ForceRequestExpiryAnnotation string = "reconcile.fluxcd.io/forceExpiresAt"
 )
 
 // ReconcileAnnotationValue returns a value for the reconciliation request annotation, which can be used to detect
@@ -67,3 +87,92 @@ type StatusWithHandledReconcileRequest interface {
 type StatusWithHandledReconcileRequestSetter interface {
 	SetLastHandledReconcileRequest(token string)
 }
+
+// AnnotationRequest is a request made through an annotation, consisting of a
+// token and an optional expiry.
+// +k8s:deepcopy-gen=false
+type AnnotationRequest struct {
+	// Token is the value of the request annotation, any change in value
+	// indicates a new request.
+	Token string
+
+	// ExpiresAt is the time after which the request is stale. A zero value
+	// means the request does not expire.
+	ExpiresAt time.Time
+
+	// InvalidExpiry is true if the expiry annotation is set to a value which
+	// can not be parsed as an RFC3339 timestamp.
+	InvalidExpiry bool
+}
+
+// ReconcileRequest returns the reconcile request from the given annotations,
+// and a boolean indicating whether the ReconcileRequestAnnotation was set.
+func ReconcileRequest(annotations map[string]string) (AnnotationRequest, bool) {
+	return annotationRequest(annotations, ReconcileRequestAnnotation, ReconcileRequestExpiryAnnotation)
+}
+
+// ForceRequest returns the force request from the given annotations,
+// and a boolean indicating whether the ForceRequestAnnotation was set.
+func ForceRequest(annotations map[string]string) (AnnotationRequest, bool) {
+	return annotationRequest(annotations, ForceRequestAnnotation, ForceRequestExpiryAnnotation)
+}
+
+// SetReconcileRequest sets the reconcile request annotations on the given
+// annotations map, which is created if nil. A zero expiresAt removes the expiry.
+func SetReconcileRequest(annotations map[string]string, token string, expiresAt time.Time) map[string]string {
+	return setAnnotationRequest(annotations, ReconcileRequestAnnotation, ReconcileRequestExpiryAnnotation, token, expiresAt)
+}
+
+// SetForceRequest sets the force request annotations on the given
+// annotations map, which is created if nil. A zero expiresAt removes the expiry.
+func SetForceRequest(annotations map[string]string, token string, expiresAt time.Time) map[string]string {
+	return setAnnotationRequest(annotations, ForceRequestAnnotation, ForceRequestExpiryAnnotation, token, expiresAt)
+}
+
+// Expired returns true if the request has an expiry which is before the given
+// time. A request with an invalid expiry is always considered expired.
+func (r AnnotationRequest) Expired(now time.Time) bool {
+	if r.InvalidExpiry {
+		return true
+	}
+	return !r.ExpiresAt.IsZero() && now.After(r.ExpiresAt)
+}
+
+// Fresh returns true if the request token differs from the last handled one,
+// and the request has not expired at the given time.
+// Controllers SHOULD only act on fresh requests, and record the token as handled
+// regardless, so that a stale request is not acted upon after a resume.
+func (r AnnotationRequest) Fresh(lastHandled string, now time.Time) bool {
+	return r.Token != "" && r.Token != lastHandled && !r.Expired(now)
+}
+
+func annotationRequest(annotations map[string]string, tokenKey, expiryKey string) (AnnotationRequest, bool) {
+	token, ok := annotations[tokenKey]
+	if !ok {
+		return AnnotationRequest{}, false
+	}
+
+	r := AnnotationRequest{Token: token}
+	if expiry, ok := annotations[expiryKey]; ok && expiry != "" {
+		t, err := time.Parse(time.RFC3339, expiry)
+		if err != nil {
+			r.InvalidExpiry = true
+		} else {
+			r.ExpiresAt = t
+		}
+	}
+	return r, true
+}
+
+func setAnnotationRequest(annotations map[string]string, tokenKey, expiryKey, token string, expiresAt time.Time) map[string]string {
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	annotations[tokenKey] = token
+	if expiresAt.IsZero() {
+		delete(annotations, expiryKey)
+	} else {
+		annotations[expiryKey] = expiresAt.UTC().Format(time.RFC3339)
+	}
+	return annotations
+}
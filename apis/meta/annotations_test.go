@@ -65,3 +65,55 @@ func TestGetAnnotationValue(t *testing.T) {
 		t.Error("expected to detect change in annotation value")
 	}
 }
+
+func TestAnnotationRequest(t *testing.T) {
+	now := time.Now()
+
+	annotations := SetForceRequest(nil, "token-1", now.Add(time.Minute))
+	req, ok := ForceRequest(annotations)
+	if !ok {
+		t.Fatal("expected ForceRequest to return true when the annotation is set")
+	}
+	if req.Token != "token-1" {
+		t.Errorf("expected token %q, got %q", "token-1", req.Token)
+	}
+	if !req.Fresh("", now) {
+		t.Error("expected request to be fresh before expiry")
+	}
+	if req.Fresh("token-1", now) {
+		t.Error("expected handled request not to be fresh")
+	}
+	if !req.Expired(now.Add(2 * time.Minute)) {
+		t.Error("expected request to be expired after expiry")
+	}
+	if req.Fresh("", now.Add(2*time.Minute)) {
+		t.Error("expected expired request not to be fresh")
+	}
+
+	// removing the expiry makes the request valid indefinitely
+	annotations = SetForceRequest(annotations, "token-2", time.Time{})
+	if _, ok := annotations[ForceRequestExpiryAnnotation]; ok {
+		t.Error("expected expiry annotation to be removed")
+	}
+	req, _ = ForceRequest(annotations)
+	if !req.Fresh("token-1", now.Add(24*time.Hour)) {
+		t.Error("expected request without expiry to be fresh")
+	}
+
+	// an invalid expiry makes the request stale
+	annotations[ForceRequestExpiryAnnotation] = "tomorrow"
+	req, _ = ForceRequest(annotations)
+	if !req.InvalidExpiry || req.Fresh("token-1", now) {
+		t.Error("expected request with invalid expiry not to be fresh")
+	}
+
+	// reconcile requests are independent of force requests
+	if _, ok := ReconcileRequest(annotations); ok {
+		t.Error("expected ReconcileRequest to return false when the annotation is not set")
+	}
+	annotations = SetReconcileRequest(annotations, "token-3", time.Time{})
+	val, _ := ReconcileAnnotationValue(annotations)
+	if val != "token-3" {
+		t.Errorf("expected reconcile annotation value %q, got %q", "token-3", val)
+	}
+}
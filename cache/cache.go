@@ -0,0 +1,308 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cache provides a typed in-memory cache with TTL and LRU
+// eviction, which loads missing entries at most once for concurrent
+// callers.
+package cache
+
+import (
+	"container/list"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrInvalidCapacity is returned when the capacity of the cache is negative.
+var ErrInvalidCapacity = errors.New("cache capacity must not be negative")
+
+// ErrLoadPanicked is returned to the callers waiting on a Loader call which
+// panicked.
+var ErrLoadPanicked = errors.New("cache loader panicked")
+
+// Loader loads the value for a key that is not in the cache. It returns
+// the value and the duration it is valid for. A zero duration means the
+// default TTL of the cache applies, and a negative duration means the
+// value is returned without being cached.
+type Loader[V any] func() (value V, ttl time.Duration, err error)
+
+// Cache is a typed cache with TTL and LRU eviction. It is safe for
+// concurrent use.
+type Cache[K comparable, V any] struct {
+	capacity int
+	ttl      time.Duration
+	metrics  *metrics
+	now      func() time.Time
+
+	mu    sync.Mutex
+	items map[K]*list.Element
+	// lru holds the items, the most recently used at the front.
+	lru   *list.List
+	calls map[K]*call[V]
+}
+
+type item[K comparable, V any] struct {
+	key       K
+	value     V
+	expiresAt time.Time
+}
+
+// call is an in-flight or completed Loader call.
+type call[V any] struct {
+	wg    sync.WaitGroup
+	value V
+	err   error
+}
+
+type options struct {
+	capacity int
+	ttl      time.Duration
+	metrics  *metrics
+	now      func() time.Time
+}
+
+// Option configures a Cache.
+type Option func(*options) error
+
+// WithCapacity sets the maximum number of items in the cache. When the
+// capacity is exceeded, the least recently used item is evicted.
+// The default of zero means the number of items is not limited.
+func WithCapacity(capacity int) Option {
+	return func(o *options) error {
+		if capacity < 0 {
+			return ErrInvalidCapacity
+		}
+		o.capacity = capacity
+		return nil
+	}
+}
+
+// WithTTL sets the default duration items are valid for.
+// The default of zero means items do not expire.
+func WithTTL(ttl time.Duration) Option {
+	return func(o *options) error {
+		o.ttl = ttl
+		return nil
+	}
+}
+
+// WithClock sets the function used to obtain the current time, against
+// which the TTL of the items is evaluated. The default is time.Now.
+func WithClock(now func() time.Time) Option {
+	return func(o *options) error {
+		o.now = now
+		return nil
+	}
+}
+
+// New returns a Cache configured with the given options.
+func New[K comparable, V any](opts ...Option) (*Cache[K, V], error) {
+	o := &options{now: time.Now}
+	for _, opt := range opts {
+		if err := opt(o); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Cache[K, V]{
+		capacity: o.capacity,
+		ttl:      o.ttl,
+		metrics:  o.metrics,
+		now:      o.now,
+		items:    make(map[K]*list.Element),
+		lru:      list.New(),
+		calls:    make(map[K]*call[V]),
+	}, nil
+}
+
+// Get returns the value for the given key, and whether it was found.
+func (c *Cache[K, V]) Get(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.get(key)
+}
+
+// Set adds the value for the given key with the default TTL.
+func (c *Cache[K, V]) Set(key K, value V) {
+	c.SetWithTTL(key, value, 0)
+}
+
+// SetWithTTL adds the value for the given key, valid for the given
+// duration. A zero duration means the default TTL applies, and a negative
+// duration removes the value for the key instead.
+func (c *Cache[K, V]) SetWithTTL(key K, value V, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.set(key, value, ttl)
+}
+
+// Delete removes the value for the given key.
+func (c *Cache[K, V]) Delete(key K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.items[key]; ok {
+		c.remove(e)
+	}
+}
+
+// Items returns a copy of the items in the cache which have not expired.
+func (c *Cache[K, V]) Items() map[K]V {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	items := make(map[K]V, c.lru.Len())
+	for e := c.lru.Front(); e != nil; e = e.Next() {
+		it := e.Value.(*item[K, V])
+		if it.expiresAt.IsZero() || now.Before(it.expiresAt) {
+			items[it.key] = it.value
+		}
+	}
+	return items
+}
+
+// Len returns the number of items in the cache, including expired items
+// that have not been removed yet.
+func (c *Cache[K, V]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.lru.Len()
+}
+
+// GetOrLoad returns the value for the given key. If it is not found,
+// the value is loaded and added to the cache. Concurrent calls for the
+// same key share a single call to load, of which the result is returned
+// to all of them. Errors returned by load are not cached, nor are the
+// values for which it returns a negative duration.
+func (c *Cache[K, V]) GetOrLoad(key K, load Loader[V]) (V, error) {
+	c.mu.Lock()
+	if v, ok := c.get(key); ok {
+		c.mu.Unlock()
+		return v, nil
+	}
+	if cl, ok := c.calls[key]; ok {
+		c.mu.Unlock()
+		cl.wg.Wait()
+		return cl.value, cl.err
+	}
+	cl := &call[V]{}
+	cl.wg.Add(1)
+	c.calls[key] = cl
+	c.mu.Unlock()
+
+	c.load(key, cl, load)
+	return cl.value, cl.err
+}
+
+// load calls load for the given in-flight call, adds the loaded value to
+// the cache on success, and releases the callers waiting on the call. If
+// load panics, the waiting callers are released with ErrLoadPanicked before
+// the panic is propagated.
+func (c *Cache[K, V]) load(key K, cl *call[V], load Loader[V]) {
+	var ttl time.Duration
+	returned := false
+	defer func() {
+		var r any
+		if !returned {
+			r = recover()
+			var zero V
+			cl.value, cl.err = zero, fmt.Errorf("%w: %v", ErrLoadPanicked, r)
+		}
+
+		c.mu.Lock()
+		if cl.err == nil {
+			c.set(key, cl.value, ttl)
+		}
+		delete(c.calls, key)
+		c.mu.Unlock()
+		cl.wg.Done()
+
+		if r != nil {
+			panic(r)
+		}
+	}()
+
+	cl.value, ttl, cl.err = load()
+	returned = true
+}
+
+// get returns the value for the given key, removing it if it expired.
+// It must be called with the lock held.
+func (c *Cache[K, V]) get(key K) (V, bool) {
+	e, ok := c.items[key]
+	if !ok {
+		c.metrics.recordMiss()
+		var zero V
+		return zero, false
+	}
+
+	it := e.Value.(*item[K, V])
+	if !it.expiresAt.IsZero() && !c.now().Before(it.expiresAt) {
+		c.remove(e)
+		c.metrics.recordExpiration()
+		c.metrics.recordMiss()
+		var zero V
+		return zero, false
+	}
+
+	c.lru.MoveToFront(e)
+	c.metrics.recordHit()
+	return it.value, true
+}
+
+// set adds the value for the given key, evicting the least recently used
+// item if the capacity is exceeded. It must be called with the lock held.
+func (c *Cache[K, V]) set(key K, value V, ttl time.Duration) {
+	if ttl < 0 {
+		if e, ok := c.items[key]; ok {
+			c.remove(e)
+		}
+		return
+	}
+	if ttl == 0 {
+		ttl = c.ttl
+	}
+	var expiresAt time.Time
+	if ttl > 0 {
+		expiresAt = c.now().Add(ttl)
+	}
+
+	if e, ok := c.items[key]; ok {
+		it := e.Value.(*item[K, V])
+		it.value, it.expiresAt = value, expiresAt
+		c.lru.MoveToFront(e)
+		return
+	}
+
+	c.items[key] = c.lru.PushFront(&item[K, V]{key: key, value: value, expiresAt: expiresAt})
+	if c.capacity > 0 && c.lru.Len() > c.capacity {
+		c.remove(c.lru.Back())
+		c.metrics.recordEviction()
+	}
+	c.metrics.setItems(c.lru.Len())
+}
+
+// remove removes the given element. It must be called with the lock held.
+func (c *Cache[K, V]) remove(e *list.Element) {
+	c.lru.Remove(e)
+	delete(c.items, e.Value.(*item[K, V]).key)
+	c.metrics.setItems(c.lru.Len())
+}
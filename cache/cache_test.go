@@ -0,0 +1,220 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCache_TTL(t *testing.T) {
+	g := NewWithT(t)
+
+	now := time.Now()
+	c, err := New[string, string](WithTTL(time.Minute), WithClock(func() time.Time { return now }))
+	g.Expect(err).ToNot(HaveOccurred())
+
+	c.Set("default", "a")
+	c.SetWithTTL("short", "b", time.Second)
+	c.SetWithTTL("uncached", "c", -1)
+	g.Expect(c.Items()).To(Equal(map[string]string{"default": "a", "short": "b"}))
+
+	v, ok := c.Get("short")
+	g.Expect(ok).To(BeTrue())
+	g.Expect(v).To(Equal("b"))
+
+	now = now.Add(2 * time.Second)
+	g.Expect(c.Items()).To(Equal(map[string]string{"default": "a"}))
+	_, ok = c.Get("short")
+	g.Expect(ok).To(BeFalse())
+	v, ok = c.Get("default")
+	g.Expect(ok).To(BeTrue())
+	g.Expect(v).To(Equal("a"))
+
+	now = now.Add(time.Minute)
+	_, ok = c.Get("default")
+	g.Expect(ok).To(BeFalse())
+	g.Expect(c.Len()).To(Equal(0))
+}
+
+func TestCache_LRU(t *testing.T) {
+	g := NewWithT(t)
+
+	c, err := New[string, int](WithCapacity(2))
+	g.Expect(err).ToNot(HaveOccurred())
+
+	c.Set("a", 1)
+	c.Set("b", 2)
+	_, ok := c.Get("a")
+	g.Expect(ok).To(BeTrue())
+
+	// "b" is the least recently used item.
+	c.Set("c", 3)
+	g.Expect(c.Len()).To(Equal(2))
+	_, ok = c.Get("b")
+	g.Expect(ok).To(BeFalse())
+	_, ok = c.Get("a")
+	g.Expect(ok).To(BeTrue())
+	_, ok = c.Get("c")
+	g.Expect(ok).To(BeTrue())
+
+	c.Delete("a")
+	_, ok = c.Get("a")
+	g.Expect(ok).To(BeFalse())
+
+	_, err = New[string, int](WithCapacity(-1))
+	g.Expect(err).To(MatchError(ErrInvalidCapacity))
+}
+
+func TestCache_GetOrLoad(t *testing.T) {
+	g := NewWithT(t)
+
+	c, err := New[string, string]()
+	g.Expect(err).ToNot(HaveOccurred())
+
+	var calls atomic.Int32
+	release := make(chan struct{})
+	load := func() (string, time.Duration, error) {
+		calls.Add(1)
+		<-release
+		return "token", 0, nil
+	}
+
+	var wg sync.WaitGroup
+	results := make([]string, 10)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			v, err := c.GetOrLoad("key", load)
+			if err == nil {
+				results[i] = v
+			}
+		}(i)
+	}
+	g.Eventually(calls.Load).Should(Equal(int32(1)))
+	close(release)
+	wg.Wait()
+
+	g.Expect(calls.Load()).To(Equal(int32(1)))
+	for _, v := range results {
+		g.Expect(v).To(Equal("token"))
+	}
+
+	// Errors are not cached.
+	loadErr := errors.New("load failed")
+	_, err = c.GetOrLoad("failing", func() (string, time.Duration, error) {
+		return "", 0, loadErr
+	})
+	g.Expect(err).To(MatchError(loadErr))
+	_, ok := c.Get("failing")
+	g.Expect(ok).To(BeFalse())
+
+	// Values loaded with a negative TTL are returned but not cached.
+	v, err := c.GetOrLoad("expired", func() (string, time.Duration, error) {
+		return "stale", -1, nil
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(v).To(Equal("stale"))
+	_, ok = c.Get("expired")
+	g.Expect(ok).To(BeFalse())
+}
+
+func TestCache_GetOrLoadPanic(t *testing.T) {
+	g := NewWithT(t)
+
+	c, err := New[string, string]()
+	g.Expect(err).ToNot(HaveOccurred())
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	panicked := make(chan any)
+	go func() {
+		defer func() { panicked <- recover() }()
+		c.GetOrLoad("key", func() (string, time.Duration, error) {
+			close(started)
+			<-release
+			panic("boom")
+		})
+	}()
+	<-started
+
+	c.mu.Lock()
+	cl := c.calls["key"]
+	c.mu.Unlock()
+	g.Expect(cl).ToNot(BeNil())
+	close(release)
+
+	g.Expect(<-panicked).To(Equal("boom"))
+	// The callers waiting on the call are released with an error.
+	cl.wg.Wait()
+	g.Expect(cl.err).To(MatchError(ErrLoadPanicked))
+
+	// The failed call is released, a later call loads the value again.
+	v, err := c.GetOrLoad("key", func() (string, time.Duration, error) {
+		return "token", 0, nil
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(v).To(Equal("token"))
+}
+
+func TestCache_Metrics(t *testing.T) {
+	g := NewWithT(t)
+
+	reg := prometheus.NewRegistry()
+	now := time.Now()
+	c, err := New[string, string](WithCapacity(1), WithMetrics(reg, "test"), WithClock(func() time.Time { return now }))
+	g.Expect(err).ToNot(HaveOccurred())
+
+	c.Get("a")
+	c.Set("a", "a")
+	c.Get("a")
+	c.SetWithTTL("b", "b", time.Second)
+	now = now.Add(time.Minute)
+	c.Get("b")
+
+	events := c.metrics.events
+	g.Expect(testutil.ToFloat64(events.WithLabelValues(CacheEventTypeHit))).To(Equal(float64(1)))
+	g.Expect(testutil.ToFloat64(events.WithLabelValues(CacheEventTypeMiss))).To(Equal(float64(2)))
+	g.Expect(testutil.ToFloat64(events.WithLabelValues(CacheEventTypeEviction))).To(Equal(float64(1)))
+	g.Expect(testutil.ToFloat64(events.WithLabelValues(CacheEventTypeExpiration))).To(Equal(float64(1)))
+	g.Expect(testutil.ToFloat64(c.metrics.items)).To(Equal(float64(0)))
+
+	_, err = New[string, string](WithMetrics(reg, "test"))
+	g.Expect(err).To(HaveOccurred())
+
+	// A failed registration does not leave any collector registered.
+	g.Expect(reg.Register(prometheus.NewGauge(prometheus.GaugeOpts{
+		Name:        "gotk_cache_items",
+		Help:        "Total number of items in the cache.",
+		ConstLabels: prometheus.Labels{"name": "partial"},
+	}))).To(Succeed())
+	_, err = New[string, string](WithMetrics(reg, "partial"))
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(reg.Register(prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name:        "gotk_cache_events_total",
+		Help:        "Total number of cache events by type.",
+		ConstLabels: prometheus.Labels{"name": "partial"},
+	}, []string{"event_type"}))).To(Succeed())
+}
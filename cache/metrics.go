@@ -0,0 +1,110 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// CacheEventTypeHit is the event type for a cache hit.
+	CacheEventTypeHit = "cache_hit"
+	// CacheEventTypeMiss is the event type for a cache miss.
+	CacheEventTypeMiss = "cache_miss"
+	// CacheEventTypeEviction is the event type for an item evicted
+	// because the capacity of the cache was exceeded.
+	CacheEventTypeEviction = "cache_eviction"
+	// CacheEventTypeExpiration is the event type for an expired item
+	// removed from the cache.
+	CacheEventTypeExpiration = "cache_expiration"
+)
+
+// metrics records the Prometheus metrics of a Cache. A nil *metrics
+// records nothing.
+type metrics struct {
+	events *prometheus.CounterVec
+	items  prometheus.Gauge
+}
+
+// WithMetrics registers the metrics of the cache with the given
+// registerer, labeled with the given cache name. The metrics are the
+// gotk_cache_events_total counter, labeled with the event type, and the
+// gotk_cache_items gauge.
+func WithMetrics(reg prometheus.Registerer, name string) Option {
+	return func(o *options) error {
+		m := &metrics{
+			events: prometheus.NewCounterVec(
+				prometheus.CounterOpts{
+					Name:        "gotk_cache_events_total",
+					Help:        "Total number of cache events by type.",
+					ConstLabels: prometheus.Labels{"name": name},
+				},
+				[]string{"event_type"},
+			),
+			items: prometheus.NewGauge(
+				prometheus.GaugeOpts{
+					Name:        "gotk_cache_items",
+					Help:        "Total number of items in the cache.",
+					ConstLabels: prometheus.Labels{"name": name},
+				},
+			),
+		}
+		// Register all the collectors or none, so that the option can be
+		// retried with another registerer or name.
+		collectors := []prometheus.Collector{m.events, m.items}
+		for i, c := range collectors {
+			if err := reg.Register(c); err != nil {
+				for _, registered := range collectors[:i] {
+					reg.Unregister(registered)
+				}
+				return err
+			}
+		}
+		o.metrics = m
+		return nil
+	}
+}
+
+func (m *metrics) recordHit() {
+	m.record(CacheEventTypeHit)
+}
+
+func (m *metrics) recordMiss() {
+	m.record(CacheEventTypeMiss)
+}
+
+func (m *metrics) recordEviction() {
+	m.record(CacheEventTypeEviction)
+}
+
+func (m *metrics) recordExpiration() {
+	m.record(CacheEventTypeExpiration)
+}
+
+func (m *metrics) record(event string) {
+	if m == nil {
+		return
+	}
+	m.events.WithLabelValues(event).Inc()
+}
+
+func (m *metrics) setItems(n int) {
+	if m == nil {
+		return
+	}
+	m.items.Set(float64(n))
+}
@@ -0,0 +1,198 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chartutil
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Dependency is a dependency of a chart, as declared in the 'dependencies'
+// of its Chart.yaml.
+type Dependency struct {
+	// Name is the name of the subchart.
+	Name string `json:"name"`
+	// Alias is the name under which the subchart is installed, and its
+	// values are set, if different from Name.
+	Alias string `json:"alias,omitempty"`
+	// Condition is a comma-separated list of paths of boolean values, the
+	// first path found in the values enables or disables the subchart.
+	Condition string `json:"condition,omitempty"`
+	// Tags are the tags of the subchart, enabled or disabled by the boolean
+	// values under the 'tags' key.
+	Tags []string `json:"tags,omitempty"`
+}
+
+// name returns the name under which the dependency is installed.
+func (d Dependency) name() string {
+	if d.Alias != "" {
+		return d.Alias
+	}
+	return d.Name
+}
+
+// DependencyStatus describes whether a dependency of a chart is enabled.
+type DependencyStatus struct {
+	// Name is the name under which the subchart is installed, i.e. its alias
+	// if any.
+	Name string `json:"name"`
+	// Chart is the name of the subchart.
+	Chart string `json:"chart"`
+	// Enabled is true if the subchart is rendered with the chart.
+	Enabled bool `json:"enabled"`
+	// Reason describes the condition, tag or default which enabled or
+	// disabled the subchart.
+	Reason string `json:"reason"`
+	// Missing is true if the subchart is enabled but not available.
+	Missing bool `json:"missing,omitempty"`
+	// Warnings are the conditions which could not be evaluated, e.g. because
+	// their value is not a boolean.
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// DependencyReport describes which dependencies of a chart are enabled for
+// a set of values.
+type DependencyReport struct {
+	Dependencies []DependencyStatus `json:"dependencies"`
+}
+
+// Enabled returns the names of the enabled dependencies.
+func (r *DependencyReport) Enabled() []string {
+	var names []string
+	for _, d := range r.Dependencies {
+		if d.Enabled {
+			names = append(names, d.Name)
+		}
+	}
+	return names
+}
+
+// Missing returns the names of the enabled dependencies which are not
+// available.
+func (r *DependencyReport) Missing() []string {
+	var names []string
+	for _, d := range r.Dependencies {
+		if d.Missing {
+			names = append(names, d.Name)
+		}
+	}
+	return names
+}
+
+// Err returns an error listing the missing dependencies, if any.
+func (r *DependencyReport) Err() error {
+	if missing := r.Missing(); len(missing) > 0 {
+		return fmt.Errorf("missing chart dependencies: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// ResolveDependencies evaluates the conditions and tags of the given
+// dependencies against the merged values of the chart, the same way Helm
+// does when rendering the chart, and reports which dependencies are enabled.
+// The available names are the names of the subcharts which are vendored in
+// the chart, or resolved from the chart repositories. The enabled
+// dependencies which are not available are reported as missing.
+//
+// A condition takes precedence over the tags of a dependency. A dependency
+// with tags is enabled if any of its tags is true, and disabled if all its
+// tags set in the values are false. The dependencies of the subcharts are
+// not evaluated.
+func ResolveDependencies(dependencies []Dependency, values map[string]interface{}, available []string) (*DependencyReport, error) {
+	normalized, err := NormalizeValues(values)
+	if err != nil {
+		return nil, err
+	}
+	availableNames := make(map[string]bool, len(available))
+	for _, name := range available {
+		availableNames[name] = true
+	}
+
+	report := &DependencyReport{}
+	for _, dep := range dependencies {
+		status := DependencyStatus{
+			Name:    dep.name(),
+			Chart:   dep.Name,
+			Enabled: true,
+			Reason:  "enabled by default",
+		}
+
+		if enabled, reason, ok := evaluateTags(dep.Tags, normalized); ok {
+			status.Enabled, status.Reason = enabled, reason
+		}
+		enabled, reason, warnings, ok := evaluateCondition(dep.Condition, normalized)
+		if ok {
+			status.Enabled, status.Reason = enabled, reason
+		}
+		status.Warnings = warnings
+
+		if status.Enabled && !availableNames[dep.Name] && !availableNames[status.Name] {
+			status.Missing = true
+		}
+		report.Dependencies = append(report.Dependencies, status)
+	}
+	return report, nil
+}
+
+// evaluateTags returns whether the given tags enable the dependency, and
+// false for ok if none of the tags are set in the values.
+func evaluateTags(tags []string, values map[string]interface{}) (enabled bool, reason string, ok bool) {
+	var disabledBy string
+	for _, tag := range tags {
+		v, found := lookupValue(values, []string{"tags", tag})
+		b, isBool := v.(bool)
+		if !found || !isBool {
+			continue
+		}
+		if b {
+			return true, fmt.Sprintf("tag '%s' is true", tag), true
+		}
+		if disabledBy == "" {
+			disabledBy = tag
+		}
+	}
+	if disabledBy != "" {
+		return false, fmt.Sprintf("tag '%s' is false", disabledBy), true
+	}
+	return false, "", false
+}
+
+// evaluateCondition returns whether the first path of the condition found
+// in the values enables the dependency, and false for ok if none of the
+// paths are found.
+func evaluateCondition(condition string, values map[string]interface{}) (enabled bool, reason string, warnings []string, ok bool) {
+	if condition == "" {
+		return false, "", nil, false
+	}
+	for _, p := range strings.Split(condition, ",") {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		v, found := lookupValue(values, strings.Split(p, "."))
+		if !found {
+			continue
+		}
+		b, isBool := v.(bool)
+		if !isBool {
+			warnings = append(warnings, fmt.Sprintf("condition path '%s' returned non-bool value", p))
+			continue
+		}
+		return b, fmt.Sprintf("condition '%s' is %t", p, b), warnings, true
+	}
+	return false, "", warnings, false
+}
@@ -0,0 +1,96 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chartutil
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestResolveDependencies(t *testing.T) {
+	dependencies := []Dependency{
+		{Name: "redis", Condition: "redis.enabled,global.redis.enabled"},
+		{Name: "postgresql", Alias: "db", Condition: "db.enabled", Tags: []string{"storage"}},
+		{Name: "frontend", Tags: []string{"ui", "web"}},
+		{Name: "metrics", Condition: "metrics.enabled"},
+		{Name: "backend"},
+	}
+	values := map[string]interface{}{
+		"redis":  map[interface{}]interface{}{"enabled": "yes"},
+		"global": map[string]interface{}{"redis": map[string]interface{}{"enabled": false}},
+		"db":     map[string]interface{}{"enabled": true},
+		"tags":   map[string]interface{}{"storage": false, "ui": false, "web": true},
+	}
+
+	report, err := ResolveDependencies(dependencies, values, []string{"redis", "postgresql", "metrics"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []DependencyStatus{
+		{
+			Name: "redis", Chart: "redis", Enabled: false, Reason: "condition 'global.redis.enabled' is false",
+			Warnings: []string{"condition path 'redis.enabled' returned non-bool value"},
+		},
+		{Name: "db", Chart: "postgresql", Enabled: true, Reason: "condition 'db.enabled' is true"},
+		{Name: "frontend", Chart: "frontend", Enabled: true, Reason: "tag 'web' is true", Missing: true},
+		{Name: "metrics", Chart: "metrics", Enabled: true, Reason: "enabled by default"},
+		{Name: "backend", Chart: "backend", Enabled: true, Reason: "enabled by default", Missing: true},
+	}
+	if !reflect.DeepEqual(report.Dependencies, want) {
+		t.Errorf("unexpected report:\n got: %+v\nwant: %+v", report.Dependencies, want)
+	}
+	if got := report.Enabled(); !reflect.DeepEqual(got, []string{"db", "frontend", "metrics", "backend"}) {
+		t.Errorf("unexpected enabled dependencies: %v", got)
+	}
+	if err := report.Err(); err == nil || err.Error() != "missing chart dependencies: frontend, backend" {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestResolveDependencies_Tags(t *testing.T) {
+	dependencies := []Dependency{{Name: "frontend", Tags: []string{"ui", "web"}}}
+
+	tests := []struct {
+		name    string
+		tags    map[string]interface{}
+		enabled bool
+	}{
+		{name: "no tags set", tags: nil, enabled: true},
+		{name: "all tags false", tags: map[string]interface{}{"ui": false, "web": false}, enabled: false},
+		{name: "one tag false", tags: map[string]interface{}{"ui": false}, enabled: false},
+		{name: "one tag true", tags: map[string]interface{}{"ui": false, "web": true}, enabled: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values := map[string]interface{}{}
+			if tt.tags != nil {
+				values["tags"] = tt.tags
+			}
+			report, err := ResolveDependencies(dependencies, values, []string{"frontend"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := report.Dependencies[0].Enabled; got != tt.enabled {
+				t.Errorf("expected enabled to be %t, got %t", tt.enabled, got)
+			}
+			if err := report.Err(); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,201 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chartutil
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// DigestAlgorithm is the algorithm of the digests computed by DigestValues.
+const DigestAlgorithm = "sha256"
+
+// SensitiveDigestAlgorithm is the algorithm of the digests replacing the
+// sensitive values when a key is configured with WithSensitiveKey.
+const SensitiveDigestAlgorithm = "hmac-sha256"
+
+// DigestOption is a function for configuring the computation of a values
+// digest.
+type DigestOption func(o *digestOptions)
+
+type digestOptions struct {
+	sensitivePaths [][]string
+	sensitiveKey   []byte
+}
+
+// WithSensitivePaths configures the dot-separated paths of the values which
+// are sensitive, e.g. 'auth.password' or 'users.*.token'. Each segment of a
+// path is matched against the keys of the values with path.Match. The
+// sensitive values are replaced with their digest by NormalizeValues.
+//
+// Without a key configured with WithSensitiveKey, the digest is an unsalted
+// SHA-256, from which low-entropy values such as passwords can be recovered
+// by brute force. The normalized values should then not be stored or logged
+// where the sensitive values themselves could not be.
+func WithSensitivePaths(paths ...string) DigestOption {
+	return func(o *digestOptions) {
+		for _, p := range paths {
+			o.sensitivePaths = append(o.sensitivePaths, strings.Split(p, "."))
+		}
+	}
+}
+
+// WithSensitiveKey configures the secret key used to replace the sensitive
+// values with their HMAC-SHA256, in the format '<SensitiveDigestAlgorithm>:<hex>',
+// instead of their plain digest. As long as the key is kept secret, the
+// normalized values can be stored or logged without revealing the sensitive
+// values. Changing the key changes the digest of the values.
+func WithSensitiveKey(key []byte) DigestOption {
+	return func(o *digestOptions) {
+		o.sensitiveKey = key
+	}
+}
+
+// NormalizeValues returns a copy of the given values in which all the maps
+// have string keys, and the sensitive values are replaced with their digest.
+// Nil maps are normalized to empty maps.
+func NormalizeValues(values map[string]interface{}, opts ...DigestOption) (map[string]interface{}, error) {
+	o := &digestOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	v, err := normalize(values, nil, o)
+	if err != nil {
+		return nil, err
+	}
+	return v.(map[string]interface{}), nil
+}
+
+// DigestValues returns the digest of the normalized values, in the format
+// '<algorithm>:<hex>'. The digest does not depend on the order of the keys
+// of the maps, and is equal for values which only differ by their numeric
+// types (e.g. int and float64) or the type of their map keys.
+func DigestValues(values map[string]interface{}, opts ...DigestOption) (string, error) {
+	normalized, err := NormalizeValues(values, opts...)
+	if err != nil {
+		return "", err
+	}
+	return digestOf(normalized)
+}
+
+// ValuesChanged compares the digest of the given values against the last
+// applied digest. It returns true if they differ, or if lastDigest is empty
+// or was computed with another algorithm, along with the digest of the
+// values.
+func ValuesChanged(lastDigest string, values map[string]interface{}, opts ...DigestOption) (bool, string, error) {
+	digest, err := DigestValues(values, opts...)
+	if err != nil {
+		return false, "", err
+	}
+	return lastDigest != digest, digest, nil
+}
+
+// normalize returns the normalized copy of v, found at the given path.
+func normalize(v interface{}, p []string, o *digestOptions) (interface{}, error) {
+	if matchesAny(p, o.sensitivePaths) {
+		if len(o.sensitiveKey) > 0 {
+			return hmacOf(v, o.sensitiveKey)
+		}
+		return digestOf(v)
+	}
+
+	switch t := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(t))
+		for k, e := range t {
+			n, err := normalize(e, append(p[:len(p):len(p)], k), o)
+			if err != nil {
+				return nil, err
+			}
+			out[k] = n
+		}
+		return out, nil
+	case map[interface{}]interface{}:
+		out := make(map[string]interface{}, len(t))
+		for k, e := range t {
+			key := fmt.Sprint(k)
+			if _, ok := out[key]; ok {
+				return nil, fmt.Errorf("duplicate key '%s' at '%s'", key, strings.Join(p, "."))
+			}
+			n, err := normalize(e, append(p[:len(p):len(p)], key), o)
+			if err != nil {
+				return nil, err
+			}
+			out[key] = n
+		}
+		return out, nil
+	case []interface{}:
+		out := make([]interface{}, len(t))
+		for i, e := range t {
+			n, err := normalize(e, p, o)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = n
+		}
+		return out, nil
+	default:
+		return v, nil
+	}
+}
+
+// matchesAny returns if the path matches any of the given patterns.
+func matchesAny(p []string, patterns [][]string) bool {
+	for _, pattern := range patterns {
+		if len(pattern) != len(p) || len(p) == 0 {
+			continue
+		}
+		matched := true
+		for i := range pattern {
+			if ok, _ := path.Match(pattern[i], p[i]); !ok {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+// digestOf returns the digest of the JSON encoding of v. The keys of maps
+// are sorted by encoding/json, which makes the encoding stable.
+func digestOf(v interface{}) (string, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode values: %w", err)
+	}
+	sum := sha256.Sum256(b)
+	return DigestAlgorithm + ":" + hex.EncodeToString(sum[:]), nil
+}
+
+// hmacOf returns the HMAC-SHA256 of the JSON encoding of v with the given key.
+func hmacOf(v interface{}, key []byte) (string, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode values: %w", err)
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write(b)
+	return SensitiveDigestAlgorithm + ":" + hex.EncodeToString(mac.Sum(nil)), nil
+}
@@ -0,0 +1,181 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chartutil
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDigestValues(t *testing.T) {
+	tests := []struct {
+		name  string
+		a     map[string]interface{}
+		b     map[string]interface{}
+		opts  []DigestOption
+		equal bool
+	}{
+		{
+			name:  "nil and empty values",
+			a:     nil,
+			b:     map[string]interface{}{},
+			equal: true,
+		},
+		{
+			name:  "numeric types",
+			a:     map[string]interface{}{"replicas": 2},
+			b:     map[string]interface{}{"replicas": float64(2)},
+			equal: true,
+		},
+		{
+			name: "map key types",
+			a: map[string]interface{}{
+				"image": map[interface{}]interface{}{"tag": "1.0.0", "repository": "app"},
+			},
+			b: map[string]interface{}{
+				"image": map[string]interface{}{"repository": "app", "tag": "1.0.0"},
+			},
+			equal: true,
+		},
+		{
+			name:  "different values",
+			a:     map[string]interface{}{"replicas": 2},
+			b:     map[string]interface{}{"replicas": 3},
+			equal: false,
+		},
+		{
+			name:  "different sensitive values",
+			a:     map[string]interface{}{"auth": map[string]interface{}{"password": "foo"}},
+			b:     map[string]interface{}{"auth": map[string]interface{}{"password": "bar"}},
+			opts:  []DigestOption{WithSensitivePaths("auth.password")},
+			equal: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := DigestValues(tt.a, tt.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := DigestValues(tt.b, tt.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.HasPrefix(a, DigestAlgorithm+":") {
+				t.Errorf("unexpected digest format: %s", a)
+			}
+			if (a == b) != tt.equal {
+				t.Errorf("expected digests equal to be %v, got %s and %s", tt.equal, a, b)
+			}
+		})
+	}
+}
+
+func TestNormalizeValues(t *testing.T) {
+	values := map[string]interface{}{
+		"auth": map[string]interface{}{
+			"password": "secret",
+			"username": "user",
+		},
+		"users": []interface{}{
+			map[interface{}]interface{}{"name": "a", "token": "t1"},
+		},
+		"extra": map[interface{}]interface{}{
+			"token": map[string]interface{}{"value": "t2"},
+		},
+	}
+
+	normalized, err := NormalizeValues(values, WithSensitivePaths("auth.password", "users.token", "*.token"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	auth := normalized["auth"].(map[string]interface{})
+	if auth["username"] != "user" {
+		t.Errorf("expected username to be kept, got %v", auth["username"])
+	}
+	if p := auth["password"].(string); !strings.HasPrefix(p, DigestAlgorithm+":") || strings.Contains(p, "secret") {
+		t.Errorf("expected password to be hashed, got %s", p)
+	}
+
+	user := normalized["users"].([]interface{})[0].(map[string]interface{})
+	if tok := user["token"].(string); !strings.HasPrefix(tok, DigestAlgorithm+":") {
+		t.Errorf("expected token in list to be hashed, got %s", tok)
+	}
+
+	extra := normalized["extra"].(map[string]interface{})
+	if _, ok := extra["token"].(string); !ok {
+		t.Errorf("expected nested token map to be hashed, got %v", extra["token"])
+	}
+
+	// The input is not modified.
+	if values["auth"].(map[string]interface{})["password"] != "secret" {
+		t.Error("expected input values to be unmodified")
+	}
+
+	// With a key, the sensitive values are replaced with their HMAC.
+	withKey, err := NormalizeValues(values, WithSensitivePaths("auth.password"), WithSensitiveKey([]byte("key")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyed := withKey["auth"].(map[string]interface{})["password"].(string)
+	if !strings.HasPrefix(keyed, SensitiveDigestAlgorithm+":") {
+		t.Errorf("expected password to be hashed with HMAC, got %s", keyed)
+	}
+	otherKey, err := NormalizeValues(values, WithSensitivePaths("auth.password"), WithSensitiveKey([]byte("other")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keyed == otherKey["auth"].(map[string]interface{})["password"] {
+		t.Error("expected HMAC to depend on the key")
+	}
+
+	_, err = NormalizeValues(map[string]interface{}{
+		"dup": map[interface{}]interface{}{1: "a", "1": "b"},
+	})
+	if err == nil {
+		t.Error("expected error for duplicate keys")
+	}
+}
+
+func TestValuesChanged(t *testing.T) {
+	values := map[string]interface{}{"replicas": 2}
+
+	changed, digest, err := ValuesChanged("", values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !changed {
+		t.Error("expected values to be changed without a last digest")
+	}
+
+	changed, again, err := ValuesChanged(digest, map[string]interface{}{"replicas": float64(2)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed || again != digest {
+		t.Errorf("expected values to be unchanged, got %s and %s", digest, again)
+	}
+
+	changed, _, err = ValuesChanged("sha512:"+strings.TrimPrefix(digest, "sha256:"), values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !changed {
+		t.Error("expected values to be changed for a digest of another algorithm")
+	}
+}
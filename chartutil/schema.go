@@ -0,0 +1,132 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chartutil
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// ValuesSchemaFile is the name of the JSON Schema file bundled with a chart.
+const ValuesSchemaFile = "values.schema.json"
+
+// ValuesSchema is a JSON Schema the values are validated against.
+type ValuesSchema struct {
+	// Name identifies the schema in the violations, e.g. 'values.schema.json'
+	// for the schema of the chart.
+	Name string
+	// Path is the dot-separated path of the values the schema applies to,
+	// e.g. the name of a subchart. The schema applies to all the values if
+	// empty.
+	Path string
+	// Data is the JSON encoded schema.
+	Data []byte
+}
+
+// SchemaViolation is a value which does not conform to a schema.
+type SchemaViolation struct {
+	// Schema is the name of the violated schema.
+	Schema string `json:"schema"`
+	// Path is the dot-separated path of the value in the merged values, or
+	// '(root)' for the values themselves.
+	Path string `json:"path"`
+	// Type is the type of the violation, e.g. 'required' or 'invalid_type'.
+	Type string `json:"type"`
+	// Message describes the violation.
+	Message string `json:"message"`
+}
+
+// String returns the violation in the format '<schema>: <path>: <message>'.
+func (v SchemaViolation) String() string {
+	return fmt.Sprintf("%s: %s: %s", v.Schema, v.Path, v.Message)
+}
+
+// SchemaValidationError is returned by ValidateValues when the values do not
+// conform to the schemas.
+type SchemaValidationError struct {
+	Violations []SchemaViolation
+}
+
+// Error returns the violations, one per line.
+func (e *SchemaValidationError) Error() string {
+	lines := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		lines[i] = "- " + v.String()
+	}
+	return "values don't meet the specifications of the schema(s):\n" + strings.Join(lines, "\n")
+}
+
+// ValidateValues validates the fully merged values against the given
+// schemas, e.g. the values.schema.json of the chart and of its subcharts,
+// and any schema supplied by the user. The validation uses the same JSON
+// Schema implementation as Helm, so the values which pass the validation
+// are accepted by Helm on install and upgrade.
+//
+// It returns a *SchemaValidationError listing the violations of all the
+// schemas, or an error if a schema can not be loaded.
+func ValidateValues(values map[string]interface{}, schemas ...ValuesSchema) error {
+	normalized, err := NormalizeValues(values)
+	if err != nil {
+		return err
+	}
+
+	var violations []SchemaViolation
+	for _, s := range schemas {
+		var document interface{} = map[string]interface{}{}
+		var prefix []string
+		if s.Path != "" {
+			prefix = strings.Split(s.Path, ".")
+			if v, ok := lookupValue(normalized, prefix); ok && v != nil {
+				document = v
+			}
+		} else {
+			document = normalized
+		}
+
+		result, err := gojsonschema.Validate(gojsonschema.NewBytesLoader(s.Data), gojsonschema.NewGoLoader(document))
+		if err != nil {
+			return fmt.Errorf("failed to validate values against schema '%s': %w", s.Name, err)
+		}
+		for _, e := range result.Errors() {
+			violations = append(violations, SchemaViolation{
+				Schema:  s.Name,
+				Path:    violationPath(prefix, e.Field()),
+				Type:    e.Type(),
+				Message: e.Description(),
+			})
+		}
+	}
+
+	if len(violations) > 0 {
+		return &SchemaValidationError{Violations: violations}
+	}
+	return nil
+}
+
+// violationPath returns the path of a violated field, relative to the root
+// of the merged values.
+func violationPath(prefix []string, field string) string {
+	if len(prefix) == 0 {
+		return field
+	}
+	if field == gojsonschema.STRING_CONTEXT_ROOT {
+		return strings.Join(prefix, ".")
+	}
+	return strings.Join(prefix, ".") + "." + field
+}
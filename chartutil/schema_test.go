@@ -0,0 +1,129 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chartutil
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidateValues(t *testing.T) {
+	chartSchema := ValuesSchema{
+		Name: ValuesSchemaFile,
+		Data: []byte(`{
+  "type": "object",
+  "required": ["image"],
+  "properties": {
+    "replicas": {"type": "integer", "minimum": 1},
+    "image": {
+      "type": "object",
+      "required": ["repository"],
+      "properties": {"tag": {"type": "string"}}
+    }
+  }
+}`),
+	}
+	subchartSchema := ValuesSchema{
+		Name: "redis/" + ValuesSchemaFile,
+		Path: "redis",
+		Data: []byte(`{"type": "object", "properties": {"port": {"type": "integer"}}}`),
+	}
+	userSchema := ValuesSchema{
+		Name: "policy",
+		Data: []byte(`{"properties": {"replicas": {"maximum": 3}}}`),
+	}
+
+	tests := []struct {
+		name       string
+		values     map[string]interface{}
+		violations []SchemaViolation
+	}{
+		{
+			name: "valid values",
+			values: map[string]interface{}{
+				"replicas": 2,
+				"image":    map[interface{}]interface{}{"repository": "podinfo", "tag": "6.0.0"},
+				"redis":    map[string]interface{}{"port": 6379},
+			},
+		},
+		{
+			name: "violations of all schemas",
+			values: map[string]interface{}{
+				"replicas": 5,
+				"image":    map[string]interface{}{"tag": 6},
+				"redis":    map[string]interface{}{"port": "6379"},
+			},
+			violations: []SchemaViolation{
+				{Schema: ValuesSchemaFile, Path: "image", Type: "required", Message: "repository is required"},
+				{Schema: ValuesSchemaFile, Path: "image.tag", Type: "invalid_type", Message: "Invalid type. Expected: string, given: integer"},
+				{Schema: "redis/" + ValuesSchemaFile, Path: "redis.port", Type: "invalid_type", Message: "Invalid type. Expected: integer, given: string"},
+				{Schema: "policy", Path: "replicas", Type: "number_lte", Message: "Must be less than or equal to 3"},
+			},
+		},
+		{
+			name:   "missing required root value",
+			values: map[string]interface{}{"replicas": 1},
+			violations: []SchemaViolation{
+				{Schema: ValuesSchemaFile, Path: "(root)", Type: "required", Message: "image is required"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateValues(tt.values, chartSchema, subchartSchema, userSchema)
+			if tt.violations == nil {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			var validationErr *SchemaValidationError
+			if !errors.As(err, &validationErr) {
+				t.Fatalf("expected a SchemaValidationError, got: %v", err)
+			}
+			if !reflect.DeepEqual(validationErr.Violations, tt.violations) {
+				t.Errorf("unexpected violations:\n got: %+v\nwant: %+v", validationErr.Violations, tt.violations)
+			}
+		})
+	}
+}
+
+func TestValidateValues_invalidSchema(t *testing.T) {
+	err := ValidateValues(map[string]interface{}{}, ValuesSchema{Name: "broken", Data: []byte(`{"type": 1}`)})
+	if err == nil || !strings.Contains(err.Error(), "schema 'broken'") {
+		t.Errorf("expected schema error, got: %v", err)
+	}
+	var validationErr *SchemaValidationError
+	if errors.As(err, &validationErr) {
+		t.Error("expected an invalid schema to not be reported as a violation")
+	}
+}
+
+func TestSchemaValidationError_Error(t *testing.T) {
+	err := &SchemaValidationError{Violations: []SchemaViolation{
+		{Schema: ValuesSchemaFile, Path: "image", Type: "required", Message: "repository is required"},
+		{Schema: "policy", Path: "replicas", Type: "number_lte", Message: "Must be less than or equal to 3"},
+	}}
+	want := `values don't meet the specifications of the schema(s):
+- values.schema.json: image: repository is required
+- policy: replicas: Must be less than or equal to 3`
+	if got := err.Error(); got != want {
+		t.Errorf("unexpected error message:\n got: %s\nwant: %s", got, want)
+	}
+}
@@ -0,0 +1,210 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chartutil
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ValuesSourceKind is the kind of a source of values.
+type ValuesSourceKind string
+
+const (
+	// ValuesSourceChart is the kind of the default values of a chart.
+	ValuesSourceChart ValuesSourceKind = "Chart"
+	// ValuesSourceConfigMap is the kind of the values referenced from a
+	// ConfigMap.
+	ValuesSourceConfigMap ValuesSourceKind = "ConfigMap"
+	// ValuesSourceSecret is the kind of the values referenced from a Secret.
+	ValuesSourceSecret ValuesSourceKind = "Secret"
+	// ValuesSourceHelmRelease is the kind of the inline values of a
+	// HelmRelease.
+	ValuesSourceHelmRelease ValuesSourceKind = "HelmRelease"
+)
+
+// ValuesSource describes where a set of values comes from.
+type ValuesSource struct {
+	// Kind is the kind of the source.
+	Kind ValuesSourceKind `json:"kind"`
+	// Name is the name of the source object, e.g. '<namespace>/<name>'.
+	Name string `json:"name,omitempty"`
+	// ValuesKey is the key of the ConfigMap or Secret data the values are
+	// read from.
+	ValuesKey string `json:"valuesKey,omitempty"`
+}
+
+// String returns the source in the format '<kind>/<name>[<key>]'.
+func (s ValuesSource) String() string {
+	str := string(s.Kind)
+	if s.Name != "" {
+		str += "/" + s.Name
+	}
+	if s.ValuesKey != "" {
+		str += "[" + s.ValuesKey + "]"
+	}
+	return str
+}
+
+// SourcedValues are values along with their source.
+type SourcedValues struct {
+	Source ValuesSource
+	Values map[string]interface{}
+}
+
+// ValueProvenance describes which source supplied the final value at a path
+// of the merged values.
+type ValueProvenance struct {
+	// Path is the dot-separated path of the value.
+	Path string `json:"path"`
+	// Source is the source which supplied the value.
+	Source ValuesSource `json:"source"`
+	// Overridden are the sources which also set the path, but were
+	// overridden by Source, in the order of the merge.
+	Overridden []ValuesSource `json:"overridden,omitempty"`
+}
+
+// ProvenanceReport describes where the values at each path of the merged
+// values come from. Only the leaves of the merged values are reported, i.e.
+// the values which are not maps, and the empty maps.
+type ProvenanceReport struct {
+	Values []ValueProvenance `json:"values"`
+}
+
+// Lookup returns the provenance of the value at the given dot-separated path,
+// or false if the path is not a leaf of the merged values.
+func (r *ProvenanceReport) Lookup(path string) (ValueProvenance, bool) {
+	i := sort.Search(len(r.Values), func(i int) bool {
+		return r.Values[i].Path >= path
+	})
+	if i < len(r.Values) && r.Values[i].Path == path {
+		return r.Values[i], true
+	}
+	return ValueProvenance{}, false
+}
+
+// String returns the report with a line per path, in the format
+// '<path>: <source>', followed by the overridden sources, if any.
+func (r *ProvenanceReport) String() string {
+	var b strings.Builder
+	for _, v := range r.Values {
+		fmt.Fprintf(&b, "%s: %s", v.Path, v.Source)
+		if len(v.Overridden) > 0 {
+			overridden := make([]string, len(v.Overridden))
+			for i, s := range v.Overridden {
+				overridden[i] = s.String()
+			}
+			fmt.Fprintf(&b, " (overrides %s)", strings.Join(overridden, ", "))
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// MergeValuesWithProvenance merges the given values in order, the values of
+// a source taking precedence over the values of the previous sources, and
+// reports which source supplied each value of the result. To match the
+// precedence applied by the helm-controller, the sources are expected in
+// the order: chart defaults, ConfigMaps and Secrets referenced in the
+// 'valuesFrom' of the HelmRelease in their declared order, and lastly the
+// inline values of the HelmRelease.
+//
+// Maps are merged recursively, any other value replaces the previous one.
+// A null value removes the key from the result, as Helm does when
+// coalescing the values with the chart defaults.
+func MergeValuesWithProvenance(sources ...SourcedValues) (map[string]interface{}, *ProvenanceReport, error) {
+	normalized := make([]map[string]interface{}, len(sources))
+	result := map[string]interface{}{}
+	for i, s := range sources {
+		v, err := NormalizeValues(s.Values)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid values from %s: %w", s.Source, err)
+		}
+		normalized[i] = v
+		mergeValues(result, v)
+	}
+
+	report := &ProvenanceReport{}
+	walkLeaves(result, nil, func(p []string) {
+		provenance := ValueProvenance{Path: strings.Join(p, ".")}
+		found := false
+		for i := len(sources) - 1; i >= 0; i-- {
+			if v, ok := lookupValue(normalized[i], p); !ok || v == nil {
+				continue
+			}
+			if !found {
+				provenance.Source, found = sources[i].Source, true
+				continue
+			}
+			provenance.Overridden = append([]ValuesSource{sources[i].Source}, provenance.Overridden...)
+		}
+		report.Values = append(report.Values, provenance)
+	})
+	sort.Slice(report.Values, func(i, j int) bool {
+		return report.Values[i].Path < report.Values[j].Path
+	})
+	return result, report, nil
+}
+
+// mergeValues merges the normalized src values into dst.
+func mergeValues(dst, src map[string]interface{}) {
+	for k, v := range src {
+		if v == nil {
+			delete(dst, k)
+			continue
+		}
+		if m, ok := v.(map[string]interface{}); ok {
+			d, ok := dst[k].(map[string]interface{})
+			if !ok {
+				d = map[string]interface{}{}
+				dst[k] = d
+			}
+			mergeValues(d, m)
+			continue
+		}
+		dst[k] = v
+	}
+}
+
+// walkLeaves calls fn with the path of the leaves of the values, i.e. the
+// values which are not maps, and the empty maps.
+func walkLeaves(values map[string]interface{}, p []string, fn func(p []string)) {
+	for k, v := range values {
+		kp := append(p[:len(p):len(p)], k)
+		if m, ok := v.(map[string]interface{}); ok && len(m) > 0 {
+			walkLeaves(m, kp, fn)
+			continue
+		}
+		fn(kp)
+	}
+}
+
+// lookupValue returns the value at the given path of the normalized values.
+func lookupValue(values map[string]interface{}, p []string) (interface{}, bool) {
+	var v interface{} = values
+	for _, k := range p {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if v, ok = m[k]; !ok {
+			return nil, false
+		}
+	}
+	return v, true
+}
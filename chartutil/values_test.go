@@ -0,0 +1,134 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chartutil
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMergeValuesWithProvenance(t *testing.T) {
+	chart := ValuesSource{Kind: ValuesSourceChart, Name: "podinfo"}
+	configMap := ValuesSource{Kind: ValuesSourceConfigMap, Name: "default/values", ValuesKey: "values.yaml"}
+	secret := ValuesSource{Kind: ValuesSourceSecret, Name: "default/creds", ValuesKey: "values.yaml"}
+	release := ValuesSource{Kind: ValuesSourceHelmRelease, Name: "default/podinfo"}
+
+	values, report, err := MergeValuesWithProvenance(
+		SourcedValues{Source: chart, Values: map[string]interface{}{
+			"replicas": 1,
+			"image":    map[string]interface{}{"repository": "podinfo", "tag": "6.0.0"},
+			"ingress":  map[string]interface{}{"enabled": false, "hosts": []interface{}{"a"}},
+			"debug":    true,
+		}},
+		SourcedValues{Source: configMap, Values: map[string]interface{}{
+			"replicas": 2,
+			"image":    map[interface{}]interface{}{"tag": "6.1.0"},
+			"ingress":  map[string]interface{}{"hosts": []interface{}{"b", "c"}},
+		}},
+		SourcedValues{Source: secret, Values: map[string]interface{}{
+			"auth": map[string]interface{}{"password": "secret"},
+		}},
+		SourcedValues{Source: release, Values: map[string]interface{}{
+			"replicas":    3,
+			"debug":       nil,
+			"annotations": map[string]interface{}{},
+		}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantValues := map[string]interface{}{
+		"replicas":    3,
+		"image":       map[string]interface{}{"repository": "podinfo", "tag": "6.1.0"},
+		"ingress":     map[string]interface{}{"enabled": false, "hosts": []interface{}{"b", "c"}},
+		"auth":        map[string]interface{}{"password": "secret"},
+		"annotations": map[string]interface{}{},
+	}
+	if !reflect.DeepEqual(values, wantValues) {
+		t.Errorf("unexpected values:\n got: %v\nwant: %v", values, wantValues)
+	}
+
+	want := []ValueProvenance{
+		{Path: "annotations", Source: release},
+		{Path: "auth.password", Source: secret},
+		{Path: "image.repository", Source: chart},
+		{Path: "image.tag", Source: configMap, Overridden: []ValuesSource{chart}},
+		{Path: "ingress.enabled", Source: chart},
+		{Path: "ingress.hosts", Source: configMap, Overridden: []ValuesSource{chart}},
+		{Path: "replicas", Source: release, Overridden: []ValuesSource{chart, configMap}},
+	}
+	if !reflect.DeepEqual(report.Values, want) {
+		t.Errorf("unexpected report:\n got: %+v\nwant: %+v", report.Values, want)
+	}
+
+	if p, ok := report.Lookup("image.tag"); !ok || p.Source != configMap {
+		t.Errorf("unexpected lookup result: %+v, %t", p, ok)
+	}
+	if _, ok := report.Lookup("debug"); ok {
+		t.Error("expected removed value to not be reported")
+	}
+	if _, ok := report.Lookup("image"); ok {
+		t.Error("expected non-leaf value to not be reported")
+	}
+
+	wantString := `annotations: HelmRelease/default/podinfo
+auth.password: Secret/default/creds[values.yaml]
+image.repository: Chart/podinfo
+image.tag: ConfigMap/default/values[values.yaml] (overrides Chart/podinfo)
+ingress.enabled: Chart/podinfo
+ingress.hosts: ConfigMap/default/values[values.yaml] (overrides Chart/podinfo)
+replicas: HelmRelease/default/podinfo (overrides Chart/podinfo, ConfigMap/default/values[values.yaml])
+`
+	if got := report.String(); got != wantString {
+		t.Errorf("unexpected report string:\n got: %s\nwant: %s", got, wantString)
+	}
+}
+
+func TestMergeValuesWithProvenance_replacedMap(t *testing.T) {
+	chart := ValuesSource{Kind: ValuesSourceChart}
+	release := ValuesSource{Kind: ValuesSourceHelmRelease, Name: "default/app"}
+
+	values, report, err := MergeValuesWithProvenance(
+		SourcedValues{Source: chart, Values: map[string]interface{}{
+			"resources": "default",
+			"service":   map[string]interface{}{"port": 80},
+		}},
+		SourcedValues{Source: release, Values: map[string]interface{}{
+			"resources": map[string]interface{}{"limits": map[string]interface{}{"cpu": "1"}},
+			"service":   "none",
+		}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantValues := map[string]interface{}{
+		"resources": map[string]interface{}{"limits": map[string]interface{}{"cpu": "1"}},
+		"service":   "none",
+	}
+	if !reflect.DeepEqual(values, wantValues) {
+		t.Errorf("unexpected values:\n got: %v\nwant: %v", values, wantValues)
+	}
+	want := []ValueProvenance{
+		{Path: "resources.limits.cpu", Source: release},
+		{Path: "service", Source: release, Overridden: []ValuesSource{chart}},
+	}
+	if !reflect.DeepEqual(report.Values, want) {
+		t.Errorf("unexpected report:\n got: %+v\nwant: %+v", report.Values, want)
+	}
+}
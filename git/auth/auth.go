@@ -0,0 +1,187 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package auth provides means to obtain credentials for Git servers from
+// provider specific sources.
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/fluxcd/pkg/cache"
+	"github.com/fluxcd/pkg/git"
+)
+
+// ErrNoProvider is returned when none of the configured providers
+// supports the host of a repository URL.
+var ErrNoProvider = errors.New("no provider configured for host")
+
+// Provider knows how to obtain credentials for a Git server.
+type Provider interface {
+	// Name returns the name of the provider, for example: 'gitea'.
+	Name() string
+	// Supports returns true if the provider can obtain credentials for
+	// the given repository URL.
+	Supports(u *url.URL) bool
+	// Credentials returns the credentials to authenticate against the
+	// Git server of the given repository URL.
+	Credentials(ctx context.Context, u *url.URL) (*Credentials, error)
+}
+
+// Credentials contains the credentials obtained from a Provider.
+type Credentials struct {
+	// Username is the username used for HTTP basic authentication.
+	Username string
+	// Password is the password used for HTTP basic authentication.
+	Password string
+	// BearerToken is the token used for HTTP bearer authentication. It is
+	// mutually exclusive with Username and Password.
+	BearerToken string
+	// ExpiresAt is the time at which the credentials expire. The zero
+	// value means the credentials do not expire.
+	ExpiresAt time.Time
+}
+
+// ApplyTo configures the given git.AuthOptions to use the credentials.
+func (c *Credentials) ApplyTo(opts *git.AuthOptions) {
+	if c.BearerToken != "" {
+		opts.BearerToken = c.BearerToken
+		opts.Username = ""
+		opts.Password = ""
+		return
+	}
+	opts.Username = c.Username
+	opts.Password = c.Password
+	opts.BearerToken = ""
+}
+
+// RefreshBeforeExpiry is the time before their expiry at which cached
+// credentials are considered expired, and are refreshed.
+const RefreshBeforeExpiry = 5 * time.Minute
+
+// newCredentialsCache returns a cache for credentials which evaluates their
+// expiry against the given clock.
+func newCredentialsCache[K comparable](now func() time.Time) *cache.Cache[K, *Credentials] {
+	// The cache can only fail to be created with an invalid capacity.
+	c, _ := cache.New[K, *Credentials](cache.WithClock(now))
+	return c
+}
+
+// credentialsLoader returns a cache.Loader for the credentials obtained with
+// load, which caches them until RefreshBeforeExpiry before they expire.
+// Credentials which are about to expire are returned without being cached.
+func credentialsLoader(now func() time.Time, load func() (*Credentials, error)) cache.Loader[*Credentials] {
+	return func() (*Credentials, time.Duration, error) {
+		creds, err := load()
+		if err != nil {
+			return nil, 0, err
+		}
+		return creds, creds.ttl(now()), nil
+	}
+}
+
+// ttl returns the duration for which the credentials can be cached at the
+// given time, zero if they do not expire and a negative duration if they
+// expire within RefreshBeforeExpiry.
+func (c *Credentials) ttl(now time.Time) time.Duration {
+	if c.ExpiresAt.IsZero() {
+		return 0
+	}
+	if ttl := c.ExpiresAt.Add(-RefreshBeforeExpiry).Sub(now); ttl > 0 {
+		return ttl
+	}
+	return -1
+}
+
+// Manager obtains credentials for Git repositories from a set of
+// providers, and caches them until they expire.
+type Manager struct {
+	providers []Provider
+	cache     *cache.Cache[string, *Credentials]
+	now       func() time.Time
+	// mu serializes the writes to the token store.
+	mu    sync.Mutex
+	store TokenStore
+}
+
+// NewManager returns a Manager which obtains credentials from the given
+// providers. When multiple providers support the same host, the first
+// one takes precedence.
+func NewManager(providers ...Provider) *Manager {
+	m := &Manager{
+		providers: providers,
+		now:       time.Now,
+	}
+	m.cache = newCredentialsCache[string](func() time.Time { return m.now() })
+	return m
+}
+
+// Login returns the credentials for the given repository URL, obtained
+// from the first provider which supports it. Credentials are cached per
+// provider, host and user until they are about to expire, or until
+// Invalidate is called. Concurrent logins for the same provider, host and
+// user share a single request to the provider.
+// It returns ErrNoProvider if none of the providers supports the URL.
+func (m *Manager) Login(ctx context.Context, repoURL string) (*Credentials, error) {
+	u, err := url.Parse(repoURL)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse repository url: %w", err)
+	}
+
+	for _, p := range m.providers {
+		if !p.Supports(u) {
+			continue
+		}
+
+		loaded := false
+		creds, err := m.cache.GetOrLoad(cacheKey(p, u), credentialsLoader(m.now, func() (*Credentials, error) {
+			loaded = true
+			return p.Credentials(ctx, u)
+		}))
+		if err != nil {
+			return nil, fmt.Errorf("unable to get credentials from %s provider for '%s': %w", p.Name(), u.Host, err)
+		}
+		if loaded {
+			// Persisting is best-effort, errors are surfaced by Persist.
+			_ = m.Persist(ctx)
+		}
+		return creds, nil
+	}
+	return nil, fmt.Errorf("%w '%s'", ErrNoProvider, u.Host)
+}
+
+// Invalidate removes any cached credentials for the given repository URL.
+func (m *Manager) Invalidate(repoURL string) error {
+	u, err := url.Parse(repoURL)
+	if err != nil {
+		return fmt.Errorf("unable to parse repository url: %w", err)
+	}
+
+	for _, p := range m.providers {
+		m.cache.Delete(cacheKey(p, u))
+	}
+	_ = m.Persist(context.Background())
+	return nil
+}
+
+func cacheKey(p Provider, u *url.URL) string {
+	return fmt.Sprintf("%s/%s/%s", p.Name(), u.Host, u.User.Username())
+}
@@ -0,0 +1,186 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/fluxcd/pkg/git"
+)
+
+type countingProvider struct {
+	Provider
+	calls int
+}
+
+func (p *countingProvider) Credentials(ctx context.Context, u *url.URL) (*Credentials, error) {
+	p.calls++
+	return p.Provider.Credentials(ctx, u)
+}
+
+func TestManager_Login(t *testing.T) {
+	g := NewWithT(t)
+
+	gerrit := &countingProvider{Provider: &GerritProvider{
+		Host:      "gerrit.example.com",
+		Username:  "bot",
+		Passwords: map[string]string{"bot": "bot-pass", "alice": "alice-pass"},
+	}}
+	gitea := &countingProvider{Provider: &GiteaProvider{
+		Host:  "gitea.example.com",
+		Token: "token",
+	}}
+	m := NewManager(gerrit, gitea)
+
+	creds, err := m.Login(context.TODO(), "https://gerrit.example.com/a/project")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(*creds).To(Equal(Credentials{Username: "bot", Password: "bot-pass"}))
+
+	creds, err = m.Login(context.TODO(), "https://alice@gerrit.example.com/a/project")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(*creds).To(Equal(Credentials{Username: "alice", Password: "alice-pass"}))
+
+	creds, err = m.Login(context.TODO(), "https://gitea.example.com/org/repo")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(*creds).To(Equal(Credentials{Username: DefaultGiteaUsername, Password: "token"}))
+
+	// Credentials are served from the cache.
+	_, err = m.Login(context.TODO(), "https://gerrit.example.com/a/other")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(gerrit.calls).To(Equal(2))
+
+	g.Expect(m.Invalidate("https://gerrit.example.com/a/project")).To(Succeed())
+	_, err = m.Login(context.TODO(), "https://gerrit.example.com/a/project")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(gerrit.calls).To(Equal(3))
+
+	_, err = m.Login(context.TODO(), "https://github.com/fluxcd/flux2")
+	g.Expect(errors.Is(err, ErrNoProvider)).To(BeTrue())
+}
+
+type expiringProvider struct {
+	Provider
+	expiresAt time.Time
+	calls     int
+}
+
+func (p *expiringProvider) Credentials(ctx context.Context, u *url.URL) (*Credentials, error) {
+	p.calls++
+	creds, err := p.Provider.Credentials(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+	creds.ExpiresAt = p.expiresAt
+	return creds, nil
+}
+
+func TestManager_LoginExpired(t *testing.T) {
+	g := NewWithT(t)
+
+	now := time.Now()
+	p := &expiringProvider{
+		Provider:  &GiteaProvider{Host: "gitea.example.com", Token: "token"},
+		expiresAt: now.Add(RefreshBeforeExpiry + time.Minute),
+	}
+	m := NewManager(p)
+	m.now = func() time.Time { return now }
+
+	_, err := m.Login(context.TODO(), "https://gitea.example.com/org/repo")
+	g.Expect(err).ToNot(HaveOccurred())
+	_, err = m.Login(context.TODO(), "https://gitea.example.com/org/repo")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(p.calls).To(Equal(1))
+
+	// The credentials are refreshed when they are about to expire.
+	now = now.Add(time.Minute)
+	_, err = m.Login(context.TODO(), "https://gitea.example.com/org/repo")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(p.calls).To(Equal(2))
+
+	// Credentials which are about to expire are not cached.
+	_, err = m.Login(context.TODO(), "https://gitea.example.com/org/repo")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(p.calls).To(Equal(3))
+}
+
+func TestGerritProvider_Credentials(t *testing.T) {
+	tests := []struct {
+		name    string
+		url     string
+		want    *Credentials
+		wantErr string
+	}{
+		{
+			name: "user from url",
+			url:  "https://alice@gerrit.example.com/a/project",
+			want: &Credentials{Username: "alice", Password: "alice-pass"},
+		},
+		{
+			name:    "unknown user",
+			url:     "https://bob@gerrit.example.com/a/project",
+			wantErr: "no HTTP password configured for user 'bob'",
+		},
+		{
+			name:    "no user",
+			url:     "https://gerrit.example.com/a/project",
+			wantErr: "no username configured",
+		},
+	}
+
+	p := &GerritProvider{
+		Host:      "gerrit.example.com",
+		Passwords: map[string]string{"alice": "alice-pass"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			u, err := url.Parse(tt.url)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(p.Supports(u)).To(BeTrue())
+
+			got, err := p.Credentials(context.TODO(), u)
+			if tt.wantErr != "" {
+				g.Expect(err).To(MatchError(tt.wantErr))
+				return
+			}
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(got).To(Equal(tt.want))
+		})
+	}
+}
+
+func TestCredentials_ApplyTo(t *testing.T) {
+	g := NewWithT(t)
+
+	opts := &git.AuthOptions{Transport: git.HTTPS, BearerToken: "old"}
+	(&Credentials{Username: "user", Password: "pass"}).ApplyTo(opts)
+	g.Expect(opts.Username).To(Equal("user"))
+	g.Expect(opts.Password).To(Equal("pass"))
+	g.Expect(opts.BearerToken).To(BeEmpty())
+
+	(&Credentials{BearerToken: "token"}).ApplyTo(opts)
+	g.Expect(opts.Username).To(BeEmpty())
+	g.Expect(opts.Password).To(BeEmpty())
+	g.Expect(opts.BearerToken).To(Equal("token"))
+}
@@ -0,0 +1,75 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// ProviderGerrit is the name of the Gerrit provider.
+const ProviderGerrit = "gerrit"
+
+// GerritProvider provides the HTTP password of Gerrit users. Gerrit
+// generates a dedicated HTTP password per user, which is used for
+// HTTP basic authentication of Git operations.
+//
+// Note that Gerrit only authenticates Git operations when the repository
+// path is prefixed with '/a/', for example:
+// 'https://gerrit.example.com/a/project'.
+type GerritProvider struct {
+	// Host is the host of the Gerrit server, for example: 'gerrit.example.com'.
+	Host string
+	// Username is the user to authenticate as when the repository URL
+	// does not contain a user.
+	Username string
+	// Passwords maps Gerrit usernames to their HTTP password.
+	Passwords map[string]string
+}
+
+// Name returns the name of the provider.
+func (p *GerritProvider) Name() string {
+	return ProviderGerrit
+}
+
+// Supports returns true if the host of the URL matches the configured host.
+func (p *GerritProvider) Supports(u *url.URL) bool {
+	return strings.EqualFold(u.Host, p.Host)
+}
+
+// Credentials returns the HTTP password of the user of the URL, or of the
+// configured Username if the URL does not contain a user.
+func (p *GerritProvider) Credentials(_ context.Context, u *url.URL) (*Credentials, error) {
+	username := u.User.Username()
+	if username == "" {
+		username = p.Username
+	}
+	if username == "" {
+		return nil, fmt.Errorf("no username configured")
+	}
+
+	password, ok := p.Passwords[username]
+	if !ok || password == "" {
+		return nil, fmt.Errorf("no HTTP password configured for user '%s'", username)
+	}
+	return &Credentials{
+		Username: username,
+		Password: password,
+	}, nil
+}
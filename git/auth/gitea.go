@@ -0,0 +1,72 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+const (
+	// ProviderGitea is the name of the Gitea provider.
+	ProviderGitea = "gitea"
+
+	// DefaultGiteaUsername is the username used with Gitea access tokens
+	// when none is configured. Gitea (and Forgejo) accept an access token
+	// as password in combination with any username.
+	DefaultGiteaUsername = "oauth2"
+)
+
+// GiteaProvider provides access tokens for Gitea and Forgejo servers.
+type GiteaProvider struct {
+	// Host is the host of the Gitea server, for example: 'gitea.example.com'.
+	Host string
+	// Username is the user the token belongs to. Defaults to
+	// DefaultGiteaUsername.
+	Username string
+	// Token is the Gitea access token.
+	Token string
+}
+
+// Name returns the name of the provider.
+func (p *GiteaProvider) Name() string {
+	return ProviderGitea
+}
+
+// Supports returns true if the host of the URL matches the configured host.
+func (p *GiteaProvider) Supports(u *url.URL) bool {
+	return strings.EqualFold(u.Host, p.Host)
+}
+
+// Credentials returns the configured access token as password for HTTP
+// basic authentication.
+func (p *GiteaProvider) Credentials(_ context.Context, _ *url.URL) (*Credentials, error) {
+	if p.Token == "" {
+		return nil, fmt.Errorf("no access token configured")
+	}
+
+	username := p.Username
+	if username == "" {
+		username = DefaultGiteaUsername
+	}
+	return &Credentials{
+		Username: username,
+		Password: p.Token,
+	}, nil
+}
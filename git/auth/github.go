@@ -0,0 +1,256 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package auth
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fluxcd/pkg/cache"
+)
+
+const (
+	// ProviderGitHub is the name of the GitHub App provider.
+	ProviderGitHub = "github"
+
+	// DefaultGitHubAPIURL is the default GitHub API endpoint.
+	DefaultGitHubAPIURL = "https://api.github.com"
+
+	// GitHubAccessTokenUsername is the username GitHub expects to be used
+	// in combination with an installation access token.
+	GitHubAccessTokenUsername = "x-access-token"
+)
+
+// GitHubAppProvider provides installation access tokens of a GitHub App.
+// Tokens can be stored in a GitHubAppTokenCache, which is shared across
+// providers (and thereby reconciliations) for the same app installation.
+type GitHubAppProvider struct {
+	// Host is the host of the GitHub server, for example: 'github.com'.
+	Host string
+	// APIURL is the URL of the GitHub API. Defaults to DefaultGitHubAPIURL.
+	APIURL string
+	// AppID is the ID of the GitHub App.
+	AppID int64
+	// InstallationID is the ID of the installation of the GitHub App.
+	InstallationID int64
+	// PrivateKey is the PEM encoded RSA private key of the GitHub App.
+	PrivateKey []byte
+	// Cache is the cache used to store installation tokens. If nil, a new
+	// token is requested for every call to Credentials.
+	Cache *GitHubAppTokenCache
+	// HTTPClient is the client used to request installation tokens.
+	// Defaults to http.DefaultClient.
+	HTTPClient *http.Client
+}
+
+// Name returns the name of the provider.
+func (p *GitHubAppProvider) Name() string {
+	return ProviderGitHub
+}
+
+// Supports returns true if the host of the URL matches the configured host.
+func (p *GitHubAppProvider) Supports(u *url.URL) bool {
+	return strings.EqualFold(u.Host, p.Host)
+}
+
+// Credentials returns an installation access token of the GitHub App. A
+// cached token is returned as long as it does not expire within the
+// refresh window, otherwise a new token is requested.
+func (p *GitHubAppProvider) Credentials(ctx context.Context, _ *url.URL) (*Credentials, error) {
+	if p.Cache == nil {
+		creds, err := p.requestToken(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("unable to get installation token for GitHub App '%d': %w", p.AppID, err)
+		}
+		return creds, nil
+	}
+	return p.Cache.Get(ctx, p.apiURL(), p.AppID, p.InstallationID, p.requestToken)
+}
+
+// apiURL returns the URL of the GitHub API, without trailing slash.
+func (p *GitHubAppProvider) apiURL() string {
+	if p.APIURL == "" {
+		return DefaultGitHubAPIURL
+	}
+	return strings.TrimSuffix(p.APIURL, "/")
+}
+
+type githubInstallationToken struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// requestToken requests a new installation access token from the GitHub API.
+func (p *GitHubAppProvider) requestToken(ctx context.Context) (*Credentials, error) {
+	jwt, err := p.signJWT(time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint := fmt.Sprintf("%s/app/installations/%d/access_tokens", p.apiURL(), p.InstallationID)
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Accept", "application/vnd.github+json")
+	request.Header.Set("Authorization", "Bearer "+jwt)
+
+	client := p.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	defer io.Copy(io.Discard, response.Body)
+
+	if response.StatusCode != http.StatusCreated {
+		return nil, fmt.Errorf("unexpected status from GitHub API: %s", response.Status)
+	}
+
+	var token githubInstallationToken
+	if err := json.NewDecoder(response.Body).Decode(&token); err != nil {
+		return nil, fmt.Errorf("unable to decode installation token: %w", err)
+	}
+	if token.Token == "" {
+		return nil, errors.New("empty installation token returned by GitHub API")
+	}
+	return &Credentials{
+		Username:  GitHubAccessTokenUsername,
+		Password:  token.Token,
+		ExpiresAt: token.ExpiresAt,
+	}, nil
+}
+
+// signJWT returns a JSON Web Token signed with the private key of the
+// GitHub App, as required to request installation tokens.
+func (p *GitHubAppProvider) signJWT(now time.Time) (string, error) {
+	key, err := parseRSAPrivateKey(p.PrivateKey)
+	if err != nil {
+		return "", err
+	}
+
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+	// The issued at time is set in the past to allow for clock drift, and
+	// the expiration time is within the maximum of ten minutes allowed by
+	// GitHub.
+	claims, err := json.Marshal(map[string]interface{}{
+		"iat": now.Add(-time.Minute).Unix(),
+		"exp": now.Add(9 * time.Minute).Unix(),
+		"iss": strconv.FormatInt(p.AppID, 10),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	var b bytes.Buffer
+	b.WriteString(base64.RawURLEncoding.EncodeToString(header))
+	b.WriteByte('.')
+	b.WriteString(base64.RawURLEncoding.EncodeToString(claims))
+	digest := sha256.Sum256(b.Bytes())
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("unable to sign JWT: %w", err)
+	}
+	b.WriteByte('.')
+	b.WriteString(base64.RawURLEncoding.EncodeToString(sig))
+	return b.String(), nil
+}
+
+func parseRSAPrivateKey(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("unable to decode GitHub App private key: no PEM data found")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse GitHub App private key: %w", err)
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("GitHub App private key is not an RSA key")
+	}
+	return rsaKey, nil
+}
+
+// GitHubAppTokenCache caches GitHub App installation tokens by API URL,
+// app and installation ID, and refreshes them before they expire.
+// Concurrent requests for the same installation share a single request to
+// the GitHub API. It is safe for concurrent use.
+type GitHubAppTokenCache struct {
+	tokens *cache.Cache[githubAppInstallation, *Credentials]
+	now    func() time.Time
+}
+
+type githubAppInstallation struct {
+	apiURL         string
+	appID          int64
+	installationID int64
+}
+
+// NewGitHubAppTokenCache returns a new, empty GitHubAppTokenCache.
+func NewGitHubAppTokenCache() *GitHubAppTokenCache {
+	c := &GitHubAppTokenCache{now: time.Now}
+	c.tokens = newCredentialsCache[githubAppInstallation](func() time.Time { return c.now() })
+	return c
+}
+
+// Get returns the cached token for the given app installation of the
+// GitHub API at apiURL. If there is no token, or if it expires within the
+// refresh window, a new token is obtained using the request function and
+// stored in the cache.
+func (c *GitHubAppTokenCache) Get(ctx context.Context, apiURL string, appID, installationID int64,
+	request func(ctx context.Context) (*Credentials, error)) (*Credentials, error) {
+	key := githubAppInstallation{apiURL: apiURL, appID: appID, installationID: installationID}
+	creds, err := c.tokens.GetOrLoad(key, credentialsLoader(c.now, func() (*Credentials, error) {
+		return request(ctx)
+	}))
+	if err != nil {
+		return nil, fmt.Errorf("unable to get installation token for GitHub App '%d': %w", appID, err)
+	}
+	return creds, nil
+}
+
+// Invalidate removes the cached token for the given app installation of
+// the GitHub API at apiURL.
+func (c *GitHubAppTokenCache) Invalidate(apiURL string, appID, installationID int64) {
+	c.tokens.Delete(githubAppInstallation{apiURL: apiURL, appID: appID, installationID: installationID})
+}
@@ -0,0 +1,139 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestGitHubAppProvider_Credentials(t *testing.T) {
+	g := NewWithT(t)
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	g.Expect(err).ToNot(HaveOccurred())
+	privateKey := pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(key),
+	})
+
+	var requests int32
+	expiresAt := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		if r.Method != http.MethodPost || r.URL.Path != "/app/installations/42/access_tokens" ||
+			!strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ") {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(githubInstallationToken{
+			Token:     "installation-token",
+			ExpiresAt: expiresAt,
+		})
+	}))
+	defer srv.Close()
+
+	cache := NewGitHubAppTokenCache()
+	newProvider := func() *GitHubAppProvider {
+		return &GitHubAppProvider{
+			Host:           "github.com",
+			APIURL:         srv.URL,
+			AppID:          1,
+			InstallationID: 42,
+			PrivateKey:     privateKey,
+			Cache:          cache,
+		}
+	}
+	u, _ := url.Parse("https://github.com/fluxcd/flux2")
+
+	creds, err := newProvider().Credentials(context.TODO(), u)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(*creds).To(Equal(Credentials{
+		Username:  GitHubAccessTokenUsername,
+		Password:  "installation-token",
+		ExpiresAt: expiresAt,
+	}))
+
+	// The token is shared by providers of the same app installation.
+	_, err = newProvider().Credentials(context.TODO(), u)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(atomic.LoadInt32(&requests)).To(Equal(int32(1)))
+
+	// The token is refreshed before it expires.
+	cache.now = func() time.Time { return expiresAt.Add(-RefreshBeforeExpiry).Add(time.Second) }
+	_, err = newProvider().Credentials(context.TODO(), u)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(atomic.LoadInt32(&requests)).To(Equal(int32(2)))
+
+	// Other installations do not share the token.
+	p := newProvider()
+	p.InstallationID = 7
+	_, err = p.Credentials(context.TODO(), u)
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(atomic.LoadInt32(&requests)).To(Equal(int32(3)))
+
+	// Other GitHub API endpoints do not share the token.
+	p = newProvider()
+	p.APIURL = srv.URL + "/api/v3"
+	_, err = p.Credentials(context.TODO(), u)
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(atomic.LoadInt32(&requests)).To(Equal(int32(4)))
+
+	// Without cache, a token is requested on every call.
+	p = newProvider()
+	p.Cache = nil
+	for i := 0; i < 2; i++ {
+		_, err = p.Credentials(context.TODO(), u)
+		g.Expect(err).ToNot(HaveOccurred())
+	}
+	g.Expect(atomic.LoadInt32(&requests)).To(Equal(int32(6)))
+}
+
+func TestGitHubAppProvider_signJWT(t *testing.T) {
+	g := NewWithT(t)
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	g.Expect(err).ToNot(HaveOccurred())
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(key)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	p := &GitHubAppProvider{
+		AppID:      1,
+		PrivateKey: pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8}),
+	}
+	jwt, err := p.signJWT(time.Now())
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(strings.Split(jwt, ".")).To(HaveLen(3))
+
+	p.PrivateKey = []byte("invalid")
+	_, err = p.signJWT(time.Now())
+	g.Expect(err).To(MatchError(ContainSubstring("no PEM data found")))
+}
@@ -0,0 +1,176 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package auth
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// ErrInvalidStoreKey is returned when the encryption key of a
+// FileTokenStore is not 32 bytes long.
+var ErrInvalidStoreKey = errors.New("token store key must be 32 bytes")
+
+// TokenStore persists the credentials cached by a Manager, to allow
+// short-lived tokens to be reused across controller restarts instead of
+// requesting new ones from every provider at once.
+// Implementations backed by a Kubernetes Secret can be provided by
+// controllers, this package provides FileTokenStore.
+type TokenStore interface {
+	// Load returns the persisted credentials by cache key.
+	Load(ctx context.Context) (map[string]*Credentials, error)
+	// Save persists the given credentials by cache key, replacing any
+	// previously persisted credentials.
+	Save(ctx context.Context, creds map[string]*Credentials) error
+}
+
+// WithTokenStore configures the Manager to persist its cached credentials
+// in the given store. Credentials are saved whenever new credentials are
+// obtained or invalidated, on a best-effort basis, use Persist to handle
+// the errors. Use Restore to load the persisted credentials.
+func (m *Manager) WithTokenStore(store TokenStore) *Manager {
+	m.store = store
+	return m
+}
+
+// Restore loads the credentials persisted in the token store into the
+// cache, skipping the ones which are expired.
+func (m *Manager) Restore(ctx context.Context) error {
+	if m.store == nil {
+		return nil
+	}
+
+	creds, err := m.store.Load(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to load credentials from token store: %w", err)
+	}
+
+	for key, c := range creds {
+		if c == nil {
+			continue
+		}
+		if _, ok := m.cache.Get(key); !ok {
+			m.cache.SetWithTTL(key, c, c.ttl(m.now()))
+		}
+	}
+	return nil
+}
+
+// Persist saves the cached credentials which are not expired in the
+// token store. The credentials are read from the cache before saving, so
+// that logins are not blocked by the token store.
+func (m *Manager) Persist(ctx context.Context) error {
+	if m.store == nil {
+		return nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.store.Save(ctx, m.cache.Items()); err != nil {
+		return fmt.Errorf("unable to save credentials to token store: %w", err)
+	}
+	return nil
+}
+
+// FileTokenStore is a TokenStore which persists credentials in a file,
+// encrypted with AES-256-GCM.
+type FileTokenStore struct {
+	path string
+	aead cipher.AEAD
+}
+
+// NewFileTokenStore returns a FileTokenStore which persists credentials in
+// the file at the given path, encrypted with the given 32 bytes key.
+func NewFileTokenStore(path string, key []byte) (*FileTokenStore, error) {
+	if len(key) != 32 {
+		return nil, ErrInvalidStoreKey
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &FileTokenStore{path: path, aead: aead}, nil
+}
+
+// Load decrypts and returns the credentials persisted in the file.
+// It returns no credentials if the file does not exist.
+func (s *FileTokenStore) Load(_ context.Context) (map[string]*Credentials, error) {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return map[string]*Credentials{}, nil
+		}
+		return nil, err
+	}
+
+	size := s.aead.NonceSize()
+	if len(data) < size {
+		return nil, errors.New("token store file is too short")
+	}
+	plaintext, err := s.aead.Open(nil, data[:size], data[size:], nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decrypt token store file: %w", err)
+	}
+
+	creds := make(map[string]*Credentials)
+	if err := json.Unmarshal(plaintext, &creds); err != nil {
+		return nil, fmt.Errorf("unable to decode token store file: %w", err)
+	}
+	return creds, nil
+}
+
+// Save encrypts and writes the given credentials to the file, replacing it
+// atomically.
+func (s *FileTokenStore) Save(_ context.Context, creds map[string]*Credentials) error {
+	plaintext, err := json.Marshal(creds)
+	if err != nil {
+		return err
+	}
+
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return err
+	}
+	data := s.aead.Seal(nonce, nonce, plaintext, nil)
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
@@ -0,0 +1,128 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package auth
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestManager_TokenStore(t *testing.T) {
+	g := NewWithT(t)
+
+	key := []byte("0123456789abcdef0123456789abcdef")
+	path := filepath.Join(t.TempDir(), "tokens")
+	store, err := NewFileTokenStore(path, key)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	gitea := &countingProvider{Provider: &GiteaProvider{
+		Host:  "gitea.example.com",
+		Token: "token",
+	}}
+	m := NewManager(gitea).WithTokenStore(store)
+	g.Expect(m.Restore(context.TODO())).To(Succeed())
+
+	_, err = m.Login(context.TODO(), "https://gitea.example.com/org/repo")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(gitea.calls).To(Equal(1))
+
+	// The file does not contain the credentials in plain text.
+	data, err := os.ReadFile(path)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(string(data)).ToNot(ContainSubstring("token"))
+
+	// A new Manager restores the credentials instead of requesting them.
+	restarted := &countingProvider{Provider: gitea.Provider}
+	m = NewManager(restarted).WithTokenStore(store)
+	g.Expect(m.Restore(context.TODO())).To(Succeed())
+	creds, err := m.Login(context.TODO(), "https://gitea.example.com/org/repo")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(*creds).To(Equal(Credentials{Username: DefaultGiteaUsername, Password: "token"}))
+	g.Expect(restarted.calls).To(Equal(0))
+
+	// Expired credentials are not restored.
+	g.Expect(store.Save(context.TODO(), map[string]*Credentials{
+		"gitea/gitea.example.com/": {Password: "expired", ExpiresAt: time.Now().Add(time.Minute)},
+	})).To(Succeed())
+	m = NewManager(restarted).WithTokenStore(store)
+	g.Expect(m.Restore(context.TODO())).To(Succeed())
+	creds, err = m.Login(context.TODO(), "https://gitea.example.com/org/repo")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(creds.Password).To(Equal("token"))
+	g.Expect(restarted.calls).To(Equal(1))
+
+	// Invalidated credentials are removed from the store.
+	g.Expect(m.Invalidate("https://gitea.example.com/org/repo")).To(Succeed())
+	persisted, err := store.Load(context.TODO())
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(persisted).To(BeEmpty())
+
+	// The file cannot be decrypted with another key.
+	g.Expect(m.Persist(context.TODO())).To(Succeed())
+	other, err := NewFileTokenStore(path, []byte("fedcba9876543210fedcba9876543210"))
+	g.Expect(err).ToNot(HaveOccurred())
+	_, err = other.Load(context.TODO())
+	g.Expect(err).To(HaveOccurred())
+
+	_, err = NewFileTokenStore(path, []byte("short"))
+	g.Expect(err).To(MatchError(ErrInvalidStoreKey))
+}
+
+type blockingTokenStore struct {
+	TokenStore
+	saving  chan struct{}
+	release chan struct{}
+}
+
+func (s *blockingTokenStore) Save(ctx context.Context, creds map[string]*Credentials) error {
+	s.saving <- struct{}{}
+	<-s.release
+	return s.TokenStore.Save(ctx, creds)
+}
+
+func TestManager_LoginDuringSave(t *testing.T) {
+	g := NewWithT(t)
+
+	fileStore, err := NewFileTokenStore(filepath.Join(t.TempDir(), "tokens"), []byte("0123456789abcdef0123456789abcdef"))
+	g.Expect(err).ToNot(HaveOccurred())
+	store := &blockingTokenStore{
+		TokenStore: fileStore,
+		saving:     make(chan struct{}),
+		release:    make(chan struct{}),
+	}
+	m := NewManager(&GiteaProvider{Host: "gitea.example.com", Token: "token"}).WithTokenStore(store)
+
+	done := make(chan error)
+	go func() {
+		_, err := m.Login(context.TODO(), "https://gitea.example.com/org/repo")
+		done <- err
+	}()
+	<-store.saving
+
+	// Cached credentials are served while the token store is being written.
+	creds, err := m.Login(context.TODO(), "https://gitea.example.com/org/other")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(creds.Password).To(Equal("token"))
+
+	close(store.release)
+	g.Expect(<-done).To(Succeed())
+}
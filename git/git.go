@@ -89,6 +89,11 @@ type Commit struct {
 	Message string
 	// ReferencingTag is the tag that points to this commit.
 	ReferencingTag *Tag
+	// SemVerCandidates holds the names of the tags that matched the SemVer
+	// expression used to select this commit, sorted from the highest to the
+	// lowest version. The first element is the tag that got selected.
+	// It is empty if the commit was not selected using a SemVer expression.
+	SemVerCandidates []string
 }
 
 // String returns a string representation of the Commit, composed
@@ -174,6 +179,44 @@ func (t *Tag) String() string {
 	return fmt.Sprintf("%s@%s", t.Name, t.Hash.String())
 }
 
+// Reference represents a named Git reference on a remote repository,
+// and the commit it points to.
+type Reference struct {
+	// Name is the full name of the reference, for example:
+	// 'refs/heads/main' or 'refs/tags/v1.0.0'.
+	Name string
+	// Hash is the hash of the commit the reference points to. For annotated
+	// tags, this is the hash of the dereferenced commit, not of the tag object.
+	Hash Hash
+}
+
+// ShortName returns the name of the reference without the 'refs/heads/' or
+// 'refs/tags/' prefix. Any other reference name is returned as is.
+func (r Reference) ShortName() string {
+	for _, prefix := range []string{"refs/heads/", "refs/tags/"} {
+		if strings.HasPrefix(r.Name, prefix) {
+			return strings.TrimPrefix(r.Name, prefix)
+		}
+	}
+	return r.Name
+}
+
+// IsBranch returns true if the reference is a branch.
+func (r Reference) IsBranch() bool {
+	return strings.HasPrefix(r.Name, "refs/heads/")
+}
+
+// IsTag returns true if the reference is a tag.
+func (r Reference) IsTag() bool {
+	return strings.HasPrefix(r.Name, "refs/tags/")
+}
+
+// String returns a string representation of the Reference in the format
+// of <name@digest>, for eg: 'refs/heads/main@sha1:a0c14dc8580a23f79bc654faa79c4f62b46c2c22'.
+func (r Reference) String() string {
+	return fmt.Sprintf("%s@%s", r.Name, r.Hash.Digest())
+}
+
 // ErrRepositoryNotFound indicates that the repository (or the ref in
 // question) does not exist at the given URL.
 type ErrRepositoryNotFound struct {
@@ -188,6 +231,12 @@ func (e ErrRepositoryNotFound) Error() string {
 var (
 	ErrNoGitRepository = errors.New("no git repository")
 	ErrNoStagedFiles   = errors.New("no staged files")
+	// ErrRemoteRefMoved is returned when a push is rejected because the
+	// remote branch has been updated since it was fetched.
+	ErrRemoteRefMoved = errors.New("remote branch has been updated")
+	// ErrRebaseConflict is returned when local commits cannot be replayed
+	// on top of a remote branch, due to files changed on both sides.
+	ErrRebaseConflict = errors.New("conflicting changes on remote branch")
 )
 
 // IsConcreteCommit returns if a given commit is a concrete commit. Concrete
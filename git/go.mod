@@ -2,6 +2,8 @@ module github.com/fluxcd/pkg/git
 
 go 1.20
 
+replace github.com/fluxcd/pkg/cache => ../cache
+
 require (
 	// github.com/ProtonMail/go-crypto is a fork of golang.org/x/crypto
 	// maintained by the ProtonMail team to continue to support the openpgp
@@ -9,15 +11,24 @@ require (
 	// When in doubt (and not using openpgp), use /x/crypto.
 	github.com/ProtonMail/go-crypto v0.0.0-20231012073058-a7379d079e0e
 	github.com/cyphar/filepath-securejoin v0.2.4
+	github.com/fluxcd/pkg/cache v0.0.0-00010101000000-000000000000
 	github.com/onsi/gomega v1.30.0
 )
 
 require (
+	github.com/beorn7/perks v1.0.1 // indirect
+	github.com/cespare/xxhash/v2 v2.2.0 // indirect
 	github.com/cloudflare/circl v1.3.6 // indirect
 	github.com/google/go-cmp v0.6.0 // indirect
+	github.com/matttproud/golang_protobuf_extensions/v2 v2.0.0 // indirect
+	github.com/prometheus/client_golang v1.17.0 // indirect
+	github.com/prometheus/client_model v0.5.0 // indirect
+	github.com/prometheus/common v0.45.0 // indirect
+	github.com/prometheus/procfs v0.12.0 // indirect
 	golang.org/x/crypto v0.16.0 // indirect
 	golang.org/x/net v0.19.0 // indirect
 	golang.org/x/sys v0.15.0 // indirect
 	golang.org/x/text v0.14.0 // indirect
+	google.golang.org/protobuf v1.31.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
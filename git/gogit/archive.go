@@ -0,0 +1,231 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gogit
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
+	"github.com/go-git/go-git/v5/plumbing/object"
+
+	"github.com/fluxcd/pkg/git"
+	"github.com/fluxcd/pkg/sourceignore"
+)
+
+// archiveDigestPrefix is the algorithm prefix of the archive digests.
+const archiveDigestPrefix = "sha256:"
+
+// WriteArchiveConfig provides configuration options for writing an archive.
+type WriteArchiveConfig struct {
+	// Revision is the revision to export, e.g. a branch, tag or commit hash.
+	// Defaults to HEAD.
+	Revision string
+	// Ignore are the patterns of the paths excluded from the archive, for
+	// example sourceignore.DefaultPatterns.
+	Ignore []gitignore.Pattern
+	// SkipIgnoreFiles disables reading the patterns of the .sourceignore
+	// files of the revision.
+	SkipIgnoreFiles bool
+}
+
+// archiveEntry is a file of the tree of the exported revision.
+type archiveEntry struct {
+	path string
+	mode filemode.FileMode
+	hash plumbing.Hash
+}
+
+// WriteArchive writes the tree of a revision of the repository to w as a
+// gzip compressed tarball, reading the files from the object storage rather
+// than from the worktree. The paths matching the configured patterns, the
+// patterns of the .sourceignore files of the revision and the VCS patterns
+// of sourceignore are excluded. The archive is deterministic: the entries
+// are sorted, and their modification time and ownership are zeroed, so that
+// the same tree always results in the same digest, which is returned.
+func (g *Client) WriteArchive(ctx context.Context, w io.Writer, cfg WriteArchiveConfig) (string, error) {
+	if g.repository == nil {
+		return "", git.ErrNoGitRepository
+	}
+
+	rev := cfg.Revision
+	if rev == "" {
+		rev = "HEAD"
+	}
+	h, err := g.repository.ResolveRevision(plumbing.Revision(rev))
+	if err != nil {
+		return "", fmt.Errorf("unable to resolve revision '%s': %w", rev, err)
+	}
+	commit, err := g.repository.CommitObject(*h)
+	if err != nil {
+		return "", fmt.Errorf("unable to resolve commit object for '%s': %w", h, err)
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return "", fmt.Errorf("unable to resolve tree of commit '%s': %w", h, err)
+	}
+
+	patterns := append(sourceignore.VCSPatterns(nil), cfg.Ignore...)
+	var entries []archiveEntry
+	if err := g.archiveEntries(ctx, tree, nil, patterns, !cfg.SkipIgnoreFiles, &entries); err != nil {
+		return "", err
+	}
+
+	hasher := sha256.New()
+	gw := gzip.NewWriter(io.MultiWriter(w, hasher))
+	tw := tar.NewWriter(gw)
+	dirs := map[string]bool{}
+	for _, e := range entries {
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+		if err := writeArchiveDirs(tw, path.Dir(e.path), dirs); err != nil {
+			return "", err
+		}
+		if err := g.writeArchiveEntry(tw, e); err != nil {
+			return "", fmt.Errorf("unable to archive '%s': %w", e.path, err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return "", err
+	}
+	if err := gw.Close(); err != nil {
+		return "", err
+	}
+
+	return archiveDigestPrefix + hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// archiveEntries appends the files of the tree at the given domain which are
+// not matched by the patterns to the entries, in tree order. The patterns of
+// the .sourceignore file of the tree apply to the tree and its subtrees.
+func (g *Client) archiveEntries(ctx context.Context, tree *object.Tree, domain []string,
+	patterns []gitignore.Pattern, ignoreFiles bool, entries *[]archiveEntry) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if ignoreFiles {
+		if f, err := tree.File(sourceignore.IgnoreFile); err == nil {
+			r, err := f.Reader()
+			if err != nil {
+				return err
+			}
+			ps := sourceignore.ReadPatterns(r, domain)
+			r.Close()
+			patterns = append(append([]gitignore.Pattern{}, patterns...), ps...)
+		}
+	}
+	matcher := sourceignore.NewMatcher(patterns)
+
+	for _, te := range tree.Entries {
+		p := append(append([]string{}, domain...), te.Name)
+		isDir := te.Mode == filemode.Dir
+		if matcher.Match(p, isDir) {
+			continue
+		}
+
+		switch te.Mode {
+		case filemode.Dir:
+			sub, err := g.repository.TreeObject(te.Hash)
+			if err != nil {
+				return fmt.Errorf("unable to read tree '%s': %w", strings.Join(p, "/"), err)
+			}
+			if err := g.archiveEntries(ctx, sub, p, patterns, ignoreFiles, entries); err != nil {
+				return err
+			}
+		case filemode.Submodule:
+			// The content of submodules is not part of the tree.
+			continue
+		default:
+			*entries = append(*entries, archiveEntry{path: strings.Join(p, "/"), mode: te.Mode, hash: te.Hash})
+		}
+	}
+	return nil
+}
+
+// writeArchiveEntry writes the header and content of the file to the tarball.
+func (g *Client) writeArchiveEntry(tw *tar.Writer, e archiveEntry) error {
+	blob, err := g.repository.BlobObject(e.hash)
+	if err != nil {
+		return err
+	}
+	r, err := blob.Reader()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	hdr := archiveHeader(e.path)
+	switch e.mode {
+	case filemode.Symlink:
+		target, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		hdr.Typeflag = tar.TypeSymlink
+		hdr.Linkname = string(target)
+		hdr.Mode = 0o777
+		return tw.WriteHeader(hdr)
+	case filemode.Executable:
+		hdr.Mode = 0o755
+	default:
+		hdr.Mode = 0o644
+	}
+	hdr.Typeflag = tar.TypeReg
+	hdr.Size = blob.Size
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, r)
+	return err
+}
+
+// writeArchiveDirs writes the headers of the given directory and its parents
+// which have not been written yet.
+func writeArchiveDirs(tw *tar.Writer, dir string, written map[string]bool) error {
+	if dir == "." || written[dir] {
+		return nil
+	}
+	if err := writeArchiveDirs(tw, path.Dir(dir), written); err != nil {
+		return err
+	}
+	hdr := archiveHeader(dir + "/")
+	hdr.Typeflag = tar.TypeDir
+	hdr.Mode = 0o755
+	written[dir] = true
+	return tw.WriteHeader(hdr)
+}
+
+// archiveHeader returns a tar header for the given name with the fields
+// which would make the archive non-deterministic zeroed.
+func archiveHeader(name string) *tar.Header {
+	return &tar.Header{
+		Name:    name,
+		ModTime: time.Unix(0, 0),
+	}
+}
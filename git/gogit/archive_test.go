@@ -0,0 +1,124 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gogit
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
+	. "github.com/onsi/gomega"
+)
+
+func TestWriteArchive(t *testing.T) {
+	g := NewWithT(t)
+
+	repo, path, err := initRepo(t.TempDir())
+	g.Expect(err).ToNot(HaveOccurred())
+
+	first, err := commitFile(repo, "README.md", "first", time.Now())
+	g.Expect(err).ToNot(HaveOccurred())
+	_, err = commitFile(repo, "deploy/app.yaml", "app", time.Now())
+	g.Expect(err).ToNot(HaveOccurred())
+	_, err = commitFile(repo, "deploy/secret.enc", "secret", time.Now())
+	g.Expect(err).ToNot(HaveOccurred())
+	_, err = commitFile(repo, "deploy/.sourceignore", "*.enc\n", time.Now())
+	g.Expect(err).ToNot(HaveOccurred())
+	_, err = commitFile(repo, "docs/index.md", "docs", time.Now())
+	g.Expect(err).ToNot(HaveOccurred())
+
+	client, err := NewClient(path, nil)
+	g.Expect(err).ToNot(HaveOccurred())
+	client.repository = repo
+
+	var archive bytes.Buffer
+	digest, err := client.WriteArchive(context.TODO(), &archive, WriteArchiveConfig{
+		Ignore: []gitignore.Pattern{gitignore.ParsePattern("docs/", nil)},
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(digest).To(HavePrefix("sha256:"))
+	g.Expect(readArchive(t, archive.Bytes())).To(Equal(map[string]string{
+		"README.md":            "first",
+		"deploy/":              "",
+		"deploy/.sourceignore": "*.enc\n",
+		"deploy/app.yaml":      "app",
+	}))
+
+	t.Run("is deterministic", func(t *testing.T) {
+		g := NewWithT(t)
+
+		var again bytes.Buffer
+		d, err := client.WriteArchive(context.TODO(), &again, WriteArchiveConfig{
+			Ignore: []gitignore.Pattern{gitignore.ParsePattern("docs/", nil)},
+		})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(d).To(Equal(digest))
+		g.Expect(again.Bytes()).To(Equal(archive.Bytes()))
+	})
+
+	t.Run("exports revision", func(t *testing.T) {
+		g := NewWithT(t)
+
+		var b bytes.Buffer
+		_, err := client.WriteArchive(context.TODO(), &b, WriteArchiveConfig{Revision: first.String()})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(readArchive(t, b.Bytes())).To(Equal(map[string]string{"README.md": "first"}))
+	})
+
+	t.Run("skips ignore files", func(t *testing.T) {
+		g := NewWithT(t)
+
+		var b bytes.Buffer
+		_, err := client.WriteArchive(context.TODO(), &b, WriteArchiveConfig{SkipIgnoreFiles: true})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(readArchive(t, b.Bytes())).To(HaveKey("deploy/secret.enc"))
+	})
+}
+
+func readArchive(t *testing.T, data []byte) map[string]string {
+	t.Helper()
+
+	gr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(gr)
+	files := map[string]string{}
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hdr.ModTime.Unix() != 0 {
+			t.Errorf("expected zero modification time for '%s', got %s", hdr.Name, hdr.ModTime)
+		}
+		b, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[hdr.Name] = string(b)
+	}
+	return files
+}
@@ -0,0 +1,300 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gogit
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	extgogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/format/packfile"
+	"github.com/go-git/go-git/v5/plumbing/revlist"
+
+	"github.com/fluxcd/pkg/git"
+)
+
+const (
+	// bundleSignature is the first line of a git bundle file, only the v2
+	// format is supported.
+	bundleSignature = "# v2 git bundle"
+	// bundleDigestPrefix is the algorithm prefix of the bundle digests.
+	bundleDigestPrefix = "sha256:"
+	// bundlePackWindow is the window size used to compute the deltas of the
+	// bundle packfile.
+	bundlePackWindow = 10
+)
+
+// ErrBundleDigestMismatch is returned when the digest of a bundle does
+// not match the expected digest.
+var ErrBundleDigestMismatch = errors.New("bundle digest mismatch")
+
+// WriteBundleConfig provides configuration options for writing a bundle.
+type WriteBundleConfig struct {
+	// RefNames are the references included in the bundle. If empty, HEAD and
+	// all the branches and tags of the repository are included.
+	RefNames []string
+	// Basis are the revisions the receiving repository is known to have.
+	// The objects reachable from them are excluded from the bundle, making it
+	// incremental, and the commits are recorded as bundle prerequisites.
+	Basis []string
+}
+
+// ReadBundleConfig provides configuration options for reading a bundle.
+type ReadBundleConfig struct {
+	// Digest is the expected digest of the bundle, in the format returned by
+	// WriteBundle. If set, the bundle is verified before any object is imported.
+	Digest string
+}
+
+// WriteBundle writes the objects and references of the repository to w in
+// the git bundle v2 format, which can be transported as a single file, e.g.
+// to air-gapped environments. It returns the digest of the written bundle.
+func (g *Client) WriteBundle(ctx context.Context, w io.Writer, cfg WriteBundleConfig) (string, error) {
+	if g.repository == nil {
+		return "", git.ErrNoGitRepository
+	}
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	refs, err := g.bundleRefs(cfg.RefNames)
+	if err != nil {
+		return "", err
+	}
+	if len(refs) == 0 {
+		return "", errors.New("refusing to create an empty bundle")
+	}
+
+	var prerequisites []plumbing.Hash
+	for _, rev := range cfg.Basis {
+		h, err := g.repository.ResolveRevision(plumbing.Revision(rev))
+		if err != nil {
+			return "", fmt.Errorf("unable to resolve basis '%s': %w", rev, err)
+		}
+		prerequisites = append(prerequisites, *h)
+	}
+
+	var wants []plumbing.Hash
+	for _, ref := range refs {
+		wants = append(wants, ref.Hash())
+	}
+	objects, err := revlist.Objects(g.repository.Storer, wants, prerequisites)
+	if err != nil {
+		return "", fmt.Errorf("unable to list bundle objects: %w", err)
+	}
+
+	hasher := sha256.New()
+	bw := bufio.NewWriter(io.MultiWriter(w, hasher))
+	fmt.Fprintln(bw, bundleSignature)
+	for _, h := range prerequisites {
+		fmt.Fprintf(bw, "-%s\n", h)
+	}
+	for _, ref := range refs {
+		fmt.Fprintf(bw, "%s %s\n", ref.Hash(), ref.Name())
+	}
+	fmt.Fprintln(bw)
+
+	if _, err := packfile.NewEncoder(bw, g.repository.Storer, false).Encode(objects, bundlePackWindow); err != nil {
+		return "", fmt.Errorf("unable to encode bundle packfile: %w", err)
+	}
+	if err := bw.Flush(); err != nil {
+		return "", fmt.Errorf("unable to write bundle: %w", err)
+	}
+
+	return bundleDigestPrefix + hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// ReadBundle imports the objects and references of the bundle read from r
+// into the repository, initializing it if needed. If the bundle contains
+// HEAD, the worktree is checked out to it and the HEAD commit is returned.
+// Incremental bundles require their prerequisite commits to be present in
+// the repository.
+func (g *Client) ReadBundle(ctx context.Context, r io.Reader, cfg ReadBundleConfig) (*git.Commit, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	// Buffer the bundle to verify its digest before importing any object.
+	f, err := os.CreateTemp("", "bundle-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	hasher := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(f, hasher), r); err != nil {
+		return nil, fmt.Errorf("unable to read bundle: %w", err)
+	}
+	if cfg.Digest != "" {
+		if digest := bundleDigestPrefix + hex.EncodeToString(hasher.Sum(nil)); digest != cfg.Digest {
+			return nil, fmt.Errorf("%w: expected '%s', got '%s'", ErrBundleDigestMismatch, cfg.Digest, digest)
+		}
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	br := bufio.NewReader(f)
+	prerequisites, refs, err := readBundleHeader(br)
+	if err != nil {
+		return nil, err
+	}
+
+	if g.repository == nil {
+		repo, err := extgogit.Init(g.storer, g.worktreeFS)
+		if errors.Is(err, extgogit.ErrRepositoryAlreadyExists) {
+			repo, err = extgogit.Open(g.storer, g.worktreeFS)
+		}
+		if err != nil {
+			return nil, err
+		}
+		g.repository = repo
+	}
+
+	for _, h := range prerequisites {
+		if err := g.repository.Storer.HasEncodedObject(h); err != nil {
+			return nil, fmt.Errorf("bundle prerequisite '%s' not found in repository: %w", h, err)
+		}
+	}
+
+	if err := packfile.UpdateObjectStorage(g.repository.Storer, br); err != nil {
+		return nil, fmt.Errorf("unable to import bundle packfile: %w", err)
+	}
+
+	var head *plumbing.Reference
+	for _, ref := range refs {
+		if ref.Name() == plumbing.HEAD {
+			head = ref
+			continue
+		}
+		if err := g.repository.Storer.SetReference(ref); err != nil {
+			return nil, fmt.Errorf("unable to set reference '%s': %w", ref.Name(), err)
+		}
+	}
+	if head == nil {
+		return nil, nil
+	}
+
+	checkoutOpts := &extgogit.CheckoutOptions{Hash: head.Hash(), Force: true}
+	refName := plumbing.HEAD
+	for _, ref := range refs {
+		if ref.Name().IsBranch() && ref.Hash() == head.Hash() {
+			checkoutOpts = &extgogit.CheckoutOptions{Branch: ref.Name(), Force: true}
+			refName = ref.Name()
+			break
+		}
+	}
+	wt, err := g.repository.Worktree()
+	if err != nil {
+		return nil, err
+	}
+	if err := wt.Checkout(checkoutOpts); err != nil {
+		return nil, fmt.Errorf("unable to checkout bundle HEAD '%s': %w", head.Hash(), err)
+	}
+
+	cc, err := g.repository.CommitObject(head.Hash())
+	if err != nil {
+		return nil, fmt.Errorf("unable to resolve commit object for '%s': %w", head.Hash(), err)
+	}
+	return buildCommitWithRef(cc, nil, refName)
+}
+
+// bundleRefs returns the references with the given names, or HEAD and all
+// the branches and tags of the repository if no names are given.
+func (g *Client) bundleRefs(names []string) ([]*plumbing.Reference, error) {
+	var refs []*plumbing.Reference
+	if len(names) > 0 {
+		for _, name := range names {
+			ref, err := g.repository.Reference(plumbing.ReferenceName(name), true)
+			if err != nil {
+				return nil, fmt.Errorf("unable to resolve reference '%s': %w", name, err)
+			}
+			refs = append(refs, plumbing.NewHashReference(plumbing.ReferenceName(name), ref.Hash()))
+		}
+		return refs, nil
+	}
+
+	if head, err := g.repository.Head(); err == nil {
+		refs = append(refs, plumbing.NewHashReference(plumbing.HEAD, head.Hash()))
+	} else if !errors.Is(err, plumbing.ErrReferenceNotFound) {
+		return nil, err
+	}
+
+	iter, err := g.repository.Storer.IterReferences()
+	if err != nil {
+		return nil, err
+	}
+	err = iter.ForEach(func(ref *plumbing.Reference) error {
+		if ref.Type() == plumbing.HashReference && (ref.Name().IsBranch() || ref.Name().IsTag()) {
+			refs = append(refs, ref)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return refs, nil
+}
+
+// readBundleHeader reads the prerequisites and references of a bundle,
+// leaving r at the start of the packfile.
+func readBundleHeader(r *bufio.Reader) ([]plumbing.Hash, []*plumbing.Reference, error) {
+	signature, err := r.ReadString('\n')
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to read bundle signature: %w", err)
+	}
+	if strings.TrimSuffix(signature, "\n") != bundleSignature {
+		return nil, nil, fmt.Errorf("unsupported bundle format: '%s'", strings.TrimSpace(signature))
+	}
+
+	var prerequisites []plumbing.Hash
+	var refs []*plumbing.Reference
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to read bundle header: %w", err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" {
+			return prerequisites, refs, nil
+		}
+
+		if strings.HasPrefix(line, "-") {
+			id, _, _ := strings.Cut(line[1:], " ")
+			if !plumbing.IsHash(id) {
+				return nil, nil, fmt.Errorf("invalid bundle prerequisite: '%s'", line)
+			}
+			prerequisites = append(prerequisites, plumbing.NewHash(id))
+			continue
+		}
+
+		id, name, ok := strings.Cut(line, " ")
+		if !ok || !plumbing.IsHash(id) || name == "" {
+			return nil, nil, fmt.Errorf("invalid bundle reference: '%s'", line)
+		}
+		refs = append(refs, plumbing.NewHashReference(plumbing.ReferenceName(name), plumbing.NewHash(id)))
+	}
+}
@@ -0,0 +1,128 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gogit
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestBundle(t *testing.T) {
+	g := NewWithT(t)
+
+	repo, path, err := initRepo(t.TempDir())
+	g.Expect(err).ToNot(HaveOccurred())
+
+	first, err := commitFile(repo, "first", "first commit", time.Now())
+	g.Expect(err).ToNot(HaveOccurred())
+	_, err = tag(repo, first, true, "v0.1.0", time.Now())
+	g.Expect(err).ToNot(HaveOccurred())
+
+	src, err := NewClient(path, nil)
+	g.Expect(err).ToNot(HaveOccurred())
+	src.repository = repo
+
+	dst, err := NewClient(t.TempDir(), nil, WithMemoryStorage())
+	g.Expect(err).ToNot(HaveOccurred())
+
+	var full bytes.Buffer
+	digest, err := src.WriteBundle(context.TODO(), &full, WriteBundleConfig{})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(digest).To(HavePrefix("sha256:"))
+
+	t.Run("rejects digest mismatch", func(t *testing.T) {
+		g := NewWithT(t)
+
+		_, err := dst.ReadBundle(context.TODO(), bytes.NewReader(full.Bytes()), ReadBundleConfig{Digest: "sha256:invalid"})
+		g.Expect(err).To(MatchError(ErrBundleDigestMismatch))
+		g.Expect(dst.repository).To(BeNil())
+	})
+
+	t.Run("reads full bundle", func(t *testing.T) {
+		g := NewWithT(t)
+
+		cc, err := dst.ReadBundle(context.TODO(), bytes.NewReader(full.Bytes()), ReadBundleConfig{Digest: digest})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(cc.Hash.String()).To(Equal(first.String()))
+		g.Expect(cc.Reference).To(Equal("refs/heads/master"))
+
+		_, err = dst.repository.Tag("v0.1.0")
+		g.Expect(err).ToNot(HaveOccurred())
+	})
+
+	second, err := commitFile(repo, "second", "second commit", time.Now())
+	g.Expect(err).ToNot(HaveOccurred())
+
+	var incremental bytes.Buffer
+	_, err = src.WriteBundle(context.TODO(), &incremental, WriteBundleConfig{
+		RefNames: []string{"HEAD", "refs/heads/master"},
+		Basis:    []string{first.String()},
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(incremental.Len()).To(BeNumerically("<", full.Len()))
+
+	t.Run("rejects missing prerequisites", func(t *testing.T) {
+		g := NewWithT(t)
+
+		empty, err := NewClient(t.TempDir(), nil, WithMemoryStorage())
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = empty.ReadBundle(context.TODO(), bytes.NewReader(incremental.Bytes()), ReadBundleConfig{})
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("prerequisite"))
+	})
+
+	t.Run("reads incremental bundle", func(t *testing.T) {
+		g := NewWithT(t)
+
+		cc, err := dst.ReadBundle(context.TODO(), bytes.NewReader(incremental.Bytes()), ReadBundleConfig{})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(cc.Hash.String()).To(Equal(second.String()))
+
+		clean, err := dst.IsClean()
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(clean).To(BeTrue())
+	})
+}
+
+func Test_readBundleHeader(t *testing.T) {
+	g := NewWithT(t)
+
+	_, _, err := readBundleHeader(bufioReader("# v3 git bundle\n@object-format=sha1\n\n"))
+	g.Expect(err).To(MatchError(ContainSubstring("unsupported bundle format")))
+
+	_, _, err = readBundleHeader(bufioReader(bundleSignature + "\ninvalid\n\n"))
+	g.Expect(err).To(MatchError(ContainSubstring("invalid bundle reference")))
+
+	prerequisites, refs, err := readBundleHeader(bufioReader(bundleSignature + "\n" +
+		"-1111111111111111111111111111111111111111 first\n" +
+		"2222222222222222222222222222222222222222 refs/heads/main\n\nPACK"))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(prerequisites).To(HaveLen(1))
+	g.Expect(refs).To(HaveLen(1))
+	g.Expect(refs[0].Name().String()).To(Equal("refs/heads/main"))
+}
+
+func bufioReader(s string) *bufio.Reader {
+	return bufio.NewReader(strings.NewReader(s))
+}
@@ -23,6 +23,7 @@ import (
 	"io"
 	"net/url"
 	"path/filepath"
+	"sort"
 	"time"
 
 	"github.com/go-git/go-billy/v5"
@@ -61,6 +62,11 @@ func init() {
 	transport.UnsupportedCapabilities = []capability.Capability{
 		capability.ThinPack,
 	}
+
+	// The operations of the clients configured with a TransportCache are
+	// routed to the connections of the cache by the installed transports,
+	// the other operations are served by the go-git transports as before.
+	installCacheTransport()
 }
 
 // ClientName is the string representation of Client.
@@ -78,9 +84,15 @@ type Client struct {
 	useDefaultKnownHosts bool
 	singleBranch         bool
 	proxy                transport.ProxyOptions
+	transportCache       *TransportCache
+	cloneCache           *CloneCache
+	credentialsProvider  CredentialsProvider
 }
 
-var _ repository.Client = &Client{}
+var (
+	_ repository.Client        = &Client{}
+	_ repository.FileCommitter = &Client{}
+)
 
 type ClientOption func(*Client) error
 
@@ -205,6 +217,15 @@ func WithProxy(opts transport.ProxyOptions) ClientOption {
 	}
 }
 
+// WithTransportCache configures the client to reuse the connections and
+// authentication methods of the provided cache.
+func WithTransportCache(cache *TransportCache) ClientOption {
+	return func(c *Client) error {
+		c.transportCache = cache
+		return nil
+	}
+}
+
 func (g *Client) Init(ctx context.Context, url, branch string) error {
 	if err := g.validateUrl(url); err != nil {
 		return err
@@ -270,6 +291,40 @@ func (g *Client) Clone(ctx context.Context, url string, cfg repository.CloneConf
 	}
 }
 
+// ListReferences lists the branches and/or tags of the remote repository at
+// the provided url, without cloning it. Annotated tags are resolved to the
+// commit they point to.
+func (g *Client) ListReferences(ctx context.Context, url string, cfg repository.ListReferencesConfig) ([]git.Reference, error) {
+	if err := g.validateUrl(url); err != nil {
+		return nil, err
+	}
+
+	authMethod, err := g.transportAuth(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to construct auth method with options: %w", err)
+	}
+
+	var refs []*plumbing.Reference
+	err = retryWithFreshCredentials(authMethod, func() error {
+		refs, err = g.listRemote(ctx, url, authMethod)
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, transport.ErrRepositoryNotFound) {
+			return nil, git.ErrRepositoryNotFound{
+				Message: fmt.Sprintf("unable to list references: %s", err),
+				URL:     url,
+			}
+		}
+		if errors.Is(err, transport.ErrEmptyRemoteRepository) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return buildReferences(refs, cfg), nil
+}
+
 func (g *Client) validateUrl(u string) error {
 	ru, err := url.Parse(u)
 	if err != nil {
@@ -295,6 +350,10 @@ func (g *Client) validateUrl(u string) error {
 		return errors.New("URL cannot contain credentials when using HTTP")
 	}
 
+	if httpOrEmpty && g.credentialsProvider != nil {
+		return errors.New("credentials provider cannot be used over HTTP")
+	}
+
 	if httpOrEmpty && g.authOpts != nil {
 		if g.authOpts.Username != "" || g.authOpts.Password != "" {
 			return errors.New("basic auth cannot be sent over HTTP")
@@ -347,13 +406,13 @@ func (g *Client) Commit(info git.Commit, commitOpts ...repository.CommitOption)
 		return "", err
 	}
 
-	var changed bool
+	var changed []string
 	for file := range status {
 		_, _ = wt.Add(file)
-		changed = true
+		changed = append(changed, filepath.ToSlash(file))
 	}
 
-	if !changed {
+	if len(changed) == 0 {
 		head, err := g.repository.Head()
 		if err != nil {
 			return "", err
@@ -373,19 +432,109 @@ func (g *Client) Commit(info git.Commit, commitOpts ...repository.CommitOption)
 		opts.SignKey = options.Signer
 	}
 
-	commit, err := wt.Commit(info.Message, opts)
+	message, err := options.RenderMessage(info.Message, changed)
+	if err != nil {
+		return "", err
+	}
+
+	commit, err := wt.Commit(message, opts)
 	if err != nil {
 		return "", err
 	}
 	return commit.String(), nil
 }
 
+func (g *Client) CommitFiles(info git.Commit, branch string, changes []repository.FileChange, commitOpts ...repository.CommitOption) (string, error) {
+	if g.repository == nil {
+		return "", git.ErrNoGitRepository
+	}
+
+	options := &repository.CommitOptions{}
+	for _, o := range commitOpts {
+		o(options)
+	}
+
+	// The files of the options are committed along with the changes.
+	paths := make([]string, 0, len(options.Files))
+	for p := range options.Files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	for _, p := range paths {
+		content, err := io.ReadAll(options.Files[p])
+		if err != nil {
+			return "", fmt.Errorf("unable to read file '%s': %w", p, err)
+		}
+		changes = append(changes, repository.FileChange{Path: p, Content: content})
+	}
+
+	refName := plumbing.NewBranchReferenceName(branch)
+	oldRef, err := g.repository.Reference(refName, true)
+	if err != nil && !errors.Is(err, plumbing.ErrReferenceNotFound) {
+		return "", err
+	}
+	// If the branch doesn't exist, it is created from HEAD.
+	parentRef := oldRef
+	if parentRef == nil {
+		parentRef, err = g.repository.Head()
+		if err != nil && !errors.Is(err, plumbing.ErrReferenceNotFound) {
+			return "", err
+		}
+	}
+
+	var parents []plumbing.Hash
+	var baseTree *object.Tree
+	if parentRef != nil {
+		parent, err := g.repository.CommitObject(parentRef.Hash())
+		if err != nil {
+			return "", fmt.Errorf("unable to resolve commit for '%s': %w", parentRef.Name(), err)
+		}
+		if baseTree, err = parent.Tree(); err != nil {
+			return "", err
+		}
+		parents = append(parents, parent.Hash)
+	}
+
+	treeHash, err := writeTree(g.repository.Storer, baseTree, changes)
+	if err != nil {
+		return "", err
+	}
+	if baseTree != nil && baseTree.Hash == treeHash {
+		return parents[0].String(), git.ErrNoStagedFiles
+	}
+
+	changed := make([]string, 0, len(changes))
+	for _, change := range changes {
+		changed = append(changed, change.Path)
+	}
+	message, err := options.RenderMessage(info.Message, changed)
+	if err != nil {
+		return "", err
+	}
+
+	signature := object.Signature{
+		Name:  info.Author.Name,
+		Email: info.Author.Email,
+		When:  time.Now(),
+	}
+	hash, err := writeCommit(g.repository.Storer, treeHash, parents, message, signature, signature, options.Signer)
+	if err != nil {
+		return "", fmt.Errorf("unable to write commit: %w", err)
+	}
+
+	// Only update the branch if it wasn't changed in the meantime.
+	if err := g.repository.Storer.CheckAndSetReference(plumbing.NewHashReference(refName, hash), oldRef); err != nil {
+		return "", fmt.Errorf("unable to update branch '%s': %w", branch, err)
+	}
+	return hash.String(), nil
+}
+
 func (g *Client) Push(ctx context.Context, cfg repository.PushConfig) error {
 	if g.repository == nil {
 		return git.ErrNoGitRepository
 	}
 
-	authMethod, err := transportAuth(g.authOpts, g.useDefaultKnownHosts)
+	authMethod, err := g.transportAuth(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to construct auth method with options: %w", err)
 	}
@@ -408,21 +557,46 @@ func (g *Client) Push(ctx context.Context, cfg repository.PushConfig) error {
 		refspecs = append(refspecs, headRefspec)
 	}
 
-	err = g.repository.PushContext(ctx, &extgogit.PushOptions{
-		RefSpecs:     refspecs,
-		Force:        cfg.Force,
-		RemoteName:   extgogit.DefaultRemoteName,
-		Auth:         authMethod,
-		Progress:     nil,
-		CABundle:     caBundle(g.authOpts),
-		ProxyOptions: g.proxy,
-		Options:      cfg.Options,
-	})
-	if err != nil {
-		return fmt.Errorf("failed to push to remote: %w", err)
-	}
+	strategy := cfg.RetryStrategy
+	switch strategy {
+	case "", repository.PushRetryFail, repository.PushRetryRebase, repository.PushRetryOurs:
+	default:
+		return fmt.Errorf("unsupported push retry strategy '%s'", strategy)
+	}
+	attempts := cfg.RetryAttempts
+	if attempts <= 0 {
+		attempts = repository.DefaultPushRetryAttempts
+	}
+
+	for attempt := 0; ; attempt++ {
+		err = retryWithFreshCredentials(authMethod, func() error {
+			return g.repository.PushContext(ctx, &extgogit.PushOptions{
+				RefSpecs:     refspecs,
+				Force:        cfg.Force,
+				RemoteName:   extgogit.DefaultRemoteName,
+				Auth:         authMethod,
+				Progress:     nil,
+				CABundle:     caBundle(g.authOpts),
+				ProxyOptions: g.proxy,
+				Options:      cfg.Options,
+			})
+		})
+		if err == nil {
+			return nil
+		}
+		if !isRemoteRefMoved(err) {
+			return fmt.Errorf("failed to push to remote: %w", err)
+		}
 
-	return nil
+		// Retry by replaying the local commits on top of the remote branch.
+		if cfg.Force || strategy == "" || strategy == repository.PushRetryFail ||
+			len(refspecs) != 1 || attempt >= attempts {
+			return fmt.Errorf("failed to push to remote: %w: %w", git.ErrRemoteRefMoved, err)
+		}
+		if err := g.replayOnRemote(ctx, authMethod, refspecs[0], strategy, cfg.Signer); err != nil {
+			return fmt.Errorf("failed to push to remote: %w", err)
+		}
+	}
 }
 
 // SwitchBranch switches the current branch to the given branch name.
@@ -18,6 +18,7 @@ package gogit
 
 import (
 	"context"
+	"errors"
 	"io"
 	"os"
 	"path/filepath"
@@ -25,8 +26,11 @@ import (
 	"testing"
 	"time"
 
+	"github.com/ProtonMail/go-crypto/openpgp"
 	extgogit "github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
 	. "github.com/onsi/gomega"
 
 	"github.com/fluxcd/pkg/git"
@@ -173,6 +177,206 @@ func TestCommit(t *testing.T) {
 	g.Expect(cc).ToNot(Equal(hash))
 }
 
+func TestCommit_MessageTemplate(t *testing.T) {
+	g := NewWithT(t)
+
+	server, err := gittestserver.NewTempGitServer()
+	g.Expect(err).ToNot(HaveOccurred())
+	defer os.RemoveAll(server.Root())
+
+	err = server.InitRepo("../testdata/git/repo", git.DefaultBranch, "test.git")
+	g.Expect(err).ToNot(HaveOccurred())
+	tmp := t.TempDir()
+	repo, err := extgogit.PlainClone(tmp, false, &extgogit.CloneOptions{
+		URL: filepath.Join(server.Root(), "test.git"),
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+
+	ggc, err := NewClient(tmp, nil)
+	g.Expect(err).ToNot(HaveOccurred())
+	ggc.repository = repo
+
+	info := git.Commit{
+		Author: git.Signature{
+			Name:  "Test User",
+			Email: "test@example.com",
+		},
+		Message: "Update images",
+	}
+	tmpl := `{{ .Message }} to {{ .Policies.app }}
+
+Files:
+{{ range .Changed }}- {{ . }}
+{{ end }}`
+	data := repository.CommitTemplateData{
+		Policies: map[string]string{"app": "ghcr.io/org/app:v1.0.1"},
+	}
+
+	cc, err := ggc.Commit(info,
+		repository.WithFiles(map[string]io.Reader{
+			"b.yaml": strings.NewReader("b"),
+			"a.yaml": strings.NewReader("a"),
+		}),
+		repository.WithMessageTemplate(tmpl, data),
+	)
+	g.Expect(err).ToNot(HaveOccurred())
+	commit, err := repo.CommitObject(plumbing.NewHash(cc))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(commit.Message).To(Equal("Update images to ghcr.io/org/app:v1.0.1\n\nFiles:\n- a.yaml\n- b.yaml\n"))
+
+	cc, err = ggc.CommitFiles(info, git.DefaultBranch, []repository.FileChange{
+		{Path: "dir/c.yaml", Content: []byte("c")},
+	}, repository.WithMessageTemplate(tmpl, data))
+	g.Expect(err).ToNot(HaveOccurred())
+	commit, err = repo.CommitObject(plumbing.NewHash(cc))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(commit.Message).To(Equal("Update images to ghcr.io/org/app:v1.0.1\n\nFiles:\n- dir/c.yaml\n"))
+
+	// Templates referencing missing values are rejected.
+	_, err = ggc.CommitFiles(info, git.DefaultBranch, []repository.FileChange{
+		{Path: "dir/d.yaml", Content: []byte("d")},
+	}, repository.WithMessageTemplate("{{ .Policies.missing }}", data))
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestCommitFiles(t *testing.T) {
+	g := NewWithT(t)
+
+	server, err := gittestserver.NewTempGitServer()
+	g.Expect(err).ToNot(HaveOccurred())
+	defer os.RemoveAll(server.Root())
+
+	err = server.InitRepo("../testdata/git/repo", git.DefaultBranch, "test.git")
+	g.Expect(err).ToNot(HaveOccurred())
+	tmp := t.TempDir()
+	repo, err := extgogit.PlainClone(tmp, false, &extgogit.CloneOptions{
+		URL: filepath.Join(server.Root(), "test.git"),
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+
+	ggc, err := NewClient(tmp, nil)
+	g.Expect(err).ToNot(HaveOccurred())
+	ggc.repository = repo
+
+	ref, err := repo.Head()
+	g.Expect(err).ToNot(HaveOccurred())
+	head := ref.Hash().String()
+
+	info := git.Commit{
+		Author: git.Signature{
+			Name:  "Test User",
+			Email: "test@example.com",
+		},
+		Message: "testing",
+	}
+
+	// No new commit made when the changes don't modify the tree.
+	cc, err := ggc.CommitFiles(info, git.DefaultBranch, []repository.FileChange{
+		{Path: "missing.txt", Delete: true},
+	})
+	g.Expect(err).To(Equal(git.ErrNoStagedFiles))
+	g.Expect(cc).To(Equal(head))
+
+	// Invalid paths are rejected.
+	_, err = ggc.CommitFiles(info, git.DefaultBranch, []repository.FileChange{
+		{Path: "../outside.txt", Content: []byte("test")},
+	})
+	g.Expect(err).To(HaveOccurred())
+	_, err = ggc.CommitFiles(info, git.DefaultBranch, []repository.FileChange{
+		{Path: ".git/config", Content: []byte("test")},
+	})
+	g.Expect(err).To(HaveOccurred())
+
+	cc, err = ggc.CommitFiles(info, git.DefaultBranch, []repository.FileChange{
+		{Path: "foo.txt", Delete: true},
+		{Path: "dir/sub/bar.txt", Content: []byte("testing gogit commit files")},
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(cc).ToNot(Equal(head))
+
+	commit, err := repo.CommitObject(plumbing.NewHash(cc))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(commit.Message).To(Equal("testing"))
+	g.Expect(commit.ParentHashes).To(Equal([]plumbing.Hash{plumbing.NewHash(head)}))
+	_, err = commit.File("foo.txt")
+	g.Expect(err).To(Equal(object.ErrFileNotFound))
+	f, err := commit.File("dir/sub/bar.txt")
+	g.Expect(err).ToNot(HaveOccurred())
+	content, err := f.Contents()
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(content).To(Equal("testing gogit commit files"))
+
+	// The branch is updated, but the working tree is left untouched.
+	ref, err = repo.Reference(plumbing.NewBranchReferenceName(git.DefaultBranch), true)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(ref.Hash().String()).To(Equal(cc))
+	_, err = os.Stat(filepath.Join(tmp, "foo.txt"))
+	g.Expect(err).ToNot(HaveOccurred())
+	_, err = os.Stat(filepath.Join(tmp, "dir"))
+	g.Expect(os.IsNotExist(err)).To(BeTrue())
+
+	// A missing branch is created from HEAD.
+	nc, err := ggc.CommitFiles(info, "new-branch", []repository.FileChange{
+		{Path: "dir/sub/bar.txt", Delete: true},
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+	commit, err = repo.CommitObject(plumbing.NewHash(nc))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(commit.ParentHashes).To(Equal([]plumbing.Hash{plumbing.NewHash(cc)}))
+	tree, err := commit.Tree()
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(tree.Entries).To(BeEmpty())
+
+	// Conflicting changes are rejected.
+	for _, changes := range [][]repository.FileChange{
+		{{Path: "a.txt", Content: []byte("a")}, {Path: "./a.txt", Delete: true}},
+		{{Path: "a", Content: []byte("a")}, {Path: "a/b.txt", Content: []byte("b")}},
+		{{Path: "dir", Content: []byte("dir")}},
+		{{Path: "dir/sub/bar.txt/baz.txt", Content: []byte("baz")}},
+	} {
+		_, err = ggc.CommitFiles(info, git.DefaultBranch, changes)
+		g.Expect(err).To(HaveOccurred())
+	}
+
+	// A directory is replaced by a file when its content is deleted.
+	cc, err = ggc.CommitFiles(info, git.DefaultBranch, []repository.FileChange{
+		{Path: "dir/sub/bar.txt", Delete: true},
+		{Path: "dir/sub", Content: []byte("sub")},
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+	commit, err = repo.CommitObject(plumbing.NewHash(cc))
+	g.Expect(err).ToNot(HaveOccurred())
+	f, err = commit.File("dir/sub")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(f.Mode).To(Equal(filemode.Regular))
+
+	// The files of the options are committed, and the commit is signed.
+	signer, err := openpgp.NewEntity("Test User", "", "test@example.com", nil)
+	g.Expect(err).ToNot(HaveOccurred())
+	cc, err = ggc.CommitFiles(info, git.DefaultBranch, []repository.FileChange{
+		{Path: "dir/sub", Delete: true},
+	}, repository.WithFiles(map[string]io.Reader{
+		"options.txt": strings.NewReader("testing options files"),
+	}), repository.WithSigner(signer))
+	g.Expect(err).ToNot(HaveOccurred())
+	commit, err = repo.CommitObject(plumbing.NewHash(cc))
+	g.Expect(err).ToNot(HaveOccurred())
+	_, err = commit.File("dir/sub")
+	g.Expect(err).To(Equal(object.ErrFileNotFound))
+	f, err = commit.File("options.txt")
+	g.Expect(err).ToNot(HaveOccurred())
+	content, err = f.Contents()
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(content).To(Equal("testing options files"))
+
+	encoded := &plumbing.MemoryObject{}
+	g.Expect(commit.EncodeWithoutSignature(encoded)).To(Succeed())
+	r, err := encoded.Reader()
+	g.Expect(err).ToNot(HaveOccurred())
+	_, err = openpgp.CheckArmoredDetachedSignature(openpgp.EntityList{signer}, r, strings.NewReader(commit.PGPSignature), nil)
+	g.Expect(err).ToNot(HaveOccurred())
+}
+
 func TestPush(t *testing.T) {
 	g := NewWithT(t)
 
@@ -392,6 +596,85 @@ func TestForcePush(t *testing.T) {
 	g.Expect(ref.Hash().String()).To(Equal(cc2.String()))
 }
 
+func TestPush_retry(t *testing.T) {
+	g := NewWithT(t)
+
+	server, repoURL, err := setupGitServer(false)
+	g.Expect(err).ToNot(HaveOccurred())
+	defer os.RemoveAll(server.Root())
+	defer server.StopHTTP()
+
+	clone := func() (*Client, *extgogit.Repository) {
+		tmp := t.TempDir()
+		repo, err := extgogit.PlainClone(tmp, false, &extgogit.CloneOptions{
+			URL:        repoURL,
+			RemoteName: git.DefaultRemote,
+			Tags:       extgogit.NoTags,
+		})
+		g.Expect(err).ToNot(HaveOccurred())
+		ggc, err := NewClient(tmp, nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		ggc.repository = repo
+		return ggc, repo
+	}
+
+	local, localRepo := clone()
+	other, otherRepo := clone()
+
+	// Update the remote branch from another clone.
+	remoteCC, err := commitFile(otherRepo, "other", "remote change", time.Now())
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(other.Push(context.TODO(), repository.PushConfig{})).To(Succeed())
+
+	_, err = commitFile(localRepo, "mine", "local change", time.Now())
+	g.Expect(err).ToNot(HaveOccurred())
+
+	err = local.Push(context.TODO(), repository.PushConfig{})
+	g.Expect(errors.Is(err, git.ErrRemoteRefMoved)).To(BeTrue())
+
+	err = local.Push(context.TODO(), repository.PushConfig{RetryStrategy: repository.PushRetryRebase})
+	g.Expect(err).ToNot(HaveOccurred())
+
+	head, err := localRepo.Head()
+	g.Expect(err).ToNot(HaveOccurred())
+	commit, err := localRepo.CommitObject(head.Hash())
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(commit.ParentHashes).To(Equal([]plumbing.Hash{remoteCC}))
+	g.Expect(commit.Message).To(Equal("Adding: mine"))
+	_, err = os.Stat(filepath.Join(local.path, "other"))
+	g.Expect(err).ToNot(HaveOccurred())
+
+	// Conflicting changes fail with the rebase strategy, and are
+	// overwritten with the ours strategy.
+	_, err = commitFile(otherRepo, "other", "remote conflict", time.Now())
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(other.Push(context.TODO(), repository.PushConfig{
+		RetryStrategy: repository.PushRetryRebase,
+	})).To(Succeed())
+
+	_, err = commitFile(localRepo, "other", "local conflict", time.Now())
+	g.Expect(err).ToNot(HaveOccurred())
+
+	err = local.Push(context.TODO(), repository.PushConfig{RetryStrategy: repository.PushRetryRebase})
+	g.Expect(errors.Is(err, git.ErrRebaseConflict)).To(BeTrue())
+
+	err = local.Push(context.TODO(), repository.PushConfig{RetryStrategy: repository.PushRetryOurs})
+	g.Expect(err).ToNot(HaveOccurred())
+
+	verify, verifyRepo := clone()
+	head, err = verifyRepo.Head()
+	g.Expect(err).ToNot(HaveOccurred())
+	localHead, err := localRepo.Head()
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(head.Hash()).To(Equal(localHead.Hash()))
+	content, err := os.ReadFile(filepath.Join(verify.path, "other"))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(string(content)).To(Equal("local conflict"))
+
+	err = local.Push(context.TODO(), repository.PushConfig{RetryStrategy: "merge"})
+	g.Expect(err).To(HaveOccurred())
+}
+
 func TestSwitchBranch(t *testing.T) {
 	tests := []struct {
 		name         string
@@ -635,6 +918,55 @@ func TestHead(t *testing.T) {
 	g.Expect(hash.String()).To(Equal(cc))
 }
 
+func TestListReferences(t *testing.T) {
+	g := NewWithT(t)
+
+	repo, path, err := initRepo(t.TempDir())
+	g.Expect(err).ToNot(HaveOccurred())
+
+	first, err := commitFile(repo, "test", "first commit", time.Now())
+	g.Expect(err).ToNot(HaveOccurred())
+	_, err = tag(repo, first, false, "v0.1.0", time.Now())
+	g.Expect(err).ToNot(HaveOccurred())
+
+	g.Expect(createBranch(repo, "feature")).To(Succeed())
+	second, err := commitFile(repo, "test", "second commit", time.Now())
+	g.Expect(err).ToNot(HaveOccurred())
+	_, err = tag(repo, second, true, "v0.2.0", time.Now())
+	g.Expect(err).ToNot(HaveOccurred())
+
+	ggc, err := NewClient(t.TempDir(), nil)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	refs, err := ggc.ListReferences(context.TODO(), path, repository.ListReferencesConfig{
+		Tags: true,
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(refs).To(Equal([]git.Reference{
+		{Name: "refs/tags/v0.1.0", Hash: git.Hash(first.String())},
+		{Name: "refs/tags/v0.2.0", Hash: git.Hash(second.String())},
+	}))
+
+	refs, err = ggc.ListReferences(context.TODO(), path, repository.ListReferencesConfig{
+		Branches: true,
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(refs).To(Equal([]git.Reference{
+		{Name: "refs/heads/feature", Hash: git.Hash(second.String())},
+		{Name: "refs/heads/" + git.DefaultBranch, Hash: git.Hash(first.String())},
+	}))
+
+	refs, err = ggc.ListReferences(context.TODO(), path, repository.ListReferencesConfig{})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(refs).To(ContainElements(
+		git.Reference{Name: "refs/heads/feature", Hash: git.Hash(second.String())},
+		git.Reference{Name: "refs/tags/v0.2.0", Hash: git.Hash(second.String())},
+	))
+
+	// The local repository is left untouched.
+	g.Expect(ggc.repository).To(BeNil())
+}
+
 func TestValidateUrl(t *testing.T) {
 	tests := []struct {
 		name                string
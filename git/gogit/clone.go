@@ -21,6 +21,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"regexp"
 	"sort"
 	"strings"
 	"time"
@@ -44,7 +45,7 @@ func (g *Client) cloneBranch(ctx context.Context, url, branch string, opts repos
 	if g.authOpts == nil {
 		return nil, fmt.Errorf("unable to checkout repo with an empty set of auth options")
 	}
-	authMethod, err := transportAuth(g.authOpts, g.useDefaultKnownHosts)
+	authMethod, err := g.transportAuth(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("unable to construct auth method with options: %w", err)
 	}
@@ -80,14 +81,14 @@ func (g *Client) cloneBranch(ctx context.Context, url, branch string, opts repos
 		SingleBranch:      g.singleBranch,
 		NoCheckout:        false,
 		Depth:             depth,
-		RecurseSubmodules: recurseSubmodules(opts.RecurseSubmodules),
+		RecurseSubmodules: recurseSubmodules(opts),
 		Progress:          nil,
 		Tags:              extgogit.NoTags,
 		CABundle:          caBundle(g.authOpts),
 		ProxyOptions:      g.proxy,
 	}
 
-	repo, err := extgogit.CloneContext(ctx, g.storer, g.worktreeFS, cloneOpts)
+	repo, err := g.cloneContext(ctx, cloneOpts)
 	if err != nil {
 		if err == transport.ErrRepositoryNotFound || isRemoteBranchNotFoundErr(err, ref.String()) {
 			return nil, git.ErrRepositoryNotFound{
@@ -118,6 +119,10 @@ func (g *Client) cloneBranch(ctx context.Context, url, branch string, opts repos
 	if err != nil {
 		return nil, fmt.Errorf("unable to resolve commit object for HEAD '%s': %w", head.Hash(), err)
 	}
+	if err = g.updateSubmodules(ctx, repo, url, authMethod, opts.Submodules); err != nil {
+		return nil, err
+	}
+
 	g.repository = repo
 	return buildCommitWithRef(cc, nil, ref)
 }
@@ -127,7 +132,7 @@ func (g *Client) cloneTag(ctx context.Context, url, tag string, opts repository.
 		return nil, fmt.Errorf("unable to checkout repo with an empty set of auth options")
 	}
 
-	authMethod, err := transportAuth(g.authOpts, g.useDefaultKnownHosts)
+	authMethod, err := g.transportAuth(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("unable to construct auth method with options: %w", err)
 	}
@@ -163,7 +168,7 @@ func (g *Client) cloneTag(ctx context.Context, url, tag string, opts repository.
 		SingleBranch:      g.singleBranch,
 		NoCheckout:        false,
 		Depth:             depth,
-		RecurseSubmodules: recurseSubmodules(opts.RecurseSubmodules),
+		RecurseSubmodules: recurseSubmodules(opts),
 		Progress:          nil,
 		// Ask for the tag object that points to the commit to be sent as well.
 		Tags:         extgogit.TagFollowing,
@@ -171,7 +176,7 @@ func (g *Client) cloneTag(ctx context.Context, url, tag string, opts repository.
 		ProxyOptions: g.proxy,
 	}
 
-	repo, err := extgogit.CloneContext(ctx, g.storer, g.worktreeFS, cloneOpts)
+	repo, err := g.cloneContext(ctx, cloneOpts)
 	if err != nil {
 		if err == transport.ErrEmptyRemoteRepository || err == transport.ErrRepositoryNotFound || isRemoteBranchNotFoundErr(err, ref.String()) {
 			return nil, git.ErrRepositoryNotFound{
@@ -201,12 +206,16 @@ func (g *Client) cloneTag(ctx context.Context, url, tag string, opts repository.
 		return nil, fmt.Errorf("unable to resolve tag object for tag '%s' with hash '%s': %w", tag, tagRef.Hash(), err)
 	}
 
+	if err = g.updateSubmodules(ctx, repo, url, authMethod, opts.Submodules); err != nil {
+		return nil, err
+	}
+
 	g.repository = repo
 	return buildCommitWithRef(cc, tagObj, ref)
 }
 
 func (g *Client) cloneCommit(ctx context.Context, url, commit string, opts repository.CloneConfig) (*git.Commit, error) {
-	authMethod, err := transportAuth(g.authOpts, g.useDefaultKnownHosts)
+	authMethod, err := g.transportAuth(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("unable to construct auth method with options: %w", err)
 	}
@@ -223,7 +232,7 @@ func (g *Client) cloneCommit(ctx context.Context, url, commit string, opts repos
 		RemoteName:        git.DefaultRemote,
 		SingleBranch:      false,
 		NoCheckout:        true,
-		RecurseSubmodules: recurseSubmodules(opts.RecurseSubmodules),
+		RecurseSubmodules: recurseSubmodules(opts),
 		Progress:          nil,
 		Tags:              tagStrategy,
 		CABundle:          caBundle(g.authOpts),
@@ -234,7 +243,7 @@ func (g *Client) cloneCommit(ctx context.Context, url, commit string, opts repos
 		cloneOpts.ReferenceName = plumbing.NewBranchReferenceName(opts.Branch)
 	}
 
-	repo, err := extgogit.CloneContext(ctx, g.storer, g.worktreeFS, cloneOpts)
+	repo, err := g.cloneContext(ctx, cloneOpts)
 	if err != nil {
 		if err == transport.ErrEmptyRemoteRepository || err == transport.ErrRepositoryNotFound ||
 			isRemoteBranchNotFoundErr(err, cloneOpts.ReferenceName.String()) {
@@ -266,17 +275,21 @@ func (g *Client) cloneCommit(ctx context.Context, url, commit string, opts repos
 		cloneOpts.ReferenceName = plumbing.ReferenceName(opts.RefName)
 	}
 
+	if err = g.updateSubmodules(ctx, repo, url, authMethod, opts.Submodules); err != nil {
+		return nil, err
+	}
+
 	g.repository = repo
 	return buildCommitWithRef(cc, nil, cloneOpts.ReferenceName)
 }
 
-func (g *Client) cloneSemVer(ctx context.Context, url, semverTag string, opts repository.CloneConfig) (*git.Commit, error) {
-	verConstraint, err := semver.NewConstraint(semverTag)
+func (g *Client) cloneSemVer(ctx context.Context, url, semverExpr string, opts repository.CloneConfig) (*git.Commit, error) {
+	selector, err := newSemVerSelector(semverExpr, opts.SemVerFilter)
 	if err != nil {
-		return nil, fmt.Errorf("semver parse error: %w", err)
+		return nil, err
 	}
 
-	authMethod, err := transportAuth(g.authOpts, g.useDefaultKnownHosts)
+	authMethod, err := g.transportAuth(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("unable to construct auth method with options: %w", err)
 	}
@@ -290,14 +303,14 @@ func (g *Client) cloneSemVer(ctx context.Context, url, semverTag string, opts re
 		RemoteName:        git.DefaultRemote,
 		NoCheckout:        false,
 		Depth:             depth,
-		RecurseSubmodules: recurseSubmodules(opts.RecurseSubmodules),
+		RecurseSubmodules: recurseSubmodules(opts),
 		Progress:          nil,
 		Tags:              extgogit.AllTags,
 		CABundle:          caBundle(g.authOpts),
 		ProxyOptions:      g.proxy,
 	}
 
-	repo, err := extgogit.CloneContext(ctx, g.storer, g.worktreeFS, cloneOpts)
+	repo, err := g.cloneContext(ctx, cloneOpts)
 	if err != nil {
 		if err == transport.ErrEmptyRemoteRepository || err == transport.ErrRepositoryNotFound {
 			return nil, git.ErrRepositoryNotFound{
@@ -313,9 +326,14 @@ func (g *Client) cloneSemVer(ctx context.Context, url, semverTag string, opts re
 		return nil, fmt.Errorf("unable to list tags: %w", err)
 	}
 
-	tags := make(map[string]string)
-	tagTimestamps := make(map[string]time.Time)
+	var candidates []semverTag
 	if err = repoTags.ForEach(func(t *plumbing.Reference) error {
+		name := t.Name().Short()
+		v, err := version.ParseVersion(name)
+		if err != nil || !selector.matches(name, v) {
+			return nil
+		}
+
 		revision := plumbing.Revision(t.Name().String())
 		hash, err := repo.ResolveRevision(revision)
 		if err != nil {
@@ -325,46 +343,26 @@ func (g *Client) cloneSemVer(ctx context.Context, url, semverTag string, opts re
 		if err != nil {
 			return fmt.Errorf("unable to resolve commit of a tag revision: %w", err)
 		}
-		tagTimestamps[t.Name().Short()] = commit.Committer.When
+		_, err = repo.TagObject(t.Hash())
+		if err != nil && err != plumbing.ErrObjectNotFound {
+			return fmt.Errorf("unable to resolve tag object for tag '%s': %w", name, err)
+		}
 
-		tags[t.Name().Short()] = t.Strings()[1]
+		candidates = append(candidates, semverTag{
+			version:   v,
+			annotated: err == nil,
+			timestamp: commit.Committer.When,
+		})
 		return nil
 	}); err != nil {
 		return nil, err
 	}
-
-	var matchedVersions semver.Collection
-	for tag := range tags {
-		v, err := version.ParseVersion(tag)
-		if err != nil {
-			continue
-		}
-		if !verConstraint.Check(v) {
-			continue
-		}
-		matchedVersions = append(matchedVersions, v)
-	}
-	if len(matchedVersions) == 0 {
-		return nil, fmt.Errorf("no match found for semver: %s", semverTag)
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("no match found for semver: %s", semverExpr)
 	}
 
-	// Sort versions
-	sort.SliceStable(matchedVersions, func(i, j int) bool {
-		left := matchedVersions[i]
-		right := matchedVersions[j]
-
-		if !left.Equal(right) {
-			return left.LessThan(right)
-		}
-
-		// Having tag target timestamps at our disposal, we further try to sort
-		// versions into a chronological order. This is especially important for
-		// versions that differ only by build metadata, because it is not considered
-		// a part of the comparable version in Semver
-		return tagTimestamps[left.Original()].Before(tagTimestamps[right.Original()])
-	})
-	v := matchedVersions[len(matchedVersions)-1]
-	t := v.Original()
+	selector.sort(candidates)
+	t := candidates[0].version.Original()
 
 	w, err := repo.Worktree()
 	if err != nil {
@@ -382,6 +380,10 @@ func (g *Client) cloneSemVer(ctx context.Context, url, semverTag string, opts re
 		return nil, fmt.Errorf("unable to checkout tag '%s': %w", t, err)
 	}
 
+	if err = g.updateSubmodules(ctx, repo, url, authMethod, opts.Submodules); err != nil {
+		return nil, err
+	}
+
 	head, err := repo.Head()
 	if err != nil {
 		return nil, fmt.Errorf("unable to resolve HEAD of tag '%s': %w", t, err)
@@ -396,15 +398,123 @@ func (g *Client) cloneSemVer(ctx context.Context, url, semverTag string, opts re
 		return nil, fmt.Errorf("unable to resolve tag object for tag '%s' with hash '%s': %w", t, tagRef.Hash(), err)
 	}
 
+	c, err := buildCommitWithRef(cc, tagObj, tagRef.Name())
+	if err != nil {
+		return nil, err
+	}
+	for _, candidate := range candidates {
+		c.SemVerCandidates = append(c.SemVerCandidates, candidate.version.Original())
+	}
+
 	g.repository = repo
-	return buildCommitWithRef(cc, tagObj, tagRef.Name())
+	return c, nil
+}
+
+// semverTag holds the details of a tag which are taken into account when
+// selecting a tag for a SemVer checkout.
+type semverTag struct {
+	version   *semver.Version
+	annotated bool
+	timestamp time.Time
+}
+
+// semverSelector selects tags based on a SemVer expression and an optional
+// repository.SemVerFilter.
+type semverSelector struct {
+	constraint  *semver.Constraints
+	include     *regexp.Regexp
+	exclude     *regexp.Regexp
+	prereleases bool
+	preference  repository.TagPreference
+}
+
+func newSemVerSelector(expr string, filter *repository.SemVerFilter) (*semverSelector, error) {
+	constraint, err := semver.NewConstraint(expr)
+	if err != nil {
+		return nil, fmt.Errorf("semver parse error: %w", err)
+	}
+
+	s := &semverSelector{
+		constraint: constraint,
+	}
+	if filter == nil {
+		return s, nil
+	}
+
+	if filter.Include != "" {
+		if s.include, err = regexp.Compile(filter.Include); err != nil {
+			return nil, fmt.Errorf("invalid semver include expression '%s': %w", filter.Include, err)
+		}
+	}
+	if filter.Exclude != "" {
+		if s.exclude, err = regexp.Compile(filter.Exclude); err != nil {
+			return nil, fmt.Errorf("invalid semver exclude expression '%s': %w", filter.Exclude, err)
+		}
+	}
+	switch filter.TagPreference {
+	case repository.TagPreferenceNone, repository.TagPreferenceAnnotated, repository.TagPreferenceLightweight:
+		s.preference = filter.TagPreference
+	default:
+		return nil, fmt.Errorf("unknown tag preference '%s'", filter.TagPreference)
+	}
+	s.prereleases = filter.IncludePrereleases
+	return s, nil
+}
+
+// matches returns true if the tag with the given name and version is a
+// candidate for the checkout.
+func (s *semverSelector) matches(name string, v *semver.Version) bool {
+	if s.exclude != nil && s.exclude.MatchString(name) {
+		return false
+	}
+	if s.include != nil && !s.include.MatchString(name) {
+		return false
+	}
+	if s.constraint.Check(v) {
+		return true
+	}
+	if s.prereleases && v.Prerelease() != "" {
+		release, err := v.SetPrerelease("")
+		return err == nil && s.constraint.Check(&release)
+	}
+	return false
+}
+
+// sort sorts the given tags from the highest to the lowest version.
+func (s *semverSelector) sort(tags []semverTag) {
+	sort.SliceStable(tags, func(i, j int) bool {
+		left := tags[i]
+		right := tags[j]
+
+		if !left.version.Equal(right.version) {
+			return left.version.GreaterThan(right.version)
+		}
+
+		// Versions that differ only by build metadata (or by a "v" prefix)
+		// are considered equal. The configured tag preference takes
+		// precedence over the timestamp of the tag targets.
+		if left.annotated != right.annotated {
+			switch s.preference {
+			case repository.TagPreferenceAnnotated:
+				return left.annotated
+			case repository.TagPreferenceLightweight:
+				return right.annotated
+			}
+		}
+
+		// Having tag target timestamps at our disposal, we further try to sort
+		// versions into a chronological order. This is especially important for
+		// versions that differ only by build metadata, because it is not considered
+		// a part of the comparable version in Semver
+		return left.timestamp.After(right.timestamp)
+	})
 }
 
 func (g *Client) cloneRefName(ctx context.Context, url string, refName string, cloneOpts repository.CloneConfig) (*git.Commit, error) {
 	if g.authOpts == nil {
 		return nil, fmt.Errorf("unable to checkout repo with an empty set of auth options")
 	}
-	authMethod, err := transportAuth(g.authOpts, g.useDefaultKnownHosts)
+	authMethod, err := g.transportAuth(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("unable to construct auth method with options: %w", err)
 	}
@@ -450,11 +560,39 @@ func (g *Client) cloneRefName(ctx context.Context, url string, refName string, c
 	return g.cloneCommit(ctx, url, hash.String(), cloneOpts)
 }
 
-func recurseSubmodules(recurse bool) extgogit.SubmoduleRescursivity {
-	if recurse {
-		return extgogit.DefaultSubmoduleRecursionDepth
+// cloneContext clones the repository into the storer and worktree of the
+// client. If the clone fails with an authentication error while using a
+// CredentialsProvider, the repository initialized by the failed attempt is
+// reset and the clone is retried once with refreshed credentials.
+func (g *Client) cloneContext(ctx context.Context, opts *extgogit.CloneOptions) (*extgogit.Repository, error) {
+	var repo *extgogit.Repository
+	var attempted bool
+	err := retryWithFreshCredentials(opts.Auth, func() error {
+		if attempted {
+			if err := g.resetStorer(); err != nil {
+				return err
+			}
+		}
+		attempted = true
+
+		var err error
+		repo, err = extgogit.CloneContext(ctx, g.storer, g.worktreeFS, opts)
+		return err
+	})
+	return repo, err
+}
+
+// resetStorer removes the HEAD reference and the configuration written to
+// the storer by a clone which failed before fetching any objects, so that
+// it can be cloned into again.
+func (g *Client) resetStorer() error {
+	if err := g.storer.RemoveReference(plumbing.HEAD); err != nil {
+		return fmt.Errorf("unable to reset repository: %w", err)
+	}
+	if err := g.storer.SetConfig(config.NewConfig()); err != nil {
+		return fmt.Errorf("unable to reset repository: %w", err)
 	}
-	return extgogit.NoRecurseSubmodules
+	return nil
 }
 
 func (g *Client) getRemoteHEAD(ctx context.Context, url string, ref plumbing.ReferenceName,
@@ -464,6 +602,18 @@ func (g *Client) getRemoteHEAD(ctx context.Context, url string, ref plumbing.Ref
 		return "", fmt.Errorf("ref %s is invalid; Git refs cannot begin or end with a slash '/'", ref.String())
 	}
 
+	refs, err := g.listRemote(ctx, url, authMethod)
+	if err != nil {
+		return "", err
+	}
+
+	head := filterRefs(refs, ref)
+	return head, nil
+}
+
+// listRemote lists the references advertised by the remote at the given url,
+// including the peeled references of annotated tags.
+func (g *Client) listRemote(ctx context.Context, url string, authMethod transport.AuthMethod) ([]*plumbing.Reference, error) {
 	remoteCfg := &config.RemoteConfig{
 		Name: git.DefaultRemote,
 		URLs: []string{url},
@@ -475,13 +625,59 @@ func (g *Client) getRemoteHEAD(ctx context.Context, url string, ref plumbing.Ref
 		PeelingOption: extgogit.AppendPeeled,
 		ProxyOptions:  g.proxy,
 	}
-	refs, err := remote.ListContext(ctx, listOpts)
+	var refs []*plumbing.Reference
+	err := retryWithFreshCredentials(authMethod, func() error {
+		var err error
+		refs, err = remote.ListContext(ctx, listOpts)
+		return err
+	})
 	if err != nil {
-		return "", fmt.Errorf("unable to list remote for '%s': %w", url, err)
+		return nil, fmt.Errorf("unable to list remote for '%s': %w", url, err)
 	}
+	return refs, nil
+}
 
-	head := filterRefs(refs, ref)
-	return head, nil
+// buildReferences converts the provided list of remote refs into a list of
+// git.Reference, sorted by name. Annotated tags are resolved to the commit
+// they point to using their peeled ref, which is not included in the result.
+// Symbolic refs are skipped, as they do not point to a commit directly.
+func buildReferences(refs []*plumbing.Reference, cfg repository.ListReferencesConfig) []git.Reference {
+	peeled := make(map[string]plumbing.Hash)
+	for _, ref := range refs {
+		if name := ref.Name().String(); strings.HasSuffix(name, tagDereferenceSuffix) {
+			peeled[strings.TrimSuffix(name, tagDereferenceSuffix)] = ref.Hash()
+		}
+	}
+
+	var result []git.Reference
+	for _, ref := range refs {
+		if ref.Type() != plumbing.HashReference {
+			continue
+		}
+		name := ref.Name()
+		if strings.HasSuffix(name.String(), tagDereferenceSuffix) {
+			continue
+		}
+		if cfg.Branches || cfg.Tags {
+			if !(cfg.Branches && name.IsBranch()) && !(cfg.Tags && name.IsTag()) {
+				continue
+			}
+		}
+
+		hash := ref.Hash()
+		if h, ok := peeled[name.String()]; ok {
+			hash = h
+		}
+		result = append(result, git.Reference{
+			Name: name.String(),
+			Hash: git.Hash(hash.String()),
+		})
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
 }
 
 // filterRefs searches through the provided list of refs to find a matching ref
@@ -0,0 +1,375 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gogit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/osfs"
+	"github.com/go-git/go-billy/v5/util"
+	extgogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/cache"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/storage"
+	"github.com/go-git/go-git/v5/storage/filesystem"
+	"github.com/go-git/go-git/v5/storage/memory"
+
+	"github.com/fluxcd/pkg/git"
+	"github.com/fluxcd/pkg/git/repository"
+)
+
+// CloneCache holds a bare clone per repository URL on disk, which is
+// updated with fetches and shared by the clients configured with
+// WithCloneCache. The Git objects are stored once in the cache, and the
+// clients only check out the worktree of the requested revision, so that
+// repeated updates of the same repository, across reconciliations and
+// across objects referencing it, do not download it again.
+type CloneCache struct {
+	root string
+
+	mu    sync.Mutex
+	locks map[string]*sync.Mutex
+}
+
+// NewCloneCache returns a new CloneCache storing the clones in the given
+// directory, which is created if it does not exist.
+func NewCloneCache(root string) (*CloneCache, error) {
+	securePath, err := git.SecurePath(root)
+	if err != nil {
+		return nil, fmt.Errorf("invalid path %s: %w", root, err)
+	}
+	if err := os.MkdirAll(securePath, 0o700); err != nil {
+		return nil, fmt.Errorf("unable to create clone cache directory: %w", err)
+	}
+	return &CloneCache{
+		root:  securePath,
+		locks: map[string]*sync.Mutex{},
+	}, nil
+}
+
+// Path returns the directory of the bare clone of the given URL.
+func (c *CloneCache) Path(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return filepath.Join(c.root, hex.EncodeToString(sum[:]))
+}
+
+// Remove removes the bare clone of the given URL from the cache.
+func (c *CloneCache) Remove(url string) error {
+	unlock := c.lock(url)
+	defer unlock()
+	return os.RemoveAll(c.Path(url))
+}
+
+// lock locks the clone of the given URL, and returns the function
+// unlocking it.
+func (c *CloneCache) lock(url string) func() {
+	c.mu.Lock()
+	l, ok := c.locks[url]
+	if !ok {
+		l = &sync.Mutex{}
+		c.locks[url] = l
+	}
+	c.mu.Unlock()
+
+	l.Lock()
+	return l.Unlock
+}
+
+// open opens the bare clone of the given URL, initializing it if it does
+// not exist.
+func (c *CloneCache) open(url string) (*extgogit.Repository, error) {
+	dir := c.Path(url)
+	fs := osfs.New(dir, osfs.WithBoundOS())
+	st := filesystem.NewStorage(fs, cache.NewObjectLRUDefault())
+
+	repo, err := extgogit.Open(st, nil)
+	if errors.Is(err, extgogit.ErrRepositoryNotExists) {
+		repo, err = extgogit.Init(st, nil)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to open clone cache of '%s': %w", url, err)
+	}
+	return repo, nil
+}
+
+// WithCloneCache configures the client to fetch the repositories into the
+// provided cache on Update.
+func WithCloneCache(cache *CloneCache) ClientOption {
+	return func(c *Client) error {
+		c.cloneCache = cache
+		return nil
+	}
+}
+
+// cacheStorer stores the Git objects in the clone cache, and the
+// references, index and configuration of a consumer in memory, so that
+// the consumers of the same clone do not interfere with each other.
+type cacheStorer struct {
+	storer.EncodedObjectStorer
+	storer.ReferenceStorer
+	storer.ShallowStorer
+	storer.IndexStorer
+	config.ConfigStorer
+	storage.ModuleStorer
+}
+
+func newCacheStorer(objects storer.EncodedObjectStorer) *cacheStorer {
+	mem := memory.NewStorage()
+	return &cacheStorer{
+		EncodedObjectStorer: objects,
+		ReferenceStorer:     mem,
+		ShallowStorer:       mem,
+		IndexStorer:         mem,
+		ConfigStorer:        mem,
+		ModuleStorer:        mem,
+	}
+}
+
+// Update fetches the revision of the checkout strategy of the config into
+// the clone cache of the client, and checks it out into the worktree of the
+// client. Unlike Clone, Update can be called repeatedly with the same
+// worktree: the files which are not part of the revision are removed, and
+// only the objects which are missing from the cache are downloaded.
+//
+// The client must be configured with WithCloneCache. Semver checkout
+// strategies, shallow clones and submodules are not supported.
+func (g *Client) Update(ctx context.Context, url string, cfg repository.CloneConfig) (*git.Commit, error) {
+	if g.cloneCache == nil {
+		return nil, errors.New("unable to update repository without a clone cache")
+	}
+	if err := g.validateUrl(url); err != nil {
+		return nil, err
+	}
+	if g.authOpts == nil {
+		return nil, fmt.Errorf("unable to checkout repo with an empty set of auth options")
+	}
+	if cfg.SemVer != "" {
+		return nil, fmt.Errorf("unable to update repository with a semver checkout strategy")
+	}
+	if cfg.RecurseSubmodules || cfg.Submodules != nil {
+		return nil, fmt.Errorf("unable to update repository with submodules")
+	}
+
+	refSpec, ref := updateRefSpec(cfg.CheckoutStrategy)
+
+	unlock := g.cloneCache.lock(url)
+	defer unlock()
+
+	cached, err := g.cloneCache.open(url)
+	if err != nil {
+		return nil, err
+	}
+	if err := g.fetchIntoCache(ctx, cached, url, refSpec); err != nil {
+		return nil, err
+	}
+
+	cc, tagObj, err := resolveUpdateCommit(cached, cfg.CheckoutStrategy, ref)
+	if err != nil {
+		return nil, err
+	}
+	commit, err := buildCommitWithRef(cc, tagObj, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	// check if the revision has changed before checking it out
+	if lastObserved := git.TransformRevision(cfg.LastObservedCommit); lastObserved != "" {
+		if lastObserved == commit.String() || lastObserved == commit.AbsoluteReference() {
+			// Construct a non-concrete commit with the existing information.
+			return &git.Commit{
+				Hash:      commit.Hash,
+				Reference: commit.Reference,
+			}, nil
+		}
+	}
+
+	// Remove the files of the previous revision. The worktree is emptied
+	// before the checkout, rather than cleaned after it, as go-git removes
+	// the root of the worktree when the last file in it is deleted.
+	if err := emptyWorktree(g.worktreeFS); err != nil {
+		return nil, fmt.Errorf("unable to clean repo worktree: %w", err)
+	}
+
+	repo, err := extgogit.Init(newCacheStorer(cached.Storer), g.worktreeFS)
+	if err != nil {
+		return nil, fmt.Errorf("unable to initialize repository: %w", err)
+	}
+	if _, err = repo.CreateRemote(&config.RemoteConfig{
+		Name: git.DefaultRemote,
+		URLs: []string{url},
+	}); err != nil {
+		return nil, err
+	}
+	w, err := repo.Worktree()
+	if err != nil {
+		return nil, fmt.Errorf("unable to open repo worktree: %w", err)
+	}
+
+	checkoutOpts := &extgogit.CheckoutOptions{
+		Hash:  cc.Hash,
+		Force: true,
+	}
+	if ref.IsBranch() {
+		checkoutOpts.Branch = ref
+		checkoutOpts.Create = true
+	}
+	if err = w.Checkout(checkoutOpts); err != nil {
+		return nil, fmt.Errorf("unable to checkout commit '%s': %w", cc.Hash, err)
+	}
+
+	g.repository = repo
+	return commit, nil
+}
+
+// emptyWorktree removes the content of the worktree, except for the Git
+// directory.
+func emptyWorktree(fs billy.Filesystem) error {
+	entries, err := fs.ReadDir(".")
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	for _, e := range entries {
+		if e.Name() == extgogit.GitDirName {
+			continue
+		}
+		if err := util.RemoveAll(fs, e.Name()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// updateRefSpec returns the refspec to fetch into the clone cache for the
+// given checkout strategy, and the reference of the resulting commit.
+func updateRefSpec(strategy repository.CheckoutStrategy) (config.RefSpec, plumbing.ReferenceName) {
+	switch {
+	case strategy.Commit != "":
+		ref := plumbing.ReferenceName(strategy.RefName)
+		if strategy.Branch != "" {
+			branch := plumbing.NewBranchReferenceName(strategy.Branch)
+			if ref == "" {
+				ref = branch
+			}
+			return config.RefSpec(fmt.Sprintf("+%s:%s", branch, branch)), ref
+		}
+		return config.RefSpec("+refs/heads/*:refs/heads/*"), ref
+	case strategy.RefName != "":
+		ref := plumbing.ReferenceName(strings.TrimSuffix(strategy.RefName, tagDereferenceSuffix))
+		return config.RefSpec(fmt.Sprintf("+%s:%s", ref, ref)), plumbing.ReferenceName(strategy.RefName)
+	case strategy.Tag != "":
+		ref := plumbing.NewTagReferenceName(strategy.Tag)
+		return config.RefSpec(fmt.Sprintf("+%s:%s", ref, ref)), ref
+	default:
+		branch := strategy.Branch
+		if branch == "" {
+			branch = git.DefaultBranch
+		}
+		ref := plumbing.NewBranchReferenceName(branch)
+		return config.RefSpec(fmt.Sprintf("+%s:%s", ref, ref)), ref
+	}
+}
+
+// fetchIntoCache fetches the given refspec from the remote at the URL into
+// the cached repository.
+func (g *Client) fetchIntoCache(ctx context.Context, cached *extgogit.Repository, url string, refSpec config.RefSpec) error {
+	authMethod, err := g.transportAuth(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to construct auth method with options: %w", err)
+	}
+
+	remote := extgogit.NewRemote(cached.Storer, &config.RemoteConfig{
+		Name: git.DefaultRemote,
+		URLs: []string{url},
+	})
+	err = retryWithFreshCredentials(authMethod, func() error {
+		return remote.FetchContext(ctx, &extgogit.FetchOptions{
+			RemoteName:   git.DefaultRemote,
+			RefSpecs:     []config.RefSpec{refSpec},
+			Auth:         authMethod,
+			Tags:         extgogit.NoTags,
+			Force:        true,
+			CABundle:     caBundle(g.authOpts),
+			ProxyOptions: g.proxy,
+		})
+	})
+	if err != nil && !errors.Is(err, extgogit.NoErrAlreadyUpToDate) {
+		if errors.Is(err, transport.ErrEmptyRemoteRepository) || errors.Is(err, transport.ErrRepositoryNotFound) ||
+			isRemoteBranchNotFoundErr(err, refSpec.Src()) {
+			return git.ErrRepositoryNotFound{
+				Message: fmt.Sprintf("unable to fetch: %s", err),
+				URL:     url,
+			}
+		}
+		return fmt.Errorf("unable to fetch '%s': %w", url, err)
+	}
+	return nil
+}
+
+// resolveUpdateCommit resolves the commit of the checkout strategy in the
+// cached repository, and the tag object referencing it, if any.
+func resolveUpdateCommit(cached *extgogit.Repository, strategy repository.CheckoutStrategy,
+	ref plumbing.ReferenceName) (*object.Commit, *object.Tag, error) {
+	if strategy.Commit != "" {
+		cc, err := cached.CommitObject(plumbing.NewHash(strategy.Commit))
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to resolve commit object for '%s': %w", strategy.Commit, err)
+		}
+		return cc, nil, nil
+	}
+
+	name := plumbing.ReferenceName(strings.TrimSuffix(ref.String(), tagDereferenceSuffix))
+	r, err := cached.Reference(name, true)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to resolve ref '%s': %w", name, err)
+	}
+
+	var tagObj *object.Tag
+	hash := r.Hash()
+	if name.IsTag() {
+		tagObj, err = cached.TagObject(hash)
+		switch {
+		case err == nil:
+			hash = tagObj.Target
+		case errors.Is(err, plumbing.ErrObjectNotFound):
+			tagObj = nil
+		default:
+			return nil, nil, fmt.Errorf("unable to resolve tag object for '%s' with hash '%s': %w", name, hash, err)
+		}
+	}
+
+	cc, err := cached.CommitObject(hash)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to resolve commit object for '%s': %w", name, err)
+	}
+	return cc, tagObj, nil
+}
@@ -0,0 +1,133 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gogit
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	extgogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	. "github.com/onsi/gomega"
+
+	"github.com/fluxcd/pkg/git"
+	"github.com/fluxcd/pkg/git/repository"
+	"github.com/fluxcd/pkg/gittestserver"
+)
+
+func TestClient_Update(t *testing.T) {
+	g := NewWithT(t)
+
+	server, err := gittestserver.NewTempGitServer()
+	g.Expect(err).ToNot(HaveOccurred())
+	defer os.RemoveAll(server.Root())
+	err = server.StartHTTP()
+	g.Expect(err).ToNot(HaveOccurred())
+	defer server.StopHTTP()
+
+	repoPath := "test.git"
+	err = server.InitRepo("../testdata/git/repo", git.DefaultBranch, repoPath)
+	g.Expect(err).ToNot(HaveOccurred())
+	repoURL := server.HTTPAddress() + "/" + repoPath
+	repo, err := extgogit.PlainClone(t.TempDir(), false, &extgogit.CloneOptions{
+		URL: repoURL,
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+	head, err := repo.Head()
+	g.Expect(err).ToNot(HaveOccurred())
+
+	cache, err := NewCloneCache(t.TempDir())
+	g.Expect(err).ToNot(HaveOccurred())
+
+	tmpDir := t.TempDir()
+	newClient := func() *Client {
+		ggc, err := NewClient(tmpDir, &git.AuthOptions{Transport: git.HTTP}, WithDiskStorage(), WithCloneCache(cache))
+		g.Expect(err).ToNot(HaveOccurred())
+		return ggc
+	}
+	cfg := repository.CloneConfig{
+		CheckoutStrategy: repository.CheckoutStrategy{Branch: git.DefaultBranch},
+	}
+
+	cc, err := newClient().Update(context.TODO(), repoURL, cfg)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(cc.String()).To(Equal(git.DefaultBranch + "@" + git.HashTypeSHA1 + ":" + head.Hash().String()))
+	g.Expect(git.IsConcreteCommit(*cc)).To(BeTrue())
+	g.Expect(filepath.Join(tmpDir, "foo.txt")).To(BeAnExistingFile())
+	g.Expect(filepath.Join(cache.Path(repoURL), "objects")).To(BeADirectory())
+
+	// An unchanged revision is not checked out again.
+	cfg.LastObservedCommit = cc.String()
+	cc, err = newClient().Update(context.TODO(), repoURL, cfg)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(git.IsConcreteCommit(*cc)).To(BeFalse())
+
+	// Push a commit removing a file, and tag it.
+	w, err := repo.Worktree()
+	g.Expect(err).ToNot(HaveOccurred())
+	_, err = w.Remove("foo.txt")
+	g.Expect(err).ToNot(HaveOccurred())
+	hash, err := commitFile(repo, "bar.txt", "this is the way", time.Now())
+	g.Expect(err).ToNot(HaveOccurred())
+	_, err = tag(repo, hash, true, "v0.1.0", time.Now())
+	g.Expect(err).ToNot(HaveOccurred())
+	err = repo.Push(&extgogit.PushOptions{
+		RefSpecs: []config.RefSpec{
+			config.RefSpec("+refs/heads/" + git.DefaultBranch + ":refs/heads/" + git.DefaultBranch),
+			config.RefSpec("+refs/tags/v0.1.0:refs/tags/v0.1.0"),
+		},
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+
+	cc, err = newClient().Update(context.TODO(), repoURL, cfg)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(cc.Hash.String()).To(Equal(hash.String()))
+	g.Expect(git.IsConcreteCommit(*cc)).To(BeTrue())
+	g.Expect(filepath.Join(tmpDir, "bar.txt")).To(BeAnExistingFile())
+	g.Expect(filepath.Join(tmpDir, "foo.txt")).ToNot(BeAnExistingFile())
+
+	// Check out the previous commit, and then the tag.
+	cc, err = newClient().Update(context.TODO(), repoURL, repository.CloneConfig{
+		CheckoutStrategy: repository.CheckoutStrategy{Branch: git.DefaultBranch, Commit: head.Hash().String()},
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(cc.Hash.String()).To(Equal(head.Hash().String()))
+	g.Expect(filepath.Join(tmpDir, "foo.txt")).To(BeAnExistingFile())
+	g.Expect(filepath.Join(tmpDir, "bar.txt")).ToNot(BeAnExistingFile())
+
+	cc, err = newClient().Update(context.TODO(), repoURL, repository.CloneConfig{
+		CheckoutStrategy: repository.CheckoutStrategy{Tag: "v0.1.0"},
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(cc.Hash.String()).To(Equal(hash.String()))
+	g.Expect(cc.ReferencingTag).ToNot(BeNil())
+	g.Expect(git.IsAnnotatedTag(*cc.ReferencingTag)).To(BeTrue())
+	g.Expect(filepath.Join(tmpDir, "bar.txt")).To(BeAnExistingFile())
+
+	_, err = newClient().Update(context.TODO(), repoURL, repository.CloneConfig{
+		CheckoutStrategy: repository.CheckoutStrategy{Branch: "missing"},
+	})
+	g.Expect(err).To(HaveOccurred())
+
+	ggc, err := NewClient(t.TempDir(), &git.AuthOptions{Transport: git.HTTP})
+	g.Expect(err).ToNot(HaveOccurred())
+	_, err = ggc.Update(context.TODO(), repoURL, cfg)
+	g.Expect(err).To(MatchError(ContainSubstring("without a clone cache")))
+}
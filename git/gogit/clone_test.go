@@ -437,24 +437,72 @@ func TestClone_cloneSemVer(t *testing.T) {
 			commitTime: now,
 			tagTime:    now,
 		},
+		{
+			tag:        "v0.3.0-rc.1",
+			annotated:  false,
+			commitTime: now,
+		},
 	}
 	tests := []struct {
-		name       string
-		constraint string
-		annotated  bool
-		expectErr  error
-		expectTag  string
+		name             string
+		constraint       string
+		filter           *repository.SemVerFilter
+		annotated        bool
+		expectErr        error
+		expectTag        string
+		expectCandidates []string
 	}{
 		{
-			name:       "Orders by SemVer",
-			constraint: ">0.1.0",
-			expectTag:  "0.2.0",
-			annotated:  true,
+			name:             "Orders by SemVer",
+			constraint:       ">0.1.0",
+			expectTag:        "0.2.0",
+			annotated:        true,
+			expectCandidates: []string{"0.2.0"},
+		},
+		{
+			name:             "Orders by SemVer and timestamp",
+			constraint:       "<0.2.0",
+			expectTag:        "v0.1.0+build-3",
+			expectCandidates: []string{"v0.1.0+build-3", "v0.1.0+build-2", "v0.1.0+build-1", "v0.0.1"},
 		},
 		{
-			name:       "Orders by SemVer and timestamp",
+			name:       "Orders by SemVer and tag preference",
 			constraint: "<0.2.0",
-			expectTag:  "v0.1.0+build-3",
+			filter: &repository.SemVerFilter{
+				TagPreference: repository.TagPreferenceAnnotated,
+			},
+			expectTag:        "v0.1.0+build-1",
+			annotated:        true,
+			expectCandidates: []string{"v0.1.0+build-1", "v0.1.0+build-3", "v0.1.0+build-2", "v0.0.1"},
+		},
+		{
+			name:       "Filters by include expression",
+			constraint: ">=0.0.1",
+			filter: &repository.SemVerFilter{
+				Include: "^v",
+			},
+			expectTag:        "v0.1.0+build-3",
+			expectCandidates: []string{"v0.1.0+build-3", "v0.1.0+build-2", "v0.1.0+build-1", "v0.0.1"},
+		},
+		{
+			name:       "Filters by exclude expression",
+			constraint: ">=0.0.1",
+			filter: &repository.SemVerFilter{
+				Include: "^v",
+				Exclude: "build-[23]$",
+			},
+			expectTag:        "v0.1.0+build-1",
+			annotated:        true,
+			expectCandidates: []string{"v0.1.0+build-1", "v0.0.1"},
+		},
+		{
+			name:       "Includes prereleases",
+			constraint: ">=0.2.0",
+			filter: &repository.SemVerFilter{
+				IncludePrereleases: true,
+			},
+			expectTag:        "v0.3.0-rc.1",
+			expectCandidates: []string{"v0.3.0-rc.1", "0.2.0"},
 		},
 		{
 			name:       "Errors without match",
@@ -491,7 +539,8 @@ func TestClone_cloneSemVer(t *testing.T) {
 
 			opts := repository.CloneConfig{
 				CheckoutStrategy: repository.CheckoutStrategy{
-					SemVer: tt.constraint,
+					SemVer:       tt.constraint,
+					SemVerFilter: tt.filter,
 				},
 				ShallowClone: true,
 			}
@@ -507,6 +556,7 @@ func TestClone_cloneSemVer(t *testing.T) {
 			g.Expect(cc.String()).To(Equal(tt.expectTag + "@" + git.HashTypeSHA1 + ":" + refs[tt.expectTag]))
 			g.Expect(filepath.Join(tmpDir, "tag")).To(BeARegularFile())
 			g.Expect(os.ReadFile(filepath.Join(tmpDir, "tag"))).To(BeEquivalentTo(tt.expectTag))
+			g.Expect(cc.SemVerCandidates).To(Equal(tt.expectCandidates))
 			g.Expect(cc.ReferencingTag).ToNot(BeNil())
 			if tt.annotated {
 				g.Expect(git.IsAnnotatedTag(*cc.ReferencingTag)).To(BeTrue())
@@ -1326,6 +1376,61 @@ func Test_filterRefs(t *testing.T) {
 	}
 }
 
+func Test_buildReferences(t *testing.T) {
+	commitHash := "84d9be20ca15d29bebc629e5b6f29dab78cc69ba"
+	tagObjectHash := "9000be6daa3323cb7009075259bb7bd62498d32f"
+	refs := []*plumbing.Reference{
+		plumbing.NewSymbolicReference(plumbing.HEAD, "refs/heads/main"),
+		plumbing.NewReferenceFromStrings("refs/tags/v1.0.0", tagObjectHash),
+		plumbing.NewReferenceFromStrings("refs/tags/v1.0.0"+tagDereferenceSuffix, commitHash),
+		plumbing.NewReferenceFromStrings("refs/heads/main", commitHash),
+		plumbing.NewReferenceFromStrings("refs/pull/1/head", commitHash),
+		plumbing.NewReferenceFromStrings("refs/tags/v0.1.0", commitHash),
+	}
+
+	tests := []struct {
+		name string
+		cfg  repository.ListReferencesConfig
+		want []string
+	}{
+		{
+			name: "all references",
+			cfg:  repository.ListReferencesConfig{},
+			want: []string{"refs/heads/main", "refs/pull/1/head", "refs/tags/v0.1.0", "refs/tags/v1.0.0"},
+		},
+		{
+			name: "branches only",
+			cfg:  repository.ListReferencesConfig{Branches: true},
+			want: []string{"refs/heads/main"},
+		},
+		{
+			name: "tags only",
+			cfg:  repository.ListReferencesConfig{Tags: true},
+			want: []string{"refs/tags/v0.1.0", "refs/tags/v1.0.0"},
+		},
+		{
+			name: "branches and tags",
+			cfg:  repository.ListReferencesConfig{Branches: true, Tags: true},
+			want: []string{"refs/heads/main", "refs/tags/v0.1.0", "refs/tags/v1.0.0"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			got := buildReferences(refs, tt.cfg)
+			var names []string
+			for _, ref := range got {
+				// Annotated tags must be resolved to the commit they point to.
+				g.Expect(ref.Hash.String()).To(Equal(commitHash))
+				names = append(names, ref.Name)
+			}
+			g.Expect(names).To(Equal(tt.want))
+		})
+	}
+}
+
 func TestClone_CredentialsOverHttp(t *testing.T) {
 	tests := []struct {
 		name                     string
@@ -0,0 +1,217 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gogit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	nethttp "net/http"
+	"sync"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	gossh "golang.org/x/crypto/ssh"
+
+	"github.com/fluxcd/pkg/git"
+)
+
+// CredentialsProvider provides the credentials used by the Client to
+// authenticate against the remote, for credentials which expire during
+// long-running operations, e.g. GitHub App installation tokens or tokens
+// exchanged through cloud OIDC providers.
+//
+// The provider is consulted every time the transport opens a new HTTP
+// request or SSH connection, implementations are expected to cache the
+// credentials until they are about to expire.
+type CredentialsProvider interface {
+	// Credentials returns the auth options for the remote. If refresh is
+	// true, the previously returned credentials have been rejected by the
+	// remote and must not be served from the cache.
+	Credentials(ctx context.Context, refresh bool) (*git.AuthOptions, error)
+}
+
+// CredentialsProviderFunc is an adapter to allow the use of ordinary
+// functions as a CredentialsProvider.
+type CredentialsProviderFunc func(ctx context.Context, refresh bool) (*git.AuthOptions, error)
+
+// Credentials calls f(ctx, refresh).
+func (f CredentialsProviderFunc) Credentials(ctx context.Context, refresh bool) (*git.AuthOptions, error) {
+	return f(ctx, refresh)
+}
+
+// WithCredentialsProvider configures the client to request the credentials
+// from the given provider during remote operations, instead of using the
+// static credentials of its git.AuthOptions. The transport of the
+// git.AuthOptions given to NewClient still determines the auth method.
+// Operations which fail with an authentication error are retried once
+// with refreshed credentials, where it is safe to do so.
+func WithCredentialsProvider(provider CredentialsProvider) ClientOption {
+	return func(c *Client) error {
+		c.credentialsProvider = provider
+		return nil
+	}
+}
+
+// credentialsAuth is a transport.AuthMethod which requests the credentials
+// from a CredentialsProvider every time they are used.
+type credentialsAuth struct {
+	ctx                         context.Context
+	provider                    CredentialsProvider
+	fallbackToDefaultKnownHosts bool
+
+	mu      sync.Mutex
+	refresh bool
+	err     error
+}
+
+// httpCredentialsAuth implements http.AuthMethod.
+type httpCredentialsAuth struct {
+	*credentialsAuth
+}
+
+// sshCredentialsAuth implements ssh.AuthMethod.
+type sshCredentialsAuth struct {
+	*credentialsAuth
+}
+
+// newCredentialsAuth returns the transport.AuthMethod for the given transport
+// type which requests the credentials from the given provider.
+func newCredentialsAuth(ctx context.Context, transportType git.TransportType, provider CredentialsProvider,
+	fallbackToDefaultKnownHosts bool) (transport.AuthMethod, error) {
+	a := &credentialsAuth{
+		ctx:                         ctx,
+		provider:                    provider,
+		fallbackToDefaultKnownHosts: fallbackToDefaultKnownHosts,
+	}
+	switch transportType {
+	case git.HTTPS, git.HTTP:
+		return &httpCredentialsAuth{a}, nil
+	case git.SSH:
+		return &sshCredentialsAuth{a}, nil
+	case "":
+		return nil, fmt.Errorf("no transport type set")
+	default:
+		return nil, fmt.Errorf("unknown transport '%s'", transportType)
+	}
+}
+
+func (a *credentialsAuth) Name() string {
+	return "credentials-provider"
+}
+
+func (a *credentialsAuth) String() string {
+	return a.Name()
+}
+
+// invalidate makes the next request for credentials bypass the cache of
+// the provider.
+func (a *credentialsAuth) invalidate() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.refresh = true
+}
+
+// lastErr returns the last error returned by the provider, if any.
+func (a *credentialsAuth) lastErr() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.err
+}
+
+// authMethod requests the credentials from the provider and constructs
+// the transport.AuthMethod for them.
+func (a *credentialsAuth) authMethod() (transport.AuthMethod, error) {
+	a.mu.Lock()
+	refresh := a.refresh
+	a.refresh = false
+	a.mu.Unlock()
+
+	var authMethod transport.AuthMethod
+	opts, err := a.provider.Credentials(a.ctx, refresh)
+	if err == nil {
+		authMethod, err = transportAuth(opts, a.fallbackToDefaultKnownHosts)
+	}
+	if err != nil {
+		err = fmt.Errorf("unable to get credentials from provider: %w", err)
+	}
+
+	a.mu.Lock()
+	a.err = err
+	a.mu.Unlock()
+	return authMethod, err
+}
+
+// SetAuth sets the credentials of the provider on the given request. Errors
+// of the provider leave the request unauthenticated, which results in an
+// authentication error returned by the transport.
+func (a *httpCredentialsAuth) SetAuth(r *nethttp.Request) {
+	authMethod, err := a.authMethod()
+	if err != nil {
+		return
+	}
+	if httpAuth, ok := authMethod.(http.AuthMethod); ok {
+		httpAuth.SetAuth(r)
+	}
+}
+
+// ClientConfig returns the SSH client config for the credentials of the provider.
+func (a *sshCredentialsAuth) ClientConfig() (*gossh.ClientConfig, error) {
+	authMethod, err := a.authMethod()
+	if err != nil {
+		return nil, err
+	}
+	sshAuth, ok := authMethod.(ssh.AuthMethod)
+	if !ok {
+		return nil, errors.New("credentials provider returned no SSH credentials")
+	}
+	return sshAuth.ClientConfig()
+}
+
+// isAuthErr returns true if the given error is an authentication or
+// authorization error returned by the transport.
+func isAuthErr(err error) bool {
+	return errors.Is(err, transport.ErrAuthenticationRequired) ||
+		errors.Is(err, transport.ErrAuthorizationFailed)
+}
+
+// retryWithFreshCredentials runs op, and if it fails with an authentication
+// error while using a CredentialsProvider, runs it once more with refreshed
+// credentials.
+func retryWithFreshCredentials(authMethod transport.AuthMethod, op func() error) error {
+	err := op()
+	if err == nil || !isAuthErr(err) {
+		return err
+	}
+
+	var a *credentialsAuth
+	switch v := authMethod.(type) {
+	case *httpCredentialsAuth:
+		a = v.credentialsAuth
+	case *sshCredentialsAuth:
+		a = v.credentialsAuth
+	default:
+		return err
+	}
+	if providerErr := a.lastErr(); providerErr != nil {
+		return fmt.Errorf("%w: %w", err, providerErr)
+	}
+
+	a.invalidate()
+	return op()
+}
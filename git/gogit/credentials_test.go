@@ -0,0 +1,176 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gogit
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/fluxcd/pkg/git"
+	"github.com/fluxcd/pkg/git/repository"
+)
+
+func TestWithCredentialsProvider(t *testing.T) {
+	g := NewWithT(t)
+
+	server, _, err := setupGitServer(true)
+	g.Expect(err).ToNot(HaveOccurred())
+	defer server.StopHTTP()
+	repoURL := server.HTTPAddress() + "/test.git"
+
+	// The provider caches the password until asked to refresh it.
+	var calls, refreshes int
+	password := "expired"
+	provider := CredentialsProviderFunc(func(ctx context.Context, refresh bool) (*git.AuthOptions, error) {
+		calls++
+		if refresh {
+			refreshes++
+			password = "test-pass"
+		}
+		return &git.AuthOptions{
+			Transport: git.HTTP,
+			Username:  "test-user",
+			Password:  password,
+		}, nil
+	})
+
+	ggc, err := NewClient(t.TempDir(), &git.AuthOptions{Transport: git.HTTP},
+		WithDiskStorage(), WithInsecureCredentialsOverHTTP(), WithCredentialsProvider(provider))
+	g.Expect(err).ToNot(HaveOccurred())
+
+	// The expired credentials are rejected and refreshed once.
+	refs, err := ggc.ListReferences(context.TODO(), repoURL, repository.ListReferencesConfig{Branches: true})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(refs).ToNot(BeEmpty())
+	g.Expect(refreshes).To(Equal(1))
+	g.Expect(calls).To(BeNumerically(">=", 2))
+
+	// The credentials are requested for every new request.
+	calls = 0
+	cc, err := ggc.Clone(context.TODO(), repoURL, repository.CloneConfig{
+		CheckoutStrategy: repository.CheckoutStrategy{Branch: git.DefaultBranch},
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(cc).ToNot(BeNil())
+	g.Expect(calls).To(BeNumerically(">=", 2))
+	g.Expect(refreshes).To(Equal(1))
+}
+
+func TestClone_withExpiredCredentials(t *testing.T) {
+	server, _, err := setupGitServer(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.StopHTTP()
+	repoURL := server.HTTPAddress() + "/test.git"
+
+	lister, err := NewClient(t.TempDir(), &git.AuthOptions{Transport: git.HTTP, Username: "test-user", Password: "test-pass"},
+		WithMemoryStorage(), WithInsecureCredentialsOverHTTP())
+	if err != nil {
+		t.Fatal(err)
+	}
+	refs, err := lister.ListReferences(context.TODO(), repoURL, repository.ListReferencesConfig{Branches: true})
+	if err != nil || len(refs) == 0 {
+		t.Fatalf("unable to list references: %v", err)
+	}
+	head := refs[0].Hash.String()
+
+	tests := []struct {
+		name     string
+		storage  ClientOption
+		checkout func(head string) repository.CheckoutStrategy
+		observed bool
+	}{
+		{
+			name:    "branch on disk",
+			storage: WithDiskStorage(),
+			checkout: func(string) repository.CheckoutStrategy {
+				return repository.CheckoutStrategy{Branch: git.DefaultBranch}
+			},
+		},
+		{
+			name:    "branch in memory",
+			storage: WithMemoryStorage(),
+			checkout: func(string) repository.CheckoutStrategy {
+				return repository.CheckoutStrategy{Branch: git.DefaultBranch}
+			},
+		},
+		{
+			name:    "commit",
+			storage: WithDiskStorage(),
+			checkout: func(head string) repository.CheckoutStrategy {
+				return repository.CheckoutStrategy{Commit: head}
+			},
+		},
+		{
+			name:    "last observed commit",
+			storage: WithDiskStorage(),
+			checkout: func(string) repository.CheckoutStrategy {
+				return repository.CheckoutStrategy{Branch: git.DefaultBranch}
+			},
+			observed: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			var refreshes int
+			password := "expired"
+			provider := CredentialsProviderFunc(func(ctx context.Context, refresh bool) (*git.AuthOptions, error) {
+				if refresh {
+					refreshes++
+					password = "test-pass"
+				}
+				return &git.AuthOptions{
+					Transport: git.HTTP,
+					Username:  "test-user",
+					Password:  password,
+				}, nil
+			})
+
+			ggc, err := NewClient(t.TempDir(), &git.AuthOptions{Transport: git.HTTP},
+				tt.storage, WithInsecureCredentialsOverHTTP(), WithCredentialsProvider(provider))
+			g.Expect(err).ToNot(HaveOccurred())
+
+			cfg := repository.CloneConfig{CheckoutStrategy: tt.checkout(head)}
+			if tt.observed {
+				cfg.LastObservedCommit = git.DefaultBranch + "@sha1:" + head
+			}
+			cc, err := ggc.Clone(context.TODO(), repoURL, cfg)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(cc.Hash.String()).To(Equal(head))
+			g.Expect(refreshes).To(Equal(1))
+		})
+	}
+}
+
+func TestValidateUrl_credentialsProvider(t *testing.T) {
+	g := NewWithT(t)
+
+	provider := CredentialsProviderFunc(func(ctx context.Context, refresh bool) (*git.AuthOptions, error) {
+		return &git.AuthOptions{Transport: git.HTTP, BearerToken: "token"}, nil
+	})
+
+	ggc, err := NewClient(t.TempDir(), &git.AuthOptions{Transport: git.HTTP}, WithDiskStorage(), WithCredentialsProvider(provider))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(ggc.validateUrl("http://example.com/repo.git")).To(MatchError("credentials provider cannot be used over HTTP"))
+	g.Expect(ggc.validateUrl("https://example.com/repo.git")).To(Succeed())
+}
@@ -0,0 +1,250 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gogit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	extgogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/utils/merkletrie"
+
+	"github.com/fluxcd/pkg/git"
+	"github.com/fluxcd/pkg/git/repository"
+)
+
+// isRemoteRefMoved returns true if the given push error indicates that the
+// remote branch has been updated since it was fetched, either detected
+// locally or reported by the server as a non-fast-forward update.
+func isRemoteRefMoved(err error) bool {
+	if errors.Is(err, extgogit.ErrNonFastForwardUpdate) {
+		return true
+	}
+	return nonFastForwardRegexp.MatchString(err.Error())
+}
+
+// nonFastForwardRegexp matches the non-fast-forward errors which go-git
+// only returns as messages: 'non-fast-forward update: <ref>' when detected
+// locally, and 'command error on <ref>: non-fast-forward' when reported by
+// the server.
+var nonFastForwardRegexp = regexp.MustCompile(`(^|: )non-fast-forward update: refs/\S+$|(^|: )command error on refs/\S+: non-fast-forward$`)
+
+// replayOnRemote fetches the remote branch of the given push refspec and
+// replays the local commits which are not on the remote branch on top of it,
+// updating the local branch. With the PushRetryRebase strategy, it fails if
+// the local commits change files which have been changed on the remote.
+// Signed commits are signed again with the signer, and can not be replayed
+// without one.
+func (g *Client) replayOnRemote(ctx context.Context, authMethod transport.AuthMethod,
+	spec config.RefSpec, strategy repository.PushRetryStrategy, signer *openpgp.Entity) error {
+	localName := plumbing.ReferenceName(spec.Src())
+	remoteName := spec.Dst(localName)
+	if !localName.IsBranch() || !remoteName.IsBranch() {
+		return fmt.Errorf("unable to retry push of '%s': only branches are supported", spec)
+	}
+	trackingName := plumbing.NewRemoteReferenceName(extgogit.DefaultRemoteName, remoteName.Short())
+
+	err := retryWithFreshCredentials(authMethod, func() error {
+		return g.repository.FetchContext(ctx, &extgogit.FetchOptions{
+			RemoteName:   extgogit.DefaultRemoteName,
+			RefSpecs:     []config.RefSpec{config.RefSpec(fmt.Sprintf("+%s:%s", remoteName, trackingName))},
+			Auth:         authMethod,
+			CABundle:     caBundle(g.authOpts),
+			ProxyOptions: g.proxy,
+		})
+	})
+	if err != nil && !errors.Is(err, extgogit.NoErrAlreadyUpToDate) {
+		return fmt.Errorf("unable to fetch '%s': %w", remoteName, err)
+	}
+
+	localRef, err := g.repository.Reference(localName, true)
+	if err != nil {
+		return err
+	}
+	remoteRef, err := g.repository.Reference(trackingName, true)
+	if err != nil {
+		return err
+	}
+	local, err := g.repository.CommitObject(localRef.Hash())
+	if err != nil {
+		return err
+	}
+	remote, err := g.repository.CommitObject(remoteRef.Hash())
+	if err != nil {
+		return err
+	}
+
+	bases, err := local.MergeBase(remote)
+	if err != nil {
+		return fmt.Errorf("unable to find common ancestor with '%s': %w", remoteName, err)
+	}
+	if len(bases) == 0 {
+		return fmt.Errorf("%w: no common ancestor with '%s'", git.ErrRebaseConflict, remoteName)
+	}
+	base := bases[0]
+	if base.Hash == remote.Hash {
+		// The local branch already contains the remote commits.
+		return nil
+	}
+
+	// Collect the local commits, from the most recent one.
+	var commits []*object.Commit
+	for c := local; c.Hash != base.Hash; {
+		if c.NumParents() != 1 {
+			return fmt.Errorf("unable to replay commit '%s': merge commits are not supported", c.Hash)
+		}
+		if c.PGPSignature != "" && signer == nil {
+			return fmt.Errorf("unable to replay commit '%s': signed commits require a signer", c.Hash)
+		}
+		commits = append(commits, c)
+		if c, err = c.Parent(0); err != nil {
+			return err
+		}
+	}
+
+	changedOnRemote, err := changedPaths(base, remote)
+	if err != nil {
+		return err
+	}
+
+	parent := remote
+	for i := len(commits) - 1; i >= 0; i-- {
+		c := commits[i]
+		changes, err := commitChanges(c)
+		if err != nil {
+			return err
+		}
+		if strategy == repository.PushRetryRebase {
+			for p := range changes {
+				if changedOnRemote[p] {
+					return fmt.Errorf("%w: '%s' changed by commit '%s'", git.ErrRebaseConflict, p, c.Hash)
+				}
+			}
+		}
+
+		parentTree, err := parent.Tree()
+		if err != nil {
+			return err
+		}
+		treeHash, err := writeTreeChanges(g.repository.Storer, parentTree, changes)
+		if err != nil {
+			return err
+		}
+
+		// Signed commits are signed again by the signer, as their signature
+		// doesn't cover the replayed content.
+		var key *openpgp.Entity
+		if c.PGPSignature != "" {
+			key = signer
+		}
+		hash, err := writeCommit(g.repository.Storer, treeHash, []plumbing.Hash{parent.Hash}, c.Message, c.Author, c.Committer, key)
+		if err != nil {
+			return fmt.Errorf("unable to replay commit '%s': %w", c.Hash, err)
+		}
+		if parent, err = g.repository.CommitObject(hash); err != nil {
+			return err
+		}
+	}
+
+	if err := g.repository.Storer.CheckAndSetReference(plumbing.NewHashReference(localName, parent.Hash), localRef); err != nil {
+		return fmt.Errorf("unable to update branch '%s': %w", localName.Short(), err)
+	}
+
+	// Keep the worktree in sync when the replayed branch is checked out.
+	if head, err := g.repository.Head(); err == nil && head.Name() == localName {
+		wt, err := g.repository.Worktree()
+		if err != nil {
+			return err
+		}
+		if err := wt.Reset(&extgogit.ResetOptions{Commit: parent.Hash, Mode: extgogit.HardReset}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// changedPaths returns the paths of the files which differ between the
+// trees of the given commits.
+func changedPaths(from, to *object.Commit) (map[string]bool, error) {
+	fromTree, err := from.Tree()
+	if err != nil {
+		return nil, err
+	}
+	toTree, err := to.Tree()
+	if err != nil {
+		return nil, err
+	}
+	changes, err := object.DiffTree(fromTree, toTree)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := make(map[string]bool, len(changes))
+	for _, change := range changes {
+		if change.From.Name != "" {
+			paths[change.From.Name] = true
+		}
+		if change.To.Name != "" {
+			paths[change.To.Name] = true
+		}
+	}
+	return paths, nil
+}
+
+// commitChanges returns the changes to the tree entries introduced by the
+// given commit compared to its first parent, keyed by path. The entries are
+// kept as is, including their mode, and gitlinks of submodules.
+func commitChanges(c *object.Commit) (map[string]treeChange, error) {
+	parent, err := c.Parent(0)
+	if err != nil {
+		return nil, err
+	}
+	parentTree, err := parent.Tree()
+	if err != nil {
+		return nil, err
+	}
+	tree, err := c.Tree()
+	if err != nil {
+		return nil, err
+	}
+	diff, err := object.DiffTree(parentTree, tree)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := make(map[string]treeChange, len(diff))
+	for _, change := range diff {
+		action, err := change.Action()
+		if err != nil {
+			return nil, err
+		}
+		if action == merkletrie.Delete {
+			changes[change.From.Name] = treeChange{delete: true}
+			continue
+		}
+		e := change.To.TreeEntry
+		changes[change.To.Name] = treeChange{mode: e.Mode, hash: e.Hash}
+	}
+	return changes, nil
+}
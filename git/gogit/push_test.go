@@ -0,0 +1,196 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gogit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	extgogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	. "github.com/onsi/gomega"
+
+	"github.com/fluxcd/pkg/git/repository"
+)
+
+func Test_isRemoteRefMoved(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{
+			name: "local non-fast-forward",
+			err:  fmt.Errorf("push failed: %w", extgogit.ErrNonFastForwardUpdate),
+			want: true,
+		},
+		{
+			name: "local non-fast-forward message",
+			err:  fmt.Errorf("failed to push to remote: %w", errors.New("non-fast-forward update: refs/heads/main")),
+			want: true,
+		},
+		{
+			name: "remote non-fast-forward",
+			err:  errors.New("command error on refs/heads/main: non-fast-forward"),
+			want: true,
+		},
+		{
+			name: "remote lock failure",
+			err:  errors.New("command error on refs/heads/main: cannot lock ref 'refs/heads/main'"),
+		},
+		{
+			name: "hook rejection mentioning non-fast-forward",
+			err:  errors.New("command error on refs/heads/main: pre-receive hook declined: non-fast-forward pushes are forbidden"),
+		},
+		{
+			name: "unrelated error",
+			err:  errors.New("authentication required"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			g.Expect(isRemoteRefMoved(tt.err)).To(Equal(tt.want))
+		})
+	}
+}
+
+func Test_replayOnRemote(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.TODO()
+
+	remoteDir := t.TempDir()
+	remoteRepo, err := extgogit.PlainInit(remoteDir, true)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	localDir := t.TempDir()
+	localRepo, err := extgogit.PlainInit(localDir, false)
+	g.Expect(err).ToNot(HaveOccurred())
+	_, err = localRepo.CreateRemote(&config.RemoteConfig{Name: extgogit.DefaultRemoteName, URLs: []string{remoteDir}})
+	g.Expect(err).ToNot(HaveOccurred())
+
+	branch := plumbing.NewBranchReferenceName("master")
+	spec := config.RefSpec(fmt.Sprintf("%s:%[1]s", branch))
+
+	blob := func(repo *extgogit.Repository, content string) plumbing.Hash {
+		hash, err := writeBlob(repo.Storer, []byte(content))
+		g.Expect(err).ToNot(HaveOccurred())
+		return hash
+	}
+
+	base := writeTestCommit(g, localRepo, plumbing.ZeroHash, map[string]treeChange{
+		"README.md": {hash: blob(localRepo, "base")},
+	}, nil)
+	g.Expect(localRepo.Storer.SetReference(plumbing.NewHashReference(branch, base))).To(Succeed())
+	g.Expect(localRepo.Push(&extgogit.PushOptions{RefSpecs: []config.RefSpec{spec}})).To(Succeed())
+
+	// Update the remote branch.
+	remote := writeTestCommit(g, remoteRepo, base, map[string]treeChange{
+		"remote.txt": {hash: blob(remoteRepo, "remote")},
+	}, nil)
+	g.Expect(remoteRepo.Storer.SetReference(plumbing.NewHashReference(branch, remote))).To(Succeed())
+
+	signer, err := openpgp.NewEntity("Jane Doe", "", "jane@example.com", nil)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	submodule := plumbing.NewHash("3f4e5a1b2c3d4e5f60718293a4b5c6d7e8f90123")
+	local := writeTestCommit(g, localRepo, base, map[string]treeChange{
+		"bin/run.sh": {mode: filemode.Executable, hash: blob(localRepo, "#!/bin/sh")},
+		"link":       {mode: filemode.Symlink, hash: blob(localRepo, "README.md")},
+		"sub":        {mode: filemode.Submodule, hash: submodule},
+	}, signer)
+	g.Expect(localRepo.Storer.SetReference(plumbing.NewHashReference(branch, local))).To(Succeed())
+
+	err = localRepo.Push(&extgogit.PushOptions{RefSpecs: []config.RefSpec{spec}})
+	g.Expect(isRemoteRefMoved(err)).To(BeTrue())
+
+	ggc, err := NewClient(localDir, nil)
+	g.Expect(err).ToNot(HaveOccurred())
+	ggc.repository = localRepo
+
+	// Signed commits are not replayed without a signer.
+	err = ggc.replayOnRemote(ctx, nil, spec, repository.PushRetryRebase, nil)
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.Error()).To(ContainSubstring("signed commits require a signer"))
+
+	g.Expect(ggc.replayOnRemote(ctx, nil, spec, repository.PushRetryRebase, signer)).To(Succeed())
+
+	ref, err := localRepo.Reference(branch, true)
+	g.Expect(err).ToNot(HaveOccurred())
+	replayed, err := localRepo.CommitObject(ref.Hash())
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(replayed.ParentHashes).To(Equal([]plumbing.Hash{remote}))
+
+	// The replayed commit is signed again by the signer.
+	encoded := &plumbing.MemoryObject{}
+	g.Expect(replayed.EncodeWithoutSignature(encoded)).To(Succeed())
+	r, err := encoded.Reader()
+	g.Expect(err).ToNot(HaveOccurred())
+	_, err = openpgp.CheckArmoredDetachedSignature(openpgp.EntityList{signer}, r, strings.NewReader(replayed.PGPSignature), nil)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	// The modes of the entries, and gitlinks, are kept.
+	tree, err := replayed.Tree()
+	g.Expect(err).ToNot(HaveOccurred())
+	for path, want := range map[string]object.TreeEntry{
+		"README.md":  {Mode: filemode.Regular},
+		"remote.txt": {Mode: filemode.Regular},
+		"bin/run.sh": {Mode: filemode.Executable},
+		"link":       {Mode: filemode.Symlink},
+		"sub":        {Mode: filemode.Submodule, Hash: submodule},
+	} {
+		e, err := tree.FindEntry(path)
+		g.Expect(err).ToNot(HaveOccurred(), path)
+		g.Expect(e.Mode).To(Equal(want.Mode), path)
+		if !want.Hash.IsZero() {
+			g.Expect(e.Hash).To(Equal(want.Hash), path)
+		}
+	}
+
+	g.Expect(localRepo.Push(&extgogit.PushOptions{RefSpecs: []config.RefSpec{spec}})).To(Succeed())
+}
+
+// writeTestCommit writes a commit applying the changes to the tree of the
+// parent, if not zero, and optionally signs it.
+func writeTestCommit(g *WithT, repo *extgogit.Repository, parent plumbing.Hash,
+	changes map[string]treeChange, signer *openpgp.Entity) plumbing.Hash {
+	var base *object.Tree
+	var parents []plumbing.Hash
+	if !parent.IsZero() {
+		c, err := repo.CommitObject(parent)
+		g.Expect(err).ToNot(HaveOccurred())
+		base, err = c.Tree()
+		g.Expect(err).ToNot(HaveOccurred())
+		parents = append(parents, parent)
+	}
+	treeHash, err := writeTreeChanges(repo.Storer, base, changes)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	sig := object.Signature{Name: "Jane Doe", Email: "jane@example.com", When: time.Now()}
+	hash, err := writeCommit(repo.Storer, treeHash, parents, "test", sig, sig, signer)
+	g.Expect(err).ToNot(HaveOccurred())
+	return hash
+}
@@ -0,0 +1,205 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gogit
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	extgogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+
+	"github.com/fluxcd/pkg/git"
+	"github.com/fluxcd/pkg/git/repository"
+)
+
+// recurseSubmodules returns the recursivity go-git uses to checkout the
+// submodules while cloning. Submodules configured through a
+// repository.SubmoduleConfig are checked out by updateSubmodules instead.
+func recurseSubmodules(opts repository.CloneConfig) extgogit.SubmoduleRescursivity {
+	if opts.RecurseSubmodules && opts.Submodules == nil {
+		return extgogit.DefaultSubmoduleRecursionDepth
+	}
+	return extgogit.NoRecurseSubmodules
+}
+
+// submoduleUpdater checks out the submodules of a repository according to a
+// repository.SubmoduleConfig.
+type submoduleUpdater struct {
+	client   *Client
+	cfg      *repository.SubmoduleConfig
+	maxDepth int
+	// parent is the endpoint of the cloned repository, and parentAuth the
+	// auth method used to clone it.
+	parent     *transport.Endpoint
+	parentAuth transport.AuthMethod
+}
+
+// updateSubmodules initializes and updates the submodules of the given
+// repository, cloned from url using authMethod, up to the configured depth.
+// It is a no-op if cfg is nil.
+func (g *Client) updateSubmodules(ctx context.Context, repo *extgogit.Repository, url string,
+	authMethod transport.AuthMethod, cfg *repository.SubmoduleConfig) error {
+	if cfg == nil {
+		return nil
+	}
+	for _, pattern := range append(append([]string{}, cfg.Allow...), cfg.Deny...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid submodule URL pattern '%s': %w", pattern, err)
+		}
+	}
+	parent, err := transport.NewEndpoint(url)
+	if err != nil {
+		return fmt.Errorf("unable to parse URL '%s': %w", url, err)
+	}
+
+	u := &submoduleUpdater{
+		client:     g,
+		cfg:        cfg,
+		maxDepth:   cfg.MaxDepth,
+		parent:     parent,
+		parentAuth: authMethod,
+	}
+	if u.maxDepth <= 0 {
+		u.maxDepth = repository.DefaultSubmoduleMaxDepth
+	}
+	return u.update(ctx, repo, parent, 1)
+}
+
+// update checks out the submodules of repo, whose remote is at endpoint,
+// and recurses into them as long as depth does not exceed the max depth.
+func (u *submoduleUpdater) update(ctx context.Context, repo *extgogit.Repository, endpoint *transport.Endpoint, depth int) error {
+	if depth > u.maxDepth {
+		return nil
+	}
+
+	w, err := repo.Worktree()
+	if err != nil {
+		return fmt.Errorf("unable to open repo worktree: %w", err)
+	}
+	submodules, err := w.Submodules()
+	if err != nil {
+		return fmt.Errorf("unable to list submodules: %w", err)
+	}
+
+	for _, sm := range submodules {
+		smCfg := sm.Config()
+		smEndpoint, err := resolveSubmoduleURL(endpoint, smCfg.URL)
+		if err != nil {
+			return fmt.Errorf("unable to parse URL of submodule '%s': %w", smCfg.Name, err)
+		}
+		if !u.allowed(smEndpoint) {
+			continue
+		}
+
+		authMethod, err := u.authMethod(ctx, smEndpoint)
+		if err != nil {
+			return fmt.Errorf("unable to construct auth method for submodule '%s': %w", smCfg.Name, err)
+		}
+		err = retryWithFreshCredentials(authMethod, func() error {
+			return sm.UpdateContext(ctx, &extgogit.SubmoduleUpdateOptions{
+				Init:              true,
+				Auth:              authMethod,
+				RecurseSubmodules: extgogit.NoRecurseSubmodules,
+			})
+		})
+		if err != nil {
+			return fmt.Errorf("unable to update submodule '%s': %w", smCfg.Name, err)
+		}
+
+		smRepo, err := sm.Repository()
+		if err != nil {
+			return fmt.Errorf("unable to open submodule '%s': %w", smCfg.Name, err)
+		}
+		if err = u.update(ctx, smRepo, smEndpoint, depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// allowed returns if the submodule at the given endpoint matches the allow
+// patterns, and none of the deny patterns.
+func (u *submoduleUpdater) allowed(e *transport.Endpoint) bool {
+	name := strings.TrimSuffix(path.Join(e.Host, e.Path), ".git")
+	for _, pattern := range u.cfg.Deny {
+		if ok, _ := path.Match(pattern, name); ok {
+			return false
+		}
+	}
+	if len(u.cfg.Allow) == 0 {
+		return true
+	}
+	for _, pattern := range u.cfg.Allow {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// authMethod returns the auth method for the submodule at the given
+// endpoint, as resolved by the Credentials func of the config. If no
+// credentials are resolved, the auth method of the parent repository is
+// returned for submodules with the same protocol and host.
+func (u *submoduleUpdater) authMethod(ctx context.Context, e *transport.Endpoint) (transport.AuthMethod, error) {
+	if u.cfg.Credentials != nil {
+		opts, err := u.cfg.Credentials(ctx, e.String())
+		if err != nil {
+			return nil, err
+		}
+		if opts != nil {
+			o := *opts
+			opts = &o
+			if opts.Transport == "" {
+				opts.Transport = git.TransportType(e.Protocol)
+			}
+			if opts.Host == "" {
+				opts.Host = e.Host
+			}
+			if err := opts.Validate(); err != nil {
+				return nil, err
+			}
+			if opts.Transport == git.HTTP && !u.client.credentialsOverHTTP &&
+				(opts.Username != "" || opts.Password != "" || opts.BearerToken != "") {
+				return nil, fmt.Errorf("credentials cannot be sent over HTTP")
+			}
+			return transportAuth(opts, u.client.useDefaultKnownHosts)
+		}
+	}
+	if e.Protocol == u.parent.Protocol && e.Host == u.parent.Host {
+		return u.parentAuth, nil
+	}
+	return nil, nil
+}
+
+// resolveSubmoduleURL returns the endpoint of a submodule URL, resolving
+// relative URLs against the endpoint of the parent repository.
+func resolveSubmoduleURL(parent *transport.Endpoint, url string) (*transport.Endpoint, error) {
+	e, err := transport.NewEndpoint(url)
+	if err != nil {
+		return nil, err
+	}
+	if e.Protocol == "file" && !path.IsAbs(e.Path) {
+		resolved := *parent
+		resolved.Path = path.Join(parent.Path, e.Path)
+		return &resolved, nil
+	}
+	return e, nil
+}
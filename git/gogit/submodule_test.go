@@ -0,0 +1,236 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gogit
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	extgogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	. "github.com/onsi/gomega"
+
+	"github.com/fluxcd/pkg/git"
+	"github.com/fluxcd/pkg/git/repository"
+	"github.com/fluxcd/pkg/gittestserver"
+)
+
+func Test_resolveSubmoduleURL(t *testing.T) {
+	parent, err := transport.NewEndpoint("https://github.com/org/parent.git")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		url  string
+		want string
+	}{
+		{
+			name: "absolute HTTPS URL",
+			url:  "https://gitlab.com/org/repo.git",
+			want: "https://gitlab.com/org/repo.git",
+		},
+		{
+			name: "SCP-like SSH URL",
+			url:  "git@github.com:org/repo.git",
+			want: "ssh://git@github.com/org/repo.git",
+		},
+		{
+			name: "relative URL",
+			url:  "../sibling.git",
+			want: "https://github.com/org/sibling.git",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			e, err := resolveSubmoduleURL(parent, tt.url)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(e.String()).To(Equal(tt.want))
+		})
+	}
+}
+
+func Test_submoduleUpdater_allowed(t *testing.T) {
+	tests := []struct {
+		name  string
+		allow []string
+		deny  []string
+		url   string
+		want  bool
+	}{
+		{
+			name: "no patterns",
+			url:  "https://github.com/org/repo.git",
+			want: true,
+		},
+		{
+			name:  "matching allow pattern",
+			allow: []string{"github.com/org/*"},
+			url:   "git@github.com:org/repo.git",
+			want:  true,
+		},
+		{
+			name:  "no matching allow pattern",
+			allow: []string{"github.com/org/*"},
+			url:   "https://gitlab.com/org/repo.git",
+			want:  false,
+		},
+		{
+			name:  "deny takes precedence over allow",
+			allow: []string{"github.com/org/*"},
+			deny:  []string{"github.com/org/secret"},
+			url:   "https://github.com/org/secret.git",
+			want:  false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			e, err := transport.NewEndpoint(tt.url)
+			g.Expect(err).ToNot(HaveOccurred())
+			u := &submoduleUpdater{
+				cfg: &repository.SubmoduleConfig{Allow: tt.allow, Deny: tt.deny},
+			}
+			g.Expect(u.allowed(e)).To(Equal(tt.want))
+		})
+	}
+}
+
+func Test_updateSubmodules(t *testing.T) {
+	g := NewWithT(t)
+
+	server, err := gittestserver.NewTempGitServer()
+	g.Expect(err).ToNot(HaveOccurred())
+	defer os.RemoveAll(server.Root())
+
+	err = server.StartHTTP()
+	g.Expect(err).ToNot(HaveOccurred())
+	defer server.StopHTTP()
+
+	baseRepoPath := "base.git"
+	err = server.InitRepo("../testdata/git/repo", git.DefaultBranch, baseRepoPath)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	icingRepoPath := "icing.git"
+	err = server.InitRepo("../testdata/git/repo2", git.DefaultBranch, icingRepoPath)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	tmp := t.TempDir()
+	icingRepo, err := extgogit.PlainClone(tmp, false, &extgogit.CloneOptions{
+		URL:           server.HTTPAddress() + "/" + icingRepoPath,
+		ReferenceName: plumbing.NewBranchReferenceName(git.DefaultBranch),
+		Tags:          extgogit.NoTags,
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+
+	cmd := exec.Command("git", "submodule", "add", fmt.Sprintf("%s/%s", server.HTTPAddress(), baseRepoPath))
+	cmd.Dir = tmp
+	_, err = cmd.Output()
+	g.Expect(err).ToNot(HaveOccurred())
+
+	wt, err := icingRepo.Worktree()
+	g.Expect(err).ToNot(HaveOccurred())
+	_, err = wt.Add(".gitmodules")
+	g.Expect(err).ToNot(HaveOccurred())
+	_, err = wt.Commit("submod", &extgogit.CommitOptions{
+		Author: &object.Signature{
+			Name: "test user",
+		},
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+	err = icingRepo.Push(&extgogit.PushOptions{})
+	g.Expect(err).ToNot(HaveOccurred())
+
+	tests := []struct {
+		name       string
+		cfg        *repository.SubmoduleConfig
+		wantBase   bool
+		wantErr    string
+		wantLookup bool
+	}{
+		{
+			name:       "checks out allowed submodule",
+			cfg:        &repository.SubmoduleConfig{Allow: []string{"*/base"}},
+			wantBase:   true,
+			wantLookup: true,
+		},
+		{
+			name:     "skips denied submodule",
+			cfg:      &repository.SubmoduleConfig{Deny: []string{"*/base"}},
+			wantBase: false,
+		},
+		{
+			name:       "checks out direct submodules with max depth",
+			cfg:        &repository.SubmoduleConfig{MaxDepth: 1},
+			wantBase:   true,
+			wantLookup: true,
+		},
+		{
+			name:    "rejects invalid pattern",
+			cfg:     &repository.SubmoduleConfig{Allow: []string{"["}},
+			wantErr: "invalid submodule URL pattern",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			var lookups []string
+			tt.cfg.Credentials = func(_ context.Context, url string) (*git.AuthOptions, error) {
+				lookups = append(lookups, url)
+				return nil, nil
+			}
+
+			tmpDir := t.TempDir()
+			ggc, err := NewClient(tmpDir, &git.AuthOptions{
+				Transport: git.HTTP,
+			})
+			g.Expect(err).ToNot(HaveOccurred())
+
+			_, err = ggc.Clone(context.TODO(), server.HTTPAddress()+"/"+icingRepoPath, repository.CloneConfig{
+				CheckoutStrategy: repository.CheckoutStrategy{
+					Branch: git.DefaultBranch,
+				},
+				Submodules: tt.cfg,
+			})
+			if tt.wantErr != "" {
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(err.Error()).To(ContainSubstring(tt.wantErr))
+				return
+			}
+			g.Expect(err).ToNot(HaveOccurred())
+
+			_, err = os.Stat(filepath.Join(tmpDir, "base", "foo.txt"))
+			g.Expect(err == nil).To(Equal(tt.wantBase))
+			if tt.wantLookup {
+				g.Expect(lookups).To(Equal([]string{server.HTTPAddress() + "/" + baseRepoPath}))
+			} else {
+				g.Expect(lookups).To(BeEmpty())
+			}
+		})
+	}
+}
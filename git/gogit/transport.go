@@ -17,6 +17,7 @@ limitations under the License.
 package gogit
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/go-git/go-git/v5/plumbing/transport"
@@ -90,6 +91,19 @@ func transportAuth(opts *git.AuthOptions, fallbackToDefaultKnownHosts bool) (tra
 	}
 }
 
+// transportAuth returns the transport.AuthMethod for the auth options of the
+// client, making use of the credentials provider or the transport cache if
+// configured.
+func (g *Client) transportAuth(ctx context.Context) (transport.AuthMethod, error) {
+	if g.credentialsProvider != nil && g.authOpts != nil {
+		return newCredentialsAuth(ctx, g.authOpts.Transport, g.credentialsProvider, g.useDefaultKnownHosts)
+	}
+	if g.transportCache != nil {
+		return g.transportCache.authMethod(g.authOpts, g.useDefaultKnownHosts)
+	}
+	return transportAuth(g.authOpts, g.useDefaultKnownHosts)
+}
+
 // caBundle returns the CA bundle from the given git.AuthOptions.
 func caBundle(opts *git.AuthOptions) []byte {
 	if opts == nil {
@@ -129,6 +143,9 @@ func (a *CustomPublicKeys) ClientConfig() (*gossh.ClientConfig, error) {
 	if len(git.HostKeyAlgos) > 0 {
 		config.HostKeyAlgorithms = git.HostKeyAlgos
 	}
+	if err := applySSHAlgorithmPolicy(config); err != nil {
+		return nil, err
+	}
 
 	return config, nil
 }
@@ -150,8 +167,30 @@ func (a *DefaultAuth) ClientConfig() (*gossh.ClientConfig, error) {
 	if err != nil {
 		return nil, err
 	}
-	if err != nil {
+	if err := applySSHAlgorithmPolicy(config); err != nil {
 		return nil, err
 	}
 	return config, nil
 }
+
+// applySSHAlgorithmPolicy restricts the algorithms of the given
+// ssh.ClientConfig to the ones allowed by git.SSHAlgorithmPolicy.
+func applySSHAlgorithmPolicy(config *gossh.ClientConfig) error {
+	if git.SSHAlgorithmPolicy == nil {
+		return nil
+	}
+	algos, err := git.SSHAlgorithmPolicy.Restrict(git.SSHAlgorithms{
+		KeyExchanges:      config.KeyExchanges,
+		Ciphers:           config.Ciphers,
+		MACs:              config.MACs,
+		HostKeyAlgorithms: config.HostKeyAlgorithms,
+	})
+	if err != nil {
+		return err
+	}
+	config.KeyExchanges = algos.KeyExchanges
+	config.Ciphers = algos.Ciphers
+	config.MACs = algos.MACs
+	config.HostKeyAlgorithms = algos.HostKeyAlgorithms
+	return nil
+}
@@ -0,0 +1,311 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gogit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	gohttp "net/http"
+	"sync"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/client"
+	"github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
+
+	"github.com/fluxcd/pkg/git"
+)
+
+// DefaultTransportIdleTimeout is the default duration after which unused
+// entries of a TransportCache are evicted.
+const DefaultTransportIdleTimeout = 5 * time.Minute
+
+// TransportCache caches the connections and authentication methods used for
+// Git operations per host and credentials fingerprint, so that they can be
+// reused across clients and reconciliations.
+//
+// HTTP(S) connections are kept alive, and SSH connections are kept open,
+// between the operations of the clients configured with WithTransportCache
+// for the same host and credentials. SSH authentication methods, including
+// the parsed private keys and known_hosts, are reused for the same
+// credentials. Entries which are not used for longer than the idle timeout
+// are evicted, and their connections closed.
+type TransportCache struct {
+	idleTimeout time.Duration
+
+	mu    sync.Mutex
+	http  map[string]*cachedHTTPTransport
+	ssh   map[string]*cachedSSHConn
+	auths map[string]*cachedAuthMethod
+	now   func() time.Time
+}
+
+type cachedHTTPTransport struct {
+	transport transport.Transport
+	roundTrip *gohttp.Transport
+	lastUsed  time.Time
+}
+
+type cachedAuthMethod struct {
+	auth     transport.AuthMethod
+	lastUsed time.Time
+}
+
+// NewTransportCache returns a new TransportCache which evicts entries that
+// have not been used for the given idle timeout. If the timeout is zero or
+// negative, DefaultTransportIdleTimeout is used.
+func NewTransportCache(idleTimeout time.Duration) *TransportCache {
+	if idleTimeout <= 0 {
+		idleTimeout = DefaultTransportIdleTimeout
+	}
+	return &TransportCache{
+		idleTimeout: idleTimeout,
+		http:        map[string]*cachedHTTPTransport{},
+		ssh:         map[string]*cachedSSHConn{},
+		auths:       map[string]*cachedAuthMethod{},
+		now:         time.Now,
+	}
+}
+
+// Len returns the number of cached entries.
+func (c *TransportCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.http) + len(c.ssh) + len(c.auths)
+}
+
+// Prune evicts the entries which have not been used for longer than the
+// idle timeout.
+func (c *TransportCache) Prune() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.prune()
+}
+
+// Close evicts all the entries and closes their connections, including the
+// SSH connections of the operations in progress.
+func (c *TransportCache) Close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for k, e := range c.http {
+		e.roundTrip.CloseIdleConnections()
+		delete(c.http, k)
+	}
+	for k, e := range c.ssh {
+		_ = e.client.Close()
+		delete(c.ssh, k)
+	}
+	for k := range c.auths {
+		delete(c.auths, k)
+	}
+}
+
+func (c *TransportCache) prune() {
+	now := c.now()
+	for k, e := range c.http {
+		if now.Sub(e.lastUsed) > c.idleTimeout {
+			e.roundTrip.CloseIdleConnections()
+			delete(c.http, k)
+		}
+	}
+	for k, e := range c.ssh {
+		if e.sessions == 0 && now.Sub(e.lastUsed) > c.idleTimeout {
+			_ = e.client.Close()
+			delete(c.ssh, k)
+		}
+	}
+	for k, e := range c.auths {
+		if now.Sub(e.lastUsed) > c.idleTimeout {
+			delete(c.auths, k)
+		}
+	}
+}
+
+// httpTransport returns the cached transport for the host and credentials,
+// creating it if it does not exist.
+func (c *TransportCache) httpTransport(ep *transport.Endpoint, auth transport.AuthMethod) transport.Transport {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%d\x00", ep.Protocol, ep.Host, ep.Port)
+	writeAuthMethod(h, auth)
+	key := hex.EncodeToString(h.Sum(nil))
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.prune()
+
+	e, ok := c.http[key]
+	if !ok {
+		rt := gohttp.DefaultTransport.(*gohttp.Transport).Clone()
+		rt.IdleConnTimeout = c.idleTimeout
+		e = &cachedHTTPTransport{
+			transport: http.NewClient(&gohttp.Client{Transport: rt}),
+			roundTrip: rt,
+		}
+		c.http[key] = e
+	}
+	e.lastUsed = c.now()
+	return e.transport
+}
+
+// authMethod returns the cached transport.AuthMethod for the given options,
+// constructing it with transportAuth if it does not exist. The returned auth
+// method routes the operations making use of it to the connections of the
+// cache.
+func (c *TransportCache) authMethod(opts *git.AuthOptions, fallbackToDefaultKnownHosts bool) (transport.AuthMethod, error) {
+	if opts == nil {
+		return nil, nil
+	}
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%s\x00%s\x00%s\x00%t\x00", opts.Transport, opts.Host, opts.Username,
+		opts.Password, opts.BearerToken, fallbackToDefaultKnownHosts)
+	writeBytes(h, opts.Identity)
+	writeBytes(h, opts.KnownHosts)
+	key := hex.EncodeToString(h.Sum(nil))
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.prune()
+
+	if e, ok := c.auths[key]; ok {
+		e.lastUsed = c.now()
+		return e.auth, nil
+	}
+
+	auth, err := transportAuth(opts, fallbackToDefaultKnownHosts)
+	if err != nil {
+		return nil, err
+	}
+	switch a := auth.(type) {
+	case http.AuthMethod:
+		auth = &cachedHTTPAuth{auth: a, cache: c}
+	case ssh.AuthMethod:
+		auth = &cachedSSHAuth{AuthMethod: a, cache: c, key: key}
+	case nil:
+		// Anonymous HTTP(S) operations are routed to the cache as well.
+		if opts.Transport == git.HTTP || opts.Transport == git.HTTPS {
+			auth = &cachedHTTPAuth{cache: c}
+		}
+	}
+	c.auths[key] = &cachedAuthMethod{auth: auth, lastUsed: c.now()}
+	return auth, nil
+}
+
+// cachedHTTPAuth is an HTTP auth method, which may be nil for anonymous
+// operations, bound to a TransportCache.
+type cachedHTTPAuth struct {
+	auth  http.AuthMethod
+	cache *TransportCache
+}
+
+func (a *cachedHTTPAuth) Name() string {
+	if a.auth == nil {
+		return "http-anonymous"
+	}
+	return a.auth.Name()
+}
+
+func (a *cachedHTTPAuth) String() string {
+	if a.auth == nil {
+		return a.Name()
+	}
+	return a.auth.String()
+}
+
+func (a *cachedHTTPAuth) SetAuth(r *gohttp.Request) {
+	if a.auth != nil {
+		a.auth.SetAuth(r)
+	}
+}
+
+// unwrap returns the bound auth method, or nil for anonymous operations.
+func (a *cachedHTTPAuth) unwrap() transport.AuthMethod {
+	if a.auth == nil {
+		return nil
+	}
+	return a.auth
+}
+
+// cachedSSHAuth is an SSH auth method bound to a TransportCache. The key is
+// the fingerprint of the credentials it was constructed from.
+type cachedSSHAuth struct {
+	ssh.AuthMethod
+	cache *TransportCache
+	key   string
+}
+
+// cacheTransport routes the sessions of the auth methods bound to a
+// TransportCache to the connections of the cache, and the other sessions to
+// the transport it replaces.
+type cacheTransport struct {
+	fallback transport.Transport
+}
+
+// installCacheTransport wraps the go-git transports of the protocols
+// supported by TransportCache. It does not change the behavior of the
+// operations which do not make use of a TransportCache.
+func installCacheTransport() {
+	for _, protocol := range []string{"http", "https", "ssh"} {
+		if t, ok := client.Protocols[protocol]; ok && t != nil {
+			client.InstallProtocol(protocol, &cacheTransport{fallback: t})
+		}
+	}
+}
+
+// NewUploadPackSession implements transport.Transport.
+func (t *cacheTransport) NewUploadPackSession(ep *transport.Endpoint, auth transport.AuthMethod) (transport.UploadPackSession, error) {
+	switch a := auth.(type) {
+	case *cachedHTTPAuth:
+		return a.cache.httpTransport(ep, a.auth).NewUploadPackSession(ep, a.unwrap())
+	case *cachedSSHAuth:
+		return a.cache.newSSHSession(ep, a, transport.UploadPackServiceName)
+	}
+	return t.fallback.NewUploadPackSession(ep, auth)
+}
+
+// NewReceivePackSession implements transport.Transport.
+func (t *cacheTransport) NewReceivePackSession(ep *transport.Endpoint, auth transport.AuthMethod) (transport.ReceivePackSession, error) {
+	switch a := auth.(type) {
+	case *cachedHTTPAuth:
+		return a.cache.httpTransport(ep, a.auth).NewReceivePackSession(ep, a.unwrap())
+	case *cachedSSHAuth:
+		return a.cache.newSSHSession(ep, a, transport.ReceivePackServiceName)
+	}
+	return t.fallback.NewReceivePackSession(ep, auth)
+}
+
+// writeAuthMethod writes the credentials of the given auth method to the hash.
+func writeAuthMethod(h hash.Hash, auth transport.AuthMethod) {
+	switch a := auth.(type) {
+	case nil:
+	case *http.BasicAuth:
+		fmt.Fprintf(h, "basic\x00%s\x00%s", a.Username, a.Password)
+	case *http.TokenAuth:
+		fmt.Fprintf(h, "token\x00%s", a.Token)
+	default:
+		fmt.Fprintf(h, "%s\x00%p", a.Name(), a)
+	}
+}
+
+// writeBytes writes the length prefixed bytes to the hash.
+func writeBytes(h hash.Hash, b []byte) {
+	fmt.Fprintf(h, "%d\x00", len(b))
+	h.Write(b)
+}
@@ -0,0 +1,500 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gogit
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing/format/pktline"
+	"github.com/go-git/go-git/v5/plumbing/protocol/packp"
+	"github.com/go-git/go-git/v5/plumbing/protocol/packp/capability"
+	"github.com/go-git/go-git/v5/plumbing/protocol/packp/sideband"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"github.com/go-git/go-git/v5/utils/ioutil"
+	"github.com/skeema/knownhosts"
+	gossh "golang.org/x/crypto/ssh"
+	"golang.org/x/net/proxy"
+)
+
+// sshStderrTimeout is the time to wait for the SSH server to report why a
+// command did not produce any output.
+const sshStderrTimeout = 10 * time.Second
+
+// sshStderrSkipPattern matches the lines of the stderr output of a command
+// which do not describe an error.
+var sshStderrSkipPattern = regexp.MustCompile("^remote:( =*){0,1}$")
+
+// cachedSSHConn is an SSH connection of a TransportCache, shared by the
+// sessions of the operations for the same host and credentials.
+type cachedSSHConn struct {
+	client   *gossh.Client
+	sessions int
+	lastUsed time.Time
+}
+
+// newSSHSession starts the Git service on a new session of the cached
+// connection to the endpoint, dialing it if needed.
+func (c *TransportCache) newSSHSession(ep *transport.Endpoint, auth *cachedSSHAuth, service string) (*sshSession, error) {
+	addr := sshAddress(ep)
+	key := auth.key + "\x00" + addr
+
+	conn, err := c.sshConn(key, addr, ep.Proxy, auth)
+	if err != nil {
+		return nil, err
+	}
+	session, err := conn.client.NewSession()
+	if err != nil {
+		// The connection may have been closed by the server, it is replaced
+		// with a new one.
+		c.releaseSSHConn(key, conn, true)
+		if conn, err = c.sshConn(key, addr, ep.Proxy, auth); err != nil {
+			return nil, err
+		}
+		if session, err = conn.client.NewSession(); err != nil {
+			c.releaseSSHConn(key, conn, true)
+			return nil, err
+		}
+	}
+
+	s := &sshSession{
+		session:       session,
+		isReceivePack: service == transport.ReceivePackServiceName,
+		release: func() {
+			c.releaseSSHConn(key, conn, false)
+		},
+	}
+	if err := s.start(fmt.Sprintf("%s '%s'", service, ep.Path)); err != nil {
+		_ = session.Close()
+		s.release()
+		return nil, err
+	}
+	return s, nil
+}
+
+// sshConn returns the cached connection for the key, dialing it if it does
+// not exist, and marks it as used by a session until it is released.
+func (c *TransportCache) sshConn(key, addr string, proxyOpts transport.ProxyOptions, auth ssh.AuthMethod) (*cachedSSHConn, error) {
+	c.mu.Lock()
+	c.prune()
+	if e, ok := c.ssh[key]; ok {
+		e.sessions++
+		e.lastUsed = c.now()
+		c.mu.Unlock()
+		return e, nil
+	}
+	c.mu.Unlock()
+
+	client, err := dialSSH(addr, proxyOpts, auth)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// Another operation may have connected to the host in the meantime.
+	if e, ok := c.ssh[key]; ok {
+		_ = client.Close()
+		e.sessions++
+		e.lastUsed = c.now()
+		return e, nil
+	}
+	e := &cachedSSHConn{client: client, sessions: 1, lastUsed: c.now()}
+	c.ssh[key] = e
+	return e, nil
+}
+
+// releaseSSHConn marks the connection as no longer used by a session. Broken
+// connections are evicted and closed.
+func (c *TransportCache) releaseSSHConn(key string, conn *cachedSSHConn, broken bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	conn.sessions--
+	conn.lastUsed = c.now()
+	if broken {
+		if c.ssh[key] == conn {
+			delete(c.ssh, key)
+		}
+		_ = conn.client.Close()
+	}
+}
+
+// sshAddress returns the address of the SSH server of the endpoint, taking
+// the ssh_config of the user into account like the go-git SSH transport.
+func sshAddress(ep *transport.Endpoint) string {
+	host, port := ep.Host, ep.Port
+	if ssh.DefaultSSHConfig != nil {
+		if h := ssh.DefaultSSHConfig.Get(ep.Host, "Hostname"); h != "" {
+			host = h
+			if p, err := strconv.Atoi(ssh.DefaultSSHConfig.Get(ep.Host, "Port")); err == nil {
+				port = p
+			}
+		}
+	}
+	if port <= 0 {
+		port = ssh.DefaultPort
+	}
+	return net.JoinHostPort(host, strconv.Itoa(port))
+}
+
+// dialSSH connects to the SSH server at the address with the auth method,
+// through the proxy if configured, like the go-git SSH transport.
+func dialSSH(addr string, proxyOpts transport.ProxyOptions, auth ssh.AuthMethod) (*gossh.Client, error) {
+	config, err := auth.ClientConfig()
+	if err != nil {
+		return nil, err
+	}
+	if config.HostKeyCallback == nil {
+		if config.HostKeyCallback, err = ssh.NewKnownHostsCallback(); err != nil {
+			return nil, err
+		}
+	}
+	if len(config.HostKeyAlgorithms) == 0 {
+		config.HostKeyAlgorithms = knownhosts.HostKeyAlgorithms(config.HostKeyCallback, addr)
+	}
+
+	ctx := context.Background()
+	if config.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, config.Timeout)
+		defer cancel()
+	}
+
+	var conn net.Conn
+	if proxyOpts.URL != "" {
+		proxyURL, err := proxyOpts.FullURL()
+		if err != nil {
+			return nil, err
+		}
+		dialer, err := proxy.FromURL(proxyURL, proxy.Direct)
+		if err != nil {
+			return nil, err
+		}
+		ctxDialer, ok := dialer.(proxy.ContextDialer)
+		if !ok {
+			return nil, fmt.Errorf("expected ssh proxy dialer to be a proxy.ContextDialer, got %T", dialer)
+		}
+		conn, err = ctxDialer.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		conn, err = proxy.Dial(ctx, "tcp", addr)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	c, chans, reqs, err := gossh.NewClientConn(conn, addr, config)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return gossh.NewClient(c, chans, reqs), nil
+}
+
+// sshSession runs a Git service on an SSH session of a cached connection.
+// It implements transport.UploadPackSession and transport.ReceivePackSession
+// the same way as the go-git SSH transport, except that closing it releases
+// the connection instead of closing it.
+type sshSession struct {
+	session *gossh.Session
+	release func()
+
+	stdin        io.WriteCloser
+	stdout       io.Reader
+	firstErrLine chan string
+
+	isReceivePack bool
+	advRefs       *packp.AdvRefs
+	packRun       bool
+	finished      bool
+
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// start starts the command on the session.
+func (s *sshSession) start(cmd string) error {
+	var err error
+	if s.stdin, err = s.session.StdinPipe(); err != nil {
+		return err
+	}
+	if s.stdout, err = s.session.StdoutPipe(); err != nil {
+		return err
+	}
+	stderr, err := s.session.StderrPipe()
+	if err != nil {
+		return err
+	}
+	if err := s.session.Start(cmd); err != nil {
+		return err
+	}
+
+	s.firstErrLine = make(chan string, 1)
+	go func() {
+		scanner := bufio.NewScanner(stderr)
+		for scanner.Scan() {
+			if line := scanner.Text(); !sshStderrSkipPattern.MatchString(line) {
+				s.firstErrLine <- line
+				break
+			}
+		}
+		close(s.firstErrLine)
+		_, _ = io.Copy(io.Discard, stderr)
+	}()
+	return nil
+}
+
+func (s *sshSession) AdvertisedReferences() (*packp.AdvRefs, error) {
+	return s.AdvertisedReferencesContext(context.TODO())
+}
+
+func (s *sshSession) AdvertisedReferencesContext(ctx context.Context) (*packp.AdvRefs, error) {
+	if s.advRefs != nil {
+		return s.advRefs, nil
+	}
+
+	ar := packp.NewAdvRefs()
+	if err := ar.Decode(s.stdoutContext(ctx)); err != nil {
+		if err := s.advRefsDecodeError(err); err != nil {
+			return nil, err
+		}
+	}
+	// Some servers announce capabilities instead of returning an empty
+	// advertised-references message.
+	if !s.isReceivePack && ar.IsEmpty() {
+		return nil, transport.ErrEmptyRemoteRepository
+	}
+
+	transport.FilterUnsupportedCapabilities(ar.Capabilities)
+	s.advRefs = ar
+	return ar, nil
+}
+
+// advRefsDecodeError returns the error to report for the given error to
+// decode the advertised references, or nil for empty repositories which
+// can be pushed to.
+func (s *sshSession) advRefsDecodeError(err error) error {
+	var errLine *pktline.ErrorLine
+	if errors.As(err, &errLine) {
+		if isRepoNotFoundError(errLine.Text) {
+			return transport.ErrRepositoryNotFound
+		}
+		return errLine
+	}
+
+	switch {
+	case errors.Is(err, packp.ErrEmptyInput):
+		// The server writes the reason to stderr, e.g. when the repository
+		// is not found.
+		s.finished = true
+		return s.stderrError()
+	case errors.Is(err, packp.ErrEmptyAdvRefs):
+		if s.isReceivePack {
+			return nil
+		}
+		if err := s.finish(); err != nil {
+			return err
+		}
+		return transport.ErrEmptyRemoteRepository
+	}
+
+	var dataErr *packp.ErrUnexpectedData
+	if errors.As(err, &dataErr) && isRepoNotFoundError(string(dataErr.Data)) {
+		return transport.ErrRepositoryNotFound
+	}
+	return err
+}
+
+// stderrError returns the error written by the server to stderr.
+func (s *sshSession) stderrError() error {
+	timer := time.NewTimer(sshStderrTimeout)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return errors.New("timeout exceeded")
+	case line, ok := <-s.firstErrLine:
+		if !ok || line == "" {
+			return io.ErrUnexpectedEOF
+		}
+		if isRepoNotFoundError(line) {
+			return transport.ErrRepositoryNotFound
+		}
+		return fmt.Errorf("unknown error: %s", line)
+	}
+}
+
+func (s *sshSession) UploadPack(ctx context.Context, req *packp.UploadPackRequest) (*packp.UploadPackResponse, error) {
+	if req.IsEmpty() {
+		// The haves are a subset of the wants, there is nothing to fetch.
+		if err := s.finish(); err != nil {
+			return nil, err
+		}
+		return nil, transport.ErrEmptyUploadPackRequest
+	}
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	if _, err := s.AdvertisedReferencesContext(ctx); err != nil {
+		return nil, err
+	}
+
+	s.packRun = true
+	in := s.stdinContext(ctx)
+	if err := req.UploadRequest.Encode(in); err != nil {
+		return nil, fmt.Errorf("sending upload-req message: %w", err)
+	}
+	if err := req.UploadHaves.Encode(in, true); err != nil {
+		return nil, fmt.Errorf("sending haves message: %w", err)
+	}
+	if err := pktline.NewEncoder(in).Encodef("done\n"); err != nil {
+		return nil, fmt.Errorf("sending done message: %w", err)
+	}
+	if err := in.Close(); err != nil {
+		return nil, fmt.Errorf("closing input: %w", err)
+	}
+
+	r, err := ioutil.NonEmptyReader(s.stdoutContext(ctx))
+	if err == ioutil.ErrEmptyReader {
+		return nil, transport.ErrEmptyUploadPackRequest
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	res := packp.NewUploadPackResponse(req)
+	if err := res.Decode(ioutil.NewReadCloser(r, s)); err != nil {
+		return nil, fmt.Errorf("error decoding upload-pack response: %w", err)
+	}
+	return res, nil
+}
+
+func (s *sshSession) ReceivePack(ctx context.Context, req *packp.ReferenceUpdateRequest) (*packp.ReportStatus, error) {
+	if _, err := s.AdvertisedReferencesContext(ctx); err != nil {
+		return nil, err
+	}
+
+	s.packRun = true
+	w := s.stdinContext(ctx)
+	if err := req.Encode(w); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	if !req.Capabilities.Supports(capability.ReportStatus) {
+		// Without report-status, only the exit status of the command
+		// tells whether the push failed.
+		err := s.session.Wait()
+		if closeErr := s.Close(); err == nil {
+			err = closeErr
+		}
+		return nil, err
+	}
+
+	r := s.stdoutContext(ctx)
+	var d *sideband.Demuxer
+	if req.Capabilities.Supports(capability.Sideband64k) {
+		d = sideband.NewDemuxer(sideband.Sideband64k, r)
+	} else if req.Capabilities.Supports(capability.Sideband) {
+		d = sideband.NewDemuxer(sideband.Sideband, r)
+	}
+	if d != nil {
+		d.Progress = req.Progress
+		r = d
+	}
+
+	report := packp.NewReportStatus()
+	if err := report.Decode(r); err != nil {
+		return nil, err
+	}
+	if err := report.Error(); err != nil {
+		defer s.Close()
+		return report, err
+	}
+	return report, s.Close()
+}
+
+func (s *sshSession) stdinContext(ctx context.Context) io.WriteCloser {
+	return ioutil.NewWriteCloserOnError(ioutil.NewContextWriteCloser(ctx, s.stdin), s.onError)
+}
+
+func (s *sshSession) stdoutContext(ctx context.Context) io.Reader {
+	return ioutil.NewReaderOnError(ioutil.NewContextReader(ctx, s.stdout), s.onError)
+}
+
+func (s *sshSession) onError(error) {
+	_ = s.Close()
+}
+
+// finish tells the server to exit gracefully with a flush packet, unless a
+// pack was requested.
+func (s *sshSession) finish() error {
+	if s.finished {
+		return nil
+	}
+	s.finished = true
+	if !s.packRun {
+		_, err := s.stdin.Write(pktline.FlushPkt)
+		return err
+	}
+	return nil
+}
+
+// Close closes the SSH session, and releases its connection.
+func (s *sshSession) Close() error {
+	s.closeOnce.Do(func() {
+		s.closeErr = s.finish()
+		if err := s.session.Close(); err != nil && !errors.Is(err, io.EOF) && s.closeErr == nil {
+			s.closeErr = err
+		}
+		s.release()
+	})
+	return s.closeErr
+}
+
+// isRepoNotFoundError returns whether the message of the server reports a
+// missing repository, as recognized by go-git.
+func isRepoNotFoundError(s string) bool {
+	for _, msg := range []string{
+		"Repository not found.",
+		"repository does not exist.",
+		"does not appear to be a git repository",
+		"no such repository",
+		"access denied",
+		"Repository does not exist or you do not have access",
+		"The project you were looking for could not be found",
+	} {
+		if strings.Contains(s, msg) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,275 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gogit
+
+import (
+	"context"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/http"
+	. "github.com/onsi/gomega"
+
+	"github.com/fluxcd/pkg/git"
+	"github.com/fluxcd/pkg/git/repository"
+	"github.com/fluxcd/pkg/gittestserver"
+	"github.com/fluxcd/pkg/ssh"
+)
+
+func TestTransportCache_authMethod(t *testing.T) {
+	g := NewWithT(t)
+
+	now := time.Now()
+	cache := NewTransportCache(time.Minute)
+	cache.now = func() time.Time { return now }
+
+	opts := &git.AuthOptions{
+		Transport: git.HTTPS,
+		Username:  "user",
+		Password:  "pass",
+	}
+	auth, err := cache.authMethod(opts, false)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(auth).To(Equal(&cachedHTTPAuth{auth: &http.BasicAuth{Username: "user", Password: "pass"}, cache: cache}))
+
+	// The same credentials result in the cached auth method.
+	cached, err := cache.authMethod(&git.AuthOptions{
+		Transport: git.HTTPS,
+		Username:  "user",
+		Password:  "pass",
+	}, false)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(cached == auth).To(BeTrue())
+
+	// Different credentials result in a new auth method.
+	other, err := cache.authMethod(&git.AuthOptions{
+		Transport: git.HTTPS,
+		Username:  "user",
+		Password:  "other",
+	}, false)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(other == auth).To(BeFalse())
+	g.Expect(cache.Len()).To(Equal(2))
+
+	// Anonymous operations are bound to the cache as well.
+	anonymous, err := cache.authMethod(&git.AuthOptions{Transport: git.HTTPS}, false)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(anonymous).To(Equal(&cachedHTTPAuth{cache: cache}))
+	g.Expect(cache.Len()).To(Equal(3))
+
+	// Entries are evicted once idle for longer than the timeout.
+	now = now.Add(2 * time.Minute)
+	cache.Prune()
+	g.Expect(cache.Len()).To(Equal(0))
+
+	// Errors are not cached.
+	_, err = cache.authMethod(&git.AuthOptions{}, false)
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(cache.Len()).To(Equal(0))
+}
+
+func TestTransportCache_httpTransport(t *testing.T) {
+	g := NewWithT(t)
+
+	now := time.Now()
+	cache := NewTransportCache(time.Minute)
+	cache.now = func() time.Time { return now }
+
+	ep, err := transport.NewEndpoint("https://example.com/org/repo.git")
+	g.Expect(err).ToNot(HaveOccurred())
+	auth := &http.BasicAuth{Username: "user", Password: "pass"}
+
+	tr := cache.httpTransport(ep, auth)
+	g.Expect(tr).ToNot(BeNil())
+
+	// The same host and credentials result in the cached transport.
+	g.Expect(cache.httpTransport(ep, &http.BasicAuth{Username: "user", Password: "pass"}) == tr).To(BeTrue())
+
+	// A different host or different credentials result in a new transport.
+	g.Expect(cache.httpTransport(ep, &http.BasicAuth{Username: "user", Password: "other"}) == tr).To(BeFalse())
+	otherEp, err := transport.NewEndpoint("https://example.org/org/repo.git")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(cache.httpTransport(otherEp, auth) == tr).To(BeFalse())
+	g.Expect(cache.Len()).To(Equal(3))
+
+	// Using an entry postpones its eviction.
+	now = now.Add(50 * time.Second)
+	g.Expect(cache.httpTransport(ep, auth) == tr).To(BeTrue())
+	now = now.Add(50 * time.Second)
+	cache.Prune()
+	g.Expect(cache.Len()).To(Equal(1))
+
+	cache.Close()
+	g.Expect(cache.Len()).To(Equal(0))
+}
+
+func TestTransportCache_http(t *testing.T) {
+	g := NewWithT(t)
+
+	server, err := gittestserver.NewTempGitServer()
+	g.Expect(err).ToNot(HaveOccurred())
+	defer os.RemoveAll(server.Root())
+	g.Expect(server.StartHTTP()).To(Succeed())
+	defer server.StopHTTP()
+	g.Expect(server.InitRepo("../testdata/git/repo", git.DefaultBranch, "test.git")).To(Succeed())
+
+	cache := NewTransportCache(time.Minute)
+	defer cache.Close()
+	for i := 0; i < 2; i++ {
+		ggc, err := NewClient(t.TempDir(), &git.AuthOptions{Transport: git.HTTP}, WithDiskStorage(), WithTransportCache(cache))
+		g.Expect(err).ToNot(HaveOccurred())
+		_, err = ggc.Clone(context.TODO(), server.HTTPAddress()+"/test.git", repository.CloneConfig{
+			CheckoutStrategy: repository.CheckoutStrategy{Branch: git.DefaultBranch},
+		})
+		g.Expect(err).ToNot(HaveOccurred())
+	}
+
+	// The clones share the transport of the cache.
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	g.Expect(cache.http).To(HaveLen(1))
+}
+
+func TestTransportCache_ssh(t *testing.T) {
+	g := NewWithT(t)
+	timeout := 5 * time.Second
+
+	kexAlgos, hostKeyAlgos := git.KexAlgos, git.HostKeyAlgos
+	defer func() {
+		git.KexAlgos, git.HostKeyAlgos = kexAlgos, hostKeyAlgos
+	}()
+	git.KexAlgos, git.HostKeyAlgos = nil, nil
+
+	server := gittestserver.NewGitServer(t.TempDir())
+	server.KeyDir(filepath.Join(server.Root(), "keys"))
+	g.Expect(server.ListenSSH()).To(Succeed())
+	go func() {
+		server.StartSSH()
+	}()
+	defer server.StopSSH()
+
+	g.Expect(server.InitRepo("../testdata/git/repo", git.DefaultBranch, "test.git")).To(Succeed())
+	u, err := url.Parse(server.SSHAddress())
+	g.Expect(err).ToNot(HaveOccurred())
+	knownHosts, err := ssh.ScanHostKey(u.Host, timeout, nil, false)
+	g.Expect(err).ToNot(HaveOccurred())
+	kp, err := ssh.GenerateKeyPair(ssh.ED25519)
+	g.Expect(err).ToNot(HaveOccurred())
+	authOpts := &git.AuthOptions{
+		Transport:  git.SSH,
+		Identity:   kp.PrivateKey,
+		KnownHosts: knownHosts,
+	}
+
+	cache := NewTransportCache(time.Minute)
+	defer cache.Close()
+	clone := func(repoPath string) (*Client, error) {
+		ctx, cancel := context.WithTimeout(context.TODO(), timeout)
+		defer cancel()
+
+		ggc, err := NewClient(t.TempDir(), authOpts, WithDiskStorage(), WithTransportCache(cache))
+		g.Expect(err).ToNot(HaveOccurred())
+		_, err = ggc.Clone(ctx, server.SSHAddress()+"/"+repoPath, repository.CloneConfig{
+			CheckoutStrategy: repository.CheckoutStrategy{Branch: git.DefaultBranch},
+		})
+		return ggc, err
+	}
+	sshConn := func() *cachedSSHConn {
+		cache.mu.Lock()
+		defer cache.mu.Unlock()
+		g.Expect(cache.ssh).To(HaveLen(1))
+		for _, conn := range cache.ssh {
+			g.Expect(conn.sessions).To(BeZero())
+			return conn
+		}
+		return nil
+	}
+
+	_, err = clone("test.git")
+	g.Expect(err).ToNot(HaveOccurred())
+	conn := sshConn()
+
+	// The connection is reused by the operations of other clients.
+	ggc, err := clone("test.git")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(sshConn()).To(BeIdenticalTo(conn))
+
+	_, err = ggc.Commit(git.Commit{
+		Author:  git.Signature{Name: "Test User", Email: "test@example.com"},
+		Message: "testing",
+	}, repository.WithFiles(map[string]io.Reader{"bar.txt": strings.NewReader("bar")}))
+	g.Expect(err).ToNot(HaveOccurred())
+	ctx, cancel := context.WithTimeout(context.TODO(), timeout)
+	defer cancel()
+	g.Expect(ggc.Push(ctx, repository.PushConfig{})).To(Succeed())
+	g.Expect(sshConn()).To(BeIdenticalTo(conn))
+
+	_, err = clone("missing.git")
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(sshConn()).To(BeIdenticalTo(conn))
+
+	// A new connection is made once the cache is closed.
+	cache.Close()
+	_, err = clone("test.git")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(sshConn()).ToNot(BeIdenticalTo(conn))
+}
+
+func TestCacheTransport_fallback(t *testing.T) {
+	g := NewWithT(t)
+
+	fallback := &recordingTransport{}
+	tr := &cacheTransport{fallback: fallback}
+	ep, err := transport.NewEndpoint("https://example.com/org/repo.git")
+	g.Expect(err).ToNot(HaveOccurred())
+
+	// The operations which don't make use of a cache are served by the
+	// replaced transport.
+	auth := &http.BasicAuth{Username: "user", Password: "pass"}
+	_, err = tr.NewUploadPackSession(ep, auth)
+	g.Expect(err).ToNot(HaveOccurred())
+	_, err = tr.NewReceivePackSession(ep, nil)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(fallback.auths).To(Equal([]transport.AuthMethod{auth, nil}))
+
+	// The operations of a cache are not.
+	cache := NewTransportCache(time.Minute)
+	_, err = tr.NewUploadPackSession(ep, &cachedHTTPAuth{auth: auth, cache: cache})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(fallback.auths).To(HaveLen(2))
+	g.Expect(cache.Len()).To(Equal(1))
+}
+
+type recordingTransport struct {
+	auths []transport.AuthMethod
+}
+
+func (t *recordingTransport) NewUploadPackSession(_ *transport.Endpoint, auth transport.AuthMethod) (transport.UploadPackSession, error) {
+	t.auths = append(t.auths, auth)
+	return nil, nil
+}
+
+func (t *recordingTransport) NewReceivePackSession(_ *transport.Endpoint, auth transport.AuthMethod) (transport.ReceivePackSession, error) {
+	t.auths = append(t.auths, auth)
+	return nil, nil
+}
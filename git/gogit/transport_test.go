@@ -309,6 +309,36 @@ func TestCustomPublicKeys_ClientConfig(t *testing.T) {
 	g.Expect(count).To(Equal(1))
 }
 
+func TestCustomPublicKeys_ClientConfig_SSHAlgorithmPolicy(t *testing.T) {
+	g := NewWithT(t)
+	pk, err := ssh.NewPublicKeys("user", []byte(privateKeyFixture), "password")
+	g.Expect(err).ToNot(HaveOccurred())
+
+	kexAlgos, hostKeyAlgos, policy := git.KexAlgos, git.HostKeyAlgos, git.SSHAlgorithmPolicy
+	defer func() {
+		git.KexAlgos, git.HostKeyAlgos, git.SSHAlgorithmPolicy = kexAlgos, hostKeyAlgos, policy
+	}()
+
+	git.KexAlgos = []string{"curve25519-sha256", "ecdh-sha2-nistp256"}
+	git.HostKeyAlgos = nil
+	git.SSHAlgorithmPolicy = &git.FIPSSSHAlgorithms
+
+	customPK := CustomPublicKeys{
+		pk:       pk,
+		callback: gossh.InsecureIgnoreHostKey(),
+	}
+	cfg, err := customPK.ClientConfig()
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(cfg.KeyExchanges).To(Equal([]string{"ecdh-sha2-nistp256"}))
+	g.Expect(cfg.Ciphers).To(Equal(git.FIPSSSHAlgorithms.Ciphers))
+	g.Expect(cfg.MACs).To(Equal(git.FIPSSSHAlgorithms.MACs))
+	g.Expect(cfg.HostKeyAlgorithms).To(Equal(git.FIPSSSHAlgorithms.HostKeyAlgorithms))
+
+	git.KexAlgos = []string{"curve25519-sha256"}
+	_, err = customPK.ClientConfig()
+	g.Expect(err).To(MatchError(ContainSubstring("none of the key exchange algorithms")))
+}
+
 func Test_defaultKnownHosts(t *testing.T) {
 	g := NewWithT(t)
 	tmp, err := os.MkdirTemp("", "ssh_agent")
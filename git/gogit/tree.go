@@ -0,0 +1,289 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gogit
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/go-git/go-billy/v5/memfs"
+	extgogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/format/index"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage"
+	"github.com/go-git/go-git/v5/storage/memory"
+
+	"github.com/fluxcd/pkg/git/repository"
+)
+
+// writeTree writes the tree resulting from applying the changes to the base
+// tree to the storer, and returns its hash. The base tree may be nil, in which
+// case the changes are applied to an empty tree. It returns an error if more
+// than one change applies to the same path, or if a file would replace a
+// directory or be written below a file that is not deleted by the changes.
+func writeTree(s storage.Storer, base *object.Tree, changes []repository.FileChange) (plumbing.Hash, error) {
+	normalized := make(map[string]treeChange, len(changes))
+	for _, c := range changes {
+		p, err := cleanTreePath(c.Path)
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+		if _, ok := normalized[p]; ok {
+			return plumbing.ZeroHash, fmt.Errorf("conflicting changes for file path '%s'", p)
+		}
+		if c.Delete {
+			normalized[p] = treeChange{delete: true}
+			continue
+		}
+		hash, err := writeBlob(s, c.Content)
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+		normalized[p] = treeChange{hash: hash}
+	}
+	return writeTreeChanges(s, base, normalized)
+}
+
+// treeChange is a change to an entry of a tree. An entry with an empty mode
+// is written as a regular file, keeping the executable bit of the existing
+// entry.
+type treeChange struct {
+	delete bool
+	mode   filemode.FileMode
+	hash   plumbing.Hash
+}
+
+// writeTreeChanges writes the tree resulting from applying the changes, keyed
+// by clean path, to the base tree to the storer, and returns its hash.
+func writeTreeChanges(s storage.Storer, base *object.Tree, changes map[string]treeChange) (plumbing.Hash, error) {
+	hash, ok, err := writeSubtree(s, base, "", changes)
+	if err != nil || ok {
+		return hash, err
+	}
+
+	// The root tree is written even when empty.
+	obj := s.NewEncodedObject()
+	if err := (&object.Tree{}).Encode(obj); err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return s.SetEncodedObject(obj)
+}
+
+// writeSubtree applies the changes, keyed by path relative to the base tree
+// at dir, and writes the resulting tree to the storer. It returns false if
+// the resulting tree is empty, in which case nothing is written.
+//
+// Deletions are applied first, then the changes to subtrees and finally the
+// files are written, so that a file can replace a directory whose content is
+// deleted, and the other way around.
+func writeSubtree(s storage.Storer, base *object.Tree, dir string, changes map[string]treeChange) (plumbing.Hash, bool, error) {
+	entries := map[string]object.TreeEntry{}
+	if base != nil {
+		for _, e := range base.Entries {
+			entries[e.Name] = e
+		}
+	}
+
+	subtrees := map[string]map[string]treeChange{}
+	for p, c := range changes {
+		if name, rest, ok := strings.Cut(p, "/"); ok {
+			if subtrees[name] == nil {
+				subtrees[name] = map[string]treeChange{}
+			}
+			subtrees[name][rest] = c
+			continue
+		}
+		if c.delete {
+			delete(entries, p)
+		}
+	}
+
+	for name, subChanges := range subtrees {
+		var subBase *object.Tree
+		if e, ok := entries[name]; ok {
+			if e.Mode != filemode.Dir {
+				return plumbing.ZeroHash, false, fmt.Errorf("unable to write below file path '%s': not a directory", path.Join(dir, name))
+			}
+			t, err := object.GetTree(s, e.Hash)
+			if err != nil {
+				return plumbing.ZeroHash, false, fmt.Errorf("unable to read tree '%s': %w", path.Join(dir, name), err)
+			}
+			subBase = t
+		}
+		hash, ok, err := writeSubtree(s, subBase, path.Join(dir, name), subChanges)
+		if err != nil {
+			return plumbing.ZeroHash, false, err
+		}
+		if !ok {
+			delete(entries, name)
+			continue
+		}
+		entries[name] = object.TreeEntry{Name: name, Mode: filemode.Dir, Hash: hash}
+	}
+
+	for p, c := range changes {
+		if c.delete || strings.Contains(p, "/") {
+			continue
+		}
+		mode := c.mode
+		if e, ok := entries[p]; ok {
+			if e.Mode == filemode.Dir {
+				return plumbing.ZeroHash, false, fmt.Errorf("unable to write file path '%s': is a directory", path.Join(dir, p))
+			}
+			if mode == filemode.Empty && e.Mode == filemode.Executable {
+				mode = filemode.Executable
+			}
+		}
+		if mode == filemode.Empty {
+			mode = filemode.Regular
+		}
+		entries[p] = object.TreeEntry{Name: p, Mode: mode, Hash: c.hash}
+	}
+
+	if len(entries) == 0 {
+		return plumbing.ZeroHash, false, nil
+	}
+
+	tree := &object.Tree{}
+	for _, e := range entries {
+		tree.Entries = append(tree.Entries, e)
+	}
+	// Git sorts tree entries by name, with directories compared as if their
+	// name had a trailing slash.
+	sort.Slice(tree.Entries, func(i, j int) bool {
+		return treeSortKey(tree.Entries[i]) < treeSortKey(tree.Entries[j])
+	})
+
+	obj := s.NewEncodedObject()
+	if err := tree.Encode(obj); err != nil {
+		return plumbing.ZeroHash, false, err
+	}
+	hash, err := s.SetEncodedObject(obj)
+	if err != nil {
+		return plumbing.ZeroHash, false, err
+	}
+	return hash, true, nil
+}
+
+func treeSortKey(e object.TreeEntry) string {
+	if e.Mode == filemode.Dir {
+		return e.Name + "/"
+	}
+	return e.Name
+}
+
+func writeBlob(s storage.Storer, content []byte) (plumbing.Hash, error) {
+	obj := s.NewEncodedObject()
+	obj.SetType(plumbing.BlobObject)
+	obj.SetSize(int64(len(content)))
+	w, err := obj.Writer()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	if _, err := w.Write(content); err != nil {
+		w.Close()
+		return plumbing.ZeroHash, err
+	}
+	if err := w.Close(); err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return s.SetEncodedObject(obj)
+}
+
+// cleanTreePath returns the cleaned path, or an error if the path is empty,
+// absolute, points outside the repository or into the .git directory.
+func cleanTreePath(p string) (string, error) {
+	cleaned := path.Clean(p)
+	if p == "" || cleaned == "." || path.IsAbs(cleaned) || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", fmt.Errorf("invalid file path '%s'", p)
+	}
+	for _, elem := range strings.Split(cleaned, "/") {
+		if strings.EqualFold(elem, ".git") {
+			return "", fmt.Errorf("invalid file path '%s'", p)
+		}
+	}
+	return cleaned, nil
+}
+
+// writeCommit writes a commit of the tree with the given parents to the
+// storer, and returns its hash. The commit is built by go-git on an in-memory
+// repository whose index holds the entries of the tree, so that it is signed
+// the same way as the commits made through the worktree.
+func writeCommit(s storage.Storer, treeHash plumbing.Hash, parents []plumbing.Hash, message string,
+	author, committer object.Signature, signer *openpgp.Entity) (plumbing.Hash, error) {
+	tree, err := object.GetTree(s, treeHash)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	idx := &index.Index{Version: 2}
+	walker := object.NewTreeWalker(tree, true, nil)
+	defer walker.Close()
+	for {
+		name, e, err := walker.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+		if e.Mode == filemode.Dir {
+			continue
+		}
+		idx.Entries = append(idx.Entries, &index.Entry{Name: name, Mode: e.Mode, Hash: e.Hash})
+	}
+
+	repo, err := extgogit.Init(memory.NewStorage(), memfs.New())
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	if err := repo.Storer.SetIndex(idx); err != nil {
+		return plumbing.ZeroHash, err
+	}
+	wt, err := repo.Worktree()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	hash, err := wt.Commit(message, &extgogit.CommitOptions{
+		Author:            &author,
+		Committer:         &committer,
+		Parents:           parents,
+		SignKey:           signer,
+		AllowEmptyCommits: true,
+	})
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	obj, err := repo.Storer.EncodedObject(plumbing.CommitObject, hash)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	commit, err := object.DecodeCommit(repo.Storer, obj)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	if commit.TreeHash != treeHash {
+		return plumbing.ZeroHash, fmt.Errorf("unexpected tree '%s' for commit, expected '%s'", commit.TreeHash, treeHash)
+	}
+	return s.SetEncodedObject(obj)
+}
@@ -3,6 +3,7 @@ module github.com/fluxcd/pkg/git/internal/e2e
 go 1.20
 
 replace (
+	github.com/fluxcd/pkg/cache => ../../../cache
 	github.com/fluxcd/pkg/git => ../../../git
 	github.com/fluxcd/pkg/git/gogit => ../../gogit
 	github.com/fluxcd/pkg/gittestserver => ../../../gittestserver
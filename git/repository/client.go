@@ -29,6 +29,10 @@ type Reader interface {
 	// It returns a Commit object describing the Git commit that the repository
 	// HEAD points to. If the repository is empty, it returns a nil Commit.
 	Clone(ctx context.Context, url string, cfg CloneConfig) (*git.Commit, error)
+	// ListReferences lists the references of the remote repository at the
+	// provided url without cloning it, using the config provided.
+	// The returned references are sorted by name.
+	ListReferences(ctx context.Context, url string, cfg ListReferencesConfig) ([]git.Reference, error)
 	// IsClean returns whether the working tree is clean.
 	IsClean() (bool, error)
 	// Head returns the hash of the current HEAD of the repo.
@@ -57,6 +61,16 @@ type Writer interface {
 	Closer
 }
 
+// FileCommitter knows how to commit file changes to a branch of a Git
+// repository without making use of the working tree.
+type FileCommitter interface {
+	// CommitFiles creates a commit with the provided file changes on top of
+	// the provided branch, along with the files of the commit options. If the
+	// branch doesn't exist, it is created from HEAD. Only the branch
+	// reference is updated, the working tree is left untouched.
+	CommitFiles(info git.Commit, branch string, changes []FileChange, commitOpts ...CommitOption) (string, error)
+}
+
 // Closer knows how to perform any operations that need to happen
 // at the end of the lifecycle of a Writer/Reader.
 // When this is not required by the implementation, it can simply embed an
@@ -17,9 +17,12 @@ limitations under the License.
 package repository
 
 import (
+	"context"
 	"io"
 
 	"github.com/ProtonMail/go-crypto/openpgp"
+
+	"github.com/fluxcd/pkg/git"
 )
 
 const (
@@ -51,6 +54,55 @@ type CloneConfig struct {
 	// ShallowClone defines if the repository should be shallow cloned,
 	// not supported by all implementations
 	ShallowClone bool
+
+	// Submodules provides fine-grained control over the checkout of
+	// submodules. If set, it takes precedence over RecurseSubmodules,
+	// not supported by all implementations.
+	Submodules *SubmoduleConfig
+}
+
+// DefaultSubmoduleMaxDepth is the maximum depth of nested submodules that
+// are checked out if SubmoduleConfig.MaxDepth is not set.
+const DefaultSubmoduleMaxDepth = 10
+
+// SubmoduleConfig provides configuration options for the recursive
+// checkout of submodules.
+type SubmoduleConfig struct {
+	// MaxDepth is the maximum depth of nested submodules to checkout,
+	// with 1 only checking out the submodules of the cloned repository.
+	// Defaults to DefaultSubmoduleMaxDepth.
+	MaxDepth int
+
+	// Allow is a list of patterns submodule URLs must match to be checked
+	// out. The patterns are matched against the host and path of the URL
+	// without the ".git" suffix (e.g. "github.com/org/repo"), using the
+	// syntax of path.Match. If empty, all submodules are allowed.
+	Allow []string
+
+	// Deny is a list of patterns, submodules with a URL matching any of
+	// them are not checked out. It takes precedence over Allow.
+	Deny []string
+
+	// Credentials resolves the auth options for the submodule at the given
+	// URL. If it is not set, or returns nil auth options, the credentials of
+	// the cloned repository are used for submodules served over the same
+	// transport by the same host, and no credentials are used otherwise.
+	Credentials SubmoduleCredentialsFunc
+}
+
+// SubmoduleCredentialsFunc returns the auth options to use for the
+// submodule at the given URL.
+type SubmoduleCredentialsFunc func(ctx context.Context, url string) (*git.AuthOptions, error)
+
+// ListReferencesConfig provides configuration options for listing the
+// references of a remote Git repository. If neither Branches nor Tags
+// is set, all references advertised by the remote are listed.
+type ListReferencesConfig struct {
+	// Branches defines if branches ('refs/heads/*') should be listed.
+	Branches bool
+
+	// Tags defines if tags ('refs/tags/*') should be listed.
+	Tags bool
 }
 
 // PushConfig provides configuration options for a Git push.
@@ -67,8 +119,41 @@ type PushConfig struct {
 	// to the Git server when performing a push option. For details, see:
 	// https://git-scm.com/docs/git-push#Documentation/git-push.txt---push-optionltoptiongt
 	Options map[string]string
+
+	// RetryStrategy defines how a push rejected because the remote branch
+	// has been updated since it was fetched is retried. Defaults to
+	// PushRetryFail. Retries are only supported when pushing a single branch.
+	RetryStrategy PushRetryStrategy
+
+	// RetryAttempts is the maximum number of times the push is retried.
+	// Defaults to DefaultPushRetryAttempts.
+	RetryAttempts int
+
+	// Signer is used to sign the replayed copies of signed local commits
+	// when the push is retried. Signed commits are not replayed without it.
+	Signer *openpgp.Entity
 }
 
+// DefaultPushRetryAttempts is the default maximum number of push retries.
+const DefaultPushRetryAttempts = 3
+
+// PushRetryStrategy defines how a rejected push is retried.
+type PushRetryStrategy string
+
+const (
+	// PushRetryFail does not retry the push, which fails with
+	// git.ErrRemoteRefMoved.
+	PushRetryFail PushRetryStrategy = "fail"
+	// PushRetryRebase fetches the remote branch and replays the local
+	// commits on top of it. It fails with git.ErrRebaseConflict if a file
+	// changed by the local commits has also been changed on the remote.
+	PushRetryRebase PushRetryStrategy = "rebase"
+	// PushRetryOurs fetches the remote branch and replays the local commits
+	// on top of it. The local version of files changed on both sides takes
+	// precedence.
+	PushRetryOurs PushRetryStrategy = "ours"
+)
+
 // CheckoutStrategy provides options to checkout a repository to a target.
 type CheckoutStrategy struct {
 	// Branch to checkout. If supported by the client, it can be combined
@@ -81,6 +166,10 @@ type CheckoutStrategy struct {
 	// SemVer tag expression to checkout, takes precedence over Branch and Tag.
 	SemVer string `json:"semver,omitempty"`
 
+	// SemVerFilter further restricts the tags that are taken into account
+	// when resolving SemVer. It has no effect if SemVer is not set.
+	SemVerFilter *SemVerFilter `json:"semverFilter,omitempty"`
+
 	// RefName is the reference to checkout to. It must conform to the
 	// Git reference format: https://git-scm.com/book/en/v2/Git-Internals-Git-References
 	// Examples: "refs/heads/main", "refs/pull/420/head", "refs/tags/v0.1.0"
@@ -92,6 +181,43 @@ type CheckoutStrategy struct {
 	Commit string
 }
 
+// TagPreference defines which kind of tag is preferred when multiple tags
+// resolve to the same version.
+type TagPreference string
+
+const (
+	// TagPreferenceNone does not prefer any kind of tag, ties are resolved
+	// using the commit timestamp of the tags.
+	TagPreferenceNone TagPreference = ""
+	// TagPreferenceAnnotated prefers annotated tags over lightweight tags.
+	TagPreferenceAnnotated TagPreference = "annotated"
+	// TagPreferenceLightweight prefers lightweight tags over annotated tags.
+	TagPreferenceLightweight TagPreference = "lightweight"
+)
+
+// SemVerFilter provides options to filter the candidate tags of a SemVer
+// checkout.
+type SemVerFilter struct {
+	// Include is a regular expression that tag names must match to be
+	// considered. If empty, all tags are considered.
+	Include string `json:"include,omitempty"`
+
+	// Exclude is a regular expression, tags with a name matching it are
+	// not considered. It takes precedence over Include.
+	Exclude string `json:"exclude,omitempty"`
+
+	// IncludePrereleases allows prerelease versions to be selected, even when
+	// the SemVer expression does not contain a prerelease itself. In which
+	// case, a prerelease is checked against the expression as if it was the
+	// release version it precedes.
+	IncludePrereleases bool `json:"includePrereleases,omitempty"`
+
+	// TagPreference defines which kind of tag is preferred when multiple
+	// tags resolve to the same version, before falling back to the commit
+	// timestamp.
+	TagPreference TagPreference `json:"tagPreference,omitempty"`
+}
+
 // CommitOptions provides options to configure a Git commit operation.
 type CommitOptions struct {
 	// Signer can be used to sign a commit using OpenPGP.
@@ -99,6 +225,23 @@ type CommitOptions struct {
 	// Files contains file names mapped to the file's content.
 	// Its used to write files which are then included in the commit.
 	Files map[string]io.Reader
+	// MessageTemplate is a Go template rendered with TemplateData to
+	// construct the commit message, instead of using the message of the
+	// git.Commit as is.
+	MessageTemplate string
+	// TemplateData holds the data passed to MessageTemplate.
+	TemplateData CommitTemplateData
+}
+
+// FileChange describes a change to a file in a Git repository.
+type FileChange struct {
+	// Path is the slash-separated path of the file, relative to the root of
+	// the repository.
+	Path string
+	// Content is the new content of the file. It is ignored if Delete is set.
+	Content []byte
+	// Delete instructs the file to be removed from the repository.
+	Delete bool
 }
 
 // CommitOption defines an option for a commit operation.
@@ -121,3 +264,14 @@ func WithFiles(files map[string]io.Reader) CommitOption {
 		co.Files = files
 	}
 }
+
+// WithMessageTemplate instructs the Git client to construct the commit
+// message by rendering the provided Go template with the given data.
+// The message of the git.Commit and the changed files are available in
+// the template as .Message and .Changed, see CommitTemplateData.
+func WithMessageTemplate(tmpl string, data CommitTemplateData) CommitOption {
+	return func(co *CommitOptions) {
+		co.MessageTemplate = tmpl
+		co.TemplateData = data
+	}
+}
@@ -0,0 +1,94 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package repository
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// CommitTemplateData holds the data made available to commit message
+// templates, e.g. "Update {{ len .Changed }} files to {{ .Policies.app }}".
+type CommitTemplateData struct {
+	// Message is the message of the git.Commit given to the Git client.
+	Message string
+	// Changed holds the slash-separated paths of the files changed by the
+	// commit, sorted. It is populated by the Git client if left empty.
+	Changed []string
+	// Policies maps the names of the policies which resulted in the changes,
+	// e.g. image policies, to their latest result, e.g. an image reference.
+	Policies map[string]string
+	// Revisions maps the names of the sources the changes are based on
+	// to their revisions, e.g. 'main@sha1:<hash>'.
+	Revisions map[string]string
+	// Values holds arbitrary values set by the caller.
+	Values map[string]string
+}
+
+// commitTemplateFuncs are the functions available to commit message templates
+// in addition to the text/template builtins.
+var commitTemplateFuncs = template.FuncMap{
+	"join":       strings.Join,
+	"lower":      strings.ToLower,
+	"upper":      strings.ToUpper,
+	"trim":       strings.TrimSpace,
+	"trimPrefix": func(prefix, s string) string { return strings.TrimPrefix(s, prefix) },
+	"trimSuffix": func(suffix, s string) string { return strings.TrimSuffix(s, suffix) },
+	"keys": func(m map[string]string) []string {
+		keys := make([]string, 0, len(m))
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		return keys
+	},
+}
+
+// RenderCommitMessage executes the given Go template with the data and
+// returns the resulting commit message. Referencing a missing map key
+// results in an error.
+func RenderCommitMessage(tmpl string, data CommitTemplateData) (string, error) {
+	t, err := template.New("commit").Funcs(commitTemplateFuncs).Option("missingkey=error").Parse(tmpl)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse commit message template: %w", err)
+	}
+
+	var b strings.Builder
+	if err := t.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("failed to execute commit message template: %w", err)
+	}
+	return b.String(), nil
+}
+
+// RenderMessage returns the commit message rendered from the MessageTemplate,
+// using the given message and changed files to populate the template data,
+// or the message unmodified if no template is set.
+func (o *CommitOptions) RenderMessage(message string, changed []string) (string, error) {
+	if o.MessageTemplate == "" {
+		return message, nil
+	}
+
+	data := o.TemplateData
+	data.Message = message
+	if len(data.Changed) == 0 {
+		data.Changed = append([]string(nil), changed...)
+		sort.Strings(data.Changed)
+	}
+	return RenderCommitMessage(o.MessageTemplate, data)
+}
@@ -0,0 +1,123 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package git
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SSHAlgorithms holds the cryptographic algorithms used by SSH
+// connections. An empty list means no restriction is put in place
+// for that kind of algorithm.
+type SSHAlgorithms struct {
+	// KeyExchanges holds the key exchange algorithms.
+	KeyExchanges []string
+	// Ciphers holds the cipher algorithms.
+	Ciphers []string
+	// MACs holds the message authentication code algorithms.
+	MACs []string
+	// HostKeyAlgorithms holds the host key algorithms.
+	HostKeyAlgorithms []string
+}
+
+// FIPSSSHAlgorithms holds the SSH algorithms approved by FIPS 140-3
+// that are supported by the SSH client.
+var FIPSSSHAlgorithms = SSHAlgorithms{
+	KeyExchanges: []string{
+		"ecdh-sha2-nistp256",
+		"ecdh-sha2-nistp384",
+		"ecdh-sha2-nistp521",
+		"diffie-hellman-group14-sha256",
+		"diffie-hellman-group16-sha512",
+	},
+	Ciphers: []string{
+		"aes128-gcm@openssh.com",
+		"aes256-gcm@openssh.com",
+		"aes128-ctr",
+		"aes192-ctr",
+		"aes256-ctr",
+	},
+	MACs: []string{
+		"hmac-sha2-256-etm@openssh.com",
+		"hmac-sha2-512-etm@openssh.com",
+		"hmac-sha2-256",
+		"hmac-sha2-512",
+	},
+	HostKeyAlgorithms: []string{
+		"ecdsa-sha2-nistp256",
+		"ecdsa-sha2-nistp384",
+		"ecdsa-sha2-nistp521",
+		"rsa-sha2-256",
+		"rsa-sha2-512",
+	},
+}
+
+// SSHAlgorithmPolicy holds the algorithms SSH connections are allowed
+// to use. It takes precedence over KexAlgos and HostKeyAlgos, which
+// are restricted to the algorithms allowed by the policy.
+// If nil, no restriction is put in place.
+var SSHAlgorithmPolicy *SSHAlgorithms
+
+// Restrict returns the given algorithms restricted to the ones allowed
+// by a. Algorithms that are not set in requested default to the ones
+// allowed by a. It returns an error if none of the requested algorithms
+// of a kind is allowed.
+func (a SSHAlgorithms) Restrict(requested SSHAlgorithms) (SSHAlgorithms, error) {
+	var result SSHAlgorithms
+	var err error
+	if result.KeyExchanges, err = restrictAlgorithms("key exchange", requested.KeyExchanges, a.KeyExchanges); err != nil {
+		return result, err
+	}
+	if result.Ciphers, err = restrictAlgorithms("cipher", requested.Ciphers, a.Ciphers); err != nil {
+		return result, err
+	}
+	if result.MACs, err = restrictAlgorithms("MAC", requested.MACs, a.MACs); err != nil {
+		return result, err
+	}
+	if result.HostKeyAlgorithms, err = restrictAlgorithms("host key", requested.HostKeyAlgorithms, a.HostKeyAlgorithms); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// restrictAlgorithms returns the requested algorithms that are
+// allowed, preserving the requested order of preference.
+func restrictAlgorithms(kind string, requested, allowed []string) ([]string, error) {
+	if len(allowed) == 0 {
+		return requested, nil
+	}
+	if len(requested) == 0 {
+		return allowed, nil
+	}
+
+	permitted := make(map[string]struct{}, len(allowed))
+	for _, algo := range allowed {
+		permitted[algo] = struct{}{}
+	}
+	var result []string
+	for _, algo := range requested {
+		if _, ok := permitted[algo]; ok {
+			result = append(result, algo)
+		}
+	}
+	if len(result) == 0 {
+		return nil, fmt.Errorf("none of the %s algorithms [%s] is allowed by the SSH algorithm policy",
+			kind, strings.Join(requested, ", "))
+	}
+	return result, nil
+}
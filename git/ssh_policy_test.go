@@ -0,0 +1,78 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package git
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestSSHAlgorithms_Restrict(t *testing.T) {
+	tests := []struct {
+		name      string
+		policy    SSHAlgorithms
+		requested SSHAlgorithms
+		want      SSHAlgorithms
+		wantErr   string
+	}{
+		{
+			name:      "empty policy keeps requested algorithms",
+			requested: SSHAlgorithms{KeyExchanges: []string{"curve25519-sha256"}},
+			want:      SSHAlgorithms{KeyExchanges: []string{"curve25519-sha256"}},
+		},
+		{
+			name:   "empty request defaults to policy",
+			policy: FIPSSSHAlgorithms,
+			want:   FIPSSSHAlgorithms,
+		},
+		{
+			name:   "request is restricted in order of preference",
+			policy: FIPSSSHAlgorithms,
+			requested: SSHAlgorithms{
+				KeyExchanges:      []string{"curve25519-sha256", "ecdh-sha2-nistp384", "ecdh-sha2-nistp256"},
+				HostKeyAlgorithms: []string{"ssh-ed25519", "rsa-sha2-512"},
+			},
+			want: SSHAlgorithms{
+				KeyExchanges:      []string{"ecdh-sha2-nistp384", "ecdh-sha2-nistp256"},
+				Ciphers:           FIPSSSHAlgorithms.Ciphers,
+				MACs:              FIPSSSHAlgorithms.MACs,
+				HostKeyAlgorithms: []string{"rsa-sha2-512"},
+			},
+		},
+		{
+			name:      "no allowed algorithm",
+			policy:    FIPSSSHAlgorithms,
+			requested: SSHAlgorithms{Ciphers: []string{"chacha20-poly1305@openssh.com"}},
+			wantErr:   "none of the cipher algorithms [chacha20-poly1305@openssh.com] is allowed by the SSH algorithm policy",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			got, err := tt.policy.Restrict(tt.requested)
+			if tt.wantErr != "" {
+				g.Expect(err).To(MatchError(tt.wantErr))
+				return
+			}
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(got).To(Equal(tt.want))
+		})
+	}
+}
@@ -0,0 +1,86 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// azureDevOpsPushEvent is the payload of Azure DevOps 'git.push' service
+// hook events.
+type azureDevOpsPushEvent struct {
+	EventType string `json:"eventType"`
+	Resource  struct {
+		RefUpdates []struct {
+			Name        string `json:"name"`
+			OldObjectID string `json:"oldObjectId"`
+			NewObjectID string `json:"newObjectId"`
+		} `json:"refUpdates"`
+		Repository struct {
+			Name      string `json:"name"`
+			RemoteURL string `json:"remoteUrl"`
+			SSHURL    string `json:"sshUrl"`
+			WebURL    string `json:"webUrl"`
+			Project   struct {
+				Name string `json:"name"`
+			} `json:"project"`
+		} `json:"repository"`
+		PushedBy struct {
+			UniqueName string `json:"uniqueName"`
+		} `json:"pushedBy"`
+	} `json:"resource"`
+}
+
+// verifyAzureDevOps verifies the basic authentication password of the
+// request, as Azure DevOps service hooks do not sign their payloads.
+func verifyAzureDevOps(header http.Header, _ []byte, secret []byte) error {
+	_, password, ok := (&http.Request{Header: header}).BasicAuth()
+	if !ok {
+		return fmt.Errorf("%w: missing basic authentication", ErrInvalidSignature)
+	}
+	return verifyToken(password, secret)
+}
+
+func parseAzureDevOps(_ http.Header, body []byte) (*Event, error) {
+	var payload azureDevOpsPushEvent
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("failed to decode payload: %w", err)
+	}
+	if payload.EventType != "git.push" {
+		return nil, fmt.Errorf("%w '%s'", ErrUnsupportedEvent, payload.EventType)
+	}
+
+	r := payload.Resource.Repository
+	event := &Event{
+		Type: EventTypePush,
+		Repository: Repository{
+			Name: r.Project.Name + "/" + r.Name,
+			URLs: urls(r.RemoteURL, r.SSHURL, r.WebURL),
+		},
+		Sender: payload.Resource.PushedBy.UniqueName,
+	}
+	for _, u := range payload.Resource.RefUpdates {
+		event.Changes = append(event.Changes, Change{
+			Ref:    u.Name,
+			Before: normalizeCommit(u.OldObjectID),
+			After:  normalizeCommit(u.NewObjectID),
+		})
+	}
+	return event, nil
+}
@@ -0,0 +1,174 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// bitbucketPushEvent is the payload of Bitbucket Cloud push events.
+type bitbucketPushEvent struct {
+	Push struct {
+		Changes []struct {
+			New *bitbucketRef `json:"new"`
+			Old *bitbucketRef `json:"old"`
+		} `json:"changes"`
+	} `json:"push"`
+	Repository struct {
+		FullName string `json:"full_name"`
+		Links    struct {
+			HTML struct {
+				Href string `json:"href"`
+			} `json:"html"`
+		} `json:"links"`
+	} `json:"repository"`
+	Actor struct {
+		Nickname string `json:"nickname"`
+	} `json:"actor"`
+}
+
+// bitbucketRef is the state of a reference in a Bitbucket Cloud push event.
+type bitbucketRef struct {
+	Type   string `json:"type"`
+	Name   string `json:"name"`
+	Target struct {
+		Hash string `json:"hash"`
+	} `json:"target"`
+}
+
+// fullName returns the full name of the reference.
+func (r *bitbucketRef) fullName() string {
+	if r.Type == "branch" {
+		return "refs/heads/" + r.Name
+	}
+	return "refs/tags/" + r.Name
+}
+
+// bitbucketServerEvent is the payload of Bitbucket Server events.
+type bitbucketServerEvent struct {
+	Changes []struct {
+		RefID    string `json:"refId"`
+		FromHash string `json:"fromHash"`
+		ToHash   string `json:"toHash"`
+	} `json:"changes"`
+	Repository *struct {
+		Slug    string `json:"slug"`
+		Project struct {
+			Key string `json:"key"`
+		} `json:"project"`
+		Links struct {
+			Clone []struct {
+				Href string `json:"href"`
+			} `json:"clone"`
+			Self []struct {
+				Href string `json:"href"`
+			} `json:"self"`
+		} `json:"links"`
+	} `json:"repository"`
+	Actor struct {
+		Name string `json:"name"`
+	} `json:"actor"`
+}
+
+func verifyBitbucket(header http.Header, body []byte, secret []byte) error {
+	signature, ok := strings.CutPrefix(header.Get("X-Hub-Signature"), "sha256=")
+	if !ok {
+		return fmt.Errorf("%w: missing signature", ErrInvalidSignature)
+	}
+	return verifyHMAC(signature, body, secret)
+}
+
+func parseBitbucket(header http.Header, body []byte) (*Event, error) {
+	switch eventType := header.Get("X-Event-Key"); eventType {
+	case "repo:push":
+		var payload bitbucketPushEvent
+		if err := json.Unmarshal(body, &payload); err != nil {
+			return nil, fmt.Errorf("failed to decode payload: %w", err)
+		}
+		event := &Event{
+			Type:   EventTypePush,
+			Sender: payload.Actor.Nickname,
+			Repository: Repository{
+				Name: payload.Repository.FullName,
+			},
+		}
+		if href := payload.Repository.Links.HTML.Href; href != "" {
+			event.Repository.URLs = urls(href, href+".git")
+		}
+		for _, c := range payload.Push.Changes {
+			var change Change
+			if c.Old != nil {
+				change.Ref = c.Old.fullName()
+				change.Before = c.Old.Target.Hash
+			}
+			if c.New != nil {
+				change.Ref = c.New.fullName()
+				change.After = c.New.Target.Hash
+			}
+			if change.Ref == "" {
+				continue
+			}
+			event.Changes = append(event.Changes, change)
+		}
+		return event, nil
+	default:
+		return nil, fmt.Errorf("%w '%s'", ErrUnsupportedEvent, eventType)
+	}
+}
+
+func parseBitbucketServer(header http.Header, body []byte) (*Event, error) {
+	var eventType EventType
+	switch key := header.Get("X-Event-Key"); key {
+	case "repo:refs_changed":
+		eventType = EventTypePush
+	case "diagnostics:ping":
+		eventType = EventTypePing
+	default:
+		return nil, fmt.Errorf("%w '%s'", ErrUnsupportedEvent, key)
+	}
+
+	var payload bitbucketServerEvent
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("failed to decode payload: %w", err)
+	}
+	event := &Event{
+		Type:   eventType,
+		Sender: payload.Actor.Name,
+	}
+	if r := payload.Repository; r != nil {
+		event.Repository.Name = r.Project.Key + "/" + r.Slug
+		var values []string
+		for _, l := range r.Links.Clone {
+			values = append(values, l.Href)
+		}
+		for _, l := range r.Links.Self {
+			values = append(values, l.Href)
+		}
+		event.Repository.URLs = urls(values...)
+	}
+	for _, c := range payload.Changes {
+		event.Changes = append(event.Changes, Change{
+			Ref:    c.RefID,
+			Before: normalizeCommit(c.FromHash),
+			After:  normalizeCommit(c.ToHash),
+		})
+	}
+	return event, nil
+}
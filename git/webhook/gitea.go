@@ -0,0 +1,37 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"fmt"
+	"net/http"
+)
+
+func verifyGitea(header http.Header, body []byte, secret []byte) error {
+	return verifyHMAC(header.Get("X-Gitea-Signature"), body, secret)
+}
+
+// parseGitea parses Gitea push events, whose payload is compatible with the
+// GitHub one.
+func parseGitea(header http.Header, body []byte) (*Event, error) {
+	switch eventType := header.Get("X-Gitea-Event"); eventType {
+	case "push":
+		return parseGitHubPush(body)
+	default:
+		return nil, fmt.Errorf("%w '%s'", ErrUnsupportedEvent, eventType)
+	}
+}
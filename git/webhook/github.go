@@ -0,0 +1,104 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// githubPushEvent is the payload of GitHub and Gitea push events.
+type githubPushEvent struct {
+	Ref        string `json:"ref"`
+	Before     string `json:"before"`
+	After      string `json:"after"`
+	Repository struct {
+		FullName string `json:"full_name"`
+		CloneURL string `json:"clone_url"`
+		SSHURL   string `json:"ssh_url"`
+		HTMLURL  string `json:"html_url"`
+	} `json:"repository"`
+	Sender struct {
+		Login string `json:"login"`
+	} `json:"sender"`
+}
+
+// githubPingEvent is the payload of GitHub ping events.
+type githubPingEvent struct {
+	Repository *struct {
+		FullName string `json:"full_name"`
+		CloneURL string `json:"clone_url"`
+		SSHURL   string `json:"ssh_url"`
+		HTMLURL  string `json:"html_url"`
+	} `json:"repository"`
+	Sender struct {
+		Login string `json:"login"`
+	} `json:"sender"`
+}
+
+func verifyGitHub(header http.Header, body []byte, secret []byte) error {
+	signature, ok := strings.CutPrefix(header.Get("X-Hub-Signature-256"), "sha256=")
+	if !ok {
+		return fmt.Errorf("%w: missing signature", ErrInvalidSignature)
+	}
+	return verifyHMAC(signature, body, secret)
+}
+
+func parseGitHub(header http.Header, body []byte) (*Event, error) {
+	switch eventType := header.Get("X-GitHub-Event"); eventType {
+	case "push":
+		return parseGitHubPush(body)
+	case "ping":
+		var payload githubPingEvent
+		if err := json.Unmarshal(body, &payload); err != nil {
+			return nil, fmt.Errorf("failed to decode payload: %w", err)
+		}
+		event := &Event{Type: EventTypePing, Sender: payload.Sender.Login}
+		if r := payload.Repository; r != nil {
+			event.Repository = Repository{
+				Name: r.FullName,
+				URLs: urls(r.CloneURL, r.SSHURL, r.HTMLURL),
+			}
+		}
+		return event, nil
+	default:
+		return nil, fmt.Errorf("%w '%s'", ErrUnsupportedEvent, eventType)
+	}
+}
+
+// parseGitHubPush parses the payload of a GitHub or Gitea push event.
+func parseGitHubPush(body []byte) (*Event, error) {
+	var payload githubPushEvent
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("failed to decode payload: %w", err)
+	}
+	return &Event{
+		Type: EventTypePush,
+		Repository: Repository{
+			Name: payload.Repository.FullName,
+			URLs: urls(payload.Repository.CloneURL, payload.Repository.SSHURL, payload.Repository.HTMLURL),
+		},
+		Changes: []Change{{
+			Ref:    payload.Ref,
+			Before: normalizeCommit(payload.Before),
+			After:  normalizeCommit(payload.After),
+		}},
+		Sender: payload.Sender.Login,
+	}, nil
+}
@@ -0,0 +1,67 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// gitlabPushEvent is the payload of GitLab push and tag push events.
+type gitlabPushEvent struct {
+	Ref          string `json:"ref"`
+	Before       string `json:"before"`
+	After        string `json:"after"`
+	UserUsername string `json:"user_username"`
+	Project      struct {
+		PathWithNamespace string `json:"path_with_namespace"`
+		GitHTTPURL        string `json:"git_http_url"`
+		GitSSHURL         string `json:"git_ssh_url"`
+		WebURL            string `json:"web_url"`
+	} `json:"project"`
+}
+
+func verifyGitLab(header http.Header, _ []byte, secret []byte) error {
+	return verifyToken(header.Get("X-Gitlab-Token"), secret)
+}
+
+func parseGitLab(header http.Header, body []byte) (*Event, error) {
+	switch eventType := header.Get("X-Gitlab-Event"); eventType {
+	case "Push Hook", "Tag Push Hook":
+		var payload gitlabPushEvent
+		if err := json.Unmarshal(body, &payload); err != nil {
+			return nil, fmt.Errorf("failed to decode payload: %w", err)
+		}
+		p := payload.Project
+		return &Event{
+			Type: EventTypePush,
+			Repository: Repository{
+				Name: p.PathWithNamespace,
+				URLs: urls(p.GitHTTPURL, p.GitSSHURL, p.WebURL),
+			},
+			Changes: []Change{{
+				Ref:    payload.Ref,
+				Before: normalizeCommit(payload.Before),
+				After:  normalizeCommit(payload.After),
+			}},
+			Sender: payload.UserUsername,
+		}, nil
+	default:
+		return nil, fmt.Errorf("%w '%s'", ErrUnsupportedEvent, eventType)
+	}
+}
@@ -0,0 +1,259 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package webhook provides means to verify and parse the webhook payloads
+// sent by Git providers, and to normalize them into a common Event.
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Provider is the name of a Git provider sending webhooks.
+type Provider string
+
+const (
+	// ProviderGitHub is the name of the GitHub provider.
+	ProviderGitHub Provider = "github"
+	// ProviderGitLab is the name of the GitLab provider.
+	ProviderGitLab Provider = "gitlab"
+	// ProviderBitbucket is the name of the Bitbucket Cloud provider.
+	ProviderBitbucket Provider = "bitbucket"
+	// ProviderBitbucketServer is the name of the Bitbucket Server (and Data
+	// Center) provider.
+	ProviderBitbucketServer Provider = "bitbucketserver"
+	// ProviderAzureDevOps is the name of the Azure DevOps provider.
+	ProviderAzureDevOps Provider = "azuredevops"
+	// ProviderGitea is the name of the Gitea (and Forgejo) provider.
+	ProviderGitea Provider = "gitea"
+)
+
+// EventType is the type of a normalized webhook Event.
+type EventType string
+
+const (
+	// EventTypePush is the type of the events sent when references of a
+	// repository are created, updated or deleted.
+	EventTypePush EventType = "push"
+	// EventTypePing is the type of the events sent by providers to test the
+	// webhook configuration.
+	EventTypePing EventType = "ping"
+)
+
+// DefaultMaxPayloadSize is the default maximum size in bytes of the payloads
+// read by Parse.
+const DefaultMaxPayloadSize = 25 << 20
+
+var (
+	// ErrMissingSecret is returned when no secret is given to verify a
+	// payload.
+	ErrMissingSecret = errors.New("webhook secret is required")
+	// ErrInvalidSignature is returned when the signature or token of a
+	// payload is missing, or does not match the secret.
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	// ErrUnsupportedEvent is returned for the events which can not be
+	// normalized into an Event.
+	ErrUnsupportedEvent = errors.New("unsupported webhook event")
+	// ErrUnsupportedProvider is returned for unknown providers.
+	ErrUnsupportedProvider = errors.New("unsupported webhook provider")
+	// ErrPayloadTooLarge is returned by Parse when the payload is larger
+	// than the maximum size.
+	ErrPayloadTooLarge = errors.New("webhook payload too large")
+)
+
+// Event is a webhook payload normalized across providers.
+type Event struct {
+	// Provider is the provider which sent the event.
+	Provider Provider
+	// Type is the type of the event.
+	Type EventType
+	// Repository is the repository the event originates from.
+	Repository Repository
+	// Changes are the references changed by a push event.
+	Changes []Change
+	// Sender is the name of the user which triggered the event, if any.
+	Sender string
+}
+
+// Repository describes the repository of an Event.
+type Repository struct {
+	// Name is the full name of the repository, e.g. 'org/repo'. The format
+	// of the name is specific to the provider.
+	Name string
+	// URLs are the clone and web URLs of the repository, which receivers
+	// can match against the URLs of their sources.
+	URLs []string
+}
+
+// Change is a reference changed by a push event.
+type Change struct {
+	// Ref is the full name of the reference, e.g. 'refs/heads/main'.
+	Ref string
+	// Before is the commit the reference pointed to before the push. It is
+	// empty if the reference was created.
+	Before string
+	// After is the commit the reference points to after the push. It is
+	// empty if the reference was deleted.
+	After string
+}
+
+// Branch returns the name of the branch of the change, or an empty string if
+// the reference is not a branch.
+func (c Change) Branch() string {
+	if branch, ok := strings.CutPrefix(c.Ref, "refs/heads/"); ok {
+		return branch
+	}
+	return ""
+}
+
+// Tag returns the name of the tag of the change, or an empty string if the
+// reference is not a tag.
+func (c Change) Tag() string {
+	if tag, ok := strings.CutPrefix(c.Ref, "refs/tags/"); ok {
+		return tag
+	}
+	return ""
+}
+
+// Created returns true if the reference was created.
+func (c Change) Created() bool {
+	return c.Before == "" && c.After != ""
+}
+
+// Deleted returns true if the reference was deleted.
+func (c Change) Deleted() bool {
+	return c.After == ""
+}
+
+// Parse reads the payload of the webhook request, and verifies and parses it
+// with ParsePayload. At most DefaultMaxPayloadSize bytes are read.
+func Parse(r *http.Request, provider Provider, secret []byte) (*Event, error) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, DefaultMaxPayloadSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read webhook payload: %w", err)
+	}
+	if len(body) > DefaultMaxPayloadSize {
+		return nil, ErrPayloadTooLarge
+	}
+	return ParsePayload(provider, r.Header, body, secret)
+}
+
+// ParsePayload verifies the payload of a webhook sent by the given provider
+// against the secret, and normalizes it into an Event.
+//
+// The secret is verified as follows:
+//   - GitHub: HMAC-SHA256 signature in the 'X-Hub-Signature-256' header.
+//   - GitLab: token in the 'X-Gitlab-Token' header.
+//   - Bitbucket Cloud and Server: HMAC-SHA256 signature in the
+//     'X-Hub-Signature' header.
+//   - Azure DevOps: password of the basic authentication of the request.
+//   - Gitea: HMAC-SHA256 signature in the 'X-Gitea-Signature' header.
+//
+// It returns ErrMissingSecret if the secret is empty, ErrInvalidSignature if
+// the payload can not be verified, and ErrUnsupportedEvent for events other
+// than push, tag and ping events.
+func ParsePayload(provider Provider, header http.Header, body []byte, secret []byte) (*Event, error) {
+	if len(secret) == 0 {
+		return nil, ErrMissingSecret
+	}
+
+	var parse func(header http.Header, body []byte) (*Event, error)
+	var verify func(header http.Header, body []byte, secret []byte) error
+	switch provider {
+	case ProviderGitHub:
+		parse, verify = parseGitHub, verifyGitHub
+	case ProviderGitLab:
+		parse, verify = parseGitLab, verifyGitLab
+	case ProviderBitbucket:
+		parse, verify = parseBitbucket, verifyBitbucket
+	case ProviderBitbucketServer:
+		parse, verify = parseBitbucketServer, verifyBitbucket
+	case ProviderAzureDevOps:
+		parse, verify = parseAzureDevOps, verifyAzureDevOps
+	case ProviderGitea:
+		parse, verify = parseGitea, verifyGitea
+	default:
+		return nil, fmt.Errorf("%w '%s'", ErrUnsupportedProvider, provider)
+	}
+
+	if err := verify(header, body, secret); err != nil {
+		return nil, err
+	}
+	event, err := parse(header, body)
+	if err != nil {
+		return nil, err
+	}
+	event.Provider = provider
+	return event, nil
+}
+
+// verifyHMAC verifies the hex encoded HMAC-SHA256 signature of the body.
+func verifyHMAC(signature string, body []byte, secret []byte) error {
+	if signature == "" {
+		return fmt.Errorf("%w: missing signature", ErrInvalidSignature)
+	}
+	got, err := hex.DecodeString(signature)
+	if err != nil {
+		return fmt.Errorf("%w: malformed signature", ErrInvalidSignature)
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	if !hmac.Equal(got, mac.Sum(nil)) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// verifyToken compares the token with the secret in constant time.
+func verifyToken(token string, secret []byte) error {
+	if token == "" {
+		return fmt.Errorf("%w: missing token", ErrInvalidSignature)
+	}
+	if !hmac.Equal([]byte(token), secret) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// normalizeCommit returns an empty string for the zero object IDs used by
+// providers for created and deleted references.
+func normalizeCommit(id string) string {
+	if strings.Trim(id, "0") == "" {
+		return ""
+	}
+	return id
+}
+
+// urls returns the non-empty URLs, without duplicates.
+func urls(values ...string) []string {
+	var result []string
+	seen := make(map[string]bool, len(values))
+	for _, v := range values {
+		if v == "" || seen[v] {
+			continue
+		}
+		seen[v] = true
+		result = append(result, v)
+	}
+	return result
+}
@@ -0,0 +1,266 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+const testCommit = "8c3b5b3e1a0b6f5b9a1d5c1e5e2b4a3c2d1e0f9a"
+
+func sign(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestParsePayload(t *testing.T) {
+	secret := []byte("secret")
+
+	tests := []struct {
+		name       string
+		provider   Provider
+		body       string
+		header     func(body []byte) http.Header
+		wantType   EventType
+		wantRepo   Repository
+		wantChange []Change
+		wantSender string
+	}{
+		{
+			name:     "github push",
+			provider: ProviderGitHub,
+			body: `{"ref":"refs/heads/main","before":"0000000000000000000000000000000000000000","after":"` + testCommit + `",
+				"repository":{"full_name":"org/repo","clone_url":"https://github.com/org/repo.git","ssh_url":"git@github.com:org/repo.git","html_url":"https://github.com/org/repo"},
+				"sender":{"login":"user"}}`,
+			header: func(body []byte) http.Header {
+				return http.Header{
+					"X-Github-Event":      {"push"},
+					"X-Hub-Signature-256": {"sha256=" + sign(secret, body)},
+				}
+			},
+			wantType: EventTypePush,
+			wantRepo: Repository{
+				Name: "org/repo",
+				URLs: []string{"https://github.com/org/repo.git", "git@github.com:org/repo.git", "https://github.com/org/repo"},
+			},
+			wantChange: []Change{{Ref: "refs/heads/main", After: testCommit}},
+			wantSender: "user",
+		},
+		{
+			name:     "github ping",
+			provider: ProviderGitHub,
+			body:     `{"zen":"Keep it logically awesome.","sender":{"login":"user"}}`,
+			header: func(body []byte) http.Header {
+				return http.Header{
+					"X-Github-Event":      {"ping"},
+					"X-Hub-Signature-256": {"sha256=" + sign(secret, body)},
+				}
+			},
+			wantType:   EventTypePing,
+			wantSender: "user",
+		},
+		{
+			name:     "gitlab tag push",
+			provider: ProviderGitLab,
+			body: `{"ref":"refs/tags/v1.0.0","before":"0000000000000000000000000000000000000000","after":"` + testCommit + `","user_username":"user",
+				"project":{"path_with_namespace":"group/repo","git_http_url":"https://gitlab.com/group/repo.git","git_ssh_url":"git@gitlab.com:group/repo.git","web_url":"https://gitlab.com/group/repo"}}`,
+			header: func(_ []byte) http.Header {
+				return http.Header{
+					"X-Gitlab-Event": {"Tag Push Hook"},
+					"X-Gitlab-Token": {string(secret)},
+				}
+			},
+			wantType: EventTypePush,
+			wantRepo: Repository{
+				Name: "group/repo",
+				URLs: []string{"https://gitlab.com/group/repo.git", "git@gitlab.com:group/repo.git", "https://gitlab.com/group/repo"},
+			},
+			wantChange: []Change{{Ref: "refs/tags/v1.0.0", After: testCommit}},
+			wantSender: "user",
+		},
+		{
+			name:     "bitbucket push",
+			provider: ProviderBitbucket,
+			body: `{"push":{"changes":[{"old":{"type":"branch","name":"main","target":{"hash":"` + testCommit + `"}},"new":null}]},
+				"repository":{"full_name":"org/repo","links":{"html":{"href":"https://bitbucket.org/org/repo"}}},
+				"actor":{"nickname":"user"}}`,
+			header: func(body []byte) http.Header {
+				return http.Header{
+					"X-Event-Key":     {"repo:push"},
+					"X-Hub-Signature": {"sha256=" + sign(secret, body)},
+				}
+			},
+			wantType: EventTypePush,
+			wantRepo: Repository{
+				Name: "org/repo",
+				URLs: []string{"https://bitbucket.org/org/repo", "https://bitbucket.org/org/repo.git"},
+			},
+			wantChange: []Change{{Ref: "refs/heads/main", Before: testCommit}},
+			wantSender: "user",
+		},
+		{
+			name:     "bitbucket server refs changed",
+			provider: ProviderBitbucketServer,
+			body: `{"actor":{"name":"user"},"repository":{"slug":"repo","project":{"key":"PROJ"},
+				"links":{"clone":[{"href":"ssh://git@bitbucket.example.com:7999/proj/repo.git","name":"ssh"},{"href":"https://bitbucket.example.com/scm/proj/repo.git","name":"http"}]}},
+				"changes":[{"refId":"refs/heads/main","fromHash":"` + testCommit + `","toHash":"` + testCommit + `","type":"UPDATE"}]}`,
+			header: func(body []byte) http.Header {
+				return http.Header{
+					"X-Event-Key":     {"repo:refs_changed"},
+					"X-Hub-Signature": {"sha256=" + sign(secret, body)},
+				}
+			},
+			wantType: EventTypePush,
+			wantRepo: Repository{
+				Name: "PROJ/repo",
+				URLs: []string{"ssh://git@bitbucket.example.com:7999/proj/repo.git", "https://bitbucket.example.com/scm/proj/repo.git"},
+			},
+			wantChange: []Change{{Ref: "refs/heads/main", Before: testCommit, After: testCommit}},
+			wantSender: "user",
+		},
+		{
+			name:     "azure devops push",
+			provider: ProviderAzureDevOps,
+			body: `{"eventType":"git.push","resource":{"refUpdates":[{"name":"refs/heads/main","oldObjectId":"` + testCommit + `","newObjectId":"` + testCommit + `"}],
+				"repository":{"name":"repo","remoteUrl":"https://dev.azure.com/org/project/_git/repo","project":{"name":"project"}},
+				"pushedBy":{"uniqueName":"user@example.com"}}}`,
+			header: func(_ []byte) http.Header {
+				req := httptest.NewRequest(http.MethodPost, "/", nil)
+				req.SetBasicAuth("flux", string(secret))
+				return req.Header
+			},
+			wantType: EventTypePush,
+			wantRepo: Repository{
+				Name: "project/repo",
+				URLs: []string{"https://dev.azure.com/org/project/_git/repo"},
+			},
+			wantChange: []Change{{Ref: "refs/heads/main", Before: testCommit, After: testCommit}},
+			wantSender: "user@example.com",
+		},
+		{
+			name:     "gitea push",
+			provider: ProviderGitea,
+			body: `{"ref":"refs/heads/main","before":"` + testCommit + `","after":"0000000000000000000000000000000000000000",
+				"repository":{"full_name":"org/repo","clone_url":"https://gitea.example.com/org/repo.git"},
+				"sender":{"login":"user"}}`,
+			header: func(body []byte) http.Header {
+				return http.Header{
+					"X-Gitea-Event":     {"push"},
+					"X-Gitea-Signature": {sign(secret, body)},
+				}
+			},
+			wantType: EventTypePush,
+			wantRepo: Repository{
+				Name: "org/repo",
+				URLs: []string{"https://gitea.example.com/org/repo.git"},
+			},
+			wantChange: []Change{{Ref: "refs/heads/main", Before: testCommit}},
+			wantSender: "user",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			body := []byte(tt.body)
+			event, err := ParsePayload(tt.provider, tt.header(body), body, secret)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(event.Provider).To(Equal(tt.provider))
+			g.Expect(event.Type).To(Equal(tt.wantType))
+			g.Expect(event.Repository).To(Equal(tt.wantRepo))
+			g.Expect(event.Changes).To(Equal(tt.wantChange))
+			g.Expect(event.Sender).To(Equal(tt.wantSender))
+
+			_, err = ParsePayload(tt.provider, tt.header(body), body, []byte("invalid"))
+			g.Expect(err).To(MatchError(ErrInvalidSignature))
+
+			_, err = ParsePayload(tt.provider, http.Header{}, body, secret)
+			g.Expect(err).To(MatchError(ErrInvalidSignature))
+		})
+	}
+}
+
+func TestParsePayload_errors(t *testing.T) {
+	g := NewWithT(t)
+
+	secret := []byte("secret")
+	body := []byte(`{}`)
+
+	_, err := ParsePayload(ProviderGitHub, http.Header{}, body, nil)
+	g.Expect(err).To(MatchError(ErrMissingSecret))
+
+	_, err = ParsePayload("unknown", http.Header{}, body, secret)
+	g.Expect(err).To(MatchError(ErrUnsupportedProvider))
+
+	_, err = ParsePayload(ProviderGitHub, http.Header{
+		"X-Github-Event":      {"issues"},
+		"X-Hub-Signature-256": {"sha256=" + sign(secret, body)},
+	}, body, secret)
+	g.Expect(err).To(MatchError(ErrUnsupportedEvent))
+
+	// The payload is verified before it is parsed.
+	_, err = ParsePayload(ProviderGitHub, http.Header{
+		"X-Github-Event":      {"push"},
+		"X-Hub-Signature-256": {"sha256=" + sign([]byte("other"), body)},
+	}, body, secret)
+	g.Expect(err).To(MatchError(ErrInvalidSignature))
+}
+
+func TestParse(t *testing.T) {
+	g := NewWithT(t)
+
+	secret := []byte("secret")
+	body := []byte(`{"ref":"refs/tags/v1.0.0","after":"` + testCommit + `"}`)
+	req := httptest.NewRequest(http.MethodPost, "/hook", bytes.NewReader(body))
+	req.Header.Set("X-Gitea-Event", "push")
+	req.Header.Set("X-Gitea-Signature", sign(secret, body))
+
+	event, err := Parse(req, ProviderGitea, secret)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(event.Changes).To(HaveLen(1))
+	g.Expect(event.Changes[0].Tag()).To(Equal("v1.0.0"))
+	g.Expect(event.Changes[0].Branch()).To(BeEmpty())
+	g.Expect(event.Changes[0].Created()).To(BeTrue())
+
+	req = httptest.NewRequest(http.MethodPost, "/hook", bytes.NewReader(make([]byte, DefaultMaxPayloadSize+1)))
+	_, err = Parse(req, ProviderGitea, secret)
+	g.Expect(err).To(MatchError(ErrPayloadTooLarge))
+}
+
+func TestChange(t *testing.T) {
+	g := NewWithT(t)
+
+	c := Change{Ref: "refs/heads/main", After: testCommit}
+	g.Expect(c.Branch()).To(Equal("main"))
+	g.Expect(c.Tag()).To(BeEmpty())
+	g.Expect(c.Created()).To(BeTrue())
+	g.Expect(c.Deleted()).To(BeFalse())
+
+	c = Change{Ref: "refs/heads/main", Before: testCommit}
+	g.Expect(c.Created()).To(BeFalse())
+	g.Expect(c.Deleted()).To(BeTrue())
+}
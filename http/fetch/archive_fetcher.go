@@ -40,9 +40,15 @@ import (
 // the file server is offline.
 type ArchiveFetcher struct {
 	httpClient        *retryablehttp.Client
+	ociOptions        OCIOptions
+	s3Options         S3Options
 	maxDownloadSize   int
 	maxUntarSize      int
 	hostnameOverwrite string
+	concurrency       int
+	segmentSize       int64
+	bandwidthLimit    int64
+	breaker           *circuitBreaker
 }
 
 // ErrFileNotFound is an error type used to signal 404 HTTP status code responses.
@@ -81,10 +87,64 @@ func NewArchiveFetcherWithLogger(retries, maxDownloadSize, maxUntarSize int, hos
 }
 
 // Fetch downloads, verifies and extracts the tarball content to the specified directory.
+// Besides HTTP URLs, the artifact can be fetched from an OCI registry with an 'oci://' URL,
+// or from an S3-compatible bucket with an 's3://' URL.
 // If the file server responds with 5xx errors, the download operation is retried.
 // If the file server responds with 404, the returned error is of type ErrFileNotFound.
 // If the file server is unavailable for more than 3 minutes, the returned error contains the original status code.
+// If parallel downloads are configured and the file server supports range requests,
+// artifacts larger than the segment size are downloaded in parallel byte-range segments.
 func (r *ArchiveFetcher) Fetch(archiveURL, digest, dir string) error {
+	f, err := os.CreateTemp("", "fetch.*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(f.Name())
+
+	limiter := newBandwidthLimiter(r.bandwidthLimit)
+
+	switch {
+	case strings.HasPrefix(archiveURL, OCIScheme+"://"):
+		err = r.downloadOCI(archiveURL, f, limiter)
+	case strings.HasPrefix(archiveURL, S3Scheme+"://"):
+		err = r.downloadS3(archiveURL, f, limiter)
+	default:
+		err = r.downloadHTTP(archiveURL, f, limiter)
+	}
+	if err != nil {
+		return err
+	}
+
+	// We have just filled the file, to be able to read it from
+	// the start we must go back to its beginning.
+	_, err = f.Seek(0, 0)
+	if err != nil {
+		return fmt.Errorf("failed to seek back to beginning: %w", err)
+	}
+
+	// Ensure that the digest of the downloaded file matches the
+	// known digest.
+	if err := r.verifyDigest(digest, f); err != nil {
+		return fmt.Errorf("failed to verify archive: %w", err)
+	}
+
+	// Jump back at the beginning of the file stream again.
+	_, err = f.Seek(0, 0)
+	if err != nil {
+		return fmt.Errorf("failed to seek back to beginning again: %w", err)
+	}
+
+	// Extracts the tar file.
+	if err = tar.Untar(f, dir, tar.WithMaxUntarSize(r.maxUntarSize), tar.WithSkipSymlinks()); err != nil {
+		return fmt.Errorf("failed to extract archive (check whether file size exceeds max download size): %w", err)
+	}
+
+	return nil
+}
+
+// downloadHTTP downloads the artifact at the given HTTP URL into the file,
+// in parallel byte-range segments if configured.
+func (r *ArchiveFetcher) downloadHTTP(archiveURL string, f *os.File, limiter *bandwidthLimiter) error {
 	if r.hostnameOverwrite != "" {
 		u, err := url.Parse(archiveURL)
 		if err != nil {
@@ -94,12 +154,36 @@ func (r *ArchiveFetcher) Fetch(archiveURL, digest, dir string) error {
 		archiveURL = u.String()
 	}
 
+	var size int64
+	if r.concurrency > 1 {
+		var err error
+		if size, err = r.rangeSize(archiveURL); err != nil {
+			return err
+		}
+	}
+	if size > r.segmentSize {
+		if r.maxDownloadSize > 0 && size > int64(r.maxDownloadSize) {
+			return fmt.Errorf("artifact is %d bytes greater than the max download size of %d bytes", size-int64(r.maxDownloadSize), r.maxDownloadSize)
+		}
+		return r.downloadSegments(archiveURL, size, f, limiter)
+	}
+	return r.download(archiveURL, f, limiter)
+}
+
+// download downloads the artifact at the given URL into the file in a
+// single request.
+func (r *ArchiveFetcher) download(archiveURL string, f *os.File, limiter *bandwidthLimiter) error {
 	req, err := retryablehttp.NewRequest(http.MethodGet, archiveURL, nil)
 	if err != nil {
 		return fmt.Errorf("failed to create a new request: %w", err)
 	}
+	return r.downloadRequest(req, f, limiter)
+}
 
-	resp, err := r.httpClient.Do(req)
+// downloadRequest downloads the response body of the request into the file.
+func (r *ArchiveFetcher) downloadRequest(req *retryablehttp.Request, f *os.File, limiter *bandwidthLimiter) error {
+	archiveURL := req.URL.Redacted()
+	resp, err := r.do(req)
 	if err != nil {
 		return fmt.Errorf("failed to download archive: %w", err)
 	}
@@ -112,12 +196,6 @@ func (r *ArchiveFetcher) Fetch(archiveURL, digest, dir string) error {
 		return fmt.Errorf("failed to download archive from %s (status: %s)", archiveURL, resp.Status)
 	}
 
-	f, err := os.CreateTemp("", "fetch.*.tmp")
-	if err != nil {
-		return fmt.Errorf("failed to create temp file: %w", err)
-	}
-	defer os.Remove(f.Name())
-
 	// Save temporary file, but limit download to the max download size.
 	if r.maxDownloadSize > 0 {
 		// Headers can lie, so instead of trusting resp.ContentLength,
@@ -125,42 +203,18 @@ func (r *ArchiveFetcher) Fetch(archiveURL, digest, dir string) error {
 		// there are still bytes left.
 		// Note that discarding of remaining bytes in resp.Body is a
 		// requirement for Go to effectively reuse HTTP connections.
-		_, err = io.Copy(f, io.LimitReader(resp.Body, int64(r.maxDownloadSize)))
+		_, err = io.Copy(f, limiter.reader(io.LimitReader(resp.Body, int64(r.maxDownloadSize))))
 		n, _ := io.Copy(io.Discard, resp.Body)
 		if n > 0 {
 			return fmt.Errorf("artifact is %d bytes greater than the max download size of %d bytes", n, r.maxDownloadSize)
 		}
 	} else {
-		_, err = io.Copy(f, resp.Body)
+		_, err = io.Copy(f, limiter.reader(resp.Body))
 	}
 	if err != nil {
 		return fmt.Errorf("failed to copy temp contents: %w", err)
 	}
 
-	// We have just filled the file, to be able to read it from
-	// the start we must go back to its beginning.
-	_, err = f.Seek(0, 0)
-	if err != nil {
-		return fmt.Errorf("failed to seek back to beginning: %w", err)
-	}
-
-	// Ensure that the digest of the downloaded file matches the
-	// known digest.
-	if err := r.verifyDigest(digest, f); err != nil {
-		return fmt.Errorf("failed to verify archive: %w", err)
-	}
-
-	// Jump back at the beginning of the file stream again.
-	_, err = f.Seek(0, 0)
-	if err != nil {
-		return fmt.Errorf("failed to seek back to beginning again: %w", err)
-	}
-
-	// Extracts the tar file.
-	if err = tar.Untar(f, dir, tar.WithMaxUntarSize(r.maxUntarSize), tar.WithSkipSymlinks()); err != nil {
-		return fmt.Errorf("failed to extract archive (check whether file size exceeds max download size): %w", err)
-	}
-
 	return nil
 }
 
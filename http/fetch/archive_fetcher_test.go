@@ -50,6 +50,7 @@ func TestArchiveFetcher_Fetch(t *testing.T) {
 		digest          string
 		maxDownloadSize int
 		maxUntarSize    int
+		parallel        bool
 		wantErr         bool
 		wantErrType     error
 	}{
@@ -109,6 +110,33 @@ func TestArchiveFetcher_Fetch(t *testing.T) {
 			maxUntarSize:    -1,
 			wantErr:         true,
 		},
+		{
+			name:            "fetches in parallel segments and verifies the digest",
+			url:             artifactURL,
+			digest:          artifactChecksum,
+			maxDownloadSize: -1,
+			maxUntarSize:    -1,
+			parallel:        true,
+			wantErr:         false,
+		},
+		{
+			name:            "breaches max download size in parallel segments",
+			url:             artifactURL,
+			digest:          artifactChecksum,
+			maxDownloadSize: 100,
+			maxUntarSize:    -1,
+			parallel:        true,
+			wantErr:         true,
+		},
+		{
+			name:            "fails to verify the digest in parallel segments",
+			url:             artifactURL,
+			digest:          "sha256:5c234ee52ff0e3dcc8528d6b9383cc235ad13a11658466f29df3be9eda6ee447",
+			maxDownloadSize: -1,
+			maxUntarSize:    -1,
+			parallel:        true,
+			wantErr:         true,
+		},
 		{
 			name:            "fails with not found error",
 			url:             artifactURL + "1",
@@ -125,6 +153,9 @@ func TestArchiveFetcher_Fetch(t *testing.T) {
 			g := NewWithT(t)
 
 			fetcher := NewArchiveFetcher(1, tt.maxDownloadSize, tt.maxUntarSize, "")
+			if tt.parallel {
+				fetcher.WithParallelDownload(4, 64)
+			}
 			err = fetcher.Fetch(tt.url, tt.digest, tmpDir)
 
 			if tt.wantErr {
@@ -0,0 +1,303 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fetch
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/hashicorp/go-retryablehttp"
+)
+
+const (
+	// OCIScheme is the URL scheme of the artifacts stored in OCI registries,
+	// in the format 'oci://<registry>/<repository>[:<tag>|@<digest>]'.
+	OCIScheme = "oci"
+
+	// OCIContentMediaType is the media type of the layer holding the
+	// content of the Flux OCI artifacts.
+	OCIContentMediaType = "application/vnd.cncf.flux.content.v1.tar+gzip"
+
+	ociManifestMediaType    = "application/vnd.oci.image.manifest.v1+json"
+	dockerManifestMediaType = "application/vnd.docker.distribution.manifest.v2+json"
+	ociIndexMediaType       = "application/vnd.oci.image.index.v1+json"
+	dockerIndexMediaType    = "application/vnd.docker.distribution.manifest.list.v2+json"
+)
+
+// OCIOptions holds the options for fetching artifacts from OCI registries.
+//
+// The fetcher implements the subset of the OCI distribution API needed to
+// download the content of single-manifest artifacts, such as the ones pushed
+// by the Flux CLI, without depending on a full registry client:
+//   - Only static credentials are supported, through basic authentication or
+//     the token service of the registry. Docker config files, credential
+//     helpers and cloud provider logins are not supported.
+//   - Image indexes are not resolved, fetching an artifact referenced by an
+//     index returns an error.
+//
+// Artifacts requiring these features should be pulled with the client of the
+// github.com/fluxcd/pkg/oci module instead.
+type OCIOptions struct {
+	// Username and Password are the credentials used to authenticate to
+	// the registry. Anonymous access is used when empty.
+	Username string
+	Password string
+	// Insecure allows connecting to registries over plain HTTP.
+	Insecure bool
+}
+
+// WithOCIOptions configures the fetcher for the artifacts with 'oci://'
+// URLs. The content layer of the artifact is downloaded and verified
+// against the digest given to Fetch.
+func (r *ArchiveFetcher) WithOCIOptions(opts OCIOptions) *ArchiveFetcher {
+	r.ociOptions = opts
+	return r
+}
+
+// ociReference is a parsed 'oci://' URL.
+type ociReference struct {
+	registry   string
+	repository string
+	reference  string
+}
+
+// parseOCIReference parses the given 'oci://' URL, the reference defaults
+// to the 'latest' tag.
+func parseOCIReference(ociURL string) (*ociReference, error) {
+	s := strings.TrimPrefix(ociURL, OCIScheme+"://")
+	registry, path, ok := strings.Cut(s, "/")
+	if !ok || registry == "" || path == "" {
+		return nil, fmt.Errorf("invalid OCI URL '%s': expected 'oci://<registry>/<repository>[:<tag>|@<digest>]'", ociURL)
+	}
+
+	ref := &ociReference{registry: registry, repository: path, reference: "latest"}
+	if repo, digest, ok := strings.Cut(path, "@"); ok {
+		ref.repository, ref.reference = repo, digest
+	} else if i := strings.LastIndex(path, ":"); i > 0 {
+		ref.repository, ref.reference = path[:i], path[i+1:]
+	}
+	if ref.repository == "" || ref.reference == "" {
+		return nil, fmt.Errorf("invalid OCI URL '%s'", ociURL)
+	}
+	return ref, nil
+}
+
+// downloadOCI downloads the content layer of the OCI artifact at the given
+// URL into the file.
+func (r *ArchiveFetcher) downloadOCI(ociURL string, f *os.File, limiter *bandwidthLimiter) error {
+	ref, err := parseOCIReference(ociURL)
+	if err != nil {
+		return err
+	}
+
+	scheme := "https"
+	if r.ociOptions.Insecure {
+		scheme = "http"
+	}
+	baseURL := fmt.Sprintf("%s://%s/v2/%s", scheme, ref.registry, ref.repository)
+	auth := &ociAuth{fetcher: r, opts: r.ociOptions, scope: fmt.Sprintf("repository:%s:pull", ref.repository)}
+
+	accept := []string{ociManifestMediaType, dockerManifestMediaType, ociIndexMediaType, dockerIndexMediaType}
+	resp, err := auth.get(baseURL+"/manifests/"+ref.reference, strings.Join(accept, ","))
+	if err != nil {
+		return fmt.Errorf("failed to fetch OCI manifest: %w", err)
+	}
+	defer resp.Body.Close()
+	if code := resp.StatusCode; code != http.StatusOK {
+		if code == http.StatusNotFound {
+			return ErrFileNotFound
+		}
+		return fmt.Errorf("failed to fetch OCI manifest of %s (status: %s)", ociURL, resp.Status)
+	}
+
+	var manifest struct {
+		MediaType string `json:"mediaType"`
+		Layers    []struct {
+			MediaType string `json:"mediaType"`
+			Digest    string `json:"digest"`
+		} `json:"layers"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 4<<20)).Decode(&manifest); err != nil {
+		return fmt.Errorf("failed to decode OCI manifest: %w", err)
+	}
+	switch mediaType, _, _ := strings.Cut(resp.Header.Get("Content-Type"), ";"); {
+	case mediaType == ociIndexMediaType, mediaType == dockerIndexMediaType,
+		manifest.MediaType == ociIndexMediaType, manifest.MediaType == dockerIndexMediaType:
+		return fmt.Errorf("failed to fetch %s: OCI image indexes are not supported", ociURL)
+	}
+
+	var layer string
+	for _, l := range manifest.Layers {
+		if l.MediaType == OCIContentMediaType {
+			layer = l.Digest
+			break
+		}
+	}
+	if layer == "" {
+		if len(manifest.Layers) != 1 {
+			return fmt.Errorf("failed to find the content layer of %s: expected a single layer, got %d", ociURL, len(manifest.Layers))
+		}
+		layer = manifest.Layers[0].Digest
+	}
+
+	req, err := retryablehttp.NewRequest(http.MethodGet, baseURL+"/blobs/"+layer, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create a new request: %w", err)
+	}
+	auth.authorize(req)
+	return r.downloadRequest(req, f, limiter)
+}
+
+// ociAuth authenticates the requests to a registry, with the token obtained
+// from the registry authorization service or with basic authentication,
+// depending on the challenge of the registry.
+type ociAuth struct {
+	fetcher *ArchiveFetcher
+	opts    OCIOptions
+	scope   string
+
+	header string
+}
+
+// authorize sets the Authorization header of the request, if any.
+func (a *ociAuth) authorize(req *retryablehttp.Request) {
+	if a.header != "" {
+		req.Header.Set("Authorization", a.header)
+	}
+}
+
+// get sends a GET request to the given URL, and retries it once
+// authenticated if the registry responds with an authentication challenge.
+func (a *ociAuth) get(u, accept string) (*http.Response, error) {
+	send := func() (*http.Response, error) {
+		req, err := retryablehttp.NewRequest(http.MethodGet, u, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Accept", accept)
+		a.authorize(req)
+		return a.fetcher.do(req)
+	}
+
+	resp, err := send()
+	if err != nil || resp.StatusCode != http.StatusUnauthorized || a.header != "" {
+		return resp, err
+	}
+	challenge := resp.Header.Get("WWW-Authenticate")
+	resp.Body.Close()
+
+	if err := a.login(challenge); err != nil {
+		return nil, err
+	}
+	return send()
+}
+
+// login computes the Authorization header answering the given challenge.
+func (a *ociAuth) login(challenge string) error {
+	scheme, params, _ := strings.Cut(challenge, " ")
+	switch strings.ToLower(scheme) {
+	case "basic":
+		if a.opts.Username == "" {
+			return fmt.Errorf("registry requires basic authentication, but no credentials are configured")
+		}
+		a.header = "Basic " + base64.StdEncoding.EncodeToString([]byte(a.opts.Username+":"+a.opts.Password))
+		return nil
+	case "bearer":
+	default:
+		return fmt.Errorf("unsupported registry authentication challenge '%s'", challenge)
+	}
+
+	values := parseChallengeParams(params)
+	realm, err := url.Parse(values["realm"])
+	if err != nil || values["realm"] == "" {
+		return fmt.Errorf("invalid registry authentication realm '%s'", values["realm"])
+	}
+	query := realm.Query()
+	if service := values["service"]; service != "" {
+		query.Set("service", service)
+	}
+	scope := values["scope"]
+	if scope == "" {
+		scope = a.scope
+	}
+	query.Set("scope", scope)
+	realm.RawQuery = query.Encode()
+
+	req, err := retryablehttp.NewRequest(http.MethodGet, realm.String(), nil)
+	if err != nil {
+		return err
+	}
+	if a.opts.Username != "" {
+		req.SetBasicAuth(a.opts.Username, a.opts.Password)
+	}
+	resp, err := a.fetcher.do(req)
+	if err != nil {
+		return fmt.Errorf("failed to obtain registry token: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to obtain registry token (status: %s)", resp.Status)
+	}
+
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&token); err != nil {
+		return fmt.Errorf("failed to decode registry token: %w", err)
+	}
+	if token.Token == "" {
+		token.Token = token.AccessToken
+	}
+	if token.Token == "" {
+		return fmt.Errorf("failed to obtain registry token: empty token")
+	}
+	a.header = "Bearer " + token.Token
+	return nil
+}
+
+// parseChallengeParams parses the comma separated key="value" parameters
+// of an authentication challenge.
+func parseChallengeParams(params string) map[string]string {
+	values := map[string]string{}
+	for len(params) > 0 {
+		key, rest, ok := strings.Cut(strings.TrimLeft(params, ", "), "=")
+		if !ok {
+			break
+		}
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.Index(rest[1:], `"`)
+			if end < 0 {
+				value, rest = rest[1:], ""
+			} else {
+				value, rest = rest[1:end+1], rest[end+2:]
+			}
+		} else {
+			value, rest, _ = strings.Cut(rest, ",")
+		}
+		values[strings.ToLower(strings.TrimSpace(key))] = value
+		params = rest
+	}
+	return values
+}
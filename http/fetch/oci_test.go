@@ -0,0 +1,209 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fetch
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/fluxcd/pkg/testserver"
+)
+
+// testArtifact returns the content and digest of an artifact built from
+// the testdata directory.
+func testArtifact(t *testing.T) ([]byte, string) {
+	t.Helper()
+
+	testServer, err := testserver.NewTempArtifactServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	checksum, err := testServer.ArtifactFromDir("testdata", "manifests.tgz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(testServer.Root(), "manifests.tgz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data, "sha256:" + checksum
+}
+
+func TestParseOCIReference(t *testing.T) {
+	tests := []struct {
+		url     string
+		want    *ociReference
+		wantErr bool
+	}{
+		{
+			url:  "oci://ghcr.io/org/app:v1.0.0",
+			want: &ociReference{registry: "ghcr.io", repository: "org/app", reference: "v1.0.0"},
+		},
+		{
+			url:  "oci://localhost:5000/app@sha256:abc",
+			want: &ociReference{registry: "localhost:5000", repository: "app", reference: "sha256:abc"},
+		},
+		{
+			url:  "oci://ghcr.io/org/app",
+			want: &ociReference{registry: "ghcr.io", repository: "org/app", reference: "latest"},
+		},
+		{
+			url:     "oci://ghcr.io",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			g := NewWithT(t)
+
+			got, err := parseOCIReference(tt.url)
+			if tt.wantErr {
+				g.Expect(err).To(HaveOccurred())
+				return
+			}
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(got).To(Equal(tt.want))
+		})
+	}
+}
+
+func TestArchiveFetcher_FetchOCI(t *testing.T) {
+	data, digest := testArtifact(t)
+
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			if user, pass, ok := r.BasicAuth(); !ok || user != "user" || pass != "pass" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			if r.URL.Query().Get("scope") != "repository:org/app:pull" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			fmt.Fprint(w, `{"token":"secret"}`)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="test"`, srv.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/v2/org/app/manifests/v1":
+			w.Header().Set("Content-Type", ociManifestMediaType)
+			fmt.Fprintf(w, `{"schemaVersion":2,"layers":[{"mediaType":%q,"digest":%q}]}`, OCIContentMediaType, digest)
+		case "/v2/org/app/manifests/multi-arch":
+			w.Header().Set("Content-Type", ociIndexMediaType)
+			fmt.Fprintf(w, `{"schemaVersion":2,"manifests":[{"mediaType":%q,"digest":%q}]}`, ociManifestMediaType, digest)
+		case "/v2/org/app/blobs/" + digest:
+			w.Write(data)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+	registry := strings.TrimPrefix(srv.URL, "http://")
+
+	t.Run("fetches and verifies the content layer", func(t *testing.T) {
+		g := NewWithT(t)
+
+		fetcher := NewArchiveFetcher(1, -1, -1, "").WithOCIOptions(OCIOptions{
+			Username: "user",
+			Password: "pass",
+			Insecure: true,
+		})
+		dir := t.TempDir()
+		g.Expect(fetcher.Fetch("oci://"+registry+"/org/app:v1", digest, dir)).To(Succeed())
+		g.Expect(filepath.Join(dir, "testdata", "manifests.yaml")).To(BeARegularFile())
+	})
+
+	t.Run("fails on digest mismatch", func(t *testing.T) {
+		g := NewWithT(t)
+
+		fetcher := NewArchiveFetcher(1, -1, -1, "").WithOCIOptions(OCIOptions{
+			Username: "user",
+			Password: "pass",
+			Insecure: true,
+		})
+		err := fetcher.Fetch("oci://"+registry+"/org/app:v1", "sha256:"+strings.Repeat("0", 64), t.TempDir())
+		g.Expect(err).To(MatchError(ContainSubstring("failed to verify archive")))
+	})
+
+	t.Run("fails without credentials", func(t *testing.T) {
+		g := NewWithT(t)
+
+		fetcher := NewArchiveFetcher(1, -1, -1, "").WithOCIOptions(OCIOptions{Insecure: true})
+		err := fetcher.Fetch("oci://"+registry+"/org/app:v1", digest, t.TempDir())
+		g.Expect(err).To(MatchError(ContainSubstring("failed to obtain registry token")))
+	})
+
+	t.Run("fails on image indexes", func(t *testing.T) {
+		g := NewWithT(t)
+
+		fetcher := NewArchiveFetcher(1, -1, -1, "").WithOCIOptions(OCIOptions{
+			Username: "user",
+			Password: "pass",
+			Insecure: true,
+		})
+		err := fetcher.Fetch("oci://"+registry+"/org/app:multi-arch", digest, t.TempDir())
+		g.Expect(err).To(MatchError(ContainSubstring("OCI image indexes are not supported")))
+	})
+
+	t.Run("returns not found for missing tags", func(t *testing.T) {
+		g := NewWithT(t)
+
+		fetcher := NewArchiveFetcher(1, -1, -1, "").WithOCIOptions(OCIOptions{
+			Username: "user",
+			Password: "pass",
+			Insecure: true,
+		})
+		err := fetcher.Fetch("oci://"+registry+"/org/app:v2", digest, t.TempDir())
+		g.Expect(err).To(MatchError(ErrFileNotFound))
+	})
+}
+
+func TestOCIAuth_login(t *testing.T) {
+	g := NewWithT(t)
+
+	auth := &ociAuth{opts: OCIOptions{Username: "user", Password: "pass"}}
+	g.Expect(auth.login(`Basic realm="registry"`)).To(Succeed())
+	g.Expect(auth.header).To(Equal("Basic dXNlcjpwYXNz"))
+
+	// Only the basic and bearer challenges are supported.
+	auth = &ociAuth{}
+	g.Expect(auth.login(`Negotiate`)).To(MatchError(ContainSubstring("unsupported registry authentication challenge")))
+	g.Expect(auth.login(`Basic realm="registry"`)).To(MatchError(ContainSubstring("no credentials are configured")))
+	g.Expect(auth.login(`Bearer service="registry"`)).To(MatchError(ContainSubstring("invalid registry authentication realm")))
+}
+
+func TestParseChallengeParams(t *testing.T) {
+	g := NewWithT(t)
+
+	g.Expect(parseChallengeParams(`realm="https://auth.example.com/token",service="registry",scope="repository:org/app:pull,push"`)).To(Equal(map[string]string{
+		"realm":   "https://auth.example.com/token",
+		"service": "registry",
+		"scope":   "repository:org/app:pull,push",
+	}))
+}
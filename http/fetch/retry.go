@@ -0,0 +1,220 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fetch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-retryablehttp"
+)
+
+// ErrCircuitOpen is returned when requests to a host are rejected because
+// its circuit breaker is open.
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// DefaultRetryableStatusCodes are the HTTP status codes for which requests
+// are retried when a RetryPolicy does not specify any.
+var DefaultRetryableStatusCodes = []int{
+	http.StatusRequestTimeout,
+	http.StatusTooManyRequests,
+	http.StatusInternalServerError,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// RetryPolicy configures how the fetcher retries failed requests.
+type RetryPolicy struct {
+	// MaxRetries is the maximum number of retries of a request.
+	MaxRetries int
+	// WaitMin is the minimum time to wait between retries.
+	// Defaults to the wait of the fetcher when zero.
+	WaitMin time.Duration
+	// WaitMax is the maximum time to wait between retries.
+	// Defaults to the wait of the fetcher when zero.
+	WaitMax time.Duration
+	// RetryableStatusCodes are the HTTP status codes for which a request
+	// is retried. Defaults to DefaultRetryableStatusCodes when empty.
+	RetryableStatusCodes []int
+	// MaxRetryAfter caps the wait requested by a Retry-After header of
+	// a response. Zero means no cap.
+	MaxRetryAfter time.Duration
+}
+
+// WithRetryPolicy configures the fetcher to retry requests according to
+// the given policy. Waits requested by the file server with a Retry-After
+// header take precedence over the exponential backoff.
+func (r *ArchiveFetcher) WithRetryPolicy(policy RetryPolicy) *ArchiveFetcher {
+	r.httpClient.RetryMax = policy.MaxRetries
+	if policy.WaitMin > 0 {
+		r.httpClient.RetryWaitMin = policy.WaitMin
+	}
+	if policy.WaitMax > 0 {
+		r.httpClient.RetryWaitMax = policy.WaitMax
+	}
+	r.httpClient.CheckRetry = policy.checkRetry
+	r.httpClient.Backoff = policy.backoff
+	return r
+}
+
+// WithCircuitBreaker configures the fetcher to reject requests to a host
+// with ErrCircuitOpen for openDuration, after failureThreshold consecutive
+// requests to it have failed after retries. Once openDuration has passed,
+// a single request is let through, and the circuit is closed if it
+// succeeds.
+func (r *ArchiveFetcher) WithCircuitBreaker(failureThreshold int, openDuration time.Duration) *ArchiveFetcher {
+	r.breaker = newCircuitBreaker(failureThreshold, openDuration)
+	return r
+}
+
+func (p RetryPolicy) checkRetry(ctx context.Context, resp *http.Response, err error) (bool, error) {
+	if ctx.Err() != nil {
+		return false, ctx.Err()
+	}
+	if err != nil {
+		return retryablehttp.DefaultRetryPolicy(ctx, resp, err)
+	}
+
+	codes := p.RetryableStatusCodes
+	if len(codes) == 0 {
+		codes = DefaultRetryableStatusCodes
+	}
+	for _, code := range codes {
+		if resp.StatusCode == code {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (p RetryPolicy) backoff(min, max time.Duration, attemptNum int, resp *http.Response) time.Duration {
+	if wait, ok := retryAfter(resp, time.Now()); ok {
+		if p.MaxRetryAfter > 0 && wait > p.MaxRetryAfter {
+			wait = p.MaxRetryAfter
+		}
+		return wait
+	}
+	return retryablehttp.DefaultBackoff(min, max, attemptNum, nil)
+}
+
+// retryAfter returns the wait requested by the Retry-After header of the
+// response, in either delay-seconds or HTTP-date format.
+func retryAfter(resp *http.Response, now time.Time) (time.Duration, bool) {
+	if resp == nil {
+		return 0, false
+	}
+	v := resp.Header.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(v); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if wait := t.Sub(now); wait > 0 {
+			return wait, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// do sends the request with the HTTP client of the fetcher, if the circuit
+// breaker of the host allows it, and records the result.
+func (r *ArchiveFetcher) do(req *retryablehttp.Request) (*http.Response, error) {
+	if r.breaker == nil {
+		return r.httpClient.Do(req)
+	}
+
+	host := req.URL.Host
+	if err := r.breaker.allow(host); err != nil {
+		return nil, err
+	}
+	resp, err := r.httpClient.Do(req)
+	r.breaker.record(host, err == nil && resp.StatusCode < http.StatusInternalServerError &&
+		resp.StatusCode != http.StatusTooManyRequests)
+	return resp, err
+}
+
+// circuitBreaker tracks the consecutive failures of requests per host.
+type circuitBreaker struct {
+	failureThreshold int
+	openDuration     time.Duration
+	now              func() time.Time
+
+	mu    sync.Mutex
+	hosts map[string]*circuitState
+}
+
+type circuitState struct {
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+func newCircuitBreaker(failureThreshold int, openDuration time.Duration) *circuitBreaker {
+	return &circuitBreaker{
+		failureThreshold: failureThreshold,
+		openDuration:     openDuration,
+		now:              time.Now,
+		hosts:            make(map[string]*circuitState),
+	}
+}
+
+// allow returns ErrCircuitOpen if requests to the host are rejected.
+func (b *circuitBreaker) allow(host string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	s, ok := b.hosts[host]
+	if !ok || s.failures < b.failureThreshold {
+		return nil
+	}
+	if b.now().Before(s.openUntil) || s.probing {
+		return fmt.Errorf("%w for host '%s' after %d consecutive failures", ErrCircuitOpen, host, s.failures)
+	}
+	// Let a single request through to probe the host.
+	s.probing = true
+	return nil
+}
+
+// record records the result of a request to the host.
+func (b *circuitBreaker) record(host string, success bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if success {
+		delete(b.hosts, host)
+		return
+	}
+	s, ok := b.hosts[host]
+	if !ok {
+		s = &circuitState{}
+		b.hosts[host] = s
+	}
+	s.failures++
+	s.probing = false
+	if s.failures >= b.failureThreshold {
+		s.openUntil = b.now().Add(b.openDuration)
+	}
+}
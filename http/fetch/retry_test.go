@@ -0,0 +1,139 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fetch
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestArchiveFetcher_WithRetryPolicy(t *testing.T) {
+	g := NewWithT(t)
+
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch calls.Add(1) {
+		case 1:
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+		case 2:
+			w.WriteHeader(http.StatusBadGateway)
+		default:
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}))
+	defer srv.Close()
+
+	fetcher := NewArchiveFetcher(0, -1, -1, "").WithRetryPolicy(RetryPolicy{
+		MaxRetries: 5,
+		WaitMin:    time.Millisecond,
+		WaitMax:    time.Millisecond,
+	})
+	err := fetcher.Fetch(srv.URL+"/artifact.tgz", "sha256:abc", t.TempDir())
+	g.Expect(err).To(MatchError(ContainSubstring("status: 403 Forbidden")))
+	// 403 is not retryable, so the third request is the last one.
+	g.Expect(calls.Load()).To(Equal(int32(3)))
+}
+
+func TestArchiveFetcher_WithCircuitBreaker(t *testing.T) {
+	g := NewWithT(t)
+
+	var calls atomic.Int32
+	var failing atomic.Bool
+	failing.Store(true)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if failing.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	now := time.Now()
+	fetcher := NewArchiveFetcher(0, -1, -1, "").WithCircuitBreaker(2, time.Minute)
+	fetcher.breaker.now = func() time.Time { return now }
+	url := srv.URL + "/artifact.tgz"
+
+	for i := 0; i < 2; i++ {
+		err := fetcher.Fetch(url, "sha256:abc", t.TempDir())
+		g.Expect(errors.Is(err, ErrCircuitOpen)).To(BeFalse())
+	}
+	err := fetcher.Fetch(url, "sha256:abc", t.TempDir())
+	g.Expect(errors.Is(err, ErrCircuitOpen)).To(BeTrue())
+	g.Expect(calls.Load()).To(Equal(int32(2)))
+
+	// After the open duration, a probe is let through and closes the
+	// circuit on success.
+	now = now.Add(time.Minute)
+	failing.Store(false)
+	err = fetcher.Fetch(url, "sha256:abc", t.TempDir())
+	g.Expect(err).To(Equal(ErrFileNotFound))
+	err = fetcher.Fetch(url, "sha256:abc", t.TempDir())
+	g.Expect(err).To(Equal(ErrFileNotFound))
+	g.Expect(calls.Load()).To(Equal(int32(4)))
+}
+
+func Test_retryAfter(t *testing.T) {
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		header string
+		want   time.Duration
+		wantOk bool
+	}{
+		{name: "no header"},
+		{name: "seconds", header: "30", want: 30 * time.Second, wantOk: true},
+		{name: "http date", header: now.Add(time.Minute).Format(http.TimeFormat), want: time.Minute, wantOk: true},
+		{name: "http date in the past", header: now.Add(-time.Minute).Format(http.TimeFormat), wantOk: true},
+		{name: "invalid", header: "soon"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			resp := &http.Response{Header: http.Header{}}
+			if tt.header != "" {
+				resp.Header.Set("Retry-After", tt.header)
+			}
+			got, ok := retryAfter(resp, now)
+			g.Expect(ok).To(Equal(tt.wantOk))
+			g.Expect(got).To(Equal(tt.want))
+		})
+	}
+}
+
+func TestRetryPolicy_checkRetry(t *testing.T) {
+	g := NewWithT(t)
+
+	p := RetryPolicy{RetryableStatusCodes: []int{http.StatusConflict}}
+	retry, err := p.checkRetry(context.TODO(), &http.Response{StatusCode: http.StatusConflict}, nil)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(retry).To(BeTrue())
+
+	retry, err = p.checkRetry(context.TODO(), &http.Response{StatusCode: http.StatusServiceUnavailable}, nil)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(retry).To(BeFalse())
+}
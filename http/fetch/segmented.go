@@ -0,0 +1,207 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fetch
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-retryablehttp"
+)
+
+// DefaultSegmentSize is the default size in bytes of the byte-range
+// segments of parallel downloads.
+const DefaultSegmentSize int64 = 8 * 1024 * 1024
+
+// WithParallelDownload configures the fetcher to download artifacts larger
+// than segmentSize in byte-range segments, using up to concurrency
+// parallel requests, if the file server supports range requests.
+// A segmentSize of zero or less defaults to DefaultSegmentSize.
+func (r *ArchiveFetcher) WithParallelDownload(concurrency int, segmentSize int64) *ArchiveFetcher {
+	if segmentSize <= 0 {
+		segmentSize = DefaultSegmentSize
+	}
+	r.concurrency = concurrency
+	r.segmentSize = segmentSize
+	return r
+}
+
+// WithBandwidthLimit configures the fetcher to limit the total download
+// rate to the given number of bytes per second. A limit of zero or less
+// disables the limit.
+func (r *ArchiveFetcher) WithBandwidthLimit(bytesPerSecond int64) *ArchiveFetcher {
+	r.bandwidthLimit = bytesPerSecond
+	return r
+}
+
+// rangeSize returns the size of the artifact at the given URL if the
+// file server supports range requests for it, or zero otherwise.
+func (r *ArchiveFetcher) rangeSize(archiveURL string) (int64, error) {
+	req, err := retryablehttp.NewRequest(http.MethodHead, archiveURL, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create a new request: %w", err)
+	}
+	resp, err := r.do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get archive size: %w", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Accept-Ranges") != "bytes" {
+		return 0, nil
+	}
+	return resp.ContentLength, nil
+}
+
+// downloadSegments downloads the given number of bytes of the artifact at
+// the given URL into the file, in parallel byte-range segments.
+func (r *ArchiveFetcher) downloadSegments(archiveURL string, size int64, f *os.File, limiter *bandwidthLimiter) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	segments := make(chan [2]int64)
+	go func() {
+		defer close(segments)
+		for start := int64(0); start < size; start += r.segmentSize {
+			end := start + r.segmentSize - 1
+			if end >= size {
+				end = size - 1
+			}
+			select {
+			case segments <- [2]int64{start, end}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+	for i := 0; i < r.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for s := range segments {
+				if err := r.downloadSegment(ctx, archiveURL, s[0], s[1], f, limiter); err != nil {
+					errOnce.Do(func() {
+						firstErr = err
+						cancel()
+					})
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	return firstErr
+}
+
+// downloadSegment downloads the bytes from start to end (inclusive) of the
+// artifact at the given URL, and writes them at the same offset in the file.
+func (r *ArchiveFetcher) downloadSegment(ctx context.Context, archiveURL string, start, end int64, f *os.File, limiter *bandwidthLimiter) error {
+	req, err := retryablehttp.NewRequestWithContext(ctx, http.MethodGet, archiveURL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create a new request: %w", err)
+	}
+	req.Header.Set("Range", "bytes="+strconv.FormatInt(start, 10)+"-"+strconv.FormatInt(end, 10))
+
+	resp, err := r.do(req)
+	if err != nil {
+		return fmt.Errorf("failed to download archive segment %d-%d: %w", start, end, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusPartialContent {
+		return fmt.Errorf("failed to download archive segment %d-%d from %s (status: %s)", start, end, archiveURL, resp.Status)
+	}
+
+	want := end - start + 1
+	n, err := io.Copy(io.NewOffsetWriter(f, start), limiter.reader(io.LimitReader(resp.Body, want)))
+	if err != nil {
+		return fmt.Errorf("failed to copy archive segment %d-%d: %w", start, end, err)
+	}
+	if n != want {
+		return fmt.Errorf("archive segment %d-%d is %d bytes instead of %d", start, end, n, want)
+	}
+	return nil
+}
+
+// bandwidthLimiter limits the rate at which bytes are read through the
+// readers it returns. A nil limiter does not limit the rate.
+type bandwidthLimiter struct {
+	bytesPerSecond int64
+
+	mu    sync.Mutex
+	start time.Time
+	total int64
+}
+
+func newBandwidthLimiter(bytesPerSecond int64) *bandwidthLimiter {
+	if bytesPerSecond <= 0 {
+		return nil
+	}
+	return &bandwidthLimiter{bytesPerSecond: bytesPerSecond, start: time.Now()}
+}
+
+// reader returns a reader which waits after each read until the total
+// number of bytes read through the limiter is within the limit.
+func (l *bandwidthLimiter) reader(rd io.Reader) io.Reader {
+	if l == nil {
+		return rd
+	}
+	return &limitedReader{reader: rd, limiter: l}
+}
+
+// wait records n bytes as read, and sleeps until reading them is within
+// the limit.
+func (l *bandwidthLimiter) wait(n int) {
+	l.mu.Lock()
+	l.total += int64(n)
+	due := l.start.Add(time.Duration(float64(l.total) / float64(l.bytesPerSecond) * float64(time.Second)))
+	l.mu.Unlock()
+
+	if d := time.Until(due); d > 0 {
+		time.Sleep(d)
+	}
+}
+
+type limitedReader struct {
+	reader  io.Reader
+	limiter *bandwidthLimiter
+}
+
+func (r *limitedReader) Read(p []byte) (int, error) {
+	// Read in chunks of at most a tenth of a second worth of bytes, to
+	// keep the rate smooth.
+	if max := r.limiter.bytesPerSecond / 10; max > 0 && int64(len(p)) > max {
+		p = p[:max]
+	}
+	n, err := r.reader.Read(p)
+	if n > 0 {
+		r.limiter.wait(n)
+	}
+	return n, err
+}
@@ -0,0 +1,194 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kustomize
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+
+	securefs "github.com/fluxcd/pkg/kustomize/filesys"
+)
+
+// BuildCacheKey identifies the rendered output of a kustomization.
+type BuildCacheKey struct {
+	// Digest is the digest of the source artifact containing the kustomization.
+	Digest string
+	// Path is the path of the kustomization relative to the artifact root.
+	Path string
+	// VarsHash is the hash of the post-build substitution variables
+	// returned by LoadVariables, as computed by HashVars.
+	VarsHash string
+
+	// kustomization is the hash of the spec of the kustomization of the
+	// Generator, set by WithBuildCache.
+	kustomization string
+}
+
+// HashVars returns a stable hash of the given substitution variables.
+func HashVars(vars map[string]string) string {
+	keys := make([]string, 0, len(vars))
+	for k := range vars {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	h := sha256.New()
+	for _, k := range keys {
+		fmt.Fprintf(h, "%d:%s=%d:%s\n", len(k), k, len(vars[k]), vars[k])
+	}
+	return fmt.Sprintf("sha256:%x", h.Sum(nil))
+}
+
+// BuildCache is a least recently used cache of rendered kustomizations.
+// It is safe for concurrent use, allowing many Kustomizations which
+// point at the same artifact to share the build results.
+type BuildCache struct {
+	mu         sync.Mutex
+	maxEntries int
+	entries    map[BuildCacheKey]*list.Element
+	order      *list.List
+}
+
+type buildCacheEntry struct {
+	key    BuildCacheKey
+	output []byte
+}
+
+// NewBuildCache returns a BuildCache holding at most maxEntries results.
+// A maxEntries lower or equal to zero means no limit.
+func NewBuildCache(maxEntries int) *BuildCache {
+	return &BuildCache{
+		maxEntries: maxEntries,
+		entries:    make(map[BuildCacheKey]*list.Element),
+		order:      list.New(),
+	}
+}
+
+// Get returns the rendered output stored for the given key.
+func (c *BuildCache) Get(key BuildCacheKey) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(e)
+	return append([]byte(nil), e.Value.(*buildCacheEntry).output...), true
+}
+
+// Set stores the rendered output for the given key, evicting the least
+// recently used entry if the cache is full.
+func (c *BuildCache) Set(key BuildCacheKey, output []byte) {
+	output = append([]byte(nil), output...)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.entries[key]; ok {
+		e.Value.(*buildCacheEntry).output = output
+		c.order.MoveToFront(e)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&buildCacheEntry{key: key, output: output})
+	if c.maxEntries > 0 && c.order.Len() > c.maxEntries {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*buildCacheEntry).key)
+	}
+}
+
+// Len returns the number of entries in the cache.
+func (c *BuildCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// WithBuildCache configures the Generator to store the output of CachedBuild
+// in the given cache under the given key, and to skip the build when the
+// cache already holds an entry for it. The spec of the kustomization of the
+// Generator is hashed into the key, so that changes to the patches, images,
+// etc. result in a new build.
+func (g *Generator) WithBuildCache(cache *BuildCache, key BuildCacheKey) *Generator {
+	spec, _ := json.Marshal(g.kustomization.Object["spec"])
+	key.kustomization = fmt.Sprintf("sha256:%x", sha256.Sum256(spec))
+
+	g.buildCache = cache
+	g.buildCacheKey = key
+	return g
+}
+
+// CachedBuild generates the kustomization.yaml in dirPath, builds it with
+// SecureBuildWithLimits and returns the resulting multi-doc YAML.
+// The generated files are cleaned up before returning.
+// If a BuildCache is configured and holds an entry for the key of the
+// Generator, the entry is returned without touching dirPath.
+// The returned boolean indicates if the output was served from the cache.
+// If a Decryptor is configured, the matching files are decrypted when read
+// by the build. If a SchemaValidator is configured, the resources are
+// validated before being cached and returned.
+func (g *Generator) CachedBuild(dirPath string, allowRemoteBases bool, limits securefs.Limits) ([]byte, bool, error) {
+	if g.buildCache != nil {
+		if output, ok := g.buildCache.Get(g.buildCacheKey); ok {
+			return output, true, nil
+		}
+	}
+
+	action, err := g.WriteFile(dirPath, WithSaveOriginalKustomization())
+	if err != nil {
+		return nil, false, err
+	}
+	defer CleanDirectory(dirPath, action)
+
+	fs, err := secureFS(g.root, allowRemoteBases, limits)
+	if err != nil {
+		return nil, false, err
+	}
+	if fs, err = g.remoteBasesFS(fs, dirPath); err != nil {
+		return nil, false, err
+	}
+	resMap, err := Build(g.decryptingFS(fs, dirPath), dirPath)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if g.schemaValidator != nil {
+		if err := g.schemaValidator.Validate(resMap); err != nil {
+			return nil, false, err
+		}
+		if g.originAdded {
+			if err := resMap.RemoveOriginAnnotations(); err != nil {
+				return nil, false, err
+			}
+		}
+	}
+
+	output, err := resMap.AsYaml()
+	if err != nil {
+		return nil, false, err
+	}
+
+	if g.buildCache != nil {
+		g.buildCache.Set(g.buildCacheKey, output)
+	}
+	return output, false, nil
+}
@@ -0,0 +1,130 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kustomize_test
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/otiai10/copy"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/fluxcd/pkg/kustomize"
+	securefs "github.com/fluxcd/pkg/kustomize/filesys"
+)
+
+func TestGenerator_CachedBuild(t *testing.T) {
+	g := NewWithT(t)
+
+	yamlKus, err := os.ReadFile("./testdata/kustomization.yaml")
+	g.Expect(err).NotTo(HaveOccurred())
+	clientObjects, err := readYamlObjects(strings.NewReader(string(yamlKus)))
+	g.Expect(err).NotTo(HaveOccurred())
+
+	expected, err := os.ReadFile("./testdata/kustomization_expected.yaml")
+	g.Expect(err).NotTo(HaveOccurred())
+
+	cache := kustomize.NewBuildCache(10)
+	key := kustomize.BuildCacheKey{
+		Digest:   "sha256:1234",
+		Path:     "./",
+		VarsHash: kustomize.HashVars(map[string]string{"cluster_env": "prod"}),
+	}
+
+	tmpDir := t.TempDir()
+	g.Expect(copy.Copy(resourcePath, tmpDir)).To(Succeed())
+
+	output, cached, err := kustomize.NewGenerator(tmpDir, clientObjects[0]).
+		WithBuildCache(cache, key).
+		CachedBuild(tmpDir, false, securefs.Limits{})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(cached).To(BeFalse())
+	g.Expect(string(output)).To(Equal(string(expected)))
+	g.Expect(cache.Len()).To(Equal(1))
+
+	// The cached output is served without building the directory.
+	output, cached, err = kustomize.NewGenerator(tmpDir, clientObjects[0]).
+		WithBuildCache(cache, key).
+		CachedBuild(t.TempDir(), false, securefs.Limits{})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(cached).To(BeTrue())
+	g.Expect(string(output)).To(Equal(string(expected)))
+
+	// A change to the kustomization results in a new build.
+	changed := clientObjects[0].DeepCopy()
+	g.Expect(unstructured.SetNestedField(changed.Object, "changed", "spec", "targetNamespace")).To(Succeed())
+	output, cached, err = kustomize.NewGenerator(tmpDir, *changed).
+		WithBuildCache(cache, key).
+		CachedBuild(tmpDir, false, securefs.Limits{})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(cached).To(BeFalse())
+	g.Expect(string(output)).To(ContainSubstring("namespace: changed"))
+	g.Expect(cache.Len()).To(Equal(2))
+}
+
+func TestBuildCache_Copy(t *testing.T) {
+	g := NewWithT(t)
+
+	cache := kustomize.NewBuildCache(0)
+	key := kustomize.BuildCacheKey{Digest: "a"}
+
+	output := []byte("a")
+	cache.Set(key, output)
+	output[0] = 'b'
+
+	got, ok := cache.Get(key)
+	g.Expect(ok).To(BeTrue())
+	g.Expect(string(got)).To(Equal("a"))
+
+	got[0] = 'c'
+	got, _ = cache.Get(key)
+	g.Expect(string(got)).To(Equal("a"))
+}
+
+func TestBuildCache_Eviction(t *testing.T) {
+	g := NewWithT(t)
+
+	cache := kustomize.NewBuildCache(2)
+	a := kustomize.BuildCacheKey{Digest: "a"}
+	b := kustomize.BuildCacheKey{Digest: "b"}
+	c := kustomize.BuildCacheKey{Digest: "c"}
+
+	cache.Set(a, []byte("a"))
+	cache.Set(b, []byte("b"))
+	_, ok := cache.Get(a)
+	g.Expect(ok).To(BeTrue())
+
+	cache.Set(c, []byte("c"))
+	g.Expect(cache.Len()).To(Equal(2))
+	_, ok = cache.Get(b)
+	g.Expect(ok).To(BeFalse())
+	_, ok = cache.Get(a)
+	g.Expect(ok).To(BeTrue())
+}
+
+func TestHashVars(t *testing.T) {
+	g := NewWithT(t)
+
+	g.Expect(kustomize.HashVars(map[string]string{"a": "1", "b": "2"})).
+		To(Equal(kustomize.HashVars(map[string]string{"b": "2", "a": "1"})))
+	g.Expect(kustomize.HashVars(map[string]string{"a": "1"})).
+		NotTo(Equal(kustomize.HashVars(map[string]string{"a": "2"})))
+	g.Expect(kustomize.HashVars(map[string]string{"a": "b=c"})).
+		NotTo(Equal(kustomize.HashVars(map[string]string{"a=b": "c"})))
+}
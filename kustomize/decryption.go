@@ -0,0 +1,176 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kustomize
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+	"sigs.k8s.io/yaml"
+)
+
+// DecryptionFormat is the format of a file passed to a Decryptor.
+type DecryptionFormat string
+
+const (
+	// DecryptionFormatYAML is the format of YAML files.
+	DecryptionFormatYAML DecryptionFormat = "yaml"
+	// DecryptionFormatJSON is the format of JSON files.
+	DecryptionFormatJSON DecryptionFormat = "json"
+	// DecryptionFormatDotenv is the format of dotenv files, as used
+	// by the kustomize ConfigMap and Secret generators.
+	DecryptionFormatDotenv DecryptionFormat = "dotenv"
+)
+
+// DefaultDecryptionPatterns are the file name patterns for which the
+// Decryptor of a Generator is invoked when none are configured.
+var DefaultDecryptionPatterns = []string{"*.yaml", "*.yml", "*.json", "*.env"}
+
+// Decryptor decrypts the contents of the files in the directory of
+// a Generator.
+type Decryptor interface {
+	// Decrypt returns the decrypted data of a file in the given format.
+	// Data which is not encrypted must be returned as is.
+	Decrypt(data []byte, format DecryptionFormat) ([]byte, error)
+}
+
+// WithDecryptor configures the Generator to decrypt the files which match
+// any of the given patterns when they are read by CachedBuild. The files
+// are decrypted in memory, and never written back to disk. Patterns are
+// matched against both the file name and the slash separated path relative
+// to the root of the Generator, or to the kustomization directory if the
+// root is empty. If no patterns are given, DefaultDecryptionPatterns are
+// used.
+func (g *Generator) WithDecryptor(d Decryptor, patterns ...string) *Generator {
+	if len(patterns) == 0 {
+		patterns = DefaultDecryptionPatterns
+	}
+	g.decryptor = d
+	g.decryptionPatterns = patterns
+	return g
+}
+
+// WithDecryption wraps the given FileSystem, and decrypts with the given
+// Decryptor the files read through it which match any of the given
+// patterns. Patterns are matched against both the file name and the slash
+// separated path relative to root. If no patterns are given,
+// DefaultDecryptionPatterns are used. The files are decrypted in memory,
+// the underlying FileSystem is never written to.
+func WithDecryption(fs filesys.FileSystem, root string, d Decryptor, patterns ...string) filesys.FileSystem {
+	if len(patterns) == 0 {
+		patterns = DefaultDecryptionPatterns
+	}
+	return &fsDecrypting{FileSystem: fs, root: root, decryptor: d, patterns: patterns}
+}
+
+// fsDecrypting wraps a FileSystem implementation, and decrypts the files
+// read through it.
+type fsDecrypting struct {
+	filesys.FileSystem
+	root      string
+	decryptor Decryptor
+	patterns  []string
+}
+
+// ReadFile delegates to the embedded FS, and decrypts the data of the file
+// if its path matches the patterns.
+func (fs *fsDecrypting) ReadFile(path string) ([]byte, error) {
+	data, err := fs.FileSystem.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	rel := filepath.Base(path)
+	if r, err := filepath.Rel(fs.root, path); err == nil && r != ".." && !strings.HasPrefix(r, ".."+string(filepath.Separator)) {
+		rel = r
+	}
+	ok, err := matchDecryptionPatterns(fs.patterns, filepath.ToSlash(rel))
+	if err != nil || !ok {
+		return data, err
+	}
+
+	out, err := fs.decryptor.Decrypt(data, formatForPath(path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt '%s': %w", rel, err)
+	}
+	return out, nil
+}
+
+// decryptingFS returns the given FileSystem wrapped with the Decryptor of
+// the Generator, if any.
+func (g *Generator) decryptingFS(fs filesys.FileSystem, dirPath string) filesys.FileSystem {
+	if g.decryptor == nil {
+		return fs
+	}
+	root := g.root
+	if root == "" {
+		root = dirPath
+	}
+	return WithDecryption(fs, root, g.decryptor, g.decryptionPatterns...)
+}
+
+func matchDecryptionPatterns(patterns []string, rel string) (bool, error) {
+	for _, p := range patterns {
+		for _, name := range []string{rel, filepath.Base(rel)} {
+			ok, err := filepath.Match(p, name)
+			if err != nil {
+				return false, fmt.Errorf("invalid decryption pattern '%s': %w", p, err)
+			}
+			if ok {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+func formatForPath(path string) DecryptionFormat {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return DecryptionFormatJSON
+	case ".env":
+		return DecryptionFormatDotenv
+	default:
+		return DecryptionFormatYAML
+	}
+}
+
+// IsSOPSEncrypted returns true if the given data in the given format
+// contains SOPS metadata.
+func IsSOPSEncrypted(data []byte, format DecryptionFormat) bool {
+	if format == DecryptionFormatDotenv {
+		for _, line := range strings.Split(string(data), "\n") {
+			if strings.HasPrefix(strings.TrimSpace(line), "sops_mac=") {
+				return true
+			}
+		}
+		return false
+	}
+
+	var doc map[string]interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return false
+	}
+	sops, ok := doc["sops"].(map[string]interface{})
+	if !ok {
+		return false
+	}
+	_, ok = sops["mac"]
+	return ok
+}
@@ -0,0 +1,172 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kustomize
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+
+	securefs "github.com/fluxcd/pkg/kustomize/filesys"
+)
+
+type upperDecryptor struct {
+	formats map[string]DecryptionFormat
+}
+
+func (d *upperDecryptor) Decrypt(data []byte, format DecryptionFormat) ([]byte, error) {
+	d.formats[string(data)] = format
+	return bytes.ToUpper(data), nil
+}
+
+func TestWithDecryption(t *testing.T) {
+	g := NewWithT(t)
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"secret.yaml":       "secret",
+		"app/config.json":   "config",
+		"app/secrets.env":   "env",
+		"app/readme.md":     "readme",
+		"ignored/other.txt": "other",
+	}
+	for name, data := range files {
+		path := filepath.Join(dir, name)
+		g.Expect(os.MkdirAll(filepath.Dir(path), 0o755)).To(Succeed())
+		g.Expect(os.WriteFile(path, []byte(data), 0o644)).To(Succeed())
+	}
+
+	d := &upperDecryptor{formats: map[string]DecryptionFormat{}}
+	fs := WithDecryption(filesys.MakeFsOnDisk(), dir, d)
+	for name, want := range map[string]string{
+		"secret.yaml":       "SECRET",
+		"app/config.json":   "CONFIG",
+		"app/secrets.env":   "ENV",
+		"app/readme.md":     "readme",
+		"ignored/other.txt": "other",
+	} {
+		data, err := fs.ReadFile(filepath.Join(dir, name))
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(string(data)).To(Equal(want))
+	}
+	g.Expect(d.formats).To(Equal(map[string]DecryptionFormat{
+		"secret": DecryptionFormatYAML,
+		"config": DecryptionFormatJSON,
+		"env":    DecryptionFormatDotenv,
+	}))
+
+	// The files are not decrypted on disk.
+	for name, want := range files {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(string(data)).To(Equal(want))
+	}
+
+	d = &upperDecryptor{formats: map[string]DecryptionFormat{}}
+	fs = WithDecryption(filesys.MakeFsOnDisk(), dir, d, "app/*.md")
+	for name := range files {
+		_, err := fs.ReadFile(filepath.Join(dir, name))
+		g.Expect(err).ToNot(HaveOccurred())
+	}
+	g.Expect(d.formats).To(HaveLen(1))
+	g.Expect(d.formats).To(HaveKey("readme"))
+}
+
+type replaceDecryptor struct {
+	old, new string
+}
+
+func (d *replaceDecryptor) Decrypt(data []byte, _ DecryptionFormat) ([]byte, error) {
+	return bytes.ReplaceAll(data, []byte(d.old), []byte(d.new)), nil
+}
+
+func TestGenerator_CachedBuildWithDecryptor(t *testing.T) {
+	g := NewWithT(t)
+
+	dir := t.TempDir()
+	secret := `apiVersion: v1
+kind: Secret
+metadata:
+  name: app
+stringData:
+  password: ENCRYPTED
+`
+	g.Expect(os.WriteFile(filepath.Join(dir, "secret.yaml"), []byte(secret), 0o644)).To(Succeed())
+
+	output, _, err := NewGenerator(dir, unstructured.Unstructured{}).
+		WithDecryptor(&replaceDecryptor{old: "ENCRYPTED", new: "s3cr3t"}).
+		CachedBuild(dir, false, securefs.Limits{})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(string(output)).To(ContainSubstring("password: s3cr3t"))
+
+	data, err := os.ReadFile(filepath.Join(dir, "secret.yaml"))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(string(data)).To(Equal(secret))
+	entries, err := os.ReadDir(dir)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(entries).To(HaveLen(1))
+}
+
+func TestIsSOPSEncrypted(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   string
+		format DecryptionFormat
+		want   bool
+	}{
+		{
+			name:   "encrypted yaml",
+			data:   "data: ENC[AES256_GCM,data:abc]\nsops:\n  mac: ENC[AES256_GCM,data:def]\n",
+			format: DecryptionFormatYAML,
+			want:   true,
+		},
+		{
+			name:   "plain yaml",
+			data:   "apiVersion: v1\nkind: Secret\n",
+			format: DecryptionFormatYAML,
+		},
+		{
+			name:   "encrypted json",
+			data:   `{"data": "ENC[AES256_GCM,data:abc]", "sops": {"mac": "ENC[AES256_GCM,data:def]"}}`,
+			format: DecryptionFormatJSON,
+			want:   true,
+		},
+		{
+			name:   "encrypted dotenv",
+			data:   "KEY=ENC[AES256_GCM,data:abc]\nsops_mac=ENC[AES256_GCM,data:def]\n",
+			format: DecryptionFormatDotenv,
+			want:   true,
+		},
+		{
+			name:   "plain dotenv",
+			data:   "KEY=value\n",
+			format: DecryptionFormatDotenv,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			g.Expect(IsSOPSEncrypted([]byte(tt.data), tt.format)).To(Equal(tt.want))
+		})
+	}
+}
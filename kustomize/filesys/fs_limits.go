@@ -0,0 +1,159 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package filesys
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+var (
+	// ErrFileTooLarge is returned when a file exceeds Limits.MaxFileSize.
+	ErrFileTooLarge = errors.New("file size exceeds limit")
+	// ErrTotalSizeExceeded is returned when reading a file would exceed
+	// Limits.MaxTotalSize.
+	ErrTotalSizeExceeded = errors.New("total size of read files exceeds limit")
+)
+
+// Limits configures the size limits enforced by a file system returned by
+// WithLimits. A zero value disables the respective limit.
+type Limits struct {
+	// MaxFileSize is the maximum size in bytes of a single file.
+	MaxFileSize int64
+	// MaxTotalSize is the maximum sum in bytes of the sizes of all the
+	// files read or opened through the file system.
+	MaxTotalSize int64
+}
+
+// WithLimits wraps the given FileSystem, and enforces the given limits on
+// the files read or opened through it. When a limit is exceeded, an error of
+// type ConstraintError is returned for the offending path.
+func WithLimits(fs filesys.FileSystem, limits Limits) filesys.FileSystem {
+	return &fsLimited{FileSystem: fs, limits: limits}
+}
+
+// MakeFsOnDiskSecureWithLimits calls MakeFsOnDiskSecure, and wraps the
+// result with the given limits.
+func MakeFsOnDiskSecureWithLimits(root string, limits Limits, allowPrefixes ...string) (filesys.FileSystem, error) {
+	fs, err := MakeFsOnDiskSecure(root, allowPrefixes...)
+	if err != nil {
+		return nil, err
+	}
+	return WithLimits(fs, limits), nil
+}
+
+// fsLimited wraps a FileSystem implementation, and limits the size of the
+// files read through it.
+type fsLimited struct {
+	filesys.FileSystem
+	limits Limits
+
+	mu    sync.Mutex
+	total int64
+}
+
+// Open delegates to the embedded FS, and confirms the size of the opened
+// file to be within limits. If it is not, the file is closed and an error
+// of type ConstraintError is returned.
+func (fs *fsLimited) Open(path string) (filesys.File, error) {
+	f, err := fs.FileSystem.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if !info.IsDir() {
+		if err := fs.reserve(info.Size()); err != nil {
+			f.Close()
+			return nil, &ConstraintError{Op: "open", Path: path, Err: err}
+		}
+	}
+	return f, nil
+}
+
+// ReadFile confirms the size of the file to be within limits before
+// delegating to the embedded FS. If it is not, an error of type
+// ConstraintError is returned.
+func (fs *fsLimited) ReadFile(path string) ([]byte, error) {
+	size, err := fs.size(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := fs.reserve(size); err != nil {
+		return nil, &ConstraintError{Op: "read", Path: path, Err: err}
+	}
+	data, err := fs.FileSystem.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	// Guard against the file having grown in between.
+	if grown := int64(len(data)) - size; grown > 0 {
+		if err := fs.checkFileSize(int64(len(data))); err != nil {
+			return nil, &ConstraintError{Op: "read", Path: path, Err: err}
+		}
+		if err := fs.reserve(grown); err != nil {
+			return nil, &ConstraintError{Op: "read", Path: path, Err: err}
+		}
+	}
+	return data, nil
+}
+
+// size returns the size of the file at the given path, as reported by the
+// embedded FS.
+func (fs *fsLimited) size(path string) (int64, error) {
+	f, err := fs.FileSystem.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+// checkFileSize returns an error if the given size exceeds the maximum
+// file size.
+func (fs *fsLimited) checkFileSize(size int64) error {
+	if max := fs.limits.MaxFileSize; max > 0 && size > max {
+		return fmt.Errorf("%w: %d > %d bytes", ErrFileTooLarge, size, max)
+	}
+	return nil
+}
+
+// reserve adds the given size to the total, or returns an error if this
+// would exceed the limits.
+func (fs *fsLimited) reserve(size int64) error {
+	if err := fs.checkFileSize(size); err != nil {
+		return err
+	}
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if max := fs.limits.MaxTotalSize; max > 0 && fs.total+size > max {
+		return fmt.Errorf("%w: %d > %d bytes", ErrTotalSizeExceeded, fs.total+size, max)
+	}
+	fs.total += size
+	return nil
+}
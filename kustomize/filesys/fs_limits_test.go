@@ -0,0 +1,91 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package filesys
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func Test_fsLimited(t *testing.T) {
+	g := NewWithT(t)
+
+	tmpDir, err := testTempDir(t)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	root := filepath.Join(tmpDir, "workdir")
+	g.Expect(os.Mkdir(root, 0o700)).To(Succeed())
+	g.Expect(os.WriteFile(filepath.Join(root, "small.txt"), []byte("small"), 0o600)).To(Succeed())
+	g.Expect(os.WriteFile(filepath.Join(root, "medium.txt"), []byte(strings.Repeat("m", 8)), 0o600)).To(Succeed())
+	g.Expect(os.WriteFile(filepath.Join(root, "large.txt"), []byte(strings.Repeat("l", 16)), 0o600)).To(Succeed())
+	g.Expect(os.WriteFile(filepath.Join(tmpDir, "host.txt"), []byte("host"), 0o600)).To(Succeed())
+	g.Expect(os.Symlink(filepath.Join(tmpDir, "host.txt"), filepath.Join(root, "escape.txt"))).To(Succeed())
+
+	t.Run("max file size", func(t *testing.T) {
+		g := NewWithT(t)
+
+		fs, err := MakeFsOnDiskSecureWithLimits(root, Limits{MaxFileSize: 10})
+		g.Expect(err).ToNot(HaveOccurred())
+
+		b, err := fs.ReadFile(filepath.Join(root, "medium.txt"))
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(b).To(HaveLen(8))
+
+		path := filepath.Join(root, "large.txt")
+		_, err = fs.ReadFile(path)
+		g.Expect(errors.Is(err, ErrFileTooLarge)).To(BeTrue())
+		var cErr *ConstraintError
+		g.Expect(errors.As(err, &cErr)).To(BeTrue())
+		g.Expect(cErr.Path).To(Equal(path))
+
+		_, err = fs.Open(path)
+		g.Expect(errors.Is(err, ErrFileTooLarge)).To(BeTrue())
+	})
+
+	t.Run("max total size", func(t *testing.T) {
+		g := NewWithT(t)
+
+		fs, err := MakeFsOnDiskSecureWithLimits(root, Limits{MaxTotalSize: 15})
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = fs.ReadFile(filepath.Join(root, "small.txt"))
+		g.Expect(err).ToNot(HaveOccurred())
+		f, err := fs.Open(filepath.Join(root, "medium.txt"))
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(f.Close()).To(Succeed())
+
+		_, err = fs.ReadFile(filepath.Join(root, "small.txt"))
+		g.Expect(errors.Is(err, ErrTotalSizeExceeded)).To(BeTrue())
+	})
+
+	t.Run("symlink escape", func(t *testing.T) {
+		g := NewWithT(t)
+
+		fs, err := MakeFsOnDiskSecureWithLimits(root, Limits{MaxFileSize: 10})
+		g.Expect(err).ToNot(HaveOccurred())
+
+		path := filepath.Join(root, "escape.txt")
+		_, err = fs.ReadFile(path)
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("resolves to '" + filepath.Join(tmpDir, "host.txt") + "'"))
+	})
+}
@@ -252,8 +252,9 @@ func isSecurePath(fs filesys.FileSystem, root filesys.ConfirmedDir, path string,
 		return fmt.Errorf("abs path error on '%s': %v", path, err)
 	}
 	d := filesys.ConfirmedDir(absRoot)
+	var evaluated string
 	if fs.Exists(absRoot) {
-		evaluated, err := filepath.EvalSymlinks(absRoot)
+		evaluated, err = filepath.EvalSymlinks(absRoot)
 		if err != nil {
 			return fmt.Errorf("evalsymlink failure on '%s': %w", path, err)
 		}
@@ -267,6 +268,9 @@ func isSecurePath(fs filesys.FileSystem, root filesys.ConfirmedDir, path string,
 		return nil
 	}
 	if !d.HasPrefix(root) {
+		if evaluated != "" && evaluated != absRoot {
+			return symlinkConstraintErr(path, evaluated, root.String())
+		}
 		return rootConstraintErr(path, root.String())
 	}
 	return nil
@@ -276,6 +280,10 @@ func rootConstraintErr(path, root string) error {
 	return fmt.Errorf("path '%s' is not in or below '%s'", path, root)
 }
 
+func symlinkConstraintErr(path, target, root string) error {
+	return fmt.Errorf("path '%s' resolves to '%s' which is not in or below '%s'", path, target, root)
+}
+
 func hasOneOfPrefixes(s string, prefixes []string) (bool, string) {
 	for _, p := range prefixes {
 		if strings.HasPrefix(s, p) {
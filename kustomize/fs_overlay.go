@@ -0,0 +1,94 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kustomize
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	securejoin "github.com/cyphar/filepath-securejoin"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+// securePath returns the given absolute path with its symlinks resolved
+// within the root, or an error if the path is outside of the root.
+func securePath(root, path string) (string, error) {
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("path '%s' is outside of root '%s'", path, root)
+	}
+	return securejoin.SecureJoin(root, rel)
+}
+
+// fsOverlay is a FileSystem serving the given files, keyed by absolute path,
+// on top of the embedded FileSystem. It is used to build rewritten copies of
+// kustomization files without modifying them.
+type fsOverlay struct {
+	filesys.FileSystem
+	files map[string][]byte
+}
+
+// newFSOverlay returns a FileSystem serving the given files, keyed by
+// absolute path, on top of fs.
+func newFSOverlay(fs filesys.FileSystem, files map[string][]byte) *fsOverlay {
+	overlay := &fsOverlay{FileSystem: fs, files: make(map[string][]byte, len(files))}
+	for p, data := range files {
+		overlay.files[overlay.abs(p)] = data
+	}
+	return overlay
+}
+
+// ReadFile returns the data of the overlay file at the given path, or
+// delegates to the embedded FS.
+func (fs *fsOverlay) ReadFile(path string) ([]byte, error) {
+	if data, ok := fs.files[fs.abs(path)]; ok {
+		return append([]byte(nil), data...), nil
+	}
+	return fs.FileSystem.ReadFile(path)
+}
+
+// Exists returns true if the path is an overlay file, or delegates to the
+// embedded FS.
+func (fs *fsOverlay) Exists(path string) bool {
+	if _, ok := fs.files[fs.abs(path)]; ok {
+		return true
+	}
+	return fs.FileSystem.Exists(path)
+}
+
+// IsDir returns false if the path is an overlay file, or delegates to the
+// embedded FS.
+func (fs *fsOverlay) IsDir(path string) bool {
+	if _, ok := fs.files[fs.abs(path)]; ok {
+		return false
+	}
+	return fs.FileSystem.IsDir(path)
+}
+
+// abs returns the absolute path, with the symlinks of its directory
+// resolved as kustomize does.
+func (fs *fsOverlay) abs(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	if dir, err := filepath.EvalSymlinks(filepath.Dir(abs)); err == nil {
+		return filepath.Join(dir, filepath.Base(abs))
+	}
+	return abs
+}
@@ -0,0 +1,316 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kustomize
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"sigs.k8s.io/kustomize/api/konfig"
+	kustypes "sigs.k8s.io/kustomize/api/types"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+	"sigs.k8s.io/yaml"
+)
+
+// ErrHelmChartNetwork is returned when a Helm chart must be pulled from its
+// repository while network access is not allowed.
+var ErrHelmChartNetwork = errors.New("helm charts can not be pulled without network access")
+
+// HelmChartRenderer renders Helm charts. It is implemented by the caller
+// with a pinned version of the Helm library, so that the inflation neither
+// depends on a helm binary nor on the plugins of kustomize.
+type HelmChartRenderer interface {
+	// Render renders the templates of the chart in chartDir with the given
+	// values, and returns the manifests as a multi-document YAML.
+	Render(chartDir string, chart kustypes.HelmChart, values map[string]interface{}) ([]byte, error)
+}
+
+// HelmChartFetcher pulls the given version of a chart from a repository,
+// and extracts it into the given directory.
+type HelmChartFetcher interface {
+	Fetch(repo, name, version, dir string) error
+}
+
+// HelmChartOptions configures how the helmCharts of kustomizations are
+// inflated by InflateHelmCharts.
+type HelmChartOptions struct {
+	// Renderer is used to render the charts.
+	Renderer HelmChartRenderer
+	// Fetcher is used to pull the charts which are not found in the chart
+	// home directory, if AllowNetwork is true.
+	Fetcher HelmChartFetcher
+	// AllowNetwork allows pulling the charts from their repository.
+	// Charts which are not found in the chart home directory result in
+	// ErrHelmChartNetwork otherwise.
+	AllowNetwork bool
+	// Values are merged on top of the values of the charts, keyed by
+	// '<namespace>/<release name>', or '<release name>' if the chart has no
+	// namespace. The release name defaults to the chart name.
+	Values map[string]map[string]interface{}
+	// Root is the directory outside of which the chart home directories,
+	// values files and bases can not be read. Defaults to the kustomization
+	// directory.
+	Root string
+}
+
+// helmChartFilePrefix is the prefix of the files into which the rendered
+// charts are served, in the kustomization directory.
+const helmChartFilePrefix = ".helm-chart-"
+
+// InflateHelmCharts renders the helmCharts of the kustomization in dirPath,
+// and of the local bases it references, with the configured renderer. It
+// returns a FileSystem which serves, on top of the given one, copies of the
+// kustomization files referencing the rendered manifests as resources
+// instead, so that the build does not require the Helm plugin of kustomize
+// to be enabled. The kustomization files are not modified, only the charts
+// pulled by the fetcher are written to their chart home directory.
+func InflateHelmCharts(fs filesys.FileSystem, dirPath string, opts HelmChartOptions) (filesys.FileSystem, error) {
+	if opts.Renderer == nil {
+		return nil, errors.New("no renderer configured for helm charts")
+	}
+	if opts.Root == "" {
+		opts.Root = dirPath
+	}
+	absRoot, err := filepath.Abs(opts.Root)
+	if err != nil {
+		return nil, err
+	}
+	opts.Root = absRoot
+
+	i := &helmChartInflater{
+		fs:      fs,
+		opts:    opts,
+		visited: make(map[string]bool),
+		files:   make(map[string][]byte),
+	}
+	abs, err := filepath.Abs(dirPath)
+	if err != nil {
+		return nil, err
+	}
+	if err := i.inflateDir(abs); err != nil {
+		return nil, err
+	}
+
+	return newFSOverlay(fs, i.files), nil
+}
+
+type helmChartInflater struct {
+	fs      filesys.FileSystem
+	opts    HelmChartOptions
+	visited map[string]bool
+	// files are the rewritten kustomization files and the rendered charts,
+	// keyed by absolute path.
+	files map[string][]byte
+}
+
+// inflateDir inflates the helmCharts of the kustomization in the given
+// absolute directory, if any.
+func (i *helmChartInflater) inflateDir(dir string) error {
+	abs, err := securePath(i.opts.Root, dir)
+	if err != nil {
+		return err
+	}
+	if i.visited[abs] {
+		return nil
+	}
+	i.visited[abs] = true
+
+	var kfile string
+	for _, name := range konfig.RecognizedKustomizationFileNames() {
+		if p := filepath.Join(abs, name); i.fs.Exists(p) && !i.fs.IsDir(p) {
+			kfile = p
+			break
+		}
+	}
+	if kfile == "" {
+		return nil
+	}
+
+	data, err := i.fs.ReadFile(kfile)
+	if err != nil {
+		return err
+	}
+	var kus kustypes.Kustomization
+	if err := yaml.Unmarshal(data, &kus); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", kfile, err)
+	}
+	// Convert the deprecated helmChartInflationGenerator field.
+	kus.FixKustomization()
+
+	for _, refs := range [][]string{kus.Resources, kus.Components} {
+		for _, ref := range refs {
+			if IsLocalRelativePath(ref) {
+				if err := i.inflateDir(filepath.Join(abs, ref)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	if len(kus.HelmCharts) == 0 {
+		return nil
+	}
+
+	chartHome := filepath.Join(abs, "charts")
+	if kus.HelmGlobals != nil && kus.HelmGlobals.ChartHome != "" {
+		chartHome = filepath.Join(abs, kus.HelmGlobals.ChartHome)
+	}
+	if chartHome, err = securePath(i.opts.Root, chartHome); err != nil {
+		return err
+	}
+
+	keys := make(map[string]bool, len(kus.HelmCharts))
+	for n, chart := range kus.HelmCharts {
+		key := helmChartKey(chart)
+		if keys[key] {
+			return fmt.Errorf("duplicate helm chart release '%s' in %s", key, kfile)
+		}
+		keys[key] = true
+
+		out, err := i.inflate(abs, chartHome, chart)
+		if err != nil {
+			return fmt.Errorf("failed to inflate helm chart '%s': %w", chart.Name, err)
+		}
+		// The index keeps the names unique, the key is only informative.
+		name := fmt.Sprintf("%s%d-%s.yaml", helmChartFilePrefix, n, strings.ReplaceAll(key, "/", "_"))
+		i.files[filepath.Join(abs, name)] = out
+		kus.Resources = append(kus.Resources, name)
+	}
+	kus.HelmCharts = nil
+	kus.HelmGlobals = nil
+
+	out, err := yaml.Marshal(kus)
+	if err != nil {
+		return err
+	}
+	i.files[kfile] = out
+	return nil
+}
+
+// inflate renders the given chart of the kustomization in dir.
+func (i *helmChartInflater) inflate(dir, chartHome string, chart kustypes.HelmChart) ([]byte, error) {
+	if chart.Name == "" {
+		return nil, errors.New("chart name is required")
+	}
+
+	// Follow the layout of the chart home directory used by kustomize.
+	chartDir := filepath.Join(chartHome, chart.Name)
+	if chart.Version != "" {
+		chartDir = filepath.Join(chartHome, chart.Name+"-"+chart.Version, chart.Name)
+	}
+	chartDir, err := securePath(i.opts.Root, chartDir)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(chartDir); err != nil {
+		if !os.IsNotExist(err) {
+			return nil, err
+		}
+		switch {
+		case chart.Repo == "":
+			return nil, fmt.Errorf("chart not found in '%s' and no repository specified", chartDir)
+		case !i.opts.AllowNetwork:
+			return nil, fmt.Errorf("%w: '%s'", ErrHelmChartNetwork, chart.Repo)
+		case i.opts.Fetcher == nil:
+			return nil, fmt.Errorf("no fetcher configured for repository '%s'", chart.Repo)
+		}
+		if err := i.opts.Fetcher.Fetch(chart.Repo, chart.Name, chart.Version, filepath.Dir(chartDir)); err != nil {
+			return nil, fmt.Errorf("failed to pull chart from '%s': %w", chart.Repo, err)
+		}
+	}
+
+	values, err := i.values(dir, chart)
+	if err != nil {
+		return nil, err
+	}
+	return i.opts.Renderer.Render(chartDir, chart, values)
+}
+
+// values returns the values of the chart, composed of the values files,
+// the inline values and the values injected with the options.
+func (i *helmChartInflater) values(dir string, chart kustypes.HelmChart) (map[string]interface{}, error) {
+	values := map[string]interface{}{}
+	files := chart.AdditionalValuesFiles
+	if chart.ValuesFile != "" {
+		files = append([]string{chart.ValuesFile}, files...)
+	}
+	for _, f := range files {
+		path, err := securePath(i.opts.Root, filepath.Join(dir, f))
+		if err != nil {
+			return nil, err
+		}
+		data, err := i.fs.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read values file: %w", err)
+		}
+		var v map[string]interface{}
+		if err := yaml.Unmarshal(data, &v); err != nil {
+			return nil, fmt.Errorf("failed to parse values file '%s': %w", f, err)
+		}
+		values = mergeValues(values, v)
+	}
+
+	switch strings.ToLower(chart.ValuesMerge) {
+	case "", "override":
+		values = mergeValues(values, chart.ValuesInline)
+	case "merge":
+		values = mergeValues(chart.ValuesInline, values)
+	case "replace":
+		if chart.ValuesInline != nil {
+			values = chart.ValuesInline
+		}
+	default:
+		return nil, fmt.Errorf("invalid valuesMerge '%s': must be one of 'override', 'merge' or 'replace'", chart.ValuesMerge)
+	}
+
+	return mergeValues(values, i.opts.Values[helmChartKey(chart)]), nil
+}
+
+// helmChartKey returns the key of the chart in HelmChartOptions.Values,
+// '<namespace>/<release name>', or '<release name>' if the chart has no
+// namespace. The release name defaults to the chart name.
+func helmChartKey(chart kustypes.HelmChart) string {
+	release := chart.ReleaseName
+	if release == "" {
+		release = chart.Name
+	}
+	if chart.Namespace != "" {
+		return chart.Namespace + "/" + release
+	}
+	return release
+}
+
+// mergeValues returns the deep merge of the override values on top of the
+// base values. Nested maps are merged, while other values are replaced.
+func mergeValues(base, override map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(base))
+	for k, v := range base {
+		out[k] = v
+	}
+	for k, v := range override {
+		if vm, ok := v.(map[string]interface{}); ok {
+			if bm, ok := out[k].(map[string]interface{}); ok {
+				out[k] = mergeValues(bm, vm)
+				continue
+			}
+		}
+		out[k] = v
+	}
+	return out
+}
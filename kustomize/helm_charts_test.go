@@ -0,0 +1,209 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kustomize_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	kustypes "sigs.k8s.io/kustomize/api/types"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+
+	"github.com/fluxcd/pkg/kustomize"
+)
+
+type fakeRenderer struct{}
+
+func (fakeRenderer) Render(chartDir string, chart kustypes.HelmChart, values map[string]interface{}) ([]byte, error) {
+	return []byte(fmt.Sprintf(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: %s
+  namespace: "%s"
+data:
+  chart: %s
+  replicas: "%v"
+`, chart.ReleaseName, chart.Namespace, filepath.Base(chartDir), values["replicas"])), nil
+}
+
+type fakeChartFetcher struct {
+	pulled []string
+}
+
+func (f *fakeChartFetcher) Fetch(repo, name, version, dir string) error {
+	f.pulled = append(f.pulled, repo+"/"+name+":"+version)
+	return os.MkdirAll(filepath.Join(dir, name), 0o755)
+}
+
+func TestInflateHelmCharts(t *testing.T) {
+	g := NewWithT(t)
+
+	tmpDir := t.TempDir()
+	g.Expect(os.MkdirAll(filepath.Join(tmpDir, "charts", "app"), 0o755)).To(Succeed())
+	g.Expect(os.WriteFile(filepath.Join(tmpDir, "values.yaml"), []byte("replicas: 1\n"), 0o644)).To(Succeed())
+	kus := []byte(`apiVersion: kustomize.config.k8s.io/v1beta1
+kind: Kustomization
+# The comments are kept.
+namespace: apps
+helmCharts:
+- name: app
+  releaseName: frontend
+  valuesFile: values.yaml
+  valuesInline:
+    replicas: 2
+- name: app
+  releaseName: backend
+  valuesFile: values.yaml
+`)
+	g.Expect(os.WriteFile(filepath.Join(tmpDir, "kustomization.yaml"), kus, 0o644)).To(Succeed())
+
+	fs, err := kustomize.InflateHelmCharts(filesys.MakeFsOnDisk(), tmpDir, kustomize.HelmChartOptions{
+		Renderer: fakeRenderer{},
+		Values: map[string]map[string]interface{}{
+			"backend": {"replicas": 3},
+		},
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+
+	// The kustomization is not modified.
+	data, err := os.ReadFile(filepath.Join(tmpDir, "kustomization.yaml"))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(data).To(Equal(kus))
+
+	resMap, err := kustomize.Build(fs, tmpDir)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(resMap.Resources()).To(HaveLen(2))
+
+	replicas := map[string]string{}
+	for _, res := range resMap.Resources() {
+		g.Expect(res.GetNamespace()).To(Equal("apps"))
+		data := res.GetDataMap()
+		g.Expect(data["chart"]).To(Equal("app"))
+		replicas[res.GetName()] = data["replicas"]
+	}
+	g.Expect(replicas).To(Equal(map[string]string{"frontend": "2", "backend": "3"}))
+}
+
+func TestInflateHelmCharts_Network(t *testing.T) {
+	g := NewWithT(t)
+
+	tmpDir := t.TempDir()
+	g.Expect(os.WriteFile(filepath.Join(tmpDir, "kustomization.yaml"), []byte(`apiVersion: kustomize.config.k8s.io/v1beta1
+kind: Kustomization
+helmCharts:
+- name: app
+  version: 1.0.0
+  repo: https://charts.example.com
+  releaseName: app
+`), 0o644)).To(Succeed())
+
+	_, err := kustomize.InflateHelmCharts(filesys.MakeFsOnDisk(), tmpDir, kustomize.HelmChartOptions{Renderer: fakeRenderer{}})
+	g.Expect(err).To(MatchError(kustomize.ErrHelmChartNetwork))
+
+	fetcher := &fakeChartFetcher{}
+	_, err = kustomize.InflateHelmCharts(filesys.MakeFsOnDisk(), tmpDir, kustomize.HelmChartOptions{
+		Renderer:     fakeRenderer{},
+		Fetcher:      fetcher,
+		AllowNetwork: true,
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(fetcher.pulled).To(Equal([]string{"https://charts.example.com/app:1.0.0"}))
+	g.Expect(filepath.Join(tmpDir, "charts", "app-1.0.0", "app")).To(BeADirectory())
+}
+
+func TestInflateHelmCharts_OutsideRoot(t *testing.T) {
+	g := NewWithT(t)
+
+	tmpDir := t.TempDir()
+	g.Expect(os.WriteFile(filepath.Join(tmpDir, "kustomization.yaml"), []byte(`apiVersion: kustomize.config.k8s.io/v1beta1
+kind: Kustomization
+helmGlobals:
+  chartHome: ../charts
+helmCharts:
+- name: app
+`), 0o644)).To(Succeed())
+
+	_, err := kustomize.InflateHelmCharts(filesys.MakeFsOnDisk(), tmpDir, kustomize.HelmChartOptions{Renderer: fakeRenderer{}})
+	g.Expect(err).To(MatchError(ContainSubstring("outside of root")))
+
+	// Symlinks are resolved within the root.
+	outside := t.TempDir()
+	g.Expect(os.WriteFile(filepath.Join(outside, "values.yaml"), []byte("replicas: 5\n"), 0o644)).To(Succeed())
+	g.Expect(os.MkdirAll(filepath.Join(tmpDir, "charts", "app"), 0o755)).To(Succeed())
+	g.Expect(os.Symlink(filepath.Join(outside, "values.yaml"), filepath.Join(tmpDir, "values.yaml"))).To(Succeed())
+	g.Expect(os.WriteFile(filepath.Join(tmpDir, "kustomization.yaml"), []byte(`apiVersion: kustomize.config.k8s.io/v1beta1
+kind: Kustomization
+helmCharts:
+- name: app
+  valuesFile: values.yaml
+`), 0o644)).To(Succeed())
+
+	_, err = kustomize.InflateHelmCharts(filesys.MakeFsOnDisk(), tmpDir, kustomize.HelmChartOptions{Renderer: fakeRenderer{}})
+	g.Expect(err).To(MatchError(ContainSubstring("failed to read values file")))
+}
+
+func TestInflateHelmCharts_Releases(t *testing.T) {
+	g := NewWithT(t)
+
+	tmpDir := t.TempDir()
+	g.Expect(os.MkdirAll(filepath.Join(tmpDir, "charts", "app"), 0o755)).To(Succeed())
+	g.Expect(os.MkdirAll(filepath.Join(tmpDir, "charts", "frontend"), 0o755)).To(Succeed())
+	g.Expect(os.WriteFile(filepath.Join(tmpDir, "kustomization.yaml"), []byte(`apiVersion: kustomize.config.k8s.io/v1beta1
+kind: Kustomization
+helmCharts:
+- name: app
+  releaseName: frontend
+  namespace: dev
+- name: app
+  releaseName: frontend
+  namespace: prod
+- name: frontend
+  releaseName: web
+  namespace: prod
+`), 0o644)).To(Succeed())
+
+	fs, err := kustomize.InflateHelmCharts(filesys.MakeFsOnDisk(), tmpDir, kustomize.HelmChartOptions{
+		Renderer: fakeRenderer{},
+		Values: map[string]map[string]interface{}{
+			"dev/frontend":  {"replicas": 1},
+			"prod/frontend": {"replicas": 3},
+		},
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+
+	resMap, err := kustomize.Build(fs, tmpDir)
+	g.Expect(err).ToNot(HaveOccurred())
+	var replicas []string
+	for _, res := range resMap.Resources() {
+		replicas = append(replicas, res.GetDataMap()["replicas"])
+	}
+	g.Expect(replicas).To(Equal([]string{"1", "3", "<nil>"}))
+
+	// The same release in the same namespace is ambiguous.
+	g.Expect(os.WriteFile(filepath.Join(tmpDir, "kustomization.yaml"), []byte(`apiVersion: kustomize.config.k8s.io/v1beta1
+kind: Kustomization
+helmCharts:
+- name: app
+  releaseName: frontend
+- name: frontend
+`), 0o644)).To(Succeed())
+	_, err = kustomize.InflateHelmCharts(filesys.MakeFsOnDisk(), tmpDir, kustomize.HelmChartOptions{Renderer: fakeRenderer{}})
+	g.Expect(err).To(MatchError(ContainSubstring("duplicate helm chart release 'frontend'")))
+}
@@ -0,0 +1,188 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kustomize
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"sigs.k8s.io/kustomize/api/resmap"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	kyaml "sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// ImagePolicyMarker is the key of the JSON object in the line comment of a
+// field, which marks the field to be set to the image resolved by a Flux
+// image policy, e.g.:
+//
+//	image: ghcr.io/org/app:1.0.0 # {"$imagepolicy": "flux-system:app"}
+//
+// The value of the marker is the '<namespace>:<name>' of the policy,
+// optionally followed by ':tag', ':name' or ':digest' to only set the
+// corresponding part of the image.
+const ImagePolicyMarker = "$imagepolicy"
+
+// ErrMissingImagePolicy is returned in strict mode when a marker references
+// an image policy for which no image is supplied.
+var ErrMissingImagePolicy = errors.New("image policy not found")
+
+// ImagePolicyTransformer sets the fields marked with an ImagePolicyMarker
+// to the images resolved for the referenced policies.
+type ImagePolicyTransformer struct {
+	// Images maps the '<namespace>:<name>' of image policies to their
+	// resolved image, e.g. 'ghcr.io/org/app:1.2.3' or
+	// 'ghcr.io/org/app:1.2.3@sha256:...'.
+	Images map[string]string
+
+	// Strict makes the transformation fail with ErrMissingImagePolicy when
+	// a marker references a policy which is not in Images. Otherwise, the
+	// field is left unchanged.
+	Strict bool
+}
+
+// Transform sets the marked fields of the resources in the ResMap.
+func (t ImagePolicyTransformer) Transform(m resmap.ResMap) error {
+	for _, res := range m.Resources() {
+		if err := t.setNode(res.YNode()); err != nil {
+			return fmt.Errorf("%s: %w", res.CurId(), err)
+		}
+	}
+	return nil
+}
+
+// TransformYAML sets the marked fields of the given multi-document YAML,
+// preserving its comments.
+func (t ImagePolicyTransformer) TransformYAML(data []byte) ([]byte, error) {
+	nodes, err := kio.FromBytes(data)
+	if err != nil {
+		return nil, err
+	}
+	for _, node := range nodes {
+		if err := t.setNode(node.YNode()); err != nil {
+			return nil, err
+		}
+	}
+	out, err := kio.StringAll(nodes)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(out), nil
+}
+
+// setNode walks the given node and sets the value of the marked scalars.
+func (t ImagePolicyTransformer) setNode(node *kyaml.Node) error {
+	if node == nil {
+		return nil
+	}
+	if node.Kind == kyaml.ScalarNode && node.LineComment != "" {
+		policy, field, ok, err := parseImagePolicyMarker(node.LineComment)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", node.Line, err)
+		}
+		if ok {
+			image, found := t.Images[policy]
+			if !found {
+				if t.Strict {
+					return fmt.Errorf("line %d: %w: '%s'", node.Line, ErrMissingImagePolicy, policy)
+				}
+				return nil
+			}
+			value, err := imageField(image, field)
+			if err != nil {
+				return fmt.Errorf("line %d: image policy '%s': %w", node.Line, policy, err)
+			}
+			node.Value = value
+			node.Tag = kyaml.NodeTagString
+		}
+		return nil
+	}
+	for _, n := range node.Content {
+		if err := t.setNode(n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseImagePolicyMarker returns the '<namespace>:<name>' of the policy
+// and the image field referenced by the marker in the given comment.
+// It returns false if the comment does not hold a marker.
+func parseImagePolicyMarker(comment string) (string, string, bool, error) {
+	comment = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(comment), "#"))
+	if !strings.HasPrefix(comment, "{") || !strings.Contains(comment, ImagePolicyMarker) {
+		return "", "", false, nil
+	}
+	var marker map[string]string
+	if err := json.Unmarshal([]byte(comment), &marker); err != nil {
+		return "", "", false, fmt.Errorf("invalid image policy marker '%s': %w", comment, err)
+	}
+	ref, ok := marker[ImagePolicyMarker]
+	if !ok {
+		return "", "", false, nil
+	}
+
+	parts := strings.Split(ref, ":")
+	switch {
+	case len(parts) == 2 && parts[0] != "" && parts[1] != "":
+		return ref, "", true, nil
+	case len(parts) == 3 && parts[0] != "" && parts[1] != "":
+		switch parts[2] {
+		case "tag", "name", "digest":
+			return parts[0] + ":" + parts[1], parts[2], true, nil
+		}
+	}
+	return "", "", false, fmt.Errorf("invalid image policy reference '%s', must be '<namespace>:<name>[:tag|:name|:digest]'", ref)
+}
+
+// imageField returns the given field of the image, or the image itself if
+// the field is empty.
+func imageField(image, field string) (string, error) {
+	name, tag, digest := splitImage(image)
+	switch field {
+	case "":
+		return image, nil
+	case "name":
+		return name, nil
+	case "tag":
+		if tag == "" {
+			return "", fmt.Errorf("image '%s' has no tag", image)
+		}
+		return tag, nil
+	case "digest":
+		if digest == "" {
+			return "", fmt.Errorf("image '%s' has no digest", image)
+		}
+		return digest, nil
+	default:
+		return "", fmt.Errorf("unknown image field '%s'", field)
+	}
+}
+
+// splitImage splits the given image reference into its name, tag and
+// digest.
+func splitImage(image string) (name, tag, digest string) {
+	name = image
+	if i := strings.Index(name, "@"); i >= 0 {
+		name, digest = name[:i], name[i+1:]
+	}
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		name, tag = name[:i], name[i+1:]
+	}
+	return name, tag, digest
+}
@@ -0,0 +1,153 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kustomize
+
+import (
+	"errors"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"sigs.k8s.io/kustomize/api/provider"
+	"sigs.k8s.io/kustomize/api/resmap"
+)
+
+const imagePolicyInput = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+spec:
+  template:
+    spec:
+      containers:
+      - name: app
+        image: ghcr.io/org/app:1.0.0 # {"$imagepolicy": "flux-system:app"}
+      - name: sidecar
+        image: docker.io/org/sidecar:1.0.0 # {"$imagepolicy": "flux-system:unknown"}
+---
+apiVersion: helm.toolkit.fluxcd.io/v2beta2
+kind: HelmRelease
+metadata:
+  name: app
+spec:
+  values:
+    image:
+      repository: ghcr.io/org/app # {"$imagepolicy": "flux-system:app:name"}
+      tag: "1.0.0" # {"$imagepolicy": "flux-system:app:tag"}
+      digest: sha256:0000 # {"$imagepolicy": "flux-system:app:digest"}
+`
+
+const imagePolicyOutput = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+spec:
+  template:
+    spec:
+      containers:
+      - name: app
+        image: localhost:5000/org/app:1.2.3@sha256:1234 # {"$imagepolicy": "flux-system:app"}
+      - name: sidecar
+        image: docker.io/org/sidecar:1.0.0 # {"$imagepolicy": "flux-system:unknown"}
+---
+apiVersion: helm.toolkit.fluxcd.io/v2beta2
+kind: HelmRelease
+metadata:
+  name: app
+spec:
+  values:
+    image:
+      repository: localhost:5000/org/app # {"$imagepolicy": "flux-system:app:name"}
+      tag: "1.2.3" # {"$imagepolicy": "flux-system:app:tag"}
+      digest: sha256:1234 # {"$imagepolicy": "flux-system:app:digest"}
+`
+
+func TestImagePolicyTransformer_TransformYAML(t *testing.T) {
+	g := NewWithT(t)
+
+	tr := ImagePolicyTransformer{
+		Images: map[string]string{
+			"flux-system:app": "localhost:5000/org/app:1.2.3@sha256:1234",
+		},
+	}
+	out, err := tr.TransformYAML([]byte(imagePolicyInput))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(string(out)).To(Equal(imagePolicyOutput))
+
+	tr.Strict = true
+	_, err = tr.TransformYAML([]byte(imagePolicyInput))
+	g.Expect(errors.Is(err, ErrMissingImagePolicy)).To(BeTrue())
+}
+
+func TestImagePolicyTransformer_Transform(t *testing.T) {
+	g := NewWithT(t)
+
+	factory := resmap.NewFactory(provider.NewDefaultDepProvider().GetResourceFactory())
+	m, err := factory.NewResMapFromBytes([]byte(imagePolicyInput))
+	g.Expect(err).ToNot(HaveOccurred())
+
+	tr := ImagePolicyTransformer{
+		Images: map[string]string{
+			"flux-system:app": "ghcr.io/org/app:2.0.0@sha256:abcd",
+		},
+	}
+	g.Expect(tr.Transform(m)).To(Succeed())
+
+	containers, err := m.Resources()[0].GetFieldValue("spec.template.spec.containers")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(containers.([]interface{})[0].(map[string]interface{})["image"]).To(Equal("ghcr.io/org/app:2.0.0@sha256:abcd"))
+
+	tag, err := m.Resources()[1].GetString("spec.values.image.tag")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(tag).To(Equal("2.0.0"))
+
+	tr.Images["flux-system:app"] = "ghcr.io/org/app:2.0.0"
+	err = tr.Transform(m)
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.Error()).To(ContainSubstring("has no digest"))
+}
+
+func Test_parseImagePolicyMarker(t *testing.T) {
+	tests := []struct {
+		comment    string
+		wantPolicy string
+		wantField  string
+		wantOK     bool
+		wantErr    bool
+	}{
+		{comment: `# {"$imagepolicy": "ns:app"}`, wantPolicy: "ns:app", wantOK: true},
+		{comment: `# {"$imagepolicy": "ns:app:tag"}`, wantPolicy: "ns:app", wantField: "tag", wantOK: true},
+		{comment: `# just a comment`},
+		{comment: `# {"$imagepolicy": "app"}`, wantErr: true},
+		{comment: `# {"$imagepolicy": "ns:app:version"}`, wantErr: true},
+		{comment: `# {"$imagepolicy": }`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.comment, func(t *testing.T) {
+			g := NewWithT(t)
+
+			policy, field, ok, err := parseImagePolicyMarker(tt.comment)
+			if tt.wantErr {
+				g.Expect(err).To(HaveOccurred())
+				return
+			}
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(ok).To(Equal(tt.wantOK))
+			g.Expect(policy).To(Equal(tt.wantPolicy))
+			g.Expect(field).To(Equal(tt.wantField))
+		})
+	}
+}
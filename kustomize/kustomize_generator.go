@@ -70,6 +70,19 @@ type Generator struct {
 	ignore        string
 	filter        bool
 	kustomization unstructured.Unstructured
+
+	decryptor          Decryptor
+	decryptionPatterns []string
+
+	buildCache    *BuildCache
+	buildCacheKey BuildCacheKey
+
+	remoteBases *RemoteBaseOptions
+
+	schemaValidator *SchemaValidator
+	// originAdded is true if the origin annotations were enabled by
+	// WriteFile for the schema validation.
+	originAdded bool
 }
 
 // SavingOptions is a function that can be used to apply saving options to a kustomization
@@ -239,6 +252,13 @@ func (g *Generator) WriteFile(dirPath string, opts ...SavingOptions) (Action, er
 		}
 	}
 
+	// track the origin of the resources for the schema validation errors
+	g.originAdded = false
+	if g.schemaValidator != nil && !containsString(kus.BuildMetadata, kustypes.OriginAnnotations) {
+		kus.BuildMetadata = append(kus.BuildMetadata, kustypes.OriginAnnotations)
+		g.originAdded = true
+	}
+
 	manifest, err := yaml.Marshal(kus)
 	if err != nil {
 		errf := CleanDirectory(dirPath, action)
@@ -558,8 +578,22 @@ var kustomizeBuildMutex sync.Mutex
 //     (but not outside root)
 //   - disable plugins except for the builtin ones
 func SecureBuild(root, dirPath string, allowRemoteBases bool) (res resmap.ResMap, err error) {
-	var fs filesys.FileSystem
+	return SecureBuildWithLimits(root, dirPath, allowRemoteBases, securefs.Limits{})
+}
 
+// SecureBuildWithLimits calls SecureBuild, but additionally enforces the
+// given size limits on the files read during the build.
+func SecureBuildWithLimits(root, dirPath string, allowRemoteBases bool, limits securefs.Limits) (res resmap.ResMap, err error) {
+	fs, err := secureFS(root, allowRemoteBases, limits)
+	if err != nil {
+		return nil, err
+	}
+	return Build(fs, dirPath)
+}
+
+// secureFS returns the secure on-disk FS for root used by
+// SecureBuildWithLimits.
+func secureFS(root string, allowRemoteBases bool, limits securefs.Limits) (fs filesys.FileSystem, err error) {
 	// Create secure FS for root with or without remote base support
 	if allowRemoteBases {
 		fs, err = securefs.MakeFsOnDiskSecureBuild(root)
@@ -572,7 +606,10 @@ func SecureBuild(root, dirPath string, allowRemoteBases bool) (res resmap.ResMap
 			return nil, err
 		}
 	}
-	return Build(fs, dirPath)
+	if limits != (securefs.Limits{}) {
+		fs = securefs.WithLimits(fs, limits)
+	}
+	return fs, nil
 }
 
 // Build wraps krusty.MakeKustomizer with the following settings:
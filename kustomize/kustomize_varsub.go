@@ -19,10 +19,8 @@ package kustomize
 import (
 	"context"
 	"fmt"
-	"regexp"
 	"strings"
 
-	"github.com/drone/envsubst"
 	"github.com/hashicorp/go-multierror"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
@@ -58,6 +56,19 @@ func SubstituteVariables(
 	kustomization unstructured.Unstructured,
 	res *resource.Resource,
 	dryRun bool) (*resource.Resource, error) {
+	return SubstituteVariablesWithOptions(ctx, kubeClient, kustomization, res, dryRun, SubstituteOptions{})
+}
+
+// SubstituteVariablesWithOptions is like SubstituteVariables, but performs the
+// substitution with the given options, e.g. to fail on variables which are
+// referenced in the resource but are not set.
+func SubstituteVariablesWithOptions(
+	ctx context.Context,
+	kubeClient client.Client,
+	kustomization unstructured.Unstructured,
+	res *resource.Resource,
+	dryRun bool,
+	opts SubstituteOptions) (*resource.Resource, error) {
 	resData, err := res.AsYAML()
 	if err != nil {
 		return nil, err
@@ -67,11 +78,37 @@ func SubstituteVariables(
 		return nil, nil
 	}
 
+	vars, err := LoadVariables(ctx, kubeClient, kustomization, dryRun)
+	if err != nil {
+		return nil, err
+	}
+
+	// run bash variable substitutions
+	if len(vars) > 0 {
+		jsonData, err := varSubstitution(resData, vars, opts)
+		if err != nil {
+			return nil, fmt.Errorf("YAMLToJSON: %w", err)
+		}
+		err = res.UnmarshalJSON(jsonData)
+		if err != nil {
+			return nil, fmt.Errorf("UnmarshalJSON: %w", err)
+		}
+	}
+
+	return res, nil
+}
+
+// LoadVariables returns the post build substitution variables of the
+// kustomization: the data of the ConfigMaps and Secrets referenced in
+// substituteFrom, overridden by the in-line substitute variables. In dryRun
+// mode, only the in-line variables are returned.
+func LoadVariables(ctx context.Context, kubeClient client.Client, kustomization unstructured.Unstructured, dryRun bool) (map[string]string, error) {
 	// load vars from ConfigMaps and Secrets data keys
 	// In dryRun mode this step is skipped. This might in different kind of errors.
 	// But if the user is using dryRun, he/she should know what he/she is doing, and we should comply.
 	var vars map[string]string
 	if !dryRun {
+		var err error
 		vars, err = loadVars(ctx, kubeClient, kustomization)
 		if err != nil {
 			return nil, err
@@ -91,20 +128,7 @@ func SubstituteVariables(
 			vars[k] = strings.ReplaceAll(v, "\n", "")
 		}
 	}
-
-	// run bash variable substitutions
-	if len(vars) > 0 {
-		jsonData, err := varSubstitution(resData, vars)
-		if err != nil {
-			return nil, fmt.Errorf("YAMLToJSON: %w", err)
-		}
-		err = res.UnmarshalJSON(jsonData)
-		if err != nil {
-			return nil, fmt.Errorf("UnmarshalJSON: %w", err)
-		}
-	}
-
-	return res, nil
+	return vars, nil
 }
 
 func loadVars(ctx context.Context, kubeClient client.Client, kustomization unstructured.Unstructured) (map[string]string, error) {
@@ -145,19 +169,10 @@ func loadVars(ctx context.Context, kubeClient client.Client, kustomization unstr
 	return vars, nil
 }
 
-func varSubstitution(data []byte, vars map[string]string) ([]byte, error) {
-	r, _ := regexp.Compile(varsubRegex)
-	for v := range vars {
-		if !r.MatchString(v) {
-			return nil, fmt.Errorf("'%s' var name is invalid, must match '%s'", v, varsubRegex)
-		}
-	}
-
-	output, err := envsubst.Eval(string(data), func(s string) string {
-		return vars[s]
-	})
+func varSubstitution(data []byte, vars map[string]string, opts SubstituteOptions) ([]byte, error) {
+	output, _, err := Substitute(string(data), vars, opts)
 	if err != nil {
-		return nil, fmt.Errorf("variable substitution failed: %w", err)
+		return nil, err
 	}
 
 	jsonData, err := yaml.YAMLToJSON([]byte(output))
@@ -24,6 +24,8 @@ import (
 
 	"github.com/fluxcd/pkg/kustomize"
 	. "github.com/onsi/gomega"
+	"sigs.k8s.io/kustomize/api/provider"
+	"sigs.k8s.io/kustomize/api/resmap"
 	"sigs.k8s.io/kustomize/kyaml/filesys"
 )
 
@@ -70,3 +72,65 @@ func TestKustomizationVarsub(t *testing.T) {
 
 	g.Expect(string(resources)).To(Equal(string(expected)))
 }
+
+func TestKustomizationVarsub_Strict(t *testing.T) {
+	g := NewWithT(t)
+
+	yamlKus, err := os.ReadFile("./testdata/kustomization_varsub.yaml")
+	g.Expect(err).NotTo(HaveOccurred())
+
+	clientObjects, err := readYamlObjects(strings.NewReader(string(yamlKus)))
+	g.Expect(err).NotTo(HaveOccurred())
+
+	tests := []struct {
+		name    string
+		value   string
+		strict  bool
+		wantErr bool
+	}{
+		{
+			name:   "set variable in strict mode",
+			value:  "${cluster_env}",
+			strict: true,
+		},
+		{
+			name:   "defaulted variable in strict mode",
+			value:  "${cluster_zone:=a}",
+			strict: true,
+		},
+		{
+			name:    "missing variable in strict mode",
+			value:   "${cluster_zone}",
+			strict:  true,
+			wantErr: true,
+		},
+		{
+			name:  "missing variable",
+			value: "${cluster_zone}",
+		},
+	}
+
+	factory := resmap.NewFactory(provider.NewDefaultDepProvider().GetResourceFactory())
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			resMap, err := factory.NewResMapFromBytes([]byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app
+data:
+  value: "` + tt.value + `"
+`))
+			g.Expect(err).NotTo(HaveOccurred())
+
+			_, err = kustomize.SubstituteVariablesWithOptions(context.Background(), kubeClient, clientObjects[0],
+				resMap.Resources()[0], true, kustomize.SubstituteOptions{Strict: tt.strict})
+			if tt.wantErr {
+				g.Expect(err).To(MatchError(kustomize.ErrMissingVariable))
+				return
+			}
+			g.Expect(err).NotTo(HaveOccurred())
+		})
+	}
+}
@@ -0,0 +1,277 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kustomize
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"sigs.k8s.io/kustomize/api/konfig"
+	kustypes "sigs.k8s.io/kustomize/api/types"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+	"sigs.k8s.io/yaml"
+)
+
+// ErrOffline is returned when a remote base must be fetched while in
+// offline mode.
+var ErrOffline = errors.New("remote bases can not be fetched in offline mode")
+
+// RemoteFetcher downloads the archive at the given URL, verifies its
+// digest if not empty, and extracts its content into the given directory.
+// It is implemented by the ArchiveFetcher of github.com/fluxcd/pkg/http/fetch.
+type RemoteFetcher interface {
+	Fetch(archiveURL, digest, dir string) error
+}
+
+// RemoteBaseOptions configures how the remote bases of kustomizations are
+// resolved by ResolveRemoteBases.
+type RemoteBaseOptions struct {
+	// Fetcher is used to fetch the remote bases.
+	Fetcher RemoteFetcher
+	// Digests pins the remote bases to the digest of their archive,
+	// keyed by URL, for example 'sha256:<hex>'.
+	Digests map[string]string
+	// RequireDigests fails the resolution of remote bases which are not
+	// pinned to a digest.
+	RequireDigests bool
+	// Offline disables fetching remote bases. Remote bases which have not
+	// been fetched before into the cache directory result in ErrOffline.
+	Offline bool
+	// CacheDir is the directory in which the remote bases are fetched. It
+	// must be within the root. Defaults to '.remote-bases' in the
+	// kustomization directory.
+	CacheDir string
+	// Root is the directory outside of which the local bases can not be
+	// read. Defaults to the kustomization directory.
+	Root string
+}
+
+// digestFile is the name of the file recording the digest of a fetched
+// remote base in its cache directory.
+const digestFile = ".digest"
+
+// ResolveRemoteBases fetches the remote archive bases and components of
+// the kustomization in dirPath, and of the local bases it references, with
+// the configured fetcher. It returns a FileSystem which serves, on top of the
+// given one, copies of the kustomization files referencing the fetched bases
+// instead, so that the build does not need network access. The kustomization
+// files are not modified, only the cache directory is written to. Only
+// HTTP(S) URLs of tarballs ('.tar.gz' or '.tgz') with a kustomization at their
+// root are fetched; other remote references are left to kustomize, or result
+// in ErrOffline in offline mode.
+func ResolveRemoteBases(fs filesys.FileSystem, dirPath string, opts RemoteBaseOptions) (filesys.FileSystem, error) {
+	abs, err := filepath.Abs(dirPath)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Root == "" {
+		opts.Root = abs
+	}
+	if opts.Root, err = filepath.Abs(opts.Root); err != nil {
+		return nil, err
+	}
+	if opts.CacheDir == "" {
+		opts.CacheDir = filepath.Join(abs, ".remote-bases")
+	}
+	absCache, err := filepath.Abs(opts.CacheDir)
+	if err != nil {
+		return nil, err
+	}
+	if opts.CacheDir, err = securePath(opts.Root, absCache); err != nil {
+		return nil, fmt.Errorf("invalid cache directory: %w", err)
+	}
+
+	r := &remoteBaseResolver{
+		fs:      fs,
+		opts:    opts,
+		visited: make(map[string]bool),
+		files:   make(map[string][]byte),
+	}
+	if err := r.resolveDir(abs); err != nil {
+		return nil, err
+	}
+	return newFSOverlay(fs, r.files), nil
+}
+
+// WithRemoteBases configures the generator to resolve the remote archive
+// bases of the kustomization with ResolveRemoteBases, when building it with
+// CachedBuild. The root of the generator is used if the root of the options
+// is not set.
+func (g *Generator) WithRemoteBases(opts RemoteBaseOptions) *Generator {
+	g.remoteBases = &opts
+	return g
+}
+
+// remoteBasesFS returns the given FileSystem with the remote bases of the
+// kustomization in dirPath resolved, if configured.
+func (g *Generator) remoteBasesFS(fs filesys.FileSystem, dirPath string) (filesys.FileSystem, error) {
+	if g.remoteBases == nil {
+		return fs, nil
+	}
+	opts := *g.remoteBases
+	if opts.Root == "" {
+		opts.Root = g.root
+	}
+	return ResolveRemoteBases(fs, dirPath, opts)
+}
+
+type remoteBaseResolver struct {
+	fs      filesys.FileSystem
+	opts    RemoteBaseOptions
+	visited map[string]bool
+	// files are the rewritten kustomization files, keyed by absolute path.
+	files map[string][]byte
+}
+
+// resolveDir resolves the remote bases of the kustomization in the given
+// absolute directory, if any.
+func (r *remoteBaseResolver) resolveDir(dir string) error {
+	abs, err := securePath(r.opts.Root, dir)
+	if err != nil {
+		return err
+	}
+	if r.visited[abs] {
+		return nil
+	}
+	r.visited[abs] = true
+
+	var kfile string
+	for _, name := range konfig.RecognizedKustomizationFileNames() {
+		if p := filepath.Join(abs, name); r.fs.Exists(p) && !r.fs.IsDir(p) {
+			kfile = p
+			break
+		}
+	}
+	if kfile == "" {
+		return nil
+	}
+
+	data, err := r.fs.ReadFile(kfile)
+	if err != nil {
+		return err
+	}
+	var kus kustypes.Kustomization
+	if err := yaml.Unmarshal(data, &kus); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", kfile, err)
+	}
+
+	changed := false
+	for _, refs := range []*[]string{&kus.Resources, &kus.Components} {
+		for i, ref := range *refs {
+			if IsLocalRelativePath(ref) {
+				if err := r.resolveDir(filepath.Join(abs, ref)); err != nil {
+					return err
+				}
+				continue
+			}
+			if filepath.IsAbs(ref) || strings.HasPrefix(strings.ToLower(ref), "file://") {
+				continue
+			}
+
+			local, err := r.resolveRemote(ref)
+			if err != nil {
+				return err
+			}
+			if local == "" {
+				continue
+			}
+			rel, err := filepath.Rel(abs, local)
+			if err != nil {
+				return err
+			}
+			(*refs)[i] = filepath.ToSlash(rel)
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+
+	out, err := yaml.Marshal(kus)
+	if err != nil {
+		return err
+	}
+	r.files[kfile] = out
+	return nil
+}
+
+// resolveRemote returns the directory into which the remote base at the
+// given URL has been fetched, or an empty string if the URL is not fetched
+// by the resolver.
+func (r *remoteBaseResolver) resolveRemote(ref string) (string, error) {
+	digest := r.opts.Digests[ref]
+	if !isRemoteArchive(ref) {
+		switch {
+		case r.opts.Offline:
+			return "", fmt.Errorf("%w: '%s'", ErrOffline, ref)
+		case digest != "" || r.opts.RequireDigests:
+			return "", fmt.Errorf("remote base '%s' can not be pinned to a digest: only tarballs served over HTTP(S) are supported", ref)
+		}
+		return "", nil
+	}
+	if digest == "" && r.opts.RequireDigests {
+		return "", fmt.Errorf("remote base '%s' is not pinned to a digest", ref)
+	}
+
+	sum := sha256.Sum256([]byte(ref))
+	dir := filepath.Join(r.opts.CacheDir, fmt.Sprintf("%x", sum[:8]))
+
+	// Reuse the fetched base if it matches the pinned digest. Unpinned bases
+	// are only reused in offline mode, as their content may have changed.
+	if cached, err := os.ReadFile(filepath.Join(dir, digestFile)); err == nil {
+		if (digest != "" && string(cached) == digest) || (digest == "" && r.opts.Offline) {
+			return dir, r.resolveDir(dir)
+		}
+	}
+	if r.opts.Offline {
+		return "", fmt.Errorf("%w: '%s' has not been fetched before", ErrOffline, ref)
+	}
+	if r.opts.Fetcher == nil {
+		return "", fmt.Errorf("no fetcher configured for remote base '%s'", ref)
+	}
+
+	if err := os.RemoveAll(dir); err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	if err := r.opts.Fetcher.Fetch(ref, digest, dir); err != nil {
+		return "", fmt.Errorf("failed to fetch remote base '%s': %w", ref, err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, digestFile), []byte(digest), 0o644); err != nil {
+		return "", err
+	}
+
+	// The fetched base may itself reference remote bases.
+	return dir, r.resolveDir(dir)
+}
+
+// isRemoteArchive returns true if the reference is the HTTP(S) URL of a
+// tarball.
+func isRemoteArchive(ref string) bool {
+	u, err := url.Parse(ref)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return false
+	}
+	return strings.HasSuffix(u.Path, ".tar.gz") || strings.HasSuffix(u.Path, ".tgz")
+}
@@ -0,0 +1,209 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kustomize_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+
+	"github.com/fluxcd/pkg/kustomize"
+	securefs "github.com/fluxcd/pkg/kustomize/filesys"
+)
+
+const remoteBaseURL = "https://example.com/bases/app.tar.gz"
+
+type fakeFetcher struct {
+	digests []string
+}
+
+func (f *fakeFetcher) Fetch(_, digest, dir string) error {
+	f.digests = append(f.digests, digest)
+	if digest == "sha256:invalid" {
+		return errors.New("digest mismatch")
+	}
+	if err := os.WriteFile(filepath.Join(dir, "kustomization.yaml"), []byte(`apiVersion: kustomize.config.k8s.io/v1beta1
+kind: Kustomization
+resources:
+- configmap.yaml
+`), 0o644); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, "configmap.yaml"), []byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: remote
+`), 0o644)
+}
+
+func writeRemoteKustomization(t *testing.T, dir, ref string) {
+	t.Helper()
+	kus := `apiVersion: kustomize.config.k8s.io/v1beta1
+kind: Kustomization
+namespace: apps
+resources:
+- ` + ref + "\n"
+	if err := os.WriteFile(filepath.Join(dir, "kustomization.yaml"), []byte(kus), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestResolveRemoteBases(t *testing.T) {
+	g := NewWithT(t)
+
+	tmpDir := t.TempDir()
+	writeRemoteKustomization(t, tmpDir, remoteBaseURL)
+	fetcher := &fakeFetcher{}
+	opts := kustomize.RemoteBaseOptions{
+		Fetcher: fetcher,
+		Digests: map[string]string{remoteBaseURL: "sha256:pinned"},
+	}
+
+	fs, err := kustomize.ResolveRemoteBases(filesys.MakeFsOnDisk(), tmpDir, opts)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(fetcher.digests).To(Equal([]string{"sha256:pinned"}))
+
+	// The kustomization is not modified.
+	data, err := os.ReadFile(filepath.Join(tmpDir, "kustomization.yaml"))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(string(data)).To(ContainSubstring(remoteBaseURL))
+
+	resMap, err := kustomize.Build(fs, tmpDir)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(resMap.Resources()).To(HaveLen(1))
+	g.Expect(resMap.Resources()[0].GetName()).To(Equal("remote"))
+	g.Expect(resMap.Resources()[0].GetNamespace()).To(Equal("apps"))
+
+	// The fetched base is reused when it matches the pinned digest.
+	opts.Offline = true
+	_, err = kustomize.ResolveRemoteBases(filesys.MakeFsOnDisk(), tmpDir, opts)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(fetcher.digests).To(HaveLen(1))
+
+	// A different digest requires fetching the base again.
+	opts.Digests[remoteBaseURL] = "sha256:other"
+	_, err = kustomize.ResolveRemoteBases(filesys.MakeFsOnDisk(), tmpDir, opts)
+	g.Expect(errors.Is(err, kustomize.ErrOffline)).To(BeTrue())
+
+	opts.Offline = false
+	opts.Digests[remoteBaseURL] = "sha256:invalid"
+	_, err = kustomize.ResolveRemoteBases(filesys.MakeFsOnDisk(), tmpDir, opts)
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.Error()).To(ContainSubstring("digest mismatch"))
+}
+
+func TestResolveRemoteBases_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		ref     string
+		opts    kustomize.RemoteBaseOptions
+		wantErr string
+	}{
+		{
+			name:    "unpinned base with required digests",
+			ref:     remoteBaseURL,
+			opts:    kustomize.RemoteBaseOptions{Fetcher: &fakeFetcher{}, RequireDigests: true},
+			wantErr: "is not pinned to a digest",
+		},
+		{
+			name:    "remote file in offline mode",
+			ref:     "https://raw.githubusercontent.com/fluxcd/flux2/main/manifests/rbac/controller.yaml",
+			opts:    kustomize.RemoteBaseOptions{Offline: true},
+			wantErr: kustomize.ErrOffline.Error(),
+		},
+		{
+			name:    "git base in offline mode",
+			ref:     "github.com/fluxcd/flux2//manifests/rbac?ref=main",
+			opts:    kustomize.RemoteBaseOptions{Offline: true},
+			wantErr: kustomize.ErrOffline.Error(),
+		},
+		{
+			name: "pinned remote file",
+			ref:  "https://raw.githubusercontent.com/fluxcd/flux2/main/manifests/rbac/controller.yaml",
+			opts: kustomize.RemoteBaseOptions{Digests: map[string]string{
+				"https://raw.githubusercontent.com/fluxcd/flux2/main/manifests/rbac/controller.yaml": "sha256:pinned",
+			}},
+			wantErr: "can not be pinned to a digest",
+		},
+		{
+			name:    "no fetcher",
+			ref:     remoteBaseURL,
+			wantErr: "no fetcher configured",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			tmpDir := t.TempDir()
+			writeRemoteKustomization(t, tmpDir, tt.ref)
+			_, err := kustomize.ResolveRemoteBases(filesys.MakeFsOnDisk(), tmpDir, tt.opts)
+			g.Expect(err).To(HaveOccurred())
+			g.Expect(err.Error()).To(ContainSubstring(tt.wantErr))
+		})
+	}
+
+	// Remote files are left to kustomize when not in offline mode.
+	g := NewWithT(t)
+	tmpDir := t.TempDir()
+	writeRemoteKustomization(t, tmpDir, "https://raw.githubusercontent.com/fluxcd/flux2/main/manifests/rbac/controller.yaml")
+	_, err := kustomize.ResolveRemoteBases(filesys.MakeFsOnDisk(), tmpDir, kustomize.RemoteBaseOptions{})
+	g.Expect(err).NotTo(HaveOccurred())
+}
+
+func TestResolveRemoteBases_OutsideRoot(t *testing.T) {
+	g := NewWithT(t)
+
+	root := t.TempDir()
+	dir := filepath.Join(root, "app")
+	g.Expect(os.MkdirAll(dir, 0o755)).To(Succeed())
+	writeRemoteKustomization(t, dir, "../../outside")
+
+	_, err := kustomize.ResolveRemoteBases(filesys.MakeFsOnDisk(), dir, kustomize.RemoteBaseOptions{Root: root})
+	g.Expect(err).To(MatchError(ContainSubstring("outside of root")))
+
+	_, err = kustomize.ResolveRemoteBases(filesys.MakeFsOnDisk(), dir, kustomize.RemoteBaseOptions{
+		Root:     dir,
+		CacheDir: filepath.Join(root, ".remote-bases"),
+	})
+	g.Expect(err).To(MatchError(ContainSubstring("invalid cache directory")))
+}
+
+func TestGenerator_CachedBuildWithRemoteBases(t *testing.T) {
+	g := NewWithT(t)
+
+	tmpDir := t.TempDir()
+	writeRemoteKustomization(t, tmpDir, remoteBaseURL)
+	fetcher := &fakeFetcher{}
+
+	output, _, err := kustomize.NewGenerator(tmpDir, unstructured.Unstructured{}).
+		WithRemoteBases(kustomize.RemoteBaseOptions{Fetcher: fetcher}).
+		CachedBuild(tmpDir, false, securefs.Limits{})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(fetcher.digests).To(HaveLen(1))
+	g.Expect(string(output)).To(ContainSubstring("name: remote"))
+
+	data, err := os.ReadFile(filepath.Join(tmpDir, "kustomization.yaml"))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(string(data)).To(ContainSubstring(remoteBaseURL))
+}
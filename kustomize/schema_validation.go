@@ -0,0 +1,332 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kustomize
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/kube-openapi/pkg/validation/spec"
+	"k8s.io/kube-openapi/pkg/validation/strfmt"
+	"k8s.io/kube-openapi/pkg/validation/validate"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/kustomize/api/resmap"
+	"sigs.k8s.io/kustomize/api/resource"
+	"sigs.k8s.io/yaml"
+)
+
+// originAnnotationKey is the annotation set by kustomize on the resources
+// built with the 'originAnnotations' build metadata.
+const originAnnotationKey = "config.kubernetes.io/origin"
+
+// SchemaValidator validates the resources rendered by a Generator against
+// the OpenAPI schemas of their kinds, so that invalid resources are reported
+// with the file they originate from before they are applied on the cluster.
+//
+// The schemas are loaded from an offline bundle of standalone JSON schemas
+// for the Kubernetes kinds, and from CustomResourceDefinitions found in the
+// bundle, in the cluster, or in the rendered resources themselves.
+// The resources of kinds without a schema are not validated.
+type SchemaValidator struct {
+	mu      sync.RWMutex
+	schemas map[schema.GroupVersionKind]*spec.Schema
+}
+
+// NewSchemaValidator returns a SchemaValidator without schemas.
+func NewSchemaValidator() *SchemaValidator {
+	return &SchemaValidator{
+		schemas: make(map[schema.GroupVersionKind]*spec.Schema),
+	}
+}
+
+// LoadBundle loads the schemas of the files in the given directory.
+// The JSON files are expected to be standalone JSON schemas, i.e. without
+// references, annotated with the 'x-kubernetes-group-version-kind' extension
+// of the Kubernetes OpenAPI documents. The YAML files are expected to contain
+// CustomResourceDefinitions. Other files are ignored.
+func (v *SchemaValidator) LoadBundle(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("failed to read schema bundle: %w", err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		switch filepath.Ext(entry.Name()) {
+		case ".json":
+			err = v.loadJSONSchema(path)
+		case ".yaml", ".yml":
+			err = v.loadCRDs(path)
+		default:
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to load schema bundle file '%s': %w", entry.Name(), err)
+		}
+	}
+	return nil
+}
+
+func (v *SchemaValidator) loadJSONSchema(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var ext struct {
+		GVKs []struct {
+			Group   string `json:"group"`
+			Version string `json:"version"`
+			Kind    string `json:"kind"`
+		} `json:"x-kubernetes-group-version-kind"`
+	}
+	if err := json.Unmarshal(data, &ext); err != nil {
+		return err
+	}
+	s := &spec.Schema{}
+	if err := json.Unmarshal(data, s); err != nil {
+		return err
+	}
+	if err := checkStandalone(s); err != nil {
+		return err
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	for _, gvk := range ext.GVKs {
+		v.schemas[schema.GroupVersionKind{Group: gvk.Group, Version: gvk.Version, Kind: gvk.Kind}] = s
+	}
+	return nil
+}
+
+func (v *SchemaValidator) loadCRDs(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	for _, doc := range strings.Split(string(data), "\n---") {
+		if strings.TrimSpace(doc) == "" {
+			continue
+		}
+		var crd apiextensionsv1.CustomResourceDefinition
+		if err := yaml.Unmarshal([]byte(doc), &crd); err != nil {
+			return err
+		}
+		if crd.Kind != "CustomResourceDefinition" {
+			continue
+		}
+		if err := v.AddCRD(&crd); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AddCRD adds the schemas of the served versions of the given
+// CustomResourceDefinition.
+func (v *SchemaValidator) AddCRD(crd *apiextensionsv1.CustomResourceDefinition) error {
+	schemas, err := crdSchemas(crd)
+	if err != nil {
+		return err
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	for gvk, s := range schemas {
+		v.schemas[gvk] = s
+	}
+	return nil
+}
+
+// AddClusterCRDs adds the schemas of the CustomResourceDefinitions
+// installed on the cluster.
+func (v *SchemaValidator) AddClusterCRDs(ctx context.Context, c client.Reader) error {
+	var list apiextensionsv1.CustomResourceDefinitionList
+	if err := c.List(ctx, &list); err != nil {
+		return fmt.Errorf("failed to list CustomResourceDefinitions: %w", err)
+	}
+	for i := range list.Items {
+		if err := v.AddCRD(&list.Items[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WithSchemaValidator configures CachedBuild to validate the rendered
+// resources with the given SchemaValidator before returning them. The
+// kustomization is built with origin annotations, so that the errors report
+// the file each invalid resource originates from.
+func (g *Generator) WithSchemaValidator(v *SchemaValidator) *Generator {
+	g.schemaValidator = v
+	return g
+}
+
+// Validate validates the given resources against the schemas of their kinds.
+// The CustomResourceDefinitions among the resources are used to validate the
+// custom resources of their kinds. It returns ValidationErrors with the file
+// each invalid resource originates from, if the resources were built with
+// origin annotations.
+func (v *SchemaValidator) Validate(resMap resmap.ResMap) error {
+	v.mu.RLock()
+	schemas := make(map[schema.GroupVersionKind]*spec.Schema, len(v.schemas))
+	for gvk, s := range v.schemas {
+		schemas[gvk] = s
+	}
+	v.mu.RUnlock()
+
+	resources := resMap.Resources()
+	for _, r := range resources {
+		gvk := r.GetGvk()
+		if gvk.Group != apiextensionsv1.GroupName || gvk.Kind != "CustomResourceDefinition" {
+			continue
+		}
+		obj, err := r.Map()
+		if err != nil {
+			return err
+		}
+		var crd apiextensionsv1.CustomResourceDefinition
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj, &crd); err != nil {
+			return fmt.Errorf("%s: %w", resourceName(r), err)
+		}
+		crdSchemas, err := crdSchemas(&crd)
+		if err != nil {
+			return fmt.Errorf("%s: %w", resourceName(r), err)
+		}
+		for gvk, s := range crdSchemas {
+			schemas[gvk] = s
+		}
+	}
+
+	var errs ValidationErrors
+	for _, r := range resources {
+		gvk := r.GetGvk()
+		s, ok := schemas[schema.GroupVersionKind{Group: gvk.Group, Version: gvk.Version, Kind: gvk.Kind}]
+		if !ok {
+			continue
+		}
+		obj, err := r.Map()
+		if err != nil {
+			return err
+		}
+		// The origin annotations are not part of the schemas.
+		if annotations, ok := obj["metadata"].(map[string]interface{})["annotations"].(map[string]interface{}); ok {
+			delete(annotations, originAnnotationKey)
+		}
+
+		result := validate.NewSchemaValidator(s, nil, "", strfmt.Default).Validate(obj)
+		if result == nil || result.IsValid() {
+			continue
+		}
+		msgs := make([]string, 0, len(result.Errors))
+		for _, e := range result.Errors {
+			msgs = append(msgs, e.Error())
+		}
+		sort.Strings(msgs)
+		errs = append(errs, &ValidationError{
+			File:    resourceFile(r),
+			Message: fmt.Sprintf("%s is invalid: %s", resourceName(r), strings.Join(msgs, "; ")),
+		})
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// crdSchemas returns the schemas of the served versions of the given
+// CustomResourceDefinition.
+func crdSchemas(crd *apiextensionsv1.CustomResourceDefinition) (map[schema.GroupVersionKind]*spec.Schema, error) {
+	schemas := make(map[schema.GroupVersionKind]*spec.Schema)
+	for _, version := range crd.Spec.Versions {
+		if !version.Served || version.Schema == nil || version.Schema.OpenAPIV3Schema == nil {
+			continue
+		}
+		data, err := json.Marshal(version.Schema.OpenAPIV3Schema)
+		if err != nil {
+			return nil, err
+		}
+		s := &spec.Schema{}
+		if err := json.Unmarshal(data, s); err != nil {
+			return nil, fmt.Errorf("invalid schema for '%s' version '%s': %w", crd.Name, version.Name, err)
+		}
+		schemas[schema.GroupVersionKind{
+			Group:   crd.Spec.Group,
+			Version: version.Name,
+			Kind:    crd.Spec.Names.Kind,
+		}] = s
+	}
+	return schemas, nil
+}
+
+// checkStandalone returns an error if the schema contains references, which
+// are not supported by the validator.
+func checkStandalone(s *spec.Schema) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	if strings.Contains(string(data), `"$ref"`) {
+		return fmt.Errorf("schema references are not supported, use standalone schemas")
+	}
+	return nil
+}
+
+// resourceFile returns the file the resource originates from, if known.
+func resourceFile(r *resource.Resource) string {
+	origin, err := r.GetOrigin()
+	if err != nil || origin == nil {
+		return resourceName(r)
+	}
+	file := origin.Path
+	if file == "" {
+		file = origin.ConfiguredIn
+	}
+	if origin.Repo != "" {
+		file = fmt.Sprintf("%s//%s", origin.Repo, file)
+		if origin.Ref != "" {
+			file += "?ref=" + origin.Ref
+		}
+	}
+	return file
+}
+
+// resourceName returns the kind, namespace and name of the resource.
+func resourceName(r *resource.Resource) string {
+	if ns := r.GetNamespace(); ns != "" {
+		return fmt.Sprintf("%s/%s/%s", r.GetKind(), ns, r.GetName())
+	}
+	return fmt.Sprintf("%s/%s", r.GetKind(), r.GetName())
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,145 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kustomize
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	securefs "github.com/fluxcd/pkg/kustomize/filesys"
+)
+
+const testDeploymentSchema = `{
+  "type": "object",
+  "properties": {
+    "spec": {
+      "type": "object",
+      "properties": {
+        "replicas": {"type": "integer"}
+      }
+    }
+  },
+  "x-kubernetes-group-version-kind": [{"group": "apps", "kind": "Deployment", "version": "v1"}]
+}`
+
+const testCRD = `apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: widgets.example.com
+spec:
+  group: example.com
+  names:
+    kind: Widget
+    plural: widgets
+  scope: Namespaced
+  versions:
+  - name: v1
+    served: true
+    storage: true
+    schema:
+      openAPIV3Schema:
+        type: object
+        properties:
+          spec:
+            type: object
+            properties:
+              size:
+                type: integer
+`
+
+func TestGenerator_WithSchemaValidator(t *testing.T) {
+	g := NewWithT(t)
+
+	bundle := t.TempDir()
+	g.Expect(os.WriteFile(filepath.Join(bundle, "deployment-apps-v1.json"), []byte(testDeploymentSchema), 0o600)).To(Succeed())
+	validator := NewSchemaValidator()
+	g.Expect(validator.LoadBundle(bundle)).To(Succeed())
+
+	writeFiles := func(replicas, size string) string {
+		dir := t.TempDir()
+		files := map[string]string{
+			"crd.yaml": testCRD,
+			"deployment.yaml": `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+  namespace: default
+spec:
+  replicas: ` + replicas + "\n",
+			"widget.yaml": `apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: widget
+  namespace: default
+spec:
+  size: ` + size + "\n",
+			"configmap.yaml": `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+  namespace: default
+data:
+  key: value
+`,
+		}
+		for name, data := range files {
+			g.Expect(os.WriteFile(filepath.Join(dir, name), []byte(data), 0o600)).To(Succeed())
+		}
+		return dir
+	}
+
+	dir := writeFiles("three", "big")
+	_, _, err := NewGenerator(dir, unstructured.Unstructured{}).
+		WithSchemaValidator(validator).
+		CachedBuild(dir, false, securefs.Limits{})
+	g.Expect(err).To(HaveOccurred())
+	var errs ValidationErrors
+	g.Expect(errors.As(err, &errs)).To(BeTrue())
+	g.Expect(errs).To(HaveLen(2))
+	g.Expect(errs[0].File).To(Equal("deployment.yaml"))
+	g.Expect(errs[0].Message).To(ContainSubstring("Deployment/default/app is invalid: spec.replicas"))
+	g.Expect(errs[1].File).To(Equal("widget.yaml"))
+	g.Expect(errs[1].Message).To(ContainSubstring("Widget/default/widget is invalid: spec.size"))
+
+	dir = writeFiles("3", "2")
+	output, _, err := NewGenerator(dir, unstructured.Unstructured{}).
+		WithSchemaValidator(validator).
+		CachedBuild(dir, false, securefs.Limits{})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(string(output)).To(ContainSubstring("kind: Widget"))
+	g.Expect(string(output)).ToNot(ContainSubstring(originAnnotationKey))
+}
+
+func TestSchemaValidator_LoadBundle(t *testing.T) {
+	g := NewWithT(t)
+
+	bundle := t.TempDir()
+	g.Expect(os.WriteFile(filepath.Join(bundle, "crds.yaml"), []byte(testCRD), 0o600)).To(Succeed())
+	g.Expect(os.WriteFile(filepath.Join(bundle, "README.md"), []byte("# schemas"), 0o600)).To(Succeed())
+	validator := NewSchemaValidator()
+	g.Expect(validator.LoadBundle(bundle)).To(Succeed())
+	g.Expect(validator.schemas).To(HaveLen(1))
+
+	g.Expect(os.WriteFile(filepath.Join(bundle, "ref.json"),
+		[]byte(`{"properties": {"spec": {"$ref": "#/definitions/spec"}}}`), 0o600)).To(Succeed())
+	g.Expect(validator.LoadBundle(bundle)).To(MatchError(ContainSubstring("standalone schemas")))
+}
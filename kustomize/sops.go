@@ -0,0 +1,168 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kustomize
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/getsops/sops/v3"
+	"github.com/getsops/sops/v3/aes"
+	sopsage "github.com/getsops/sops/v3/age"
+	"github.com/getsops/sops/v3/keyservice"
+	"github.com/getsops/sops/v3/pgp"
+	sopsdotenv "github.com/getsops/sops/v3/stores/dotenv"
+	sopsjson "github.com/getsops/sops/v3/stores/json"
+	sopsyaml "github.com/getsops/sops/v3/stores/yaml"
+	"google.golang.org/grpc"
+)
+
+// SOPSDecryptor is a Decryptor for SOPS encrypted files, which decrypts
+// the data key with age identities or OpenPGP keys, without reading the
+// environment of the process.
+type SOPSDecryptor struct {
+	keyService sopsKeyService
+}
+
+// NewSOPSDecryptor returns a new SOPSDecryptor without any keys.
+func NewSOPSDecryptor() *SOPSDecryptor {
+	return &SOPSDecryptor{}
+}
+
+// WithAgeIdentities configures the SOPSDecryptor to decrypt data keys with
+// the given age identities, e.g. the contents of an age keys file.
+func (d *SOPSDecryptor) WithAgeIdentities(identities ...string) (*SOPSDecryptor, error) {
+	if err := d.keyService.ageIdentities.Import(identities...); err != nil {
+		return nil, fmt.Errorf("failed to import age identities: %w", err)
+	}
+	return d, nil
+}
+
+// WithGnuPGHome configures the SOPSDecryptor to decrypt data keys with the
+// OpenPGP keys of the GnuPG home directory at the given path.
+func (d *SOPSDecryptor) WithGnuPGHome(dir string) *SOPSDecryptor {
+	d.keyService.gnuPGHome = pgp.GnuPGHome(dir)
+	return d
+}
+
+// Decrypt implements Decryptor. It returns the data as is if it does not
+// contain SOPS metadata.
+func (d *SOPSDecryptor) Decrypt(data []byte, format DecryptionFormat) ([]byte, error) {
+	if !IsSOPSEncrypted(data, format) {
+		return data, nil
+	}
+
+	var store sopsStore
+	switch format {
+	case DecryptionFormatJSON:
+		store = &sopsjson.Store{}
+	case DecryptionFormatDotenv:
+		store = &sopsdotenv.Store{}
+	default:
+		store = &sopsyaml.Store{}
+	}
+
+	tree, err := store.LoadEncryptedFile(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load encrypted %s data: %w", format, err)
+	}
+
+	dataKey, err := tree.Metadata.GetDataKeyWithKeyServices([]keyservice.KeyServiceClient{&d.keyService})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get data key: %w", err)
+	}
+
+	cipher := aes.NewCipher()
+	mac, err := tree.Decrypt(dataKey, cipher)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt %s data: %w", format, err)
+	}
+	if err := verifyMAC(tree, cipher, dataKey, mac); err != nil {
+		return nil, err
+	}
+
+	out, err := store.EmitPlainFile(tree.Branches)
+	if err != nil {
+		return nil, fmt.Errorf("failed to emit decrypted %s data: %w", format, err)
+	}
+	return out, nil
+}
+
+// sopsStore is the subset of the sops.Store interface used to decrypt data.
+type sopsStore interface {
+	LoadEncryptedFile(in []byte) (sops.Tree, error)
+	EmitPlainFile(in sops.TreeBranches) ([]byte, error)
+}
+
+// verifyMAC compares the MAC of the decrypted tree with the one stored in
+// its metadata.
+func verifyMAC(tree *sops.Tree, cipher sops.Cipher, dataKey []byte, mac string) error {
+	original, err := cipher.Decrypt(tree.Metadata.MessageAuthenticationCode, dataKey,
+		tree.Metadata.LastModified.Format(time.RFC3339))
+	if err != nil {
+		return fmt.Errorf("failed to decrypt original MAC: %w", err)
+	}
+	if original != mac {
+		return fmt.Errorf("failed to verify data integrity: expected MAC '%s', got '%s'", original, mac)
+	}
+	return nil
+}
+
+// sopsKeyService is a keyservice.KeyServiceClient which decrypts data
+// keys with the age identities and the GnuPG home directory of a
+// SOPSDecryptor.
+type sopsKeyService struct {
+	ageIdentities sopsage.ParsedIdentities
+	gnuPGHome     pgp.GnuPGHome
+}
+
+// Decrypt decrypts the ciphertext of the request with the age or OpenPGP
+// key it references.
+func (s *sopsKeyService) Decrypt(_ context.Context, req *keyservice.DecryptRequest, _ ...grpc.CallOption) (*keyservice.DecryptResponse, error) {
+	switch k := req.Key.KeyType.(type) {
+	case *keyservice.Key_AgeKey:
+		key := &sopsage.MasterKey{
+			Recipient:    k.AgeKey.Recipient,
+			EncryptedKey: string(req.Ciphertext),
+		}
+		s.ageIdentities.ApplyToMasterKey(key)
+		plaintext, err := key.Decrypt()
+		if err != nil {
+			return nil, err
+		}
+		return &keyservice.DecryptResponse{Plaintext: plaintext}, nil
+	case *keyservice.Key_PgpKey:
+		key := pgp.NewMasterKeyFromFingerprint(k.PgpKey.Fingerprint)
+		key.EncryptedKey = string(req.Ciphertext)
+		if s.gnuPGHome != "" {
+			s.gnuPGHome.ApplyToMasterKey(key)
+		}
+		plaintext, err := key.Decrypt()
+		if err != nil {
+			return nil, err
+		}
+		return &keyservice.DecryptResponse{Plaintext: plaintext}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", req.Key.KeyType)
+	}
+}
+
+// Encrypt is not supported, as SOPSDecryptor only decrypts data.
+func (s *sopsKeyService) Encrypt(_ context.Context, _ *keyservice.EncryptRequest, _ ...grpc.CallOption) (*keyservice.EncryptResponse, error) {
+	return nil, fmt.Errorf("encryption is not supported")
+}
@@ -0,0 +1,392 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kustomize
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"sigs.k8s.io/kustomize/api/konfig"
+	kustypes "sigs.k8s.io/kustomize/api/types"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+	kyaml "sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// ValidationError describes a problem found in a file by Validate.
+type ValidationError struct {
+	// File is the path of the offending file.
+	File string
+	// Line is the 1-based line of the offending value, or zero if unknown.
+	Line int
+	// Column is the 1-based column of the offending value, or zero if unknown.
+	Column int
+	// Message describes the problem.
+	Message string
+	// Suggestion describes how the problem can be fixed, if known.
+	Suggestion string
+}
+
+func (e *ValidationError) Error() string {
+	var b strings.Builder
+	b.WriteString(e.File)
+	if e.Line > 0 {
+		fmt.Fprintf(&b, ":%d", e.Line)
+		if e.Column > 0 {
+			fmt.Fprintf(&b, ":%d", e.Column)
+		}
+	}
+	b.WriteString(": ")
+	b.WriteString(e.Message)
+	if e.Suggestion != "" {
+		fmt.Fprintf(&b, " (%s)", e.Suggestion)
+	}
+	return b.String()
+}
+
+// ValidationErrors is a list of problems found by Validate.
+type ValidationErrors []*ValidationError
+
+func (e ValidationErrors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Validate parses the kustomization file in dirPath, and the local
+// resources, components and patches it references, and returns
+// ValidationErrors with the location of the problems it finds.
+// It is meant to be run before Build, to surface actionable errors for
+// mistakes which would otherwise result in opaque kustomize errors.
+func Validate(fs filesys.FileSystem, dirPath string) error {
+	v := &validator{fs: fs, visited: make(map[string]bool)}
+	v.validateDir(dirPath, false)
+	if len(v.errs) > 0 {
+		return v.errs
+	}
+	return nil
+}
+
+type validator struct {
+	fs      filesys.FileSystem
+	visited map[string]bool
+	errs    ValidationErrors
+}
+
+func (v *validator) addError(file string, node *kyaml.Node, msg, suggestion string) {
+	err := &ValidationError{File: file, Message: msg, Suggestion: suggestion}
+	if node != nil {
+		err.Line, err.Column = node.Line, node.Column
+	}
+	v.errs = append(v.errs, err)
+}
+
+// validateDir validates the kustomization file in the given directory.
+// If component is true, the file is expected to be of kind Component.
+func (v *validator) validateDir(dirPath string, component bool) {
+	if v.visited[dirPath] {
+		return
+	}
+	v.visited[dirPath] = true
+
+	var kfile string
+	for _, name := range konfig.RecognizedKustomizationFileNames() {
+		if p := filepath.Join(dirPath, name); v.fs.Exists(p) && !v.fs.IsDir(p) {
+			kfile = p
+			break
+		}
+	}
+	if kfile == "" {
+		v.addError(dirPath, nil, "no kustomization file found",
+			fmt.Sprintf("add a '%s' file to the directory", konfig.DefaultKustomizationFileName()))
+		return
+	}
+
+	doc, ok := v.parseFile(kfile)
+	if !ok {
+		return
+	}
+	if doc.Kind != kyaml.MappingNode {
+		v.addError(kfile, doc, "kustomization must be a YAML mapping", "")
+		return
+	}
+
+	known := kustomizationFields()
+	var kind string
+	for i := 0; i+1 < len(doc.Content); i += 2 {
+		key, value := doc.Content[i], doc.Content[i+1]
+		if _, ok := known[key.Value]; !ok {
+			v.addError(kfile, key, fmt.Sprintf("unknown field '%s'", key.Value), suggestField(key.Value, known))
+			continue
+		}
+
+		switch key.Value {
+		case "kind":
+			kind = value.Value
+		case "resources", "bases":
+			for _, entry := range v.stringList(kfile, key.Value, value) {
+				v.validateResource(dirPath, kfile, entry)
+			}
+		case "components":
+			for _, entry := range v.stringList(kfile, key.Value, value) {
+				v.validateComponent(dirPath, kfile, entry)
+			}
+		case "patches", "patchesJson6902":
+			v.validatePatches(dirPath, kfile, key.Value, value)
+		case "patchesStrategicMerge":
+			for _, entry := range v.stringList(kfile, key.Value, value) {
+				if !strings.Contains(entry.Value, "\n") {
+					v.validatePatchFile(dirPath, kfile, entry)
+				}
+			}
+		}
+	}
+
+	switch {
+	case component && kind != kustypes.ComponentKind:
+		v.addError(kfile, doc, "referenced as component but is not of kind Component",
+			fmt.Sprintf("set 'kind: %s' and 'apiVersion: %s'", kustypes.ComponentKind, kustypes.ComponentVersion))
+	case !component && kind == kustypes.ComponentKind:
+		v.addError(kfile, doc, "component cannot be used as a resource",
+			"move the reference to the 'components' field")
+	}
+}
+
+// parseFile reads and parses the given YAML file, and returns the content
+// of its first document.
+func (v *validator) parseFile(path string) (*kyaml.Node, bool) {
+	data, err := v.fs.ReadFile(path)
+	if err != nil {
+		v.addError(path, nil, fmt.Sprintf("failed to read file: %s", err), "")
+		return nil, false
+	}
+
+	var doc kyaml.Node
+	if err := kyaml.Unmarshal(data, &doc); err != nil {
+		v.errs = append(v.errs, yamlSyntaxError(path, err))
+		return nil, false
+	}
+	if len(doc.Content) == 0 {
+		v.addError(path, nil, "file is empty", "")
+		return nil, false
+	}
+	return doc.Content[0], true
+}
+
+// stringList returns the scalar entries of the given sequence node, and
+// records an error for any other entries.
+func (v *validator) stringList(file, field string, node *kyaml.Node) []*kyaml.Node {
+	if node.Kind != kyaml.SequenceNode {
+		v.addError(file, node, fmt.Sprintf("field '%s' must be a list", field),
+			fmt.Sprintf("write each entry of '%s' on its own line prefixed with '- '", field))
+		return nil
+	}
+	var entries []*kyaml.Node
+	for _, n := range node.Content {
+		if n.Kind != kyaml.ScalarNode || n.Value == "" {
+			v.addError(file, n, fmt.Sprintf("entries of '%s' must be non-empty strings", field), "")
+			continue
+		}
+		entries = append(entries, n)
+	}
+	return entries
+}
+
+func (v *validator) validateResource(dirPath, kfile string, entry *kyaml.Node) {
+	if isRemoteRef(entry.Value) {
+		return
+	}
+	path := filepath.Join(dirPath, entry.Value)
+	if !v.fs.Exists(path) {
+		v.addError(kfile, entry, fmt.Sprintf("resource '%s' not found", entry.Value),
+			"paths must be relative to the directory of the kustomization file")
+		return
+	}
+	if v.fs.IsDir(path) {
+		v.validateDir(path, false)
+	}
+}
+
+func (v *validator) validateComponent(dirPath, kfile string, entry *kyaml.Node) {
+	if isRemoteRef(entry.Value) {
+		return
+	}
+	path := filepath.Join(dirPath, entry.Value)
+	if !v.fs.Exists(path) || !v.fs.IsDir(path) {
+		v.addError(kfile, entry, fmt.Sprintf("component directory '%s' not found", entry.Value),
+			"paths must be relative to the directory of the kustomization file")
+		return
+	}
+	v.validateDir(path, true)
+}
+
+func (v *validator) validatePatches(dirPath, kfile, field string, node *kyaml.Node) {
+	if node.Kind != kyaml.SequenceNode {
+		v.addError(kfile, node, fmt.Sprintf("field '%s' must be a list", field), "")
+		return
+	}
+	for _, p := range node.Content {
+		if p.Kind != kyaml.MappingNode {
+			v.addError(kfile, p, fmt.Sprintf("entries of '%s' must be mappings", field),
+				"specify the patch with the 'path' or 'patch' field")
+			continue
+		}
+		var path, patch *kyaml.Node
+		for i := 0; i+1 < len(p.Content); i += 2 {
+			switch p.Content[i].Value {
+			case "path":
+				path = p.Content[i+1]
+			case "patch":
+				patch = p.Content[i+1]
+			}
+		}
+		switch {
+		case path != nil && patch != nil:
+			v.addError(kfile, p, "patch cannot have both 'path' and 'patch'", "remove one of the fields")
+		case path == nil && patch == nil:
+			v.addError(kfile, p, "patch must have either 'path' or 'patch'", "")
+		case path != nil:
+			v.validatePatchFile(dirPath, kfile, path)
+		}
+	}
+}
+
+func (v *validator) validatePatchFile(dirPath, kfile string, entry *kyaml.Node) {
+	path := filepath.Join(dirPath, entry.Value)
+	if !v.fs.Exists(path) || v.fs.IsDir(path) {
+		v.addError(kfile, entry, fmt.Sprintf("patch file '%s' not found", entry.Value),
+			"paths must be relative to the directory of the kustomization file")
+		return
+	}
+	data, err := v.fs.ReadFile(path)
+	if err != nil {
+		v.addError(path, nil, fmt.Sprintf("failed to read file: %s", err), "")
+		return
+	}
+	dec := kyaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var doc kyaml.Node
+		if err := dec.Decode(&doc); err != nil {
+			if err != io.EOF {
+				v.errs = append(v.errs, yamlSyntaxError(path, err))
+			}
+			return
+		}
+	}
+}
+
+var yamlLineRegexp = regexp.MustCompile(`line (\d+):?\s*`)
+
+// yamlSyntaxError converts a YAML parsing error into a ValidationError with
+// the line of the error, if present.
+func yamlSyntaxError(path string, err error) *ValidationError {
+	msg := strings.TrimPrefix(err.Error(), "yaml: ")
+	verr := &ValidationError{
+		File:       path,
+		Suggestion: "check the indentation and quoting of the values",
+	}
+	if m := yamlLineRegexp.FindStringSubmatchIndex(msg); m != nil {
+		verr.Line, _ = strconv.Atoi(msg[m[2]:m[3]])
+		msg = msg[:m[0]] + msg[m[1]:]
+	}
+	verr.Message = "invalid YAML: " + msg
+	return verr
+}
+
+// isRemoteRef returns true if the given resource or component reference
+// points to a remote location.
+func isRemoteRef(s string) bool {
+	return isUrl(s) || strings.HasPrefix(s, "git@") || strings.Contains(s, "?ref=") ||
+		strings.Contains(s, "//")
+}
+
+// kustomizationFields returns the set of the field names of a Kustomization.
+func kustomizationFields() map[string]struct{} {
+	fields := make(map[string]struct{})
+	var collect func(t reflect.Type)
+	collect = func(t reflect.Type) {
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if name == "" && strings.Contains(opts, "inline") && f.Type.Kind() == reflect.Struct {
+				collect(f.Type)
+				continue
+			}
+			if name != "" && name != "-" {
+				fields[name] = struct{}{}
+			}
+		}
+	}
+	collect(reflect.TypeOf(kustypes.Kustomization{}))
+	return fields
+}
+
+// suggestField returns a suggestion with the known field closest to the
+// given name, if any is close enough.
+func suggestField(name string, known map[string]struct{}) string {
+	candidates := make([]string, 0, len(known))
+	for k := range known {
+		candidates = append(candidates, k)
+	}
+	sort.Strings(candidates)
+
+	best, bestDist := "", len(name)/2+1
+	for _, c := range candidates {
+		if d := levenshtein(strings.ToLower(name), strings.ToLower(c)); d < bestDist {
+			best, bestDist = c, d
+		}
+	}
+	if best == "" {
+		return ""
+	}
+	return fmt.Sprintf("did you mean '%s'?", best)
+}
+
+func levenshtein(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = minInt(prev[j]+1, minInt(curr[j-1]+1, prev[j-1]+cost))
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,104 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kustomize
+
+import (
+	"errors"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+func TestValidate(t *testing.T) {
+	g := NewWithT(t)
+
+	fs := filesys.MakeFsInMemory()
+	files := map[string]string{
+		"/app/kustomization.yaml": `apiVersion: kustomize.config.k8s.io/v1beta1
+kind: Kustomization
+resource:
+  - deployment.yaml
+resources:
+  - deployment.yaml
+  - missing.yaml
+  - https://github.com/fluxcd/flux2//manifests/rbac?ref=main
+components:
+  - ../not-a-component
+patches:
+  - path: patch.yaml
+    patch: |
+      - op: remove
+  - path: broken-patch.yaml
+`,
+		"/app/deployment.yaml": "apiVersion: apps/v1\nkind: Deployment\n",
+		"/app/patch.yaml":      "apiVersion: apps/v1\nkind: Deployment\n",
+		"/app/broken-patch.yaml": `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+   labels: {}
+`,
+		"/not-a-component/kustomization.yaml": "apiVersion: kustomize.config.k8s.io/v1beta1\nkind: Kustomization\n",
+	}
+	for path, data := range files {
+		g.Expect(fs.WriteFile(path, []byte(data))).To(Succeed())
+	}
+
+	err := Validate(fs, "/app")
+	g.Expect(err).To(HaveOccurred())
+
+	var errs ValidationErrors
+	g.Expect(errors.As(err, &errs)).To(BeTrue())
+	g.Expect(errs).To(HaveLen(5))
+
+	g.Expect(*errs[0]).To(Equal(ValidationError{
+		File:       "/app/kustomization.yaml",
+		Line:       3,
+		Column:     1,
+		Message:    "unknown field 'resource'",
+		Suggestion: "did you mean 'resources'?",
+	}))
+	g.Expect(errs[1].Error()).To(Equal("/app/kustomization.yaml:7:5: resource 'missing.yaml' not found " +
+		"(paths must be relative to the directory of the kustomization file)"))
+	g.Expect(errs[2].File).To(Equal("/not-a-component/kustomization.yaml"))
+	g.Expect(errs[2].Message).To(Equal("referenced as component but is not of kind Component"))
+	g.Expect(errs[3].Line).To(Equal(12))
+	g.Expect(errs[3].Message).To(Equal("patch cannot have both 'path' and 'patch'"))
+	g.Expect(errs[4].File).To(Equal("/app/broken-patch.yaml"))
+	g.Expect(errs[4].Line).To(Equal(5))
+	g.Expect(errs[4].Message).To(HavePrefix("invalid YAML: "))
+}
+
+func TestValidate_Valid(t *testing.T) {
+	g := NewWithT(t)
+
+	fs := filesys.MakeFsInMemory()
+	g.Expect(fs.WriteFile("/app/kustomization.yaml", []byte(`apiVersion: kustomize.config.k8s.io/v1beta1
+kind: Kustomization
+resources:
+  - ../base
+components:
+  - ../component
+`))).To(Succeed())
+	g.Expect(fs.WriteFile("/base/kustomization.yaml", []byte("resources: []\n"))).To(Succeed())
+	g.Expect(fs.WriteFile("/component/kustomization.yaml", []byte(`apiVersion: kustomize.config.k8s.io/v1alpha1
+kind: Component
+`))).To(Succeed())
+
+	g.Expect(Validate(fs, "/app")).To(Succeed())
+}
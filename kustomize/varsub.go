@@ -0,0 +1,136 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kustomize
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/drone/envsubst"
+	"github.com/drone/envsubst/parse"
+)
+
+// ErrMissingVariable is returned in strict mode when the input references
+// a variable which is not set and has no default value.
+var ErrMissingVariable = errors.New("variable not set")
+
+// SubstituteOptions configures the behaviour of Substitute.
+type SubstituteOptions struct {
+	// Strict makes the substitution fail with ErrMissingVariable when
+	// a variable without a default value is not set.
+	Strict bool
+}
+
+// SubstituteReport describes how the variables referenced in the input
+// of Substitute were resolved.
+type SubstituteReport struct {
+	// Used holds the sorted names of the referenced variables
+	// which were set.
+	Used []string
+	// Defaulted holds the sorted names of the referenced variables
+	// which were not set, and were replaced with their default value.
+	Defaulted []string
+	// Missing holds the sorted names of the referenced variables
+	// which were not set and have no default value, and were
+	// replaced with an empty string.
+	Missing []string
+}
+
+// Substitute replaces the bash style variable references in the input with
+// their values. It supports the expressions of the envsubst package, such as
+// ${var}, ${var:=default} and ${var:-default}. References can be escaped with
+// a double dollar sign, e.g. $${var} results in the literal ${var}.
+// The names of the given variables must match varsubRegex.
+func Substitute(input string, vars map[string]string, opts SubstituteOptions) (string, *SubstituteReport, error) {
+	r, _ := regexp.Compile(varsubRegex)
+	for v := range vars {
+		if !r.MatchString(v) {
+			return "", nil, fmt.Errorf("'%s' var name is invalid, must match '%s'", v, varsubRegex)
+		}
+	}
+
+	tree, err := parse.Parse(input)
+	if err != nil {
+		return "", nil, fmt.Errorf("variable substitution failed: %w", err)
+	}
+
+	refs := make(map[string]bool)
+	collectReferences(tree.Root, refs)
+
+	report := &SubstituteReport{}
+	for name, hasDefault := range refs {
+		_, ok := vars[name]
+		switch {
+		case ok:
+			report.Used = append(report.Used, name)
+		case hasDefault:
+			report.Defaulted = append(report.Defaulted, name)
+		default:
+			report.Missing = append(report.Missing, name)
+		}
+	}
+	sort.Strings(report.Used)
+	sort.Strings(report.Defaulted)
+	sort.Strings(report.Missing)
+
+	if opts.Strict && len(report.Missing) > 0 {
+		return "", report, fmt.Errorf("%w: %s", ErrMissingVariable, strings.Join(report.Missing, ", "))
+	}
+
+	output, err := envsubst.Eval(input, func(s string) string {
+		return vars[s]
+	})
+	if err != nil {
+		return "", report, fmt.Errorf("variable substitution failed: %w", err)
+	}
+	return output, report, nil
+}
+
+// collectReferences records the names of the variables referenced in the
+// given node and its children. A variable is recorded as having a default
+// value only if all of its references have one.
+func collectReferences(node parse.Node, refs map[string]bool) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		for _, c := range n.Nodes {
+			collectReferences(c, refs)
+		}
+	case *parse.FuncNode:
+		hasDefault := isDefaultFunc(n.Name)
+		if prev, ok := refs[n.Param]; ok {
+			hasDefault = hasDefault && prev
+		}
+		refs[n.Param] = hasDefault
+		for _, c := range n.Args {
+			collectReferences(c, refs)
+		}
+	}
+}
+
+// isDefaultFunc returns true if the named envsubst function replaces
+// unset variables with a default value.
+func isDefaultFunc(name string) bool {
+	switch name {
+	case "=", ":=", "-", ":-":
+		return true
+	default:
+		return false
+	}
+}
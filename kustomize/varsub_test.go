@@ -0,0 +1,95 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kustomize
+
+import (
+	"errors"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestSubstitute(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      string
+		vars       map[string]string
+		strict     bool
+		want       string
+		wantReport SubstituteReport
+		wantErr    error
+	}{
+		{
+			name:       "set variables",
+			input:      "name: ${app}-${env}",
+			vars:       map[string]string{"app": "podinfo", "env": "prod", "unused": "x"},
+			want:       "name: podinfo-prod",
+			wantReport: SubstituteReport{Used: []string{"app", "env"}},
+		},
+		{
+			name:       "default values",
+			input:      "replicas: ${replicas:=2}\nregion: ${region:-${default_region:=eu}}",
+			vars:       map[string]string{},
+			want:       "replicas: 2\nregion: eu",
+			wantReport: SubstituteReport{Defaulted: []string{"default_region", "region", "replicas"}},
+		},
+		{
+			name:       "escaped references",
+			input:      "cmd: echo $${HOME} ${app}",
+			vars:       map[string]string{"app": "podinfo"},
+			want:       "cmd: echo ${HOME} podinfo",
+			wantReport: SubstituteReport{Used: []string{"app"}},
+		},
+		{
+			name:       "missing variables",
+			input:      "name: ${app}${suffix}",
+			vars:       map[string]string{"app": "podinfo"},
+			want:       "name: podinfo",
+			wantReport: SubstituteReport{Used: []string{"app"}, Missing: []string{"suffix"}},
+		},
+		{
+			name:       "missing variables in strict mode",
+			input:      "name: ${app}${suffix}${tier:=web}",
+			vars:       map[string]string{"app": "podinfo"},
+			strict:     true,
+			wantReport: SubstituteReport{Used: []string{"app"}, Defaulted: []string{"tier"}, Missing: []string{"suffix"}},
+			wantErr:    ErrMissingVariable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			got, report, err := Substitute(tt.input, tt.vars, SubstituteOptions{Strict: tt.strict})
+			if tt.wantErr != nil {
+				g.Expect(errors.Is(err, tt.wantErr)).To(BeTrue())
+			} else {
+				g.Expect(err).ToNot(HaveOccurred())
+				g.Expect(got).To(Equal(tt.want))
+			}
+			g.Expect(*report).To(Equal(tt.wantReport))
+		})
+	}
+}
+
+func TestSubstitute_InvalidName(t *testing.T) {
+	g := NewWithT(t)
+
+	_, _, err := Substitute("${app}", map[string]string{"app-name": "podinfo"}, SubstituteOptions{})
+	g.Expect(err).To(MatchError(ContainSubstring("'app-name' var name is invalid")))
+}
@@ -0,0 +1,187 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lockedfile
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SwapDir atomically replaces the content of the directory at path with the
+// content written by populate, so that readers of path never observe a
+// partially written directory.
+//
+// populate is called with an empty temporary directory, located next to
+// path, to write the new content into. Once populate returns, the content is
+// synced to disk, and path is atomically switched to it by renaming a
+// symbolic link over path. The previous content is then removed. If
+// populate fails, path is left untouched.
+//
+// path is managed as a symbolic link to a hidden sibling directory, named
+// after path with a '.swap-' suffix. If path is an existing directory, it is
+// moved to a hidden sibling directory on the first swap, during which path
+// briefly does not exist.
+//
+// Concurrent calls for the same path, including from other processes, are
+// serialized with a Mutex at path + ".lock". Leftovers of interrupted swaps
+// are removed by the next call.
+func SwapDir(path string, populate func(dir string) error) (err error) {
+	path = filepath.Clean(path)
+	parent, base := filepath.Dir(path), filepath.Base(path)
+	prefix := "." + base + ".swap-"
+
+	unlock, err := MutexAt(path + ".lock").Lock()
+	if err != nil {
+		return fmt.Errorf("failed to lock '%s': %w", path, err)
+	}
+	defer unlock()
+
+	current, err := swapDirTarget(path, parent, prefix)
+	if err != nil {
+		return err
+	}
+	if err := removeSwapDirLeftovers(parent, prefix, current); err != nil {
+		return err
+	}
+
+	dir, err := os.MkdirTemp(parent, prefix)
+	if err != nil {
+		return fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			os.RemoveAll(dir)
+		}
+	}()
+
+	if err := populate(dir); err != nil {
+		return err
+	}
+	if err := syncTree(dir); err != nil {
+		return fmt.Errorf("failed to sync '%s': %w", dir, err)
+	}
+
+	link := filepath.Join(parent, prefix+"link-"+strconv.FormatInt(time.Now().UnixNano(), 36))
+	if err := os.Symlink(filepath.Base(dir), link); err != nil {
+		return fmt.Errorf("failed to create symlink: %w", err)
+	}
+	if err := os.Rename(link, path); err != nil {
+		os.Remove(link)
+		return fmt.Errorf("failed to swap '%s': %w", path, err)
+	}
+	if err := syncDir(parent); err != nil {
+		return fmt.Errorf("failed to sync '%s': %w", parent, err)
+	}
+
+	// Only remove the previous content if it is managed by SwapDir.
+	if strings.HasPrefix(filepath.Base(current), prefix) && filepath.Dir(current) == parent {
+		if err := os.RemoveAll(current); err != nil {
+			return fmt.Errorf("failed to remove previous content '%s': %w", current, err)
+		}
+	}
+	return nil
+}
+
+// swapDirTarget returns the directory path points to, moving path to a
+// hidden sibling directory if it is a directory. It returns an empty string
+// if path does not exist.
+func swapDirTarget(path, parent, prefix string) (string, error) {
+	fi, err := os.Lstat(path)
+	switch {
+	case os.IsNotExist(err):
+		return "", nil
+	case err != nil:
+		return "", err
+	case fi.Mode()&fs.ModeSymlink != 0:
+		target, err := os.Readlink(path)
+		if err != nil {
+			return "", err
+		}
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(parent, target)
+		}
+		return target, nil
+	case fi.IsDir():
+		target := filepath.Join(parent, prefix+"moved-"+strconv.FormatInt(time.Now().UnixNano(), 36))
+		if err := os.Rename(path, target); err != nil {
+			return "", fmt.Errorf("failed to move '%s': %w", path, err)
+		}
+		return target, nil
+	default:
+		return "", fmt.Errorf("'%s' is not a directory", path)
+	}
+}
+
+// removeSwapDirLeftovers removes the hidden siblings of path left by
+// interrupted swaps, except for the current target.
+func removeSwapDirLeftovers(parent, prefix, current string) error {
+	entries, err := os.ReadDir(parent)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		p := filepath.Join(parent, e.Name())
+		if !strings.HasPrefix(e.Name(), prefix) || p == current {
+			continue
+		}
+		if err := os.RemoveAll(p); err != nil {
+			return fmt.Errorf("failed to remove '%s': %w", p, err)
+		}
+	}
+	return nil
+}
+
+// syncTree flushes the files and directories of the tree rooted at dir to disk.
+func syncTree(dir string) error {
+	return filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return syncDir(p)
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		if err := f.Sync(); err != nil {
+			f.Close()
+			return err
+		}
+		return f.Close()
+	})
+}
+
+func syncDir(dir string) error {
+	f, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
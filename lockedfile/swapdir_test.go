@@ -0,0 +1,133 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lockedfile
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestSwapDir(t *testing.T) {
+	parent := t.TempDir()
+	path := filepath.Join(parent, "artifacts")
+
+	write := func(content string) func(dir string) error {
+		return func(dir string) error {
+			return os.WriteFile(filepath.Join(dir, "file.txt"), []byte(content), 0o644)
+		}
+	}
+	read := func() string {
+		t.Helper()
+		b, err := os.ReadFile(filepath.Join(path, "file.txt"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(b)
+	}
+
+	if err := SwapDir(path, write("v1")); err != nil {
+		t.Fatal(err)
+	}
+	if got := read(); got != "v1" {
+		t.Errorf("expected content 'v1', got '%s'", got)
+	}
+
+	if err := SwapDir(path, write("v2")); err != nil {
+		t.Fatal(err)
+	}
+	if got := read(); got != "v2" {
+		t.Errorf("expected content 'v2', got '%s'", got)
+	}
+
+	errPopulate := errors.New("populate failed")
+	if err := SwapDir(path, func(dir string) error { return errPopulate }); !errors.Is(err, errPopulate) {
+		t.Errorf("expected populate error, got %v", err)
+	}
+	if got := read(); got != "v2" {
+		t.Errorf("expected content 'v2' after failed swap, got '%s'", got)
+	}
+
+	// Only the current content, the symlink and the lock file remain.
+	entries, err := os.ReadDir(parent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		var names []string
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+		t.Errorf("expected 3 entries, got %v", names)
+	}
+}
+
+func TestSwapDir_existingDir(t *testing.T) {
+	parent := t.TempDir()
+	path := filepath.Join(parent, "artifacts")
+	if err := os.MkdirAll(path, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(path, "old.txt"), []byte("old"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// Unrelated sibling with a similar name must be preserved.
+	sibling := filepath.Join(parent, ".artifacts-cache")
+	if err := os.MkdirAll(sibling, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := SwapDir(path, func(dir string) error {
+		return os.WriteFile(filepath.Join(dir, "new.txt"), []byte("new"), 0o644)
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(filepath.Join(path, "new.txt")); err != nil {
+		t.Errorf("expected new content: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(path, "old.txt")); !os.IsNotExist(err) {
+		t.Errorf("expected old content to be removed, got %v", err)
+	}
+	if _, err := os.Stat(sibling); err != nil {
+		t.Errorf("expected sibling to be preserved: %v", err)
+	}
+}
+
+func TestSwapDir_concurrent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "artifacts")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := SwapDir(path, func(dir string) error {
+				return os.WriteFile(filepath.Join(dir, "file.txt"), []byte("content"), 0o644)
+			}); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if _, err := os.Stat(filepath.Join(path, "file.txt")); err != nil {
+		t.Errorf("expected content: %v", err)
+	}
+}
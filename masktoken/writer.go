@@ -0,0 +1,235 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package masktoken
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Mask is the replacement for redacted secrets.
+const Mask = "*****"
+
+// maxBufferSize is the number of bytes a Writer buffers while waiting
+// for the end of a line before it redacts and writes them regardless.
+const maxBufferSize = 64 * 1024
+
+// Option configures a Masker.
+type Option func(*maskerOptions)
+
+type maskerOptions struct {
+	secrets  []string
+	patterns []string
+}
+
+// WithSecrets registers secrets that are redacted verbatim.
+// Empty secrets are ignored.
+func WithSecrets(secrets ...string) Option {
+	return func(o *maskerOptions) {
+		o.secrets = append(o.secrets, secrets...)
+	}
+}
+
+// WithPatterns registers regular expressions of which all matches are
+// redacted. When used with a Writer, the patterns are matched against
+// complete lines.
+func WithPatterns(patterns ...string) Option {
+	return func(o *maskerOptions) {
+		o.patterns = append(o.patterns, patterns...)
+	}
+}
+
+// Masker redacts a registered set of secrets and patterns.
+type Masker struct {
+	re *regexp.Regexp
+	// maxSecretLen is the length of the longest secret.
+	maxSecretLen int
+	// hasPatterns is true if patterns of unbounded length are registered.
+	hasPatterns bool
+}
+
+// NewMasker returns a Masker for the given secrets and patterns.
+// It returns an error if a secret is not a valid UTF-8 string, or if
+// a pattern can not be compiled.
+func NewMasker(opts ...Option) (*Masker, error) {
+	o := &maskerOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	m := &Masker{}
+	var exprs []string
+	secrets := make([]string, 0, len(o.secrets))
+	for _, s := range o.secrets {
+		if s != "" {
+			secrets = append(secrets, s)
+		}
+	}
+	// Prefer the longest secret when secrets overlap.
+	sort.SliceStable(secrets, func(i, j int) bool {
+		return len(secrets[i]) > len(secrets[j])
+	})
+	for _, s := range secrets {
+		if !utf8.ValidString(s) {
+			return nil, fmt.Errorf("invalid secret: not a valid UTF-8 string")
+		}
+		exprs = append(exprs, regexp.QuoteMeta(s))
+		if len(s) > m.maxSecretLen {
+			m.maxSecretLen = len(s)
+		}
+	}
+	for _, p := range o.patterns {
+		if _, err := regexp.Compile(p); err != nil {
+			return nil, fmt.Errorf("invalid pattern '%s': %w", p, err)
+		}
+		exprs = append(exprs, "(?:"+p+")")
+		m.hasPatterns = true
+	}
+	if len(exprs) == 0 {
+		return m, nil
+	}
+
+	re, err := regexp.Compile(strings.Join(exprs, "|"))
+	if err != nil {
+		return nil, err
+	}
+	m.re = re
+	return m, nil
+}
+
+// Redact returns s with all secrets and pattern matches replaced by Mask.
+func (m *Masker) Redact(s string) string {
+	out, _ := m.redact([]byte(s), len(s))
+	return string(out)
+}
+
+// redact replaces the matches in b starting before cut by Mask. It
+// returns the redacted data up to cut, or up to the end of the last
+// redacted match if that is beyond cut, and the offset in b the
+// returned data ends at.
+func (m *Masker) redact(b []byte, cut int) ([]byte, int) {
+	var out []byte
+	last := 0
+	if m.re != nil {
+		for _, loc := range m.re.FindAllIndex(b, -1) {
+			if loc[0] >= cut {
+				break
+			}
+			// Empty matches of patterns are not redacted.
+			if loc[0] == loc[1] {
+				continue
+			}
+			out = append(out, b[last:loc[0]]...)
+			out = append(out, Mask...)
+			last = loc[1]
+		}
+	}
+	if last > cut {
+		cut = last
+	}
+	return append(out, b[last:cut]...), cut
+}
+
+// Writer is an io.Writer that redacts secrets from anything written
+// through it before passing it on to the underlying writer.
+// To redact secrets split across writes, the trailing bytes of a
+// write that may be the start of a secret are held back until the
+// next write. When patterns are registered, incomplete lines are held
+// back as well. Flush must be called to write any held back data.
+type Writer struct {
+	w      io.Writer
+	masker *Masker
+	buf    []byte
+	mu     sync.Mutex
+}
+
+// NewWriter returns a Writer redacting the secrets and patterns of the
+// given Masker from the data written to w.
+func NewWriter(w io.Writer, masker *Masker) *Writer {
+	return &Writer{
+		w:      w,
+		masker: masker,
+	}
+}
+
+// Write redacts p and writes it to the underlying writer, holding back
+// any data that may be part of a secret split across writes.
+func (w *Writer) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.masker.re == nil {
+		return w.w.Write(p)
+	}
+
+	w.buf = append(w.buf, p...)
+	if err := w.write(w.cut()); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Flush redacts and writes all held back data.
+func (w *Writer) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.write(len(w.buf))
+}
+
+// Close flushes the Writer. It does not close the underlying writer.
+func (w *Writer) Close() error {
+	return w.Flush()
+}
+
+// cut returns the offset in the buffer up to which data can be written
+// without risking to leak a secret that is completed by a next write.
+func (w *Writer) cut() int {
+	cut := len(w.buf) - (w.masker.maxSecretLen - 1)
+	if w.masker.hasPatterns && len(w.buf) < maxBufferSize {
+		if nl := bytes.LastIndexByte(w.buf, '\n') + 1; nl < cut {
+			cut = nl
+		}
+	}
+	if cut < 0 {
+		cut = 0
+	}
+	return cut
+}
+
+// write redacts the buffered data up to cut and writes it. If a match
+// starts before cut, the data is written up to the end of the match.
+func (w *Writer) write(cut int) error {
+	if len(w.buf) == 0 {
+		return nil
+	}
+
+	out, cut := w.masker.redact(w.buf, cut)
+	w.buf = append(w.buf[:0], w.buf[cut:]...)
+
+	if len(out) == 0 {
+		return nil
+	}
+	_, err := w.w.Write(out)
+	return err
+}
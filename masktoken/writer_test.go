@@ -0,0 +1,125 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package masktoken
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestMasker_Redact(t *testing.T) {
+	m, err := NewMasker(
+		WithSecrets("8h0387hdyehbwwa45", "8h0387", ""),
+		WithPatterns(`ghp_[A-Za-z0-9]+`, `z*`),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got := m.Redact("token 8h0387hdyehbwwa45, prefix 8h0387, github ghp_abc123.")
+	want := "token *****, prefix *****, github *****."
+	if got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+
+	if _, err := NewMasker(WithSecrets("\x18\xd0\xfa\xab")); err == nil {
+		t.Error("expected error for invalid UTF-8 secret")
+	}
+	if _, err := NewMasker(WithPatterns("(")); err == nil {
+		t.Error("expected error for invalid pattern")
+	}
+}
+
+func TestWriter(t *testing.T) {
+	const secret = "8h0387hdyehbwwa45"
+	input := "first line with " + secret + "\n" +
+		"second line with token=ghp_abc123 and " + secret + secret + "\n" +
+		"unterminated " + secret
+
+	tests := []struct {
+		name      string
+		opts      []Option
+		chunkSize int
+		want      string
+	}{
+		{
+			name:      "no secrets",
+			chunkSize: 3,
+			want:      input,
+		},
+		{
+			name:      "secret in single write",
+			opts:      []Option{WithSecrets(secret)},
+			chunkSize: len(input),
+			want: "first line with *****\n" +
+				"second line with token=ghp_abc123 and **********\n" +
+				"unterminated *****",
+		},
+		{
+			name:      "secret split across writes",
+			opts:      []Option{WithSecrets(secret)},
+			chunkSize: 1,
+			want: "first line with *****\n" +
+				"second line with token=ghp_abc123 and **********\n" +
+				"unterminated *****",
+		},
+		{
+			name:      "pattern split across writes",
+			opts:      []Option{WithSecrets(secret), WithPatterns(`token=\S+`)},
+			chunkSize: 5,
+			want: "first line with *****\n" +
+				"second line with ***** and **********\n" +
+				"unterminated *****",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := NewMasker(tt.opts...)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			var out bytes.Buffer
+			w := NewWriter(&out, m)
+			for i := 0; i < len(input); i += tt.chunkSize {
+				end := i + tt.chunkSize
+				if end > len(input) {
+					end = len(input)
+				}
+				n, err := w.Write([]byte(input[i:end]))
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if n != end-i {
+					t.Fatalf("expected %d bytes written, got %d", end-i, n)
+				}
+				if len(tt.opts) > 0 && strings.Contains(out.String(), secret[:len(secret)/2]) {
+					t.Fatalf("partial secret written: %q", out.String())
+				}
+			}
+			if err := w.Close(); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if got := out.String(); got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,170 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package auth provides a chain of credential providers for OCI artifact
+// registries, which caches the credentials until they are about to expire.
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+
+	"github.com/fluxcd/pkg/cache"
+	"github.com/fluxcd/pkg/oci"
+)
+
+// ErrNoProvider is returned when none of the providers of a Chain
+// supports a registry.
+var ErrNoProvider = errors.New("no provider configured for registry")
+
+// ArtifactRegistryCredentialsProvider knows how to obtain credentials for
+// the registries of a cloud provider or identity federation setup.
+type ArtifactRegistryCredentialsProvider interface {
+	// Name returns the name of the provider, for example: 'aws'.
+	Name() string
+	// Supports returns true if the provider can obtain credentials for
+	// the given registry host.
+	Supports(registry string) bool
+	// Credentials returns the credentials to authenticate against the
+	// given registry host.
+	Credentials(ctx context.Context, registry string) (*Credentials, error)
+}
+
+// Credentials contains the credentials obtained from an
+// ArtifactRegistryCredentialsProvider.
+type Credentials struct {
+	// Authenticator is used to authenticate against the registry.
+	Authenticator authn.Authenticator
+	// ExpiresAt is the time at which the credentials expire. The zero
+	// value means the credentials do not expire.
+	ExpiresAt time.Time
+}
+
+// RefreshBeforeExpiry is the time before their expiry at which cached
+// credentials are considered expired, and are refreshed.
+const RefreshBeforeExpiry = 5 * time.Minute
+
+// ttl returns the duration for which the credentials can be cached at the
+// given time, zero if they do not expire and a negative duration if they
+// expire within RefreshBeforeExpiry.
+func (c *Credentials) ttl(now time.Time) time.Duration {
+	if c.ExpiresAt.IsZero() {
+		return 0
+	}
+	if ttl := c.ExpiresAt.Add(-RefreshBeforeExpiry).Sub(now); ttl > 0 {
+		return ttl
+	}
+	return -1
+}
+
+// Chain obtains credentials for OCI registries from the first of its
+// providers which supports them, and caches them until they expire.
+// A Chain is safe for concurrent use, and is meant to be shared by all
+// the reconcilers of a controller.
+type Chain struct {
+	providers []ArtifactRegistryCredentialsProvider
+	cache     *cache.Cache[string, *Credentials]
+	now       func() time.Time
+}
+
+// NewChain returns a Chain which obtains credentials from the given
+// providers. When multiple providers support the same registry, the first
+// one takes precedence.
+func NewChain(providers ...ArtifactRegistryCredentialsProvider) *Chain {
+	c := &Chain{
+		providers: providers,
+		now:       time.Now,
+	}
+	// The cache can only fail to be created with an invalid capacity.
+	c.cache, _ = cache.New[string, *Credentials](cache.WithClock(func() time.Time { return c.now() }))
+	return c
+}
+
+// Login returns the Authenticator for the registry of the given artifact
+// URL, for example 'ghcr.io/org/repo:tag' or 'oci://ghcr.io/org/repo'.
+// Credentials are cached per provider and registry until they are about
+// to expire, or until Invalidate is called. Concurrent logins to the same
+// registry share a single request to the provider.
+// It returns ErrNoProvider if none of the providers supports the registry.
+func (c *Chain) Login(ctx context.Context, url string) (authn.Authenticator, error) {
+	creds, err := c.Credentials(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	return creds.Authenticator, nil
+}
+
+// Credentials returns the credentials for the registry of the given
+// artifact URL, as Login does.
+func (c *Chain) Credentials(ctx context.Context, url string) (*Credentials, error) {
+	registry, err := registryHost(url)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, p := range c.providers {
+		if !p.Supports(registry) {
+			continue
+		}
+
+		creds, err := c.cache.GetOrLoad(p.Name()+"/"+registry, func() (*Credentials, time.Duration, error) {
+			creds, err := p.Credentials(ctx, registry)
+			if err != nil {
+				return nil, 0, err
+			}
+			return creds, creds.ttl(c.now()), nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("unable to get credentials from %s provider for '%s': %w", p.Name(), registry, err)
+		}
+		return creds, nil
+	}
+	return nil, fmt.Errorf("%w '%s'", ErrNoProvider, registry)
+}
+
+// Invalidate removes any cached credentials for the registry of the given
+// artifact URL, for example after the registry rejected them.
+func (c *Chain) Invalidate(url string) error {
+	registry, err := registryHost(url)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range c.providers {
+		c.cache.Delete(p.Name() + "/" + registry)
+	}
+	return nil
+}
+
+// registryHost returns the registry host of the given artifact URL.
+func registryHost(url string) (string, error) {
+	url = strings.TrimPrefix(url, oci.OCIRepositoryPrefix)
+	// A URL without a path is the address of the registry itself.
+	if !strings.ContainsRune(strings.TrimSuffix(url, "/"), '/') {
+		return strings.TrimSuffix(url, "/"), nil
+	}
+	ref, err := name.ParseReference(url)
+	if err != nil {
+		return "", fmt.Errorf("invalid URL: %w", err)
+	}
+	return ref.Context().RegistryStr(), nil
+}
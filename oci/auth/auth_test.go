@@ -0,0 +1,217 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	. "github.com/onsi/gomega"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+type fakeProvider struct {
+	name      string
+	host      string
+	expiresAt time.Time
+	calls     int
+}
+
+func (p *fakeProvider) Name() string {
+	return p.name
+}
+
+func (p *fakeProvider) Supports(registry string) bool {
+	return registry == p.host
+}
+
+func (p *fakeProvider) Credentials(_ context.Context, registry string) (*Credentials, error) {
+	p.calls++
+	return &Credentials{
+		Authenticator: authn.FromConfig(authn.AuthConfig{Username: p.name, Password: registry}),
+		ExpiresAt:     p.expiresAt,
+	}, nil
+}
+
+func TestChain_Login(t *testing.T) {
+	g := NewWithT(t)
+
+	first := &fakeProvider{name: "first", host: "registry.example.com"}
+	second := &fakeProvider{name: "second", host: "registry.example.com"}
+	other := &fakeProvider{name: "other", host: "other.example.com:5000"}
+	c := NewChain(first, second, other)
+
+	auth, err := c.Login(context.TODO(), "oci://registry.example.com/org/repo:v1")
+	g.Expect(err).ToNot(HaveOccurred())
+	cfg, err := auth.Authorization()
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(cfg.Username).To(Equal("first"))
+	g.Expect(cfg.Password).To(Equal("registry.example.com"))
+
+	auth, err = c.Login(context.TODO(), "other.example.com:5000")
+	g.Expect(err).ToNot(HaveOccurred())
+	cfg, err = auth.Authorization()
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(cfg.Username).To(Equal("other"))
+
+	// Credentials are served from the cache.
+	_, err = c.Login(context.TODO(), "registry.example.com/org/other")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(first.calls).To(Equal(1))
+	g.Expect(second.calls).To(BeZero())
+
+	g.Expect(c.Invalidate("registry.example.com/org/repo")).To(Succeed())
+	_, err = c.Login(context.TODO(), "registry.example.com/org/repo")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(first.calls).To(Equal(2))
+
+	_, err = c.Login(context.TODO(), "ghcr.io/fluxcd/flux2")
+	g.Expect(errors.Is(err, ErrNoProvider)).To(BeTrue())
+}
+
+func TestChain_LoginExpired(t *testing.T) {
+	g := NewWithT(t)
+
+	now := time.Now()
+	p := &fakeProvider{
+		name:      "fake",
+		host:      "registry.example.com",
+		expiresAt: now.Add(RefreshBeforeExpiry + time.Minute),
+	}
+	c := NewChain(p)
+	c.now = func() time.Time { return now }
+
+	_, err := c.Login(context.TODO(), "registry.example.com/org/repo")
+	g.Expect(err).ToNot(HaveOccurred())
+	_, err = c.Login(context.TODO(), "registry.example.com/org/repo")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(p.calls).To(Equal(1))
+
+	now = now.Add(time.Minute)
+	_, err = c.Login(context.TODO(), "registry.example.com/org/repo")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(p.calls).To(Equal(2))
+}
+
+func TestOIDCProvider_Credentials(t *testing.T) {
+	g := NewWithT(t)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if r.Form.Get("grant_type") != tokenExchangeGrantType ||
+			r.Form.Get("subject_token") != "id-token" ||
+			r.Form.Get("audience") != "registry" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "registry-token",
+			"expires_in":   3600,
+		})
+	}))
+	defer srv.Close()
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	g.Expect(os.WriteFile(tokenFile, []byte("id-token\n"), 0o600)).To(Succeed())
+
+	p := NewOIDCProvider(srv.URL, IDTokenFromFile(tokenFile), "registry.example.com").
+		WithAudience("registry")
+	g.Expect(p.Supports("registry.example.com")).To(BeTrue())
+	g.Expect(p.Supports("ghcr.io")).To(BeFalse())
+
+	creds, err := p.Credentials(context.TODO(), "registry.example.com")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(creds.ExpiresAt).To(BeTemporally("~", time.Now().Add(time.Hour), time.Minute))
+	cfg, err := creds.Authenticator.Authorization()
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(cfg.RegistryToken).To(Equal("registry-token"))
+
+	_, err = p.WithAudience("other").Credentials(context.TODO(), "registry.example.com")
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.Error()).To(ContainSubstring("unexpected status"))
+}
+
+func TestNewHarborProvider(t *testing.T) {
+	g := NewWithT(t)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil || r.Form.Get("audience") != "harbor.example.com" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "robot-secret",
+		})
+	}))
+	defer srv.Close()
+
+	idToken := func(context.Context) (string, error) {
+		return "id-token", nil
+	}
+	p := NewHarborProvider("harbor.example.com", srv.URL, "robot$project+flux", idToken)
+	g.Expect(p.Supports("harbor.example.com")).To(BeTrue())
+
+	creds, err := p.Credentials(context.TODO(), "harbor.example.com")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(creds.ExpiresAt.IsZero()).To(BeTrue())
+	cfg, err := creds.Authenticator.Authorization()
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(cfg.Username).To(Equal("robot$project+flux"))
+	g.Expect(cfg.Password).To(Equal("robot-secret"))
+	g.Expect(cfg.RegistryToken).To(BeEmpty())
+
+	g.Expect(NewGHCRProvider(srv.URL, idToken).Supports(GHCRHost)).To(BeTrue())
+}
+
+func TestIDTokenFromServiceAccount(t *testing.T) {
+	g := NewWithT(t)
+
+	c := fake.NewClientBuilder().WithInterceptorFuncs(interceptor.Funcs{
+		SubResourceCreate: func(ctx context.Context, c client.Client, subResourceName string, obj client.Object, subResource client.Object, opts ...client.SubResourceCreateOption) error {
+			tr, ok := subResource.(*authenticationv1.TokenRequest)
+			if !ok || subResourceName != "token" || obj.GetNamespace() != "apps" || obj.GetName() != "flux" {
+				return errors.New("unexpected token request")
+			}
+			if len(tr.Spec.Audiences) != 1 || tr.Spec.Audiences[0] != "ghcr.io" {
+				return errors.New("unexpected audiences")
+			}
+			tr.Status.Token = "sa-token"
+			return nil
+		},
+	}).Build()
+
+	token, err := IDTokenFromServiceAccount(c, "apps", "flux", "ghcr.io")(context.TODO())
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(token).To(Equal("sa-token"))
+
+	_, err = IDTokenFromServiceAccount(c, "apps", "other", "ghcr.io")(context.TODO())
+	g.Expect(err).To(MatchError(ContainSubstring("service account 'apps/other'")))
+}
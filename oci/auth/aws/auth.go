@@ -50,8 +50,12 @@ func ParseRegistry(registry string) (accountId, awsEcrRegion string, ok bool) {
 // Client is a AWS ECR client which can log into the registry and return
 // authorization information.
 type Client struct {
-	config *aws.Config
-	mu     sync.Mutex
+	config        *aws.Config
+	assumeRoles   []AssumeRole
+	rolesAnywhere *RolesAnywhere
+	// credentials caches the credentials of the role chain across logins.
+	credentials aws.CredentialsProvider
+	mu          sync.Mutex
 }
 
 // NewClient creates a new empty ECR client.
@@ -69,6 +73,7 @@ func (c *Client) WithConfig(cfg *aws.Config) {
 	defer c.mu.Unlock()
 	if c.config == nil {
 		c.config = cfg
+		c.credentials = nil
 	}
 }
 
@@ -98,9 +103,14 @@ func (c *Client) getLoginAuth(ctx context.Context, awsEcrRegion string) (authn.A
 		}
 		c.config = &cfg
 	}
+	// Chain the roles on a copy of the config, as c.config may point to cfg.
+	chainedCfg, err := c.withCredentialChain(cfg.Copy())
 	c.mu.Unlock()
+	if err != nil {
+		return authConfig, err
+	}
 
-	ecrService := ecr.NewFromConfig(cfg)
+	ecrService := ecr.NewFromConfig(chainedCfg)
 	// NOTE: ecr.GetAuthorizationTokenInput has deprecated RegistryIds. Hence,
 	// pass nil input.
 	ecrToken, err := ecrService.GetAuthorizationToken(ctx, nil)
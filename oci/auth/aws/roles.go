@@ -0,0 +1,369 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aws
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// AssumeRole configures an IAM role to assume before logging in to ECR.
+type AssumeRole struct {
+	// RoleARN is the ARN of the role to assume.
+	RoleARN string
+	// ExternalID is the external ID required by the trust policy of the
+	// role, if any.
+	ExternalID string
+	// SessionName is the name of the role session. Defaults to a name
+	// generated by the AWS SDK.
+	SessionName string
+	// Duration is the duration of the role session. Defaults to the
+	// default duration of the AWS SDK.
+	Duration time.Duration
+}
+
+// RolesAnywhere configures IAM Roles Anywhere as the source of the
+// credentials used to log in to ECR, for workloads authenticating with
+// an X.509 certificate instead of a web identity.
+type RolesAnywhere struct {
+	// TrustAnchorARN is the ARN of the trust anchor of the certificate.
+	TrustAnchorARN string
+	// ProfileARN is the ARN of the Roles Anywhere profile.
+	ProfileARN string
+	// RoleARN is the ARN of the role to obtain credentials for.
+	RoleARN string
+	// Certificate is the end-entity certificate of the workload.
+	Certificate *x509.Certificate
+	// Intermediates are the intermediate certificates of the chain of
+	// Certificate, if any.
+	Intermediates []*x509.Certificate
+	// PrivateKey is the RSA or ECDSA private key of Certificate.
+	PrivateKey crypto.Signer
+	// SessionDuration is the duration of the session. Defaults to one hour.
+	SessionDuration time.Duration
+	// Endpoint overrides the Roles Anywhere endpoint of the region of the
+	// trust anchor.
+	Endpoint string
+}
+
+// WithAssumeRoles configures the client to assume the given roles in order
+// before logging in to ECR, each role being assumed with the credentials of
+// the previous one. The first role is assumed with the credentials of the
+// client config, or with the Roles Anywhere credentials if configured.
+func (c *Client) WithAssumeRoles(roles ...AssumeRole) *Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.assumeRoles = roles
+	c.credentials = nil
+	return c
+}
+
+// WithRolesAnywhere configures the client to obtain the credentials used to
+// log in to ECR from IAM Roles Anywhere.
+func (c *Client) WithRolesAnywhere(ra *RolesAnywhere) *Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.rolesAnywhere = ra
+	c.credentials = nil
+	return c
+}
+
+// withCredentialChain returns a copy of the given config using the
+// credentials of the configured Roles Anywhere profile and role chain.
+// The credentials provider of the chain is created on first use and
+// reused for subsequent logins, so that the credentials of the roles are
+// cached until they expire. The caller must hold the lock.
+func (c *Client) withCredentialChain(cfg aws.Config) (aws.Config, error) {
+	if c.rolesAnywhere == nil && len(c.assumeRoles) == 0 {
+		return cfg, nil
+	}
+	if c.credentials == nil {
+		provider, err := c.credentialChain(cfg)
+		if err != nil {
+			return cfg, err
+		}
+		c.credentials = provider
+	}
+	cfg.Credentials = c.credentials
+	return cfg, nil
+}
+
+// credentialChain returns the credentials provider of the configured Roles
+// Anywhere profile and role chain, starting from the credentials of the
+// given config.
+func (c *Client) credentialChain(cfg aws.Config) (aws.CredentialsProvider, error) {
+	if c.rolesAnywhere != nil {
+		p, err := newRolesAnywhereProvider(c.rolesAnywhere, cfg.HTTPClient)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Credentials = aws.NewCredentialsCache(p)
+	}
+
+	for _, role := range c.assumeRoles {
+		if role.RoleARN == "" {
+			return nil, errors.New("invalid role chain: role ARN is empty")
+		}
+		role := role
+		provider := stscreds.NewAssumeRoleProvider(sts.NewFromConfig(cfg), role.RoleARN, func(o *stscreds.AssumeRoleOptions) {
+			if role.ExternalID != "" {
+				o.ExternalID = aws.String(role.ExternalID)
+			}
+			if role.SessionName != "" {
+				o.RoleSessionName = role.SessionName
+			}
+			if role.Duration > 0 {
+				o.Duration = role.Duration
+			}
+		})
+		cfg.Credentials = aws.NewCredentialsCache(provider)
+	}
+	return cfg.Credentials, nil
+}
+
+const (
+	rolesAnywhereService        = "rolesanywhere"
+	rolesAnywhereRSAAlgorithm   = "AWS4-X509-RSA-SHA256"
+	rolesAnywhereECDSAAlgorithm = "AWS4-X509-ECDSA-SHA256"
+	amzDateFormat               = "20060102T150405Z"
+)
+
+// rolesAnywhereProvider is an aws.CredentialsProvider which obtains
+// credentials with the CreateSession API of IAM Roles Anywhere.
+type rolesAnywhereProvider struct {
+	config     *RolesAnywhere
+	region     string
+	endpoint   string
+	algorithm  string
+	httpClient aws.HTTPClient
+	now        func() time.Time
+}
+
+func newRolesAnywhereProvider(ra *RolesAnywhere, httpClient aws.HTTPClient) (*rolesAnywhereProvider, error) {
+	if ra.Certificate == nil || ra.PrivateKey == nil {
+		return nil, errors.New("invalid Roles Anywhere configuration: certificate and private key are required")
+	}
+
+	// The ARN is of the form 'arn:<partition>:rolesanywhere:<region>:<account>:trust-anchor/<id>'.
+	parts := strings.Split(ra.TrustAnchorARN, ":")
+	if len(parts) < 6 || parts[2] != rolesAnywhereService {
+		return nil, fmt.Errorf("invalid trust anchor ARN '%s'", ra.TrustAnchorARN)
+	}
+	region := parts[3]
+
+	p := &rolesAnywhereProvider{
+		config:     ra,
+		region:     region,
+		endpoint:   ra.Endpoint,
+		httpClient: httpClient,
+		now:        time.Now,
+	}
+	if p.endpoint == "" {
+		domain := "amazonaws.com"
+		if strings.HasPrefix(region, "cn-") {
+			domain = "amazonaws.com.cn"
+		}
+		p.endpoint = fmt.Sprintf("https://%s.%s.%s", rolesAnywhereService, region, domain)
+	}
+	if p.httpClient == nil {
+		p.httpClient = http.DefaultClient
+	}
+
+	switch ra.PrivateKey.(type) {
+	case *rsa.PrivateKey:
+		p.algorithm = rolesAnywhereRSAAlgorithm
+	case *ecdsa.PrivateKey:
+		p.algorithm = rolesAnywhereECDSAAlgorithm
+	default:
+		return nil, fmt.Errorf("unsupported private key type %T", ra.PrivateKey)
+	}
+	return p, nil
+}
+
+type createSessionInput struct {
+	DurationSeconds int64  `json:"durationSeconds"`
+	ProfileARN      string `json:"profileArn"`
+	RoleARN         string `json:"roleArn"`
+	TrustAnchorARN  string `json:"trustAnchorArn"`
+}
+
+type createSessionOutput struct {
+	CredentialSet []struct {
+		Credentials struct {
+			AccessKeyID     string    `json:"accessKeyId"`
+			SecretAccessKey string    `json:"secretAccessKey"`
+			SessionToken    string    `json:"sessionToken"`
+			Expiration      time.Time `json:"expiration"`
+		} `json:"credentials"`
+	} `json:"credentialSet"`
+}
+
+// Retrieve implements aws.CredentialsProvider.
+func (p *rolesAnywhereProvider) Retrieve(ctx context.Context) (aws.Credentials, error) {
+	duration := p.config.SessionDuration
+	if duration == 0 {
+		duration = time.Hour
+	}
+	body, err := json.Marshal(createSessionInput{
+		DurationSeconds: int64(duration.Seconds()),
+		ProfileARN:      p.config.ProfileARN,
+		RoleARN:         p.config.RoleARN,
+		TrustAnchorARN:  p.config.TrustAnchorARN,
+	})
+	if err != nil {
+		return aws.Credentials{}, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(p.endpoint, "/")+"/sessions", bytes.NewReader(body))
+	if err != nil {
+		return aws.Credentials{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if err := p.sign(req, body); err != nil {
+		return aws.Credentials{}, fmt.Errorf("signing Roles Anywhere request failed: %w", err)
+	}
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return aws.Credentials{}, fmt.Errorf("requesting Roles Anywhere session failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return aws.Credentials{}, fmt.Errorf("requesting Roles Anywhere session failed: unexpected status: %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	var out createSessionOutput
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return aws.Credentials{}, fmt.Errorf("decoding Roles Anywhere session failed: %w", err)
+	}
+	if len(out.CredentialSet) == 0 {
+		return aws.Credentials{}, errors.New("no credentials in Roles Anywhere session")
+	}
+
+	creds := out.CredentialSet[0].Credentials
+	return aws.Credentials{
+		AccessKeyID:     creds.AccessKeyID,
+		SecretAccessKey: creds.SecretAccessKey,
+		SessionToken:    creds.SessionToken,
+		Source:          "RolesAnywhere",
+		CanExpire:       true,
+		Expires:         creds.Expiration,
+	}, nil
+}
+
+// sign signs the request with the private key of the certificate, as
+// specified by the Roles Anywhere signing process, which is Signature
+// Version 4 with the signing key replaced by the certificate's key.
+func (p *rolesAnywhereProvider) sign(req *http.Request, body []byte) error {
+	now := p.now().UTC()
+	amzDate := now.Format(amzDateFormat)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-X509", base64.StdEncoding.EncodeToString(p.config.Certificate.Raw))
+	if len(p.config.Intermediates) > 0 {
+		chain := make([]string, 0, len(p.config.Intermediates))
+		for _, cert := range p.config.Intermediates {
+			chain = append(chain, base64.StdEncoding.EncodeToString(cert.Raw))
+		}
+		req.Header.Set("X-Amz-X509-Chain", strings.Join(chain, ","))
+	}
+
+	signedHeaders, canonicalHeaders := canonicalizeHeaders(req)
+	payloadHash := sha256.Sum256(body)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		canonicalURI(req.URL),
+		req.URL.Query().Encode(),
+		canonicalHeaders,
+		signedHeaders,
+		hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+
+	scope := fmt.Sprintf("%s/%s/%s/aws4_request", now.Format("20060102"), p.region, rolesAnywhereService)
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		p.algorithm,
+		amzDate,
+		scope,
+		hex.EncodeToString(requestHash[:]),
+	}, "\n")
+
+	digest := sha256.Sum256([]byte(stringToSign))
+	signature, err := p.config.PrivateKey.Sign(rand.Reader, digest[:], crypto.SHA256)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		p.algorithm, p.config.Certificate.SerialNumber.String(), scope, signedHeaders, hex.EncodeToString(signature)))
+	return nil
+}
+
+// canonicalizeHeaders returns the signed headers and canonical headers of
+// the request, as defined by Signature Version 4.
+func canonicalizeHeaders(req *http.Request) (string, string) {
+	headers := map[string]string{
+		"host": req.URL.Host,
+	}
+	names := []string{"host"}
+	for name, values := range req.Header {
+		name = strings.ToLower(name)
+		if name != "content-type" && !strings.HasPrefix(name, "x-amz-") {
+			continue
+		}
+		headers[name] = strings.TrimSpace(strings.Join(values, ","))
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonical strings.Builder
+	for _, name := range names {
+		canonical.WriteString(name + ":" + headers[name] + "\n")
+	}
+	return strings.Join(names, ";"), canonical.String()
+}
+
+// canonicalURI returns the escaped path of the URL, or '/' if empty.
+func canonicalURI(u *url.URL) string {
+	if p := u.EscapedPath(); p != "" {
+		return p
+	}
+	return "/"
+}
@@ -0,0 +1,205 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aws
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	. "github.com/onsi/gomega"
+)
+
+func TestGetLoginAuth_AssumeRoles(t *testing.T) {
+	g := NewWithT(t)
+
+	var assumed []string
+	var ecrAuthorization string
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Amz-Target") != "" {
+			ecrAuthorization = r.Header.Get("Authorization")
+			w.Write([]byte(`{"authorizationData": [{"authorizationToken": "c29tZS1rZXk6c29tZS1zZWNyZXQ="}]}`))
+			return
+		}
+
+		if err := r.ParseForm(); err != nil || r.Form.Get("Action") != "AssumeRole" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		// Each role must be assumed with the credentials of the previous one.
+		wantKey := "x"
+		if len(assumed) > 0 {
+			wantKey = fmt.Sprintf("AKID%d", len(assumed))
+		}
+		if !strings.Contains(r.Header.Get("Authorization"), "Credential="+wantKey+"/") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		assumed = append(assumed, r.Form.Get("RoleArn")+"|"+r.Form.Get("ExternalId"))
+		fmt.Fprintf(w, `<AssumeRoleResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+  <AssumeRoleResult>
+    <Credentials>
+      <AccessKeyId>AKID%d</AccessKeyId>
+      <SecretAccessKey>secret</SecretAccessKey>
+      <SessionToken>token</SessionToken>
+      <Expiration>%s</Expiration>
+    </Credentials>
+  </AssumeRoleResult>
+</AssumeRoleResponse>`, len(assumed), time.Now().Add(time.Hour).UTC().Format(time.RFC3339))
+	}
+	srv := httptest.NewServer(http.HandlerFunc(handler))
+	t.Cleanup(func() {
+		srv.Close()
+	})
+
+	cfg := aws.NewConfig()
+	cfg.Region = "us-east-1"
+	cfg.EndpointResolverWithOptions = aws.EndpointResolverWithOptionsFunc(func(service, region string, options ...interface{}) (aws.Endpoint, error) {
+		return aws.Endpoint{URL: srv.URL}, nil
+	})
+	cfg.Credentials = credentials.NewStaticCredentialsProvider("x", "y", "z")
+
+	ec := NewClient().WithAssumeRoles(
+		AssumeRole{RoleARN: "arn:aws:iam::111111111111:role/a", ExternalID: "external"},
+		AssumeRole{RoleARN: "arn:aws:iam::222222222222:role/b"},
+	)
+	ec.WithConfig(cfg)
+
+	a, err := ec.getLoginAuth(context.TODO(), "us-east-1")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(a.Username).To(Equal("some-key"))
+	g.Expect(assumed).To(Equal([]string{
+		"arn:aws:iam::111111111111:role/a|external",
+		"arn:aws:iam::222222222222:role/b|",
+	}))
+	g.Expect(ecrAuthorization).To(ContainSubstring("Credential=AKID2/"))
+
+	// The credentials of the roles are reused until they expire.
+	_, err = ec.getLoginAuth(context.TODO(), "us-east-1")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(assumed).To(HaveLen(2))
+	g.Expect(ecrAuthorization).To(ContainSubstring("Credential=AKID2/"))
+}
+
+func TestRolesAnywhereProvider_Retrieve(t *testing.T) {
+	g := NewWithT(t)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	g.Expect(err).ToNot(HaveOccurred())
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(4242),
+		Subject:      pkix.Name{CommonName: "workload"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	g.Expect(err).ToNot(HaveOccurred())
+	cert, err := x509.ParseCertificate(der)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	expiration := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var in createSessionInput
+		if r.URL.Path != "/sessions" || json.Unmarshal(body, &in) != nil || in.RoleARN != "arn:aws:iam::111111111111:role/a" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if r.Header.Get("X-Amz-X509") != base64.StdEncoding.EncodeToString(cert.Raw) {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		// Verify the signature against the public key of the certificate.
+		auth := r.Header.Get("Authorization")
+		prefix := rolesAnywhereECDSAAlgorithm + " Credential=4242/"
+		if !strings.HasPrefix(auth, prefix) {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		scope := strings.SplitN(strings.TrimPrefix(auth, prefix), ",", 2)[0]
+		sig, err := hex.DecodeString(auth[strings.Index(auth, "Signature=")+len("Signature="):])
+		if err != nil {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		r.Header.Del("Authorization")
+		r.URL.Host = r.Host
+		signedHeaders, canonicalHeaders := canonicalizeHeaders(r)
+		payloadHash := sha256.Sum256(body)
+		requestHash := sha256.Sum256([]byte(strings.Join([]string{
+			r.Method, "/sessions", "", canonicalHeaders, signedHeaders, hex.EncodeToString(payloadHash[:]),
+		}, "\n")))
+		digest := sha256.Sum256([]byte(strings.Join([]string{
+			rolesAnywhereECDSAAlgorithm, r.Header.Get("X-Amz-Date"), scope, hex.EncodeToString(requestHash[:]),
+		}, "\n")))
+		if !ecdsa.VerifyASN1(&key.PublicKey, digest[:], sig) {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"credentialSet": [{"credentials": {"accessKeyId": "AKID", "secretAccessKey": "secret", "sessionToken": "token", "expiration": "%s"}}]}`,
+			expiration.Format(time.RFC3339))
+	}
+	srv := httptest.NewServer(http.HandlerFunc(handler))
+	t.Cleanup(func() {
+		srv.Close()
+	})
+
+	p, err := newRolesAnywhereProvider(&RolesAnywhere{
+		TrustAnchorARN: "arn:aws:rolesanywhere:us-east-1:111111111111:trust-anchor/id",
+		ProfileARN:     "arn:aws:rolesanywhere:us-east-1:111111111111:profile/id",
+		RoleARN:        "arn:aws:iam::111111111111:role/a",
+		Certificate:    cert,
+		PrivateKey:     key,
+		Endpoint:       srv.URL,
+	}, nil)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(p.region).To(Equal("us-east-1"))
+
+	creds, err := p.Retrieve(context.TODO())
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(creds.AccessKeyID).To(Equal("AKID"))
+	g.Expect(creds.SessionToken).To(Equal("token"))
+	g.Expect(creds.CanExpire).To(BeTrue())
+	g.Expect(creds.Expires.Equal(expiration)).To(BeTrue())
+
+	_, err = newRolesAnywhereProvider(&RolesAnywhere{
+		TrustAnchorARN: "arn:aws:iam::111111111111:role/a",
+		Certificate:    cert,
+		PrivateKey:     key,
+	}, nil)
+	g.Expect(err).To(HaveOccurred())
+}
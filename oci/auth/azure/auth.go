@@ -20,6 +20,7 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"sync"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
 	_ "github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
@@ -38,11 +39,38 @@ import (
 type Client struct {
 	credential azcore.TokenCredential
 	scheme     string
+
+	// workloadIdentities are the workload identities used to authenticate
+	// against specific registries, keyed by registry host.
+	workloadIdentities map[string]WorkloadIdentity
+	// registryCredentials caches the token credentials of the workload
+	// identities, keyed by registry host.
+	registryCredentials map[string]azcore.TokenCredential
+	// newWorkloadIdentityCredential creates the token credential of a
+	// workload identity. Defaults to newWorkloadIdentityCredential.
+	newWorkloadIdentityCredential func(wi WorkloadIdentity, cloudConfig cloud.Configuration) (azcore.TokenCredential, error)
+	mu                            sync.Mutex
+}
+
+// WorkloadIdentity identifies the Microsoft Entra application used to
+// authenticate against the registries of a tenant with workload identity
+// federation.
+type WorkloadIdentity struct {
+	// TenantID is the ID of the tenant of the application.
+	TenantID string
+	// ClientID is the client ID of the application.
+	ClientID string
+	// TokenFilePath is the path of the federated token of the workload.
+	// Defaults to the value of the AZURE_FEDERATED_TOKEN_FILE environment
+	// variable.
+	TokenFilePath string
 }
 
 // NewClient creates a new ACR client with default configurations.
 func NewClient() *Client {
-	return &Client{scheme: "https"}
+	return &Client{
+		scheme: "https",
+	}
 }
 
 // WithTokenCredential sets the token credential used by the ACR client.
@@ -51,6 +79,21 @@ func (c *Client) WithTokenCredential(tc azcore.TokenCredential) *Client {
 	return c
 }
 
+// WithWorkloadIdentity configures the client to authenticate against the
+// given registry host with the given workload identity, instead of the
+// default token credential. This allows a single client to log in to the
+// registries of multiple tenants.
+func (c *Client) WithWorkloadIdentity(registry string, wi WorkloadIdentity) *Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.workloadIdentities == nil {
+		c.workloadIdentities = make(map[string]WorkloadIdentity)
+	}
+	c.workloadIdentities[registry] = wi
+	delete(c.registryCredentials, registry)
+	return c
+}
+
 // WithScheme sets the scheme of the http request that the client makes.
 func (c *Client) WithScheme(scheme string) *Client {
 	c.scheme = scheme
@@ -63,20 +106,14 @@ func (c *Client) WithScheme(scheme string) *Client {
 func (c *Client) getLoginAuth(ctx context.Context, registryURL string) (authn.AuthConfig, error) {
 	var authConfig authn.AuthConfig
 
-	// Use default credentials if no token credential is provided.
-	// NOTE: NewDefaultAzureCredential() performs a lot of environment lookup
-	// for creating default token credential. Load it only when it's needed.
-	if c.credential == nil {
-		cred, err := azidentity.NewDefaultAzureCredential(nil)
-		if err != nil {
-			return authConfig, err
-		}
-		c.credential = cred
+	configurationEnvironment := getCloudConfiguration(registryURL)
+	credential, err := c.tokenCredential(registryURL, configurationEnvironment)
+	if err != nil {
+		return authConfig, err
 	}
 
-	configurationEnvironment := getCloudConfiguration(registryURL)
 	// Obtain access token using the token credential.
-	armToken, err := c.credential.GetToken(ctx, policy.TokenRequestOptions{
+	armToken, err := credential.GetToken(ctx, policy.TokenRequestOptions{
 		Scopes: []string{configurationEnvironment.Services[cloud.ResourceManager].Endpoint + "/" + ".default"},
 	})
 	if err != nil {
@@ -98,6 +135,62 @@ func (c *Client) getLoginAuth(ctx context.Context, registryURL string) (authn.Au
 	}, nil
 }
 
+// tokenCredential returns the token credential of the workload identity
+// configured for the host of the registry URL, or the default token
+// credential if none is configured.
+func (c *Client) tokenCredential(registryURL string, cloudConfig cloud.Configuration) (azcore.TokenCredential, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	host := registryURL
+	if i := strings.Index(host, "://"); i >= 0 {
+		host = host[i+3:]
+	}
+	host, _, _ = strings.Cut(host, "/")
+
+	if wi, ok := c.workloadIdentities[host]; ok {
+		if cred, ok := c.registryCredentials[host]; ok {
+			return cred, nil
+		}
+		newCredential := c.newWorkloadIdentityCredential
+		if newCredential == nil {
+			newCredential = newWorkloadIdentityCredential
+		}
+		cred, err := newCredential(wi, cloudConfig)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create workload identity credential for tenant '%s': %w", wi.TenantID, err)
+		}
+		if c.registryCredentials == nil {
+			c.registryCredentials = make(map[string]azcore.TokenCredential)
+		}
+		c.registryCredentials[host] = cred
+		return cred, nil
+	}
+
+	// Use default credentials if no token credential is provided.
+	// NOTE: NewDefaultAzureCredential() performs a lot of environment lookup
+	// for creating default token credential. Load it only when it's needed.
+	if c.credential == nil {
+		cred, err := azidentity.NewDefaultAzureCredential(nil)
+		if err != nil {
+			return nil, err
+		}
+		c.credential = cred
+	}
+	return c.credential, nil
+}
+
+// newWorkloadIdentityCredential returns a token credential for the given
+// workload identity, authenticating against the given cloud.
+func newWorkloadIdentityCredential(wi WorkloadIdentity, cloudConfig cloud.Configuration) (azcore.TokenCredential, error) {
+	return azidentity.NewWorkloadIdentityCredential(&azidentity.WorkloadIdentityCredentialOptions{
+		ClientOptions: azcore.ClientOptions{Cloud: cloudConfig},
+		TenantID:      wi.TenantID,
+		ClientID:      wi.ClientID,
+		TokenFilePath: wi.TokenFilePath,
+	})
+}
+
 // getCloudConfiguration returns the cloud configuration based on the registry URL.
 // List from https://github.com/Azure/azure-sdk-for-go/blob/main/sdk/containers/azcontainerregistry/cloud_config.go#L16
 func getCloudConfiguration(url string) cloud.Configuration {
@@ -129,7 +222,11 @@ func (c *Client) Login(ctx context.Context, autoLogin bool, image string, ref na
 		log.FromContext(ctx).Info("logging in to Azure ACR for " + image)
 		// get registry host from image
 		strArr := strings.SplitN(image, "/", 2)
-		endpoint := fmt.Sprintf("%s://%s", c.scheme, strArr[0])
+		scheme := c.scheme
+		if scheme == "" {
+			scheme = "https"
+		}
+		endpoint := fmt.Sprintf("%s://%s", scheme, strArr[0])
 		authConfig, err := c.getLoginAuth(ctx, endpoint)
 		if err != nil {
 			log.FromContext(ctx).Info("error logging into ACR " + err.Error())
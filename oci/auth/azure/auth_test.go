@@ -23,6 +23,7 @@ import (
 	"net/http/httptest"
 	"net/url"
 	"path"
+	"strings"
 	"testing"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
@@ -93,6 +94,58 @@ func TestGetAzureLoginAuth(t *testing.T) {
 	}
 }
 
+func TestGetAzureLoginAuth_WorkloadIdentity(t *testing.T) {
+	g := NewWithT(t)
+
+	// The exchange service returns the ARM token as refresh token.
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"refresh_token": "` + r.FormValue("access_token") + `"}`))
+	}
+	tenantA := httptest.NewServer(http.HandlerFunc(handler))
+	t.Cleanup(tenantA.Close)
+	tenantB := httptest.NewServer(http.HandlerFunc(handler))
+	t.Cleanup(tenantB.Close)
+
+	var created []string
+	c := NewClient().
+		WithTokenCredential(&FakeTokenCredential{Token: "default"}).
+		WithWorkloadIdentity(strings.TrimPrefix(tenantA.URL, "http://"), WorkloadIdentity{TenantID: "a", ClientID: "client-a"}).
+		WithWorkloadIdentity(strings.TrimPrefix(tenantB.URL, "http://"), WorkloadIdentity{TenantID: "b", ClientID: "client-b"})
+	c.newWorkloadIdentityCredential = func(wi WorkloadIdentity, _ cloud.Configuration) (azcore.TokenCredential, error) {
+		created = append(created, wi.TenantID)
+		return &FakeTokenCredential{Token: wi.TenantID + "/" + wi.ClientID}, nil
+	}
+
+	auth, err := c.getLoginAuth(context.TODO(), tenantA.URL)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(auth.Password).To(Equal("a/client-a"))
+
+	auth, err = c.getLoginAuth(context.TODO(), tenantB.URL+"/repo")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(auth.Password).To(Equal("b/client-b"))
+
+	// The credentials of the workload identities are reused.
+	_, err = c.getLoginAuth(context.TODO(), tenantA.URL)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(created).To(Equal([]string{"a", "b"}))
+
+	// Registries without a workload identity use the default credential.
+	other := httptest.NewServer(http.HandlerFunc(handler))
+	t.Cleanup(other.Close)
+	auth, err = c.getLoginAuth(context.TODO(), other.URL)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(auth.Password).To(Equal("default"))
+
+	// A zero-value client can be configured and used.
+	zero := (&Client{}).
+		WithTokenCredential(&FakeTokenCredential{Token: "default"}).
+		WithWorkloadIdentity(strings.TrimPrefix(tenantA.URL, "http://"), WorkloadIdentity{TenantID: "a", ClientID: "client-a"})
+	zero.newWorkloadIdentityCredential = c.newWorkloadIdentityCredential
+	auth, err = zero.getLoginAuth(context.TODO(), tenantA.URL)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(auth.Password).To(Equal("a/client-a"))
+}
+
 func TestValidHost(t *testing.T) {
 	tests := []struct {
 		host   string
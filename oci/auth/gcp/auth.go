@@ -48,12 +48,15 @@ func ValidHost(host string) bool {
 // Client is a GCP GCR client which can log into the registry and return
 // authorization information.
 type Client struct {
-	tokenURL string
+	tokenURL          string
+	iamCredentialsURL string
+	impersonate       string
+	delegates         []string
 }
 
 // NewClient creates a new GCR client with default configurations.
 func NewClient() *Client {
-	return &Client{tokenURL: GCP_TOKEN_URL}
+	return &Client{tokenURL: GCP_TOKEN_URL, iamCredentialsURL: IAM_CREDENTIALS_URL}
 }
 
 // WithTokenURL sets the token URL used by the GCR client.
@@ -65,7 +68,8 @@ func (c *Client) WithTokenURL(url string) *Client {
 // getLoginAuth obtains authentication by getting a token from the metadata API
 // on GCP. This assumes that the pod has right to pull the image which would be
 // the case if it is hosted on GCP. It works with both service account and
-// workload identity enabled clusters.
+// workload identity enabled clusters. If impersonation is configured, the
+// token is exchanged for a token of the impersonated service account.
 func (c *Client) getLoginAuth(ctx context.Context) (authn.AuthConfig, error) {
 	var authConfig authn.AuthConfig
 
@@ -94,9 +98,17 @@ func (c *Client) getLoginAuth(ctx context.Context) (authn.AuthConfig, error) {
 		return authConfig, err
 	}
 
+	token := accessToken.AccessToken
+	if c.impersonate != "" {
+		token, err = c.impersonateToken(ctx, token)
+		if err != nil {
+			return authConfig, err
+		}
+	}
+
 	authConfig = authn.AuthConfig{
 		Username: "oauth2accesstoken",
-		Password: accessToken.AccessToken,
+		Password: token,
 	}
 	return authConfig, nil
 }
@@ -0,0 +1,120 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// IAM_CREDENTIALS_URL is the default endpoint of the IAM Service Account
+// Credentials API, used to impersonate service accounts.
+const IAM_CREDENTIALS_URL = "https://iamcredentials.googleapis.com"
+
+// cloudPlatformScope is the OAuth scope requested for impersonated tokens.
+const cloudPlatformScope = "https://www.googleapis.com/auth/cloud-platform"
+
+// WithImpersonation configures the client to impersonate the given service
+// account when logging in, with the token obtained from the metadata API.
+// The delegates, if any, are the service accounts of the impersonation
+// chain between the identity of the client and the target service account,
+// in order. Each service account of the chain must be granted the Service
+// Account Token Creator role on the next one.
+func (c *Client) WithImpersonation(serviceAccount string, delegates ...string) *Client {
+	c.impersonate = serviceAccount
+	c.delegates = delegates
+	return c
+}
+
+// WithIAMCredentialsURL sets the endpoint of the IAM Service Account
+// Credentials API used to impersonate service accounts.
+func (c *Client) WithIAMCredentialsURL(url string) *Client {
+	c.iamCredentialsURL = url
+	return c
+}
+
+type generateAccessTokenRequest struct {
+	Delegates []string `json:"delegates,omitempty"`
+	Scope     []string `json:"scope"`
+}
+
+type generateAccessTokenResponse struct {
+	AccessToken string `json:"accessToken"`
+	ExpireTime  string `json:"expireTime"`
+}
+
+// impersonateToken exchanges the given access token for an access token of
+// the service account to impersonate, using the IAM Service Account
+// Credentials generateAccessToken method.
+func (c *Client) impersonateToken(ctx context.Context, token string) (string, error) {
+	delegates := make([]string, 0, len(c.delegates))
+	for _, d := range c.delegates {
+		delegates = append(delegates, serviceAccountResource(d))
+	}
+	body, err := json.Marshal(generateAccessTokenRequest{
+		Delegates: delegates,
+		Scope:     []string{cloudPlatformScope},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	endpoint := fmt.Sprintf("%s/v1/%s:generateAccessToken",
+		strings.TrimSuffix(c.iamCredentialsURL, "/"), serviceAccountResource(c.impersonate))
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("Authorization", "Bearer "+token)
+
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+	defer io.Copy(io.Discard, response.Body)
+
+	if response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status from IAM credentials service while impersonating '%s': %s",
+			c.impersonate, response.Status)
+	}
+
+	var accessToken generateAccessTokenResponse
+	if err := json.NewDecoder(response.Body).Decode(&accessToken); err != nil {
+		return "", err
+	}
+	if accessToken.AccessToken == "" {
+		return "", fmt.Errorf("no access token returned while impersonating '%s'", c.impersonate)
+	}
+	return accessToken.AccessToken, nil
+}
+
+// serviceAccountResource returns the resource name of the given service
+// account email, for example: 'projects/-/serviceAccounts/sa@project.iam.gserviceaccount.com'.
+func serviceAccountResource(serviceAccount string) string {
+	if strings.HasPrefix(serviceAccount, "projects/") {
+		return serviceAccount
+	}
+	return "projects/-/serviceAccounts/" + url.PathEscape(serviceAccount)
+}
@@ -0,0 +1,73 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gcp
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestGetLoginAuth_Impersonation(t *testing.T) {
+	g := NewWithT(t)
+
+	metadata := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"access_token": "controller-token", "expires_in": 3600}`))
+	}))
+	t.Cleanup(metadata.Close)
+
+	var gotPath string
+	var gotReq generateAccessTokenRequest
+	iam := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer controller-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		gotPath = r.URL.Path
+		if err := json.NewDecoder(r.Body).Decode(&gotReq); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`{"accessToken": "tenant-token", "expireTime": "2030-01-01T00:00:00Z"}`))
+	}))
+	t.Cleanup(iam.Close)
+
+	gc := NewClient().
+		WithTokenURL(metadata.URL).
+		WithIAMCredentialsURL(iam.URL).
+		WithImpersonation("tenant@tenant-project.iam.gserviceaccount.com", "intermediate@platform.iam.gserviceaccount.com")
+
+	a, err := gc.getLoginAuth(context.TODO())
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(a.Username).To(Equal("oauth2accesstoken"))
+	g.Expect(a.Password).To(Equal("tenant-token"))
+	g.Expect(gotPath).To(Equal("/v1/projects/-/serviceAccounts/tenant@tenant-project.iam.gserviceaccount.com:generateAccessToken"))
+	g.Expect(gotReq.Delegates).To(Equal([]string{"projects/-/serviceAccounts/intermediate@platform.iam.gserviceaccount.com"}))
+	g.Expect(gotReq.Scope).To(Equal([]string{cloudPlatformScope}))
+
+	// Impersonation failures are returned.
+	_, err = NewClient().
+		WithTokenURL(metadata.URL).
+		WithIAMCredentialsURL(metadata.URL + "/missing").
+		WithImpersonation("tenant@tenant-project.iam.gserviceaccount.com").
+		getLoginAuth(context.TODO())
+	g.Expect(err).To(HaveOccurred())
+}
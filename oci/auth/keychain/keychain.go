@@ -0,0 +1,163 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keychain
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+// DefaultCacheTTL is the default duration for which resolved credentials
+// are cached.
+const DefaultCacheTTL = 5 * time.Minute
+
+// Source resolves registry credentials from a credentials file or a
+// credential helper.
+type Source interface {
+	// Get returns the credentials for the given resource, or false if
+	// the source has no credentials for it.
+	Get(target authn.Resource) (*authn.AuthConfig, bool, error)
+}
+
+// Keychain is an authn.Keychain resolving registry credentials from a list
+// of sources, e.g. the docker config or podman auth files mounted from the
+// node, or credential helper binaries. The sources are queried in order,
+// and the first one with credentials for a resource wins. The sources can be
+// overridden per registry host. Resolved credentials are cached for the
+// configured TTL to avoid re-reading files and executing helpers on every
+// request.
+type Keychain struct {
+	sources []Source
+	hosts   map[string][]Source
+	ttl     time.Duration
+
+	mu    sync.Mutex
+	cache map[string]cachedAuth
+}
+
+type cachedAuth struct {
+	config    *authn.AuthConfig
+	expiresAt time.Time
+}
+
+// Option configures a Keychain.
+type Option func(*Keychain)
+
+// WithSources sets the sources queried for all the registry hosts
+// without a host specific configuration.
+func WithSources(sources ...Source) Option {
+	return func(k *Keychain) {
+		k.sources = sources
+	}
+}
+
+// WithHostSources sets the sources queried for the given registry host,
+// instead of the ones set with WithSources.
+func WithHostSources(host string, sources ...Source) Option {
+	return func(k *Keychain) {
+		k.hosts[normalizeHost(host)] = sources
+	}
+}
+
+// WithCacheTTL sets the duration for which resolved credentials are cached.
+// A zero TTL disables caching.
+func WithCacheTTL(ttl time.Duration) Option {
+	return func(k *Keychain) {
+		k.ttl = ttl
+	}
+}
+
+// New returns a Keychain configured with the given options. Without any
+// sources, it resolves all resources to authn.Anonymous.
+func New(opts ...Option) *Keychain {
+	k := &Keychain{
+		hosts: make(map[string][]Source),
+		ttl:   DefaultCacheTTL,
+		cache: make(map[string]cachedAuth),
+	}
+	for _, opt := range opts {
+		opt(k)
+	}
+	return k
+}
+
+// Resolve implements authn.Keychain. The sources are queried without
+// holding the cache lock, so that a slow credential helper does not block
+// the resolution of other resources.
+func (k *Keychain) Resolve(target authn.Resource) (authn.Authenticator, error) {
+	key := target.String()
+
+	k.mu.Lock()
+	cached, ok := k.cache[key]
+	k.mu.Unlock()
+	if ok && time.Now().Before(cached.expiresAt) {
+		return authenticator(cached.config), nil
+	}
+
+	sources, ok := k.hosts[normalizeHost(target.RegistryStr())]
+	if !ok {
+		sources = k.sources
+	}
+
+	var config *authn.AuthConfig
+	for _, source := range sources {
+		c, found, err := source.Get(target)
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			config = c
+			break
+		}
+	}
+
+	if k.ttl > 0 {
+		k.mu.Lock()
+		k.cache[key] = cachedAuth{config: config, expiresAt: time.Now().Add(k.ttl)}
+		k.mu.Unlock()
+	}
+	return authenticator(config), nil
+}
+
+// Invalidate removes all the cached credentials.
+func (k *Keychain) Invalidate() {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.cache = make(map[string]cachedAuth)
+}
+
+func authenticator(config *authn.AuthConfig) authn.Authenticator {
+	if config == nil {
+		return authn.Anonymous
+	}
+	return authn.FromConfig(*config)
+}
+
+// normalizeHost returns the registry host of a credentials file key,
+// which may include a scheme and a path, with the Docker Hub aliases
+// mapped to name.DefaultRegistry.
+func normalizeHost(key string) string {
+	host, _ := splitKey(key)
+	switch host {
+	case "docker.io", "registry-1.docker.io":
+		return name.DefaultRegistry
+	}
+	return host
+}
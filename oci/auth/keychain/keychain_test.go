@@ -0,0 +1,202 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keychain
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	. "github.com/onsi/gomega"
+)
+
+func TestKeychain_DockerConfig(t *testing.T) {
+	g := NewWithT(t)
+
+	file := writeFile(t, "config.json", `{"auths": {
+		"https://index.docker.io/v1/": {"auth": "`+encode("hub", "hub-pass")+`"},
+		"ghcr.io": {"username": "gh", "password": "gh-pass"},
+		"empty.io": {}
+	}}`)
+	kc := New(WithSources(DockerConfig(file)))
+
+	g.Expect(resolve(t, kc, "nginx")).To(Equal(authn.AuthConfig{Username: "hub", Password: "hub-pass"}))
+	g.Expect(resolve(t, kc, "ghcr.io/org/repo")).To(Equal(authn.AuthConfig{Username: "gh", Password: "gh-pass"}))
+	g.Expect(resolve(t, kc, "empty.io/repo")).To(Equal(authn.AuthConfig{}))
+	g.Expect(resolve(t, kc, "quay.io/repo")).To(Equal(authn.AuthConfig{}))
+}
+
+func TestKeychain_PodmanAuth(t *testing.T) {
+	g := NewWithT(t)
+
+	file := writeFile(t, "auth.json", `{"auths": {
+		"quay.io": {"auth": "`+encode("all", "all-pass")+`"},
+		"quay.io/org": {"auth": "`+encode("org", "org-pass")+`"},
+		"docker.io/library": {"auth": "`+encode("library", "library-pass")+`"}
+	}}`)
+	kc := New(WithSources(PodmanAuth(file)))
+
+	g.Expect(resolve(t, kc, "quay.io/org/app")).To(Equal(authn.AuthConfig{Username: "org", Password: "org-pass"}))
+	g.Expect(resolve(t, kc, "quay.io/other/app")).To(Equal(authn.AuthConfig{Username: "all", Password: "all-pass"}))
+	g.Expect(resolve(t, kc, "nginx")).To(Equal(authn.AuthConfig{Username: "library", Password: "library-pass"}))
+}
+
+func TestKeychain_CredentialHelper(t *testing.T) {
+	g := NewWithT(t)
+
+	bin := t.TempDir()
+	script := `#!/bin/sh
+read server
+case "$server" in
+  registry.example.com) echo '{"ServerURL":"registry.example.com","Username":"helper","Secret":"helper-pass"}' ;;
+  token.example.com) echo '{"ServerURL":"token.example.com","Username":"<token>","Secret":"identity"}' ;;
+  *) echo "credentials not found in native keychain"; exit 1 ;;
+esac
+`
+	g.Expect(os.WriteFile(filepath.Join(bin, "docker-credential-test"), []byte(script), 0o755)).To(Succeed())
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	file := writeFile(t, "config.json", `{"credHelpers": {"registry.example.com": "test", "token.example.com": "test", "other.example.com": "test"}}`)
+	kc := New(WithSources(DockerConfig(file)))
+
+	g.Expect(resolve(t, kc, "registry.example.com/app")).To(Equal(authn.AuthConfig{Username: "helper", Password: "helper-pass"}))
+	g.Expect(resolve(t, kc, "token.example.com/app")).To(Equal(authn.AuthConfig{IdentityToken: "identity"}))
+	g.Expect(resolve(t, kc, "other.example.com/app")).To(Equal(authn.AuthConfig{}))
+
+	_, err := New(WithSources(CredentialHelper("missing"))).Resolve(mustRepository(t, "ghcr.io/app"))
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestKeychain_CredentialHelperTimeout(t *testing.T) {
+	g := NewWithT(t)
+
+	bin := t.TempDir()
+	g.Expect(os.WriteFile(filepath.Join(bin, "docker-credential-slow"), []byte("#!/bin/sh\nsleep 10\n"), 0o755)).To(Succeed())
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	kc := New(WithSources(&helperSource{name: "slow", timeout: 100 * time.Millisecond}))
+	start := time.Now()
+	_, err := kc.Resolve(mustRepository(t, "ghcr.io/app"))
+	g.Expect(err).To(MatchError(ContainSubstring("timed out")))
+	g.Expect(time.Since(start)).To(BeNumerically("<", 5*time.Second))
+}
+
+func TestKeychain_ConcurrentResolve(t *testing.T) {
+	g := NewWithT(t)
+
+	file := writeFile(t, "config.json", `{"auths": {"ghcr.io": {"auth": "`+encode("user", "pass")+`"}}}`)
+	blocked := &blockingSource{release: make(chan struct{}), started: make(chan struct{})}
+	kc := New(
+		WithSources(DockerConfig(file)),
+		WithHostSources("slow.example.com", blocked),
+	)
+
+	done := make(chan error)
+	go func() {
+		_, err := kc.Resolve(mustRepository(t, "slow.example.com/app"))
+		done <- err
+	}()
+	<-blocked.started
+
+	// Other resources are resolved while a source is blocked.
+	g.Expect(resolve(t, kc, "ghcr.io/app")).To(Equal(authn.AuthConfig{Username: "user", Password: "pass"}))
+
+	close(blocked.release)
+	g.Expect(<-done).To(Succeed())
+}
+
+func TestKeychain_HostSources(t *testing.T) {
+	g := NewWithT(t)
+
+	defaults := writeFile(t, "default.json", `{"auths": {"ghcr.io": {"auth": "`+encode("default", "pass")+`"}}}`)
+	override := writeFile(t, "override.json", `{"auths": {"ghcr.io": {"auth": "`+encode("override", "pass")+`"}}}`)
+	kc := New(
+		WithSources(DockerConfig(defaults)),
+		WithHostSources("ghcr.io", DockerConfig(override)),
+	)
+
+	g.Expect(resolve(t, kc, "ghcr.io/app")).To(Equal(authn.AuthConfig{Username: "override", Password: "pass"}))
+}
+
+func TestKeychain_Cache(t *testing.T) {
+	g := NewWithT(t)
+
+	file := writeFile(t, "config.json", `{"auths": {"ghcr.io": {"auth": "`+encode("first", "pass")+`"}}}`)
+	kc := New(WithSources(DockerConfig(file)))
+	g.Expect(resolve(t, kc, "ghcr.io/app")).To(Equal(authn.AuthConfig{Username: "first", Password: "pass"}))
+
+	g.Expect(os.WriteFile(file, []byte(`{"auths": {"ghcr.io": {"auth": "`+encode("second", "pass")+`"}}}`), 0o600)).To(Succeed())
+	g.Expect(resolve(t, kc, "ghcr.io/app")).To(Equal(authn.AuthConfig{Username: "first", Password: "pass"}))
+
+	kc.Invalidate()
+	g.Expect(resolve(t, kc, "ghcr.io/app")).To(Equal(authn.AuthConfig{Username: "second", Password: "pass"}))
+
+	uncached := New(WithSources(DockerConfig(file)), WithCacheTTL(0))
+	g.Expect(resolve(t, uncached, "ghcr.io/app")).To(Equal(authn.AuthConfig{Username: "second", Password: "pass"}))
+	g.Expect(uncached.cache).To(BeEmpty())
+}
+
+// blockingSource is a Source blocking until it is released.
+type blockingSource struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (s *blockingSource) Get(_ authn.Resource) (*authn.AuthConfig, bool, error) {
+	close(s.started)
+	<-s.release
+	return nil, false, nil
+}
+
+func resolve(t *testing.T, kc *Keychain, repo string) authn.AuthConfig {
+	t.Helper()
+	auth, err := kc.Resolve(mustRepository(t, repo))
+	if err != nil {
+		t.Fatal(err)
+	}
+	config, err := auth.Authorization()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return *config
+}
+
+func mustRepository(t *testing.T, repo string) name.Repository {
+	t.Helper()
+	r, err := name.NewRepository(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(file, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func encode(username, password string) string {
+	return base64.StdEncoding.EncodeToString([]byte(username + ":" + password))
+}
@@ -0,0 +1,277 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keychain
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+// DefaultHelperTimeout is the maximum duration of the execution of a
+// credential helper.
+const DefaultHelperTimeout = 30 * time.Second
+
+// credentialsNotFound is the message returned by the credential helpers
+// when they have no credentials for a registry.
+const credentialsNotFound = "credentials not found"
+
+// DockerConfig returns a Source reading the credentials from a docker config
+// JSON file, e.g. a mounted kubernetes.io/dockerconfigjson secret or the
+// kubelet config. The credHelpers and credsStore entries of the file are
+// resolved by executing the corresponding credential helpers. A missing file
+// has no credentials.
+func DockerConfig(path string) Source {
+	return &fileSource{path: path}
+}
+
+// PodmanAuth returns a Source reading the credentials from a containers
+// auth.json file as used by podman, where the entries can be scoped to a
+// repository namespace. The most specific entry matching a resource wins.
+// A missing file has no credentials.
+func PodmanAuth(path string) Source {
+	return &fileSource{path: path, scoped: true}
+}
+
+// CredentialHelper returns a Source executing the docker-credential-<name>
+// binary, e.g. 'ecr-login' for the Amazon ECR credential helper. The binary
+// is looked up in the PATH, and is killed if it does not complete within
+// DefaultHelperTimeout.
+func CredentialHelper(name string) Source {
+	return &helperSource{name: name, timeout: DefaultHelperTimeout}
+}
+
+// DefaultSources returns the sources of the docker config and podman auth
+// files at their default locations, honoring the DOCKER_CONFIG and
+// REGISTRY_AUTH_FILE environment variables.
+func DefaultSources() []Source {
+	var sources []Source
+
+	dir := os.Getenv("DOCKER_CONFIG")
+	if dir == "" {
+		if home, err := os.UserHomeDir(); err == nil {
+			dir = filepath.Join(home, ".docker")
+		}
+	}
+	if dir != "" {
+		sources = append(sources, DockerConfig(filepath.Join(dir, "config.json")))
+	}
+
+	if file := os.Getenv("REGISTRY_AUTH_FILE"); file != "" {
+		sources = append(sources, PodmanAuth(file))
+	} else if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		sources = append(sources, PodmanAuth(filepath.Join(dir, "containers", "auth.json")))
+	}
+
+	return sources
+}
+
+// configFile is the format shared by the docker config and containers
+// auth.json files.
+type configFile struct {
+	Auths       map[string]authEntry `json:"auths"`
+	CredHelpers map[string]string    `json:"credHelpers,omitempty"`
+	CredsStore  string               `json:"credsStore,omitempty"`
+}
+
+type authEntry struct {
+	Auth          string `json:"auth,omitempty"`
+	Username      string `json:"username,omitempty"`
+	Password      string `json:"password,omitempty"`
+	IdentityToken string `json:"identitytoken,omitempty"`
+	RegistryToken string `json:"registrytoken,omitempty"`
+}
+
+type fileSource struct {
+	path string
+	// scoped enables the matching of entries scoped to a repository namespace.
+	scoped bool
+}
+
+// Get implements Source.
+func (s *fileSource) Get(target authn.Resource) (*authn.AuthConfig, bool, error) {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, false, nil
+		}
+		return nil, false, fmt.Errorf("failed to read credentials file '%s': %w", s.path, err)
+	}
+
+	var cfg configFile
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, false, fmt.Errorf("failed to parse credentials file '%s': %w", s.path, err)
+	}
+
+	host := normalizeHost(target.RegistryStr())
+	for _, key := range sortedKeys(cfg.CredHelpers) {
+		if normalizeHost(key) == host {
+			return CredentialHelper(cfg.CredHelpers[key]).Get(target)
+		}
+	}
+	if cfg.CredsStore != "" {
+		return CredentialHelper(cfg.CredsStore).Get(target)
+	}
+
+	entries := make(map[string]authEntry, len(cfg.Auths))
+	for _, key := range sortedKeys(cfg.Auths) {
+		k := normalizeHost(key)
+		// Keys with a scheme are registry URLs, e.g. the Docker Hub key,
+		// their path is not a repository namespace.
+		if _, p := splitKey(key); s.scoped && p != "" && !strings.Contains(key, "://") {
+			k = path.Join(k, p)
+		}
+		if _, ok := entries[k]; !ok {
+			entries[k] = cfg.Auths[key]
+		}
+	}
+
+	for _, k := range s.candidates(target) {
+		entry, ok := entries[k]
+		if !ok {
+			continue
+		}
+		config, err := entry.config()
+		if err != nil {
+			return nil, false, fmt.Errorf("invalid credentials for '%s' in '%s': %w", k, s.path, err)
+		}
+		return config, config != nil, nil
+	}
+	return nil, false, nil
+}
+
+// candidates returns the keys matching the given resource, from the most
+// to the least specific.
+func (s *fileSource) candidates(target authn.Resource) []string {
+	host := normalizeHost(target.RegistryStr())
+	if !s.scoped {
+		return []string{host}
+	}
+
+	var keys []string
+	repo := strings.TrimPrefix(target.String(), target.RegistryStr())
+	for p := strings.Trim(repo, "/"); p != "" && p != "."; p = path.Dir(p) {
+		keys = append(keys, path.Join(host, p))
+	}
+	return append(keys, host)
+}
+
+// config returns the auth config of the entry, or nil for empty entries.
+func (e authEntry) config() (*authn.AuthConfig, error) {
+	config := &authn.AuthConfig{
+		Username:      e.Username,
+		Password:      e.Password,
+		IdentityToken: e.IdentityToken,
+		RegistryToken: e.RegistryToken,
+	}
+	if e.Auth != "" {
+		decoded, err := base64.StdEncoding.DecodeString(e.Auth)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode auth: %w", err)
+		}
+		username, password, ok := strings.Cut(string(decoded), ":")
+		if !ok {
+			return nil, errors.New("auth must be in the format 'username:password'")
+		}
+		config.Username, config.Password = username, password
+	}
+	if *config == (authn.AuthConfig{}) {
+		return nil, nil
+	}
+	return config, nil
+}
+
+type helperSource struct {
+	name    string
+	timeout time.Duration
+}
+
+// Get implements Source.
+func (s *helperSource) Get(target authn.Resource) (*authn.AuthConfig, bool, error) {
+	server := target.RegistryStr()
+	if normalizeHost(server) == name.DefaultRegistry {
+		server = authn.DefaultAuthKey
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "docker-credential-"+s.name, "get")
+	cmd.Stdin = strings.NewReader(server)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	// Do not wait for the output of the processes started by the helper
+	// once it has been killed.
+	cmd.WaitDelay = time.Second
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return nil, false, fmt.Errorf("credential helper '%s' timed out after %s", s.name, s.timeout)
+		}
+		output := stdout.String() + stderr.String()
+		if strings.Contains(output, credentialsNotFound) {
+			return nil, false, nil
+		}
+		return nil, false, fmt.Errorf("credential helper '%s' failed: %w: %s", s.name, err, strings.TrimSpace(output))
+	}
+
+	var resp struct {
+		Username string `json:"Username"`
+		Secret   string `json:"Secret"`
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &resp); err != nil {
+		return nil, false, fmt.Errorf("failed to parse credential helper '%s' output: %w", s.name, err)
+	}
+
+	// A '<token>' username denotes an identity token.
+	if resp.Username == "<token>" {
+		return &authn.AuthConfig{IdentityToken: resp.Secret}, true, nil
+	}
+	return &authn.AuthConfig{Username: resp.Username, Password: resp.Secret}, true, nil
+}
+
+// splitKey returns the host and the path of a credentials file key,
+// stripped of the scheme and of the surrounding slashes.
+func splitKey(key string) (string, string) {
+	key = strings.TrimPrefix(key, "https://")
+	key = strings.TrimPrefix(key, "http://")
+	host, p, _ := strings.Cut(key, "/")
+	return host, strings.Trim(p, "/")
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,233 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// GHCRHost is the host of the GitHub Container Registry.
+	GHCRHost = "ghcr.io"
+
+	// DefaultGHCRUsername is the username used with the tokens obtained
+	// for GHCR, which accepts a token as password with any username.
+	DefaultGHCRUsername = "flux"
+
+	tokenExchangeGrantType = "urn:ietf:params:oauth:grant-type:token-exchange"
+	jwtTokenType           = "urn:ietf:params:oauth:token-type:jwt"
+	accessTokenType        = "urn:ietf:params:oauth:token-type:access_token"
+)
+
+// IDTokenSource returns an OIDC ID token identifying the workload, for
+// example a projected Kubernetes service account token.
+type IDTokenSource func(ctx context.Context) (string, error)
+
+// IDTokenFromFile returns an IDTokenSource which reads the token from the
+// given file on each call, as projected tokens are rotated on disk.
+func IDTokenFromFile(path string) IDTokenSource {
+	return func(context.Context) (string, error) {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("reading ID token failed: %w", err)
+		}
+		return strings.TrimSpace(string(b)), nil
+	}
+}
+
+// IDTokenFromServiceAccount returns an IDTokenSource which requests a token
+// for the given Kubernetes service account with the TokenRequest API on
+// each call, for the given audiences. This allows using the identity of the
+// service account of an object instead of the one of the controller.
+func IDTokenFromServiceAccount(c client.Client, namespace, name string, audiences ...string) IDTokenSource {
+	return func(ctx context.Context) (string, error) {
+		sa := &corev1.ServiceAccount{}
+		sa.Namespace = namespace
+		sa.Name = name
+		tr := &authenticationv1.TokenRequest{
+			Spec: authenticationv1.TokenRequestSpec{
+				Audiences: audiences,
+			},
+		}
+		if err := c.SubResource("token").Create(ctx, sa, tr); err != nil {
+			return "", fmt.Errorf("requesting token for service account '%s/%s' failed: %w", namespace, name, err)
+		}
+		return tr.Status.Token, nil
+	}
+}
+
+// OIDCProvider obtains registry tokens by exchanging the ID token of the
+// workload at an OAuth 2.0 token exchange (RFC 8693) endpoint, for
+// registries which federate with an OIDC identity provider.
+type OIDCProvider struct {
+	tokenURL   string
+	idToken    IDTokenSource
+	hosts      map[string]struct{}
+	audience   string
+	scope      string
+	username   string
+	httpClient *http.Client
+}
+
+// NewOIDCProvider returns an OIDCProvider for the given registry hosts,
+// which exchanges the ID tokens returned by idToken at tokenURL.
+func NewOIDCProvider(tokenURL string, idToken IDTokenSource, hosts ...string) *OIDCProvider {
+	p := &OIDCProvider{
+		tokenURL:   tokenURL,
+		idToken:    idToken,
+		hosts:      make(map[string]struct{}, len(hosts)),
+		httpClient: http.DefaultClient,
+	}
+	for _, h := range hosts {
+		p.hosts[h] = struct{}{}
+	}
+	return p
+}
+
+// WithAudience sets the audience requested for the registry token.
+func (p *OIDCProvider) WithAudience(audience string) *OIDCProvider {
+	p.audience = audience
+	return p
+}
+
+// WithScope sets the scope requested for the registry token.
+func (p *OIDCProvider) WithScope(scope string) *OIDCProvider {
+	p.scope = scope
+	return p
+}
+
+// WithUsername makes the provider authenticate with the registry token as
+// the password of the given username, for registries which expect their
+// tokens with basic authentication, like Harbor robot accounts and GHCR.
+// By default, the registry token is used as a bearer token.
+func (p *OIDCProvider) WithUsername(username string) *OIDCProvider {
+	p.username = username
+	return p
+}
+
+// WithHTTPClient sets the HTTP client used to call the token endpoint.
+func (p *OIDCProvider) WithHTTPClient(c *http.Client) *OIDCProvider {
+	p.httpClient = c
+	return p
+}
+
+// Name implements ArtifactRegistryCredentialsProvider.
+func (p *OIDCProvider) Name() string {
+	return "oidc"
+}
+
+// Supports implements ArtifactRegistryCredentialsProvider.
+func (p *OIDCProvider) Supports(registry string) bool {
+	_, ok := p.hosts[registry]
+	return ok
+}
+
+// Credentials implements ArtifactRegistryCredentialsProvider.
+func (p *OIDCProvider) Credentials(ctx context.Context, _ string) (*Credentials, error) {
+	idToken, err := p.idToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type":           {tokenExchangeGrantType},
+		"subject_token":        {idToken},
+		"subject_token_type":   {jwtTokenType},
+		"requested_token_type": {accessTokenType},
+	}
+	if p.audience != "" {
+		form.Set("audience", p.audience)
+	}
+	if p.scope != "" {
+		form.Set("scope", p.scope)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	now := time.Now()
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("token exchange failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("token exchange failed: unexpected status: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return nil, fmt.Errorf("decoding token exchange response failed: %w", err)
+	}
+	if token.AccessToken == "" {
+		return nil, fmt.Errorf("token exchange response does not contain an access token")
+	}
+
+	creds := &Credentials{
+		Authenticator: authn.FromConfig(authn.AuthConfig{RegistryToken: token.AccessToken}),
+	}
+	if p.username != "" {
+		creds.Authenticator = &authn.Basic{Username: p.username, Password: token.AccessToken}
+	}
+	if token.ExpiresIn > 0 {
+		creds.ExpiresAt = now.Add(time.Duration(token.ExpiresIn) * time.Second)
+	}
+	return creds, nil
+}
+
+// NewHarborProvider returns an OIDCProvider for the Harbor registry at the
+// given host, which exchanges the ID tokens returned by idToken at tokenURL
+// for the secret of the given robot account, e.g. 'robot$project+flux'.
+// The token endpoint is the one of the identity provider or token broker
+// Harbor federates with.
+func NewHarborProvider(host, tokenURL, robotAccount string, idToken IDTokenSource) *OIDCProvider {
+	return NewOIDCProvider(tokenURL, idToken, host).
+		WithAudience(host).
+		WithUsername(robotAccount)
+}
+
+// NewGHCRProvider returns an OIDCProvider for the GitHub Container
+// Registry, which exchanges the ID tokens returned by idToken at tokenURL
+// for a GitHub token with access to the packages. The token endpoint is the
+// one of a token broker federating with GitHub, as GitHub does not exchange
+// third party ID tokens itself.
+func NewGHCRProvider(tokenURL string, idToken IDTokenSource) *OIDCProvider {
+	return NewOIDCProvider(tokenURL, idToken, GHCRHost).
+		WithAudience(GHCRHost).
+		WithUsername(DefaultGHCRUsername)
+}
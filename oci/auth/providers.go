@@ -0,0 +1,140 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package auth
+
+import (
+	"context"
+	"time"
+
+	"github.com/fluxcd/pkg/oci/auth/aws"
+	"github.com/fluxcd/pkg/oci/auth/azure"
+	"github.com/fluxcd/pkg/oci/auth/gcp"
+)
+
+// Lifetimes of the credentials issued by the cloud providers. The clients of
+// the cloud providers do not report the expiry of the credentials they
+// obtain, which are therefore assumed to be valid for the documented
+// lifetime from the time they were obtained.
+const (
+	// ECRTokenLifetime is the lifetime of an ECR authorization token.
+	ECRTokenLifetime = 12 * time.Hour
+	// GCPTokenLifetime is the lifetime of a GCP metadata server access token.
+	GCPTokenLifetime = time.Hour
+	// ACRTokenLifetime is the lifetime of an ACR refresh token.
+	ACRTokenLifetime = 3 * time.Hour
+)
+
+// AWSProvider obtains credentials for AWS Elastic Container Registry.
+type AWSProvider struct {
+	client *aws.Client
+}
+
+// NewAWSProvider returns an AWSProvider using the given ECR client.
+func NewAWSProvider(client *aws.Client) *AWSProvider {
+	return &AWSProvider{client: client}
+}
+
+// Name implements ArtifactRegistryCredentialsProvider.
+func (p *AWSProvider) Name() string {
+	return "aws"
+}
+
+// Supports implements ArtifactRegistryCredentialsProvider.
+func (p *AWSProvider) Supports(registry string) bool {
+	_, _, ok := aws.ParseRegistry(registry)
+	return ok
+}
+
+// Credentials implements ArtifactRegistryCredentialsProvider.
+func (p *AWSProvider) Credentials(ctx context.Context, registry string) (*Credentials, error) {
+	auth, err := p.client.OIDCLogin(ctx, registry)
+	if err != nil {
+		return nil, err
+	}
+	return &Credentials{Authenticator: auth, ExpiresAt: time.Now().Add(ECRTokenLifetime)}, nil
+}
+
+// GCPProvider obtains credentials for Google Artifact Registry and
+// Container Registry.
+type GCPProvider struct {
+	client *gcp.Client
+}
+
+// NewGCPProvider returns a GCPProvider using the given GCR client.
+func NewGCPProvider(client *gcp.Client) *GCPProvider {
+	return &GCPProvider{client: client}
+}
+
+// Name implements ArtifactRegistryCredentialsProvider.
+func (p *GCPProvider) Name() string {
+	return "gcp"
+}
+
+// Supports implements ArtifactRegistryCredentialsProvider.
+func (p *GCPProvider) Supports(registry string) bool {
+	return gcp.ValidHost(registry)
+}
+
+// Credentials implements ArtifactRegistryCredentialsProvider.
+func (p *GCPProvider) Credentials(ctx context.Context, _ string) (*Credentials, error) {
+	auth, err := p.client.OIDCLogin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &Credentials{Authenticator: auth, ExpiresAt: time.Now().Add(GCPTokenLifetime)}, nil
+}
+
+// AzureProvider obtains credentials for Azure Container Registry.
+type AzureProvider struct {
+	client *azure.Client
+}
+
+// NewAzureProvider returns an AzureProvider using the given ACR client.
+func NewAzureProvider(client *azure.Client) *AzureProvider {
+	return &AzureProvider{client: client}
+}
+
+// Name implements ArtifactRegistryCredentialsProvider.
+func (p *AzureProvider) Name() string {
+	return "azure"
+}
+
+// Supports implements ArtifactRegistryCredentialsProvider.
+func (p *AzureProvider) Supports(registry string) bool {
+	return azure.ValidHost(registry)
+}
+
+// Credentials implements ArtifactRegistryCredentialsProvider.
+func (p *AzureProvider) Credentials(ctx context.Context, registry string) (*Credentials, error) {
+	auth, err := p.client.OIDCLogin(ctx, "https://"+registry)
+	if err != nil {
+		return nil, err
+	}
+	return &Credentials{Authenticator: auth, ExpiresAt: time.Now().Add(ACRTokenLifetime)}, nil
+}
+
+// NewDefaultChain returns a Chain with the AWS, GCP and Azure providers,
+// using the default clients of the cloud providers, followed by the given
+// providers.
+func NewDefaultChain(providers ...ArtifactRegistryCredentialsProvider) *Chain {
+	defaults := []ArtifactRegistryCredentialsProvider{
+		NewAWSProvider(aws.NewClient()),
+		NewGCPProvider(gcp.NewClient()),
+		NewAzureProvider(azure.NewClient()),
+	}
+	return NewChain(append(defaults, providers...)...)
+}
@@ -0,0 +1,332 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	gcrv1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// ErrLayerCorrupted is returned when the content of a cached layer does
+// not match its digest.
+var ErrLayerCorrupted = errors.New("cached layer content does not match its digest")
+
+// LayerCache stores the compressed content of artifact layers on disk,
+// keyed by their digest. When the total size of the cached layers exceeds
+// the configured maximum size, the least recently used layers are evicted.
+// The content of a layer is verified against its digest before it is
+// stored, and before it is read from the cache.
+type LayerCache struct {
+	dir     string
+	maxSize int64
+
+	mu      sync.Mutex
+	entries map[string]*cacheEntry
+	size    int64
+}
+
+type cacheEntry struct {
+	size       int64
+	lastAccess time.Time
+}
+
+// NewLayerCache returns a LayerCache storing layers in the given directory,
+// which is created if it does not exist. Layers already present in the
+// directory are loaded into the cache, with their modification time as
+// last access time. A maxSize of zero or less disables eviction.
+func NewLayerCache(dir string, maxSize int64) (*LayerCache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("creating cache dir failed: %w", err)
+	}
+
+	c := &LayerCache{
+		dir:     dir,
+		maxSize: maxSize,
+		entries: make(map[string]*cacheEntry),
+	}
+
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("reading cache dir failed: %w", err)
+	}
+	for _, f := range files {
+		if !f.Type().IsRegular() {
+			continue
+		}
+		// Remove leftovers of interrupted writes.
+		if strings.HasPrefix(f.Name(), ".tmp-") {
+			_ = os.Remove(filepath.Join(dir, f.Name()))
+			continue
+		}
+		digest, err := gcrv1.NewHash(strings.Replace(f.Name(), "-", ":", 1))
+		if err != nil {
+			continue
+		}
+		info, err := f.Info()
+		if err != nil {
+			continue
+		}
+		c.entries[digest.String()] = &cacheEntry{size: info.Size(), lastAccess: info.ModTime()}
+		c.size += info.Size()
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.evict(0); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Size returns the total size of the cached layers.
+func (c *LayerCache) Size() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.size
+}
+
+// Has returns true if the layer with the given digest is cached.
+func (c *LayerCache) Has(digest gcrv1.Hash) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, ok := c.entries[digest.String()]
+	return ok
+}
+
+// Get returns a reader for the content of the layer with the given digest,
+// and false if the layer is not cached. The content is verified against the
+// digest before it is returned, so that callers never consume unverified
+// bytes. Corrupted layers, e.g. truncated by an interrupted write, are
+// removed from the cache and reported as not cached.
+func (c *LayerCache) Get(digest gcrv1.Hash) (io.ReadCloser, bool, error) {
+	c.mu.Lock()
+	entry, ok := c.entries[digest.String()]
+	c.mu.Unlock()
+	if !ok {
+		return nil, false, nil
+	}
+
+	f, err := os.Open(c.path(digest))
+	if err != nil {
+		c.removeEntry(digest, entry)
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, false, nil
+		}
+		return nil, false, fmt.Errorf("opening cached layer failed: %w", err)
+	}
+
+	h, err := gcrv1.Hasher(digest.Algorithm)
+	if err != nil {
+		f.Close()
+		return nil, false, err
+	}
+	if _, err := io.Copy(h, f); err != nil {
+		f.Close()
+		return nil, false, fmt.Errorf("reading cached layer failed: %w", err)
+	}
+	if !matchesDigest(h, digest) {
+		f.Close()
+		return nil, false, c.removeEntry(digest, entry)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		f.Close()
+		return nil, false, fmt.Errorf("reading cached layer failed: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	entry.lastAccess = now
+	_ = os.Chtimes(c.path(digest), now, now)
+	return f, true, nil
+}
+
+// removeEntry removes the layer with the given digest, unless it has been
+// replaced in the meantime.
+func (c *LayerCache) removeEntry(digest gcrv1.Hash, entry *cacheEntry) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries[digest.String()] != entry {
+		return nil
+	}
+	return c.remove(digest)
+}
+
+// Put stores the content read from r as the layer with the given digest,
+// and evicts the least recently used layers if the maximum size of the
+// cache is exceeded. ErrLayerCorrupted is returned if the content does not
+// match the digest, in which case nothing is stored. Content larger than
+// the maximum size of the cache is discarded.
+func (c *LayerCache) Put(digest gcrv1.Hash, r io.Reader) error {
+	h, err := gcrv1.Hasher(digest.Algorithm)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(c.dir, ".tmp-")
+	if err != nil {
+		return fmt.Errorf("creating cache file failed: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	size, err := io.Copy(io.MultiWriter(tmp, h), r)
+	if err != nil {
+		tmp.Close()
+		return fmt.Errorf("writing cache file failed: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("writing cache file failed: %w", err)
+	}
+	if !matchesDigest(h, digest) {
+		return ErrLayerCorrupted
+	}
+
+	// Layers larger than the cache are not stored.
+	if c.maxSize > 0 && size > c.maxSize {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.evict(size); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), c.path(digest)); err != nil {
+		return fmt.Errorf("storing cache file failed: %w", err)
+	}
+	if old, ok := c.entries[digest.String()]; ok {
+		c.size -= old.size
+	}
+	c.entries[digest.String()] = &cacheEntry{size: size, lastAccess: time.Now()}
+	c.size += size
+	return nil
+}
+
+// Delete removes the layer with the given digest from the cache.
+func (c *LayerCache) Delete(digest gcrv1.Hash) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[digest.String()]; !ok {
+		return nil
+	}
+	return c.remove(digest)
+}
+
+// path returns the path of the cache file of the layer with the given digest.
+func (c *LayerCache) path(digest gcrv1.Hash) string {
+	return filepath.Join(c.dir, digest.Algorithm+"-"+digest.Hex)
+}
+
+// remove deletes the layer with the given digest from disk and from the
+// index. The caller must hold the lock.
+func (c *LayerCache) remove(digest gcrv1.Hash) error {
+	if entry, ok := c.entries[digest.String()]; ok {
+		c.size -= entry.size
+		delete(c.entries, digest.String())
+	}
+	if err := os.Remove(c.path(digest)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("removing cached layer failed: %w", err)
+	}
+	return nil
+}
+
+// evict removes the least recently used layers until the given number of
+// bytes can be added without exceeding the maximum size of the cache.
+// The caller must hold the lock.
+func (c *LayerCache) evict(incoming int64) error {
+	if c.maxSize <= 0 || c.size+incoming <= c.maxSize {
+		return nil
+	}
+
+	keys := make([]string, 0, len(c.entries))
+	for k := range c.entries {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return c.entries[keys[i]].lastAccess.Before(c.entries[keys[j]].lastAccess)
+	})
+
+	for _, k := range keys {
+		if c.size+incoming <= c.maxSize {
+			break
+		}
+		digest, err := gcrv1.NewHash(k)
+		if err != nil {
+			return err
+		}
+		if err := c.remove(digest); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func matchesDigest(h hash.Hash, digest gcrv1.Hash) bool {
+	return fmt.Sprintf("%x", h.Sum(nil)) == digest.Hex
+}
+
+// WithLayerCache configures the client to store the layers of the pulled
+// artifacts in the given cache, and to read them from it on subsequent
+// pulls instead of downloading them again.
+func (c *Client) WithLayerCache(cache *LayerCache) *Client {
+	c.cache = cache
+	return c
+}
+
+// openLayer returns a reader for the compressed content of the given layer,
+// served from the layer cache if the client has one configured.
+func (c *Client) openLayer(layer gcrv1.Layer, digest gcrv1.Hash) (io.ReadCloser, error) {
+	if c.cache == nil {
+		return layer.Compressed()
+	}
+
+	if rc, ok, err := c.cache.Get(digest); err != nil || ok {
+		return rc, err
+	}
+
+	blob, err := layer.Compressed()
+	if err != nil {
+		return nil, err
+	}
+	defer blob.Close()
+
+	if err := c.cache.Put(digest, blob); err != nil {
+		return nil, fmt.Errorf("caching layer failed: %w", err)
+	}
+
+	rc, ok, err := c.cache.Get(digest)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		// The layer is larger than the cache.
+		return layer.Compressed()
+	}
+	return rc, nil
+}
@@ -0,0 +1,168 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	gcrv1 "github.com/google/go-containerregistry/pkg/v1"
+	. "github.com/onsi/gomega"
+)
+
+func digestOf(t *testing.T, content string) gcrv1.Hash {
+	t.Helper()
+	h, _, err := gcrv1.SHA256(strings.NewReader(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+func TestLayerCache_PutGet(t *testing.T) {
+	g := NewWithT(t)
+	dir := t.TempDir()
+
+	cache, err := NewLayerCache(dir, 0)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	digest := digestOf(t, "layer")
+	_, ok, err := cache.Get(digest)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(ok).To(BeFalse())
+
+	g.Expect(cache.Put(digest, strings.NewReader("layer"))).To(Succeed())
+	g.Expect(cache.Has(digest)).To(BeTrue())
+	g.Expect(cache.Size()).To(BeEquivalentTo(len("layer")))
+
+	rc, ok, err := cache.Get(digest)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(ok).To(BeTrue())
+	b, err := io.ReadAll(rc)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(rc.Close()).To(Succeed())
+	g.Expect(string(b)).To(Equal("layer"))
+
+	// Content not matching the digest is not stored.
+	other := digestOf(t, "other")
+	g.Expect(cache.Put(other, strings.NewReader("layer"))).To(MatchError(ErrLayerCorrupted))
+	g.Expect(cache.Has(other)).To(BeFalse())
+
+	// Layers are loaded from disk.
+	cache, err = NewLayerCache(dir, 0)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(cache.Has(digest)).To(BeTrue())
+	g.Expect(cache.Size()).To(BeEquivalentTo(len("layer")))
+
+	g.Expect(cache.Delete(digest)).To(Succeed())
+	g.Expect(cache.Has(digest)).To(BeFalse())
+	g.Expect(cache.Size()).To(BeZero())
+}
+
+func TestLayerCache_Corrupted(t *testing.T) {
+	g := NewWithT(t)
+
+	cache, err := NewLayerCache(t.TempDir(), 0)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	digest := digestOf(t, "layer")
+	g.Expect(cache.Put(digest, strings.NewReader("layer"))).To(Succeed())
+	g.Expect(os.WriteFile(cache.path(digest), []byte("tampered"), 0o644)).To(Succeed())
+
+	_, ok, err := cache.Get(digest)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(ok).To(BeFalse())
+
+	g.Expect(cache.Has(digest)).To(BeFalse())
+	g.Expect(cache.path(digest)).ToNot(BeAnExistingFile())
+
+	// Truncated layers left on disk are not served.
+	dir := t.TempDir()
+	g.Expect(os.WriteFile(filepath.Join(dir, digest.Algorithm+"-"+digest.Hex), []byte("lay"), 0o644)).To(Succeed())
+	cache, err = NewLayerCache(dir, 0)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(cache.Has(digest)).To(BeTrue())
+
+	_, ok, err = cache.Get(digest)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(ok).To(BeFalse())
+	g.Expect(cache.Size()).To(BeZero())
+}
+
+func TestLayerCache_Eviction(t *testing.T) {
+	g := NewWithT(t)
+
+	cache, err := NewLayerCache(t.TempDir(), 10)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	a, b, c := digestOf(t, "aaaa"), digestOf(t, "bbbb"), digestOf(t, "cccc")
+	g.Expect(cache.Put(a, strings.NewReader("aaaa"))).To(Succeed())
+	g.Expect(cache.Put(b, strings.NewReader("bbbb"))).To(Succeed())
+
+	// Reading a layer marks it as recently used.
+	rc, ok, err := cache.Get(a)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(ok).To(BeTrue())
+	g.Expect(rc.Close()).To(Succeed())
+
+	// Adding a third layer exceeds the size of the cache.
+	g.Expect(cache.Put(c, strings.NewReader("cccc"))).To(Succeed())
+	g.Expect(cache.Has(a)).To(BeTrue())
+	g.Expect(cache.Has(b)).To(BeFalse())
+	g.Expect(cache.Has(c)).To(BeTrue())
+	g.Expect(cache.Size()).To(BeEquivalentTo(8))
+
+	// Layers larger than the cache are not stored.
+	large := bytes.Repeat([]byte("x"), 11)
+	largeDigest, _, err := gcrv1.SHA256(bytes.NewReader(large))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(cache.Put(largeDigest, bytes.NewReader(large))).To(Succeed())
+	g.Expect(cache.Has(largeDigest)).To(BeFalse())
+	g.Expect(cache.Size()).To(BeEquivalentTo(8))
+}
+
+func Test_PullWithLayerCache(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	repo := "test-cache" + randStringRunes(5)
+	url := fmt.Sprintf("%s/%s:v1", dockerReg, repo)
+
+	_, err := NewClient(DefaultOptions()).Push(ctx, url, "testdata/artifact")
+	g.Expect(err).ToNot(HaveOccurred())
+
+	cache, err := NewLayerCache(t.TempDir(), 0)
+	g.Expect(err).ToNot(HaveOccurred())
+	c := NewClient(DefaultOptions()).WithLayerCache(cache)
+
+	tmpDir := t.TempDir()
+	_, err = c.Pull(ctx, url, tmpDir)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(filepath.Join(tmpDir, "deployment.yaml")).To(BeARegularFile())
+	g.Expect(cache.Size()).To(BeNumerically(">", 0))
+
+	// The second pull is served from the cache.
+	tmpDir = t.TempDir()
+	_, err = c.Pull(ctx, url, tmpDir)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(filepath.Join(tmpDir, "deployment.yaml")).To(BeARegularFile())
+}
@@ -19,6 +19,7 @@ package client
 import (
 	"context"
 
+	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/crane"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
 
@@ -28,6 +29,12 @@ import (
 // Client holds the options for accessing remote OCI registries.
 type Client struct {
 	options []crane.Option
+	mirrors map[string][]Mirror
+	// auth is the authenticator configured with the login methods, used
+	// for the requests which are not performed through crane.
+	auth authn.Authenticator
+	// cache stores the layers of the pulled artifacts, if set.
+	cache *LayerCache
 }
 
 // NewClient returns an OCI client configured with the given crane options.
@@ -17,6 +17,7 @@ limitations under the License.
 package client
 
 import (
+	"bytes"
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
@@ -25,14 +26,22 @@ import (
 	"os"
 	"path/filepath"
 
-	"github.com/google/go-containerregistry/pkg/crane"
+	"filippo.io/age"
 	"github.com/google/go-containerregistry/pkg/name"
+	gcrv1 "github.com/google/go-containerregistry/pkg/v1"
 )
 
 // Diff compares the files included in an OCI image with the local files in the given path
-// and returns an error if the contents is different
-func (c *Client) Diff(ctx context.Context, url, dir string, ignorePaths []string) error {
-	_, err := name.ParseReference(url)
+// and returns an error if the contents is different. Encrypted artifacts are decrypted
+// with the identities configured with WithPullDecryption, and image indexes are resolved
+// with the platform configured with WithPullPlatform.
+func (c *Client) Diff(ctx context.Context, url, dir string, ignorePaths []string, opts ...PullOption) error {
+	o := &PullOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	ref, err := name.ParseReference(url)
 	if err != nil {
 		return fmt.Errorf("invalid URL: %w", err)
 	}
@@ -65,7 +74,7 @@ func (c *Client) Diff(ctx context.Context, url, dir string, ignorePaths []string
 		return fmt.Errorf("calculating artifact hash failed: %w", err)
 	}
 
-	img, err := crane.Pull(url, c.optionsWithContext(ctx)...)
+	img, _, err := c.pullImage(ctx, ref, o.platform)
 	if err != nil {
 		return err
 	}
@@ -81,6 +90,14 @@ func (c *Client) Diff(ctx context.Context, url, dir string, ignorePaths []string
 
 	l0 := layers[0]
 
+	manifest, err := img.Manifest()
+	if err != nil {
+		return fmt.Errorf("parsing manifest failed: %w", err)
+	}
+	if len(manifest.Layers) > 0 && isEncrypted(manifest.Layers[0]) {
+		return diffEncrypted(l0, manifest.Layers[0], o.identities, h1.Sum(nil), fstat.Size())
+	}
+
 	h, err := l0.Digest()
 	if err != nil {
 		return fmt.Errorf("failed to get layer digest: %w", err)
@@ -97,3 +114,29 @@ func (c *Client) Diff(ctx context.Context, url, dir string, ignorePaths []string
 
 	return nil
 }
+
+// diffEncrypted compares the decrypted content of the layer with the given
+// hash and size of the local artifact.
+func diffEncrypted(layer gcrv1.Layer, desc gcrv1.Descriptor, identities []age.Identity, sum []byte, size int64) error {
+	blob, err := layer.Compressed()
+	if err != nil {
+		return fmt.Errorf("extracting layer failed: %w", err)
+	}
+	defer blob.Close()
+
+	content, err := decryptLayer(blob, desc, identities)
+	if err != nil {
+		return err
+	}
+
+	h := sha256.New()
+	n, err := io.Copy(h, content)
+	if err != nil {
+		return fmt.Errorf("calculating artifact hash failed: %w", err)
+	}
+
+	if !bytes.Equal(h.Sum(nil), sum) || n != size {
+		return fmt.Errorf("the remote artifact contents differs from the local one")
+	}
+	return nil
+}
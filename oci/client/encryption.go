@@ -0,0 +1,192 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"filippo.io/age"
+	gcrv1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+
+	"github.com/fluxcd/pkg/oci"
+)
+
+// ErrDecryptionIdentityRequired is returned when pulling an encrypted layer
+// without any identities to decrypt it.
+var ErrDecryptionIdentityRequired = errors.New("layer is encrypted but no decryption identities were provided")
+
+// WithPushEncryption configures the content of the layer to be encrypted
+// with age before it is pushed, for the given recipients. The recipients
+// are age X25519 public keys (e.g. 'age1...'), which are recorded in the
+// annotations of the layer.
+func WithPushEncryption(recipients ...string) PushOption {
+	return func(o *PushOptions) {
+		o.recipients = append(o.recipients, recipients...)
+	}
+}
+
+// WithPullDecryption configures the identities used to decrypt the content
+// of a layer encrypted with age. Pulling an encrypted layer without
+// identities results in ErrDecryptionIdentityRequired.
+func WithPullDecryption(identities ...age.Identity) PullOption {
+	return func(o *PullOptions) {
+		o.identities = append(o.identities, identities...)
+	}
+}
+
+// encryptLayer returns a layer with the content of the given layer
+// encrypted for the recipients, and the annotations describing it.
+// The encrypted content is streamed to a file in dir, which must outlive
+// the returned layer. The media type of the layer is suffixed with
+// oci.EncryptedMediaTypeSuffix.
+func encryptLayer(layer gcrv1.Layer, recipients []string, dir string) (gcrv1.Layer, map[string]string, error) {
+	rs := make([]age.Recipient, 0, len(recipients))
+	keys := make([]string, 0, len(recipients))
+	for _, r := range recipients {
+		recipient, err := age.ParseX25519Recipient(strings.TrimSpace(r))
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid age recipient '%s': %w", r, err)
+		}
+		rs = append(rs, recipient)
+		keys = append(keys, recipient.String())
+	}
+
+	mediaType, err := layer.MediaType()
+	if err != nil {
+		return nil, nil, err
+	}
+	content, err := layer.Compressed()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer content.Close()
+
+	f, err := os.CreateTemp(dir, "layer-*.age")
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	// The ciphertext is randomized, compute the digest while writing it
+	// so that it matches the content which is uploaded.
+	h := sha256.New()
+	cw := &countingWriter{w: io.MultiWriter(f, h)}
+	w, err := age.Encrypt(cw, rs...)
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, err := io.Copy(w, content); err != nil {
+		return nil, nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, nil, err
+	}
+	if err := f.Close(); err != nil {
+		return nil, nil, err
+	}
+
+	annotations := map[string]string{
+		oci.EncryptionAnnotation:           oci.EncryptionAge,
+		oci.EncryptionRecipientsAnnotation: strings.Join(keys, ","),
+	}
+	return &fileLayer{
+		path: f.Name(),
+		digest: gcrv1.Hash{
+			Algorithm: "sha256",
+			Hex:       hex.EncodeToString(h.Sum(nil)),
+		},
+		size:      cw.n,
+		mediaType: mediaType + oci.EncryptedMediaTypeSuffix,
+	}, annotations, nil
+}
+
+// isEncrypted returns true if the layer described by desc is encrypted.
+func isEncrypted(desc gcrv1.Descriptor) bool {
+	return desc.Annotations[oci.EncryptionAnnotation] != ""
+}
+
+// decryptLayer returns a reader for the decrypted content of the layer
+// described by desc, or the content as is if the layer is not encrypted.
+func decryptLayer(content io.Reader, desc gcrv1.Descriptor, identities []age.Identity) (io.Reader, error) {
+	switch scheme := desc.Annotations[oci.EncryptionAnnotation]; scheme {
+	case "":
+		return content, nil
+	case oci.EncryptionAge:
+		if len(identities) == 0 {
+			return nil, ErrDecryptionIdentityRequired
+		}
+		r, err := age.Decrypt(content, identities...)
+		if err != nil {
+			return nil, fmt.Errorf("decrypting layer failed: %w", err)
+		}
+		return r, nil
+	default:
+		return nil, fmt.Errorf("unsupported layer encryption: '%s'", scheme)
+	}
+}
+
+// countingWriter counts the bytes written to the underlying writer.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// fileLayer is a layer whose content is stored as is in a file. Unlike
+// tarball.LayerFromFile, the content is never compressed.
+type fileLayer struct {
+	path      string
+	digest    gcrv1.Hash
+	size      int64
+	mediaType types.MediaType
+}
+
+func (l *fileLayer) Digest() (gcrv1.Hash, error) {
+	return l.digest, nil
+}
+
+func (l *fileLayer) DiffID() (gcrv1.Hash, error) {
+	return l.digest, nil
+}
+
+func (l *fileLayer) Compressed() (io.ReadCloser, error) {
+	return os.Open(l.path)
+}
+
+func (l *fileLayer) Uncompressed() (io.ReadCloser, error) {
+	return os.Open(l.path)
+}
+
+func (l *fileLayer) Size() (int64, error) {
+	return l.size, nil
+}
+
+func (l *fileLayer) MediaType() (types.MediaType, error) {
+	return l.mediaType, nil
+}
@@ -0,0 +1,123 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/name"
+	gcrv1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// resolvedIndex holds the result of resolving an image index to one of
+// its manifests.
+type resolvedIndex struct {
+	digest   gcrv1.Hash
+	platform *gcrv1.Platform
+}
+
+// fetchImage fetches the image at the given URL. If the URL points to an
+// image index, the index is resolved to the manifest which matches the
+// given platform, or to its only manifest if no platform is given.
+func fetchImage(url string, platform *gcrv1.Platform, options ...crane.Option) (gcrv1.Image, *resolvedIndex, error) {
+	o := crane.GetOptions(options...)
+	ref, err := name.ParseReference(url, o.Name...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("parsing reference %q: %w", url, err)
+	}
+
+	desc, err := remote.Get(ref, o.Remote...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !desc.MediaType.IsIndex() {
+		img, err := desc.Image()
+		return img, nil, err
+	}
+
+	idx, err := desc.ImageIndex()
+	if err != nil {
+		return nil, nil, err
+	}
+	indexManifest, err := idx.IndexManifest()
+	if err != nil {
+		return nil, nil, fmt.Errorf("parsing index manifest failed: %w", err)
+	}
+
+	child, err := selectManifest(indexManifest.Manifests, platform)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	img, err := idx.Image(child.Digest)
+	if err != nil {
+		return nil, nil, err
+	}
+	return img, &resolvedIndex{digest: desc.Digest, platform: child.Platform}, nil
+}
+
+// defaultPlatform is the platform used to resolve indexes with multiple
+// image manifests when no platform is given.
+var defaultPlatform = gcrv1.Platform{OS: "linux", Architecture: "amd64"}
+
+// selectManifest returns the first image manifest of an index which matches
+// the given platform. If no platform is given, the single image manifest of
+// the index is returned, or the one matching defaultPlatform if the index
+// contains multiple image manifests.
+func selectManifest(manifests []gcrv1.Descriptor, platform *gcrv1.Platform) (*gcrv1.Descriptor, error) {
+	var images []gcrv1.Descriptor
+	for _, m := range manifests {
+		if m.MediaType.IsImage() {
+			images = append(images, m)
+		}
+	}
+
+	if platform == nil && len(images) > 1 {
+		platform = &defaultPlatform
+	}
+
+	if platform != nil {
+		for i, m := range images {
+			if m.Platform != nil && m.Platform.Satisfies(*platform) {
+				return &images[i], nil
+			}
+		}
+		return nil, fmt.Errorf("no manifest found in index for platform '%s', available platforms: [%s]",
+			platform, platforms(images))
+	}
+
+	if len(images) == 0 {
+		return nil, fmt.Errorf("no manifests found in index")
+	}
+	return &images[0], nil
+}
+
+// platforms returns the platforms of the given descriptors as a comma
+// separated string.
+func platforms(descs []gcrv1.Descriptor) string {
+	var s []string
+	for _, d := range descs {
+		if d.Platform != nil {
+			s = append(s, d.Platform.String())
+		}
+	}
+	return strings.Join(s, ", ")
+}
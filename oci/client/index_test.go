@@ -0,0 +1,115 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/name"
+	gcrv1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	. "github.com/onsi/gomega"
+
+	"github.com/fluxcd/pkg/oci"
+)
+
+func Test_PullIndex(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	c := NewClient(DefaultOptions())
+
+	artifact := filepath.Join(t.TempDir(), "artifact.tgz")
+	g.Expect(build(artifact, "testdata/artifact", nil)).To(Succeed())
+
+	newImage := func(platform string) gcrv1.Image {
+		img := mutate.MediaType(empty.Image, types.OCIManifestSchema1)
+		img = mutate.ConfigMediaType(img, oci.CanonicalConfigMediaType)
+		img = mutate.Annotations(img, map[string]string{oci.RevisionAnnotation: platform}).(gcrv1.Image)
+		layer, err := tarball.LayerFromFile(artifact, tarball.WithMediaType(oci.CanonicalContentMediaType))
+		g.Expect(err).ToNot(HaveOccurred())
+		img, err = mutate.Append(img, mutate.Addendum{Layer: layer})
+		g.Expect(err).ToNot(HaveOccurred())
+		return img
+	}
+
+	pushIndex := func(dst string, platforms ...string) {
+		idx := mutate.IndexMediaType(empty.Index, types.OCIImageIndex)
+		for _, p := range platforms {
+			platform, err := gcrv1.ParsePlatform(p)
+			g.Expect(err).ToNot(HaveOccurred())
+			idx = mutate.AppendManifests(idx, mutate.IndexAddendum{
+				Add:        newImage(p),
+				Descriptor: gcrv1.Descriptor{Platform: platform},
+			})
+		}
+		ref, err := name.ParseReference(dst)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(remote.WriteIndex(ref, idx, crane.GetOptions(c.optionsWithContext(ctx)...).Remote...)).To(Succeed())
+	}
+
+	multiArch := fmt.Sprintf("%s/%s:v1", dockerReg, "test-index-multi"+randStringRunes(5))
+	pushIndex(multiArch, "linux/amd64", "linux/arm64")
+
+	t.Run("resolves index by platform", func(t *testing.T) {
+		g := NewWithT(t)
+
+		m, err := c.Pull(ctx, multiArch, t.TempDir(), WithPullPlatform(gcrv1.Platform{OS: "linux", Architecture: "arm64"}))
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(m.Platform).To(Equal("linux/arm64"))
+		g.Expect(m.Revision).To(Equal("linux/arm64"))
+		g.Expect(m.IndexDigest).ToNot(BeEmpty())
+		g.Expect(m.Digest).ToNot(Equal(m.IndexDigest))
+	})
+
+	t.Run("defaults to linux/amd64 without platform", func(t *testing.T) {
+		g := NewWithT(t)
+
+		m, err := c.Pull(ctx, multiArch, t.TempDir())
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(m.Platform).To(Equal("linux/amd64"))
+		g.Expect(m.Revision).To(Equal("linux/amd64"))
+	})
+
+	t.Run("fails for unknown platform", func(t *testing.T) {
+		g := NewWithT(t)
+
+		_, err := c.Pull(ctx, multiArch, t.TempDir(), WithPullPlatform(gcrv1.Platform{OS: "windows", Architecture: "amd64"}))
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("no manifest found in index for platform 'windows/amd64'"))
+	})
+
+	t.Run("resolves single manifest index", func(t *testing.T) {
+		g := NewWithT(t)
+
+		single := fmt.Sprintf("%s/%s:v1", dockerReg, "test-index-single"+randStringRunes(5))
+		pushIndex(single, "linux/amd64")
+
+		extractTo := filepath.Join(t.TempDir(), "artifact")
+		m, err := c.Pull(ctx, single, extractTo)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(m.Platform).To(Equal("linux/amd64"))
+		g.Expect(extractTo + "/deployment.yaml").To(BeAnExistingFile())
+	})
+}
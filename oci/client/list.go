@@ -26,7 +26,9 @@ import (
 
 	"github.com/Masterminds/semver/v3"
 	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/name"
 	gcrv1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
 
 	"github.com/fluxcd/pkg/version"
 )
@@ -52,41 +54,13 @@ func (c *Client) List(ctx context.Context, url string, opts ListOptions) ([]Meta
 
 	sort.Slice(tags, func(i, j int) bool { return tags[i] > tags[j] })
 
-	var constraint *semver.Constraints
-	if opts.SemverFilter != "" {
-		constraint, err = semver.NewConstraint(opts.SemverFilter)
-		if err != nil {
-			return nil, fmt.Errorf("semver '%s' parse error: %w", opts.SemverFilter, err)
-		}
-	}
-
-	var re *regexp.Regexp
-	if opts.RegexFilter != "" {
-		re, err = regexp.Compile(opts.RegexFilter)
-		if err != nil {
-			return nil, fmt.Errorf("regex '%s' parse error: %w", opts.RegexFilter, err)
-		}
+	filter, err := newTagFilter(opts.SemverFilter, opts.RegexFilter, opts.IncludeCosignArtifacts)
+	if err != nil {
+		return nil, err
 	}
 
 	for _, tag := range tags {
-		// ignore cosign artifacts by default
-		if !opts.IncludeCosignArtifacts && IsCosignArtifact(tag) {
-			continue
-		}
-
-		if constraint != nil {
-			v, err := version.ParseVersion(tag)
-			// version isn't a valid semver so we can skip
-			if err != nil {
-				continue
-			}
-
-			if !constraint.Check(v) {
-				continue
-			}
-		}
-
-		if re != nil && !re.Match([]byte(tag)) {
+		if _, ok := filter.match(tag); !ok {
 			continue
 		}
 
@@ -121,6 +95,164 @@ func (c *Client) List(ctx context.Context, url string, opts ListOptions) ([]Meta
 	return metas, nil
 }
 
+// ListTagsOptions contains options for listing the tags of an OCI repository
+// with ListTags.
+type ListTagsOptions struct {
+	// SemverFilter contains a semver range for filtering tags. When set,
+	// the tags are sorted by version in descending order.
+	SemverFilter string
+	// RegexFilter contains a regex that tags will be filtered by.
+	RegexFilter string
+	// IncludeCosignArtifacts can be used to include cosign attestation,
+	// signature and SBOM tags in the list, as these are excluded by default.
+	IncludeCosignArtifacts bool
+	// PageSize is the number of tags requested from the registry per page.
+	// Defaults to the page size of the underlying registry client.
+	PageSize int
+	// Limit is the maximum number of tags returned, after filtering and
+	// sorting. Zero means no limit.
+	Limit int
+}
+
+// Tag holds a tag of an OCI repository and the digest of the artifact it
+// references.
+type Tag struct {
+	// Name is the name of the tag.
+	Name string
+	// Digest is the digest of the artifact the tag references.
+	Digest string
+	// Version is the semver version parsed from the tag, set when the tags
+	// are filtered with a semver range.
+	Version *semver.Version
+}
+
+// ListTags fetches the tags of the given OCI repository page by page,
+// filters them according to the given options and returns them sorted,
+// with the digest of the artifact they reference resolved. Tags are sorted
+// by version in descending order when filtered by semver, and in reverse
+// alphabetical order otherwise.
+func (c *Client) ListTags(ctx context.Context, url string, opts ListTagsOptions) ([]Tag, error) {
+	repo, err := name.NewRepository(url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL: %w", err)
+	}
+
+	filter, err := newTagFilter(opts.SemverFilter, opts.RegexFilter, opts.IncludeCosignArtifacts)
+	if err != nil {
+		return nil, err
+	}
+
+	remoteOpts := crane.GetOptions(c.optionsWithContext(ctx)...).Remote
+	if opts.PageSize > 0 {
+		remoteOpts = append(remoteOpts, remote.WithPageSize(opts.PageSize))
+	}
+	puller, err := remote.NewPuller(remoteOpts...)
+	if err != nil {
+		return nil, err
+	}
+
+	lister, err := puller.Lister(ctx, repo)
+	if err != nil {
+		return nil, fmt.Errorf("listing tags failed: %w", err)
+	}
+
+	var tags []Tag
+	for lister.HasNext() {
+		page, err := lister.Next(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("listing tags failed: %w", err)
+		}
+		for _, t := range page.Tags {
+			if v, ok := filter.match(t); ok {
+				tags = append(tags, Tag{Name: t, Version: v})
+			}
+		}
+	}
+
+	sort.SliceStable(tags, func(i, j int) bool {
+		if tags[i].Version != nil && tags[j].Version != nil {
+			if !tags[i].Version.Equal(tags[j].Version) {
+				return tags[i].Version.GreaterThan(tags[j].Version)
+			}
+		}
+		return tags[i].Name > tags[j].Name
+	})
+
+	if opts.Limit > 0 && len(tags) > opts.Limit {
+		tags = tags[:opts.Limit]
+	}
+
+	for i := range tags {
+		desc, err := puller.Head(ctx, repo.Tag(tags[i].Name))
+		if err != nil {
+			return nil, fmt.Errorf("fetching digest for tag '%s' failed: %w", tags[i].Name, err)
+		}
+		tags[i].Digest = desc.Digest.String()
+	}
+
+	return tags, nil
+}
+
+// tagFilter filters the tags of an OCI repository.
+type tagFilter struct {
+	constraint             *semver.Constraints
+	re                     *regexp.Regexp
+	includeCosignArtifacts bool
+}
+
+// newTagFilter returns a tagFilter for the given semver range and regex,
+// which are ignored if empty.
+func newTagFilter(semverFilter, regexFilter string, includeCosignArtifacts bool) (*tagFilter, error) {
+	f := &tagFilter{includeCosignArtifacts: includeCosignArtifacts}
+
+	if semverFilter != "" {
+		constraint, err := semver.NewConstraint(semverFilter)
+		if err != nil {
+			return nil, fmt.Errorf("semver '%s' parse error: %w", semverFilter, err)
+		}
+		f.constraint = constraint
+	}
+
+	if regexFilter != "" {
+		re, err := regexp.Compile(regexFilter)
+		if err != nil {
+			return nil, fmt.Errorf("regex '%s' parse error: %w", regexFilter, err)
+		}
+		f.re = re
+	}
+
+	return f, nil
+}
+
+// match returns true if the tag passes the filter, along with its parsed
+// version when filtering by semver.
+func (f *tagFilter) match(tag string) (*semver.Version, bool) {
+	// ignore cosign artifacts by default
+	if !f.includeCosignArtifacts && IsCosignArtifact(tag) {
+		return nil, false
+	}
+
+	var v *semver.Version
+	if f.constraint != nil {
+		var err error
+		v, err = version.ParseVersion(tag)
+		// version isn't a valid semver so we can skip
+		if err != nil {
+			return nil, false
+		}
+
+		if !f.constraint.Check(v) {
+			return nil, false
+		}
+	}
+
+	if f.re != nil && !f.re.MatchString(tag) {
+		return nil, false
+	}
+
+	return v, true
+}
+
 // IsCosignArtifact will return true if the tag has one of the following suffices:
 // ".att", ".sbom", or ".sig". These are the suffices used by cosign to store the
 // attestations, SBOMs, and signatures respectively.
@@ -142,3 +142,70 @@ func Test_List(t *testing.T) {
 		})
 	}
 }
+
+func Test_ListTags(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	c := NewClient(DefaultOptions())
+	repo := "test-list-tags" + randStringRunes(5)
+	tags := []string{
+		"v0.0.1", "v0.0.10", "v0.0.2", "v6.0.0", "v6.0.1", "v6.0.2-rc.1", "staging-fb3355b",
+		"sha256-e2688bb75ee43df49c9bfb2aa30dd98173649db53955e87c347024ba71bc1c80.sig",
+	}
+
+	digests := make(map[string]string, len(tags))
+	for _, tag := range tags {
+		dst := fmt.Sprintf("%s/%s:%s", dockerReg, repo, tag)
+		img, err := random.Image(1024, 1)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(crane.Push(img, dst, c.options...)).To(Succeed())
+		digest, err := img.Digest()
+		g.Expect(err).ToNot(HaveOccurred())
+		digests[tag] = digest.String()
+	}
+
+	tests := []struct {
+		name         string
+		opts         ListTagsOptions
+		expectedTags []string
+	}{
+		{
+			name:         "list all app tags across pages",
+			opts:         ListTagsOptions{PageSize: 2},
+			expectedTags: []string{"v6.0.2-rc.1", "v6.0.1", "v6.0.0", "v0.0.2", "v0.0.10", "v0.0.1", "staging-fb3355b"},
+		},
+		{
+			name:         "sort semver tags by version",
+			opts:         ListTagsOptions{SemverFilter: "0.0.x", PageSize: 3},
+			expectedTags: []string{"v0.0.10", "v0.0.2", "v0.0.1"},
+		},
+		{
+			name:         "limit semver tags",
+			opts:         ListTagsOptions{SemverFilter: ">=0.0.0-0", Limit: 2},
+			expectedTags: []string{"v6.0.2-rc.1", "v6.0.1"},
+		},
+		{
+			name:         "filter by regex",
+			opts:         ListTagsOptions{RegexFilter: "^v6"},
+			expectedTags: []string{"v6.0.2-rc.1", "v6.0.1", "v6.0.0"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			result, err := c.ListTags(ctx, fmt.Sprintf("%s/%s", dockerReg, repo), tt.opts)
+			g.Expect(err).ToNot(HaveOccurred())
+
+			var names []string
+			for _, tag := range result {
+				names = append(names, tag.Name)
+				g.Expect(tag.Digest).To(Equal(digests[tag.Name]))
+			}
+			g.Expect(names).To(Equal(tt.expectedTags))
+		})
+	}
+
+	_, err := c.ListTags(ctx, fmt.Sprintf("%s/%s", dockerReg, repo), ListTagsOptions{SemverFilter: "invalid"})
+	g.Expect(err).To(HaveOccurred())
+}
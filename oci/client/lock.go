@@ -0,0 +1,119 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+// LockFileVersion is the version of the lock file format.
+const LockFileVersion = "v1"
+
+// LockFile maps the references of a set of artifacts to the digests they
+// resolved to, so that the artifacts can be pulled by digest after resolving
+// their tags once.
+type LockFile struct {
+	// Version is the version of the lock file format.
+	Version string `json:"version"`
+	// Artifacts maps the references of the artifacts, e.g.
+	// 'ghcr.io/org/app:v1.0.0', to their digest, e.g. 'sha256:...'.
+	Artifacts map[string]string `json:"artifacts"`
+}
+
+// ReadLockFile reads the lock file at the given path.
+func ReadLockFile(path string) (*LockFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read lock file: %w", err)
+	}
+	var lock LockFile
+	if err := json.Unmarshal(data, &lock); err != nil {
+		return nil, fmt.Errorf("failed to decode lock file: %w", err)
+	}
+	if lock.Version != LockFileVersion {
+		return nil, fmt.Errorf("unsupported lock file version '%s'", lock.Version)
+	}
+	return &lock, nil
+}
+
+// Write writes the lock file to the given path.
+func (l *LockFile) Write(path string) error {
+	data, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode lock file: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to write lock file: %w", err)
+	}
+	return nil
+}
+
+// Resolve returns the digest reference, e.g. 'ghcr.io/org/app@sha256:...',
+// of the artifact at the given URL as recorded in the lock file.
+func (l *LockFile) Resolve(url string) (string, error) {
+	ref, err := name.ParseReference(url)
+	if err != nil {
+		return "", fmt.Errorf("invalid URL: %w", err)
+	}
+	digest, ok := l.Artifacts[ref.Name()]
+	if !ok {
+		return "", fmt.Errorf("'%s' not found in lock file", url)
+	}
+	return ref.Context().Digest(digest).String(), nil
+}
+
+// Lock resolves the given artifact URLs to their digests, and returns them
+// recorded in a lock file. The digest references are recorded as is.
+func (c *Client) Lock(ctx context.Context, urls ...string) (*LockFile, error) {
+	lock := &LockFile{
+		Version:   LockFileVersion,
+		Artifacts: make(map[string]string, len(urls)),
+	}
+	for _, url := range urls {
+		ref, err := name.ParseReference(url)
+		if err != nil {
+			return nil, fmt.Errorf("invalid URL: %w", err)
+		}
+		if d, ok := ref.(name.Digest); ok {
+			lock.Artifacts[ref.Name()] = d.DigestStr()
+			continue
+		}
+		digest, err := crane.Digest(ref.String(), c.optionsWithContext(ctx)...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve '%s': %w", url, err)
+		}
+		lock.Artifacts[ref.Name()] = digest
+	}
+	return lock, nil
+}
+
+// PullLocked pulls the artifact at the given URL by the digest recorded in
+// the lock file, see Pull. An error is returned if the URL is not found in
+// the lock file. The URL of the returned metadata is the digest reference.
+func (c *Client) PullLocked(ctx context.Context, lock *LockFile, url, outDir string, opts ...PullOption) (*Metadata, error) {
+	digestURL, err := lock.Resolve(url)
+	if err != nil {
+		return nil, err
+	}
+	return c.Pull(ctx, digestURL, outDir, opts...)
+}
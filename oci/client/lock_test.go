@@ -0,0 +1,66 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func Test_Lock_PullLocked(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	c := NewClient(DefaultOptions())
+	repo := fmt.Sprintf("%s/test-lock-%s", dockerReg, randStringRunes(5))
+	url := repo + ":v1"
+
+	first, err := c.Push(ctx, url, "testdata/artifact")
+	g.Expect(err).ToNot(HaveOccurred())
+	byDigest := fmt.Sprintf("%s/test-lock-digest@sha256:%064d", dockerReg, 1)
+
+	lock, err := c.Lock(ctx, url, byDigest)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(lock.Artifacts).To(HaveLen(2))
+
+	// The lock file survives a round trip to disk.
+	path := filepath.Join(t.TempDir(), "oci.lock")
+	g.Expect(lock.Write(path)).To(Succeed())
+	lock, err = ReadLockFile(path)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	resolved, err := lock.Resolve(url)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(resolved).To(Equal(first))
+	resolved, err = lock.Resolve(byDigest)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(resolved).To(Equal(byDigest))
+
+	// Moving the tag does not change the pulled artifact.
+	_, err = c.Push(ctx, url, "testdata/artifact/deployment.yaml")
+	g.Expect(err).ToNot(HaveOccurred())
+
+	meta, err := c.PullLocked(ctx, lock, url, t.TempDir())
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(meta.Digest).To(Equal(first))
+
+	_, err = c.PullLocked(ctx, lock, repo+":v2", t.TempDir())
+	g.Expect(err).To(MatchError(ContainSubstring("not found in lock file")))
+}
@@ -40,6 +40,7 @@ func (c *Client) LoginWithCredentials(credentials string) error {
 	}
 
 	c.options = append(c.options, crane.WithAuth(auth))
+	c.auth = auth
 	return nil
 }
 
@@ -63,6 +64,25 @@ func GetAuthFromCredentials(credentials string) (authn.Authenticator, error) {
 	return authn.FromConfig(authConfig), nil
 }
 
+// LoginWithKeychain configures the client with the credentials resolved from the keychain
+// for the repository of the specified url, e.g. a keychain.Keychain reading the credentials
+// files and helpers of the node.
+func (c *Client) LoginWithKeychain(url string, keychain authn.Keychain) error {
+	ref, err := name.ParseReference(url)
+	if err != nil {
+		return fmt.Errorf("could not create reference from url '%s': %w", url, err)
+	}
+
+	authenticator, err := keychain.Resolve(ref.Context())
+	if err != nil {
+		return fmt.Errorf("could not resolve credentials for url %s: %w", url, err)
+	}
+
+	c.options = append(c.options, crane.WithAuth(authenticator))
+	c.auth = authenticator
+	return nil
+}
+
 // LoginWithProvider configures the client to log in to the specified provider
 func (c *Client) LoginWithProvider(ctx context.Context, url string, provider oci.Provider) error {
 	var authenticator authn.Authenticator
@@ -89,5 +109,6 @@ func (c *Client) LoginWithProvider(ctx context.Context, url string, provider oci
 	}
 
 	c.options = append(c.options, crane.WithAuth(authenticator))
+	c.auth = authenticator
 	return nil
 }
@@ -21,11 +21,15 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
 	"github.com/google/go-containerregistry/pkg/crane"
 	. "github.com/onsi/gomega"
+
+	"github.com/fluxcd/pkg/oci/auth/keychain"
 )
 
 type mockTransport struct {
@@ -81,3 +85,29 @@ func Test_Login(t *testing.T) {
 		})
 	}
 }
+
+func Test_LoginWithKeychain(t *testing.T) {
+	g := NewWithT(t)
+
+	config := filepath.Join(t.TempDir(), "config.json")
+	err := os.WriteFile(config, []byte(fmt.Sprintf(`{"auths": {"%s": {"auth": "dXNlcm5hbWU6cGFzc3dvcmQ="}}}`, dockerReg)), 0o600)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	c := NewClient(DefaultOptions())
+	url := fmt.Sprintf("%s/%s:%s", dockerReg, "test", "test")
+	err = c.LoginWithKeychain(url, keychain.New(keychain.WithSources(keychain.DockerConfig(config))))
+	g.Expect(err).ToNot(HaveOccurred())
+
+	transportFunc := mockTransport{
+		response: &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader(`{}`)),
+		},
+	}
+	c.options = append(c.options, crane.WithTransport(&transportFunc))
+
+	err = crane.Delete(url, c.optionsWithContext(context.Background())...)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(transportFunc.request).ToNot(BeNil())
+	g.Expect(transportFunc.request.Header.Get("Authorization")).To(Equal("Basic dXNlcm5hbWU6cGFzc3dvcmQ="))
+}
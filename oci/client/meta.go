@@ -29,6 +29,8 @@ type Metadata struct {
 	Digest      string            `json:"digest"`
 	URL         string            `json:"url"`
 	Annotations map[string]string `json:"annotations,omitempty"`
+	IndexDigest string            `json:"index_digest,omitempty"`
+	Platform    string            `json:"platform,omitempty"`
 }
 
 // ToAnnotations returns the OpenContainers annotations map.
@@ -0,0 +1,104 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/name"
+	gcrv1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// Mirror is a registry which serves the content of another registry,
+// for example an internal pull-through cache.
+type Mirror struct {
+	// Endpoint is the address of the mirror, optionally followed by the
+	// path under which the repositories of the mirrored registry are served.
+	// For example: 'registry.internal:5000' or 'registry.internal/ghcr.io'.
+	Endpoint string
+	// Auth is used to authenticate against the mirror. If nil, the
+	// credentials of the mirror are looked up in the default keychain
+	// (e.g. the Docker config), the authentication configured on the
+	// client for the mirrored registry is never sent to the mirror.
+	Auth authn.Authenticator
+	// Insecure allows connecting to the mirror over plain HTTP.
+	Insecure bool
+}
+
+// WithMirrors configures the client to pull artifacts from mirrors.
+// The mirrors map is keyed by the host of the mirrored registry (for
+// example, 'ghcr.io' or 'docker.io'), and its mirrors are tried in order
+// before falling back to the registry itself.
+func (c *Client) WithMirrors(mirrors map[string][]Mirror) *Client {
+	c.mirrors = make(map[string][]Mirror, len(mirrors))
+	for host, m := range mirrors {
+		// Normalize the host, e.g. 'docker.io' to 'index.docker.io'.
+		if reg, err := name.NewRegistry(host); err == nil {
+			host = reg.RegistryStr()
+		}
+		c.mirrors[host] = append(c.mirrors[host], m...)
+	}
+	return c
+}
+
+// mirrorURL returns the URL of the artifact referenced by ref on the mirror.
+func mirrorURL(ref name.Reference, mirror Mirror) string {
+	sep := ":"
+	if _, ok := ref.(name.Digest); ok {
+		sep = "@"
+	}
+	endpoint := strings.TrimSuffix(mirror.Endpoint, "/")
+	return fmt.Sprintf("%s/%s%s%s", endpoint, ref.Context().RepositoryStr(), sep, ref.Identifier())
+}
+
+// pullImage pulls the image at the given URL from the first mirror of
+// its registry which serves it, or from the registry itself if none does.
+// Image indexes are resolved to the manifest of the given platform.
+func (c *Client) pullImage(ctx context.Context, ref name.Reference, platform *gcrv1.Platform) (gcrv1.Image, *resolvedIndex, error) {
+	var errs []error
+	for _, mirror := range c.mirrors[ref.Context().RegistryStr()] {
+		// Override the authentication of the client, which is meant for
+		// the mirrored registry.
+		options := c.optionsWithContext(ctx)
+		if mirror.Auth != nil {
+			options = append(options, crane.WithAuth(mirror.Auth))
+		} else {
+			options = append(options, crane.WithAuthFromKeychain(authn.DefaultKeychain))
+		}
+		if mirror.Insecure {
+			options = append(options, crane.Insecure)
+		}
+
+		url := mirrorURL(ref, mirror)
+		img, resolved, err := fetchImage(url, platform, options...)
+		if err == nil {
+			return img, resolved, nil
+		}
+		errs = append(errs, fmt.Errorf("pulling from mirror '%s' failed: %w", mirror.Endpoint, err))
+	}
+
+	img, resolved, err := fetchImage(ref.String(), platform, c.optionsWithContext(ctx)...)
+	if err != nil {
+		return nil, nil, errors.Join(append(errs, err)...)
+	}
+	return img, resolved, nil
+}
@@ -0,0 +1,139 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/name"
+	. "github.com/onsi/gomega"
+)
+
+func Test_mirrorURL(t *testing.T) {
+	tests := []struct {
+		name     string
+		url      string
+		endpoint string
+		want     string
+	}{
+		{
+			name:     "tag",
+			url:      "ghcr.io/fluxcd/manifests:v1.0.0",
+			endpoint: "registry.internal",
+			want:     "registry.internal/fluxcd/manifests:v1.0.0",
+		},
+		{
+			name:     "digest with path prefix",
+			url:      "ghcr.io/fluxcd/manifests@sha256:ccfd4f3fd1ed6dd35d2bd2d4bd6ab6a0b3c3ef4c5e6da2e5d6ad4b3d9e1b1f0a",
+			endpoint: "registry.internal:5000/ghcr.io/",
+			want:     "registry.internal:5000/ghcr.io/fluxcd/manifests@sha256:ccfd4f3fd1ed6dd35d2bd2d4bd6ab6a0b3c3ef4c5e6da2e5d6ad4b3d9e1b1f0a",
+		},
+		{
+			name:     "docker hub library image",
+			url:      "nginx",
+			endpoint: "registry.internal/docker.io",
+			want:     "registry.internal/docker.io/library/nginx:latest",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			ref, err := name.ParseReference(tt.url)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(mirrorURL(ref, Mirror{Endpoint: tt.endpoint})).To(Equal(tt.want))
+		})
+	}
+}
+
+func Test_PullFromMirror(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	repo := "test-mirror" + randStringRunes(5)
+
+	// Push the artifact to the mirror, under the path of the mirrored registry.
+	mirrored := fmt.Sprintf("%s/upstream.internal/%s:v1", dockerReg, repo)
+	_, err := NewClient(DefaultOptions()).Push(ctx, mirrored, "testdata/artifact")
+	g.Expect(err).ToNot(HaveOccurred())
+
+	c := NewClient(DefaultOptions()).WithMirrors(map[string][]Mirror{
+		"upstream.internal": {
+			{Endpoint: "localhost:1", Insecure: true},
+			{Endpoint: dockerReg + "/upstream.internal"},
+		},
+	})
+
+	url := fmt.Sprintf("upstream.internal/%s:v1", repo)
+	tmpDir := t.TempDir()
+	meta, err := c.Pull(ctx, url, tmpDir)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(meta.URL).To(Equal(url))
+	g.Expect(filepath.Join(tmpDir, "deployment.yaml")).To(BeARegularFile())
+
+	// Without a mirror serving the artifact, all errors are returned.
+	c = NewClient(DefaultOptions()).WithMirrors(map[string][]Mirror{
+		"upstream.internal": {
+			{Endpoint: "localhost:1", Insecure: true},
+		},
+	})
+	_, err = c.Pull(ctx, url, t.TempDir())
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.Error()).To(ContainSubstring("pulling from mirror 'localhost:1' failed"))
+}
+
+func Test_PullFromMirror_noUpstreamCredentials(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	var mu sync.Mutex
+	var authHeaders []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		authHeaders = append(authHeaders, r.Header.Get("Authorization"))
+		mu.Unlock()
+		w.Header().Set("WWW-Authenticate", `Basic realm="mirror"`)
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+
+	upstreamAuth := &authn.Basic{Username: "upstream", Password: "secret"}
+	c := NewClient([]crane.Option{crane.WithAuth(upstreamAuth)}).WithMirrors(map[string][]Mirror{
+		"upstream.internal": {
+			{Endpoint: strings.TrimPrefix(srv.URL, "http://"), Insecure: true},
+		},
+	})
+
+	_, err := c.Pull(ctx, "upstream.internal/test:v1", t.TempDir())
+	g.Expect(err).To(HaveOccurred())
+
+	mu.Lock()
+	defer mu.Unlock()
+	g.Expect(authHeaders).ToNot(BeEmpty())
+	for _, h := range authHeaders {
+		g.Expect(h).To(BeEmpty())
+	}
+}
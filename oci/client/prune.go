@@ -0,0 +1,181 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/name"
+	gcrv1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// PruneOptions contains the retention policy applied by Prune. A tag is kept
+// if it matches any of the retention rules, and at least one rule must be set.
+type PruneOptions struct {
+	// KeepLast is the number of tags to keep, starting from the ones
+	// referencing the most recently created artifacts.
+	KeepLast int
+	// KeepSemver is a semver range, the tags matching it are kept.
+	KeepSemver string
+	// TTL is the duration for which the tags referencing an artifact are
+	// kept after the artifact's creation time.
+	TTL time.Duration
+	// RegexFilter restricts pruning to the tags matching the regex,
+	// the other tags are kept.
+	RegexFilter string
+	// PruneUndated allows the pruning of the artifacts without a valid
+	// creation time. By default, they are kept as their age is unknown.
+	PruneUndated bool
+	// DryRun can be used to list the tags which would be pruned
+	// without deleting them.
+	DryRun bool
+}
+
+// PrunedTag holds a tag deleted by Prune and the artifact it referenced.
+type PrunedTag struct {
+	// Name is the name of the tag.
+	Name string
+	// Digest is the digest of the artifact the tag referenced.
+	Digest string
+	// Created is the creation time of the artifact, read from the
+	// 'org.opencontainers.image.created' annotation. It is zero if the
+	// annotation is missing or invalid.
+	Created time.Time
+}
+
+// Prune deletes the artifacts of the given OCI repository whose tags are not
+// retained by the given policy, and returns the pruned tags sorted from the most
+// recently created artifact. Artifacts are deleted by digest, hence an artifact
+// referenced by a retained tag is never deleted. Artifacts without a valid
+// creation time are kept unless PruneUndated is set. Cosign artifacts are ignored.
+// If DryRun is set, the tags which would be pruned are returned and nothing is deleted.
+func (c *Client) Prune(ctx context.Context, url string, opts PruneOptions) ([]PrunedTag, error) {
+	if opts.KeepLast <= 0 && opts.KeepSemver == "" && opts.TTL <= 0 {
+		return nil, errors.New("at least one retention rule is required")
+	}
+
+	repo, err := name.NewRepository(url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL: %w", err)
+	}
+
+	semverFilter, err := newTagFilter(opts.KeepSemver, "", false)
+	if err != nil {
+		return nil, err
+	}
+	regexFilter, err := newTagFilter("", opts.RegexFilter, false)
+	if err != nil {
+		return nil, err
+	}
+
+	tags, err := c.ListTags(ctx, url, ListTagsOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := make([]PrunedTag, 0, len(tags))
+	created := make(map[string]time.Time)
+	retained := make(map[string]bool)
+	for _, tag := range tags {
+		if _, ok := regexFilter.match(tag.Name); !ok {
+			retained[tag.Digest] = true
+			continue
+		}
+		if opts.KeepSemver != "" {
+			if _, ok := semverFilter.match(tag.Name); ok {
+				retained[tag.Digest] = true
+				continue
+			}
+		}
+
+		t, ok := created[tag.Digest]
+		if !ok {
+			if t, err = c.artifactCreated(ctx, repo.Digest(tag.Digest).String()); err != nil {
+				return nil, err
+			}
+			created[tag.Digest] = t
+		}
+		if t.IsZero() && !opts.PruneUndated {
+			retained[tag.Digest] = true
+			continue
+		}
+		candidates = append(candidates, PrunedTag{Name: tag.Name, Digest: tag.Digest, Created: t})
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if !candidates[i].Created.Equal(candidates[j].Created) {
+			return candidates[i].Created.After(candidates[j].Created)
+		}
+		return candidates[i].Name > candidates[j].Name
+	})
+
+	now := time.Now()
+	var pruned []PrunedTag
+	for i, tag := range candidates {
+		switch {
+		case i < opts.KeepLast:
+			retained[tag.Digest] = true
+		case opts.TTL > 0 && !tag.Created.IsZero() && now.Sub(tag.Created) < opts.TTL:
+			retained[tag.Digest] = true
+		default:
+			pruned = append(pruned, tag)
+		}
+	}
+
+	result := make([]PrunedTag, 0, len(pruned))
+	deleted := make(map[string]bool)
+	for _, tag := range pruned {
+		if retained[tag.Digest] {
+			continue
+		}
+		if !opts.DryRun && !deleted[tag.Digest] {
+			if err := crane.Delete(repo.Digest(tag.Digest).String(), c.optionsWithContext(ctx)...); err != nil {
+				return result, fmt.Errorf("deleting artifact '%s' failed: %w", tag.Digest, err)
+			}
+			deleted[tag.Digest] = true
+		}
+		result = append(result, tag)
+	}
+
+	return result, nil
+}
+
+// artifactCreated returns the creation time of the artifact at the given URL,
+// or the zero time if the artifact has no valid creation annotation.
+func (c *Client) artifactCreated(ctx context.Context, url string) (time.Time, error) {
+	manifestJSON, err := crane.Manifest(url, c.optionsWithContext(ctx)...)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("fetching manifest failed: %w", err)
+	}
+
+	manifest, err := gcrv1.ParseManifest(bytes.NewReader(manifestJSON))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("parsing manifest failed: %w", err)
+	}
+
+	t, err := time.Parse(time.RFC3339, MetadataFromAnnotations(manifest.Annotations).Created)
+	if err != nil {
+		return time.Time{}, nil
+	}
+	return t, nil
+}
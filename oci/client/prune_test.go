@@ -0,0 +1,93 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	gcrv1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	. "github.com/onsi/gomega"
+)
+
+func TestPrune(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	c := NewClient(DefaultOptions())
+	repo := fmt.Sprintf("%s/test-prune%s", dockerReg, randStringRunes(5))
+
+	// Each tag references a distinct artifact, created one hour apart
+	// from the oldest 'v0.0.1' to the most recent 'dev-3'.
+	tags := []string{"v0.0.1", "v1.0.0", "v1.1.0", "dev-1", "dev-2", "dev-3"}
+	now := time.Now()
+	for i, tag := range tags {
+		img, err := random.Image(1024, 1)
+		g.Expect(err).ToNot(HaveOccurred())
+		m := Metadata{
+			Source:   "github.com/fluxcd/fluxv2",
+			Revision: tag,
+			Created:  now.Add(time.Duration(i-len(tags)) * time.Hour).Format(time.RFC3339),
+		}
+		img = mutate.Annotations(img, m.ToAnnotations()).(gcrv1.Image)
+		g.Expect(crane.Push(img, fmt.Sprintf("%s:%s", repo, tag), c.options...)).To(Succeed())
+	}
+
+	// The 'undated' tag references an artifact without creation time.
+	img, err := random.Image(1024, 1)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(crane.Push(img, fmt.Sprintf("%s:undated", repo), c.options...)).To(Succeed())
+
+	prunedNames := func(pruned []PrunedTag) []string {
+		var names []string
+		for _, p := range pruned {
+			names = append(names, p.Name)
+		}
+		return names
+	}
+
+	_, err = c.Prune(ctx, repo, PruneOptions{})
+	g.Expect(err).To(HaveOccurred())
+
+	pruned, err := c.Prune(ctx, repo, PruneOptions{TTL: time.Minute, RegexFilter: "^undated$", DryRun: true})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(pruned).To(BeEmpty())
+
+	pruned, err = c.Prune(ctx, repo, PruneOptions{TTL: time.Minute, RegexFilter: "^undated$", PruneUndated: true, DryRun: true})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(prunedNames(pruned)).To(Equal([]string{"undated"}))
+
+	pruned, err = c.Prune(ctx, repo, PruneOptions{KeepLast: 2, DryRun: true})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(prunedNames(pruned)).To(Equal([]string{"dev-1", "v1.1.0", "v1.0.0", "v0.0.1"}))
+
+	pruned, err = c.Prune(ctx, repo, PruneOptions{KeepLast: 1, KeepSemver: ">= 1.0.0", TTL: 200 * time.Minute, DryRun: true})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(prunedNames(pruned)).To(Equal([]string{"v0.0.1"}))
+
+	pruned, err = c.Prune(ctx, repo, PruneOptions{KeepLast: 1, RegexFilter: "^dev-"})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(prunedNames(pruned)).To(Equal([]string{"dev-2", "dev-1"}))
+
+	remaining, err := crane.ListTags(repo, c.options...)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(remaining).To(ConsistOf("v0.0.1", "v1.0.0", "v1.1.0", "dev-3", "undated"))
+}
@@ -19,21 +19,98 @@ package client
 import (
 	"context"
 	"fmt"
+	"io"
+	"os"
+	"path/filepath"
 
-	"github.com/google/go-containerregistry/pkg/crane"
+	"filippo.io/age"
 	"github.com/google/go-containerregistry/pkg/name"
+	gcrv1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/types"
 
+	"github.com/fluxcd/pkg/oci"
 	"github.com/fluxcd/pkg/tar"
 )
 
+// PullOptions are options for configuring the Pull operation.
+type PullOptions struct {
+	layerType       LayerType
+	layerMediaTypes []types.MediaType
+	progress        ProgressFunc
+	stats           *TransferStats
+	platform        *gcrv1.Platform
+	identities      []age.Identity
+}
+
+// PullOption is a function for configuring PullOptions.
+type PullOption func(o *PullOptions)
+
+// WithPullLayerType sets how the content of the layer is extracted.
+// A `LayerTypeTarball` layer is extracted into the output directory,
+// while the content of a `LayerTypeStatic` layer is written as is to a
+// file in the output directory. The file is named after the title
+// annotation of the layer, or after the layer digest if not set.
+// Defaults to `LayerTypeTarball`.
+func WithPullLayerType(l LayerType) PullOption {
+	return func(o *PullOptions) {
+		o.layerType = l
+	}
+}
+
+// WithPullLayerMediaTypes configures the media types of the layer to pull,
+// in order of preference. The first layer of the artifact which matches the
+// most preferred media type is pulled. If none of the layers match, an error
+// is returned. By default, the first layer of the artifact is pulled
+// regardless of its media type.
+func WithPullLayerMediaTypes(mediaTypes ...types.MediaType) PullOption {
+	return func(o *PullOptions) {
+		o.layerMediaTypes = append(o.layerMediaTypes, mediaTypes...)
+	}
+}
+
+// WithPullProgress configures a function which is called with the progress
+// of the layer download.
+func WithPullProgress(fn ProgressFunc) PullOption {
+	return func(o *PullOptions) {
+		o.progress = fn
+	}
+}
+
+// WithPullTransferStats configures the given TransferStats to be filled
+// with the metrics of the download once the pull completes.
+func WithPullTransferStats(stats *TransferStats) PullOption {
+	return func(o *PullOptions) {
+		o.stats = stats
+	}
+}
+
+// WithPullPlatform configures the platform used to resolve image indexes
+// (multi-arch artifacts) to one of their manifests. If not set, an image
+// index with multiple manifests is resolved to the linux/amd64 one.
+func WithPullPlatform(platform gcrv1.Platform) PullOption {
+	return func(o *PullOptions) {
+		o.platform = &platform
+	}
+}
+
 // Pull downloads an artifact from an OCI repository and extracts the content to the given directory.
-func (c *Client) Pull(ctx context.Context, url, outDir string) (*Metadata, error) {
+func (c *Client) Pull(ctx context.Context, url, outDir string, opts ...PullOption) (*Metadata, error) {
+	o := &PullOptions{
+		layerType: LayerTypeTarball,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+
 	ref, err := name.ParseReference(url)
 	if err != nil {
 		return nil, fmt.Errorf("invalid URL: %w", err)
 	}
 
-	img, err := crane.Pull(url, c.optionsWithContext(ctx)...)
+	tracker := newTransferTracker(o.progress, o.stats)
+	defer tracker.finish()
+
+	img, resolved, err := c.pullImage(ctx, ref, o.platform)
 	if err != nil {
 		return nil, err
 	}
@@ -51,6 +128,12 @@ func (c *Client) Pull(ctx context.Context, url, outDir string) (*Metadata, error
 	meta := MetadataFromAnnotations(manifest.Annotations)
 	meta.URL = url
 	meta.Digest = ref.Context().Digest(digest.String()).String()
+	if resolved != nil {
+		meta.IndexDigest = ref.Context().Digest(resolved.digest.String()).String()
+		if resolved.platform != nil {
+			meta.Platform = resolved.platform.String()
+		}
+	}
 
 	layers, err := img.Layers()
 	if err != nil {
@@ -61,14 +144,79 @@ func (c *Client) Pull(ctx context.Context, url, outDir string) (*Metadata, error
 		return nil, fmt.Errorf("no layers found in artifact")
 	}
 
-	blob, err := layers[0].Compressed()
+	index, err := selectLayer(manifest.Layers, o.layerMediaTypes)
 	if err != nil {
-		return nil, fmt.Errorf("extracting first layer failed: %w", err)
+		return nil, err
 	}
 
-	if err = tar.Untar(blob, outDir, tar.WithMaxUntarSize(-1), tar.WithSkipSymlinks()); err != nil {
-		return nil, fmt.Errorf("failed to untar first layer: %w", err)
+	layer, err := tracker.track(layers[index])
+	if err != nil {
+		return nil, fmt.Errorf("extracting layer failed: %w", err)
+	}
+
+	blob, err := c.openLayer(layer, manifest.Layers[index].Digest)
+	if err != nil {
+		return nil, fmt.Errorf("extracting layer failed: %w", err)
+	}
+	defer blob.Close()
+
+	content, err := decryptLayer(blob, manifest.Layers[index], o.identities)
+	if err != nil {
+		return nil, err
+	}
+
+	switch o.layerType {
+	case LayerTypeTarball:
+		if err = tar.Untar(content, outDir, tar.WithMaxUntarSize(-1), tar.WithSkipSymlinks()); err != nil {
+			return nil, fmt.Errorf("failed to untar layer: %w", err)
+		}
+	case LayerTypeStatic:
+		if err = writeLayer(content, outDir, manifest.Layers[index]); err != nil {
+			return nil, fmt.Errorf("failed to write layer: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported layer type: '%s'", o.layerType)
 	}
 
 	return meta, nil
 }
+
+// selectLayer returns the index of the first layer matching the most
+// preferred of the given media types. Encrypted layers match the media
+// type of their decrypted content. If no media types are given, the
+// index of the first layer is returned.
+func selectLayer(layers []gcrv1.Descriptor, mediaTypes []types.MediaType) (int, error) {
+	if len(mediaTypes) == 0 {
+		return 0, nil
+	}
+	for _, mt := range mediaTypes {
+		for i, l := range layers {
+			if l.MediaType == mt || isEncrypted(l) && l.MediaType == mt+oci.EncryptedMediaTypeSuffix {
+				return i, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("no layer found in artifact matching media types: %v", mediaTypes)
+}
+
+// writeLayer writes the content of the layer to a file in the given
+// directory.
+func writeLayer(content io.Reader, dir string, desc gcrv1.Descriptor) error {
+	fileName := filepath.Base(desc.Annotations[oci.TitleAnnotation])
+	if fileName == "" || fileName == "." || fileName == string(filepath.Separator) {
+		fileName = desc.Digest.Hex
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	f, err := os.Create(filepath.Join(dir, fileName))
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(f, content); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
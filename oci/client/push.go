@@ -49,14 +49,21 @@ const (
 
 // PushOptions are options for configuring the Push operation.
 type PushOptions struct {
-	layerType LayerType
-	layerOpts layerOptions
-	meta      Metadata
+	layerType       LayerType
+	layerOpts       layerOptions
+	configMediaType types.MediaType
+	meta            Metadata
+	chunkSize       int64
+	chunkRetries    int
+	progress        ProgressFunc
+	stats           *TransferStats
+	recipients      []string
 }
 
 // layerOptions are options for configuring a layer.
 type layerOptions struct {
 	mediaTypeExt string
+	mediaType    types.MediaType
 	ignorePaths  []string
 }
 
@@ -80,6 +87,25 @@ func WithPushMediaTypeExt(extension string) PushOption {
 	}
 }
 
+// WithPushLayerMediaType configures the media type of the image layer,
+// regardless of the layer type. This allows storing content which is not
+// specific to Flux, for example SBOMs or policies.
+// It takes precedence over the media type extension configured with
+// WithPushMediaTypeExt.
+func WithPushLayerMediaType(mediaType types.MediaType) PushOption {
+	return func(o *PushOptions) {
+		o.layerOpts.mediaType = mediaType
+	}
+}
+
+// WithPushConfigMediaType configures the media type of the image config.
+// Defaults to `application/vnd.cncf.flux.config.v1+json`.
+func WithPushConfigMediaType(mediaType types.MediaType) PushOption {
+	return func(o *PushOptions) {
+		o.configMediaType = mediaType
+	}
+}
+
 // WithPushIgnorePaths configures ignore paths for PushOptions
 func WithPushIgnorePaths(paths ...string) PushOption {
 	return func(o *PushOptions) {
@@ -94,11 +120,44 @@ func WithPushMetadata(meta Metadata) PushOption {
 	}
 }
 
+// WithPushChunkedUpload configures the layer to be uploaded in chunks of
+// the given size (in bytes), instead of in a single request. The upload of
+// a chunk which fails is retried up to the given number of times, resuming
+// from the last byte received by the registry. A negative number of retries
+// defaults to DefaultChunkRetries.
+//
+// The credentials used for the upload are the ones configured with
+// LoginWithCredentials or LoginWithProvider, or else the ones found in
+// the default keychain.
+func WithPushChunkedUpload(chunkSize int64, retries int) PushOption {
+	return func(o *PushOptions) {
+		o.chunkSize = chunkSize
+		o.chunkRetries = retries
+	}
+}
+
+// WithPushProgress configures a function which is called with the progress
+// of the layer upload.
+func WithPushProgress(fn ProgressFunc) PushOption {
+	return func(o *PushOptions) {
+		o.progress = fn
+	}
+}
+
+// WithPushTransferStats configures the given TransferStats to be filled
+// with the metrics of the upload once the push completes.
+func WithPushTransferStats(stats *TransferStats) PushOption {
+	return func(o *PushOptions) {
+		o.stats = stats
+	}
+}
+
 // Push creates an artifact from the given path, uploads the artifact
 // to the given OCI repository and returns the digest.
 func (c *Client) Push(ctx context.Context, url, sourcePath string, opts ...PushOption) (string, error) {
 	o := &PushOptions{
-		layerType: LayerTypeTarball,
+		layerType:       LayerTypeTarball,
+		configMediaType: oci.CanonicalConfigMediaType,
 	}
 
 	for _, opt := range opts {
@@ -114,20 +173,58 @@ func (c *Client) Push(ctx context.Context, url, sourcePath string, opts ...PushO
 		return "", fmt.Errorf("error creating layer: %w", err)
 	}
 
+	var layerAnnotations map[string]string
+	if len(o.recipients) > 0 {
+		tmpDir, err := os.MkdirTemp("", "oci")
+		if err != nil {
+			return "", fmt.Errorf("error encrypting layer: %w", err)
+		}
+		defer os.RemoveAll(tmpDir)
+		if layer, layerAnnotations, err = encryptLayer(layer, o.recipients, tmpDir); err != nil {
+			return "", fmt.Errorf("error encrypting layer: %w", err)
+		}
+	}
+
+	tracker := newTransferTracker(o.progress, o.stats)
+	defer tracker.finish()
+	if layer, err = tracker.track(layer); err != nil {
+		return "", fmt.Errorf("error creating layer: %w", err)
+	}
+
 	if o.meta.Created == "" {
 		ct := time.Now().UTC()
 		o.meta.Created = ct.Format(time.RFC3339)
 	}
 
 	img := mutate.MediaType(empty.Image, types.OCIManifestSchema1)
-	img = mutate.ConfigMediaType(img, oci.CanonicalConfigMediaType)
+	img = mutate.ConfigMediaType(img, o.configMediaType)
 	img = mutate.Annotations(img, o.meta.ToAnnotations()).(gcrv1.Image)
 
-	img, err = mutate.Append(img, mutate.Addendum{Layer: layer})
+	addendum := mutate.Addendum{Layer: layer, Annotations: layerAnnotations}
+	if o.layerType == LayerTypeStatic {
+		// Record the file name, so that the content can be restored
+		// as is when pulling the artifact.
+		if addendum.Annotations == nil {
+			addendum.Annotations = map[string]string{}
+		}
+		addendum.Annotations[oci.TitleAnnotation] = filepath.Base(sourcePath)
+	}
+	img, err = mutate.Append(img, addendum)
 	if err != nil {
 		return "", fmt.Errorf("appeding content to artifact failed: %w", err)
 	}
 
+	// Upload the layer beforehand, the push then skips the existing blob.
+	if o.chunkSize > 0 {
+		uploader, err := c.newChunkedUploader(ctx, ref.Context(), o.chunkSize, o.chunkRetries)
+		if err != nil {
+			return "", fmt.Errorf("uploading layer failed: %w", err)
+		}
+		if err := uploader.upload(ctx, layer); err != nil {
+			return "", fmt.Errorf("uploading layer failed: %w", err)
+		}
+	}
+
 	if err := crane.Push(img, url, c.optionsWithContext(ctx)...); err != nil {
 		return "", fmt.Errorf("pushing artifact failed: %w", err)
 	}
@@ -145,6 +242,9 @@ func createLayer(path string, layerType LayerType, opts layerOptions) (gcrv1.Lay
 	switch layerType {
 	case LayerTypeTarball:
 		var ociMediaType = oci.CanonicalContentMediaType
+		if opts.mediaType != "" {
+			ociMediaType = opts.mediaType
+		}
 		var tmpDir string
 		tmpDir, err := os.MkdirTemp("", "oci")
 		if err != nil {
@@ -158,6 +258,9 @@ func createLayer(path string, layerType LayerType, opts layerOptions) (gcrv1.Lay
 		return tarball.LayerFromFile(tmpFile, tarball.WithMediaType(ociMediaType), tarball.WithCompressedCaching)
 	case LayerTypeStatic:
 		var ociMediaType = getLayerMediaType(opts.mediaTypeExt)
+		if opts.mediaType != "" {
+			ociMediaType = opts.mediaType
+		}
 		content, err := os.ReadFile(path)
 		if err != nil {
 			return nil, fmt.Errorf("error reading file for static layer: %w", err)
@@ -27,6 +27,7 @@ import (
 	"testing"
 	"time"
 
+	"filippo.io/age"
 	"github.com/google/go-containerregistry/pkg/crane"
 	"github.com/google/go-containerregistry/pkg/v1/types"
 	. "github.com/onsi/gomega"
@@ -44,13 +45,14 @@ func Test_Push_Pull(t *testing.T) {
 	created := ct.Format(time.RFC3339)
 
 	tests := []struct {
-		name              string
-		sourcePath        string
-		tag               string
-		ignorePaths       []string
-		opts              []PushOption
-		expectErr         bool
-		expectedMediaType types.MediaType
+		name                    string
+		sourcePath              string
+		tag                     string
+		ignorePaths             []string
+		opts                    []PushOption
+		expectErr               bool
+		expectedMediaType       types.MediaType
+		expectedConfigMediaType types.MediaType
 	}{
 		{
 			name:              "push directory (default layer type)",
@@ -94,6 +96,28 @@ func Test_Push_Pull(t *testing.T) {
 			},
 			expectedMediaType: oci.CanonicalMediaTypePrefix,
 		},
+		{
+			name:       "push static file with custom media types",
+			tag:        "v0.0.3",
+			sourcePath: "testdata/artifact/deployment.yaml",
+			opts: []PushOption{
+				WithPushLayerType(LayerTypeStatic),
+				WithPushMediaTypeExt("ml"),
+				WithPushLayerMediaType("application/vnd.acme.policy.v1+yaml"),
+				WithPushConfigMediaType("application/vnd.acme.config.v1+json"),
+			},
+			expectedMediaType:       "application/vnd.acme.policy.v1+yaml",
+			expectedConfigMediaType: "application/vnd.acme.config.v1+json",
+		},
+		{
+			name:       "push directory with custom layer media type",
+			tag:        "v0.0.4",
+			sourcePath: "testdata/artifact",
+			opts: []PushOption{
+				WithPushLayerMediaType("application/vnd.acme.bundle.v1.tar+gzip"),
+			},
+			expectedMediaType: "application/vnd.acme.bundle.v1.tar+gzip",
+		},
 	}
 
 	for _, tt := range tests {
@@ -139,7 +163,11 @@ func Test_Push_Pull(t *testing.T) {
 
 			// Verify media types
 			g.Expect(manifest.MediaType).To(Equal(types.OCIManifestSchema1))
-			g.Expect(manifest.Config.MediaType).To(BeEquivalentTo(oci.CanonicalConfigMediaType))
+			expectedConfigMediaType := tt.expectedConfigMediaType
+			if expectedConfigMediaType == "" {
+				expectedConfigMediaType = oci.CanonicalConfigMediaType
+			}
+			g.Expect(manifest.Config.MediaType).To(Equal(expectedConfigMediaType))
 			g.Expect(len(manifest.Layers)).To(BeEquivalentTo(1))
 			g.Expect(manifest.Layers[0].MediaType).To(BeEquivalentTo(tt.expectedMediaType))
 
@@ -148,7 +176,7 @@ func Test_Push_Pull(t *testing.T) {
 			g.Expect(meta.Annotations["org.opencontainers.image.documentation"]).To(BeEquivalentTo("https://my/readme.md"))
 			g.Expect(meta.Annotations["org.opencontainers.image.licenses"]).To(BeEquivalentTo("Apache-2.0"))
 
-			po := &PushOptions{}
+			po := &PushOptions{layerType: LayerTypeTarball}
 			for _, opt := range opts {
 				opt(po)
 			}
@@ -156,7 +184,7 @@ func Test_Push_Pull(t *testing.T) {
 			case LayerTypeTarball:
 				// Pull the artifact from registry and extract its contents to tmp
 				tmpDir := t.TempDir()
-				_, err := c.Pull(ctx, url, tmpDir)
+				_, err := c.Pull(ctx, url, tmpDir, WithPullLayerMediaTypes(tt.expectedMediaType))
 				g.Expect(err).ToNot(HaveOccurred())
 				// Walk the test directory and check that all files exist in the pulled artifact
 				fsErr := filepath.Walk(tt.sourcePath, func(path string, info fs.FileInfo, err error) error {
@@ -192,6 +220,18 @@ func Test_Push_Pull(t *testing.T) {
 				g.Expect(err).ToNot(HaveOccurred())
 
 				g.Expect(b).To(BeEquivalentTo(expectedBytes))
+
+				// Pull the artifact from registry and write the layer as is to tmp
+				tmpDir := t.TempDir()
+				_, err = c.Pull(ctx, url, tmpDir, WithPullLayerType(LayerTypeStatic),
+					WithPullLayerMediaTypes("application/unknown", tt.expectedMediaType))
+				g.Expect(err).ToNot(HaveOccurred())
+				b, err = os.ReadFile(filepath.Join(tmpDir, filepath.Base(tt.sourcePath)))
+				g.Expect(err).ToNot(HaveOccurred())
+				g.Expect(b).To(BeEquivalentTo(expectedBytes))
+
+				_, err = c.Pull(ctx, url, tmpDir, WithPullLayerMediaTypes("application/unknown"))
+				g.Expect(err).To(HaveOccurred())
 			}
 		})
 	}
@@ -222,3 +262,49 @@ func Test_getLayerMediaType(t *testing.T) {
 		})
 	}
 }
+
+func Test_Push_Pull_Encryption(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	c := NewClient(DefaultOptions())
+	url := fmt.Sprintf("%s/test-encryption%s:v0.0.1", dockerReg, randStringRunes(5))
+
+	identity, err := age.GenerateX25519Identity()
+	g.Expect(err).ToNot(HaveOccurred())
+	otherIdentity, err := age.GenerateX25519Identity()
+	g.Expect(err).ToNot(HaveOccurred())
+
+	_, err = c.Push(ctx, url, "testdata/artifact", WithPushEncryption(identity.Recipient().String()))
+	g.Expect(err).ToNot(HaveOccurred())
+
+	image, err := crane.Pull(url)
+	g.Expect(err).ToNot(HaveOccurred())
+	manifest, err := image.Manifest()
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(manifest.Layers).To(HaveLen(1))
+	g.Expect(manifest.Layers[0].MediaType).To(Equal(oci.CanonicalContentMediaType + oci.EncryptedMediaTypeSuffix))
+	g.Expect(manifest.Layers[0].Annotations).To(HaveKeyWithValue(oci.EncryptionAnnotation, oci.EncryptionAge))
+	g.Expect(manifest.Layers[0].Annotations).To(HaveKeyWithValue(oci.EncryptionRecipientsAnnotation, identity.Recipient().String()))
+
+	_, err = c.Pull(ctx, url, t.TempDir())
+	g.Expect(err).To(MatchError(ErrDecryptionIdentityRequired))
+
+	_, err = c.Pull(ctx, url, t.TempDir(), WithPullDecryption(otherIdentity))
+	g.Expect(err).To(HaveOccurred())
+
+	tmpDir := t.TempDir()
+	_, err = c.Pull(ctx, url, tmpDir, WithPullDecryption(otherIdentity, identity),
+		WithPullLayerMediaTypes(oci.CanonicalContentMediaType))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(filepath.Join(tmpDir, "deployment.yaml")).To(BeARegularFile())
+
+	err = c.Diff(ctx, url, "testdata/artifact", nil)
+	g.Expect(err).To(MatchError(ErrDecryptionIdentityRequired))
+	err = c.Diff(ctx, url, "testdata/artifact", nil, WithPullDecryption(identity))
+	g.Expect(err).ToNot(HaveOccurred())
+	err = c.Diff(ctx, url, "testdata", nil, WithPullDecryption(identity))
+	g.Expect(err).To(HaveOccurred())
+
+	_, err = c.Push(ctx, url, "testdata/artifact", WithPushEncryption("invalid"))
+	g.Expect(err).To(HaveOccurred())
+}
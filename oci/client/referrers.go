@@ -0,0 +1,202 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/name"
+	gcrv1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+
+	"github.com/fluxcd/pkg/oci"
+)
+
+const (
+	// SPDXArtifactType is the artifact type of SBOMs in the SPDX JSON format.
+	SPDXArtifactType types.MediaType = "application/spdx+json"
+	// CycloneDXArtifactType is the artifact type of SBOMs in the CycloneDX
+	// JSON format.
+	CycloneDXArtifactType types.MediaType = "application/vnd.cyclonedx+json"
+	// InTotoArtifactType is the artifact type of in-toto attestations.
+	InTotoArtifactType types.MediaType = "application/vnd.in-toto+json"
+
+	// MaxReferrerContentSize is the maximum size in bytes of the content
+	// returned by ReferrerContent.
+	MaxReferrerContentSize = 10 << 20
+)
+
+// Referrer describes an artifact which refers to another artifact, such as
+// an SBOM or an attestation.
+type Referrer struct {
+	// Digest is the digest URL of the referrer,
+	// e.g. 'ghcr.io/org/repo@sha256:...'.
+	Digest string
+	// ArtifactType is the type of the referrer.
+	ArtifactType string
+	// Annotations are the annotations of the referrer, if provided by the
+	// registry.
+	Annotations map[string]string
+}
+
+// Attach pushes the given content as an artifact of the given type which
+// refers to the artifact at the given URL, and returns the digest URL of
+// the referrer. On registries which don't support the Referrers API, the
+// referrer is tracked using the tag schema fallback.
+func (c *Client) Attach(ctx context.Context, url string, artifactType types.MediaType, content []byte, annotations map[string]string) (string, error) {
+	ref, err := name.ParseReference(url)
+	if err != nil {
+		return "", fmt.Errorf("invalid URL: %w", err)
+	}
+
+	subject, err := crane.Head(url, c.optionsWithContext(ctx)...)
+	if err != nil {
+		return "", fmt.Errorf("fetching subject descriptor failed: %w", err)
+	}
+
+	meta := make(map[string]string, len(annotations)+1)
+	meta[oci.CreatedAnnotation] = time.Now().UTC().Format(time.RFC3339)
+	for k, v := range annotations {
+		meta[k] = v
+	}
+
+	img := mutate.MediaType(empty.Image, types.OCIManifestSchema1)
+	// The artifact type is recorded as the config media type, which
+	// registries and the tag schema fallback report as the artifact type.
+	img = mutate.ConfigMediaType(img, artifactType)
+	img = mutate.Annotations(img, meta).(gcrv1.Image)
+	img, err = mutate.Append(img, mutate.Addendum{Layer: static.NewLayer(content, artifactType)})
+	if err != nil {
+		return "", fmt.Errorf("appending content to referrer failed: %w", err)
+	}
+	img = mutate.Subject(img, gcrv1.Descriptor{
+		MediaType: subject.MediaType,
+		Size:      subject.Size,
+		Digest:    subject.Digest,
+	}).(gcrv1.Image)
+
+	digest, err := img.Digest()
+	if err != nil {
+		return "", fmt.Errorf("parsing referrer digest failed: %w", err)
+	}
+	dst := ref.Context().Digest(digest.String())
+
+	if err := crane.Push(img, dst.String(), c.optionsWithContext(ctx)...); err != nil {
+		return "", fmt.Errorf("pushing referrer failed: %w", err)
+	}
+	return dst.String(), nil
+}
+
+// Referrers returns the artifacts which refer to the artifact at the given
+// URL. If artifactType is not empty, only the referrers of that type are
+// returned. On registries which don't support the Referrers API, the
+// referrers are looked up using the tag schema fallback.
+func (c *Client) Referrers(ctx context.Context, url string, artifactType types.MediaType) ([]Referrer, error) {
+	ref, err := name.ParseReference(url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL: %w", err)
+	}
+
+	digest, ok := ref.(name.Digest)
+	if !ok {
+		d, err := crane.Digest(url, c.optionsWithContext(ctx)...)
+		if err != nil {
+			return nil, fmt.Errorf("fetching subject digest failed: %w", err)
+		}
+		digest = ref.Context().Digest(d)
+	}
+
+	options := crane.GetOptions(c.optionsWithContext(ctx)...).Remote
+	if artifactType != "" {
+		options = append(options, remote.WithFilter("artifactType", string(artifactType)))
+	}
+	idx, err := remote.Referrers(digest, options...)
+	if err != nil {
+		return nil, fmt.Errorf("listing referrers failed: %w", err)
+	}
+	manifest, err := idx.IndexManifest()
+	if err != nil {
+		return nil, fmt.Errorf("parsing referrers failed: %w", err)
+	}
+
+	referrers := make([]Referrer, 0, len(manifest.Manifests))
+	for _, desc := range manifest.Manifests {
+		referrers = append(referrers, Referrer{
+			Digest:       ref.Context().Digest(desc.Digest.String()).String(),
+			ArtifactType: desc.ArtifactType,
+			Annotations:  desc.Annotations,
+		})
+	}
+	return referrers, nil
+}
+
+// ReferrerContent returns the content of the referrer at the given URL,
+// as attached with Attach. Encrypted content is decrypted with the
+// identities configured with WithPullDecryption. Content larger than
+// MaxReferrerContentSize is rejected.
+func (c *Client) ReferrerContent(ctx context.Context, url string, opts ...PullOption) ([]byte, error) {
+	o := &PullOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	img, err := crane.Pull(url, c.optionsWithContext(ctx)...)
+	if err != nil {
+		return nil, fmt.Errorf("pulling referrer failed: %w", err)
+	}
+	layers, err := img.Layers()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list layers: %w", err)
+	}
+	if len(layers) < 1 {
+		return nil, fmt.Errorf("no layers found in referrer")
+	}
+
+	manifest, err := img.Manifest()
+	if err != nil {
+		return nil, fmt.Errorf("parsing manifest failed: %w", err)
+	}
+
+	blob, err := layers[0].Compressed()
+	if err != nil {
+		return nil, fmt.Errorf("extracting layer failed: %w", err)
+	}
+	defer blob.Close()
+
+	var content io.Reader = blob
+	if len(manifest.Layers) > 0 {
+		if content, err = decryptLayer(blob, manifest.Layers[0], o.identities); err != nil {
+			return nil, err
+		}
+	}
+	data, err := io.ReadAll(io.LimitReader(content, MaxReferrerContentSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("reading referrer content failed: %w", err)
+	}
+	if len(data) > MaxReferrerContentSize {
+		return nil, fmt.Errorf("referrer content exceeds the maximum size of %d bytes", MaxReferrerContentSize)
+	}
+	return data, nil
+}
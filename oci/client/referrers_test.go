@@ -0,0 +1,76 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func Test_AttachReferrers(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	c := NewClient(DefaultOptions())
+	repo := "test-referrers" + randStringRunes(5)
+	url := fmt.Sprintf("%s/%s:v1", dockerReg, repo)
+
+	digestURL, err := c.Push(ctx, url, "testdata/artifact")
+	g.Expect(err).ToNot(HaveOccurred())
+
+	// No referrers exist for a new artifact.
+	referrers, err := c.Referrers(ctx, url, "")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(referrers).To(BeEmpty())
+
+	sbom := []byte(`{"spdxVersion":"SPDX-2.3"}`)
+	sbomURL, err := c.Attach(ctx, url, SPDXArtifactType, sbom, map[string]string{"org.example.tool": "syft"})
+	g.Expect(err).ToNot(HaveOccurred())
+
+	attestation := []byte(`{"_type":"https://in-toto.io/Statement/v1"}`)
+	attestationURL, err := c.Attach(ctx, digestURL, InTotoArtifactType, attestation, nil)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(attestationURL).ToNot(Equal(sbomURL))
+
+	// Referrers are listed by tag and by digest.
+	referrers, err = c.Referrers(ctx, url, "")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(referrers).To(HaveLen(2))
+	referrers, err = c.Referrers(ctx, digestURL, "")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(referrers).To(HaveLen(2))
+
+	// Referrers can be filtered by artifact type.
+	referrers, err = c.Referrers(ctx, url, SPDXArtifactType)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(referrers).To(HaveLen(1))
+	g.Expect(referrers[0].Digest).To(Equal(sbomURL))
+	g.Expect(referrers[0].ArtifactType).To(Equal(string(SPDXArtifactType)))
+
+	content, err := c.ReferrerContent(ctx, referrers[0].Digest)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(content).To(Equal(sbom))
+
+	// Content larger than the maximum size is rejected.
+	large := make([]byte, MaxReferrerContentSize+1)
+	largeURL, err := c.Attach(ctx, url, SPDXArtifactType, large, nil)
+	g.Expect(err).ToNot(HaveOccurred())
+	_, err = c.ReferrerContent(ctx, largeURL)
+	g.Expect(err).To(MatchError(ContainSubstring("exceeds the maximum size")))
+}
@@ -0,0 +1,30 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+
+	"github.com/fluxcd/pkg/oci/cosign"
+)
+
+// Sign signs the artifact at the given URL with the signer, e.g. a
+// cosign.KeylessSigner or a cosign.KMSSigner, and pushes the signature to
+// the registry. It returns the digest of the signed artifact.
+func (c *Client) Sign(ctx context.Context, url string, signer cosign.Signer) (string, error) {
+	return cosign.Sign(ctx, url, signer, c.options...)
+}
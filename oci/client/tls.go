@@ -0,0 +1,166 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+const (
+	// CACertKey is the key of the CA bundle in the TLS secret data.
+	CACertKey = "ca.crt"
+	// ClientCertKey is the key of the client certificate in the TLS secret data.
+	ClientCertKey = "tls.crt"
+	// ClientKeyKey is the key of the client private key in the TLS secret data.
+	ClientKeyKey = "tls.key"
+
+	// The legacy keys used by the Flux secrets.
+	legacyCACertKey     = "caFile"
+	legacyClientCertKey = "certFile"
+	legacyClientKeyKey  = "keyFile"
+)
+
+// RegistryTLS holds the TLS settings for connecting to a registry host.
+type RegistryTLS struct {
+	// CA is the PEM encoded CA bundle used to verify the certificate of
+	// the registry, in addition to the system cert pool.
+	CA []byte
+	// Cert is the PEM encoded client certificate used for mutual TLS.
+	Cert []byte
+	// Key is the PEM encoded private key of the client certificate.
+	Key []byte
+	// InsecureSkipVerify disables the verification of the certificate
+	// of the registry.
+	InsecureSkipVerify bool
+}
+
+// RegistryTLSFromSecretData returns the RegistryTLS for the data of a
+// Kubernetes secret, read from the CACertKey, ClientCertKey and ClientKeyKey
+// or the legacy 'caFile', 'certFile' and 'keyFile' keys.
+func RegistryTLSFromSecretData(data map[string][]byte) (RegistryTLS, error) {
+	get := func(key, legacyKey string) []byte {
+		if v, ok := data[key]; ok {
+			return v
+		}
+		return data[legacyKey]
+	}
+
+	t := RegistryTLS{
+		CA:   get(CACertKey, legacyCACertKey),
+		Cert: get(ClientCertKey, legacyClientCertKey),
+		Key:  get(ClientKeyKey, legacyClientKeyKey),
+	}
+	if len(t.CA) == 0 && len(t.Cert) == 0 && len(t.Key) == 0 {
+		return RegistryTLS{}, fmt.Errorf("no %s, or %s and %s found in secret data", CACertKey, ClientCertKey, ClientKeyKey)
+	}
+	return t, nil
+}
+
+// Config returns the tls.Config for the settings.
+func (t RegistryTLS) Config() (*tls.Config, error) {
+	config := &tls.Config{
+		InsecureSkipVerify: t.InsecureSkipVerify,
+	}
+
+	if (len(t.Cert) == 0) != (len(t.Key) == 0) {
+		return nil, errors.New("client certificate and key must be specified together")
+	}
+	if len(t.Cert) > 0 {
+		cert, err := tls.X509KeyPair(t.Cert, t.Key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	if len(t.CA) > 0 {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(t.CA) {
+			return nil, errors.New("failed to parse CA bundle: no certificates found")
+		}
+		config.RootCAs = pool
+	}
+
+	return config, nil
+}
+
+// WithRegistryTLS configures the client to use the given TLS settings when
+// connecting to the registries. The map is keyed by the registry host, for
+// example 'registry.internal:5000'. The requests to hosts which are not in
+// the map, e.g. public registries or token servers on another host, use the
+// transport configured on the client. It returns an error if the client is
+// configured with a transport which is not a *http.Transport, as the TLS
+// settings can't be applied to it.
+func (c *Client) WithRegistryTLS(configs map[string]RegistryTLS) (*Client, error) {
+	base := crane.GetOptions(c.options...).Transport
+	if base == nil {
+		base = remote.DefaultTransport
+	}
+	baseTransport, ok := base.(*http.Transport)
+	if !ok && len(configs) > 0 {
+		return nil, fmt.Errorf("unable to configure TLS on a transport of type %T, expected *http.Transport", base)
+	}
+
+	hosts := make(map[string]http.RoundTripper, len(configs))
+	for host, t := range configs {
+		config, err := t.Config()
+		if err != nil {
+			return nil, fmt.Errorf("invalid TLS configuration for host '%s': %w", host, err)
+		}
+
+		// Normalize the host, e.g. 'docker.io' to 'index.docker.io'.
+		if reg, err := name.NewRegistry(host); err == nil {
+			host = reg.RegistryStr()
+		}
+
+		transport := baseTransport.Clone()
+		transport.TLSClientConfig = config
+		hosts[host] = transport
+	}
+
+	c.options = append(c.options, crane.WithTransport(&hostTransport{
+		base:  base,
+		hosts: hosts,
+	}))
+	return c, nil
+}
+
+// hostTransport is a http.RoundTripper which dispatches the requests to
+// the transport configured for the host of the request.
+type hostTransport struct {
+	base  http.RoundTripper
+	hosts map[string]http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *hostTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if rt, ok := t.hosts[req.URL.Host]; ok {
+		return rt.RoundTrip(req)
+	}
+	return t.base.RoundTrip(req)
+}
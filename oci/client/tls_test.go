@@ -0,0 +1,121 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	. "github.com/onsi/gomega"
+)
+
+func TestRegistryTLSFromSecretData(t *testing.T) {
+	g := NewWithT(t)
+
+	_, err := RegistryTLSFromSecretData(map[string][]byte{"username": []byte("user")})
+	g.Expect(err).To(HaveOccurred())
+
+	rt, err := RegistryTLSFromSecretData(map[string][]byte{
+		CACertKey:  []byte("ca"),
+		"certFile": []byte("cert"),
+		"keyFile":  []byte("key"),
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(rt).To(Equal(RegistryTLS{CA: []byte("ca"), Cert: []byte("cert"), Key: []byte("key")}))
+
+	_, err = RegistryTLS{Cert: []byte("cert")}.Config()
+	g.Expect(err).To(MatchError(ContainSubstring("specified together")))
+
+	_, err = RegistryTLS{CA: []byte("invalid")}.Config()
+	g.Expect(err).To(MatchError(ContainSubstring("no certificates found")))
+}
+
+func TestClient_WithRegistryTLS(t *testing.T) {
+	g := NewWithT(t)
+
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	u, err := url.Parse(srv.URL)
+	g.Expect(err).ToNot(HaveOccurred())
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+
+	get := func(c *Client) error {
+		client := &http.Client{Transport: crane.GetOptions(c.GetOptions()...).Transport}
+		resp, err := client.Get(srv.URL + "/v2/")
+		if err != nil {
+			return err
+		}
+		return resp.Body.Close()
+	}
+
+	tests := []struct {
+		name    string
+		configs map[string]RegistryTLS
+		wantErr bool
+	}{
+		{
+			name:    "no TLS configuration",
+			wantErr: true,
+		},
+		{
+			name:    "TLS configuration of another host",
+			configs: map[string]RegistryTLS{"registry.internal:5000": {CA: ca}},
+			wantErr: true,
+		},
+		{
+			name:    "CA of the host",
+			configs: map[string]RegistryTLS{u.Host: {CA: ca}},
+		},
+		{
+			name:    "insecure host",
+			configs: map[string]RegistryTLS{u.Host: {InsecureSkipVerify: true}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			c, err := NewClient(DefaultOptions()).WithRegistryTLS(tt.configs)
+			g.Expect(err).ToNot(HaveOccurred())
+
+			err = get(c)
+			if tt.wantErr {
+				g.Expect(err).To(MatchError(ContainSubstring("certificate")))
+			} else {
+				g.Expect(err).ToNot(HaveOccurred())
+			}
+		})
+	}
+
+	t.Run("wrapped transport", func(t *testing.T) {
+		g := NewWithT(t)
+
+		opts := append(DefaultOptions(), crane.WithTransport(transport.NewUserAgent(remote.DefaultTransport, "test")))
+		_, err := NewClient(opts).WithRegistryTLS(map[string]RegistryTLS{u.Host: {CA: ca}})
+		g.Expect(err).To(MatchError(ContainSubstring("expected *http.Transport")))
+	})
+}
@@ -0,0 +1,154 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"io"
+	"sync"
+	"time"
+
+	gcrv1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// Progress describes the progress of a push or pull operation.
+type Progress struct {
+	// Transferred is the number of layer bytes transferred so far.
+	Transferred int64
+	// Total is the number of layer bytes to transfer.
+	Total int64
+}
+
+// ProgressFunc is called with the progress of a push or pull operation,
+// each time layer bytes are transferred. It may be called concurrently
+// when multiple layers are transferred in parallel.
+type ProgressFunc func(p Progress)
+
+// TransferStats holds the aggregate metrics of a push or pull operation.
+// Layers which already exist in the registry, or which are served from the
+// layer cache, are not transferred and do not count towards the metrics.
+type TransferStats struct {
+	// Bytes is the number of layer bytes transferred.
+	Bytes int64
+	// Layers is the number of layers transferred.
+	Layers int
+	// Duration is the duration of the operation.
+	Duration time.Duration
+}
+
+// transferTracker tracks the layer bytes transferred during an operation,
+// reporting them to the configured progress function and stats.
+type transferTracker struct {
+	progress ProgressFunc
+	stats    *TransferStats
+	start    time.Time
+
+	mu          sync.Mutex
+	total       int64
+	transferred int64
+	layers      map[gcrv1.Hash]struct{}
+}
+
+// newTransferTracker returns a transferTracker, or nil if neither a
+// progress function nor stats are given.
+func newTransferTracker(progress ProgressFunc, stats *TransferStats) *transferTracker {
+	if progress == nil && stats == nil {
+		return nil
+	}
+	return &transferTracker{
+		progress: progress,
+		stats:    stats,
+		start:    time.Now(),
+		layers:   make(map[gcrv1.Hash]struct{}),
+	}
+}
+
+// track returns a layer which reports the bytes read from its compressed
+// content to the tracker. It returns the layer as is if t is nil.
+func (t *transferTracker) track(layer gcrv1.Layer) (gcrv1.Layer, error) {
+	if t == nil {
+		return layer, nil
+	}
+	size, err := layer.Size()
+	if err != nil {
+		return nil, err
+	}
+	t.mu.Lock()
+	t.total += size
+	t.mu.Unlock()
+	return &trackedLayer{Layer: layer, tracker: t}, nil
+}
+
+// add records n bytes transferred for the layer with the given digest.
+func (t *transferTracker) add(digest gcrv1.Hash, n int64) {
+	t.mu.Lock()
+	t.transferred += n
+	t.layers[digest] = struct{}{}
+	p := Progress{Transferred: t.transferred, Total: t.total}
+	t.mu.Unlock()
+
+	if t.progress != nil {
+		t.progress(p)
+	}
+}
+
+// finish records the aggregate metrics of the operation to the stats.
+func (t *transferTracker) finish() {
+	if t == nil || t.stats == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	*t.stats = TransferStats{
+		Bytes:    t.transferred,
+		Layers:   len(t.layers),
+		Duration: time.Since(t.start),
+	}
+}
+
+// trackedLayer is a gcrv1.Layer which reports the bytes read from its
+// compressed content to a transferTracker.
+type trackedLayer struct {
+	gcrv1.Layer
+	tracker *transferTracker
+}
+
+func (l *trackedLayer) Compressed() (io.ReadCloser, error) {
+	rc, err := l.Layer.Compressed()
+	if err != nil {
+		return nil, err
+	}
+	digest, err := l.Layer.Digest()
+	if err != nil {
+		rc.Close()
+		return nil, err
+	}
+	return &trackedReader{ReadCloser: rc, digest: digest, tracker: l.tracker}, nil
+}
+
+type trackedReader struct {
+	io.ReadCloser
+	digest  gcrv1.Hash
+	tracker *transferTracker
+}
+
+func (r *trackedReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if n > 0 {
+		r.tracker.add(r.digest, int64(n))
+	}
+	return n, err
+}
@@ -0,0 +1,81 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func Test_PushPullProgress(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	repo := "test-progress" + randStringRunes(5)
+	url := fmt.Sprintf("%s/%s:v1", dockerReg, repo)
+	c := NewClient(DefaultOptions())
+
+	var mu sync.Mutex
+	var pushProgress []Progress
+	var pushStats TransferStats
+	_, err := c.Push(ctx, url, "testdata/artifact",
+		WithPushProgress(func(p Progress) {
+			mu.Lock()
+			defer mu.Unlock()
+			pushProgress = append(pushProgress, p)
+		}),
+		WithPushTransferStats(&pushStats),
+	)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(pushProgress).ToNot(BeEmpty())
+	last := pushProgress[len(pushProgress)-1]
+	g.Expect(last.Total).To(BeNumerically(">", 0))
+	g.Expect(last.Transferred).To(BeNumerically(">=", last.Total))
+	g.Expect(pushStats.Layers).To(Equal(1))
+	g.Expect(pushStats.Bytes).To(Equal(last.Transferred))
+	g.Expect(pushStats.Duration).To(BeNumerically(">", 0))
+
+	var pullProgress []Progress
+	var pullStats TransferStats
+	_, err = c.Pull(ctx, url, t.TempDir(),
+		WithPullProgress(func(p Progress) {
+			pullProgress = append(pullProgress, p)
+		}),
+		WithPullTransferStats(&pullStats),
+	)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(pullProgress).ToNot(BeEmpty())
+	last = pullProgress[len(pullProgress)-1]
+	g.Expect(last.Total).To(BeNumerically(">", 0))
+	g.Expect(last.Transferred).To(BeNumerically("<=", last.Total))
+	g.Expect(pullStats.Layers).To(Equal(1))
+	g.Expect(pullStats.Bytes).To(Equal(last.Transferred))
+
+	// Layers served from the cache are not transferred.
+	cache, err := NewLayerCache(t.TempDir(), 0)
+	g.Expect(err).ToNot(HaveOccurred())
+	cc := NewClient(DefaultOptions()).WithLayerCache(cache)
+	_, err = cc.Pull(ctx, url, t.TempDir())
+	g.Expect(err).ToNot(HaveOccurred())
+	_, err = cc.Pull(ctx, url, t.TempDir(), WithPullTransferStats(&pullStats))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(pullStats.Layers).To(Equal(0))
+	g.Expect(pullStats.Bytes).To(BeZero())
+}
@@ -0,0 +1,310 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/name"
+	gcrv1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+)
+
+const (
+	// DefaultChunkRetries is the default number of times the upload of a
+	// chunk is retried before giving up.
+	DefaultChunkRetries = 3
+
+	// defaultChunkRetryInterval is the base interval between retries of a
+	// chunk upload, which is multiplied by the number of the attempt.
+	defaultChunkRetryInterval = time.Second
+)
+
+// chunkedUploader uploads blobs in chunks of a fixed size, using the
+// OCI distribution API. When the upload of a chunk fails, the uploader
+// retrieves the upload status from the registry and resumes from the
+// last offset received by the registry.
+type chunkedUploader struct {
+	client        *http.Client
+	repo          name.Repository
+	chunkSize     int64
+	retries       int
+	retryInterval time.Duration
+}
+
+// newChunkedUploader returns a chunkedUploader for the given repository,
+// which authenticates using the given crane options.
+func (c *Client) newChunkedUploader(ctx context.Context, repo name.Repository, chunkSize int64, retries int) (*chunkedUploader, error) {
+	o := crane.GetOptions(c.optionsWithContext(ctx)...)
+
+	auth := c.auth
+	if auth == nil {
+		var err error
+		if auth, err = o.Keychain.Resolve(repo.Registry); err != nil {
+			return nil, fmt.Errorf("resolving credentials failed: %w", err)
+		}
+	}
+	if auth == nil {
+		auth = authn.Anonymous
+	}
+
+	rt, err := transport.NewWithContext(ctx, repo.Registry, auth, o.Transport, []string{repo.Scope(transport.PushScope)})
+	if err != nil {
+		return nil, fmt.Errorf("authenticating with registry failed: %w", err)
+	}
+
+	if retries < 0 {
+		retries = DefaultChunkRetries
+	}
+	return &chunkedUploader{
+		client:        &http.Client{Transport: rt},
+		repo:          repo,
+		chunkSize:     chunkSize,
+		retries:       retries,
+		retryInterval: defaultChunkRetryInterval,
+	}, nil
+}
+
+// blobsURL returns the URL of the blobs endpoint of the repository.
+func (u *chunkedUploader) blobsURL(suffix string) *url.URL {
+	return &url.URL{
+		Scheme: u.repo.Registry.Scheme(),
+		Host:   u.repo.RegistryStr(),
+		Path:   fmt.Sprintf("/v2/%s/blobs/%s", u.repo.RepositoryStr(), suffix),
+	}
+}
+
+// upload uploads the compressed content of the given layer, unless the
+// blob already exists in the repository.
+func (u *chunkedUploader) upload(ctx context.Context, layer gcrv1.Layer) error {
+	digest, err := layer.Digest()
+	if err != nil {
+		return err
+	}
+
+	exists, err := u.exists(ctx, digest)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return nil
+	}
+
+	location, err := u.initiate(ctx)
+	if err != nil {
+		return err
+	}
+
+	rc, err := layer.Compressed()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	var offset int64
+	buf := make([]byte, u.chunkSize)
+	for {
+		n, err := io.ReadFull(rc, buf)
+		if err == io.EOF {
+			break
+		}
+		if err != nil && err != io.ErrUnexpectedEOF {
+			return fmt.Errorf("reading layer failed: %w", err)
+		}
+
+		location, offset, err = u.uploadChunk(ctx, location, buf[:n], offset)
+		if err != nil {
+			return err
+		}
+	}
+
+	return u.commit(ctx, location, digest)
+}
+
+// uploadChunk uploads the given chunk starting at offset, and retries
+// from the offset reported by the registry on failure. It returns the
+// location of the upload and the offset of the next chunk. An error is
+// returned without retrying if the offset reported by the registry is
+// outside the chunk, as the bytes before the chunk are no longer available.
+func (u *chunkedUploader) uploadChunk(ctx context.Context, location *url.URL, chunk []byte, offset int64) (*url.URL, int64, error) {
+	start := offset
+	end := offset + int64(len(chunk))
+
+	var lastErr error
+	for attempt := 0; attempt <= u.retries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return nil, 0, ctx.Err()
+			case <-time.After(time.Duration(attempt) * u.retryInterval):
+			}
+
+			// Resume from the last offset the registry received.
+			received, current, err := u.status(ctx, location)
+			if err != nil {
+				lastErr = err
+				continue
+			}
+			location = current
+			// The chunk only holds the bytes from start, the upload can not
+			// be resumed if the registry lost previously received bytes.
+			if received < start || received > end {
+				return nil, 0, fmt.Errorf("uploading chunk at offset %d failed: registry received %d bytes, expected between %d and %d",
+					offset, received, start, end)
+			}
+			chunk = chunk[received-start:]
+			start = received
+			if start == end {
+				return location, end, nil
+			}
+		}
+
+		next, err := u.patch(ctx, location, chunk, start)
+		if err == nil {
+			return next, end, nil
+		}
+		lastErr = err
+	}
+	return nil, 0, fmt.Errorf("uploading chunk at offset %d failed after %d retries: %w", offset, u.retries, lastErr)
+}
+
+func (u *chunkedUploader) exists(ctx context.Context, digest gcrv1.Hash) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, u.blobsURL(digest.String()).String(), nil)
+	if err != nil {
+		return false, err
+	}
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	return resp.StatusCode == http.StatusOK, nil
+}
+
+func (u *chunkedUploader) initiate(ctx context.Context) (*url.URL, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.blobsURL("uploads/").String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("initiating upload failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		return nil, fmt.Errorf("initiating upload failed: unexpected status: %s", resp.Status)
+	}
+	return u.location(resp)
+}
+
+func (u *chunkedUploader) patch(ctx context.Context, location *url.URL, chunk []byte, offset int64) (*url.URL, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPatch, location.String(), bytes.NewReader(chunk))
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = int64(len(chunk))
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("Content-Range", fmt.Sprintf("%d-%d", offset, offset+int64(len(chunk))-1))
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		return nil, fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+	return u.location(resp)
+}
+
+// status returns the offset up to which the registry received the upload,
+// and the current location of the upload. Registries may encode the state
+// of the upload in its location, in which case the location of a failed
+// request can no longer be used.
+func (u *chunkedUploader) status(ctx context.Context, location *url.URL) (int64, *url.URL, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, location.String(), nil)
+	if err != nil {
+		return 0, nil, err
+	}
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return 0, nil, fmt.Errorf("retrieving upload status failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		return 0, nil, fmt.Errorf("retrieving upload status failed: unexpected status: %s", resp.Status)
+	}
+
+	current, err := u.location(resp)
+	if err != nil {
+		current = location
+	}
+
+	// The Range header is of the form '0-<last received byte>'.
+	rng := resp.Header.Get("Range")
+	if rng == "" {
+		return 0, current, nil
+	}
+	parts := strings.SplitN(rng, "-", 2)
+	if len(parts) != 2 {
+		return 0, nil, fmt.Errorf("invalid upload range '%s'", rng)
+	}
+	last, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return 0, nil, fmt.Errorf("invalid upload range '%s': %w", rng, err)
+	}
+	return last + 1, current, nil
+}
+
+func (u *chunkedUploader) commit(ctx context.Context, location *url.URL, digest gcrv1.Hash) error {
+	commitURL := *location
+	q := commitURL.Query()
+	q.Set("digest", digest.String())
+	commitURL.RawQuery = q.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, commitURL.String(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("committing upload failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		return fmt.Errorf("committing upload failed: unexpected status: %s", resp.Status)
+	}
+	return nil
+}
+
+// location returns the absolute upload location of the response.
+func (u *chunkedUploader) location(resp *http.Response) (*url.URL, error) {
+	loc, err := resp.Location()
+	if err != nil {
+		return nil, fmt.Errorf("missing upload location: %w", err)
+	}
+	return loc, nil
+}
@@ -0,0 +1,142 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	. "github.com/onsi/gomega"
+)
+
+// flakyTransport fails the response of every other PATCH request, after
+// the request has been received by the registry.
+type flakyTransport struct {
+	patches int32
+}
+
+func (t *flakyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := http.DefaultTransport.RoundTrip(req)
+	if err != nil || req.Method != http.MethodPatch {
+		return resp, err
+	}
+	if atomic.AddInt32(&t.patches, 1)%2 == 1 {
+		resp.Body.Close()
+		return nil, errors.New("connection reset by peer")
+	}
+	return resp, nil
+}
+
+func Test_PushChunked(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	c := NewClient(DefaultOptions())
+	url := fmt.Sprintf("%s/%s:v1", dockerReg, "test-chunked"+randStringRunes(5))
+
+	_, err := c.Push(ctx, url, "testdata/artifact", WithPushChunkedUpload(512, -1))
+	g.Expect(err).ToNot(HaveOccurred())
+
+	tmpDir := t.TempDir()
+	_, err = c.Pull(ctx, url, tmpDir)
+	g.Expect(err).ToNot(HaveOccurred())
+	expected, err := os.ReadFile("testdata/artifact/deployment.yaml")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(os.ReadFile(filepath.Join(tmpDir, "deployment.yaml"))).To(Equal(expected))
+}
+
+func Test_chunkedUploader_resume(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	ft := &flakyTransport{}
+	c := NewClient(append(DefaultOptions(), crane.WithTransport(ft)))
+
+	repo, err := name.NewRepository(fmt.Sprintf("%s/%s", dockerReg, "test-chunked"+randStringRunes(5)))
+	g.Expect(err).ToNot(HaveOccurred())
+	layer, err := random.Layer(4096, "application/octet-stream")
+	g.Expect(err).ToNot(HaveOccurred())
+
+	u, err := c.newChunkedUploader(ctx, repo, 1000, 1)
+	g.Expect(err).ToNot(HaveOccurred())
+	u.retryInterval = time.Millisecond
+	g.Expect(u.upload(ctx, layer)).To(Succeed())
+
+	// The chunks received by the registry despite the failed responses
+	// are not uploaded again.
+	size, err := layer.Size()
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(atomic.LoadInt32(&ft.patches)).To(BeNumerically("==", (size+999)/1000))
+
+	digest, err := layer.Digest()
+	g.Expect(err).ToNot(HaveOccurred())
+	pulled, err := crane.PullLayer(repo.Digest(digest.String()).String())
+	g.Expect(err).ToNot(HaveOccurred())
+	rc, err := pulled.Compressed()
+	g.Expect(err).ToNot(HaveOccurred())
+	defer rc.Close()
+	got, err := io.ReadAll(rc)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	want, err := layer.Compressed()
+	g.Expect(err).ToNot(HaveOccurred())
+	defer want.Close()
+	g.Expect(io.ReadAll(want)).To(Equal(got))
+
+	// Without retries, the upload fails.
+	layer, err = random.Layer(2048, "application/octet-stream")
+	g.Expect(err).ToNot(HaveOccurred())
+	u.retries = 0
+	atomic.StoreInt32(&ft.patches, 0)
+	g.Expect(u.upload(ctx, layer)).ToNot(Succeed())
+}
+
+func Test_chunkedUploader_lostBytes(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPatch:
+			w.WriteHeader(http.StatusInternalServerError)
+		case http.MethodGet:
+			// The registry lost the bytes of the previous chunks.
+			w.Header().Set("Location", r.URL.String())
+			w.Header().Set("Range", "0-99")
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer srv.Close()
+
+	location, err := url.Parse(srv.URL + "/v2/test/blobs/uploads/1")
+	g.Expect(err).ToNot(HaveOccurred())
+	u := &chunkedUploader{client: srv.Client(), retries: 3, retryInterval: time.Millisecond}
+
+	_, _, err = u.uploadChunk(ctx, location, make([]byte, 1000), 1000)
+	g.Expect(err).To(MatchError(ContainSubstring("registry received 100 bytes, expected between 1000 and 2000")))
+}
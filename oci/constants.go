@@ -49,6 +49,28 @@ const (
 	// the date and time on which the OCI artifact was built (RFC 3339).
 	CreatedAnnotation = "org.opencontainers.image.created"
 
+	// TitleAnnotation is the OpenContainers annotation for specifying
+	// the human-readable title of a layer, such as its file name.
+	TitleAnnotation = "org.opencontainers.image.title"
+
+	// EncryptionAnnotation is the Flux annotation for specifying the
+	// encryption scheme used for the content of a layer.
+	EncryptionAnnotation = "io.fluxcd.artifact.encryption"
+
+	// EncryptionRecipientsAnnotation is the Flux annotation for specifying
+	// the comma-separated list of recipients the content of a layer is
+	// encrypted for.
+	EncryptionRecipientsAnnotation = "io.fluxcd.artifact.encryption.recipients"
+
+	// EncryptionAge is the value of the EncryptionAnnotation for layers
+	// encrypted with age (https://age-encryption.org).
+	EncryptionAge = "age"
+
+	// EncryptedMediaTypeSuffix is appended to the media type of encrypted
+	// layers, so that clients unaware of the encryption do not attempt to
+	// extract their content.
+	EncryptedMediaTypeSuffix = "+encrypted"
+
 	// OCIRepositoryPrefix is the prefix used for OCIRepository URLs.
 	OCIRepositoryPrefix = "oci://"
 )
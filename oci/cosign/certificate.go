@@ -0,0 +1,63 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cosign
+
+import (
+	"crypto/x509"
+	"encoding/asn1"
+	"errors"
+)
+
+var (
+	// oidIssuer is the OID of the deprecated Fulcio certificate extension
+	// holding the OIDC issuer as raw string.
+	oidIssuer = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 1}
+	// oidIssuerV2 is the OID of the Fulcio certificate extension holding
+	// the OIDC issuer as DER encoded string.
+	oidIssuerV2 = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 8}
+)
+
+// certificateIdentity returns the OIDC identity embedded in the given
+// Fulcio certificate.
+func certificateIdentity(cert *x509.Certificate) (*Identity, error) {
+	identity := &Identity{}
+	for _, ext := range cert.Extensions {
+		switch {
+		case ext.Id.Equal(oidIssuerV2):
+			var issuer string
+			if _, err := asn1.Unmarshal(ext.Value, &issuer); err != nil {
+				return nil, errors.New("invalid OIDC issuer certificate extension")
+			}
+			identity.Issuer = issuer
+		case ext.Id.Equal(oidIssuer) && identity.Issuer == "":
+			identity.Issuer = string(ext.Value)
+		}
+	}
+	if identity.Issuer == "" {
+		return nil, errors.New("certificate does not hold an OIDC issuer")
+	}
+
+	switch {
+	case len(cert.EmailAddresses) > 0:
+		identity.Subject = cert.EmailAddresses[0]
+	case len(cert.URIs) > 0:
+		identity.Subject = cert.URIs[0].String()
+	default:
+		return nil, errors.New("certificate does not hold an OIDC subject")
+	}
+	return identity, nil
+}
@@ -0,0 +1,299 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cosign
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// DefaultFulcioURL is the URL of the public Sigstore Fulcio instance.
+const DefaultFulcioURL = "https://fulcio.sigstore.dev"
+
+// KeylessSignerOptions holds the options for keyless signing.
+type KeylessSignerOptions struct {
+	// IDToken returns the OIDC ID token identifying the workload, for
+	// example a projected Kubernetes service account token, exchanged at
+	// Fulcio for a short-lived signing certificate.
+	IDToken func(ctx context.Context) (string, error)
+	// FulcioURL is the URL of the Fulcio instance issuing the signing
+	// certificates. Defaults to DefaultFulcioURL.
+	FulcioURL string
+	// RekorURL is the URL of the Rekor instance the signatures are recorded
+	// in. Defaults to DefaultRekorURL.
+	RekorURL string
+	// SkipTlog disables recording the signatures in the transparency log.
+	// The signatures can then only be verified with IgnoreTlog, until their
+	// short-lived certificate expires.
+	SkipTlog bool
+	// HTTPClient is the client used to call Fulcio and Rekor. Defaults to
+	// http.DefaultClient.
+	HTTPClient *http.Client
+}
+
+// KeylessSigner signs payloads with ephemeral keys, certified by Fulcio for
+// the identity of the workload, and records the signatures in Rekor.
+type KeylessSigner struct {
+	opts KeylessSignerOptions
+}
+
+// NewKeylessSigner returns a KeylessSigner for the given options.
+func NewKeylessSigner(opts KeylessSignerOptions) (*KeylessSigner, error) {
+	if opts.IDToken == nil {
+		return nil, errors.New("no ID token source configured")
+	}
+	if opts.FulcioURL == "" {
+		opts.FulcioURL = DefaultFulcioURL
+	}
+	if opts.RekorURL == "" {
+		opts.RekorURL = DefaultRekorURL
+	}
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = http.DefaultClient
+	}
+	return &KeylessSigner{opts: opts}, nil
+}
+
+// SignPayload implements Signer.
+func (s *KeylessSigner) SignPayload(ctx context.Context, payload []byte) (*Signature, error) {
+	idToken, err := s.opts.IDToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	certs, err := s.signingCertificate(ctx, key, idToken)
+	if err != nil {
+		return nil, err
+	}
+
+	digest := sha256.Sum256(payload)
+	sig, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Signature{
+		Signature:   sig,
+		Certificate: []byte(certs[0]),
+		Chain:       []byte(strings.Join(certs[1:], "")),
+	}
+	if !s.opts.SkipTlog {
+		if result.Bundle, err = s.upload(ctx, digest[:], sig, result.Certificate); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// signingCertificate requests a signing certificate for the public key of
+// the given key at Fulcio, and returns the PEM encoded certificate chain.
+func (s *KeylessSigner) signingCertificate(ctx context.Context, key *ecdsa.PrivateKey, idToken string) ([]string, error) {
+	subject, err := tokenSubject(idToken)
+	if err != nil {
+		return nil, err
+	}
+	// The proof of possession of the private key is the signature of the
+	// subject of the ID token.
+	subjectDigest := sha256.Sum256([]byte(subject))
+	proof, err := ecdsa.SignASN1(rand.Reader, key, subjectDigest[:])
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	var req struct {
+		Credentials struct {
+			OIDCIdentityToken string `json:"oidcIdentityToken"`
+		} `json:"credentials"`
+		PublicKeyRequest struct {
+			PublicKey struct {
+				Algorithm string `json:"algorithm"`
+				Content   string `json:"content"`
+			} `json:"publicKey"`
+			ProofOfPossession []byte `json:"proofOfPossession"`
+		} `json:"publicKeyRequest"`
+	}
+	req.Credentials.OIDCIdentityToken = idToken
+	req.PublicKeyRequest.PublicKey.Algorithm = "ECDSA"
+	req.PublicKeyRequest.PublicKey.Content = string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	req.PublicKeyRequest.ProofOfPossession = proof
+
+	var resp struct {
+		SignedCertificateEmbeddedSct *struct {
+			Chain struct {
+				Certificates []string `json:"certificates"`
+			} `json:"chain"`
+		} `json:"signedCertificateEmbeddedSct"`
+		SignedCertificateDetachedSct *struct {
+			Chain struct {
+				Certificates []string `json:"certificates"`
+			} `json:"chain"`
+		} `json:"signedCertificateDetachedSct"`
+	}
+	endpoint := strings.TrimSuffix(s.opts.FulcioURL, "/") + "/api/v2/signingCert"
+	if err := s.post(ctx, endpoint, req, &resp); err != nil {
+		return nil, fmt.Errorf("unable to obtain signing certificate: %w", err)
+	}
+
+	var certs []string
+	switch {
+	case resp.SignedCertificateEmbeddedSct != nil:
+		certs = resp.SignedCertificateEmbeddedSct.Chain.Certificates
+	case resp.SignedCertificateDetachedSct != nil:
+		certs = resp.SignedCertificateDetachedSct.Chain.Certificates
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("unable to obtain signing certificate: no certificates in response")
+	}
+	return certs, nil
+}
+
+// upload records the signature in Rekor as a 'hashedrekord' entry, and
+// returns the Rekor bundle of the entry.
+func (s *KeylessSigner) upload(ctx context.Context, digest, sig, cert []byte) ([]byte, error) {
+	var entry struct {
+		APIVersion string `json:"apiVersion"`
+		Kind       string `json:"kind"`
+		Spec       struct {
+			Data struct {
+				Hash struct {
+					Algorithm string `json:"algorithm"`
+					Value     string `json:"value"`
+				} `json:"hash"`
+			} `json:"data"`
+			Signature struct {
+				Content   []byte `json:"content"`
+				PublicKey struct {
+					Content []byte `json:"content"`
+				} `json:"publicKey"`
+			} `json:"signature"`
+		} `json:"spec"`
+	}
+	entry.APIVersion = "0.0.1"
+	entry.Kind = "hashedrekord"
+	entry.Spec.Data.Hash.Algorithm = "sha256"
+	entry.Spec.Data.Hash.Value = hex.EncodeToString(digest)
+	entry.Spec.Signature.Content = sig
+	entry.Spec.Signature.PublicKey.Content = cert
+
+	var resp map[string]struct {
+		Body           string `json:"body"`
+		IntegratedTime int64  `json:"integratedTime"`
+		LogID          string `json:"logID"`
+		LogIndex       int64  `json:"logIndex"`
+		Verification   struct {
+			SignedEntryTimestamp []byte `json:"signedEntryTimestamp"`
+		} `json:"verification"`
+	}
+	endpoint := strings.TrimSuffix(s.opts.RekorURL, "/") + "/api/v1/log/entries"
+	if err := s.post(ctx, endpoint, entry, &resp); err != nil {
+		return nil, fmt.Errorf("unable to record signature in Rekor: %w", err)
+	}
+	for _, e := range resp {
+		return json.Marshal(rekorBundle{
+			SignedEntryTimestamp: e.Verification.SignedEntryTimestamp,
+			Payload: rekorPayload{
+				Body:           e.Body,
+				IntegratedTime: e.IntegratedTime,
+				LogID:          e.LogID,
+				LogIndex:       e.LogIndex,
+			},
+		})
+	}
+	return nil, errors.New("unable to record signature in Rekor: no entry in response")
+}
+
+// post sends the JSON encoding of the request to the given endpoint, and
+// decodes the JSON response into resp.
+func (s *KeylessSigner) post(ctx context.Context, endpoint string, req, resp interface{}) error {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("Accept", "application/json")
+	return doJSON(s.opts.HTTPClient, request, resp)
+}
+
+// doJSON sends the request with the client and decodes the JSON response
+// into resp.
+func doJSON(client *http.Client, req *http.Request, resp interface{}) error {
+	response, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK && response.StatusCode != http.StatusCreated {
+		b, _ := io.ReadAll(io.LimitReader(response.Body, 1024))
+		return fmt.Errorf("unexpected status: %s: %s", response.Status, strings.TrimSpace(string(b)))
+	}
+	return json.NewDecoder(response.Body).Decode(resp)
+}
+
+// tokenSubject returns the subject of the given ID token, which is its
+// email claim if set, as done by Fulcio. The signature of the token is not
+// verified, as it is verified by Fulcio.
+func tokenSubject(idToken string) (string, error) {
+	parts := strings.Split(idToken, ".")
+	if len(parts) != 3 {
+		return "", errors.New("invalid ID token: not a JWT")
+	}
+	data, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", fmt.Errorf("invalid ID token: %w", err)
+	}
+	var claims struct {
+		Subject string `json:"sub"`
+		Email   string `json:"email"`
+	}
+	if err := json.Unmarshal(data, &claims); err != nil {
+		return "", fmt.Errorf("invalid ID token: %w", err)
+	}
+	if claims.Email != "" {
+		return claims.Email, nil
+	}
+	if claims.Subject == "" {
+		return "", errors.New("invalid ID token: no subject")
+	}
+	return claims.Subject, nil
+}
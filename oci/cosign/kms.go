@@ -0,0 +1,297 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cosign
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/asn1"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/Azure/azure-sdk-for-go/sdk/security/keyvault/azkeys"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	kmstypes "github.com/aws/aws-sdk-go-v2/service/kms/types"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+const (
+	// AWSKMSScheme is the scheme of the AWS KMS key references, in the
+	// format 'awskms:///<key ID, ARN or alias>'.
+	AWSKMSScheme = "awskms"
+	// GCPKMSScheme is the scheme of the GCP KMS key references, in the
+	// format 'gcpkms://projects/<project>/locations/<location>/keyRings/<ring>/cryptoKeys/<key>/cryptoKeyVersions/<version>'.
+	GCPKMSScheme = "gcpkms"
+	// AzureKMSScheme is the scheme of the Azure Key Vault key references,
+	// in the format 'azurekms://<vault host>/<key>[/<version>]'.
+	AzureKMSScheme = "azurekms"
+
+	gcpKMSEndpoint = "https://cloudkms.googleapis.com"
+	gcpKMSScope    = "https://www.googleapis.com/auth/cloudkms"
+)
+
+// KMSAlgorithm is the signing algorithm of a KMS key.
+type KMSAlgorithm string
+
+const (
+	// ECDSAP256SHA256 is the algorithm of ECDSA P-256 keys, the default.
+	ECDSAP256SHA256 KMSAlgorithm = "ecdsa-p256-sha256"
+	// RSAPKCS1v15SHA256 is the algorithm of RSA keys with PKCS #1 v1.5 padding.
+	RSAPKCS1v15SHA256 KMSAlgorithm = "rsa-pkcs1v15-sha256"
+)
+
+// KMSSignerOptions holds the options for signing with KMS keys.
+type KMSSignerOptions struct {
+	// Algorithm is the signing algorithm of the key. Defaults to
+	// ECDSAP256SHA256.
+	Algorithm KMSAlgorithm
+	// AWSConfig is the configuration of the AWS KMS client. Defaults to the
+	// configuration loaded from the default credential chain, e.g. IRSA.
+	AWSConfig *aws.Config
+	// GCPTokenSource is the source of the OAuth 2.0 tokens used to call GCP
+	// KMS. Defaults to the Application Default Credentials, e.g. workload
+	// identity.
+	GCPTokenSource oauth2.TokenSource
+	// AzureCredential is the credential used to call Azure Key Vault.
+	// Defaults to the azidentity.DefaultAzureCredential, e.g. workload
+	// identity.
+	AzureCredential azcore.TokenCredential
+	// AzureClientOptions are the options of the Azure Key Vault client.
+	AzureClientOptions *azkeys.ClientOptions
+	// Endpoint overrides the URL of the KMS API.
+	Endpoint string
+	// HTTPClient is the base client used to call GCP KMS. Defaults to
+	// http.DefaultClient. The AWS and Azure clients are configured with
+	// AWSConfig and AzureClientOptions.
+	HTTPClient *http.Client
+}
+
+// KMSSigner signs payloads with a key stored in a cloud KMS. The signatures
+// are not recorded in the transparency log, they are verified with the
+// public key of the KMS key.
+type KMSSigner struct {
+	provider  string
+	key       string
+	algorithm KMSAlgorithm
+
+	aws         *kms.Client
+	gcp         *http.Client
+	gcpEndpoint string
+	azure       *azkeys.Client
+}
+
+// NewKMSSigner returns a KMSSigner for the key with the given reference, in
+// the format 'awskms:///<key>', 'gcpkms://<key version resource name>' or
+// 'azurekms://<vault host>/<key>[/<version>]'. The clients of the KMS APIs
+// use the default credential chains of the cloud providers, unless the
+// credentials are set in the options.
+func NewKMSSigner(ctx context.Context, keyRef string, opts KMSSignerOptions) (*KMSSigner, error) {
+	provider, key, ok := strings.Cut(keyRef, "://")
+	if !ok || key == "" {
+		return nil, fmt.Errorf("invalid KMS key reference '%s'", keyRef)
+	}
+	if opts.Algorithm == "" {
+		opts.Algorithm = ECDSAP256SHA256
+	}
+	if opts.Algorithm != ECDSAP256SHA256 && opts.Algorithm != RSAPKCS1v15SHA256 {
+		return nil, fmt.Errorf("unsupported KMS algorithm '%s'", opts.Algorithm)
+	}
+
+	s := &KMSSigner{provider: provider, algorithm: opts.Algorithm}
+	switch provider {
+	case AWSKMSScheme:
+		// The key may be prefixed with a custom endpoint, e.g.
+		// 'awskms://localhost:4566/alias/key'.
+		if i := strings.Index(key, "/"); i > 0 && opts.Endpoint == "" {
+			opts.Endpoint = "https://" + key[:i]
+		}
+		key = key[strings.Index(key, "/")+1:]
+		if opts.AWSConfig == nil {
+			cfg, err := config.LoadDefaultConfig(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("failed to load default AWS configuration: %w", err)
+			}
+			opts.AWSConfig = &cfg
+		}
+		s.aws = kms.NewFromConfig(*opts.AWSConfig, func(o *kms.Options) {
+			if region := awsKeyRegion(key); region != "" {
+				o.Region = region
+			}
+			if opts.Endpoint != "" {
+				o.BaseEndpoint = aws.String(opts.Endpoint)
+			}
+		})
+	case GCPKMSScheme:
+		ts := opts.GCPTokenSource
+		if ts == nil {
+			var err error
+			ts, err = google.DefaultTokenSource(ctx, gcpKMSScope)
+			if err != nil {
+				return nil, fmt.Errorf("failed to find default GCP credentials: %w", err)
+			}
+		}
+		base := opts.HTTPClient
+		if base == nil {
+			base = http.DefaultClient
+		}
+		s.gcp = oauth2.NewClient(context.WithValue(ctx, oauth2.HTTPClient, base), ts)
+		s.gcpEndpoint = opts.Endpoint
+		if s.gcpEndpoint == "" {
+			s.gcpEndpoint = gcpKMSEndpoint
+		}
+	case AzureKMSScheme:
+		vault, name, ok := strings.Cut(key, "/")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid KMS key reference '%s': expected '%s://<vault host>/<key>[/<version>]'", keyRef, AzureKMSScheme)
+		}
+		if opts.AzureCredential == nil {
+			cred, err := azidentity.NewDefaultAzureCredential(nil)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create default Azure credential: %w", err)
+			}
+			opts.AzureCredential = cred
+		}
+		endpoint := opts.Endpoint
+		if endpoint == "" {
+			endpoint = "https://" + vault
+		}
+		client, err := azkeys.NewClient(endpoint, opts.AzureCredential, opts.AzureClientOptions)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create Azure Key Vault client: %w", err)
+		}
+		s.azure = client
+	default:
+		return nil, fmt.Errorf("unsupported KMS provider '%s'", provider)
+	}
+	s.key = key
+
+	return s, nil
+}
+
+// SignPayload implements Signer.
+func (s *KMSSigner) SignPayload(ctx context.Context, payload []byte) (*Signature, error) {
+	digest := sha256.Sum256(payload)
+
+	var sig []byte
+	var err error
+	switch s.provider {
+	case AWSKMSScheme:
+		sig, err = s.signAWS(ctx, digest[:])
+	case GCPKMSScheme:
+		sig, err = s.signGCP(ctx, digest[:])
+	case AzureKMSScheme:
+		sig, err = s.signAzure(ctx, digest[:])
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to sign with %s key '%s': %w", s.provider, s.key, err)
+	}
+	return &Signature{Signature: sig}, nil
+}
+
+// signAWS signs the digest with the AWS KMS Sign API.
+func (s *KMSSigner) signAWS(ctx context.Context, digest []byte) ([]byte, error) {
+	algorithm := kmstypes.SigningAlgorithmSpecEcdsaSha256
+	if s.algorithm == RSAPKCS1v15SHA256 {
+		algorithm = kmstypes.SigningAlgorithmSpecRsassaPkcs1V15Sha256
+	}
+	resp, err := s.aws.Sign(ctx, &kms.SignInput{
+		KeyId:            aws.String(s.key),
+		Message:          digest,
+		MessageType:      kmstypes.MessageTypeDigest,
+		SigningAlgorithm: algorithm,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Signature, nil
+}
+
+// signGCP signs the digest with the GCP KMS asymmetricSign API. The
+// request is authenticated by the OAuth 2.0 client of the signer.
+func (s *KMSSigner) signGCP(ctx context.Context, digest []byte) ([]byte, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"digest": map[string][]byte{"sha256": digest},
+	})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		fmt.Sprintf("%s/v1/%s:asymmetricSign", s.gcpEndpoint, s.key), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	var resp struct {
+		Signature []byte `json:"signature"`
+	}
+	if err := doJSON(s.gcp, req, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Signature, nil
+}
+
+// signAzure signs the digest with the Azure Key Vault Sign API.
+func (s *KMSSigner) signAzure(ctx context.Context, digest []byte) ([]byte, error) {
+	_, key, _ := strings.Cut(s.key, "/")
+	name, version, _ := strings.Cut(strings.TrimSuffix(key, "/"), "/")
+
+	algorithm := azkeys.SignatureAlgorithmES256
+	if s.algorithm == RSAPKCS1v15SHA256 {
+		algorithm = azkeys.SignatureAlgorithmRS256
+	}
+	resp, err := s.azure.Sign(ctx, name, version, azkeys.SignParameters{
+		Algorithm: &algorithm,
+		Value:     digest,
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+	sig := resp.Result
+	if s.algorithm == RSAPKCS1v15SHA256 {
+		return sig, nil
+	}
+	// Key Vault returns the ECDSA signatures as the concatenation of r and s,
+	// while cosign expects their ASN.1 DER encoding.
+	if len(sig) == 0 || len(sig)%2 != 0 {
+		return nil, errors.New("invalid ECDSA signature length")
+	}
+	return asn1.Marshal(struct{ R, S *big.Int }{
+		R: new(big.Int).SetBytes(sig[:len(sig)/2]),
+		S: new(big.Int).SetBytes(sig[len(sig)/2:]),
+	})
+}
+
+// awsKeyRegion returns the region of the given key ARN, or an empty string
+// if the key is not an ARN.
+func awsKeyRegion(key string) string {
+	parts := strings.Split(key, ":")
+	if len(parts) < 6 || parts[0] != "arn" {
+		return ""
+	}
+	return parts[3]
+}
@@ -0,0 +1,205 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cosign
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// rekorBundle is the bundle cosign attaches to a signature, which proves
+// the signature was recorded in the Rekor transparency log.
+type rekorBundle struct {
+	SignedEntryTimestamp []byte       `json:"SignedEntryTimestamp"`
+	Payload              rekorPayload `json:"Payload"`
+}
+
+// rekorPayload is the payload of a rekorBundle. Its fields are sorted
+// alphabetically, to marshal it into its canonical form.
+type rekorPayload struct {
+	Body           string `json:"body"`
+	IntegratedTime int64  `json:"integratedTime"`
+	LogID          string `json:"logID"`
+	LogIndex       int64  `json:"logIndex"`
+}
+
+// hashedRekord is the body of a Rekor entry of the 'hashedrekord' kind.
+type hashedRekord struct {
+	Kind string `json:"kind"`
+	Spec struct {
+		Data struct {
+			Hash struct {
+				Algorithm string `json:"algorithm"`
+				Value     string `json:"value"`
+			} `json:"hash"`
+		} `json:"data"`
+		Signature struct {
+			Content   string `json:"content"`
+			PublicKey struct {
+				Content string `json:"content"`
+			} `json:"publicKey"`
+		} `json:"signature"`
+	} `json:"spec"`
+}
+
+// rekorLog is a Rekor transparency log, identified by its public key.
+type rekorLog struct {
+	key   *ecdsa.PublicKey
+	logID string
+}
+
+// newRekorLog returns the rekorLog with the given PEM encoded public key.
+// The ID of the log is the hex encoded SHA-256 digest of its DER encoded
+// public key.
+func newRekorLog(keyPEM []byte) (*rekorLog, error) {
+	key, err := parsePublicKey(keyPEM)
+	if err != nil {
+		return nil, err
+	}
+	ecKey, ok := key.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("unsupported Rekor public key type '%T'", key)
+	}
+	der, err := x509.MarshalPKIXPublicKey(ecKey)
+	if err != nil {
+		return nil, err
+	}
+	id := sha256.Sum256(der)
+	return &rekorLog{key: ecKey, logID: hex.EncodeToString(id[:])}, nil
+}
+
+// verifyBundle verifies that the given bundle was issued by the log, with
+// its signed entry timestamp, and that the entry matches the payload, the
+// signature and the public key of the signing certificate.
+// It returns the time at which the entry was integrated in the log.
+func (l *rekorLog) verifyBundle(data, payload, sig []byte, cert *x509.Certificate) (time.Time, error) {
+	var bundle rekorBundle
+	if err := json.Unmarshal(data, &bundle); err != nil {
+		return time.Time{}, fmt.Errorf("unable to decode bundle: %w", err)
+	}
+	if bundle.Payload.LogID != l.logID {
+		return time.Time{}, fmt.Errorf("bundle log ID '%s' does not match the Rekor public key", bundle.Payload.LogID)
+	}
+
+	canonical, err := json.Marshal(bundle.Payload)
+	if err != nil {
+		return time.Time{}, err
+	}
+	digest := sha256.Sum256(canonical)
+	if !ecdsa.VerifyASN1(l.key, digest[:], bundle.SignedEntryTimestamp) {
+		return time.Time{}, errors.New("invalid signed entry timestamp")
+	}
+
+	body, err := base64.StdEncoding.DecodeString(bundle.Payload.Body)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("unable to decode entry body: %w", err)
+	}
+	var entry hashedRekord
+	if err := json.Unmarshal(body, &entry); err != nil {
+		return time.Time{}, fmt.Errorf("unable to decode entry body: %w", err)
+	}
+	if entry.Kind != "hashedrekord" {
+		return time.Time{}, fmt.Errorf("unsupported entry kind '%s'", entry.Kind)
+	}
+	payloadDigest := sha256.Sum256(payload)
+	if entry.Spec.Data.Hash.Algorithm != "sha256" || entry.Spec.Data.Hash.Value != hex.EncodeToString(payloadDigest[:]) {
+		return time.Time{}, errors.New("entry hash does not match the signature payload")
+	}
+	if entry.Spec.Signature.Content != base64.StdEncoding.EncodeToString(sig) {
+		return time.Time{}, errors.New("entry signature does not match the signature")
+	}
+	keyPEM, err := base64.StdEncoding.DecodeString(entry.Spec.Signature.PublicKey.Content)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("unable to decode entry public key: %w", err)
+	}
+	if !entryKeyMatches(keyPEM, cert) {
+		return time.Time{}, errors.New("entry public key does not match the signing certificate")
+	}
+
+	return time.Unix(bundle.Payload.IntegratedTime, 0), nil
+}
+
+// entryKeyMatches returns whether the PEM encoded certificate or public key
+// of a Rekor entry holds the public key of the given certificate.
+func entryKeyMatches(data []byte, cert *x509.Certificate) bool {
+	block, _ := pem.Decode(bytes.TrimSpace(data))
+	if block == nil {
+		return false
+	}
+	var key crypto.PublicKey
+	switch block.Type {
+	case "CERTIFICATE":
+		c, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return false
+		}
+		key = c.PublicKey
+	case "PUBLIC KEY":
+		k, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return false
+		}
+		key = k
+	default:
+		return false
+	}
+	k, ok := key.(interface{ Equal(crypto.PublicKey) bool })
+	return ok && k.Equal(cert.PublicKey)
+}
+
+// fetchRekorPublicKey retrieves the PEM encoded public key of the Rekor
+// instance at the given URL.
+func fetchRekorPublicKey(ctx context.Context, rekorURL string) ([]byte, error) {
+	endpoint := strings.TrimSuffix(rekorURL, "/") + "/api/v1/log/publicKey"
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch Rekor public key: %w", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unable to fetch Rekor public key: unexpected status: %s", response.Status)
+	}
+	return io.ReadAll(io.LimitReader(response.Body, 1<<20))
+}
+
+// parsePublicKey parses the given PEM encoded PKIX public key.
+func parsePublicKey(data []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(bytes.TrimSpace(data))
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+	return x509.ParsePKIXPublicKey(block.Bytes)
+}
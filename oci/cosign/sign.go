@@ -0,0 +1,169 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cosign
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/name"
+	gcrv1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// Signature holds a cosign signature of a payload.
+type Signature struct {
+	// Signature is the signature of the payload.
+	Signature []byte
+	// Certificate is the PEM encoded signing certificate of keyless
+	// signatures. It is empty for signatures made with a key.
+	Certificate []byte
+	// Chain is the PEM encoded certificate chain of the signing certificate.
+	Chain []byte
+	// Bundle is the Rekor bundle proving the signature was recorded in the
+	// transparency log, if it was.
+	Bundle []byte
+}
+
+// Signer signs the cosign payloads of OCI artifacts.
+type Signer interface {
+	// SignPayload signs the SHA-256 digest of the given payload.
+	SignPayload(ctx context.Context, payload []byte) (*Signature, error)
+}
+
+// KeySigner signs payloads with a crypto.Signer, e.g. a private key loaded
+// in memory or a hardware token.
+type KeySigner struct {
+	signer crypto.Signer
+}
+
+// NewKeySigner returns a KeySigner for the given crypto.Signer.
+func NewKeySigner(signer crypto.Signer) *KeySigner {
+	return &KeySigner{signer: signer}
+}
+
+// SignPayload implements Signer.
+func (s *KeySigner) SignPayload(_ context.Context, payload []byte) (*Signature, error) {
+	digest := sha256.Sum256(payload)
+	sig, err := s.signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+	if err != nil {
+		return nil, err
+	}
+	return &Signature{Signature: sig}, nil
+}
+
+// newPayload returns the simple signing payload of the artifact with the
+// given digest in the given repository.
+func newPayload(repo name.Repository, digest string) ([]byte, error) {
+	var p struct {
+		Critical struct {
+			Identity struct {
+				DockerReference string `json:"docker-reference"`
+			} `json:"identity"`
+			Image struct {
+				DockerManifestDigest string `json:"docker-manifest-digest"`
+			} `json:"image"`
+			Type string `json:"type"`
+		} `json:"critical"`
+		Optional map[string]string `json:"optional"`
+	}
+	p.Critical.Identity.DockerReference = repo.String()
+	p.Critical.Image.DockerManifestDigest = digest
+	p.Critical.Type = "cosign container image signature"
+	return json.Marshal(p)
+}
+
+// Sign signs the artifact at the given URL with the signer, and pushes the
+// signature to the signature tag of the artifact, next to the signatures it
+// may already have. The crane options are used to access the registry.
+// It returns the digest of the signed artifact.
+func Sign(ctx context.Context, url string, signer Signer, craneOpts ...crane.Option) (string, error) {
+	ref, err := name.ParseReference(url)
+	if err != nil {
+		return "", fmt.Errorf("invalid URL: %w", err)
+	}
+
+	options := append([]crane.Option{crane.WithContext(ctx)}, craneOpts...)
+	digest, err := crane.Digest(url, options...)
+	if err != nil {
+		return "", fmt.Errorf("unable to resolve artifact digest: %w", err)
+	}
+	hash, err := gcrv1.NewHash(digest)
+	if err != nil {
+		return "", fmt.Errorf("unable to parse artifact digest: %w", err)
+	}
+
+	payload, err := newPayload(ref.Context(), digest)
+	if err != nil {
+		return "", err
+	}
+	sig, err := signer.SignPayload(ctx, payload)
+	if err != nil {
+		return "", fmt.Errorf("signing failed: %w", err)
+	}
+	if len(sig.Signature) == 0 {
+		return "", errors.New("signing failed: empty signature")
+	}
+
+	annotations := map[string]string{
+		SignatureAnnotation: base64.StdEncoding.EncodeToString(sig.Signature),
+	}
+	if len(sig.Certificate) > 0 {
+		annotations[CertificateAnnotation] = string(sig.Certificate)
+	}
+	if len(sig.Chain) > 0 {
+		annotations[ChainAnnotation] = string(sig.Chain)
+	}
+	if len(sig.Bundle) > 0 {
+		annotations[BundleAnnotation] = string(sig.Bundle)
+	}
+
+	// Append the signature to the existing signatures of the artifact.
+	sigRef := ref.Context().Tag(fmt.Sprintf("%s-%s%s", hash.Algorithm, hash.Hex, SignatureTagSuffix))
+	base, err := crane.Pull(sigRef.String(), options...)
+	if err != nil {
+		var terr *transport.Error
+		if !errors.As(err, &terr) || terr.StatusCode != http.StatusNotFound {
+			return "", fmt.Errorf("unable to fetch signatures from '%s': %w", sigRef, err)
+		}
+		base = mutate.MediaType(mutate.ConfigMediaType(empty.Image, types.OCIConfigJSON), types.OCIManifestSchema1)
+	}
+	img, err := mutate.Append(base, mutate.Addendum{
+		Layer:       static.NewLayer(payload, SimpleSigningMediaType),
+		Annotations: annotations,
+	})
+	if err != nil {
+		return "", err
+	}
+	if err := crane.Push(img, sigRef.String(), options...); err != nil {
+		return "", fmt.Errorf("unable to push signature to '%s': %w", sigRef, err)
+	}
+
+	return ref.Context().Digest(digest).String(), nil
+}
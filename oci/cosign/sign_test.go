@@ -0,0 +1,324 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cosign
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/security/keyvault/azkeys"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	. "github.com/onsi/gomega"
+	"golang.org/x/oauth2"
+)
+
+// fulcioHandler issues signing certificates for the test identity.
+func (s *testSigstore) fulcioHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			PublicKeyRequest struct {
+				PublicKey struct {
+					Content string `json:"content"`
+				} `json:"publicKey"`
+			} `json:"publicKeyRequest"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		pub, err := parsePublicKey([]byte(req.PublicKeyRequest.PublicKey.Content))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		issuer, _ := asn1.Marshal(testIssuer)
+		subject, _ := url.Parse(testSubject)
+		tmpl := &x509.Certificate{
+			SerialNumber:    big.NewInt(3),
+			NotBefore:       time.Now().Add(-time.Minute),
+			NotAfter:        time.Now().Add(9 * time.Minute),
+			KeyUsage:        x509.KeyUsageDigitalSignature,
+			ExtKeyUsage:     []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+			ExtraExtensions: []pkix.Extension{{Id: oidIssuerV2, Value: issuer}},
+			URIs:            []*url.URL{subject},
+		}
+		der, err := x509.CreateCertificate(rand.Reader, tmpl, s.root, pub, s.rootKey)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		cert := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"signedCertificateEmbeddedSct":{"chain":{"certificates":[%q,%q]}}}`, cert, string(s.rootPEM))
+	}
+}
+
+// rekorHandler records entries and returns their signed entry timestamp.
+func (s *testSigstore) rekorHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var entry json.RawMessage
+		if err := json.NewDecoder(r.Body).Decode(&entry); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		payload := rekorPayload{
+			Body:           base64.StdEncoding.EncodeToString(entry),
+			IntegratedTime: time.Now().Unix(),
+			LogID:          s.logID,
+			LogIndex:       1,
+		}
+		canonical, _ := json.Marshal(payload)
+		digest := sha256.Sum256(canonical)
+		set, err := ecdsa.SignASN1(rand.Reader, s.rekorKey, digest[:])
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"uuid": map[string]interface{}{
+				"body":           payload.Body,
+				"integratedTime": payload.IntegratedTime,
+				"logID":          payload.LogID,
+				"logIndex":       payload.LogIndex,
+				"verification":   map[string][]byte{"signedEntryTimestamp": set},
+			},
+		})
+	}
+}
+
+type fakeTokenCredential struct{}
+
+func (fakeTokenCredential) GetToken(context.Context, policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	return azcore.AccessToken{Token: "token", ExpiresOn: time.Now().Add(time.Hour)}, nil
+}
+
+func testIDToken(claims string) string {
+	enc := base64.RawURLEncoding
+	return enc.EncodeToString([]byte(`{"alg":"RS256"}`)) + "." + enc.EncodeToString([]byte(claims)) + ".sig"
+}
+
+func TestSign_Keyless(t *testing.T) {
+	g := NewWithT(t)
+
+	reg := httptest.NewServer(registry.New())
+	defer reg.Close()
+	sigstore := newTestSigstore(t)
+	fulcio := httptest.NewServer(sigstore.fulcioHandler())
+	defer fulcio.Close()
+	rekor := httptest.NewServer(sigstore.rekorHandler())
+	defer rekor.Close()
+
+	url := strings.TrimPrefix(reg.URL, "http://") + "/keyless:v1"
+	img, err := random.Image(256, 1)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(crane.Push(img, url)).To(Succeed())
+
+	signer, err := NewKeylessSigner(KeylessSignerOptions{
+		IDToken: func(context.Context) (string, error) {
+			return testIDToken(`{"sub":"` + testSubject + `"}`), nil
+		},
+		FulcioURL: fulcio.URL,
+		RekorURL:  rekor.URL,
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+
+	// Sign twice to check the signatures are appended.
+	digest, err := Sign(context.TODO(), url, signer)
+	g.Expect(err).ToNot(HaveOccurred())
+	_, err = Sign(context.TODO(), url, signer)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	v, err := NewKeylessVerifier(context.TODO(), KeylessOptions{
+		FulcioRoots:    sigstore.rootPEM,
+		RekorPublicKey: sigstore.rekorPEM,
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+	result, err := v.Verify(context.TODO(), url)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(result.Digest).To(Equal(digest))
+	g.Expect(result.Identity).To(Equal(Identity{Issuer: testIssuer, Subject: testSubject}))
+
+	h, err := crane.Digest(url)
+	g.Expect(err).ToNot(HaveOccurred())
+	sigImg, err := crane.Pull(strings.Replace(url, ":v1", ":"+strings.Replace(h, ":", "-", 1)+SignatureTagSuffix, 1))
+	g.Expect(err).ToNot(HaveOccurred())
+	layers, err := sigImg.Layers()
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(layers).To(HaveLen(2))
+}
+
+func TestKMSSigner(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload := []byte("payload")
+	digest := sha256.Sum256(payload)
+
+	t.Run("AWS", func(t *testing.T) {
+		g := NewWithT(t)
+
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			g.Expect(r.Header.Get("X-Amz-Target")).To(Equal("TrentService.Sign"))
+			g.Expect(r.Header.Get("Authorization")).To(ContainSubstring("/eu-west-1/kms/aws4_request"))
+			var req struct {
+				KeyId            string
+				Message          []byte
+				MessageType      string
+				SigningAlgorithm string
+			}
+			g.Expect(json.NewDecoder(r.Body).Decode(&req)).To(Succeed())
+			g.Expect(req.KeyId).To(Equal("arn:aws:kms:eu-west-1:123456789012:key/1234"))
+			g.Expect(req.MessageType).To(Equal("DIGEST"))
+			g.Expect(req.SigningAlgorithm).To(Equal("ECDSA_SHA_256"))
+			sig, err := ecdsa.SignASN1(rand.Reader, key, req.Message)
+			g.Expect(err).ToNot(HaveOccurred())
+			json.NewEncoder(w).Encode(map[string][]byte{"Signature": sig})
+		}))
+		defer srv.Close()
+
+		signer, err := NewKMSSigner(context.TODO(), "awskms:///arn:aws:kms:eu-west-1:123456789012:key/1234", KMSSignerOptions{
+			AWSConfig: &aws.Config{
+				Region:      "us-east-1",
+				Credentials: credentials.NewStaticCredentialsProvider("id", "secret", ""),
+			},
+			Endpoint: srv.URL,
+		})
+		g.Expect(err).ToNot(HaveOccurred())
+		sig, err := signer.SignPayload(context.TODO(), payload)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(ecdsa.VerifyASN1(&key.PublicKey, digest[:], sig.Signature)).To(BeTrue())
+	})
+
+	t.Run("GCP", func(t *testing.T) {
+		g := NewWithT(t)
+
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			g.Expect(r.URL.Path).To(Equal("/v1/projects/p/locations/l/keyRings/r/cryptoKeys/k/cryptoKeyVersions/1:asymmetricSign"))
+			g.Expect(r.Header.Get("Authorization")).To(Equal("Bearer token"))
+			var req struct {
+				Digest struct {
+					SHA256 []byte `json:"sha256"`
+				} `json:"digest"`
+			}
+			g.Expect(json.NewDecoder(r.Body).Decode(&req)).To(Succeed())
+			sig, err := ecdsa.SignASN1(rand.Reader, key, req.Digest.SHA256)
+			g.Expect(err).ToNot(HaveOccurred())
+			json.NewEncoder(w).Encode(map[string][]byte{"signature": sig})
+		}))
+		defer srv.Close()
+
+		signer, err := NewKMSSigner(context.TODO(), "gcpkms://projects/p/locations/l/keyRings/r/cryptoKeys/k/cryptoKeyVersions/1", KMSSignerOptions{
+			GCPTokenSource: oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "token"}),
+			Endpoint:       srv.URL,
+		})
+		g.Expect(err).ToNot(HaveOccurred())
+		sig, err := signer.SignPayload(context.TODO(), payload)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(ecdsa.VerifyASN1(&key.PublicKey, digest[:], sig.Signature)).To(BeTrue())
+	})
+
+	t.Run("Azure", func(t *testing.T) {
+		g := NewWithT(t)
+
+		srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Key Vault challenges the unauthenticated requests for the
+			// tenant and the scope of the token.
+			if r.Header.Get("Authorization") == "" {
+				w.Header().Set("WWW-Authenticate", `Bearer authorization="https://login.microsoftonline.com/tenant", resource="https://vault.azure.net"`)
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			g.Expect(r.Header.Get("Authorization")).To(Equal("Bearer token"))
+			g.Expect(r.URL.Path).To(Equal("/keys/cosign/sign"))
+			var req struct {
+				Alg   string `json:"alg"`
+				Value string `json:"value"`
+			}
+			g.Expect(json.NewDecoder(r.Body).Decode(&req)).To(Succeed())
+			g.Expect(req.Alg).To(Equal("ES256"))
+			d, err := base64.RawURLEncoding.DecodeString(req.Value)
+			g.Expect(err).ToNot(HaveOccurred())
+			r1, s1, err := ecdsa.Sign(rand.Reader, key, d)
+			g.Expect(err).ToNot(HaveOccurred())
+			raw := append(r1.FillBytes(make([]byte, 32)), s1.FillBytes(make([]byte, 32))...)
+			json.NewEncoder(w).Encode(map[string]string{"kid": "cosign", "value": base64.RawURLEncoding.EncodeToString(raw)})
+		}))
+		defer srv.Close()
+
+		signer, err := NewKMSSigner(context.TODO(), "azurekms://vault.vault.azure.net/cosign", KMSSignerOptions{
+			AzureCredential: fakeTokenCredential{},
+			AzureClientOptions: &azkeys.ClientOptions{
+				ClientOptions:                        azcore.ClientOptions{Transport: srv.Client()},
+				DisableChallengeResourceVerification: true,
+			},
+			Endpoint: srv.URL,
+		})
+		g.Expect(err).ToNot(HaveOccurred())
+		sig, err := signer.SignPayload(context.TODO(), payload)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(ecdsa.VerifyASN1(&key.PublicKey, digest[:], sig.Signature)).To(BeTrue())
+	})
+
+	t.Run("invalid Azure key reference", func(t *testing.T) {
+		g := NewWithT(t)
+
+		_, err := NewKMSSigner(context.TODO(), "azurekms://vault.vault.azure.net", KMSSignerOptions{
+			AzureCredential: fakeTokenCredential{},
+		})
+		g.Expect(err).To(MatchError(ContainSubstring("expected 'azurekms://<vault host>/<key>[/<version>]'")))
+	})
+
+	t.Run("unsupported provider", func(t *testing.T) {
+		g := NewWithT(t)
+
+		_, err := NewKMSSigner(context.TODO(), "hashivault://key", KMSSignerOptions{})
+		g.Expect(err).To(MatchError(ContainSubstring("unsupported KMS provider")))
+	})
+}
+
+func Test_awsKeyRegion(t *testing.T) {
+	g := NewWithT(t)
+
+	g.Expect(awsKeyRegion("arn:aws:kms:eu-west-1:123456789012:key/1234")).To(Equal("eu-west-1"))
+	g.Expect(awsKeyRegion("alias/cosign")).To(BeEmpty())
+}
@@ -0,0 +1,388 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cosign provides means to verify the Sigstore cosign signatures
+// of OCI artifacts.
+package cosign
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/name"
+	gcrv1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+const (
+	// SignatureTagSuffix is the suffix of the tag cosign stores the
+	// signatures of an artifact at.
+	SignatureTagSuffix = ".sig"
+
+	// SimpleSigningMediaType is the media type of the layers holding
+	// cosign signature payloads.
+	SimpleSigningMediaType = "application/vnd.dev.cosign.simplesigning.v1+json"
+
+	// SignatureAnnotation is the layer annotation holding the base64
+	// encoded signature of the payload.
+	SignatureAnnotation = "dev.cosignproject.cosign/signature"
+	// CertificateAnnotation is the layer annotation holding the PEM encoded
+	// signing certificate.
+	CertificateAnnotation = "dev.sigstore.cosign/certificate"
+	// ChainAnnotation is the layer annotation holding the PEM encoded
+	// certificate chain of the signing certificate.
+	ChainAnnotation = "dev.sigstore.cosign/chain"
+	// BundleAnnotation is the layer annotation holding the Rekor bundle.
+	BundleAnnotation = "dev.sigstore.cosign/bundle"
+
+	// DefaultRekorURL is the URL of the public Sigstore Rekor instance.
+	DefaultRekorURL = "https://rekor.sigstore.dev"
+)
+
+// ErrNoMatchingSignature is returned when none of the signatures of an
+// artifact could be verified.
+var ErrNoMatchingSignature = errors.New("no matching signatures were found")
+
+// IdentityMatcher holds the regular expressions the OIDC issuer and subject
+// of a keyless signing certificate must match.
+type IdentityMatcher struct {
+	// IssuerRegExp is the regular expression the OIDC issuer must match.
+	IssuerRegExp string
+	// SubjectRegExp is the regular expression the OIDC subject (for
+	// example, an email address or a workflow URI) must match.
+	SubjectRegExp string
+}
+
+// KeylessOptions holds the options for verifying keyless signatures.
+type KeylessOptions struct {
+	// Identities is the list of identities a signing certificate is matched
+	// against. A certificate must match at least one of them. If empty, any
+	// identity is accepted.
+	Identities []IdentityMatcher
+	// FulcioRoots holds the PEM encoded root (and intermediate) certificates
+	// of the Fulcio instance which issued the signing certificates.
+	FulcioRoots []byte
+	// RekorURL is the URL of the Rekor instance the signatures are recorded
+	// in, used to retrieve its public key when RekorPublicKey is not set.
+	// Defaults to DefaultRekorURL.
+	RekorURL string
+	// RekorPublicKey holds the PEM encoded public key of the Rekor instance.
+	// It can be set for air-gapped Sigstore deployments, to avoid retrieving
+	// it from RekorURL.
+	RekorPublicKey []byte
+	// IgnoreTlog disables the verification of the transparency log entry.
+	// Without the time at which the entry was integrated in the log, the
+	// certificate is verified at the current time. Since keyless
+	// certificates are short-lived, the signatures are then rejected once
+	// their certificate expired.
+	IgnoreTlog bool
+}
+
+// Identity is the identity of a keyless signing certificate.
+type Identity struct {
+	// Issuer is the OIDC issuer of the identity.
+	Issuer string
+	// Subject is the OIDC subject of the identity.
+	Subject string
+}
+
+// Result holds the outcome of a successful verification.
+type Result struct {
+	// Digest is the digest of the verified artifact.
+	Digest string
+	// Identity is the identity of the verified signing certificate.
+	Identity Identity
+	// Certificate is the verified signing certificate.
+	Certificate *x509.Certificate
+}
+
+// Verifier verifies the keyless cosign signatures of OCI artifacts.
+//
+// The signing certificates are bound to the signatures by their Rekor entry.
+// The signed certificate timestamps embedded by Fulcio in the certificates
+// are not verified, so the certificates are not checked against the
+// certificate transparency log.
+type Verifier struct {
+	options    []crane.Option
+	roots      *x509.CertPool
+	identities []identityMatcher
+	rekor      *rekorLog
+	ignoreTlog bool
+}
+
+type identityMatcher struct {
+	issuer  *regexp.Regexp
+	subject *regexp.Regexp
+}
+
+// NewKeylessVerifier returns a Verifier for the given options. The crane
+// options are used when fetching signatures from the registry.
+func NewKeylessVerifier(ctx context.Context, opts KeylessOptions, craneOpts ...crane.Option) (*Verifier, error) {
+	v := &Verifier{
+		options:    craneOpts,
+		roots:      x509.NewCertPool(),
+		ignoreTlog: opts.IgnoreTlog,
+	}
+
+	if !v.roots.AppendCertsFromPEM(opts.FulcioRoots) {
+		return nil, errors.New("no valid Fulcio root certificates configured")
+	}
+
+	for _, id := range opts.Identities {
+		issuer, err := regexp.Compile(id.IssuerRegExp)
+		if err != nil {
+			return nil, fmt.Errorf("invalid issuer expression '%s': %w", id.IssuerRegExp, err)
+		}
+		subject, err := regexp.Compile(id.SubjectRegExp)
+		if err != nil {
+			return nil, fmt.Errorf("invalid subject expression '%s': %w", id.SubjectRegExp, err)
+		}
+		v.identities = append(v.identities, identityMatcher{issuer: issuer, subject: subject})
+	}
+
+	if !opts.IgnoreTlog {
+		keyPEM := opts.RekorPublicKey
+		if len(keyPEM) == 0 {
+			rekorURL := opts.RekorURL
+			if rekorURL == "" {
+				rekorURL = DefaultRekorURL
+			}
+			var err error
+			if keyPEM, err = fetchRekorPublicKey(ctx, rekorURL); err != nil {
+				return nil, err
+			}
+		}
+		rekor, err := newRekorLog(keyPEM)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Rekor public key: %w", err)
+		}
+		v.rekor = rekor
+	}
+
+	return v, nil
+}
+
+// Verify verifies the signatures of the artifact at the given URL, and
+// returns the result of the first signature which could be verified.
+// It returns ErrNoMatchingSignature if none of the signatures could be
+// verified.
+func (v *Verifier) Verify(ctx context.Context, url string) (*Result, error) {
+	ref, err := name.ParseReference(url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL: %w", err)
+	}
+
+	options := append([]crane.Option{crane.WithContext(ctx)}, v.options...)
+	digest, err := crane.Digest(url, options...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to resolve artifact digest: %w", err)
+	}
+	hash, err := gcrv1.NewHash(digest)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse artifact digest: %w", err)
+	}
+
+	sigRef := ref.Context().Tag(fmt.Sprintf("%s-%s%s", hash.Algorithm, hash.Hex, SignatureTagSuffix))
+	sigImg, err := crane.Pull(sigRef.String(), options...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch signatures from '%s': %w", sigRef, err)
+	}
+	manifest, err := sigImg.Manifest()
+	if err != nil {
+		return nil, fmt.Errorf("parsing signatures manifest failed: %w", err)
+	}
+
+	var errs []error
+	for _, desc := range manifest.Layers {
+		if desc.MediaType != SimpleSigningMediaType {
+			continue
+		}
+		layer, err := sigImg.LayerByDigest(desc.Digest)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		result, err := v.verifyLayer(layer, desc, digest)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("signature '%s': %w", desc.Digest, err))
+			continue
+		}
+		result.Digest = ref.Context().Digest(digest).String()
+		return result, nil
+	}
+
+	return nil, fmt.Errorf("%w for '%s': %w", ErrNoMatchingSignature, url, errors.Join(errs...))
+}
+
+// signaturePayload is the simple signing payload signed by cosign.
+type signaturePayload struct {
+	Critical struct {
+		Image struct {
+			DockerManifestDigest string `json:"docker-manifest-digest"`
+		} `json:"image"`
+	} `json:"critical"`
+}
+
+// verifyLayer verifies the signature held by the given layer for the
+// artifact with the given digest.
+func (v *Verifier) verifyLayer(layer gcrv1.Layer, desc gcrv1.Descriptor, digest string) (*Result, error) {
+	rc, err := layer.Uncompressed()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	payload, err := io.ReadAll(rc)
+	if err != nil {
+		return nil, err
+	}
+
+	var p signaturePayload
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return nil, fmt.Errorf("unable to decode payload: %w", err)
+	}
+	if p.Critical.Image.DockerManifestDigest != digest {
+		return nil, fmt.Errorf("payload digest '%s' does not match artifact digest '%s'",
+			p.Critical.Image.DockerManifestDigest, digest)
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(desc.Annotations[SignatureAnnotation])
+	if err != nil || len(sig) == 0 {
+		return nil, errors.New("missing or invalid signature annotation")
+	}
+	certs, err := parseCertificates([]byte(desc.Annotations[CertificateAnnotation]))
+	if err != nil || len(certs) == 0 {
+		return nil, errors.New("missing or invalid certificate annotation")
+	}
+	cert := certs[0]
+	intermediates, _ := parseCertificates([]byte(desc.Annotations[ChainAnnotation]))
+
+	if err := verifySignature(cert.PublicKey, payload, sig); err != nil {
+		return nil, err
+	}
+
+	// Keyless certificates are only valid for a few minutes, they must be
+	// verified at the time the signature was recorded in the transparency log.
+	// Without it, they can only be verified at the current time.
+	verifyAt := time.Now()
+	if !v.ignoreTlog {
+		bundle := desc.Annotations[BundleAnnotation]
+		if bundle == "" {
+			return nil, errors.New("missing Rekor bundle annotation")
+		}
+		integratedTime, err := v.rekor.verifyBundle([]byte(bundle), payload, sig, cert)
+		if err != nil {
+			return nil, fmt.Errorf("unable to verify Rekor bundle: %w", err)
+		}
+		verifyAt = integratedTime
+	}
+
+	identity, err := v.verifyCertificate(cert, intermediates, verifyAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Result{
+		Identity:    *identity,
+		Certificate: cert,
+	}, nil
+}
+
+// verifyCertificate verifies the certificate chains up to the Fulcio roots
+// at the given time, and that its identity matches one of the configured
+// identities.
+func (v *Verifier) verifyCertificate(cert *x509.Certificate, intermediates []*x509.Certificate, at time.Time) (*Identity, error) {
+	pool := x509.NewCertPool()
+	for _, c := range intermediates {
+		pool.AddCert(c)
+	}
+	if _, err := cert.Verify(x509.VerifyOptions{
+		Roots:         v.roots,
+		Intermediates: pool,
+		CurrentTime:   at,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+	}); err != nil {
+		return nil, fmt.Errorf("unable to verify certificate: %w", err)
+	}
+
+	identity, err := certificateIdentity(cert)
+	if err != nil {
+		return nil, err
+	}
+	if len(v.identities) == 0 {
+		return identity, nil
+	}
+	for _, m := range v.identities {
+		if m.issuer.MatchString(identity.Issuer) && m.subject.MatchString(identity.Subject) {
+			return identity, nil
+		}
+	}
+	return nil, fmt.Errorf("certificate identity (issuer '%s', subject '%s') does not match any of the configured identities",
+		identity.Issuer, identity.Subject)
+}
+
+func verifySignature(key crypto.PublicKey, payload, sig []byte) error {
+	digest := sha256.Sum256(payload)
+	switch k := key.(type) {
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(k, digest[:], sig) {
+			return errors.New("invalid signature")
+		}
+	case *rsa.PublicKey:
+		if err := rsa.VerifyPKCS1v15(k, crypto.SHA256, digest[:], sig); err != nil {
+			return fmt.Errorf("invalid signature: %w", err)
+		}
+	case ed25519.PublicKey:
+		if !ed25519.Verify(k, payload, sig) {
+			return errors.New("invalid signature")
+		}
+	default:
+		return fmt.Errorf("unsupported public key type '%T'", key)
+	}
+	return nil
+}
+
+func parseCertificates(data []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+	return certs, nil
+}
@@ -0,0 +1,341 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cosign
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/registry"
+	gcrv1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	. "github.com/onsi/gomega"
+)
+
+const (
+	testIssuer  = "https://token.actions.githubusercontent.com"
+	testSubject = "https://github.com/fluxcd/flux2/.github/workflows/release.yaml@refs/tags/v2.0.0"
+)
+
+type testSigstore struct {
+	rootKey   *ecdsa.PrivateKey
+	root      *x509.Certificate
+	rootPEM   []byte
+	rekorKey  *ecdsa.PrivateKey
+	rekorPEM  []byte
+	logID     string
+	signerKey *ecdsa.PrivateKey
+	signer    *x509.Certificate
+}
+
+func newTestSigstore(t *testing.T) *testSigstore {
+	g := NewWithT(t)
+	s := &testSigstore{}
+
+	var err error
+	s.rootKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	g.Expect(err).ToNot(HaveOccurred())
+	rootTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "fulcio"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, rootTmpl, rootTmpl, &s.rootKey.PublicKey, s.rootKey)
+	g.Expect(err).ToNot(HaveOccurred())
+	s.root, err = x509.ParseCertificate(der)
+	g.Expect(err).ToNot(HaveOccurred())
+	s.rootPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+
+	s.rekorKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	g.Expect(err).ToNot(HaveOccurred())
+	pub, err := x509.MarshalPKIXPublicKey(&s.rekorKey.PublicKey)
+	g.Expect(err).ToNot(HaveOccurred())
+	s.rekorPEM = pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pub})
+	logID := sha256.Sum256(pub)
+	s.logID = hex.EncodeToString(logID[:])
+
+	s.issueSigner(t, time.Now().Add(-time.Minute))
+	return s
+}
+
+// issueSigner issues a new signing certificate, valid for ten minutes from
+// the given time.
+func (s *testSigstore) issueSigner(t *testing.T, notBefore time.Time) {
+	g := NewWithT(t)
+
+	var err error
+	s.signerKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	g.Expect(err).ToNot(HaveOccurred())
+	issuer, err := asn1.Marshal(testIssuer)
+	g.Expect(err).ToNot(HaveOccurred())
+	signerTmpl := &x509.Certificate{
+		SerialNumber:    big.NewInt(2),
+		NotBefore:       notBefore,
+		NotAfter:        notBefore.Add(10 * time.Minute),
+		KeyUsage:        x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:     []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+		ExtraExtensions: []pkix.Extension{{Id: oidIssuerV2, Value: issuer}},
+	}
+	subject, err := url.Parse(testSubject)
+	g.Expect(err).ToNot(HaveOccurred())
+	signerTmpl.URIs = []*url.URL{subject}
+	der, err := x509.CreateCertificate(rand.Reader, signerTmpl, s.root, &s.signerKey.PublicKey, s.rootKey)
+	g.Expect(err).ToNot(HaveOccurred())
+	s.signer, err = x509.ParseCertificate(der)
+	g.Expect(err).ToNot(HaveOccurred())
+}
+
+// tampering is a modification of the Rekor bundle of a test signature.
+type tampering int
+
+const (
+	noTampering tampering = iota
+	// tamperEntryTimestamp modifies the bundle after it was signed.
+	tamperEntryTimestamp
+	// tamperLogID records the entry in another log.
+	tamperLogID
+	// tamperPublicKey records the entry with another public key.
+	tamperPublicKey
+)
+
+// sign attaches a cosign signature for the artifact at the given URL.
+func (s *testSigstore) sign(t *testing.T, repo, tag string, tamper tampering) {
+	g := NewWithT(t)
+
+	digest, err := crane.Digest(repo + ":" + tag)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	payload := []byte(fmt.Sprintf(`{"critical":{"identity":{"docker-reference":"%s"},"image":{"docker-manifest-digest":"%s"},"type":"cosign container image signature"},"optional":null}`, repo, digest))
+	payloadDigest := sha256.Sum256(payload)
+	sig, err := ecdsa.SignASN1(rand.Reader, s.signerKey, payloadDigest[:])
+	g.Expect(err).ToNot(HaveOccurred())
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: s.signer.Raw})
+	entryKey := certPEM
+	if tamper == tamperPublicKey {
+		entryKey = s.rootPEM
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"apiVersion": "0.0.1",
+		"kind":       "hashedrekord",
+		"spec": map[string]interface{}{
+			"data": map[string]interface{}{"hash": map[string]string{"algorithm": "sha256", "value": hex.EncodeToString(payloadDigest[:])}},
+			"signature": map[string]interface{}{
+				"content":   base64.StdEncoding.EncodeToString(sig),
+				"publicKey": map[string]string{"content": base64.StdEncoding.EncodeToString(entryKey)},
+			},
+		},
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+	bundle := rekorBundle{
+		Payload: rekorPayload{
+			Body:           base64.StdEncoding.EncodeToString(body),
+			IntegratedTime: time.Now().Unix(),
+			LogID:          s.logID,
+			LogIndex:       1,
+		},
+	}
+	if tamper == tamperLogID {
+		bundle.Payload.LogID = strings.Repeat("0", len(s.logID))
+	}
+	canonical, err := json.Marshal(bundle.Payload)
+	g.Expect(err).ToNot(HaveOccurred())
+	canonicalDigest := sha256.Sum256(canonical)
+	bundle.SignedEntryTimestamp, err = ecdsa.SignASN1(rand.Reader, s.rekorKey, canonicalDigest[:])
+	g.Expect(err).ToNot(HaveOccurred())
+	if tamper == tamperEntryTimestamp {
+		bundle.Payload.LogIndex = 2
+	}
+	bundleJSON, err := json.Marshal(bundle)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	img, err := mutate.Append(empty.Image, mutate.Addendum{
+		Layer: static.NewLayer(payload, SimpleSigningMediaType),
+		Annotations: map[string]string{
+			SignatureAnnotation:   base64.StdEncoding.EncodeToString(sig),
+			CertificateAnnotation: string(certPEM),
+			ChainAnnotation:       string(s.rootPEM),
+			BundleAnnotation:      string(bundleJSON),
+		},
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+
+	h, err := gcrv1.NewHash(digest)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(crane.Push(img, fmt.Sprintf("%s:%s-%s%s", repo, h.Algorithm, h.Hex, SignatureTagSuffix))).To(Succeed())
+}
+
+func TestVerifier_Verify(t *testing.T) {
+	srv := httptest.NewServer(registry.New())
+	defer srv.Close()
+	host := strings.TrimPrefix(srv.URL, "http://")
+
+	sigstore := newTestSigstore(t)
+
+	tests := []struct {
+		name     string
+		opts     KeylessOptions
+		tamper   tampering
+		expired  bool
+		unsigned bool
+		wantErr  string
+	}{
+		{
+			name: "matching identity",
+			opts: KeylessOptions{
+				Identities: []IdentityMatcher{
+					{IssuerRegExp: "^https://gitlab.com$", SubjectRegExp: ".*"},
+					{IssuerRegExp: "^https://token.actions.githubusercontent.com$", SubjectRegExp: "^https://github.com/fluxcd/.*$"},
+				},
+			},
+		},
+		{
+			name: "any identity",
+			opts: KeylessOptions{},
+		},
+		{
+			name: "mismatching identity",
+			opts: KeylessOptions{
+				Identities: []IdentityMatcher{
+					{IssuerRegExp: "^https://token.actions.githubusercontent.com$", SubjectRegExp: "^https://github.com/stefanprodan/.*$"},
+				},
+			},
+			wantErr: "does not match any of the configured identities",
+		},
+		{
+			name:    "tampered bundle",
+			tamper:  tamperEntryTimestamp,
+			wantErr: "invalid signed entry timestamp",
+		},
+		{
+			name:    "bundle of another log",
+			tamper:  tamperLogID,
+			wantErr: "does not match the Rekor public key",
+		},
+		{
+			name:    "entry of another public key",
+			tamper:  tamperPublicKey,
+			wantErr: "entry public key does not match the signing certificate",
+		},
+		{
+			name:   "tampered bundle with tlog ignored",
+			opts:   KeylessOptions{IgnoreTlog: true},
+			tamper: tamperEntryTimestamp,
+		},
+		{
+			name:    "expired certificate with tlog ignored",
+			opts:    KeylessOptions{IgnoreTlog: true},
+			expired: true,
+			wantErr: "certificate has expired",
+		},
+		{
+			name:     "unsigned artifact",
+			unsigned: true,
+			wantErr:  "unable to fetch signatures",
+		},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			repo := fmt.Sprintf("%s/test%d", host, i)
+			url := repo + ":v1"
+			img, err := random.Image(256, 1)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(crane.Push(img, url)).To(Succeed())
+			sigstore := sigstore
+			if tt.expired {
+				sigstore = newTestSigstore(t)
+				sigstore.issueSigner(t, time.Now().Add(-time.Hour))
+			}
+			if !tt.unsigned {
+				sigstore.sign(t, repo, "v1", tt.tamper)
+			}
+
+			opts := tt.opts
+			opts.FulcioRoots = sigstore.rootPEM
+			opts.RekorPublicKey = sigstore.rekorPEM
+			v, err := NewKeylessVerifier(context.TODO(), opts)
+			g.Expect(err).ToNot(HaveOccurred())
+
+			result, err := v.Verify(context.TODO(), url)
+			if tt.wantErr != "" {
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(err.Error()).To(ContainSubstring(tt.wantErr))
+				if !tt.unsigned {
+					g.Expect(errors.Is(err, ErrNoMatchingSignature)).To(BeTrue())
+				}
+				return
+			}
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(result.Identity).To(Equal(Identity{Issuer: testIssuer, Subject: testSubject}))
+			g.Expect(result.Certificate.Equal(sigstore.signer)).To(BeTrue())
+			digest, err := crane.Digest(url)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(result.Digest).To(HaveSuffix("@" + digest))
+		})
+	}
+}
+
+func TestNewKeylessVerifier(t *testing.T) {
+	g := NewWithT(t)
+
+	sigstore := newTestSigstore(t)
+
+	_, err := NewKeylessVerifier(context.TODO(), KeylessOptions{IgnoreTlog: true})
+	g.Expect(err).To(MatchError("no valid Fulcio root certificates configured"))
+
+	_, err = NewKeylessVerifier(context.TODO(), KeylessOptions{
+		FulcioRoots: sigstore.rootPEM,
+		Identities:  []IdentityMatcher{{IssuerRegExp: "(", SubjectRegExp: ".*"}},
+		IgnoreTlog:  true,
+	})
+	g.Expect(err).To(MatchError(ContainSubstring("invalid issuer expression")))
+
+	_, err = NewKeylessVerifier(context.TODO(), KeylessOptions{
+		FulcioRoots:    sigstore.rootPEM,
+		RekorPublicKey: []byte("invalid"),
+	})
+	g.Expect(err).To(MatchError(ContainSubstring("invalid Rekor public key")))
+}
@@ -49,7 +49,8 @@ func IsAccessDenied(e error) bool {
 
 // Authorization is an ACL helper for asserting access to cross-namespace references.
 type Authorization struct {
-	client client.Client
+	client    client.Client
+	auditSink AuditSink
 }
 
 // NewAuthorization takes a controller runtime client and returns an Authorization object that allows asserting
@@ -70,8 +71,10 @@ func (a *Authorization) HasAccessToRef(ctx context.Context, object client.Object
 
 	// deny access if no ACL is defined on the reference
 	if acl == nil {
-		return accessDeniedErrorf("'%s/%s' can't be accessed due to missing ACL labels on 'accessFrom'",
+		err := accessDeniedErrorf("'%s/%s' can't be accessed due to missing ACL labels on 'accessFrom'",
 			reference.Namespace, reference.Name)
+		a.audit(ctx, object, reference, MissingACLReason, err)
+		return err
 	}
 
 	// get the object's namespace labels
@@ -92,6 +95,8 @@ func (a *Authorization) HasAccessToRef(ctx context.Context, object client.Object
 		}
 	}
 
-	return accessDeniedErrorf("'%s/%s' can't be accessed due to ACL labels mismatch on namespace '%s'",
+	err := accessDeniedErrorf("'%s/%s' can't be accessed due to ACL labels mismatch on namespace '%s'",
 		reference.Namespace, reference.Name, object.GetNamespace())
+	a.audit(ctx, object, reference, NamespaceMismatchReason, err)
+	return err
 }
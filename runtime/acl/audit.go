@@ -0,0 +1,127 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package acl
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+const (
+	// DecisionDenied is the decision of the audit records of denied
+	// cross-namespace references.
+	DecisionDenied = "Denied"
+
+	// MissingACLReason is the reason of a denial due to the referenced
+	// object not defining an ACL.
+	MissingACLReason = "MissingACL"
+
+	// NamespaceMismatchReason is the reason of a denial due to the namespace
+	// of the object not matching the ACL of the referenced object.
+	NamespaceMismatchReason = "NamespaceMismatch"
+)
+
+// AuditObjectReference identifies the object of an audit record.
+type AuditObjectReference struct {
+	APIVersion string `json:"apiVersion,omitempty"`
+	Kind       string `json:"kind,omitempty"`
+	Namespace  string `json:"namespace"`
+	Name       string `json:"name"`
+}
+
+// AuditRecord describes the decision of a cross-namespace reference check.
+type AuditRecord struct {
+	// Time is the time of the decision.
+	Time metav1.Time `json:"time"`
+	// Source is the object holding the reference.
+	Source AuditObjectReference `json:"source"`
+	// Target is the referenced object.
+	Target types.NamespacedName `json:"target"`
+	// Decision is the decision of the check, e.g. DecisionDenied.
+	Decision string `json:"decision"`
+	// Reason is the reason of the decision in CamelCase, e.g. MissingACLReason.
+	Reason string `json:"reason"`
+	// Message is the message of the AccessDeniedError.
+	Message string `json:"message"`
+}
+
+// AuditSink records the audit records of an Authorization, e.g. to allow
+// monitoring and alerting on denied cross-namespace references.
+type AuditSink interface {
+	Record(ctx context.Context, record AuditRecord)
+}
+
+// AuditSinkFunc is a function implementing AuditSink.
+type AuditSinkFunc func(ctx context.Context, record AuditRecord)
+
+// Record implements AuditSink.
+func (f AuditSinkFunc) Record(ctx context.Context, record AuditRecord) {
+	f(ctx, record)
+}
+
+// LogAuditSink records the audit records as structured log entries.
+type LogAuditSink struct {
+	Logger logr.Logger
+}
+
+// Record implements AuditSink.
+func (s LogAuditSink) Record(_ context.Context, record AuditRecord) {
+	s.Logger.Info("cross-namespace reference audit",
+		"decision", record.Decision,
+		"reason", record.Reason,
+		"source", record.Source,
+		"target", record.Target,
+		"message", record.Message,
+	)
+}
+
+// WithAuditSink configures the Authorization to record the denied
+// cross-namespace references in the given sink.
+func (a *Authorization) WithAuditSink(sink AuditSink) *Authorization {
+	a.auditSink = sink
+	return a
+}
+
+// audit records the denied reference of the object in the audit sink.
+func (a *Authorization) audit(ctx context.Context, object client.Object, reference types.NamespacedName, reason string, err error) {
+	if a.auditSink == nil {
+		return
+	}
+	source := AuditObjectReference{
+		Namespace: object.GetNamespace(),
+		Name:      object.GetName(),
+	}
+	gvk := object.GetObjectKind().GroupVersionKind()
+	if gvk.Empty() {
+		gvk, _ = apiutil.GVKForObject(object, a.client.Scheme())
+	}
+	source.APIVersion, source.Kind = gvk.ToAPIVersionAndKind()
+
+	a.auditSink.Record(ctx, AuditRecord{
+		Time:     metav1.Now(),
+		Source:   source,
+		Target:   reference,
+		Decision: DecisionDenied,
+		Reason:   reason,
+		Message:  err.Error(),
+	})
+}
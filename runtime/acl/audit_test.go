@@ -0,0 +1,71 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package acl
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/fluxcd/pkg/apis/acl"
+)
+
+func TestAuthorization_AuditSink(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	namespace := getNamespaceWithLabels("tenant-a", map[string]string{"tenant": "a"})
+	kubeClient := fake.NewClientBuilder().WithObjects(namespace).Build()
+
+	var records []AuditRecord
+	aclAuth := NewAuthorization(kubeClient).WithAuditSink(AuditSinkFunc(func(_ context.Context, record AuditRecord) {
+		records = append(records, record)
+	}))
+
+	object := getObject("app", "tenant-a")
+	reference := types.NamespacedName{Namespace: "flux-system", Name: "repo"}
+
+	// Allowed references are not recorded.
+	g.Expect(aclAuth.HasAccessToRef(ctx, object, types.NamespacedName{Namespace: "tenant-a", Name: "repo"}, nil)).To(Succeed())
+	g.Expect(aclAuth.HasAccessToRef(ctx, object, reference, getReferenceAcl(map[string]string{"tenant": "a"}))).To(Succeed())
+	g.Expect(records).To(BeEmpty())
+
+	err := aclAuth.HasAccessToRef(ctx, object, reference, nil)
+	g.Expect(IsAccessDenied(err)).To(BeTrue())
+	err = aclAuth.HasAccessToRef(ctx, object, reference, &acl.AccessFrom{
+		NamespaceSelectors: []acl.NamespaceSelector{{MatchLabels: map[string]string{"tenant": "b"}}},
+	})
+	g.Expect(IsAccessDenied(err)).To(BeTrue())
+
+	g.Expect(records).To(HaveLen(2))
+	g.Expect(records[0].Reason).To(Equal(MissingACLReason))
+	g.Expect(records[1].Reason).To(Equal(NamespaceMismatchReason))
+	g.Expect(records[1].Message).To(Equal(err.Error()))
+	for _, record := range records {
+		g.Expect(record.Decision).To(Equal(DecisionDenied))
+		g.Expect(record.Target).To(Equal(reference))
+		g.Expect(record.Source).To(Equal(AuditObjectReference{
+			APIVersion: "v1",
+			Kind:       "ConfigMap",
+			Namespace:  "tenant-a",
+			Name:       "app",
+		}))
+	}
+}
@@ -41,6 +41,8 @@ type Impersonator struct {
 	pollingOpts           polling.Options
 	kubeConfigRef         *meta.KubeConfigReference
 	kubeConfigOpts        KubeConfigOptions
+	tokenExchange         *TokenExchange
+	tokenExchangeConfig   *rest.Config
 	defaultServiceAccount string
 	serviceAccountName    string
 	namespace             string
@@ -82,13 +84,25 @@ func NewImpersonatorWithScheme(kubeClient rc.Client,
 	}
 }
 
+// WithTokenExchange configures the Impersonator to access a remote cluster
+// with tokens obtained by exchanging the token of a ServiceAccount in the
+// namespace of the Impersonator, instead of a kubeconfig read from a secret.
+func (i *Impersonator) WithTokenExchange(te *TokenExchange) *Impersonator {
+	i.tokenExchange = te
+	i.tokenExchangeConfig = nil
+	return i
+}
+
 // GetClient creates a controller-runtime client for talking to a Kubernetes API server.
+// If a TokenExchange is set, use a kubeconfig for the remote cluster authenticated with exchanged tokens.
 // If spec.KubeConfig is set, use the kubeconfig bytes from the Kubernetes secret.
 // Otherwise, will assume running in cluster and use the cluster provided kubeconfig.
 // If a --default-service-account is set and no spec.ServiceAccountName, use the provided kubeconfig and impersonate the default SA.
 // If spec.ServiceAccountName is set, use the provided kubeconfig and impersonate the specified SA.
 func (i *Impersonator) GetClient(ctx context.Context) (rc.Client, *polling.StatusPoller, error) {
 	switch {
+	case i.tokenExchange != nil:
+		return i.clientForTokenExchange()
 	case i.kubeConfigRef != nil:
 		return i.clientForKubeConfig(ctx)
 	case i.defaultServiceAccount != "" || i.serviceAccountName != "":
@@ -182,6 +196,44 @@ func (i *Impersonator) clientForKubeConfig(ctx context.Context) (rc.Client, *pol
 	return client, statusPoller, err
 }
 
+func (i *Impersonator) clientForTokenExchange() (rc.Client, *polling.StatusPoller, error) {
+	restConfig, err := i.getTokenExchangeRESTConfig()
+	if err != nil {
+		return nil, nil, err
+	}
+	i.setImpersonationConfig(restConfig)
+
+	restMapper, err := NewDynamicRESTMapper(restConfig)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	client, err := rc.New(restConfig, rc.Options{
+		Scheme: i.scheme,
+		Mapper: restMapper,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	statusPoller := polling.NewStatusPoller(client, restMapper, i.pollingOpts)
+	return client, statusPoller, nil
+}
+
+// getTokenExchangeRESTConfig returns a copy of the rest.Config of the
+// TokenExchange, which is created once so that all the clients of the
+// Impersonator share the same cluster tokens.
+func (i *Impersonator) getTokenExchangeRESTConfig() (*rest.Config, error) {
+	if i.tokenExchangeConfig == nil {
+		restConfig, err := TokenExchangeRESTConfig(i.Client, i.namespace, *i.tokenExchange, i.kubeConfigOpts)
+		if err != nil {
+			return nil, err
+		}
+		i.tokenExchangeConfig = restConfig
+	}
+	return rest.CopyConfig(i.tokenExchangeConfig), nil
+}
+
 func (i *Impersonator) getKubeConfig(ctx context.Context) ([]byte, error) {
 	if i.kubeConfigRef == nil {
 		return nil, fmt.Errorf("KubeConfig is nil")
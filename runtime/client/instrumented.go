@@ -0,0 +1,325 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/util/flowcontrol"
+	rc "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// InstrumentedOptions contains the configuration of an InstrumentedClient.
+type InstrumentedOptions struct {
+	// QPS indicates the maximum queries-per-second of requests sent to the Kubernetes API.
+	// A value lower or equal to zero disables the client-side rate limiting.
+	QPS float32
+
+	// Burst indicates the maximum burst queries-per-second of requests sent to the Kubernetes API.
+	Burst int
+
+	// PriorityAndFairness indicates that the Kubernetes API server has the PriorityAndFairness
+	// flow control filter enabled, in which case the client-side rate limiting is disabled.
+	// It can be determined with flowcontrol.IsEnabled from github.com/fluxcd/cli-utils/pkg/flowcontrol.
+	PriorityAndFairness bool
+
+	// SlowRequestThreshold is the duration after which a request is logged as slow.
+	// A zero value disables the logging of slow requests.
+	SlowRequestThreshold time.Duration
+
+	// Logger is used to log slow requests, defaults to the logger from the request context.
+	Logger logr.Logger
+
+	// Metrics records the requests made by the client, if set.
+	Metrics *ClientMetrics
+
+	// Cache holds the cache options of the wrapped client, if it reads from a cache.
+	// The Get and List requests served from the cache are neither rate limited nor
+	// recorded, as they do not reach the Kubernetes API.
+	Cache *rc.CacheOptions
+}
+
+// InstrumentedClient is a controller-runtime client decorator which applies client-side
+// rate limits, records request metrics and logs slow requests.
+type InstrumentedClient struct {
+	rc.Client
+	limiter       flowcontrol.RateLimiter
+	metrics       *ClientMetrics
+	logger        logr.Logger
+	slowThreshold time.Duration
+
+	cacheReads        bool
+	cacheUnstructured bool
+	uncachedGVKs      map[schema.GroupVersionKind]struct{}
+}
+
+// NewInstrumentedClient wraps the given client with an InstrumentedClient configured with the given options.
+func NewInstrumentedClient(kubeClient rc.Client, opts InstrumentedOptions) *InstrumentedClient {
+	c := &InstrumentedClient{
+		Client:        kubeClient,
+		metrics:       opts.Metrics,
+		logger:        opts.Logger,
+		slowThreshold: opts.SlowRequestThreshold,
+	}
+	if !opts.PriorityAndFairness && opts.QPS > 0 {
+		c.limiter = flowcontrol.NewTokenBucketRateLimiter(opts.QPS, opts.Burst)
+	}
+	if opts.Cache != nil && opts.Cache.Reader != nil {
+		c.cacheReads = true
+		c.cacheUnstructured = opts.Cache.Unstructured
+		c.uncachedGVKs = make(map[schema.GroupVersionKind]struct{}, len(opts.Cache.DisableFor))
+		for _, obj := range opts.Cache.DisableFor {
+			if gvk, err := kubeClient.GroupVersionKindFor(obj); err == nil {
+				c.uncachedGVKs[gvk] = struct{}{}
+			}
+		}
+	}
+	return c
+}
+
+// Get retrieves the object for the given key from the Kubernetes API.
+func (c *InstrumentedClient) Get(ctx context.Context, key rc.ObjectKey, obj rc.Object, opts ...rc.GetOption) error {
+	if c.readsFromCache(obj) {
+		return c.Client.Get(ctx, key, obj, opts...)
+	}
+	return c.do(ctx, "get", obj, func() error {
+		return c.Client.Get(ctx, key, obj, opts...)
+	})
+}
+
+// List retrieves the list of objects for the given options from the Kubernetes API.
+func (c *InstrumentedClient) List(ctx context.Context, list rc.ObjectList, opts ...rc.ListOption) error {
+	if c.readsFromCache(list) {
+		return c.Client.List(ctx, list, opts...)
+	}
+	return c.do(ctx, "list", list, func() error {
+		return c.Client.List(ctx, list, opts...)
+	})
+}
+
+// Create saves the object in the Kubernetes cluster.
+func (c *InstrumentedClient) Create(ctx context.Context, obj rc.Object, opts ...rc.CreateOption) error {
+	return c.do(ctx, "create", obj, func() error {
+		return c.Client.Create(ctx, obj, opts...)
+	})
+}
+
+// Delete deletes the object from the Kubernetes cluster.
+func (c *InstrumentedClient) Delete(ctx context.Context, obj rc.Object, opts ...rc.DeleteOption) error {
+	return c.do(ctx, "delete", obj, func() error {
+		return c.Client.Delete(ctx, obj, opts...)
+	})
+}
+
+// Update updates the object in the Kubernetes cluster.
+func (c *InstrumentedClient) Update(ctx context.Context, obj rc.Object, opts ...rc.UpdateOption) error {
+	return c.do(ctx, "update", obj, func() error {
+		return c.Client.Update(ctx, obj, opts...)
+	})
+}
+
+// Patch patches the object in the Kubernetes cluster.
+func (c *InstrumentedClient) Patch(ctx context.Context, obj rc.Object, patch rc.Patch, opts ...rc.PatchOption) error {
+	return c.do(ctx, "patch", obj, func() error {
+		return c.Client.Patch(ctx, obj, patch, opts...)
+	})
+}
+
+// DeleteAllOf deletes all objects of the given type matching the given options.
+func (c *InstrumentedClient) DeleteAllOf(ctx context.Context, obj rc.Object, opts ...rc.DeleteAllOfOption) error {
+	return c.do(ctx, "deletecollection", obj, func() error {
+		return c.Client.DeleteAllOf(ctx, obj, opts...)
+	})
+}
+
+// Status returns an instrumented client for the status subresource.
+func (c *InstrumentedClient) Status() rc.SubResourceWriter {
+	return c.SubResource("status")
+}
+
+// SubResource returns an instrumented client for the given subresource.
+func (c *InstrumentedClient) SubResource(subResource string) rc.SubResourceClient {
+	return &instrumentedSubResourceClient{
+		SubResourceClient: c.Client.SubResource(subResource),
+		parent:            c,
+	}
+}
+
+// do waits for the rate limiter, performs the request and records its outcome.
+func (c *InstrumentedClient) do(ctx context.Context, verb string, obj runtime.Object, request func() error) error {
+	if c.limiter != nil {
+		if err := c.limiter.Wait(ctx); err != nil {
+			return err
+		}
+	}
+
+	start := time.Now()
+	err := request()
+	duration := time.Since(start)
+
+	kind := c.kindOf(obj)
+	if c.metrics != nil {
+		c.metrics.record(verb, kind, statusCode(verb, err), duration)
+	}
+
+	if c.slowThreshold > 0 && duration >= c.slowThreshold {
+		logger := c.logger
+		if logger.GetSink() == nil {
+			logger = log.FromContext(ctx)
+		}
+		keysAndValues := []interface{}{"verb", verb, "kind", kind, "duration", duration.String()}
+		if o, ok := obj.(rc.Object); ok {
+			keysAndValues = append(keysAndValues, "name", o.GetName(), "namespace", o.GetNamespace())
+		}
+		logger.Info("slow Kubernetes API request", keysAndValues...)
+	}
+
+	return err
+}
+
+// readsFromCache returns whether the wrapped client reads the given object or list
+// from its cache, following the rules of the controller-runtime client.
+func (c *InstrumentedClient) readsFromCache(obj runtime.Object) bool {
+	if !c.cacheReads {
+		return false
+	}
+	gvk, err := c.Client.GroupVersionKindFor(obj)
+	if err != nil {
+		return false
+	}
+	if meta.IsListType(obj) {
+		gvk.Kind = strings.TrimSuffix(gvk.Kind, "List")
+	}
+	if _, ok := c.uncachedGVKs[gvk]; ok {
+		return false
+	}
+	if _, ok := obj.(runtime.Unstructured); ok {
+		return c.cacheUnstructured
+	}
+	return true
+}
+
+// kindOf returns the kind of the given object, or of the items of the given list.
+func (c *InstrumentedClient) kindOf(obj runtime.Object) string {
+	gvk, err := c.Client.GroupVersionKindFor(obj)
+	if err != nil {
+		return "unknown"
+	}
+	if _, ok := obj.(rc.ObjectList); ok {
+		return strings.TrimSuffix(gvk.Kind, "List")
+	}
+	return gvk.Kind
+}
+
+// statusCode returns the HTTP status code of the request outcome, or "<error>"
+// if the error does not originate from the Kubernetes API.
+func statusCode(verb string, err error) string {
+	if err == nil {
+		if verb == "create" {
+			return strconv.Itoa(http.StatusCreated)
+		}
+		return strconv.Itoa(http.StatusOK)
+	}
+	var status apierrors.APIStatus
+	if errors.As(err, &status) && status.Status().Code != 0 {
+		return strconv.Itoa(int(status.Status().Code))
+	}
+	return "<error>"
+}
+
+// instrumentedSubResourceClient instruments the requests made to a subresource.
+type instrumentedSubResourceClient struct {
+	rc.SubResourceClient
+	parent *InstrumentedClient
+}
+
+func (c *instrumentedSubResourceClient) Get(ctx context.Context, obj rc.Object, subResource rc.Object, opts ...rc.SubResourceGetOption) error {
+	return c.parent.do(ctx, "get", obj, func() error {
+		return c.SubResourceClient.Get(ctx, obj, subResource, opts...)
+	})
+}
+
+func (c *instrumentedSubResourceClient) Create(ctx context.Context, obj rc.Object, subResource rc.Object, opts ...rc.SubResourceCreateOption) error {
+	return c.parent.do(ctx, "create", obj, func() error {
+		return c.SubResourceClient.Create(ctx, obj, subResource, opts...)
+	})
+}
+
+func (c *instrumentedSubResourceClient) Update(ctx context.Context, obj rc.Object, opts ...rc.SubResourceUpdateOption) error {
+	return c.parent.do(ctx, "update", obj, func() error {
+		return c.SubResourceClient.Update(ctx, obj, opts...)
+	})
+}
+
+func (c *instrumentedSubResourceClient) Patch(ctx context.Context, obj rc.Object, patch rc.Patch, opts ...rc.SubResourcePatchOption) error {
+	return c.parent.do(ctx, "patch", obj, func() error {
+		return c.SubResourceClient.Patch(ctx, obj, patch, opts...)
+	})
+}
+
+// ClientMetrics holds the Prometheus collectors for the requests made by an InstrumentedClient.
+type ClientMetrics struct {
+	requestsCounter   *prometheus.CounterVec
+	durationHistogram *prometheus.HistogramVec
+}
+
+// NewClientMetrics returns a new ClientMetrics with the metric names configured
+// conform GitOps Toolkit standards.
+func NewClientMetrics() *ClientMetrics {
+	return &ClientMetrics{
+		requestsCounter: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "gotk_client_requests_total",
+				Help: "The number of Kubernetes API requests made by a GitOps Toolkit controller.",
+			},
+			[]string{"verb", "kind", "code"},
+		),
+		durationHistogram: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "gotk_client_request_duration_seconds",
+				Help:    "The duration in seconds of Kubernetes API requests made by a GitOps Toolkit controller.",
+				Buckets: prometheus.ExponentialBucketsRange(1e-3, 60, 10),
+			},
+			[]string{"verb", "kind"},
+		),
+	}
+}
+
+// Collectors returns a slice of Prometheus collectors, which can be used to register them in a metrics registry.
+func (m *ClientMetrics) Collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		m.requestsCounter,
+		m.durationHistogram,
+	}
+}
+
+func (m *ClientMetrics) record(verb, kind, code string, duration time.Duration) {
+	m.requestsCounter.WithLabelValues(verb, kind, code).Inc()
+	m.durationHistogram.WithLabelValues(verb, kind).Observe(duration.Seconds())
+}
@@ -0,0 +1,178 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr/funcr"
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	rc "sigs.k8s.io/controller-runtime/pkg/client"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestInstrumentedClient(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := corev1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	var logs []string
+	metrics := NewClientMetrics()
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(metrics.Collectors()...)
+
+	c := NewInstrumentedClient(fakeclient.NewClientBuilder().WithScheme(scheme).Build(), InstrumentedOptions{
+		QPS:                  100,
+		Burst:                10,
+		SlowRequestThreshold: time.Nanosecond,
+		Logger: funcr.New(func(prefix, args string) {
+			logs = append(logs, args)
+		}, funcr.Options{}),
+		Metrics: metrics,
+	})
+
+	ctx := context.Background()
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"}}
+
+	if err := c.Get(ctx, rc.ObjectKeyFromObject(cm), &corev1.ConfigMap{}); err == nil {
+		t.Fatal("expected not found error")
+	}
+	if err := c.Create(ctx, cm); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.List(ctx, &corev1.ConfigMapList{}); err != nil {
+		t.Fatal(err)
+	}
+
+	got := requestCounts(t, reg)
+	for _, key := range []string{"404/ConfigMap/get", "201/ConfigMap/create", "200/ConfigMap/list"} {
+		if got[key] != 1 {
+			t.Errorf("expected one request for %s, got %v", key, got)
+		}
+	}
+
+	if len(logs) != 3 {
+		t.Errorf("expected 3 slow request logs, got %d", len(logs))
+	}
+}
+
+func TestInstrumentedClient_cache(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := corev1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	metrics := NewClientMetrics()
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(metrics.Collectors()...)
+
+	kubeClient := fakeclient.NewClientBuilder().WithScheme(scheme).Build()
+	c := NewInstrumentedClient(kubeClient, InstrumentedOptions{
+		Metrics: metrics,
+		Cache: &rc.CacheOptions{
+			Reader:     kubeClient,
+			DisableFor: []rc.Object{&corev1.Secret{}},
+		},
+	})
+
+	ctx := context.Background()
+	key := rc.ObjectKey{Name: "test", Namespace: "default"}
+
+	if err := c.Get(ctx, key, &corev1.ConfigMap{}); err == nil {
+		t.Fatal("expected not found error")
+	}
+	if err := c.List(ctx, &corev1.ConfigMapList{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Get(ctx, key, &corev1.Secret{}); err == nil {
+		t.Fatal("expected not found error")
+	}
+	if err := c.List(ctx, &corev1.SecretList{}); err != nil {
+		t.Fatal(err)
+	}
+	u := &unstructured.Unstructured{}
+	u.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("ConfigMap"))
+	if err := c.Get(ctx, key, u); err == nil {
+		t.Fatal("expected not found error")
+	}
+
+	got := requestCounts(t, reg)
+	expected := map[string]float64{
+		"404/Secret/get":    1,
+		"200/Secret/list":   1,
+		"404/ConfigMap/get": 1,
+	}
+	if len(got) != len(expected) {
+		t.Errorf("expected requests %v, got %v", expected, got)
+	}
+	for key, count := range expected {
+		if got[key] != count {
+			t.Errorf("expected %v requests for %s, got %v", count, key, got)
+		}
+	}
+}
+
+// requestCounts returns the number of recorded requests keyed by their
+// labels, which are sorted by name: code, kind, verb.
+func requestCounts(t *testing.T, reg *prometheus.Registry) map[string]float64 {
+	t.Helper()
+
+	metricFamilies, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	counts := map[string]float64{}
+	for _, mf := range metricFamilies {
+		if mf.GetName() != "gotk_client_requests_total" {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			var labels []string
+			for _, pair := range m.GetLabel() {
+				labels = append(labels, pair.GetValue())
+			}
+			counts[strings.Join(labels, "/")] = m.GetCounter().GetValue()
+		}
+	}
+	return counts
+}
+
+func TestStatusCode(t *testing.T) {
+	tests := []struct {
+		verb     string
+		err      error
+		expected string
+	}{
+		{verb: "get", expected: "200"},
+		{verb: "create", expected: "201"},
+		{verb: "get", err: context.DeadlineExceeded, expected: "<error>"},
+	}
+	for _, tt := range tests {
+		if got := statusCode(tt.verb, tt.err); got != tt.expected {
+			t.Errorf("statusCode(%s, %v) = %s, expected %s", tt.verb, tt.err, got, tt.expected)
+		}
+	}
+}
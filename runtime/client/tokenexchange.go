@@ -0,0 +1,181 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+	rc "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// refreshBeforeExpiry is the time before their expiry at which cluster
+// tokens are refreshed.
+const refreshBeforeExpiry = time.Minute
+
+// ClusterToken is a short-lived token for the API server of a remote
+// cluster.
+type ClusterToken struct {
+	// Token is the bearer token used to authenticate against the API server.
+	Token string
+	// ExpiresAt is the time at which the token expires.
+	ExpiresAt time.Time
+}
+
+// ClusterTokenProvider exchanges a Kubernetes ServiceAccount token for a
+// token of a remote cluster, with the get-token flow of a cloud provider.
+type ClusterTokenProvider interface {
+	// Name returns the name of the provider, for example: 'aws'.
+	Name() string
+	// Audience returns the audience the ServiceAccount token must be
+	// issued for.
+	Audience() string
+	// ClusterToken exchanges the given ServiceAccount token for a token
+	// of the remote cluster.
+	ClusterToken(ctx context.Context, serviceAccountToken string) (*ClusterToken, error)
+}
+
+// TokenExchange configures the access to a remote cluster with tokens
+// obtained by exchanging the token of a Kubernetes ServiceAccount, instead
+// of a static kubeconfig.
+type TokenExchange struct {
+	// Provider exchanges the ServiceAccount token for a cluster token.
+	Provider ClusterTokenProvider
+	// ServiceAccountName is the name of the ServiceAccount whose token is
+	// exchanged. It must be in the namespace of the reconciled object.
+	ServiceAccountName string
+	// Host is the address of the API server of the remote cluster.
+	Host string
+	// CAData is the PEM encoded CA bundle of the API server of the remote
+	// cluster. If empty, the system trust store is used.
+	CAData []byte
+}
+
+// TokenExchangeRESTConfig returns a rest.Config for the remote cluster of
+// the given TokenExchange. The cluster tokens are obtained when the first
+// request is made, and refreshed before they expire, using tokens of the
+// ServiceAccount in the given namespace requested with the TokenRequest API.
+func TokenExchangeRESTConfig(kubeClient rc.Client, namespace string, te TokenExchange, opts KubeConfigOptions) (*rest.Config, error) {
+	if te.Provider == nil {
+		return nil, errors.New("token exchange provider is nil")
+	}
+	if te.ServiceAccountName == "" {
+		return nil, errors.New("token exchange ServiceAccount name is empty")
+	}
+	if te.Host == "" {
+		return nil, errors.New("token exchange host is empty")
+	}
+
+	source := &clusterTokenSource{
+		provider: te.Provider,
+		serviceAccountToken: func(ctx context.Context) (string, error) {
+			return RequestServiceAccountToken(ctx, kubeClient, namespace, te.ServiceAccountName, te.Provider.Audience())
+		},
+		now: time.Now,
+	}
+
+	opts = opts.withDefaults()
+	restConfig := &rest.Config{
+		Host:      te.Host,
+		UserAgent: opts.UserAgent,
+		Timeout:   *opts.Timeout,
+		TLSClientConfig: rest.TLSClientConfig{
+			CAData: te.CAData,
+		},
+	}
+	restConfig.Wrap(func(rt http.RoundTripper) http.RoundTripper {
+		return &clusterTokenRoundTripper{source: source, next: rt}
+	})
+	return restConfig, nil
+}
+
+// RequestServiceAccountToken returns a token for the given ServiceAccount,
+// issued for the given audience with the TokenRequest API.
+func RequestServiceAccountToken(ctx context.Context, kubeClient rc.Client, namespace, name, audience string) (string, error) {
+	sa := &corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+	}
+	tr := &authenticationv1.TokenRequest{
+		Spec: authenticationv1.TokenRequestSpec{
+			Audiences: []string{audience},
+		},
+	}
+	if err := kubeClient.SubResource("token").Create(ctx, sa, tr); err != nil {
+		return "", fmt.Errorf("unable to request token for ServiceAccount '%s/%s': %w", namespace, name, err)
+	}
+	return tr.Status.Token, nil
+}
+
+// clusterTokenSource caches the cluster tokens obtained from a provider
+// until they are about to expire.
+type clusterTokenSource struct {
+	provider            ClusterTokenProvider
+	serviceAccountToken func(ctx context.Context) (string, error)
+	now                 func() time.Time
+
+	mu    sync.Mutex
+	token *ClusterToken
+}
+
+// Token returns a valid cluster token.
+func (s *clusterTokenSource) Token(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token != nil && s.now().Add(refreshBeforeExpiry).Before(s.token.ExpiresAt) {
+		return s.token.Token, nil
+	}
+
+	saToken, err := s.serviceAccountToken(ctx)
+	if err != nil {
+		return "", err
+	}
+	token, err := s.provider.ClusterToken(ctx, saToken)
+	if err != nil {
+		return "", fmt.Errorf("unable to exchange ServiceAccount token with %s provider: %w", s.provider.Name(), err)
+	}
+	s.token = token
+	return token.Token, nil
+}
+
+// clusterTokenRoundTripper authenticates requests with the token of a
+// clusterTokenSource.
+type clusterTokenRoundTripper struct {
+	source *clusterTokenSource
+	next   http.RoundTripper
+}
+
+func (rt *clusterTokenRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := rt.source.Token(req.Context())
+	if err != nil {
+		return nil, err
+	}
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+token)
+	return rt.next.RoundTrip(req)
+}
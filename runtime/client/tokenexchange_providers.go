@@ -0,0 +1,370 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// EKSTokenProvider obtains tokens for an EKS cluster, by assuming an IAM
+// role with the ServiceAccount token and presigning an STS
+// GetCallerIdentity request, as 'aws eks get-token' does.
+type EKSTokenProvider struct {
+	// ClusterName is the name of the EKS cluster.
+	ClusterName string
+	// RoleARN is the ARN of the IAM role to assume, which must trust the
+	// OIDC issuer of the cluster the ServiceAccount belongs to.
+	RoleARN string
+	// Region is the AWS region of the STS endpoint. Defaults to 'us-east-1'.
+	Region string
+	// STSEndpoint overrides the STS endpoint of the region.
+	STSEndpoint string
+	// HTTPClient is the client used to call STS. Defaults to http.DefaultClient.
+	HTTPClient *http.Client
+}
+
+// eksTokenLifetime is the lifetime of an EKS token, which is valid for
+// 15 minutes after the presigned request was signed.
+const eksTokenLifetime = 14 * time.Minute
+
+// Name implements ClusterTokenProvider.
+func (p *EKSTokenProvider) Name() string {
+	return "aws"
+}
+
+// Audience implements ClusterTokenProvider.
+func (p *EKSTokenProvider) Audience() string {
+	return "sts.amazonaws.com"
+}
+
+// ClusterToken implements ClusterTokenProvider.
+func (p *EKSTokenProvider) ClusterToken(ctx context.Context, serviceAccountToken string) (*ClusterToken, error) {
+	region := p.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+	endpoint := p.STSEndpoint
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://sts.%s.amazonaws.com", region)
+	}
+
+	creds, err := p.assumeRoleWithWebIdentity(ctx, endpoint, serviceAccountToken)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	presigned, err := presignGetCallerIdentity(endpoint, region, p.ClusterName, creds, now)
+	if err != nil {
+		return nil, err
+	}
+	return &ClusterToken{
+		Token:     "k8s-aws-v1." + base64.RawURLEncoding.EncodeToString([]byte(presigned)),
+		ExpiresAt: now.Add(eksTokenLifetime),
+	}, nil
+}
+
+type awsCredentials struct {
+	AccessKeyID     string `xml:"AccessKeyId"`
+	SecretAccessKey string `xml:"SecretAccessKey"`
+	SessionToken    string `xml:"SessionToken"`
+}
+
+// assumeRoleWithWebIdentity exchanges the ServiceAccount token for the
+// credentials of the IAM role.
+func (p *EKSTokenProvider) assumeRoleWithWebIdentity(ctx context.Context, endpoint, token string) (*awsCredentials, error) {
+	form := url.Values{
+		"Action":           {"AssumeRoleWithWebIdentity"},
+		"Version":          {"2011-06-15"},
+		"RoleArn":          {p.RoleARN},
+		"RoleSessionName":  {"flux-" + p.ClusterName},
+		"WebIdentityToken": {token},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	var out struct {
+		Credentials awsCredentials `xml:"AssumeRoleWithWebIdentityResult>Credentials"`
+	}
+	if err := doTokenRequest(p.HTTPClient, req, func(r io.Reader) error {
+		return xml.NewDecoder(r).Decode(&out)
+	}); err != nil {
+		return nil, fmt.Errorf("assuming role '%s' failed: %w", p.RoleARN, err)
+	}
+	if out.Credentials.AccessKeyID == "" {
+		return nil, fmt.Errorf("assuming role '%s' failed: no credentials returned", p.RoleARN)
+	}
+	return &out.Credentials, nil
+}
+
+// presignGetCallerIdentity returns the URL of an STS GetCallerIdentity
+// request for the given cluster, presigned with AWS Signature Version 4.
+func presignGetCallerIdentity(endpoint, region, clusterName string, creds *awsCredentials, now time.Time) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+	u.Path = "/"
+
+	date := now.Format("20060102")
+	scope := fmt.Sprintf("%s/%s/sts/aws4_request", date, region)
+	query := url.Values{
+		"Action":              {"GetCallerIdentity"},
+		"Version":             {"2011-06-15"},
+		"X-Amz-Algorithm":     {"AWS4-HMAC-SHA256"},
+		"X-Amz-Credential":    {creds.AccessKeyID + "/" + scope},
+		"X-Amz-Date":          {now.Format("20060102T150405Z")},
+		"X-Amz-Expires":       {"60"},
+		"X-Amz-SignedHeaders": {"host;x-k8s-aws-id"},
+	}
+	if creds.SessionToken != "" {
+		query.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+	// Encode sorts the parameters by key, as required for signing.
+	canonicalQuery := strings.ReplaceAll(query.Encode(), "+", "%20")
+
+	emptyHash := sha256.Sum256(nil)
+	canonicalRequest := strings.Join([]string{
+		http.MethodGet,
+		u.Path,
+		canonicalQuery,
+		"host:" + u.Host + "\n" + "x-k8s-aws-id:" + clusterName + "\n",
+		"host;x-k8s-aws-id",
+		hex.EncodeToString(emptyHash[:]),
+	}, "\n")
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		query.Get("X-Amz-Date"),
+		scope,
+		hex.EncodeToString(requestHash[:]),
+	}, "\n")
+
+	key := []byte("AWS4" + creds.SecretAccessKey)
+	for _, part := range []string{date, region, "sts", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	u.RawQuery = canonicalQuery + "&X-Amz-Signature=" + signature
+	return u.String(), nil
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// GKETokenProvider obtains tokens for a GKE cluster, by exchanging the
+// ServiceAccount token with the Security Token Service of a workload
+// identity pool, and optionally impersonating a Google service account.
+type GKETokenProvider struct {
+	// WorkloadIdentityProvider is the full resource name of the provider of
+	// the workload identity pool, for example:
+	// '//iam.googleapis.com/projects/<number>/locations/global/workloadIdentityPools/<pool>/providers/<provider>'.
+	WorkloadIdentityProvider string
+	// ServiceAccountEmail is the email of the Google service account to
+	// impersonate, if any.
+	ServiceAccountEmail string
+	// STSURL overrides the token endpoint of the Security Token Service.
+	STSURL string
+	// IAMCredentialsURL overrides the endpoint of the IAM Service Account
+	// Credentials API.
+	IAMCredentialsURL string
+	// HTTPClient is the client used to call the Google APIs. Defaults to
+	// http.DefaultClient.
+	HTTPClient *http.Client
+}
+
+// Name implements ClusterTokenProvider.
+func (p *GKETokenProvider) Name() string {
+	return "gcp"
+}
+
+// Audience implements ClusterTokenProvider.
+func (p *GKETokenProvider) Audience() string {
+	return "https:" + p.WorkloadIdentityProvider
+}
+
+// ClusterToken implements ClusterTokenProvider.
+func (p *GKETokenProvider) ClusterToken(ctx context.Context, serviceAccountToken string) (*ClusterToken, error) {
+	stsURL := p.STSURL
+	if stsURL == "" {
+		stsURL = "https://sts.googleapis.com/v1/token"
+	}
+	form := url.Values{
+		"grant_type":           {"urn:ietf:params:oauth:grant-type:token-exchange"},
+		"audience":             {p.WorkloadIdentityProvider},
+		"scope":                {"https://www.googleapis.com/auth/cloud-platform"},
+		"requested_token_type": {"urn:ietf:params:oauth:token-type:access_token"},
+		"subject_token":        {serviceAccountToken},
+		"subject_token_type":   {"urn:ietf:params:oauth:token-type:jwt"},
+	}
+	token, err := postOAuthForm(ctx, p.HTTPClient, stsURL, form)
+	if err != nil {
+		return nil, fmt.Errorf("token exchange failed: %w", err)
+	}
+	if p.ServiceAccountEmail == "" {
+		return token, nil
+	}
+
+	iamURL := p.IAMCredentialsURL
+	if iamURL == "" {
+		iamURL = "https://iamcredentials.googleapis.com"
+	}
+	body, err := json.Marshal(map[string][]string{"scope": {"https://www.googleapis.com/auth/cloud-platform"}})
+	if err != nil {
+		return nil, err
+	}
+	endpoint := fmt.Sprintf("%s/v1/projects/-/serviceAccounts/%s:generateAccessToken",
+		strings.TrimSuffix(iamURL, "/"), url.PathEscape(p.ServiceAccountEmail))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token.Token)
+
+	var out struct {
+		AccessToken string    `json:"accessToken"`
+		ExpireTime  time.Time `json:"expireTime"`
+	}
+	if err := doTokenRequest(p.HTTPClient, req, func(r io.Reader) error {
+		return json.NewDecoder(r).Decode(&out)
+	}); err != nil {
+		return nil, fmt.Errorf("impersonating '%s' failed: %w", p.ServiceAccountEmail, err)
+	}
+	return &ClusterToken{Token: out.AccessToken, ExpiresAt: out.ExpireTime}, nil
+}
+
+// AKSTokenProvider obtains tokens for an AKS cluster with Microsoft Entra
+// integration, by using the ServiceAccount token as client assertion of a
+// federated identity credential of an application.
+type AKSTokenProvider struct {
+	// TenantID is the ID of the tenant of the application.
+	TenantID string
+	// ClientID is the client ID of the application.
+	ClientID string
+	// AuthorityHost overrides the Microsoft Entra authority host.
+	// Defaults to 'https://login.microsoftonline.com'.
+	AuthorityHost string
+	// HTTPClient is the client used to call Microsoft Entra. Defaults to
+	// http.DefaultClient.
+	HTTPClient *http.Client
+}
+
+// aksServerApplicationID is the ID of the Microsoft Entra application of
+// the API servers of AKS clusters.
+const aksServerApplicationID = "6dae42f8-4368-4678-94ff-3960e28e3630"
+
+// Name implements ClusterTokenProvider.
+func (p *AKSTokenProvider) Name() string {
+	return "azure"
+}
+
+// Audience implements ClusterTokenProvider.
+func (p *AKSTokenProvider) Audience() string {
+	return "api://AzureADTokenExchange"
+}
+
+// ClusterToken implements ClusterTokenProvider.
+func (p *AKSTokenProvider) ClusterToken(ctx context.Context, serviceAccountToken string) (*ClusterToken, error) {
+	authority := p.AuthorityHost
+	if authority == "" {
+		authority = "https://login.microsoftonline.com"
+	}
+	form := url.Values{
+		"grant_type":            {"client_credentials"},
+		"client_id":             {p.ClientID},
+		"scope":                 {aksServerApplicationID + "/.default"},
+		"client_assertion_type": {"urn:ietf:params:oauth:client-assertion-type:jwt-bearer"},
+		"client_assertion":      {serviceAccountToken},
+	}
+	endpoint := fmt.Sprintf("%s/%s/oauth2/v2.0/token", strings.TrimSuffix(authority, "/"), url.PathEscape(p.TenantID))
+	token, err := postOAuthForm(ctx, p.HTTPClient, endpoint, form)
+	if err != nil {
+		return nil, fmt.Errorf("token request for tenant '%s' failed: %w", p.TenantID, err)
+	}
+	return token, nil
+}
+
+// postOAuthForm posts the form to an OAuth 2.0 token endpoint and returns
+// the access token of the response.
+func postOAuthForm(ctx context.Context, client *http.Client, endpoint string, form url.Values) (*ClusterToken, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	now := time.Now()
+	var out struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err := doTokenRequest(client, req, func(r io.Reader) error {
+		return json.NewDecoder(r).Decode(&out)
+	}); err != nil {
+		return nil, err
+	}
+	if out.AccessToken == "" {
+		return nil, fmt.Errorf("no access token returned")
+	}
+	return &ClusterToken{
+		Token:     out.AccessToken,
+		ExpiresAt: now.Add(time.Duration(out.ExpiresIn) * time.Second),
+	}, nil
+}
+
+// doTokenRequest sends the request and decodes the response with the given
+// function if it succeeded.
+func doTokenRequest(client *http.Client, req *http.Request, decode func(io.Reader) error) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("unexpected status: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	if err := decode(resp.Body); err != nil {
+		return fmt.Errorf("decoding response failed: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,265 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+type fakeClusterTokenProvider struct {
+	calls int
+	ttl   time.Duration
+}
+
+func (p *fakeClusterTokenProvider) Name() string {
+	return "fake"
+}
+
+func (p *fakeClusterTokenProvider) Audience() string {
+	return "fake-audience"
+}
+
+func (p *fakeClusterTokenProvider) ClusterToken(_ context.Context, serviceAccountToken string) (*ClusterToken, error) {
+	p.calls++
+	return &ClusterToken{
+		Token:     fmt.Sprintf("%s-%d", serviceAccountToken, p.calls),
+		ExpiresAt: time.Now().Add(p.ttl),
+	}, nil
+}
+
+func TestClusterTokenSource(t *testing.T) {
+	g := NewWithT(t)
+
+	provider := &fakeClusterTokenProvider{ttl: time.Hour}
+	now := time.Now()
+	source := &clusterTokenSource{
+		provider: provider,
+		serviceAccountToken: func(context.Context) (string, error) {
+			return "sa", nil
+		},
+		now: func() time.Time { return now },
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("Authorization")))
+	}))
+	t.Cleanup(srv.Close)
+	client := &http.Client{Transport: &clusterTokenRoundTripper{source: source, next: http.DefaultTransport}}
+
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(srv.URL)
+		g.Expect(err).ToNot(HaveOccurred())
+		body := make([]byte, 64)
+		n, _ := resp.Body.Read(body)
+		resp.Body.Close()
+		g.Expect(string(body[:n])).To(Equal("Bearer sa-1"))
+	}
+	g.Expect(provider.calls).To(Equal(1))
+
+	// The token is refreshed before it expires.
+	source.now = func() time.Time { return now.Add(time.Hour - refreshBeforeExpiry) }
+	token, err := source.Token(context.TODO())
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(token).To(Equal("sa-2"))
+}
+
+func TestTokenExchangeRESTConfig(t *testing.T) {
+	g := NewWithT(t)
+
+	_, err := TokenExchangeRESTConfig(nil, "default", TokenExchange{ServiceAccountName: "sa", Host: "https://cluster"}, KubeConfigOptions{})
+	g.Expect(err).To(HaveOccurred())
+
+	cfg, err := TokenExchangeRESTConfig(nil, "default", TokenExchange{
+		Provider:           &fakeClusterTokenProvider{},
+		ServiceAccountName: "sa",
+		Host:               "https://cluster",
+		CAData:             []byte("ca"),
+	}, KubeConfigOptions{UserAgent: "test"})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(cfg.Host).To(Equal("https://cluster"))
+	g.Expect(cfg.UserAgent).To(Equal("test"))
+	g.Expect(cfg.CAData).To(Equal([]byte("ca")))
+	g.Expect(cfg.Timeout).To(Equal(30 * time.Second))
+	g.Expect(cfg.BearerToken).To(BeEmpty())
+	g.Expect(cfg.WrapTransport).ToNot(BeNil())
+}
+
+func TestImpersonator_TokenExchange(t *testing.T) {
+	g := NewWithT(t)
+
+	i := (&Impersonator{namespace: "default", serviceAccountName: "sa"}).WithTokenExchange(&TokenExchange{
+		Provider:           &fakeClusterTokenProvider{},
+		ServiceAccountName: "sa",
+		Host:               "https://cluster",
+	})
+
+	cfg1, err := i.getTokenExchangeRESTConfig()
+	g.Expect(err).ToNot(HaveOccurred())
+	i.setImpersonationConfig(cfg1)
+	cfg2, err := i.getTokenExchangeRESTConfig()
+	g.Expect(err).ToNot(HaveOccurred())
+
+	// The configs share the cluster token source.
+	rt1, ok := cfg1.WrapTransport(http.DefaultTransport).(*clusterTokenRoundTripper)
+	g.Expect(ok).To(BeTrue())
+	rt2, ok := cfg2.WrapTransport(http.DefaultTransport).(*clusterTokenRoundTripper)
+	g.Expect(ok).To(BeTrue())
+	g.Expect(rt1.source).To(BeIdenticalTo(rt2.source))
+	g.Expect(cfg2.Impersonate.UserName).To(BeEmpty())
+}
+
+func TestEKSTokenProvider_ClusterToken(t *testing.T) {
+	g := NewWithT(t)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil || r.Form.Get("Action") != "AssumeRoleWithWebIdentity" ||
+			r.Form.Get("WebIdentityToken") != "sa-token" || r.Form.Get("RoleArn") != "arn:aws:iam::111111111111:role/flux" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`<AssumeRoleWithWebIdentityResponse>
+  <AssumeRoleWithWebIdentityResult>
+    <Credentials>
+      <AccessKeyId>AKID</AccessKeyId>
+      <SecretAccessKey>secret</SecretAccessKey>
+      <SessionToken>session</SessionToken>
+    </Credentials>
+  </AssumeRoleWithWebIdentityResult>
+</AssumeRoleWithWebIdentityResponse>`))
+	}))
+	t.Cleanup(srv.Close)
+
+	p := &EKSTokenProvider{
+		ClusterName: "prod",
+		RoleARN:     "arn:aws:iam::111111111111:role/flux",
+		Region:      "eu-west-1",
+		STSEndpoint: srv.URL,
+	}
+	g.Expect(p.Audience()).To(Equal("sts.amazonaws.com"))
+
+	token, err := p.ClusterToken(context.TODO(), "sa-token")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(token.Token).To(HavePrefix("k8s-aws-v1."))
+	g.Expect(token.ExpiresAt).To(BeTemporally("~", time.Now().Add(eksTokenLifetime), time.Minute))
+
+	presigned, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(token.Token, "k8s-aws-v1."))
+	g.Expect(err).ToNot(HaveOccurred())
+	u, err := url.Parse(string(presigned))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(u.Host).To(Equal(strings.TrimPrefix(srv.URL, "http://")))
+	q := u.Query()
+	g.Expect(q.Get("Action")).To(Equal("GetCallerIdentity"))
+	g.Expect(q.Get("X-Amz-Credential")).To(HavePrefix("AKID/"))
+	g.Expect(q.Get("X-Amz-Credential")).To(HaveSuffix("/eu-west-1/sts/aws4_request"))
+	g.Expect(q.Get("X-Amz-Security-Token")).To(Equal("session"))
+	g.Expect(q.Get("X-Amz-SignedHeaders")).To(Equal("host;x-k8s-aws-id"))
+	g.Expect(q.Get("X-Amz-Signature")).To(HaveLen(64))
+}
+
+func TestPresignGetCallerIdentity(t *testing.T) {
+	g := NewWithT(t)
+
+	// The signature is deterministic for the same inputs.
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	creds := &awsCredentials{AccessKeyID: "AKID", SecretAccessKey: "secret"}
+	a, err := presignGetCallerIdentity("https://sts.us-east-1.amazonaws.com", "us-east-1", "prod", creds, now)
+	g.Expect(err).ToNot(HaveOccurred())
+	b, err := presignGetCallerIdentity("https://sts.us-east-1.amazonaws.com", "us-east-1", "prod", creds, now)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(a).To(Equal(b))
+
+	c, err := presignGetCallerIdentity("https://sts.us-east-1.amazonaws.com", "us-east-1", "staging", creds, now)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(c).ToNot(Equal(a))
+	g.Expect(a).To(HavePrefix("https://sts.us-east-1.amazonaws.com/?Action=GetCallerIdentity&"))
+}
+
+func TestGKETokenProvider_ClusterToken(t *testing.T) {
+	g := NewWithT(t)
+
+	const wip = "//iam.googleapis.com/projects/123/locations/global/workloadIdentityPools/pool/providers/provider"
+	sts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("subject_token") != "sa-token" || r.FormValue("audience") != wip {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`{"access_token": "federated", "expires_in": 3600}`))
+	}))
+	t.Cleanup(sts.Close)
+	iam := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer federated" ||
+			r.URL.Path != "/v1/projects/-/serviceAccounts/flux@project.iam.gserviceaccount.com:generateAccessToken" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Write([]byte(`{"accessToken": "impersonated", "expireTime": "2030-01-01T00:00:00Z"}`))
+	}))
+	t.Cleanup(iam.Close)
+
+	p := &GKETokenProvider{WorkloadIdentityProvider: wip, STSURL: sts.URL}
+	g.Expect(p.Audience()).To(Equal("https:" + wip))
+	token, err := p.ClusterToken(context.TODO(), "sa-token")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(token.Token).To(Equal("federated"))
+
+	p.ServiceAccountEmail = "flux@project.iam.gserviceaccount.com"
+	p.IAMCredentialsURL = iam.URL
+	token, err = p.ClusterToken(context.TODO(), "sa-token")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(token.Token).To(Equal("impersonated"))
+	g.Expect(token.ExpiresAt).To(Equal(time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)))
+
+	_, err = p.ClusterToken(context.TODO(), "other-token")
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestAKSTokenProvider_ClusterToken(t *testing.T) {
+	g := NewWithT(t)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/tenant/oauth2/v2.0/token" ||
+			r.FormValue("client_assertion") != "sa-token" ||
+			r.FormValue("client_id") != "client" ||
+			r.FormValue("scope") != aksServerApplicationID+"/.default" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`{"access_token": "aks-token", "expires_in": 3600}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	p := &AKSTokenProvider{TenantID: "tenant", ClientID: "client", AuthorityHost: srv.URL}
+	token, err := p.ClusterToken(context.TODO(), "sa-token")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(token.Token).To(Equal("aks-token"))
+	g.Expect(token.ExpiresAt).To(BeTemporally("~", time.Now().Add(time.Hour), time.Minute))
+
+	p.ClientID = "other"
+	_, err = p.ClusterToken(context.TODO(), "sa-token")
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.Error()).To(ContainSubstring("unexpected status"))
+}
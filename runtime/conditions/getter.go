@@ -153,12 +153,12 @@ func summary(from Getter, t string, options ...MergeOption) *metav1.Condition {
 		o(mergeOpt)
 	}
 
-	// Identifies the conditions in scope for the Summary by taking all the existing conditions except t,
-	// or, if a list of conditions types is specified, only the conditions the condition in that list.
+	// Identifies the conditions in scope for the Summary by taking all the existing conditions except t and the
+	// top level conditions, or, if a list of conditions types is specified, only the conditions the condition in that list.
 	conditionsInScope := make([]localizedCondition, 0, len(conditions))
 	for i := range conditions {
 		c := conditions[i]
-		if c.Type == t {
+		if c.Type == t || stringInSlice(mergeOpt.topLevelConditions, c.Type) {
 			continue
 		}
 
@@ -181,6 +181,7 @@ func TestSummary(t *testing.T) {
 	tests := []struct {
 		name    string
 		from    Getter
+		target  string
 		options []MergeOption
 		want    *metav1.Condition
 	}{
@@ -265,13 +266,40 @@ func TestSummary(t *testing.T) {
 			from: getterWithConditions(existingReady, foo, bar),
 			want: FalseCondition(meta.ReadyCondition, "reason falseBar", "message falseBar"),
 		},
+		{
+			name:    "Ignores top level conditions when computing the summary",
+			from:    getterWithConditions(existingReady, foo, bar),
+			target:  "Degraded",
+			options: []MergeOption{WithTopLevelConditions(meta.ReadyCondition)},
+			want:    FalseCondition("Degraded", "reason falseBar", "message falseBar"),
+		},
+		{
+			name:    "Summary respects condition weights",
+			from:    getterWithConditions(bar, baz),
+			options: []MergeOption{WithConditionWeights(map[string]int{"baz": 0, "bar": 1})},
+			want:    FalseCondition(meta.ReadyCondition, "reason falseBaz", "message falseBaz"),
+		},
+		{
+			name:   "Summary of a negative polarity target condition",
+			from:   getterWithConditions(foo, bar),
+			target: meta.StalledCondition,
+			options: []MergeOption{
+				WithNegativePolarityConditions(meta.StalledCondition),
+				WithTopLevelConditions(meta.ReadyCondition),
+			},
+			want: TrueCondition(meta.StalledCondition, "reason falseBar", "message falseBar"),
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			g := NewWithT(t)
 
-			got := summary(tt.from, meta.ReadyCondition, tt.options...)
+			target := meta.ReadyCondition
+			if tt.target != "" {
+				target = tt.target
+			}
+			got := summary(tt.from, target, tt.options...)
 			if tt.want == nil {
 				g.Expect(got).To(BeNil())
 				return
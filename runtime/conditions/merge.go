@@ -144,6 +144,9 @@ func getConditionGroups(conditions []localizedCondition, options *mergeOptions)
 			a := groups[0].conditions[i]
 			b := groups[0].conditions[j]
 			if a.Type != b.Type {
+				if options.conditionWeights != nil {
+					return weightedLess(a.Condition, b.Condition, options.conditionWeights)
+				}
 				return lexicographicLess(a.Condition, b.Condition)
 			}
 			return a.GetName() < b.GetName()
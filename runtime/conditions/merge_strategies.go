@@ -44,8 +44,28 @@ type mergeOptions struct {
 	stepCounter int
 
 	withLatestGeneration bool
+
+	conditionWeights   map[string]int
+	topLevelConditions []string
+	reasonSelection    ReasonSelection
 }
 
+// ReasonSelection defines the rule used to select the condition in the top group from which the Reason and Message
+// for the target condition are taken.
+type ReasonSelection string
+
+const (
+	// ReasonSelectionFirst selects the first condition of the top group, taking into account the order defined by
+	// WithConditions and the weights defined by WithConditionWeights. This is the default.
+	ReasonSelectionFirst ReasonSelection = "First"
+	// ReasonSelectionLatestTransition selects the condition of the top group with the most recent
+	// LastTransitionTime. Ties are resolved using the ReasonSelectionFirst order.
+	ReasonSelectionLatestTransition ReasonSelection = "LatestTransition"
+	// ReasonSelectionOldestTransition selects the condition of the top group with the least recent
+	// LastTransitionTime. Ties are resolved using the ReasonSelectionFirst order.
+	ReasonSelectionOldestTransition ReasonSelection = "OldestTransition"
+)
+
 // MergeOption defines an option for computing a summary of conditions.
 type MergeOption func(*mergeOptions)
 
@@ -141,10 +161,54 @@ func WithSourceRefIf(t ...string) MergeOption {
 	}
 }
 
+// WithConditionWeights instructs merge about the weight of condition types when selecting the condition the Reason and
+// Message of the target condition are taken from. Condition types with a lower weight take precedence over the ones
+// with a higher weight, and condition types without a weight go last. If this option is not specified, the Stalled,
+// Reconciling and Ready condition types take precedence in this order.
+//
+// NOTE: The order of condition types defined by WithConditions takes precedence over the weights.
+// IMPORTANT: This option works only while generating the Summary or Aggregated condition.
+func WithConditionWeights(weights map[string]int) MergeOption {
+	return func(c *mergeOptions) {
+		c.conditionWeights = weights
+	}
+}
+
+// WithTopLevelConditions instructs summary about condition types that summarize the state of the object, e.g.
+// Ready, Stalled or Degraded. These condition types are never considered when computing the summary, in the same way
+// as the target condition is not. This allows to model a hierarchy of summary conditions on the same object.
+//
+// IMPORTANT: This option works only while generating the Summary condition.
+func WithTopLevelConditions(t ...string) MergeOption {
+	return func(c *mergeOptions) {
+		c.topLevelConditions = t
+	}
+}
+
+// WithReasonSelection instructs merge about the rule used to select the condition in the top group from which the
+// Reason and Message of the target condition are taken. If this option is not specified, ReasonSelectionFirst is
+// used.
+//
+// IMPORTANT: This option works only while generating the Summary or Aggregated condition.
+func WithReasonSelection(s ReasonSelection) MergeOption {
+	return func(c *mergeOptions) {
+		c.reasonSelection = s
+	}
+}
+
 // getReason returns the reason to be applied to the condition resulting by merging a set of condition groups.
 // The reason is computed according to the given mergeOptions.
 func getReason(groups conditionGroups, options *mergeOptions) string {
-	return getFirstReason(groups, options.conditionTypes, options.addSourceRef)
+	if options.reasonSelection == "" || options.reasonSelection == ReasonSelectionFirst {
+		return getFirstReason(groups, options.conditionTypes, options.addSourceRef)
+	}
+	if condition := getTransitionCondition(groups, options); condition != nil {
+		if options.addSourceRef {
+			return localizeReason(condition.Reason, condition.Getter)
+		}
+		return condition.Reason
+	}
+	return ""
 }
 
 // getFirstReason returns the first reason from the ordered list of conditions in the top group.
@@ -178,7 +242,37 @@ func getMessage(groups conditionGroups, options *mergeOptions) string {
 	if options.addCounter {
 		return getCounterMessage(groups, options.stepCounter)
 	}
-	return getFirstMessage(groups, options.conditionTypes)
+	if options.reasonSelection == "" || options.reasonSelection == ReasonSelectionFirst {
+		return getFirstMessage(groups, options.conditionTypes)
+	}
+	if condition := getTransitionCondition(groups, options); condition != nil {
+		return condition.Message
+	}
+	return ""
+}
+
+// getTransitionCondition returns the condition in the top group with the most or least recent LastTransitionTime,
+// according to the ReasonSelection of the given mergeOptions. Ties are resolved using the order of getFirstCondition.
+func getTransitionCondition(g conditionGroups, options *mergeOptions) *localizedCondition {
+	selected := getFirstCondition(g, options.conditionTypes)
+	if selected == nil {
+		return nil
+	}
+	topGroup := g.TopGroup()
+	for i := range topGroup.conditions {
+		c := &topGroup.conditions[i]
+		switch options.reasonSelection {
+		case ReasonSelectionLatestTransition:
+			if c.LastTransitionTime.After(selected.LastTransitionTime.Time) {
+				selected = c
+			}
+		case ReasonSelectionOldestTransition:
+			if c.LastTransitionTime.Before(&selected.LastTransitionTime) {
+				selected = c
+			}
+		}
+	}
+	return selected
 }
 
 // getCounterMessage returns a "x of y <Type>", where x is the number of conditions in the top group, y is the number
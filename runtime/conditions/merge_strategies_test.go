@@ -25,6 +25,7 @@ package conditions
 
 import (
 	"testing"
+	"time"
 
 	. "github.com/onsi/gomega"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -114,3 +115,37 @@ func TestGetFirstReasonAndMessage(t *testing.T) {
 	gotReason = getFirstReason(groups, nil, true)
 	g.Expect(gotReason).To(Equal("falseBar @ Fake/test-fake"))
 }
+
+func TestGetReasonAndMessageWithReasonSelection(t *testing.T) {
+	g := NewWithT(t)
+
+	now := metav1.Now()
+	foo := FalseCondition("foo", "falseFoo", "message falseFoo")
+	foo.LastTransitionTime = metav1.NewTime(now.Add(-time.Minute))
+	bar := FalseCondition("bar", "falseBar", "message falseBar")
+	bar.LastTransitionTime = now
+	baz := FalseCondition("baz", "falseBaz", "message falseBaz")
+	baz.LastTransitionTime = now
+
+	setter := &testdata.Fake{}
+	groups := getConditionGroups(conditionsWithSource(setter, foo, bar, baz), &mergeOptions{})
+
+	// ReasonSelectionFirst should report the first condition in lexicographical order
+	opts := &mergeOptions{reasonSelection: ReasonSelectionFirst}
+	g.Expect(getReason(groups, opts)).To(Equal("falseBar"))
+	g.Expect(getMessage(groups, opts)).To(Equal("message falseBar"))
+
+	// ReasonSelectionOldestTransition should report the condition with the oldest transition
+	opts = &mergeOptions{reasonSelection: ReasonSelectionOldestTransition}
+	g.Expect(getReason(groups, opts)).To(Equal("falseFoo"))
+	g.Expect(getMessage(groups, opts)).To(Equal("message falseFoo"))
+
+	// ReasonSelectionLatestTransition should resolve ties using the lexicographical order
+	opts = &mergeOptions{reasonSelection: ReasonSelectionLatestTransition}
+	g.Expect(getReason(groups, opts)).To(Equal("falseBar"))
+	g.Expect(getMessage(groups, opts)).To(Equal("message falseBar"))
+
+	// ReasonSelectionLatestTransition should resolve ties using the order of conditions
+	opts = &mergeOptions{reasonSelection: ReasonSelectionLatestTransition, conditionTypes: []string{"baz", "bar", "foo"}}
+	g.Expect(getReason(groups, opts)).To(Equal("falseBaz"))
+}
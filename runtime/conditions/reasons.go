@@ -0,0 +1,207 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package conditions
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ErrUnknownReason is returned when the reason of a condition is not
+// registered for its type.
+var ErrUnknownReason = errors.New("unknown condition reason")
+
+// reasonRegexp is the format of the reasons accepted by the Kubernetes API
+// for metav1.Condition.
+var reasonRegexp = regexp.MustCompile(`^[A-Za-z]([A-Za-z0-9_,:]*[A-Za-z0-9_])?$`)
+
+// maxReasonLength is the maximum length of a metav1.Condition reason.
+const maxReasonLength = 1024
+
+// ReasonRegistry records the reasons allowed for each condition type, along
+// with their description. It allows controllers to validate the reasons they
+// set, so that a misspelled reason does not silently break the alerts keyed
+// on it, and to generate the documentation of their reasons.
+//
+// The reasons of the condition types which are not registered are not
+// validated, so that the registry can be adopted one condition type at a
+// time.
+type ReasonRegistry struct {
+	mu      sync.RWMutex
+	reasons map[string]map[string]string
+	common  map[string]string
+}
+
+// NewReasonRegistry returns an empty ReasonRegistry.
+func NewReasonRegistry() *ReasonRegistry {
+	return &ReasonRegistry{
+		reasons: make(map[string]map[string]string),
+		common:  make(map[string]string),
+	}
+}
+
+// Register allows the given reason for the condition type. It returns an
+// error if the reason is not accepted by the Kubernetes API.
+func (r *ReasonRegistry) Register(conditionType, reason, description string) error {
+	if err := validReason(reason); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.reasons[conditionType] == nil {
+		r.reasons[conditionType] = make(map[string]string)
+	}
+	r.reasons[conditionType][reason] = description
+	return nil
+}
+
+// RegisterCommon allows the given reason for all the registered condition
+// types, e.g. the generic reasons of the meta package like
+// meta.SucceededReason. It returns an error if the reason is not accepted by
+// the Kubernetes API.
+func (r *ReasonRegistry) RegisterCommon(reason, description string) error {
+	if err := validReason(reason); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.common[reason] = description
+	return nil
+}
+
+// Allowed returns true if the reason is allowed for the condition type, or
+// if no reasons are registered for the type.
+func (r *ReasonRegistry) Allowed(conditionType, reason string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	reasons, ok := r.reasons[conditionType]
+	if !ok {
+		return true
+	}
+	if _, ok := reasons[reason]; ok {
+		return true
+	}
+	_, ok = r.common[reason]
+	return ok
+}
+
+// Validate returns an error wrapping ErrUnknownReason if the reason of the
+// condition is not allowed for its type.
+func (r *ReasonRegistry) Validate(condition *metav1.Condition) error {
+	if !r.Allowed(condition.Type, condition.Reason) {
+		return fmt.Errorf("%w '%s' for condition type '%s'", ErrUnknownReason, condition.Reason, condition.Type)
+	}
+	return nil
+}
+
+// WriteMarkdown writes the documentation of the registered reasons to the
+// writer, as a Markdown table per condition type, sorted by name.
+func (r *ReasonRegistry) WriteMarkdown(w io.Writer) error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	types := make([]string, 0, len(r.reasons))
+	for t := range r.reasons {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+
+	var err error
+	write := func(format string, args ...interface{}) {
+		if err == nil {
+			_, err = fmt.Fprintf(w, format, args...)
+		}
+	}
+	writeTable := func(title string, reasons map[string]string) {
+		names := make([]string, 0, len(reasons))
+		for name := range reasons {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		write("## %s\n\n| Reason | Description |\n| --- | --- |\n", title)
+		for _, name := range names {
+			write("| `%s` | %s |\n", name, reasons[name])
+		}
+		write("\n")
+	}
+
+	for _, t := range types {
+		writeTable(t, r.reasons[t])
+	}
+	if len(r.common) > 0 {
+		writeTable("Common reasons", r.common)
+	}
+	return err
+}
+
+func validReason(reason string) error {
+	if len(reason) > maxReasonLength || !reasonRegexp.MatchString(reason) {
+		return fmt.Errorf("invalid condition reason '%s': must match '%s'", reason, reasonRegexp.String())
+	}
+	return nil
+}
+
+// strictReasons is the configuration of the reason validation of Set.
+type strictReasons struct {
+	registry  *ReasonRegistry
+	onInvalid func(error)
+}
+
+var strict atomic.Pointer[strictReasons]
+
+// EnableStrictReasons makes Set, and all the helpers setting conditions,
+// validate the reasons of the conditions against the registry. The onInvalid
+// function is called with the validation error of each invalid reason, the
+// condition being set regardless. When nil, Set panics instead, which is
+// meant to detect invalid reasons in the tests of controllers.
+//
+// Note that the summary, mirror and aggregate conditions take the reason of
+// other conditions, which must then be registered for their types as well.
+func EnableStrictReasons(registry *ReasonRegistry, onInvalid func(error)) {
+	if onInvalid == nil {
+		onInvalid = func(err error) {
+			panic(err)
+		}
+	}
+	strict.Store(&strictReasons{registry: registry, onInvalid: onInvalid})
+}
+
+// DisableStrictReasons disables the validation enabled by
+// EnableStrictReasons.
+func DisableStrictReasons() {
+	strict.Store(nil)
+}
+
+// validateReason validates the reason of the condition if strict reasons are
+// enabled.
+func validateReason(condition *metav1.Condition) {
+	s := strict.Load()
+	if s == nil || s.registry == nil {
+		return
+	}
+	if err := s.registry.Validate(condition); err != nil {
+		s.onInvalid(err)
+	}
+}
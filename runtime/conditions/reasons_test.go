@@ -0,0 +1,88 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package conditions
+
+import (
+	"bytes"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/fluxcd/pkg/apis/meta"
+)
+
+func TestReasonRegistry(t *testing.T) {
+	g := NewWithT(t)
+
+	r := NewReasonRegistry()
+	g.Expect(r.Register(meta.ReadyCondition, "ArtifactFailed", "The artifact could not be fetched.")).To(Succeed())
+	g.Expect(r.RegisterCommon(meta.SucceededReason, "The reconciliation succeeded.")).To(Succeed())
+	g.Expect(r.Register(meta.ReadyCondition, "Not a reason", "")).ToNot(Succeed())
+	g.Expect(r.RegisterCommon("", "")).ToNot(Succeed())
+
+	g.Expect(r.Allowed(meta.ReadyCondition, "ArtifactFailed")).To(BeTrue())
+	g.Expect(r.Allowed(meta.ReadyCondition, meta.SucceededReason)).To(BeTrue())
+	g.Expect(r.Allowed(meta.ReadyCondition, "ArtefactFailed")).To(BeFalse())
+	// The reasons of unregistered condition types are not validated.
+	g.Expect(r.Allowed(meta.StalledCondition, "ArtefactFailed")).To(BeTrue())
+
+	g.Expect(r.Validate(TrueCondition(meta.ReadyCondition, "ArtifactFailed", ""))).To(Succeed())
+	err := r.Validate(FalseCondition(meta.ReadyCondition, "ArtefactFailed", ""))
+	g.Expect(err).To(MatchError(ErrUnknownReason))
+	g.Expect(err.Error()).To(ContainSubstring("'ArtefactFailed' for condition type 'Ready'"))
+
+	var buf bytes.Buffer
+	g.Expect(r.WriteMarkdown(&buf)).To(Succeed())
+	g.Expect(buf.String()).To(Equal("## Ready\n\n" +
+		"| Reason | Description |\n| --- | --- |\n" +
+		"| `ArtifactFailed` | The artifact could not be fetched. |\n\n" +
+		"## Common reasons\n\n" +
+		"| Reason | Description |\n| --- | --- |\n" +
+		"| `Succeeded` | The reconciliation succeeded. |\n\n"))
+}
+
+func TestEnableStrictReasons(t *testing.T) {
+	g := NewWithT(t)
+
+	r := NewReasonRegistry()
+	g.Expect(r.Register(meta.ReadyCondition, meta.SucceededReason, "")).To(Succeed())
+
+	var errs []error
+	EnableStrictReasons(r, func(err error) {
+		errs = append(errs, err)
+	})
+	defer DisableStrictReasons()
+
+	obj := setterWithConditions()
+	MarkTrue(obj, meta.ReadyCondition, meta.SucceededReason, "")
+	g.Expect(errs).To(BeEmpty())
+	MarkFalse(obj, meta.ReadyCondition, "Succeded", "")
+	g.Expect(errs).To(HaveLen(1))
+	g.Expect(errs[0]).To(MatchError(ErrUnknownReason))
+	// The condition is set regardless.
+	g.Expect(GetReason(obj, meta.ReadyCondition)).To(Equal("Succeded"))
+
+	EnableStrictReasons(r, nil)
+	g.Expect(func() {
+		MarkFalse(obj, meta.ReadyCondition, "Succeded", "")
+	}).To(PanicWith(MatchError(ErrUnknownReason)))
+
+	DisableStrictReasons()
+	g.Expect(func() {
+		MarkFalse(obj, meta.ReadyCondition, "Succeded", "")
+	}).ToNot(Panic())
+}
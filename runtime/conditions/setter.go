@@ -44,11 +44,15 @@ type Setter interface {
 //
 // NOTE: If a condition already exists, the LastTransitionTime is updated only if a change is detected in any of the
 // following fields: Status, Reason, and Message. The ObservedGeneration is always updated.
+// The Reason is validated if strict reasons are enabled, see EnableStrictReasons.
 func Set(to Setter, condition *metav1.Condition) {
 	if to == nil || condition == nil {
 		return
 	}
 
+	// Validate the reason if strict reasons are enabled.
+	validateReason(condition)
+
 	// Always set the observed generation on the condition.
 	condition.ObservedGeneration = to.GetGeneration()
 
@@ -205,8 +209,15 @@ var conditionWeights = map[string]int{
 // sorted by their defined weight, followed by all the other conditions sorted by highest observedGeneration and
 // lexicographically by Type.
 func lexicographicLess(i, j *metav1.Condition) bool {
-	w1, ok1 := conditionWeights[i.Type]
-	w2, ok2 := conditionWeights[j.Type]
+	return weightedLess(i, j, conditionWeights)
+}
+
+// weightedLess returns true if a condition is less than another in regard to the given weights. The condition types
+// in weights always go first, sorted by their defined weight, followed by all the other conditions sorted by highest
+// observedGeneration and lexicographically by Type.
+func weightedLess(i, j *metav1.Condition, weights map[string]int) bool {
+	w1, ok1 := weights[i.Type]
+	w2, ok2 := weights[j.Type]
 	switch {
 	case ok1 && ok2:
 		return w1 < w2
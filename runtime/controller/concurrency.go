@@ -0,0 +1,348 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/spf13/pflag"
+	crtlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	defaultMinConcurrentReconciles = 1
+	defaultMaxConcurrentReconciles = 10
+	defaultConcurrencyTuneInterval = 30 * time.Second
+	flagConcurrencyAutoTune        = "concurrent-auto-tune"
+	flagMinConcurrentReconciles    = "concurrent-min"
+	flagConcurrencyTuneInterval    = "concurrent-tune-interval"
+	flagConcurrencyTargetLatency   = "concurrent-target-latency"
+
+	// latencyWindowSize is the number of reconcile durations taken into
+	// account to calculate the latency percentile.
+	latencyWindowSize = 100
+	// latencyPercentile is the percentile of the reconcile durations that
+	// is compared against the target latency.
+	latencyPercentile = 0.9
+)
+
+// ConcurrencyOptions defines the configurable options for the auto-tuning
+// of the number of concurrent reconciles of a controller.
+type ConcurrencyOptions struct {
+	// AutoTune enables the auto-tuning of the number of concurrent
+	// reconciles. If false, MaxConcurrentReconciles is used as a fixed value.
+	AutoTune bool
+
+	// MinConcurrentReconciles is the lower bound of the number of
+	// concurrent reconciles.
+	MinConcurrentReconciles int
+
+	// MaxConcurrentReconciles is the upper bound of the number of
+	// concurrent reconciles. It is not bound to a flag, and is expected
+	// to be set to the value of the '--concurrent' flag of the controller.
+	MaxConcurrentReconciles int
+
+	// TuneInterval is the interval at which the number of concurrent
+	// reconciles is adjusted.
+	TuneInterval time.Duration
+
+	// TargetLatency is the 90th percentile of the reconcile latency above
+	// which the number of concurrent reconciles is decreased. If zero, the
+	// latency is not taken into account.
+	TargetLatency time.Duration
+}
+
+// BindFlags will parse the given pflag.FlagSet for the controller and
+// set the ConcurrencyOptions accordingly. MaxConcurrentReconciles must be
+// set by the caller from the existing '--concurrent' flag.
+func (o *ConcurrencyOptions) BindFlags(fs *pflag.FlagSet) {
+	fs.BoolVar(&o.AutoTune, flagConcurrencyAutoTune, false,
+		"Adjust the number of concurrent reconciles based on the depth of the work queue and the reconcile latency.")
+	fs.IntVar(&o.MinConcurrentReconciles, flagMinConcurrentReconciles, defaultMinConcurrentReconciles,
+		"The minimum number of concurrent reconciles when auto-tuning is enabled.")
+	fs.DurationVar(&o.TuneInterval, flagConcurrencyTuneInterval, defaultConcurrencyTuneInterval,
+		"The interval at which the number of concurrent reconciles is adjusted when auto-tuning is enabled.")
+	fs.DurationVar(&o.TargetLatency, flagConcurrencyTargetLatency, 0,
+		"The 90th percentile of the reconcile latency above which the number of concurrent reconciles is decreased when auto-tuning is enabled.")
+}
+
+// ConcurrencyTuner adjusts the number of concurrent reconciles of a
+// controller between a minimum and a maximum, based on the depth of the
+// work queue of the controller and the latency of its reconciles.
+//
+// As the number of workers of a controller can not be changed after it is
+// created, the controller is expected to be configured with
+// MaxConcurrentReconciles workers, of which only the current number of
+// concurrent reconciles is allowed to reconcile at the same time:
+//
+//	opts.MaxConcurrentReconciles = concurrent
+//	tuner := controller.NewConcurrencyTuner("kustomization", opts)
+//	crtlmetrics.Registry.MustRegister(tuner.Collectors()...)
+//	if err := mgr.Add(tuner); err != nil {
+//		return err
+//	}
+//	return ctrl.NewControllerManagedBy(mgr).
+//		For(&v1.Kustomization{}).
+//		WithOptions(controller.Options{
+//			MaxConcurrentReconciles: tuner.MaxConcurrentReconciles(),
+//		}).
+//		Complete(tuner.Wrap(r))
+type ConcurrencyTuner struct {
+	name string
+	opts ConcurrencyOptions
+
+	// QueueDepth returns the current depth of the work queue of the
+	// controller. It defaults to reading the 'workqueue_depth' metric of
+	// the controller from the controller-runtime metrics registry.
+	QueueDepth func() (int, error)
+
+	mu        sync.Mutex
+	limit     int
+	active    int
+	waiting   int
+	released  chan struct{}
+	latencies []time.Duration
+	next      int
+
+	gauge prometheus.Gauge
+}
+
+// NewConcurrencyTuner returns a new ConcurrencyTuner for the controller with
+// the given name, which must match the name of its work queue.
+func NewConcurrencyTuner(name string, opts ConcurrencyOptions) *ConcurrencyTuner {
+	if opts.MaxConcurrentReconciles < 1 {
+		opts.MaxConcurrentReconciles = defaultMaxConcurrentReconciles
+	}
+	if opts.MinConcurrentReconciles < 1 {
+		opts.MinConcurrentReconciles = defaultMinConcurrentReconciles
+	}
+	if opts.MinConcurrentReconciles > opts.MaxConcurrentReconciles {
+		opts.MinConcurrentReconciles = opts.MaxConcurrentReconciles
+	}
+	if opts.TuneInterval <= 0 {
+		opts.TuneInterval = defaultConcurrencyTuneInterval
+	}
+
+	t := &ConcurrencyTuner{
+		name:     name,
+		opts:     opts,
+		limit:    opts.MaxConcurrentReconciles,
+		released: make(chan struct{}),
+		gauge: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name:        "gotk_reconcile_concurrency",
+			Help:        "The current number of concurrent reconciles allowed for a GitOps Toolkit controller.",
+			ConstLabels: prometheus.Labels{"controller": name},
+		}),
+	}
+	if opts.AutoTune {
+		t.limit = opts.MinConcurrentReconciles
+	}
+	t.QueueDepth = func() (int, error) {
+		return registryQueueDepth(crtlmetrics.Registry, t.name)
+	}
+	t.gauge.Set(float64(t.limit))
+	return t
+}
+
+// Collectors returns a slice of Prometheus collectors, which can be used to
+// register them in a metrics registry.
+func (t *ConcurrencyTuner) Collectors() []prometheus.Collector {
+	return []prometheus.Collector{t.gauge}
+}
+
+// MaxConcurrentReconciles returns the number of workers the controller
+// must be configured with.
+func (t *ConcurrencyTuner) MaxConcurrentReconciles() int {
+	return t.opts.MaxConcurrentReconciles
+}
+
+// ConcurrentReconciles returns the current number of concurrent reconciles.
+func (t *ConcurrencyTuner) ConcurrentReconciles() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.limit
+}
+
+// Wrap returns a reconcile.Reconciler which limits the number of concurrent
+// reconciles of r to the current value, and records their latency. If
+// auto-tuning is disabled, r is returned as is.
+func (t *ConcurrencyTuner) Wrap(r reconcile.Reconciler) reconcile.Reconciler {
+	if !t.opts.AutoTune {
+		return r
+	}
+	return reconcile.Func(func(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+		if err := t.acquire(ctx); err != nil {
+			return reconcile.Result{}, err
+		}
+		start := time.Now()
+		defer func() {
+			t.release(time.Since(start))
+		}()
+		return r.Reconcile(ctx, req)
+	})
+}
+
+// Start adjusts the number of concurrent reconciles at every tune interval
+// until the context is canceled. It implements manager.Runnable, and
+// returns immediately if auto-tuning is disabled.
+func (t *ConcurrencyTuner) Start(ctx context.Context) error {
+	if !t.opts.AutoTune {
+		return nil
+	}
+	ticker := time.NewTicker(t.opts.TuneInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			depth, err := t.QueueDepth()
+			if err != nil {
+				continue
+			}
+			t.tune(depth)
+		}
+	}
+}
+
+// tune increases the number of concurrent reconciles by one when items are
+// waiting in the queue and the latency is within the target, and decreases
+// it by one when the queue is empty or the latency exceeds the target.
+// The reconciles blocked in acquire are counted as waiting in the queue,
+// since they have been taken out of the queue by the workers.
+func (t *ConcurrencyTuner) tune(depth int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	depth += t.waiting
+
+	overTarget := t.opts.TargetLatency > 0 && t.latencyPercentile(latencyPercentile) > t.opts.TargetLatency
+	limit := t.limit
+	switch {
+	case overTarget || depth == 0:
+		limit--
+	case depth > 0:
+		limit++
+	}
+	if limit < t.opts.MinConcurrentReconciles {
+		limit = t.opts.MinConcurrentReconciles
+	}
+	if limit > t.opts.MaxConcurrentReconciles {
+		limit = t.opts.MaxConcurrentReconciles
+	}
+	if limit != t.limit {
+		t.limit = limit
+		t.gauge.Set(float64(limit))
+		t.broadcast()
+	}
+}
+
+// acquire blocks until the number of active reconciles is below the
+// current limit, or the context is canceled.
+func (t *ConcurrencyTuner) acquire(ctx context.Context) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for t.active >= t.limit {
+		released := t.released
+		t.waiting++
+		t.mu.Unlock()
+
+		var err error
+		select {
+		case <-released:
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+
+		t.mu.Lock()
+		t.waiting--
+		if err != nil {
+			return err
+		}
+	}
+	t.active++
+	return nil
+}
+
+// release marks a reconcile which took the given duration as done.
+func (t *ConcurrencyTuner) release(d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.active--
+	if len(t.latencies) < latencyWindowSize {
+		t.latencies = append(t.latencies, d)
+	} else {
+		t.latencies[t.next] = d
+		t.next = (t.next + 1) % latencyWindowSize
+	}
+	t.broadcast()
+}
+
+// broadcast wakes up all reconciles waiting in acquire. It must be called
+// with the lock held.
+func (t *ConcurrencyTuner) broadcast() {
+	close(t.released)
+	t.released = make(chan struct{})
+}
+
+// latencyPercentile returns the given percentile of the recorded reconcile
+// durations. It must be called with the lock held.
+func (t *ConcurrencyTuner) latencyPercentile(p float64) time.Duration {
+	if len(t.latencies) == 0 {
+		return 0
+	}
+	sorted := make([]time.Duration, len(t.latencies))
+	copy(sorted, t.latencies)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted[int(p*float64(len(sorted)-1))]
+}
+
+// registryQueueDepth returns the value of the 'workqueue_depth' metric for
+// the work queue with the given name.
+func registryQueueDepth(g prometheus.Gatherer, name string) (int, error) {
+	families, err := g.Gather()
+	if err != nil {
+		return 0, err
+	}
+	for _, family := range families {
+		if family.GetName() != "workqueue_depth" {
+			continue
+		}
+		for _, m := range family.GetMetric() {
+			if hasLabel(m, "name", name) {
+				return int(m.GetGauge().GetValue()), nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("no workqueue_depth metric found for queue '%s'", name)
+}
+
+func hasLabel(m *dto.Metric, name, value string) bool {
+	for _, l := range m.GetLabel() {
+		if l.GetName() == name && l.GetValue() == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,167 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/spf13/pflag"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func Test_ConcurrencyOptions_BindFlags(t *testing.T) {
+	g := NewWithT(t)
+
+	f := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	var opts ConcurrencyOptions
+	opts.BindFlags(f)
+
+	err := f.Parse([]string{"--concurrent-auto-tune", "--concurrent-min=2", "--concurrent-target-latency=1m"})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(f.Lookup("concurrent-max")).To(BeNil())
+	g.Expect(opts).To(Equal(ConcurrencyOptions{
+		AutoTune:                true,
+		MinConcurrentReconciles: 2,
+		TuneInterval:            defaultConcurrencyTuneInterval,
+		TargetLatency:           time.Minute,
+	}))
+}
+
+func TestConcurrencyTuner_tune(t *testing.T) {
+	g := NewWithT(t)
+
+	tuner := NewConcurrencyTuner("test", ConcurrencyOptions{
+		AutoTune:                true,
+		MinConcurrentReconciles: 1,
+		MaxConcurrentReconciles: 3,
+		TargetLatency:           time.Second,
+	})
+	g.Expect(tuner.ConcurrentReconciles()).To(Equal(1))
+	g.Expect(testutil.ToFloat64(tuner.gauge)).To(Equal(float64(1)))
+
+	// Scale up while items are queued, up to the max.
+	for i := 0; i < 5; i++ {
+		tuner.tune(10)
+	}
+	g.Expect(tuner.ConcurrentReconciles()).To(Equal(3))
+	g.Expect(testutil.ToFloat64(tuner.gauge)).To(Equal(float64(3)))
+
+	// Scale down when the latency exceeds the target.
+	tuner.release(2 * time.Second)
+	tuner.tune(10)
+	g.Expect(tuner.ConcurrentReconciles()).To(Equal(2))
+
+	// Scale down when the queue is empty, down to the min.
+	tuner.latencies = nil
+	for i := 0; i < 5; i++ {
+		tuner.tune(0)
+	}
+	g.Expect(tuner.ConcurrentReconciles()).To(Equal(1))
+}
+
+func TestConcurrencyTuner_Wrap(t *testing.T) {
+	g := NewWithT(t)
+
+	tuner := NewConcurrencyTuner("test", ConcurrencyOptions{
+		AutoTune:                true,
+		MinConcurrentReconciles: 1,
+		MaxConcurrentReconciles: 4,
+	})
+
+	var running, maxRunning int32
+	block := make(chan struct{})
+	r := tuner.Wrap(reconcile.Func(func(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			m := atomic.LoadInt32(&maxRunning)
+			if n <= m || atomic.CompareAndSwapInt32(&maxRunning, m, n) {
+				break
+			}
+		}
+		<-block
+		atomic.AddInt32(&running, -1)
+		return reconcile.Result{}, nil
+	}))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _ = r.Reconcile(context.TODO(), reconcile.Request{})
+		}()
+	}
+
+	g.Eventually(func() int32 { return atomic.LoadInt32(&running) }).Should(Equal(int32(1)))
+
+	// The reconciles waiting for a slot are no longer in the queue, but
+	// must count towards its depth.
+	g.Eventually(func() int {
+		tuner.mu.Lock()
+		defer tuner.mu.Unlock()
+		return tuner.waiting
+	}).Should(Equal(3))
+	tuner.tune(0)
+	g.Eventually(func() int32 { return atomic.LoadInt32(&running) }).Should(Equal(int32(2)))
+
+	close(block)
+	wg.Wait()
+	g.Expect(atomic.LoadInt32(&maxRunning)).To(Equal(int32(2)))
+	g.Expect(tuner.latencies).To(HaveLen(4))
+}
+
+func TestConcurrencyTuner_Wrap_disabled(t *testing.T) {
+	g := NewWithT(t)
+
+	tuner := NewConcurrencyTuner("test", ConcurrencyOptions{MaxConcurrentReconciles: 5})
+	g.Expect(tuner.ConcurrentReconciles()).To(Equal(5))
+	g.Expect(tuner.MaxConcurrentReconciles()).To(Equal(5))
+
+	var called bool
+	r := tuner.Wrap(reconcile.Func(func(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+		called = true
+		return reconcile.Result{}, nil
+	}))
+	_, err := r.Reconcile(context.TODO(), reconcile.Request{})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(called).To(BeTrue())
+	g.Expect(tuner.latencies).To(BeEmpty())
+}
+
+func Test_registryQueueDepth(t *testing.T) {
+	g := NewWithT(t)
+
+	depth := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "workqueue_depth"}, []string{"name"})
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(depth)
+	depth.WithLabelValues("other").Set(1)
+	depth.WithLabelValues("test").Set(7)
+
+	got, err := registryQueueDepth(reg, "test")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(got).To(Equal(7))
+
+	_, err = registryQueueDepth(reg, "missing")
+	g.Expect(err).To(HaveOccurred())
+}
@@ -15,5 +15,5 @@ limitations under the License.
 */
 
 // Package dependency contains an utility for sorting a set of Kubernetes resource objects that implement the
-// Dependent interface.
+// Dependent interface, and helpers for enqueueing the Dependent objects when their dependencies become ready.
 package dependency
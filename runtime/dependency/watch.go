@@ -0,0 +1,181 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dependency
+
+import (
+	"context"
+
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/fluxcd/pkg/apis/meta"
+	"github.com/fluxcd/pkg/runtime/conditions"
+)
+
+// DependsOnIndexKey is the key of the field index of the Dependent objects
+// by the '<namespace>/<name>' of their dependencies.
+const DependsOnIndexKey = ".metadata.dependsOn"
+
+// IndexDependsOn is a client.IndexerFunc returning the '<namespace>/<name>'
+// of the dependencies of the given Dependent. A dependency without namespace
+// defaults to the namespace of the Dependent.
+func IndexDependsOn(o client.Object) []string {
+	d, ok := o.(Dependent)
+	if !ok {
+		return nil
+	}
+	deps := d.GetDependsOn()
+	if len(deps) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(deps))
+	for _, dep := range deps {
+		if dep.Namespace == "" {
+			dep.Namespace = d.GetNamespace()
+		}
+		keys = append(keys, namespacedNameObjRef(dep))
+	}
+	return keys
+}
+
+// SetupIndex registers the DependsOnIndexKey field index of the given
+// Dependent kind with the manager.
+func SetupIndex(ctx context.Context, mgr ctrl.Manager, obj Dependent) error {
+	return mgr.GetFieldIndexer().IndexField(ctx, obj, DependsOnIndexKey, IndexDependsOn)
+}
+
+// ReadyGate enqueues the Dependent objects for reconciliation when one of
+// their dependencies becomes ready, removing the need for requeueing them
+// at an interval while waiting for their dependencies. It is intended to be
+// used with a watch of the dependency kind, filtered with the
+// ReadyChangedPredicate, as in the following example:
+//
+//	gate := dependency.NewReadyGate(mgr.GetClient(), func() client.ObjectList {
+//		return &v1.MyCustomKindList{}
+//	})
+//	ctrl.NewControllerManagedBy(mgr).
+//		For(&v1.MyCustomKind{}).
+//		Watches(&v1.MyCustomKind{},
+//			handler.EnqueueRequestsFromMapFunc(gate.EnqueueDependents),
+//			builder.WithPredicates(dependency.ReadyChangedPredicate{}))
+//
+// The Dependent kind must be indexed with SetupIndex.
+type ReadyGate struct {
+	client  client.Reader
+	newList func() client.ObjectList
+}
+
+// NewReadyGate returns a ReadyGate listing the Dependent objects with the
+// given client, using the list type returned by newList.
+func NewReadyGate(c client.Reader, newList func() client.ObjectList) *ReadyGate {
+	return &ReadyGate{
+		client:  c,
+		newList: newList,
+	}
+}
+
+// EnqueueDependents returns a reconcile.Request for each Dependent object
+// which depends on the given object. It implements handler.MapFunc.
+func (g *ReadyGate) EnqueueDependents(ctx context.Context, obj client.Object) []reconcile.Request {
+	list := g.newList()
+	key := namespacedNameObjRef(meta.NamespacedObjectReference{
+		Namespace: obj.GetNamespace(),
+		Name:      obj.GetName(),
+	})
+	if err := g.client.List(ctx, list, client.MatchingFields{DependsOnIndexKey: key}); err != nil {
+		log.FromContext(ctx).Error(err, "failed to list objects for dependency change", "dependency", key)
+		return nil
+	}
+
+	items, err := apimeta.ExtractList(list)
+	if err != nil {
+		log.FromContext(ctx).Error(err, "failed to extract objects for dependency change", "dependency", key)
+		return nil
+	}
+	reqs := make([]reconcile.Request, 0, len(items))
+	for _, item := range items {
+		o, ok := item.(client.Object)
+		if !ok {
+			continue
+		}
+		reqs = append(reqs, reconcile.Request{NamespacedName: types.NamespacedName{
+			Namespace: o.GetNamespace(),
+			Name:      o.GetName(),
+		}})
+	}
+	return reqs
+}
+
+// ReadyChangedPredicate implements an event filter for objects becoming
+// ready, as reported by conditions.IsReady. Objects which do not implement
+// conditions.Getter are filtered out.
+type ReadyChangedPredicate struct {
+	predicate.Funcs
+}
+
+// Create allows the create events of ready objects, e.g. on the initial
+// list of the watch.
+func (ReadyChangedPredicate) Create(e event.CreateEvent) bool {
+	return isReady(e.Object)
+}
+
+// Update allows the update events of objects transitioning to ready, or
+// of ready objects which have been reconciled again, e.g. due to a change
+// of their revision.
+func (ReadyChangedPredicate) Update(e event.UpdateEvent) bool {
+	if e.ObjectOld == nil || e.ObjectNew == nil {
+		return false
+	}
+	if !isReady(e.ObjectNew) {
+		return false
+	}
+	return !isReady(e.ObjectOld) || readyState(e.ObjectOld) != readyState(e.ObjectNew)
+}
+
+// Delete filters out the delete events.
+func (ReadyChangedPredicate) Delete(event.DeleteEvent) bool {
+	return false
+}
+
+// Generic filters out the generic events.
+func (ReadyChangedPredicate) Generic(event.GenericEvent) bool {
+	return false
+}
+
+func isReady(o client.Object) bool {
+	g, ok := o.(conditions.Getter)
+	return ok && conditions.IsReady(g)
+}
+
+// readyState returns the last transition time and message of the Ready
+// condition, which change when an object is reconciled to a new revision.
+func readyState(o client.Object) string {
+	g, ok := o.(conditions.Getter)
+	if !ok {
+		return ""
+	}
+	if c := conditions.Get(g, meta.ReadyCondition); c != nil {
+		return c.LastTransitionTime.String() + "/" + c.Message
+	}
+	return ""
+}
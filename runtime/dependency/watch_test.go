@@ -0,0 +1,153 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dependency
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/fluxcd/pkg/apis/meta"
+	"github.com/fluxcd/pkg/runtime/conditions/testdata"
+)
+
+func TestIndexDependsOn(t *testing.T) {
+	g := NewWithT(t)
+
+	d := &MockDependent{
+		Node: corev1.Node{ObjectMeta: metav1.ObjectMeta{Namespace: "apps", Name: "frontend"}},
+		DependsOn: []meta.NamespacedObjectReference{
+			{Name: "backend"},
+			{Namespace: "infra", Name: "ingress"},
+		},
+	}
+	g.Expect(IndexDependsOn(d)).To(Equal([]string{"apps/backend", "infra/ingress"}))
+	g.Expect(IndexDependsOn(&corev1.ConfigMap{})).To(BeNil())
+}
+
+func TestReadyGate_EnqueueDependents(t *testing.T) {
+	g := NewWithT(t)
+
+	scheme := runtime.NewScheme()
+	g.Expect(testdata.AddFakeToScheme(scheme)).To(Succeed())
+
+	// The value of the spec is used as the dependency of the fake objects.
+	newFake := func(name, dependsOn string) *testdata.Fake {
+		return &testdata.Fake{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name},
+			Spec:       testdata.FakeSpec{Value: dependsOn},
+		}
+	}
+	c := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(newFake("backend", ""), newFake("frontend", "default/backend"), newFake("worker", "default/backend")).
+		WithIndex(&testdata.Fake{}, DependsOnIndexKey, func(o client.Object) []string {
+			if v := o.(*testdata.Fake).Spec.Value; v != "" {
+				return []string{v}
+			}
+			return nil
+		}).
+		Build()
+
+	gate := NewReadyGate(c, func() client.ObjectList { return &testdata.FakeList{} })
+
+	reqs := gate.EnqueueDependents(context.TODO(), newFake("backend", ""))
+	g.Expect(reqs).To(ConsistOf(
+		reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "frontend"}},
+		reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "worker"}},
+	))
+	g.Expect(gate.EnqueueDependents(context.TODO(), newFake("frontend", ""))).To(BeEmpty())
+}
+
+func TestReadyChangedPredicate(t *testing.T) {
+	newFake := func(status metav1.ConditionStatus, message string, at time.Time) *testdata.Fake {
+		f := &testdata.Fake{}
+		if status != "" {
+			f.Status.Conditions = []metav1.Condition{{
+				Type:               meta.ReadyCondition,
+				Status:             status,
+				Reason:             meta.SucceededReason,
+				Message:            message,
+				LastTransitionTime: metav1.NewTime(at),
+			}}
+		}
+		return f
+	}
+	now := time.Now().Truncate(time.Second)
+
+	tests := []struct {
+		name      string
+		oldObject client.Object
+		newObject client.Object
+		want      bool
+	}{
+		{
+			name:      "becomes ready",
+			oldObject: newFake(metav1.ConditionFalse, "", now),
+			newObject: newFake(metav1.ConditionTrue, "rev1", now),
+			want:      true,
+		},
+		{
+			name:      "no conditions to ready",
+			oldObject: newFake("", "", now),
+			newObject: newFake(metav1.ConditionTrue, "rev1", now),
+			want:      true,
+		},
+		{
+			name:      "ready unchanged",
+			oldObject: newFake(metav1.ConditionTrue, "rev1", now),
+			newObject: newFake(metav1.ConditionTrue, "rev1", now),
+			want:      false,
+		},
+		{
+			name:      "ready with new revision",
+			oldObject: newFake(metav1.ConditionTrue, "rev1", now),
+			newObject: newFake(metav1.ConditionTrue, "rev2", now),
+			want:      true,
+		},
+		{
+			name:      "becomes not ready",
+			oldObject: newFake(metav1.ConditionTrue, "rev1", now),
+			newObject: newFake(metav1.ConditionFalse, "", now),
+			want:      false,
+		},
+		{
+			name:      "not a conditions getter",
+			oldObject: &corev1.ConfigMap{},
+			newObject: &corev1.ConfigMap{},
+			want:      false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			p := ReadyChangedPredicate{}
+			g.Expect(p.Update(event.UpdateEvent{ObjectOld: tt.oldObject, ObjectNew: tt.newObject})).To(Equal(tt.want))
+		})
+	}
+}
@@ -0,0 +1,225 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package errors
+
+import (
+	"errors"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	"github.com/fluxcd/pkg/apis/meta"
+	"github.com/fluxcd/pkg/runtime/conditions"
+)
+
+// Type is the type of a reconcile error, it determines how the error is
+// reported in the object status conditions and events, and how the
+// reconciliation is retried.
+type Type string
+
+const (
+	// StalledType is the type of errors which can't be recovered from without
+	// a change to the object, the reconciliation is not retried.
+	StalledType Type = "Stalled"
+	// TransientType is the type of errors which may be recovered from by
+	// retrying, the reconciliation is retried with backoff.
+	TransientType Type = "Transient"
+	// AccessDeniedType is the type of errors caused by the controller lacking
+	// permissions, the reconciliation is retried at the given interval.
+	AccessDeniedType Type = "AccessDenied"
+	// DependencyNotReadyType is the type of errors caused by a dependency not
+	// being ready, the reconciliation is retried at the given interval.
+	DependencyNotReadyType Type = "DependencyNotReady"
+	// InvalidType is the type of errors caused by an invalid object spec,
+	// the reconciliation is not retried.
+	InvalidType Type = "Invalid"
+)
+
+const (
+	// AccessDeniedReason is the default condition reason of AccessDeniedType errors.
+	AccessDeniedReason string = "AccessDenied"
+	// InvalidReason is the default condition reason of InvalidType errors.
+	InvalidReason string = "InvalidSpec"
+)
+
+// Error is a typed reconcile error, it includes the Type, the condition Reason
+// and the underlying Err.
+type Error struct {
+	// Type of the error.
+	Type Type
+	// Reason is the reason recorded in the status conditions and events.
+	Reason string
+	// Err is the underlying error.
+	Err error
+	// RequeueAfter overrides the retry interval of the reconciliation.
+	RequeueAfter time.Duration
+}
+
+// NewStalled returns a StalledType error with the given reason.
+func NewStalled(reason string, err error) *Error {
+	return newError(StalledType, reason, err)
+}
+
+// NewTransient returns a TransientType error with the given reason.
+func NewTransient(reason string, err error) *Error {
+	return newError(TransientType, reason, err)
+}
+
+// NewAccessDenied returns an AccessDeniedType error with the given reason.
+func NewAccessDenied(reason string, err error) *Error {
+	return newError(AccessDeniedType, reason, err)
+}
+
+// NewDependencyNotReady returns a DependencyNotReadyType error with the given reason.
+func NewDependencyNotReady(reason string, err error) *Error {
+	return newError(DependencyNotReadyType, reason, err)
+}
+
+// NewInvalid returns an InvalidType error with the given reason.
+func NewInvalid(reason string, err error) *Error {
+	return newError(InvalidType, reason, err)
+}
+
+func newError(t Type, reason string, err error) *Error {
+	if reason == "" {
+		reason = defaultReason(t)
+	}
+	return &Error{Type: t, Reason: reason, Err: err}
+}
+
+func (e *Error) Error() string {
+	if e.Err == nil {
+		return e.Reason
+	}
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// WithRequeueAfter sets the retry interval of the reconciliation and returns the error.
+func (e *Error) WithRequeueAfter(interval time.Duration) *Error {
+	e.RequeueAfter = interval
+	return e
+}
+
+// Stalled returns true if the reconciliation can't proceed without a change
+// to the object.
+func (e *Error) Stalled() bool {
+	return e.Type == StalledType || e.Type == InvalidType
+}
+
+// TypeOf returns the Type of the given error. Errors which don't wrap
+// an *Error are considered transient, and a nil error has no type.
+func TypeOf(err error) Type {
+	if err == nil {
+		return ""
+	}
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Type
+	}
+	return TransientType
+}
+
+// ReasonOf returns the condition reason of the given error.
+func ReasonOf(err error) string {
+	if err == nil {
+		return ""
+	}
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Reason
+	}
+	return meta.FailedReason
+}
+
+// IsStalled returns true if the given error wraps a StalledType or InvalidType error.
+func IsStalled(err error) bool {
+	var e *Error
+	return errors.As(err, &e) && e.Stalled()
+}
+
+// SetConditions records the given error in the Ready and Stalled conditions of the
+// object. Stalled errors mark the object as Stalled and remove the Reconciling
+// condition, while the others remove the Stalled condition.
+// A nil error leaves the conditions untouched.
+func SetConditions(obj conditions.Setter, err error) {
+	if err == nil {
+		return
+	}
+
+	reason := ReasonOf(err)
+	if IsStalled(err) {
+		conditions.MarkStalled(obj, reason, "%s", err.Error())
+	} else {
+		conditions.Delete(obj, meta.StalledCondition)
+	}
+	conditions.MarkFalse(obj, meta.ReadyCondition, reason, "%s", err.Error())
+}
+
+// EventType returns the type of the event to be recorded for the given error.
+// Dependencies not being ready are expected and result in normal events.
+func EventType(err error) string {
+	if TypeOf(err) == DependencyNotReadyType {
+		return corev1.EventTypeNormal
+	}
+	return corev1.EventTypeWarning
+}
+
+// Result computes the ctrl.Result and error to be returned by the reconciler
+// for the given error:
+//   - Stalled and invalid errors are not retried.
+//   - Access denied and dependency not ready errors are retried at the given interval.
+//   - Transient errors are returned to be retried with backoff.
+//
+// The RequeueAfter of an *Error takes precedence over the given interval,
+// for all types.
+func Result(err error, retryInterval time.Duration) (ctrl.Result, error) {
+	if err == nil {
+		return ctrl.Result{}, nil
+	}
+
+	var e *Error
+	if errors.As(err, &e) && e.RequeueAfter > 0 {
+		return ctrl.Result{RequeueAfter: e.RequeueAfter}, nil
+	}
+
+	switch TypeOf(err) {
+	case StalledType, InvalidType:
+		return ctrl.Result{}, nil
+	case AccessDeniedType, DependencyNotReadyType:
+		return ctrl.Result{RequeueAfter: retryInterval}, nil
+	default:
+		return ctrl.Result{}, err
+	}
+}
+
+func defaultReason(t Type) string {
+	switch t {
+	case AccessDeniedType:
+		return AccessDeniedReason
+	case DependencyNotReadyType:
+		return meta.DependencyNotReadyReason
+	case InvalidType:
+		return InvalidReason
+	default:
+		return meta.FailedReason
+	}
+}
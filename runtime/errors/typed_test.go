@@ -0,0 +1,117 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package errors
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	"github.com/fluxcd/pkg/apis/meta"
+	"github.com/fluxcd/pkg/runtime/conditions"
+	"github.com/fluxcd/pkg/runtime/conditions/testdata"
+)
+
+func TestTypeOf(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantType   Type
+		wantReason string
+	}{
+		{name: "nil", err: nil, wantType: "", wantReason: ""},
+		{name: "untyped", err: fmt.Errorf("boom"), wantType: TransientType, wantReason: meta.FailedReason},
+		{name: "wrapped stalled", err: fmt.Errorf("wrap: %w", NewStalled("BuildFailed", fmt.Errorf("boom"))), wantType: StalledType, wantReason: "BuildFailed"},
+		{name: "default reason", err: NewDependencyNotReady("", fmt.Errorf("boom")), wantType: DependencyNotReadyType, wantReason: meta.DependencyNotReadyReason},
+		{name: "invalid", err: NewInvalid("", fmt.Errorf("boom")), wantType: InvalidType, wantReason: InvalidReason},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			g.Expect(TypeOf(tt.err)).To(Equal(tt.wantType))
+			g.Expect(ReasonOf(tt.err)).To(Equal(tt.wantReason))
+		})
+	}
+}
+
+func TestSetConditions(t *testing.T) {
+	t.Run("stalled", func(t *testing.T) {
+		g := NewWithT(t)
+		obj := &testdata.Fake{}
+		conditions.MarkReconciling(obj, meta.ProgressingReason, "reconciling")
+
+		SetConditions(obj, NewInvalid("", fmt.Errorf("invalid 100%% path")))
+		g.Expect(conditions.IsTrue(obj, meta.StalledCondition)).To(BeTrue())
+		g.Expect(conditions.Has(obj, meta.ReconcilingCondition)).To(BeFalse())
+		g.Expect(conditions.IsFalse(obj, meta.ReadyCondition)).To(BeTrue())
+		g.Expect(conditions.GetReason(obj, meta.ReadyCondition)).To(Equal(InvalidReason))
+		g.Expect(conditions.GetMessage(obj, meta.ReadyCondition)).To(Equal("invalid 100% path"))
+	})
+
+	t.Run("transient", func(t *testing.T) {
+		g := NewWithT(t)
+		obj := &testdata.Fake{}
+		conditions.MarkStalled(obj, meta.FailedReason, "stalled")
+
+		SetConditions(obj, NewTransient("", fmt.Errorf("timeout")))
+		g.Expect(conditions.Has(obj, meta.StalledCondition)).To(BeFalse())
+		g.Expect(conditions.GetReason(obj, meta.ReadyCondition)).To(Equal(meta.FailedReason))
+	})
+}
+
+func TestEventType(t *testing.T) {
+	g := NewWithT(t)
+	g.Expect(EventType(NewDependencyNotReady("", fmt.Errorf("boom")))).To(Equal(corev1.EventTypeNormal))
+	g.Expect(EventType(NewAccessDenied("", fmt.Errorf("boom")))).To(Equal(corev1.EventTypeWarning))
+	g.Expect(EventType(fmt.Errorf("boom"))).To(Equal(corev1.EventTypeWarning))
+}
+
+func TestResult(t *testing.T) {
+	interval := time.Minute
+	transient := fmt.Errorf("boom")
+
+	tests := []struct {
+		name       string
+		err        error
+		wantResult ctrl.Result
+		wantErr    error
+	}{
+		{name: "nil", err: nil, wantResult: ctrl.Result{}},
+		{name: "stalled", err: NewStalled("", transient), wantResult: ctrl.Result{}},
+		{name: "invalid", err: NewInvalid("", transient), wantResult: ctrl.Result{}},
+		{name: "access denied", err: NewAccessDenied("", transient), wantResult: ctrl.Result{RequeueAfter: interval}},
+		{name: "dependency not ready", err: NewDependencyNotReady("", transient), wantResult: ctrl.Result{RequeueAfter: interval}},
+		{name: "requeue after", err: NewTransient("", transient).WithRequeueAfter(time.Second), wantResult: ctrl.Result{RequeueAfter: time.Second}},
+		{name: "transient", err: transient, wantResult: ctrl.Result{}, wantErr: transient},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			res, err := Result(tt.err, interval)
+			g.Expect(res).To(Equal(tt.wantResult))
+			if tt.wantErr != nil {
+				g.Expect(err).To(MatchError(tt.wantErr))
+			} else {
+				g.Expect(err).ToNot(HaveOccurred())
+			}
+		})
+	}
+}
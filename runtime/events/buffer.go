@@ -0,0 +1,234 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package events
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultBufferSize is the default number of events held in memory by
+	// an EventBuffer.
+	DefaultBufferSize = 1000
+
+	// DefaultMaxSpilledEvents is the default number of events written to
+	// disk by an EventBuffer.
+	DefaultMaxSpilledEvents = 10000
+
+	// DefaultBufferRetryInterval is the default interval between the
+	// delivery attempts of an EventBuffer.
+	DefaultBufferRetryInterval = 10 * time.Second
+
+	spillFileSuffix = ".event.json"
+)
+
+// ErrBufferFull is returned when an event is recorded while the memory
+// queue and the disk spillover of an EventBuffer are full.
+var ErrBufferFull = errors.New("event buffer is full")
+
+// BufferOptions holds the options of an EventBuffer.
+type BufferOptions struct {
+	// Size is the maximum number of events held in memory.
+	// Defaults to DefaultBufferSize.
+	Size int
+
+	// SpillDir is the directory where the events are written once the
+	// memory queue is full. The events written to disk are delivered after
+	// a restart of the controller. When empty, the events recorded while the
+	// memory queue is full are dropped.
+	SpillDir string
+
+	// MaxSpilled is the maximum number of events written to SpillDir.
+	// Defaults to DefaultMaxSpilledEvents.
+	MaxSpilled int
+
+	// RetryInterval is the interval between the delivery attempts of an
+	// event when posting it to all the webhook addresses failed.
+	// Defaults to DefaultBufferRetryInterval.
+	RetryInterval time.Duration
+}
+
+// EventBuffer queues the events of a Recorder, so that they are delivered
+// in order once the webhook addresses are available again, instead of being
+// dropped. The events are held in a bounded memory queue, and written to
+// disk when it is full if a spill directory is configured. The events which
+// are in the memory queue when the controller exits are lost.
+type EventBuffer struct {
+	size          int
+	spillDir      string
+	maxSpilled    int
+	retryInterval time.Duration
+
+	mu      sync.Mutex
+	memory  []bufferedEvent
+	spilled []uint64
+	next    uint64
+	notify  chan struct{}
+}
+
+// bufferedEvent is an encoded event waiting to be delivered.
+type bufferedEvent struct {
+	Body   []byte      `json:"body"`
+	Header http.Header `json:"header"`
+}
+
+// NewEventBuffer returns an EventBuffer configured with the given options.
+// The events found in the spill directory, written before a restart of the
+// controller, are queued for delivery.
+func NewEventBuffer(opts BufferOptions) (*EventBuffer, error) {
+	if opts.Size <= 0 {
+		opts.Size = DefaultBufferSize
+	}
+	if opts.MaxSpilled <= 0 {
+		opts.MaxSpilled = DefaultMaxSpilledEvents
+	}
+	if opts.RetryInterval <= 0 {
+		opts.RetryInterval = DefaultBufferRetryInterval
+	}
+	b := &EventBuffer{
+		size:          opts.Size,
+		spillDir:      opts.SpillDir,
+		maxSpilled:    opts.MaxSpilled,
+		retryInterval: opts.RetryInterval,
+		notify:        make(chan struct{}, 1),
+	}
+
+	if b.spillDir == "" {
+		return b, nil
+	}
+	if err := os.MkdirAll(b.spillDir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create event spill directory: %w", err)
+	}
+	entries, err := os.ReadDir(b.spillDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read event spill directory: %w", err)
+	}
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), spillFileSuffix)
+		if !ok || entry.IsDir() {
+			continue
+		}
+		seq, err := strconv.ParseUint(name, 10, 64)
+		if err != nil {
+			continue
+		}
+		b.spilled = append(b.spilled, seq)
+		if seq >= b.next {
+			b.next = seq + 1
+		}
+	}
+	sort.Slice(b.spilled, func(i, j int) bool { return b.spilled[i] < b.spilled[j] })
+	return b, nil
+}
+
+// Len returns the number of events waiting to be delivered.
+func (b *EventBuffer) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.memory) + len(b.spilled)
+}
+
+// push queues the given event. The event is written to disk when the memory
+// queue is full, or when events are already waiting on disk to keep the
+// delivery order.
+func (b *EventBuffer) push(e bufferedEvent) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.spilled) == 0 && len(b.memory) < b.size {
+		b.memory = append(b.memory, e)
+		b.wake()
+		return nil
+	}
+	if b.spillDir == "" || len(b.spilled) >= b.maxSpilled {
+		return ErrBufferFull
+	}
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	seq := b.next
+	if err := os.WriteFile(b.spillPath(seq), data, 0o600); err != nil {
+		return fmt.Errorf("failed to write event to spill directory: %w", err)
+	}
+	b.next++
+	b.spilled = append(b.spilled, seq)
+	b.wake()
+	return nil
+}
+
+// front returns the oldest event waiting to be delivered, if any.
+func (b *EventBuffer) front() (bufferedEvent, bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.memory) > 0 {
+		return b.memory[0], true, nil
+	}
+	if len(b.spilled) == 0 {
+		return bufferedEvent{}, false, nil
+	}
+
+	var e bufferedEvent
+	data, err := os.ReadFile(b.spillPath(b.spilled[0]))
+	if err == nil {
+		err = json.Unmarshal(data, &e)
+	}
+	if err != nil {
+		return bufferedEvent{}, true, fmt.Errorf("failed to read event from spill directory: %w", err)
+	}
+	return e, true, nil
+}
+
+// pop removes the oldest event waiting to be delivered.
+func (b *EventBuffer) pop() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.memory) > 0 {
+		b.memory[0] = bufferedEvent{}
+		b.memory = b.memory[1:]
+		return
+	}
+	if len(b.spilled) > 0 {
+		_ = os.Remove(b.spillPath(b.spilled[0]))
+		b.spilled = b.spilled[1:]
+	}
+}
+
+// wake notifies the delivery loop of a new event, the lock must be held.
+func (b *EventBuffer) wake() {
+	select {
+	case b.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (b *EventBuffer) spillPath(seq uint64) string {
+	return filepath.Join(b.spillDir, fmt.Sprintf("%020d%s", seq, spillFileSuffix))
+}
@@ -0,0 +1,163 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	eventv1 "github.com/fluxcd/pkg/apis/event/v1beta1"
+)
+
+func TestEventRecorder_FailoverWebhooks(t *testing.T) {
+	var primaryCount, failoverCount int32
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&primaryCount, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer primary.Close()
+	failover := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&failoverCount, 1)
+	}))
+	defer failover.Close()
+
+	eventRecorder, err := NewRecorder(env, ctrl.Log, primary.URL, "test-controller")
+	require.NoError(t, err)
+	eventRecorder.Client.RetryMax = 0
+	eventRecorder.FailoverWebhooks = []string{failover.URL}
+
+	obj := &corev1.ConfigMap{}
+	obj.Namespace = "gitops-system"
+	obj.Name = "webapp"
+
+	eventRecorder.AnnotatedEventf(obj, nil, corev1.EventTypeNormal, "sync", "sync %s", obj.Name)
+	require.True(t, atomic.LoadInt32(&primaryCount) > 0)
+	require.True(t, atomic.LoadInt32(&failoverCount) > 0)
+}
+
+func TestEventRecorder_Buffer(t *testing.T) {
+	var available atomic.Bool
+	var mu sync.Mutex
+	var received []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !available.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		b, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		var payload eventv1.Event
+		require.NoError(t, json.Unmarshal(b, &payload))
+
+		mu.Lock()
+		defer mu.Unlock()
+		// The first request of each event is not retried and may be
+		// received twice.
+		if len(received) == 0 || received[len(received)-1] != payload.Message {
+			received = append(received, payload.Message)
+		}
+	}))
+	defer ts.Close()
+
+	eventRecorder, err := NewRecorder(env, ctrl.Log, ts.URL, "test-controller")
+	require.NoError(t, err)
+	eventRecorder.Client.RetryMax = 0
+	eventRecorder.Buffer, err = NewEventBuffer(BufferOptions{
+		Size:          1,
+		SpillDir:      t.TempDir(),
+		RetryInterval: 10 * time.Millisecond,
+	})
+	require.NoError(t, err)
+
+	obj := &corev1.ConfigMap{}
+	obj.Namespace = "gitops-system"
+	obj.Name = "webapp"
+	for _, msg := range []string{"first", "second", "third"} {
+		eventRecorder.AnnotatedEventf(obj, nil, corev1.EventTypeNormal, "sync", msg)
+	}
+	require.Equal(t, 3, eventRecorder.Buffer.Len())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go eventRecorder.Start(ctx)
+
+	time.Sleep(50 * time.Millisecond)
+	require.Equal(t, 3, eventRecorder.Buffer.Len())
+
+	available.Store(true)
+	require.Eventually(t, func() bool {
+		return eventRecorder.Buffer.Len() == 0
+	}, 5*time.Second, 10*time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Equal(t, []string{"first", "second", "third"}, received)
+}
+
+func TestEventBuffer_Spill(t *testing.T) {
+	dir := t.TempDir()
+	buffer, err := NewEventBuffer(BufferOptions{Size: 1, SpillDir: dir, MaxSpilled: 2})
+	require.NoError(t, err)
+
+	for _, body := range []string{"first", "second", "third"} {
+		require.NoError(t, buffer.push(bufferedEvent{Body: []byte(body)}))
+	}
+	require.ErrorIs(t, buffer.push(bufferedEvent{Body: []byte("fourth")}), ErrBufferFull)
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+
+	// The spilled events are recovered in order by a new buffer.
+	recovered, err := NewEventBuffer(BufferOptions{SpillDir: dir})
+	require.NoError(t, err)
+	require.Equal(t, 2, recovered.Len())
+	for _, body := range []string{"second", "third"} {
+		event, ok, err := recovered.front()
+		require.NoError(t, err)
+		require.True(t, ok)
+		require.Equal(t, body, string(event.Body))
+		recovered.pop()
+	}
+	_, ok, err := recovered.front()
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	entries, err = os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Empty(t, entries)
+}
+
+func TestEventBuffer_WithoutSpill(t *testing.T) {
+	buffer, err := NewEventBuffer(BufferOptions{Size: 1})
+	require.NoError(t, err)
+
+	require.NoError(t, buffer.push(bufferedEvent{Body: []byte("first")}))
+	require.ErrorIs(t, buffer.push(bufferedEvent{Body: []byte("second")}), ErrBufferFull)
+}
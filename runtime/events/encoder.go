@@ -0,0 +1,150 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package events
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	eventv1 "github.com/fluxcd/pkg/apis/event/v1beta1"
+)
+
+// Encoder encodes an event into the body and headers of the HTTP request
+// posted to the webhook address.
+type Encoder interface {
+	Encode(event eventv1.Event) ([]byte, http.Header, error)
+}
+
+// JSONEncoder encodes events as the eventv1.Event JSON expected by the
+// GitOps Toolkit notification-controller. It is the default Encoder.
+type JSONEncoder struct{}
+
+// Encode implements Encoder.
+func (JSONEncoder) Encode(event eventv1.Event) ([]byte, http.Header, error) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return nil, nil, err
+	}
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+	return body, header, nil
+}
+
+// CloudEventsMode is the HTTP content mode of CloudEvents.
+type CloudEventsMode string
+
+const (
+	// CloudEventsStructured encodes the event attributes and data in the
+	// request body, with the 'application/cloudevents+json' content type.
+	CloudEventsStructured CloudEventsMode = "structured"
+	// CloudEventsBinary encodes the event attributes in the 'ce-' prefixed
+	// request headers, and the data in the request body.
+	CloudEventsBinary CloudEventsMode = "binary"
+)
+
+const (
+	// cloudEventsSpecVersion is the version of the CloudEvents specification.
+	cloudEventsSpecVersion = "1.0"
+	// DefaultCloudEventsTypePrefix is the prefix of the CloudEvents type,
+	// followed by the event severity.
+	DefaultCloudEventsTypePrefix = "io.fluxcd.event"
+)
+
+// CloudEventsEncoder encodes events in the CloudEvents 1.0 format, for the
+// integration with CloudEvents brokers like Knative Eventing or Argo Events.
+// The eventv1.Event JSON is the event data, the involved object is the event
+// subject, and the severity and reason are set as extension attributes.
+type CloudEventsEncoder struct {
+	// Mode is the HTTP content mode, defaults to CloudEventsStructured.
+	Mode CloudEventsMode
+	// Source is the 'source' attribute, defaults to the reporting controller.
+	Source string
+	// Type is the 'type' attribute, defaults to the DefaultCloudEventsTypePrefix
+	// followed by the event severity, e.g. 'io.fluxcd.event.error'.
+	Type string
+}
+
+// Encode implements Encoder.
+func (e CloudEventsEncoder) Encode(event eventv1.Event) ([]byte, http.Header, error) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	id, err := newCloudEventID()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	attributes := map[string]string{
+		"specversion":     cloudEventsSpecVersion,
+		"id":              id,
+		"source":          e.Source,
+		"type":            e.Type,
+		"subject":         fmt.Sprintf("%s/%s/%s", event.InvolvedObject.Kind, event.InvolvedObject.Namespace, event.InvolvedObject.Name),
+		"time":            event.Timestamp.UTC().Format(time.RFC3339Nano),
+		"datacontenttype": "application/json",
+		"severity":        event.Severity,
+		"reason":          event.Reason,
+	}
+	if attributes["source"] == "" {
+		attributes["source"] = event.ReportingController
+	}
+	if attributes["type"] == "" {
+		attributes["type"] = DefaultCloudEventsTypePrefix + "." + event.Severity
+	}
+
+	header := http.Header{}
+	switch e.Mode {
+	case CloudEventsBinary:
+		for k, v := range attributes {
+			if k == "datacontenttype" {
+				continue
+			}
+			header.Set("ce-"+k, v)
+		}
+		header.Set("Content-Type", attributes["datacontenttype"])
+		return data, header, nil
+	case CloudEventsStructured, "":
+		structured := make(map[string]interface{}, len(attributes)+1)
+		for k, v := range attributes {
+			structured[k] = v
+		}
+		structured["data"] = json.RawMessage(data)
+		body, err := json.Marshal(structured)
+		if err != nil {
+			return nil, nil, err
+		}
+		header.Set("Content-Type", "application/cloudevents+json")
+		return body, header, nil
+	default:
+		return nil, nil, fmt.Errorf("unsupported CloudEvents mode '%s'", e.Mode)
+	}
+}
+
+// newCloudEventID returns a random event ID.
+func newCloudEventID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -18,7 +18,7 @@ package events
 
 import (
 	"bytes"
-	"encoding/json"
+	"context"
 	"errors"
 	"fmt"
 	"net/http"
@@ -29,6 +29,7 @@ import (
 	"github.com/go-logr/logr"
 	"github.com/hashicorp/go-retryablehttp"
 	corev1 "k8s.io/api/core/v1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	kuberecorder "k8s.io/client-go/tools/record"
@@ -75,6 +76,30 @@ type Recorder struct {
 
 	// Log is the recorder logger.
 	Log logr.Logger
+
+	// Encoder encodes the events posted to the webhook address,
+	// defaults to JSONEncoder.
+	Encoder Encoder
+
+	// MetadataLabels defines the label keys of the involved object to be copied
+	// to the metadata of the events posted to the webhook address, e.g. to allow
+	// receivers to route the events by team or application.
+	MetadataLabels []string
+
+	// MetadataAnnotations defines the annotation keys of the involved object to be
+	// copied to the metadata of the events posted to the webhook address, e.g. the
+	// revision annotations. The annotations given to AnnotatedEventf take precedence
+	// over the copied labels and annotations.
+	MetadataAnnotations []string
+
+	// FailoverWebhooks defines the webhook addresses to which the events are
+	// posted, in order, when posting them to Webhook fails.
+	FailoverWebhooks []string
+
+	// Buffer, when set, queues the events posted to the webhook addresses,
+	// and delivers them in the background once the Recorder is started,
+	// retrying the delivery until it succeeds.
+	Buffer *EventBuffer
 }
 
 var _ kuberecorder.EventRecorder = &Recorder{}
@@ -197,29 +222,158 @@ func (r *Recorder) AnnotatedEventf(
 		Timestamp:           metav1.Now(),
 		Message:             message,
 		Reason:              reason,
-		Metadata:            annotations,
+		Metadata:            r.eventMetadata(object, annotations),
 		ReportingController: r.ReportingController,
 		ReportingInstance:   hostname,
 	}
 
-	body, err := json.Marshal(event)
+	encoder := r.Encoder
+	if encoder == nil {
+		encoder = JSONEncoder{}
+	}
+	body, header, err := encoder.Encode(event)
 	if err != nil {
-		log.Error(err, "failed to marshal object into json")
+		log.Error(err, "failed to encode event")
 		return
 	}
 
-	// avoid retrying rate limited requests
-	if res, _ := r.Client.HTTPClient.Post(r.Webhook, "application/json", bytes.NewReader(body)); res != nil &&
-		(res.StatusCode == http.StatusTooManyRequests || res.StatusCode == http.StatusAccepted) {
+	if r.Buffer != nil {
+		if err := r.Buffer.push(bufferedEvent{Body: body, Header: header}); err != nil {
+			log.Error(err, "unable to record event")
+		}
 		return
 	}
 
-	if _, err := r.Client.Post(r.Webhook, "application/json", body); err != nil {
+	if err := r.post(body, header); err != nil {
 		log.Error(err, "unable to record event")
 		return
 	}
 }
 
+// Start delivers the events queued in the Buffer until the context is
+// cancelled, it can be added to the controller manager as a Runnable.
+// If the Buffer is not set, Start returns when the context is cancelled.
+func (r *Recorder) Start(ctx context.Context) error {
+	if r.Buffer == nil {
+		<-ctx.Done()
+		return nil
+	}
+
+	for {
+		event, ok, err := r.Buffer.front()
+		if err != nil {
+			r.Log.Error(err, "dropping buffered event")
+			r.Buffer.pop()
+			continue
+		}
+		if !ok {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-r.Buffer.notify:
+			}
+			continue
+		}
+
+		if err := r.post(event.Body, event.Header); err != nil {
+			r.Log.Error(err, "unable to deliver buffered event, retrying", "buffered", r.Buffer.Len())
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(r.Buffer.retryInterval):
+			}
+			continue
+		}
+		r.Buffer.pop()
+	}
+}
+
+// NeedLeaderElection implements the LeaderElectionRunnable interface, the
+// events are delivered by all the instances of a controller.
+func (r *Recorder) NeedLeaderElection() bool {
+	return false
+}
+
+// post posts the encoded event to the webhook address, and to the failover
+// addresses in order until posting succeeds.
+func (r *Recorder) post(body []byte, header http.Header) error {
+	var errs []error
+	for i, address := range append([]string{r.Webhook}, r.FailoverWebhooks...) {
+		err := r.postTo(address, body, header)
+		if err == nil {
+			return nil
+		}
+		if i == 0 {
+			errs = append(errs, fmt.Errorf("webhook: %w", err))
+		} else {
+			errs = append(errs, fmt.Errorf("failover webhook %d: %w", i, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// postTo posts the encoded event to the given address.
+func (r *Recorder) postTo(address string, body []byte, header http.Header) error {
+	req, err := http.NewRequest(http.MethodPost, address, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header = header.Clone()
+
+	// avoid retrying rate limited requests
+	if res, _ := r.Client.HTTPClient.Do(req); res != nil {
+		res.Body.Close()
+		if res.StatusCode == http.StatusTooManyRequests || res.StatusCode == http.StatusAccepted {
+			return nil
+		}
+	}
+
+	retryReq, err := retryablehttp.NewRequest(http.MethodPost, address, body)
+	if err != nil {
+		return err
+	}
+	retryReq.Header = header.Clone()
+
+	res, err := r.Client.Do(retryReq)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	return nil
+}
+
+// eventMetadata returns the given annotations merged with the labels and
+// annotations of the object selected by MetadataLabels and MetadataAnnotations.
+func (r *Recorder) eventMetadata(object runtime.Object, annotations map[string]string) map[string]string {
+	if len(r.MetadataLabels) == 0 && len(r.MetadataAnnotations) == 0 {
+		return annotations
+	}
+
+	obj, err := apimeta.Accessor(object)
+	if err != nil {
+		return annotations
+	}
+
+	metadata := make(map[string]string)
+	copyKeys := func(src map[string]string, keys []string) {
+		for _, k := range keys {
+			if v, ok := src[k]; ok {
+				metadata[k] = v
+			}
+		}
+	}
+	copyKeys(obj.GetLabels(), r.MetadataLabels)
+	copyKeys(obj.GetAnnotations(), r.MetadataAnnotations)
+	for k, v := range annotations {
+		metadata[k] = v
+	}
+
+	if len(metadata) == 0 {
+		return annotations
+	}
+	return metadata
+}
+
 // eventTypeToSeverity maps the given eventType string to a GOTK event severity
 // type.
 func eventTypeToSeverity(eventType string) string {
@@ -69,6 +69,45 @@ func TestEventRecorder_AnnotatedEventf(t *testing.T) {
 	require.Equal(t, 2, requestCount)
 }
 
+func TestEventRecorder_AnnotatedEventf_ObjectMetadata(t *testing.T) {
+	var payload eventv1.Event
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		require.NoError(t, json.Unmarshal(b, &payload))
+	}))
+	defer ts.Close()
+
+	eventRecorder, err := NewRecorder(env, ctrl.Log, ts.URL, "test-controller")
+	require.NoError(t, err)
+	eventRecorder.MetadataLabels = []string{"team", "app"}
+	eventRecorder.MetadataAnnotations = []string{"toolkit.fluxcd.io/revision"}
+
+	obj := &corev1.ConfigMap{}
+	obj.Namespace = "gitops-system"
+	obj.Name = "webapp"
+	obj.Labels = map[string]string{
+		"team":  "dev",
+		"other": "ignored",
+	}
+	obj.Annotations = map[string]string{
+		"toolkit.fluxcd.io/revision": "main@sha1:a1b2c3",
+	}
+
+	meta := map[string]string{
+		"team": "ops",
+		"test": "true",
+	}
+
+	eventRecorder.AnnotatedEventf(obj, meta, corev1.EventTypeNormal, "sync", "sync %s", obj.Name)
+	require.Equal(t, map[string]string{
+		"team":                       "ops",
+		"test":                       "true",
+		"toolkit.fluxcd.io/revision": "main@sha1:a1b2c3",
+	}, payload.Metadata)
+	require.Equal(t, map[string]string{"team": "ops", "test": "true"}, meta)
+}
+
 func TestEventRecorder_AnnotatedEventf_Retry(t *testing.T) {
 	requestCount := 0
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -133,3 +172,84 @@ func TestEventRecorder_Webhook(t *testing.T) {
 	_, err = NewRecorder(env, ctrl.Log, "http://example.com", "test-controller")
 	require.NoError(t, err)
 }
+
+func TestEventRecorder_CloudEvents(t *testing.T) {
+	tests := []struct {
+		name    string
+		encoder CloudEventsEncoder
+		assert  func(t *testing.T, r *http.Request, body []byte)
+	}{
+		{
+			name:    "structured mode",
+			encoder: CloudEventsEncoder{Mode: CloudEventsStructured},
+			assert: func(t *testing.T, r *http.Request, body []byte) {
+				require.Equal(t, "application/cloudevents+json", r.Header.Get("Content-Type"))
+
+				var ce struct {
+					SpecVersion string        `json:"specversion"`
+					ID          string        `json:"id"`
+					Source      string        `json:"source"`
+					Type        string        `json:"type"`
+					Subject     string        `json:"subject"`
+					Severity    string        `json:"severity"`
+					Data        eventv1.Event `json:"data"`
+				}
+				require.NoError(t, json.Unmarshal(body, &ce))
+				require.Equal(t, "1.0", ce.SpecVersion)
+				require.NotEmpty(t, ce.ID)
+				require.Equal(t, "test-controller", ce.Source)
+				require.Equal(t, "io.fluxcd.event.info", ce.Type)
+				require.Equal(t, "ConfigMap/gitops-system/webapp", ce.Subject)
+				require.Equal(t, eventv1.EventSeverityInfo, ce.Severity)
+				require.Equal(t, "sync", ce.Data.Reason)
+			},
+		},
+		{
+			name:    "binary mode",
+			encoder: CloudEventsEncoder{Mode: CloudEventsBinary, Source: "flux", Type: "com.example.sync"},
+			assert: func(t *testing.T, r *http.Request, body []byte) {
+				require.Equal(t, "application/json", r.Header.Get("Content-Type"))
+				require.Equal(t, "1.0", r.Header.Get("ce-specversion"))
+				require.NotEmpty(t, r.Header.Get("ce-id"))
+				require.Equal(t, "flux", r.Header.Get("ce-source"))
+				require.Equal(t, "com.example.sync", r.Header.Get("ce-type"))
+				require.Equal(t, "ConfigMap/gitops-system/webapp", r.Header.Get("ce-subject"))
+
+				var payload eventv1.Event
+				require.NoError(t, json.Unmarshal(body, &payload))
+				require.Equal(t, "sync", payload.Reason)
+				require.Equal(t, "webapp", payload.InvolvedObject.Name)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requestCount := 0
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requestCount++
+				b, err := io.ReadAll(r.Body)
+				require.NoError(t, err)
+				tt.assert(t, r, b)
+				w.WriteHeader(http.StatusAccepted)
+			}))
+			defer ts.Close()
+
+			eventRecorder, err := NewRecorder(env, ctrl.Log, ts.URL, "test-controller")
+			require.NoError(t, err)
+			eventRecorder.Encoder = tt.encoder
+
+			obj := &corev1.ConfigMap{}
+			obj.Namespace = "gitops-system"
+			obj.Name = "webapp"
+
+			eventRecorder.AnnotatedEventf(obj, nil, corev1.EventTypeNormal, "sync", "sync %s", obj.Name)
+			require.Equal(t, 1, requestCount)
+		})
+	}
+}
+
+func TestCloudEventsEncoder_InvalidMode(t *testing.T) {
+	_, _, err := CloudEventsEncoder{Mode: "invalid"}.Encode(eventv1.Event{})
+	require.Error(t, err)
+}
@@ -18,6 +18,7 @@ package features
 
 import (
 	"fmt"
+	"sync"
 
 	"github.com/go-logr/logr"
 	"github.com/spf13/pflag"
@@ -28,16 +29,20 @@ const (
 	flagFeatureGates = "feature-gates"
 )
 
-var featureGates map[string]bool
-var loaded bool
+var (
+	mu           sync.RWMutex
+	featureGates map[string]bool
+	featureSpecs map[string]FeatureSpec
+	loaded       bool
+)
 
 // FeatureGates is a helper to manage feature switches.
 //
 // Controllers can set their supported features and then at runtime
 // verify which ones are enabled/disabled.
 //
-// Callers have to call BindFlags, and then call SupportedFeatures to
-// set the supported features and their default values.
+// Callers have to call BindFlags, and then call SupportedFeatures or
+// SupportedFeatureSpecs to set the supported features and their default values.
 type FeatureGates struct {
 	log         *logr.Logger
 	cliFeatures map[string]bool
@@ -51,17 +56,42 @@ func (o *FeatureGates) WithLogger(l logr.Logger) *FeatureGates {
 
 // SupportedFeatures sets the supported features and their default values.
 func (o *FeatureGates) SupportedFeatures(features map[string]bool) error {
+	specs := make(map[string]FeatureSpec, len(features))
+	for k, v := range features {
+		specs[k] = FeatureSpec{Default: v}
+	}
+	return o.SupportedFeatureSpecs(specs)
+}
+
+// SupportedFeatureSpecs sets the supported features and their specification.
+// It returns an error if a feature gate set from the command line is not
+// supported, or if it is locked to its default value.
+// A warning is logged for every deprecated feature gate set from the command line.
+func (o *FeatureGates) SupportedFeatureSpecs(specs map[string]FeatureSpec) error {
+	mu.Lock()
+	defer mu.Unlock()
+
 	loaded = true
-	featureGates = features
+	featureSpecs = specs
+	featureGates = make(map[string]bool, len(specs))
+	for k, spec := range specs {
+		featureGates[k] = spec.Default
+	}
 
 	for k, v := range o.cliFeatures {
-		if _, ok := featureGates[k]; ok {
-			featureGates[k] = v
-		} else {
+		spec, ok := featureSpecs[k]
+		if !ok {
 			return fmt.Errorf("feature-gate '%s' not supported", k)
 		}
+		if spec.LockToDefault && v != spec.Default {
+			return fmt.Errorf("feature-gate '%s' is locked to %t", k, spec.Default)
+		}
+		featureGates[k] = v
 		if o.log != nil {
 			o.log.Info("loading feature gate", k, v)
+			if spec.Deprecated {
+				o.log.Info(deprecationWarning(k, spec))
+			}
 		}
 	}
 	return nil
@@ -69,6 +99,9 @@ func (o *FeatureGates) SupportedFeatures(features map[string]bool) error {
 
 // Enabled verifies whether the feature is enabled or not.
 func Enabled(feature string) (bool, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+
 	if !loaded {
 		return false, fmt.Errorf("supported features not set")
 	}
@@ -0,0 +1,143 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package features
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+)
+
+// Stage is the maturity stage of a feature.
+type Stage string
+
+const (
+	// Alpha features are experimental and disabled by default.
+	Alpha Stage = "Alpha"
+	// Beta features are well tested and may be enabled by default.
+	Beta Stage = "Beta"
+	// GA features are stable and their gate is scheduled for removal.
+	GA Stage = "GA"
+)
+
+// FeatureSpec describes a feature gate.
+type FeatureSpec struct {
+	// Default is the state of the feature gate when it is not set
+	// from the command line.
+	Default bool
+
+	// Stage is the maturity stage of the feature.
+	Stage Stage
+
+	// LockToDefault prevents the feature gate from being set to
+	// a value other than its default, e.g. for GA features.
+	LockToDefault bool
+
+	// Deprecated marks the feature gate as scheduled for removal,
+	// a warning is logged when it is set from the command line.
+	Deprecated bool
+
+	// DeprecationMessage is appended to the deprecation warning,
+	// e.g. to point users to a replacement.
+	DeprecationMessage string
+}
+
+// Gate is a typed accessor for a feature gate, which controllers
+// can declare as constants:
+//
+//	const CacheSecretsAndConfigMaps features.Gate = "CacheSecretsAndConfigMaps"
+//
+//	if CacheSecretsAndConfigMaps.Enabled() {
+//		// ...
+//	}
+type Gate string
+
+// String returns the name of the feature gate.
+func (g Gate) String() string {
+	return string(g)
+}
+
+// Enabled returns true if the feature gate is enabled. It returns false
+// if the supported features are not set or the feature is not supported.
+func (g Gate) Enabled() bool {
+	enabled, err := Enabled(string(g))
+	return err == nil && enabled
+}
+
+// Spec returns the specification of the feature gate, and false if the
+// feature is not supported.
+func (g Gate) Spec() (FeatureSpec, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	spec, ok := featureSpecs[string(g)]
+	return spec, ok
+}
+
+// Status holds the state of a supported feature gate.
+type Status struct {
+	Name       string `json:"name"`
+	Enabled    bool   `json:"enabled"`
+	Default    bool   `json:"default"`
+	Stage      Stage  `json:"stage,omitempty"`
+	Deprecated bool   `json:"deprecated,omitempty"`
+}
+
+// List returns the state of all the supported feature gates, sorted by name.
+func List() []Status {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	result := make([]Status, 0, len(featureGates))
+	for name, enabled := range featureGates {
+		spec := featureSpecs[name]
+		result = append(result, Status{
+			Name:       name,
+			Enabled:    enabled,
+			Default:    spec.Default,
+			Stage:      spec.Stage,
+			Deprecated: spec.Deprecated,
+		})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+// Handler returns an HTTP handler which lists the supported feature gates
+// in JSON format. It can be registered as an extra handler of the
+// controller-runtime metrics server.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(List())
+	})
+}
+
+func deprecationWarning(name string, spec FeatureSpec) string {
+	msg := fmt.Sprintf("feature-gate '%s' is deprecated and will be removed in a future release", name)
+	if spec.DeprecationMessage != "" {
+		msg = fmt.Sprintf("%s: %s", msg, spec.DeprecationMessage)
+	}
+	return msg
+}
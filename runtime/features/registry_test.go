@@ -0,0 +1,99 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package features
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-logr/logr/funcr"
+	. "github.com/onsi/gomega"
+	"github.com/spf13/pflag"
+)
+
+func TestSupportedFeatureSpecs(t *testing.T) {
+	g := NewWithT(t)
+
+	var logs []string
+	fs := pflag.NewFlagSet("", pflag.ContinueOnError)
+	features := FeatureGates{}
+	features.WithLogger(funcr.New(func(prefix, args string) {
+		logs = append(logs, args)
+	}, funcr.Options{}))
+	features.BindFlags(fs)
+	g.Expect(fs.Parse([]string{"--feature-gates=time-travel=true,legacy-mode=true"})).To(Succeed())
+
+	err := features.SupportedFeatureSpecs(map[string]FeatureSpec{
+		"time-travel":  {Default: false, Stage: Alpha},
+		"legacy-mode":  {Default: false, Stage: Beta, Deprecated: true, DeprecationMessage: "use time-travel instead"},
+		"stable-point": {Default: true, Stage: GA, LockToDefault: true},
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+
+	g.Expect(Gate("time-travel").Enabled()).To(BeTrue())
+	g.Expect(Gate("stable-point").Enabled()).To(BeTrue())
+	g.Expect(Gate("unknown").Enabled()).To(BeFalse())
+
+	spec, ok := Gate("legacy-mode").Spec()
+	g.Expect(ok).To(BeTrue())
+	g.Expect(spec.Deprecated).To(BeTrue())
+
+	g.Expect(strings.Join(logs, "\n")).To(ContainSubstring("feature-gate 'legacy-mode' is deprecated"))
+
+	g.Expect(List()).To(Equal([]Status{
+		{Name: "legacy-mode", Enabled: true, Default: false, Stage: Beta, Deprecated: true},
+		{Name: "stable-point", Enabled: true, Default: true, Stage: GA},
+		{Name: "time-travel", Enabled: true, Default: false, Stage: Alpha},
+	}))
+}
+
+func TestSupportedFeatureSpecs_LockToDefault(t *testing.T) {
+	g := NewWithT(t)
+
+	fs := pflag.NewFlagSet("", pflag.ContinueOnError)
+	features := FeatureGates{}
+	features.BindFlags(fs)
+	g.Expect(fs.Parse([]string{"--feature-gates=stable-point=false"})).To(Succeed())
+
+	err := features.SupportedFeatureSpecs(map[string]FeatureSpec{
+		"stable-point": {Default: true, Stage: GA, LockToDefault: true},
+	})
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.Error()).To(ContainSubstring("feature-gate 'stable-point' is locked to true"))
+}
+
+func TestHandler(t *testing.T) {
+	g := NewWithT(t)
+
+	features := FeatureGates{}
+	g.Expect(features.SupportedFeatures(map[string]bool{"time-travel": true})).To(Succeed())
+
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/features", nil))
+	g.Expect(rec.Code).To(Equal(http.StatusOK))
+
+	var got []Status
+	g.Expect(json.Unmarshal(rec.Body.Bytes(), &got)).To(Succeed())
+	g.Expect(got).To(Equal([]Status{{Name: "time-travel", Enabled: true, Default: true}}))
+
+	rec = httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/features", nil))
+	g.Expect(rec.Code).To(Equal(http.StatusMethodNotAllowed))
+}
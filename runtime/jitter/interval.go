@@ -24,16 +24,21 @@ import (
 
 	"github.com/spf13/pflag"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 const (
 	flagIntervalJitter              = "interval-jitter-percentage"
+	flagIntervalJitterDeterministic = "interval-jitter-deterministic"
 	defaultIntervalJitterPercentage = 5
 )
 
 var (
 	globalIntervalJitter     Duration = NoJitter
 	globalIntervalJitterOnce sync.Once
+	// globalObjectIntervalJitter returns the jitter for an object, it is nil
+	// unless deterministic jitter is configured.
+	globalObjectIntervalJitter func(obj client.Object) Duration
 
 	errInvalidIntervalJitter = errors.New("the interval jitter percentage must be a non-negative value and less than 100")
 )
@@ -46,6 +51,24 @@ func SetGlobalIntervalJitter(p float64, rand *rand.Rand) {
 	})
 }
 
+// SetGlobalDeterministicIntervalJitter sets the global interval jitter to a
+// deterministic jitter per object, derived from the namespace and name of the
+// object given to JitteredRequeueIntervalFor and JitteredIntervalDurationFor.
+// This keeps the requeue interval of an object stable across restarts, which
+// avoids reconcile storms after a controller rollout. Durations jittered
+// without an object use a random jitter of the same percentage.
+//
+// It is safe to call this method multiple times, but only the first call to it
+// or SetGlobalIntervalJitter will have an effect.
+func SetGlobalDeterministicIntervalJitter(p float64, rand *rand.Rand) {
+	globalIntervalJitterOnce.Do(func() {
+		globalIntervalJitter = Percent(p, rand)
+		globalObjectIntervalJitter = func(obj client.Object) Duration {
+			return Deterministic(p, client.ObjectKeyFromObject(obj).String())
+		}
+	})
+}
+
 // JitteredRequeueInterval returns a result with a requeue-after interval that has
 // been jittered. It will not modify the result if it is zero or is marked
 // to requeue immediately.
@@ -68,6 +91,33 @@ func JitteredIntervalDuration(d time.Duration) time.Duration {
 	return globalIntervalJitter(d)
 }
 
+// JitteredRequeueIntervalFor returns a result with a requeue-after interval
+// that has been jittered for the given object. It will not modify the result
+// if it is zero or is marked to requeue immediately.
+//
+// When the global jitter is deterministic, the jitter is derived from the
+// namespace and name of the object. Otherwise, it behaves as
+// JitteredRequeueInterval.
+func JitteredRequeueIntervalFor(obj client.Object, res ctrl.Result) ctrl.Result {
+	if !res.IsZero() && res.RequeueAfter > 0 {
+		res.RequeueAfter = JitteredIntervalDurationFor(obj, res.RequeueAfter)
+	}
+	return res
+}
+
+// JitteredIntervalDurationFor returns a jittered duration for the given
+// object based on the given duration.
+//
+// When the global jitter is deterministic, the jitter is derived from the
+// namespace and name of the object. Otherwise, it behaves as
+// JitteredIntervalDuration.
+func JitteredIntervalDurationFor(obj client.Object, d time.Duration) time.Duration {
+	if globalObjectIntervalJitter != nil {
+		return globalObjectIntervalJitter(obj)(d)
+	}
+	return globalIntervalJitter(d)
+}
+
 // IntervalOptions is used to configure the interval jitter for a controller
 // using command line flags. To use it, create an IntervalOptions and call
 // BindFlags, then call SetGlobalJitter with a rand.Rand (or nil to use the
@@ -99,6 +149,10 @@ type IntervalOptions struct {
 	// will apply a jitter of +/-10% to the interval duration. It can not be negative,
 	// and must be less than 100.
 	Percentage uint8
+	// Deterministic derives the jitter of an object from its namespace and
+	// name, instead of choosing it randomly on every requeue. This keeps the
+	// requeue interval of an object stable across restarts.
+	Deterministic bool
 }
 
 // BindFlags will parse the given pflag.FlagSet and load the interval jitter
@@ -115,6 +169,9 @@ func (o *IntervalOptions) BindFlagsWithDefault(fs *pflag.FlagSet, defaultPercent
 		"Percentage of jitter to apply to interval durations. A value of 10 "+
 			"will apply a jitter of +/-10% to the interval duration. It cannot be "+
 			"negative, and must be less than 100.")
+	fs.BoolVar(&o.Deterministic, flagIntervalJitterDeterministic, false,
+		"Derive the interval jitter of an object from its namespace and name, "+
+			"keeping its requeue interval stable across restarts.")
 }
 
 // SetGlobalJitter sets the global interval jitter. It is safe to call this
@@ -124,7 +181,11 @@ func (o *IntervalOptions) SetGlobalJitter(rand *rand.Rand) error {
 		return errInvalidIntervalJitter
 	}
 	if o.Percentage > 0 && o.Percentage < 100 {
-		SetGlobalIntervalJitter(float64(o.Percentage)/100.0, rand)
+		if o.Deterministic {
+			SetGlobalDeterministicIntervalJitter(float64(o.Percentage)/100.0, rand)
+		} else {
+			SetGlobalIntervalJitter(float64(o.Percentage)/100.0, rand)
+		}
 	}
 	return nil
 }
@@ -18,11 +18,13 @@ package jitter
 
 import (
 	"math/rand"
+	"sync"
 	"testing"
 	"time"
 
 	. "github.com/onsi/gomega"
 	"github.com/spf13/pflag"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	ctrl "sigs.k8s.io/controller-runtime"
 )
 
@@ -83,6 +85,35 @@ func TestJitteredIntervalDuration(t *testing.T) {
 	}
 }
 
+func TestJitteredIntervalDurationFor(t *testing.T) {
+	g := NewWithT(t)
+
+	jitter, objectJitter := globalIntervalJitter, globalObjectIntervalJitter
+	defer func() {
+		globalIntervalJitter, globalObjectIntervalJitter = jitter, objectJitter
+	}()
+
+	p := 0.5
+	globalIntervalJitterOnce = sync.Once{}
+	SetGlobalDeterministicIntervalJitter(p, nil)
+
+	obj := &metav1.PartialObjectMetadata{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "podinfo"}}
+	interval := 10 * time.Second
+
+	d := JitteredIntervalDurationFor(obj, interval)
+	g.Expect(d).To(BeNumerically(">=", float64(interval)*(1-p)))
+	g.Expect(d).To(BeNumerically("<=", float64(interval)*(1+p)))
+	g.Expect(d).To(Equal(Deterministic(p, "default/podinfo")(interval)))
+	for i := 0; i < 10; i++ {
+		g.Expect(JitteredIntervalDurationFor(obj, interval)).To(Equal(d))
+		g.Expect(JitteredRequeueIntervalFor(obj, ctrl.Result{RequeueAfter: interval})).To(Equal(ctrl.Result{RequeueAfter: d}))
+	}
+	g.Expect(JitteredRequeueIntervalFor(obj, ctrl.Result{Requeue: true})).To(Equal(ctrl.Result{Requeue: true}))
+
+	other := &metav1.PartialObjectMetadata{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "other"}}
+	g.Expect(JitteredIntervalDurationFor(other, interval)).ToNot(Equal(d))
+}
+
 func TestIntervalOptions_BindFlags(t *testing.T) {
 	g := NewWithT(t)
 
@@ -92,6 +123,10 @@ func TestIntervalOptions_BindFlags(t *testing.T) {
 	interval.BindFlags(fs)
 
 	g.Expect(interval.Percentage).To(Equal(uint8(defaultIntervalJitterPercentage)))
+	g.Expect(interval.Deterministic).To(BeFalse())
+
+	g.Expect(fs.Set(flagIntervalJitterDeterministic, "true")).To(Succeed())
+	g.Expect(interval.Deterministic).To(BeTrue())
 }
 
 func TestIntervalOptions_BindFlagsWithDefault(t *testing.T) {
@@ -17,6 +17,8 @@ limitations under the License.
 package jitter
 
 import (
+	"hash/fnv"
+	"math"
 	"math/rand"
 	"time"
 )
@@ -51,6 +53,28 @@ func Percent(p float64, r *rand.Rand) Duration {
 	}
 }
 
+// Deterministic returns a Duration function that will modify the given
+// duration by a percentage between -p and p derived from the hash of the
+// given key. The same key always results in the same percentage, which makes
+// the jitter stable across restarts of a process.
+//
+// For example, when the key is the namespace/name of an object, each object
+// is assigned a fixed offset within the +/-p window.
+//
+// When p <= 0 or p >= 1, duration is returned without a modification.
+func Deterministic(p float64, key string) Duration {
+	if p <= 0 || p >= 1 {
+		return NoJitter
+	}
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(key))
+	fraction := float64(h.Sum64()) / float64(math.MaxUint64)
+	return func(d time.Duration) time.Duration {
+		deterministicP := p * (2*fraction - 1)
+		return time.Duration(float64(d) * (1 + deterministicP))
+	}
+}
+
 // defaultOrRand returns the given rand.Rand if it is not nil, otherwise it
 // returns a new rand.Rand
 func defaultOrRand(r *rand.Rand) *rand.Rand {
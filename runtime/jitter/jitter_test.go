@@ -69,3 +69,35 @@ func TestPercent(t *testing.T) {
 		})
 	}
 }
+
+func TestDeterministic(t *testing.T) {
+	tests := []struct {
+		p        float64
+		duration time.Duration
+	}{
+		{p: 0.1, duration: 100 * time.Millisecond},
+		{p: 0, duration: 100 * time.Millisecond},
+		{p: 1, duration: 100 * time.Millisecond},
+		{p: -1, duration: 100 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("p=%v, duration=%v", tt.p, tt.duration), func(t *testing.T) {
+			g := NewWithT(t)
+
+			fn := Deterministic(tt.p, "default/podinfo")
+			if tt.p <= 0 || tt.p >= 1 {
+				g.Expect(fn(tt.duration)).To(Equal(tt.duration))
+				return
+			}
+
+			d := fn(tt.duration)
+			g.Expect(d).To(BeNumerically(">=", float64(tt.duration)*(1-tt.p)))
+			g.Expect(d).To(BeNumerically("<=", float64(tt.duration)*(1+tt.p)))
+			for i := 0; i < 10; i++ {
+				g.Expect(Deterministic(tt.p, "default/podinfo")(tt.duration)).To(Equal(d))
+			}
+			g.Expect(Deterministic(tt.p, "default/other")(tt.duration)).ToNot(Equal(d))
+		})
+	}
+}
@@ -0,0 +1,129 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logger
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// LogLevelAnnotation is the annotation that can be set on an object to
+// override the log verbosity level for operations on that object.
+// The value can be one of 'trace', 'debug', 'info', 'error'.
+const LogLevelAnnotation = "fluxcd.io/log-level"
+
+// levelVerbosity maps the log level strings to the logr verbosity at which
+// messages are still emitted. The 'error' level disables all info messages.
+var levelVerbosity = map[string]int{
+	"trace": TraceLevel,
+	"debug": DebugLevel,
+	"info":  InfoLevel,
+	"error": InfoLevel - 1,
+}
+
+// levelSink is a logr.LogSink that filters messages based on a verbosity
+// level which can be changed per logger, while the underlying sink is
+// configured to accept messages at all levels.
+type levelSink struct {
+	sink      logr.LogSink
+	verbosity int
+}
+
+// newLevelSink wraps the sink of the given logger in a levelSink with the
+// given verbosity.
+func newLevelSink(log logr.Logger, verbosity int) logr.Logger {
+	sink := log.GetSink()
+	// Account for the additional stack frame of the levelSink.
+	if s, ok := sink.(logr.CallDepthLogSink); ok {
+		sink = s.WithCallDepth(1)
+	}
+	return log.WithSink(&levelSink{sink: sink, verbosity: verbosity})
+}
+
+// Init is a no-op, as the wrapped sink has already been initialized.
+func (s *levelSink) Init(logr.RuntimeInfo) {}
+
+// Enabled returns true if the given level is within the verbosity of the sink.
+func (s *levelSink) Enabled(level int) bool {
+	return level <= s.verbosity
+}
+
+func (s *levelSink) Info(level int, msg string, keysAndValues ...interface{}) {
+	s.sink.Info(level, msg, keysAndValues...)
+}
+
+func (s *levelSink) Error(err error, msg string, keysAndValues ...interface{}) {
+	s.sink.Error(err, msg, keysAndValues...)
+}
+
+func (s *levelSink) WithValues(keysAndValues ...interface{}) logr.LogSink {
+	return &levelSink{sink: s.sink.WithValues(keysAndValues...), verbosity: s.verbosity}
+}
+
+func (s *levelSink) WithName(name string) logr.LogSink {
+	return &levelSink{sink: s.sink.WithName(name), verbosity: s.verbosity}
+}
+
+func (s *levelSink) WithCallDepth(depth int) logr.LogSink {
+	if sink, ok := s.sink.(logr.CallDepthLogSink); ok {
+		return &levelSink{sink: sink.WithCallDepth(depth), verbosity: s.verbosity}
+	}
+	return s
+}
+
+// ObjectLogger returns a logger with the verbosity level set to the value of
+// the LogLevelAnnotation of the given object. If the object does not have the
+// annotation, the value is not a valid level, or the logger was not created
+// with NewLogger, the logger is returned unchanged.
+func ObjectLogger(log logr.Logger, obj metav1.Object) logr.Logger {
+	if obj == nil {
+		return log
+	}
+	verbosity, ok := levelVerbosity[obj.GetAnnotations()[LogLevelAnnotation]]
+	if !ok {
+		return log
+	}
+	sink, ok := log.GetSink().(*levelSink)
+	if !ok || sink.verbosity == verbosity {
+		return log
+	}
+	return log.WithSink(&levelSink{sink: sink.sink, verbosity: verbosity})
+}
+
+// IntoObjectContext returns a copy of the context with the logger from the
+// context replaced by the ObjectLogger for the given object. It is intended
+// to be called at the start of a reconciliation, after the object has been
+// fetched, so that all subsequent logging honours the LogLevelAnnotation:
+//
+//	func (r *Reconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+//		obj := &v1.Object{}
+//		if err := r.Get(ctx, req.NamespacedName, obj); err != nil {
+//			return ctrl.Result{}, client.IgnoreNotFound(err)
+//		}
+//		ctx = logger.IntoObjectContext(ctx, obj)
+//		log := ctrl.LoggerFrom(ctx)
+//		...
+//	}
+func IntoObjectContext(ctx context.Context, obj metav1.Object) context.Context {
+	log, err := logr.FromContext(ctx)
+	if err != nil {
+		return ctx
+	}
+	return logr.NewContext(ctx, ObjectLogger(log, obj))
+}
@@ -0,0 +1,167 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logger
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/go-logr/logr/funcr"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// newTestLogger returns a logger at the given verbosity, which records the
+// messages it emits in the returned slice.
+func newTestLogger(verbosity int) (logr.Logger, *[]string) {
+	var msgs []string
+	log := funcr.New(func(prefix, args string) {
+		msgs = append(msgs, prefix+" "+args)
+	}, funcr.Options{Verbosity: TraceLevel})
+	return newLevelSink(log, verbosity), &msgs
+}
+
+func objectWithLogLevel(level string) *corev1.ConfigMap {
+	obj := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+	}
+	if level != "" {
+		obj.Annotations = map[string]string{LogLevelAnnotation: level}
+	}
+	return obj
+}
+
+func TestNewLogger_LogLevel(t *testing.T) {
+	tests := []struct {
+		level   string
+		enabled []int
+	}{
+		{level: "trace", enabled: []int{InfoLevel, DebugLevel, TraceLevel}},
+		{level: "debug", enabled: []int{InfoLevel, DebugLevel}},
+		{level: "info", enabled: []int{InfoLevel}},
+		{level: "error", enabled: nil},
+		{level: "", enabled: []int{InfoLevel}},
+		{level: "invalid", enabled: []int{InfoLevel}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.level, func(t *testing.T) {
+			g := NewWithT(t)
+
+			log := NewLogger(Options{LogEncoding: "json", LogLevel: tt.level})
+			for _, level := range []int{InfoLevel, DebugLevel, TraceLevel} {
+				want := false
+				for _, l := range tt.enabled {
+					want = want || l == level
+				}
+				g.Expect(log.V(level).Enabled()).To(Equal(want), "V(%d)", level)
+			}
+		})
+	}
+}
+
+func TestLevelSink(t *testing.T) {
+	g := NewWithT(t)
+
+	log, msgs := newTestLogger(DebugLevel)
+	log.Info("info")
+	log.V(DebugLevel).Info("debug")
+	log.V(TraceLevel).Info("trace")
+	log.Error(errors.New("failed"), "error")
+	g.Expect(*msgs).To(HaveLen(3))
+	g.Expect(*msgs).To(ContainElement(ContainSubstring(`"msg"="debug"`)))
+	g.Expect(*msgs).ToNot(ContainElement(ContainSubstring(`"msg"="trace"`)))
+
+	// Errors are logged at the 'error' level, info messages are not.
+	log, msgs = newTestLogger(levelVerbosity["error"])
+	log.Info("info")
+	log.Error(errors.New("failed"), "error")
+	g.Expect(*msgs).To(HaveLen(1))
+	g.Expect((*msgs)[0]).To(ContainSubstring(`"msg"="error"`))
+}
+
+func TestObjectLogger(t *testing.T) {
+	tests := []struct {
+		name       string
+		annotation string
+		// enabled is whether V(0), V(1) and V(2) are enabled.
+		enabled [3]bool
+	}{
+		{name: "raises the level", annotation: "trace", enabled: [3]bool{true, true, true}},
+		{name: "lowers the level", annotation: "info", enabled: [3]bool{true, false, false}},
+		{name: "disables info messages", annotation: "error", enabled: [3]bool{false, false, false}},
+		{name: "keeps the default without annotation", annotation: "", enabled: [3]bool{true, true, false}},
+		{name: "keeps the default for invalid values", annotation: "verbose", enabled: [3]bool{true, true, false}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			base, msgs := newTestLogger(DebugLevel)
+			log := ObjectLogger(base, objectWithLogLevel(tt.annotation))
+
+			// The level is kept by the loggers derived from the object logger.
+			for _, l := range []logr.Logger{log, log.WithValues("key", "value"), log.WithName("child")} {
+				for level, enabled := range tt.enabled {
+					g.Expect(l.V(level).Enabled()).To(Equal(enabled), "V(%d)", level)
+				}
+			}
+
+			// The base logger is not changed.
+			g.Expect(base.V(DebugLevel).Enabled()).To(BeTrue())
+			g.Expect(base.V(TraceLevel).Enabled()).To(BeFalse())
+
+			log.WithName("child").WithValues("key", "value").V(TraceLevel).Info("trace")
+			if tt.enabled[TraceLevel] {
+				g.Expect(*msgs).To(ConsistOf(And(
+					ContainSubstring("child"),
+					ContainSubstring(`"msg"="trace"`),
+					ContainSubstring(`"key"="value"`),
+				)))
+			} else {
+				g.Expect(*msgs).To(BeEmpty())
+			}
+		})
+	}
+}
+
+func TestObjectLogger_NotLevelSink(t *testing.T) {
+	g := NewWithT(t)
+
+	log := funcr.New(func(prefix, args string) {}, funcr.Options{})
+	g.Expect(ObjectLogger(log, objectWithLogLevel("trace"))).To(Equal(log))
+	g.Expect(ObjectLogger(log, nil)).To(Equal(log))
+}
+
+func TestIntoObjectContext(t *testing.T) {
+	g := NewWithT(t)
+
+	base, _ := newTestLogger(InfoLevel)
+	ctx := logr.NewContext(context.TODO(), base)
+
+	log := logr.FromContextOrDiscard(IntoObjectContext(ctx, objectWithLogLevel("debug")))
+	g.Expect(log.V(DebugLevel).Enabled()).To(BeTrue())
+	g.Expect(log.V(TraceLevel).Enabled()).To(BeFalse())
+
+	log = logr.FromContextOrDiscard(IntoObjectContext(ctx, objectWithLogLevel("")))
+	g.Expect(log.V(DebugLevel).Enabled()).To(BeFalse())
+
+	// A context without logger is returned unchanged.
+	g.Expect(IntoObjectContext(context.TODO(), objectWithLogLevel("debug"))).To(Equal(context.TODO()))
+}
@@ -110,15 +110,21 @@ func NewLogger(opts Options) logr.Logger {
 		zap.JSONEncoder(zapOpts.EncoderConfigOptions...)(&zapOpts)
 	}
 
-	if l, ok := levelStrings[opts.LogLevel]; ok {
-		zapOpts.Level = l
+	// The zap logger accepts messages at all levels, the configured level is
+	// enforced by the levelSink to allow overrides per object using the
+	// LogLevelAnnotation.
+	zapOpts.Level = levelStrings["trace"]
+
+	verbosity := InfoLevel
+	if v, ok := levelVerbosity[opts.LogLevel]; ok {
+		verbosity = v
 	}
 
 	if l, ok := stackLevelStrings[opts.LogLevel]; ok {
 		zapOpts.StacktraceLevel = l
 	}
 
-	return zap.New(zap.UseFlagOptions(&zapOpts))
+	return newLevelSink(zap.New(zap.UseFlagOptions(&zapOpts)), verbosity)
 }
 
 // SetLogger sets the logger for the controller-runtime and klog packages to the given logger.
@@ -0,0 +1,253 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	crtlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// Profile curates the metric families exposed by a controller, to bound
+// the cardinality of the metrics registered by its dependencies, e.g. the
+// controller-runtime workqueue and client-go REST client metrics.
+type Profile struct {
+	// Rules are the rules applied to the gathered metric families. The first
+	// rule matching the name of a metric family applies.
+	Rules []ProfileRule
+}
+
+// ProfileRule defines how a metric family is curated.
+type ProfileRule struct {
+	// Metric is the name of the metric family the rule applies to.
+	// A trailing '*' matches the metric families with the given prefix.
+	Metric string
+	// Drop removes the metric family, the Aggregates are still added.
+	Drop bool
+	// DropLabels removes the given labels from the series of the metric
+	// family, the series which no longer differ are summed up.
+	DropLabels []string
+	// Aggregates defines the metric families to be added by summing up
+	// the series of the metric family.
+	Aggregates []Aggregate
+}
+
+// Aggregate defines a metric family computed by summing up the series of
+// another metric family. Quantiles of summaries are not aggregated.
+type Aggregate struct {
+	// Name is the name of the aggregate metric family.
+	Name string
+	// Help is the description of the aggregate metric family.
+	Help string
+	// By defines the labels kept in the aggregate metric family.
+	By []string
+}
+
+// CuratedProfile drops the URL and host labels of the REST client metrics,
+// and adds totals of the workqueue and REST client metrics across the
+// controllers, which are sufficient for alerting in large fleets.
+var CuratedProfile = Profile{
+	Rules: []ProfileRule{
+		{
+			Metric:     "rest_client_requests_total",
+			DropLabels: []string{"url", "host"},
+			Aggregates: []Aggregate{{
+				Name: "gotk_rest_client_requests_by_code_total",
+				Help: "Number of HTTP requests made by the REST client, partitioned by status code.",
+				By:   []string{"code"},
+			}},
+		},
+		{
+			Metric:     "rest_client_*",
+			DropLabels: []string{"url", "host"},
+		},
+		{
+			Metric: "workqueue_depth",
+			Aggregates: []Aggregate{{
+				Name: "gotk_workqueue_depth_total",
+				Help: "Current depth of all the workqueues.",
+			}},
+		},
+		{
+			Metric: "workqueue_retries_total",
+			Aggregates: []Aggregate{{
+				Name: "gotk_workqueue_retries_total",
+				Help: "Total number of retries handled by all the workqueues.",
+			}},
+		},
+	},
+}
+
+// Gatherer returns a prometheus.Gatherer which applies the profile to the
+// metric families gathered from the given gatherer.
+func (p Profile) Gatherer(g prometheus.Gatherer) prometheus.Gatherer {
+	return prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		mfs, err := g.Gather()
+		return p.apply(mfs), err
+	})
+}
+
+// ApplyProfile configures the controller-runtime metrics registry to expose
+// the metrics curated by the given profile. It must be called before the
+// controller manager is created.
+func ApplyProfile(p Profile) {
+	crtlmetrics.Registry = &profiledRegistry{
+		RegistererGatherer: crtlmetrics.Registry,
+		gatherer:           p.Gatherer(crtlmetrics.Registry),
+	}
+}
+
+type profiledRegistry struct {
+	crtlmetrics.RegistererGatherer
+	gatherer prometheus.Gatherer
+}
+
+func (r *profiledRegistry) Gather() ([]*dto.MetricFamily, error) {
+	return r.gatherer.Gather()
+}
+
+// rule returns the first rule matching the given metric name.
+func (p Profile) rule(name string) (ProfileRule, bool) {
+	for _, r := range p.Rules {
+		if prefix, ok := strings.CutSuffix(r.Metric, "*"); ok && strings.HasPrefix(name, prefix) {
+			return r, true
+		}
+		if r.Metric == name {
+			return r, true
+		}
+	}
+	return ProfileRule{}, false
+}
+
+// apply returns the metric families curated according to the profile,
+// sorted by name.
+func (p Profile) apply(mfs []*dto.MetricFamily) []*dto.MetricFamily {
+	result := make([]*dto.MetricFamily, 0, len(mfs))
+	for _, mf := range mfs {
+		r, ok := p.rule(mf.GetName())
+		if !ok {
+			result = append(result, mf)
+			continue
+		}
+
+		for _, a := range r.Aggregates {
+			by := make(map[string]bool, len(a.By))
+			for _, l := range a.By {
+				by[l] = true
+			}
+			if agg := aggregate(mf, a.Name, a.Help, func(l string) bool { return by[l] }); len(agg.Metric) > 0 {
+				result = append(result, agg)
+			}
+		}
+
+		switch {
+		case r.Drop:
+		case len(r.DropLabels) > 0:
+			drop := make(map[string]bool, len(r.DropLabels))
+			for _, l := range r.DropLabels {
+				drop[l] = true
+			}
+			result = append(result, aggregate(mf, mf.GetName(), mf.GetHelp(), func(l string) bool { return !drop[l] }))
+		default:
+			result = append(result, mf)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].GetName() < result[j].GetName() })
+	return result
+}
+
+// aggregate returns a metric family with the given name and help, whose
+// series sum up the series of mf which have the same values for the labels
+// to keep.
+func aggregate(mf *dto.MetricFamily, name, help string, keep func(string) bool) *dto.MetricFamily {
+	out := &dto.MetricFamily{
+		Name: &name,
+		Help: &help,
+		Type: mf.Type,
+	}
+
+	index := make(map[string]*dto.Metric)
+	for _, m := range mf.GetMetric() {
+		var labels []*dto.LabelPair
+		var key strings.Builder
+		for _, l := range m.GetLabel() {
+			if keep(l.GetName()) {
+				labels = append(labels, l)
+				key.WriteString(l.GetName() + "\xff" + l.GetValue() + "\xff")
+			}
+		}
+
+		agg, ok := index[key.String()]
+		if !ok {
+			agg = &dto.Metric{Label: labels}
+			index[key.String()] = agg
+			out.Metric = append(out.Metric, agg)
+		}
+		merge(agg, m, mf.GetType())
+	}
+	return out
+}
+
+// merge adds the value of the metric m to the metric agg.
+func merge(agg, m *dto.Metric, t dto.MetricType) {
+	switch t {
+	case dto.MetricType_COUNTER:
+		if agg.Counter == nil {
+			agg.Counter = &dto.Counter{Value: new(float64)}
+		}
+		*agg.Counter.Value += m.GetCounter().GetValue()
+	case dto.MetricType_GAUGE:
+		if agg.Gauge == nil {
+			agg.Gauge = &dto.Gauge{Value: new(float64)}
+		}
+		*agg.Gauge.Value += m.GetGauge().GetValue()
+	case dto.MetricType_UNTYPED:
+		if agg.Untyped == nil {
+			agg.Untyped = &dto.Untyped{Value: new(float64)}
+		}
+		*agg.Untyped.Value += m.GetUntyped().GetValue()
+	case dto.MetricType_SUMMARY:
+		if agg.Summary == nil {
+			agg.Summary = &dto.Summary{SampleCount: new(uint64), SampleSum: new(float64)}
+		}
+		*agg.Summary.SampleCount += m.GetSummary().GetSampleCount()
+		*agg.Summary.SampleSum += m.GetSummary().GetSampleSum()
+	case dto.MetricType_HISTOGRAM:
+		h := m.GetHistogram()
+		if agg.Histogram == nil {
+			agg.Histogram = &dto.Histogram{SampleCount: new(uint64), SampleSum: new(float64)}
+			for _, b := range h.GetBucket() {
+				agg.Histogram.Bucket = append(agg.Histogram.Bucket, &dto.Bucket{
+					UpperBound:      b.UpperBound,
+					CumulativeCount: new(uint64),
+				})
+			}
+		}
+		*agg.Histogram.SampleCount += h.GetSampleCount()
+		*agg.Histogram.SampleSum += h.GetSampleSum()
+		// The series of a histogram vector share the same buckets.
+		for i, b := range h.GetBucket() {
+			if i < len(agg.Histogram.Bucket) && agg.Histogram.Bucket[i].GetUpperBound() == b.GetUpperBound() {
+				*agg.Histogram.Bucket[i].CumulativeCount += b.GetCumulativeCount()
+			}
+		}
+	}
+}
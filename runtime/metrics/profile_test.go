@@ -0,0 +1,119 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProfile_Gatherer(t *testing.T) {
+	reg := prometheus.NewRegistry()
+
+	requests := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "rest_client_requests_total",
+		Help: "Number of HTTP requests.",
+	}, []string{"code", "host", "method"})
+	latency := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "rest_client_request_duration_seconds",
+		Help:    "Request latency.",
+		Buckets: []float64{0.1, 1},
+	}, []string{"url", "verb"})
+	depth := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "workqueue_depth",
+		Help: "Current depth of workqueue.",
+	}, []string{"name"})
+	reg.MustRegister(requests, latency, depth)
+
+	requests.WithLabelValues("200", "10.0.0.1:443", "GET").Add(1)
+	requests.WithLabelValues("200", "10.0.0.2:443", "GET").Add(2)
+	requests.WithLabelValues("500", "10.0.0.2:443", "PUT").Add(4)
+	latency.WithLabelValues("/api/v1/namespaces/a", "GET").Observe(0.05)
+	latency.WithLabelValues("/api/v1/namespaces/b", "GET").Observe(0.5)
+	depth.WithLabelValues("kustomization").Set(3)
+	depth.WithLabelValues("gitrepository").Set(5)
+
+	mfs, err := CuratedProfile.Gatherer(reg).Gather()
+	require.NoError(t, err)
+
+	families := make(map[string]*dto.MetricFamily)
+	for _, mf := range mfs {
+		families[mf.GetName()] = mf
+	}
+	require.Len(t, families, 5)
+
+	series := func(name string) map[string]*dto.Metric {
+		result := make(map[string]*dto.Metric)
+		for _, m := range families[name].GetMetric() {
+			var key string
+			for _, l := range m.GetLabel() {
+				key += l.GetName() + "=" + l.GetValue() + ","
+			}
+			result[key] = m
+		}
+		return result
+	}
+
+	// The host label is dropped, and the series are summed up.
+	s := series("rest_client_requests_total")
+	require.Len(t, s, 2)
+	require.Equal(t, float64(3), s["code=200,method=GET,"].GetCounter().GetValue())
+	require.Equal(t, float64(4), s["code=500,method=PUT,"].GetCounter().GetValue())
+
+	s = series("gotk_rest_client_requests_by_code_total")
+	require.Len(t, s, 2)
+	require.Equal(t, float64(3), s["code=200,"].GetCounter().GetValue())
+
+	// The url label is dropped from histograms.
+	s = series("rest_client_request_duration_seconds")
+	require.Len(t, s, 1)
+	h := s["verb=GET,"].GetHistogram()
+	require.Equal(t, uint64(2), h.GetSampleCount())
+	require.Equal(t, uint64(1), h.GetBucket()[0].GetCumulativeCount())
+	require.Equal(t, uint64(2), h.GetBucket()[1].GetCumulativeCount())
+
+	// Workqueue metrics are kept as is, along with their total.
+	require.Len(t, series("workqueue_depth"), 2)
+	s = series("gotk_workqueue_depth_total")
+	require.Len(t, s, 1)
+	require.Equal(t, float64(8), s[""].GetGauge().GetValue())
+}
+
+func TestProfile_Drop(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	depth := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "workqueue_depth",
+		Help: "Current depth of workqueue.",
+	}, []string{"name"})
+	reg.MustRegister(depth)
+	depth.WithLabelValues("a").Set(1)
+	depth.WithLabelValues("b").Set(2)
+
+	profile := Profile{Rules: []ProfileRule{{
+		Metric:     "workqueue_*",
+		Drop:       true,
+		Aggregates: []Aggregate{{Name: "workqueue_depth_total", Help: "Total depth."}},
+	}}}
+	mfs, err := profile.Gatherer(reg).Gather()
+	require.NoError(t, err)
+	require.Len(t, mfs, 1)
+	require.Equal(t, "workqueue_depth_total", mfs[0].GetName())
+	require.Equal(t, float64(3), mfs[0].GetMetric()[0].GetGauge().GetValue())
+}
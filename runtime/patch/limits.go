@@ -0,0 +1,156 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package patch
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const (
+	// TruncatedMessageMarker is appended to the condition messages truncated to the limit set with
+	// WithConditionMessageLimit.
+	TruncatedMessageMarker = "... (truncated)"
+
+	// TruncatedStatusAnnotation is the annotation recording the status lists truncated to the limits set with
+	// WithStatusListLimits, in the format '<path>=<original length>[,<path>=<original length>]'.
+	TruncatedStatusAnnotation = "patch.fluxcd.io/truncated-status"
+)
+
+// truncateConditionMessages truncates the messages of the status conditions of the given object to the limit.
+// It returns true if any message was truncated.
+func truncateConditionMessages(u *unstructured.Unstructured, limit int) (bool, error) {
+	conditions, found, err := unstructured.NestedSlice(u.Object, "status", "conditions")
+	if err != nil || !found {
+		return false, err
+	}
+
+	truncated := false
+	for _, c := range conditions {
+		condition, ok := c.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		message, ok := condition["message"].(string)
+		if !ok || len(message) <= limit {
+			continue
+		}
+		condition["message"] = truncateMessage(message, limit)
+		truncated = true
+	}
+	if !truncated {
+		return false, nil
+	}
+	return true, unstructured.SetNestedSlice(u.Object, conditions, "status", "conditions")
+}
+
+// truncateMessage truncates the message to the limit in bytes, including the truncation marker if it fits. Multi-line messages,
+// e.g. lists of failing health checks, are truncated at the last line that fits, and the marker holds the number of
+// lines left out.
+func truncateMessage(message string, limit int) string {
+	if len(message) <= limit {
+		return message
+	}
+
+	if lines := strings.Split(message, "\n"); len(lines) > 1 {
+		var b strings.Builder
+		for i, line := range lines {
+			marker := fmt.Sprintf("\n... (%d more lines truncated)", len(lines)-i)
+			if b.Len()+len(line)+len(marker)+1 > limit {
+				if i > 0 {
+					b.WriteString(marker)
+					return b.String()
+				}
+				break
+			}
+			if i > 0 {
+				b.WriteString("\n")
+			}
+			b.WriteString(line)
+		}
+	}
+
+	// The marker is left out if it does not fit within the limit.
+	marker := TruncatedMessageMarker
+	if limit < len(marker) {
+		marker = ""
+	}
+	cut := limit - len(marker)
+	if cut < 0 {
+		cut = 0
+	}
+	// Do not split multi-byte characters.
+	for cut > 0 && !utf8.RuneStart(message[cut]) {
+		cut--
+	}
+	return message[:cut] + marker
+}
+
+// truncateStatusLists truncates the lists at the given status paths to their limit, and records the original
+// length of the truncated lists in the TruncatedStatusAnnotation. It returns true if the object was changed.
+func truncateStatusLists(u *unstructured.Unstructured, limits map[string]int) (bool, error) {
+	truncated := map[string]int{}
+	for path, limit := range limits {
+		fields := append([]string{"status"}, strings.Split(path, ".")...)
+		list, found, err := unstructured.NestedSlice(u.Object, fields...)
+		if err != nil {
+			return false, fmt.Errorf("invalid status list '%s': %w", path, err)
+		}
+		if !found || len(list) <= limit {
+			continue
+		}
+		if err := unstructured.SetNestedSlice(u.Object, list[:limit], fields...); err != nil {
+			return false, err
+		}
+		truncated[path] = len(list)
+	}
+
+	annotations := u.GetAnnotations()
+	previous, hadAnnotation := annotations[TruncatedStatusAnnotation]
+	if len(truncated) == 0 {
+		if !hadAnnotation {
+			return false, nil
+		}
+		delete(annotations, TruncatedStatusAnnotation)
+		u.SetAnnotations(annotations)
+		return true, nil
+	}
+
+	paths := make([]string, 0, len(truncated))
+	for path := range truncated {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	entries := make([]string, 0, len(paths))
+	for _, path := range paths {
+		entries = append(entries, path+"="+strconv.Itoa(truncated[path]))
+	}
+	value := strings.Join(entries, ",")
+	if value != previous {
+		if annotations == nil {
+			annotations = map[string]string{}
+		}
+		annotations[TruncatedStatusAnnotation] = value
+		u.SetAnnotations(annotations)
+	}
+	return true, nil
+}
@@ -0,0 +1,157 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package patch
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestTruncateMessage(t *testing.T) {
+	t.Run("keeps short messages", func(t *testing.T) {
+		g := NewWithT(t)
+
+		g.Expect(truncateMessage("ready", 10)).To(Equal("ready"))
+	})
+
+	t.Run("truncates single-line messages", func(t *testing.T) {
+		g := NewWithT(t)
+
+		got := truncateMessage(strings.Repeat("a", 100), 50)
+		g.Expect(got).To(HaveLen(50))
+		g.Expect(got).To(HaveSuffix(TruncatedMessageMarker))
+	})
+
+	t.Run("does not split multi-byte characters", func(t *testing.T) {
+		g := NewWithT(t)
+
+		got := truncateMessage(strings.Repeat("é", 50), 20)
+		g.Expect(got).To(Equal("éé" + TruncatedMessageMarker))
+	})
+
+	t.Run("leaves out the marker if it exceeds the limit", func(t *testing.T) {
+		g := NewWithT(t)
+
+		g.Expect(truncateMessage(strings.Repeat("a", 100), 5)).To(Equal("aaaaa"))
+		g.Expect(truncateMessage("ééé", 3)).To(Equal("é"))
+		g.Expect(truncateMessage("failed", 0)).To(BeEmpty())
+	})
+
+	t.Run("truncates multi-line messages at line boundaries", func(t *testing.T) {
+		g := NewWithT(t)
+
+		var lines []string
+		for i := 0; i < 1000; i++ {
+			lines = append(lines, fmt.Sprintf("Deployment/default/app-%d status: 'InProgress'", i))
+		}
+		got := truncateMessage(strings.Join(lines, "\n"), 1024)
+		g.Expect(len(got)).To(BeNumerically("<=", 1024))
+		g.Expect(got).To(HavePrefix(lines[0] + "\n"))
+		g.Expect(got).To(MatchRegexp(`\n\.\.\. \(\d+ more lines truncated\)$`))
+	})
+}
+
+func TestTruncateConditionMessages(t *testing.T) {
+	g := NewWithT(t)
+
+	u := &unstructured.Unstructured{Object: map[string]interface{}{
+		"status": map[string]interface{}{
+			"conditions": []interface{}{
+				map[string]interface{}{"type": "Ready", "message": strings.Repeat("a", 100)},
+				map[string]interface{}{"type": "Healthy", "message": "ok"},
+			},
+		},
+	}}
+
+	truncated, err := truncateConditionMessages(u, 50)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(truncated).To(BeTrue())
+
+	conditions, _, _ := unstructured.NestedSlice(u.Object, "status", "conditions")
+	g.Expect(conditions[0].(map[string]interface{})["message"]).To(HaveLen(50))
+	g.Expect(conditions[1].(map[string]interface{})["message"]).To(Equal("ok"))
+
+	truncated, err = truncateConditionMessages(u, 50)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(truncated).To(BeFalse())
+}
+
+func TestTruncateStatusLists(t *testing.T) {
+	entries := func(n int) []interface{} {
+		var list []interface{}
+		for i := 0; i < n; i++ {
+			list = append(list, map[string]interface{}{"id": fmt.Sprintf("entry-%d", i)})
+		}
+		return list
+	}
+
+	t.Run("truncates lists and records their length", func(t *testing.T) {
+		g := NewWithT(t)
+
+		u := &unstructured.Unstructured{Object: map[string]interface{}{
+			"status": map[string]interface{}{
+				"inventory": map[string]interface{}{"entries": entries(10)},
+				"history":   entries(2),
+			},
+		}}
+
+		changed, err := truncateStatusLists(u, map[string]int{"inventory.entries": 3, "history": 5})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(changed).To(BeTrue())
+
+		list, _, _ := unstructured.NestedSlice(u.Object, "status", "inventory", "entries")
+		g.Expect(list).To(HaveLen(3))
+		list, _, _ = unstructured.NestedSlice(u.Object, "status", "history")
+		g.Expect(list).To(HaveLen(2))
+		g.Expect(u.GetAnnotations()).To(HaveKeyWithValue(TruncatedStatusAnnotation, "inventory.entries=10"))
+	})
+
+	t.Run("removes the annotation when lists fit", func(t *testing.T) {
+		g := NewWithT(t)
+
+		u := &unstructured.Unstructured{Object: map[string]interface{}{
+			"status": map[string]interface{}{
+				"inventory": map[string]interface{}{"entries": entries(2)},
+			},
+		}}
+		u.SetAnnotations(map[string]string{TruncatedStatusAnnotation: "inventory.entries=10"})
+
+		changed, err := truncateStatusLists(u, map[string]int{"inventory.entries": 3})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(changed).To(BeTrue())
+		g.Expect(u.GetAnnotations()).ToNot(HaveKey(TruncatedStatusAnnotation))
+
+		changed, err = truncateStatusLists(u, map[string]int{"inventory.entries": 3})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(changed).To(BeFalse())
+	})
+
+	t.Run("rejects paths which are not lists", func(t *testing.T) {
+		g := NewWithT(t)
+
+		u := &unstructured.Unstructured{Object: map[string]interface{}{
+			"status": map[string]interface{}{"inventory": "entries"},
+		}}
+
+		_, err := truncateStatusLists(u, map[string]int{"inventory.entries": 3})
+		g.Expect(err).To(HaveOccurred())
+	})
+}
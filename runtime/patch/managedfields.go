@@ -0,0 +1,121 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package patch
+
+import (
+	"bytes"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
+)
+
+// managedFieldsKey identifies the managed fields entries of a field manager
+// that can be merged into a single entry.
+type managedFieldsKey struct {
+	operation   metav1.ManagedFieldsOperationType
+	apiVersion  string
+	subresource string
+}
+
+// migrateManagedFields renames the managed fields entries of the given
+// previous field managers to the new field manager. Entries which end up with
+// the same operation, API version and subresource as an existing entry of the
+// new field manager are merged into it. It returns true if any entry was
+// migrated.
+func migrateManagedFields(entries []metav1.ManagedFieldsEntry, previous []string, manager string) ([]metav1.ManagedFieldsEntry, bool, error) {
+	result := make([]metav1.ManagedFieldsEntry, 0, len(entries))
+	index := make(map[managedFieldsKey]int)
+	var migrate []metav1.ManagedFieldsEntry
+
+	for _, entry := range entries {
+		if entry.Manager != manager && stringInSlice(previous, entry.Manager) {
+			migrate = append(migrate, entry)
+			continue
+		}
+		if entry.Manager == manager {
+			index[keyOf(entry)] = len(result)
+		}
+		result = append(result, entry)
+	}
+
+	if len(migrate) == 0 {
+		return entries, false, nil
+	}
+
+	for _, entry := range migrate {
+		entry.Manager = manager
+		i, ok := index[keyOf(entry)]
+		if !ok {
+			index[keyOf(entry)] = len(result)
+			result = append(result, entry)
+			continue
+		}
+
+		fields, err := mergeFieldsV1(result[i].FieldsV1, entry.FieldsV1)
+		if err != nil {
+			return nil, false, fmt.Errorf("unable to merge managed fields of '%s': %w", entry.Manager, err)
+		}
+		result[i].FieldsV1 = fields
+		if entry.Time != nil && (result[i].Time == nil || result[i].Time.Before(entry.Time)) {
+			result[i].Time = entry.Time
+		}
+	}
+
+	return result, true, nil
+}
+
+func keyOf(entry metav1.ManagedFieldsEntry) managedFieldsKey {
+	return managedFieldsKey{
+		operation:   entry.Operation,
+		apiVersion:  entry.APIVersion,
+		subresource: entry.Subresource,
+	}
+}
+
+// mergeFieldsV1 returns the union of the given sets of fields.
+func mergeFieldsV1(a, b *metav1.FieldsV1) (*metav1.FieldsV1, error) {
+	if a == nil {
+		return b, nil
+	}
+	if b == nil {
+		return a, nil
+	}
+
+	var as, bs fieldpath.Set
+	if err := as.FromJSON(bytes.NewReader(a.Raw)); err != nil {
+		return nil, err
+	}
+	if err := bs.FromJSON(bytes.NewReader(b.Raw)); err != nil {
+		return nil, err
+	}
+
+	raw, err := as.Union(&bs).ToJSON()
+	if err != nil {
+		return nil, err
+	}
+	return &metav1.FieldsV1{Raw: raw}, nil
+}
+
+func stringInSlice(s []string, val string) bool {
+	for _, v := range s {
+		if v == val {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,146 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package patch
+
+import (
+	"bytes"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
+)
+
+func TestHelper_PreviousFieldOwners(t *testing.T) {
+	g := NewWithT(t)
+
+	obj := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: "test-",
+			Namespace:    "default",
+		},
+		Data: map[string]string{"a": "a"},
+	}
+
+	t.Log("Creating the object with the previous field owner")
+	g.Expect(env.Create(ctx, obj, client.FieldOwner("old-controller"))).To(Succeed())
+	defer func() {
+		g.Expect(env.Delete(ctx, obj)).To(Succeed())
+	}()
+	key := client.ObjectKeyFromObject(obj)
+
+	t.Log("Checking that the object has been created")
+	g.Eventually(func() error {
+		return env.Get(ctx, key, obj)
+	}).Should(Succeed())
+
+	t.Log("Patching the object with the new field owner")
+	patcher, err := NewHelper(obj, env)
+	g.Expect(err).ToNot(HaveOccurred())
+	obj.Data["b"] = "b"
+	g.Expect(patcher.Patch(ctx, obj,
+		WithFieldOwner("new-controller"),
+		WithPreviousFieldOwners{"old-controller"},
+	)).To(Succeed())
+
+	t.Log("Validating the fields are owned by the new field owner only")
+	g.Eventually(func() []string {
+		objAfter := &corev1.ConfigMap{}
+		if err := env.Get(ctx, key, objAfter); err != nil {
+			return nil
+		}
+		var managers []string
+		for _, entry := range objAfter.ManagedFields {
+			managers = append(managers, entry.Manager)
+			if entry.Manager != "new-controller" || entry.FieldsV1 == nil {
+				continue
+			}
+			var set fieldpath.Set
+			if err := set.FromJSON(bytes.NewReader(entry.FieldsV1.Raw)); err != nil {
+				return nil
+			}
+			if !set.Has(fieldpath.MakePathOrDie("data", "a")) || !set.Has(fieldpath.MakePathOrDie("data", "b")) {
+				return nil
+			}
+		}
+		return managers
+	}, timeout).Should(Equal([]string{"new-controller"}))
+}
+
+func TestMigrateManagedFields(t *testing.T) {
+	labelA := &metav1.FieldsV1{Raw: []byte(`{"f:metadata":{"f:labels":{"f:a":{}}}}`)}
+	labelB := &metav1.FieldsV1{Raw: []byte(`{"f:metadata":{"f:labels":{"f:b":{}}}}`)}
+	status := &metav1.FieldsV1{Raw: []byte(`{"f:status":{"f:observedGeneration":{}}}`)}
+
+	t.Run("renames entries of previous field owners", func(t *testing.T) {
+		g := NewWithT(t)
+
+		entries := []metav1.ManagedFieldsEntry{
+			{Manager: "kubectl", Operation: metav1.ManagedFieldsOperationUpdate, APIVersion: "v1", FieldsV1: labelB},
+			{Manager: "old-controller", Operation: metav1.ManagedFieldsOperationUpdate, APIVersion: "v1", FieldsV1: labelA},
+			{Manager: "old-controller", Operation: metav1.ManagedFieldsOperationUpdate, APIVersion: "v1", Subresource: "status", FieldsV1: status},
+		}
+
+		got, migrated, err := migrateManagedFields(entries, []string{"old-controller"}, "new-controller")
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(migrated).To(BeTrue())
+		g.Expect(got).To(HaveLen(3))
+		g.Expect(got[0].Manager).To(Equal("kubectl"))
+		g.Expect(got[1].Manager).To(Equal("new-controller"))
+		g.Expect(got[1].Subresource).To(BeEmpty())
+		g.Expect(got[2].Manager).To(Equal("new-controller"))
+		g.Expect(got[2].Subresource).To(Equal("status"))
+	})
+
+	t.Run("merges entries into existing entries of the field owner", func(t *testing.T) {
+		g := NewWithT(t)
+
+		older := metav1.Now()
+		newer := metav1.NewTime(older.Add(1))
+		entries := []metav1.ManagedFieldsEntry{
+			{Manager: "new-controller", Operation: metav1.ManagedFieldsOperationUpdate, APIVersion: "v1", Time: &older, FieldsV1: labelA},
+			{Manager: "old-controller", Operation: metav1.ManagedFieldsOperationUpdate, APIVersion: "v1", Time: &newer, FieldsV1: labelB},
+		}
+
+		got, migrated, err := migrateManagedFields(entries, []string{"old-controller"}, "new-controller")
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(migrated).To(BeTrue())
+		g.Expect(got).To(HaveLen(1))
+		g.Expect(got[0].Manager).To(Equal("new-controller"))
+		g.Expect(got[0].Time).To(Equal(&newer))
+
+		var set fieldpath.Set
+		g.Expect(set.FromJSON(bytes.NewReader(got[0].FieldsV1.Raw))).To(Succeed())
+		g.Expect(set.Has(fieldpath.MakePathOrDie("metadata", "labels", "a"))).To(BeTrue())
+		g.Expect(set.Has(fieldpath.MakePathOrDie("metadata", "labels", "b"))).To(BeTrue())
+	})
+
+	t.Run("ignores entries of other field owners", func(t *testing.T) {
+		g := NewWithT(t)
+
+		entries := []metav1.ManagedFieldsEntry{
+			{Manager: "kubectl", Operation: metav1.ManagedFieldsOperationUpdate, APIVersion: "v1", FieldsV1: labelA},
+		}
+
+		got, migrated, err := migrateManagedFields(entries, []string{"old-controller"}, "new-controller")
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(migrated).To(BeFalse())
+		g.Expect(got).To(Equal(entries))
+	})
+}
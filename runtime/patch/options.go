@@ -44,6 +44,19 @@ type HelperOptions struct {
 
 	// FieldOwner defines the field owner configuration for Kubernetes patch operations.
 	FieldOwner string
+
+	// PreviousFieldOwners defines the field owners used by previous versions of the controller.
+	// The managed fields of these owners are migrated to FieldOwner on patch.
+	PreviousFieldOwners []string
+
+	// ConditionMessageLimit defines the maximum length in bytes of the status condition messages.
+	// Longer messages are truncated and marked with TruncatedMessageMarker.
+	ConditionMessageLimit int
+
+	// StatusListLimits defines the maximum number of items of the status lists at the given paths,
+	// relative to the status and dot separated, e.g. 'inventory.entries'.
+	// The truncated lists are recorded in the TruncatedStatusAnnotation.
+	StatusListLimits map[string]int
 }
 
 // WithForceOverwriteConditions allows the patch helper to overwrite conditions in case of conflicts.
@@ -82,3 +95,36 @@ type WithFieldOwner string
 func (w WithFieldOwner) ApplyToHelper(in *HelperOptions) {
 	in.FieldOwner = string(w)
 }
+
+// WithPreviousFieldOwners migrates the managed fields of the given field managers to the field manager set with
+// WithFieldOwner. This allows a controller to rename its field owner without leaving the fields owned by both the old
+// and the new field manager.
+type WithPreviousFieldOwners []string
+
+// ApplyToHelper applies this configuration to the given HelperOptions.
+func (w WithPreviousFieldOwners) ApplyToHelper(in *HelperOptions) {
+	in.PreviousFieldOwners = w
+}
+
+// WithConditionMessageLimit truncates the status condition messages longer than the given number of bytes, to avoid
+// exceeding the request size limit of the API server with messages listing thousands of entries. The truncated
+// messages end with TruncatedMessageMarker, or with the number of lines left out for multi-line messages.
+// The changes are made to the patched object.
+type WithConditionMessageLimit int
+
+// ApplyToHelper applies this configuration to the given HelperOptions.
+func (w WithConditionMessageLimit) ApplyToHelper(in *HelperOptions) {
+	in.ConditionMessageLimit = int(w)
+}
+
+// WithStatusListLimits bounds the number of items of inventory-like status lists, keyed by their path relative to the
+// status, e.g. 'inventory.entries'. The lists exceeding their limit are truncated, and their original length is
+// recorded in the TruncatedStatusAnnotation of the object. The changes are made to the patched object.
+// The dropped entries are no longer recorded in the status: when truncating an inventory, the garbage collection of
+// a controller which prunes the objects missing from the new inventory will not prune the dropped objects.
+type WithStatusListLimits map[string]int
+
+// ApplyToHelper applies this configuration to the given HelperOptions.
+func (w WithStatusListLimits) ApplyToHelper(in *HelperOptions) {
+	in.StatusListLimits = w
+}
@@ -180,6 +180,17 @@ func (h *Helper) Patch(ctx context.Context, obj client.Object, opts ...Option) e
 		opt.ApplyToHelper(options)
 	}
 
+	// Migrate the managed fields of the previous field owners, the result is persisted with the metadata patch.
+	if options.FieldOwner != "" && len(options.PreviousFieldOwners) > 0 {
+		managedFields, migrated, err := migrateManagedFields(obj.GetManagedFields(), options.PreviousFieldOwners, options.FieldOwner)
+		if err != nil {
+			return err
+		}
+		if migrated {
+			obj.SetManagedFields(managedFields)
+		}
+	}
+
 	// Convert the object to unstructured to compare against our before copy.
 	h.after, err = ToUnstructured(obj)
 	if err != nil {
@@ -188,12 +199,32 @@ func (h *Helper) Patch(ctx context.Context, obj client.Object, opts ...Option) e
 
 	// Determine if the object has status.
 	if unstructuredHasStatus(h.after) {
+		changed := false
 		if options.IncludeStatusObservedGeneration {
 			// Set status.observedGeneration if we're asked to do so.
 			if err := unstructured.SetNestedField(h.after.Object, h.after.GetGeneration(), "status", "observedGeneration"); err != nil {
 				return err
 			}
+			changed = true
+		}
+
+		// Bound the size of the status if we're asked to do so.
+		if options.ConditionMessageLimit > 0 {
+			truncated, err := truncateConditionMessages(h.after, options.ConditionMessageLimit)
+			if err != nil {
+				return err
+			}
+			changed = changed || truncated
+		}
+		if len(options.StatusListLimits) > 0 {
+			truncated, err := truncateStatusLists(h.after, options.StatusListLimits)
+			if err != nil {
+				return err
+			}
+			changed = changed || truncated
+		}
 
+		if changed {
 			// Restore the changes back to the original object.
 			if err := runtime.DefaultUnstructuredConverter.FromUnstructured(h.after.Object, obj); err != nil {
 				return err
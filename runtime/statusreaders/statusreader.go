@@ -0,0 +1,187 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package statusreaders
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/fluxcd/cli-utils/pkg/kstatus/polling/engine"
+	"github.com/fluxcd/cli-utils/pkg/kstatus/polling/statusreaders"
+	"github.com/fluxcd/cli-utils/pkg/kstatus/status"
+
+	fluxmeta "github.com/fluxcd/pkg/apis/meta"
+)
+
+var (
+	// KustomizationGroupKind is the GroupKind of the Flux Kustomization.
+	KustomizationGroupKind = schema.GroupKind{Group: "kustomize.toolkit.fluxcd.io", Kind: "Kustomization"}
+	// HelmReleaseGroupKind is the GroupKind of the Flux HelmRelease.
+	HelmReleaseGroupKind = schema.GroupKind{Group: "helm.toolkit.fluxcd.io", Kind: "HelmRelease"}
+	// GitRepositoryGroupKind is the GroupKind of the Flux GitRepository.
+	GitRepositoryGroupKind = schema.GroupKind{Group: "source.toolkit.fluxcd.io", Kind: "GitRepository"}
+	// OCIRepositoryGroupKind is the GroupKind of the Flux OCIRepository.
+	OCIRepositoryGroupKind = schema.GroupKind{Group: "source.toolkit.fluxcd.io", Kind: "OCIRepository"}
+	// BucketGroupKind is the GroupKind of the Flux Bucket.
+	BucketGroupKind = schema.GroupKind{Group: "source.toolkit.fluxcd.io", Kind: "Bucket"}
+	// HelmRepositoryGroupKind is the GroupKind of the Flux HelmRepository.
+	HelmRepositoryGroupKind = schema.GroupKind{Group: "source.toolkit.fluxcd.io", Kind: "HelmRepository"}
+	// HelmChartGroupKind is the GroupKind of the Flux HelmChart.
+	HelmChartGroupKind = schema.GroupKind{Group: "source.toolkit.fluxcd.io", Kind: "HelmChart"}
+)
+
+// FluxGroupKinds returns the GroupKinds of the Flux resources supported
+// by the StatusReader returned by NewStatusReader.
+func FluxGroupKinds() []schema.GroupKind {
+	return []schema.GroupKind{
+		KustomizationGroupKind,
+		HelmReleaseGroupKind,
+		GitRepositoryGroupKind,
+		OCIRepositoryGroupKind,
+		BucketGroupKind,
+		HelmRepositoryGroupKind,
+		HelmChartGroupKind,
+	}
+}
+
+// statusReader is an engine.StatusReader restricted to a set of GroupKinds.
+type statusReader struct {
+	engine.StatusReader
+	groupKinds map[schema.GroupKind]struct{}
+}
+
+// NewStatusReader returns an engine.StatusReader which computes the status
+// of the Flux resources with Compute. If no GroupKinds are given, the
+// FluxGroupKinds are supported.
+func NewStatusReader(mapper meta.RESTMapper, groupKinds ...schema.GroupKind) engine.StatusReader {
+	if len(groupKinds) == 0 {
+		groupKinds = FluxGroupKinds()
+	}
+	r := &statusReader{
+		StatusReader: statusreaders.NewGenericStatusReader(mapper, Compute),
+		groupKinds:   make(map[schema.GroupKind]struct{}, len(groupKinds)),
+	}
+	for _, gk := range groupKinds {
+		r.groupKinds[gk] = struct{}{}
+	}
+	return r
+}
+
+// Supports returns true if the StatusReader is configured for the given GroupKind.
+func (r *statusReader) Supports(gk schema.GroupKind) bool {
+	_, ok := r.groupKinds[gk]
+	return ok
+}
+
+// Compute computes the kstatus of a Flux resource from its observed generation
+// and its Ready, Reconciling and Stalled conditions:
+//   - an unobserved generation or a Reconciling condition result in InProgress
+//   - a Stalled condition results in Failed
+//   - a Ready condition with status True results in Current
+//   - a Ready condition with status False results in Failed, as the reconciliation
+//     of the latest generation finished unsuccessfully
+//   - a missing or Unknown Ready condition results in InProgress
+func Compute(u *unstructured.Unstructured) (*status.Result, error) {
+	observedGeneration, found, err := unstructured.NestedInt64(u.Object, "status", "observedGeneration")
+	if err != nil {
+		return nil, fmt.Errorf("%s/%s: invalid observedGeneration: %w", u.GetKind(), u.GetName(), err)
+	}
+	if !found || observedGeneration < u.GetGeneration() {
+		return &status.Result{
+			Status:  status.InProgressStatus,
+			Message: fmt.Sprintf("%s generation %d not yet observed", u.GetKind(), u.GetGeneration()),
+		}, nil
+	}
+
+	conditions, err := getConditions(u)
+	if err != nil {
+		return nil, fmt.Errorf("%s/%s: invalid conditions: %w", u.GetKind(), u.GetName(), err)
+	}
+
+	if c, ok := conditions[fluxmeta.StalledCondition]; ok && c.status == "True" {
+		return &status.Result{
+			Status:  status.FailedStatus,
+			Message: c.message,
+			Conditions: []status.Condition{
+				{Type: status.ConditionStalled, Status: "True", Reason: c.reason, Message: c.message},
+			},
+		}, nil
+	}
+
+	if c, ok := conditions[fluxmeta.ReconcilingCondition]; ok && c.status == "True" {
+		return &status.Result{
+			Status:  status.InProgressStatus,
+			Message: c.message,
+			Conditions: []status.Condition{
+				{Type: status.ConditionReconciling, Status: "True", Reason: c.reason, Message: c.message},
+			},
+		}, nil
+	}
+
+	ready, ok := conditions[fluxmeta.ReadyCondition]
+	switch {
+	case ok && ready.status == "True":
+		return &status.Result{
+			Status:  status.CurrentStatus,
+			Message: ready.message,
+		}, nil
+	case ok && ready.status == "False":
+		return &status.Result{
+			Status:  status.FailedStatus,
+			Message: ready.message,
+			Conditions: []status.Condition{
+				{Type: status.ConditionStalled, Status: "True", Reason: ready.reason, Message: ready.message},
+			},
+		}, nil
+	default:
+		return &status.Result{
+			Status:  status.InProgressStatus,
+			Message: fmt.Sprintf("%s condition not yet reported as True", fluxmeta.ReadyCondition),
+		}, nil
+	}
+}
+
+type condition struct {
+	status  string
+	reason  string
+	message string
+}
+
+// getConditions returns the conditions of the given object indexed by type.
+func getConditions(u *unstructured.Unstructured) (map[string]condition, error) {
+	items, _, err := unstructured.NestedSlice(u.Object, "status", "conditions")
+	if err != nil {
+		return nil, err
+	}
+
+	conditions := make(map[string]condition, len(items))
+	for _, item := range items {
+		c, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		t, _, _ := unstructured.NestedString(c, "type")
+		s, _, _ := unstructured.NestedString(c, "status")
+		r, _, _ := unstructured.NestedString(c, "reason")
+		m, _, _ := unstructured.NestedString(c, "message")
+		conditions[t] = condition{status: s, reason: r, message: m}
+	}
+	return conditions, nil
+}
@@ -0,0 +1,133 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package statusreaders
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/fluxcd/cli-utils/pkg/kstatus/status"
+)
+
+func TestCompute(t *testing.T) {
+	tests := []struct {
+		name               string
+		generation         int64
+		observedGeneration int64
+		conditions         []interface{}
+		want               status.Status
+	}{
+		{
+			name:               "unobserved generation",
+			generation:         2,
+			observedGeneration: 1,
+			conditions:         []interface{}{condition("Ready", "True")},
+			want:               status.InProgressStatus,
+		},
+		{
+			name:               "ready",
+			generation:         1,
+			observedGeneration: 1,
+			conditions:         []interface{}{condition("Ready", "True")},
+			want:               status.CurrentStatus,
+		},
+		{
+			name:               "reconciling",
+			generation:         1,
+			observedGeneration: 1,
+			conditions:         []interface{}{condition("Ready", "False"), condition("Reconciling", "True")},
+			want:               status.InProgressStatus,
+		},
+		{
+			name:               "stalled",
+			generation:         1,
+			observedGeneration: 1,
+			conditions:         []interface{}{condition("Ready", "False"), condition("Stalled", "True")},
+			want:               status.FailedStatus,
+		},
+		{
+			name:               "failed",
+			generation:         1,
+			observedGeneration: 1,
+			conditions:         []interface{}{condition("Ready", "False")},
+			want:               status.FailedStatus,
+		},
+		{
+			name:               "unknown",
+			generation:         1,
+			observedGeneration: 1,
+			conditions:         []interface{}{condition("Ready", "Unknown")},
+			want:               status.InProgressStatus,
+		},
+		{
+			name:               "no conditions",
+			generation:         1,
+			observedGeneration: 1,
+			want:               status.InProgressStatus,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			u := &unstructured.Unstructured{Object: map[string]interface{}{
+				"apiVersion": "kustomize.toolkit.fluxcd.io/v1",
+				"kind":       "Kustomization",
+				"metadata": map[string]interface{}{
+					"name":       "test",
+					"namespace":  "default",
+					"generation": tt.generation,
+				},
+				"status": map[string]interface{}{
+					"observedGeneration": tt.observedGeneration,
+					"conditions":         tt.conditions,
+				},
+			}}
+
+			result, err := Compute(u)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(result.Status).To(Equal(tt.want))
+		})
+	}
+}
+
+func TestStatusReader_Supports(t *testing.T) {
+	g := NewWithT(t)
+
+	r := NewStatusReader(nil)
+	for _, gk := range FluxGroupKinds() {
+		g.Expect(r.Supports(gk)).To(BeTrue())
+	}
+	g.Expect(r.Supports(schema.GroupKind{Group: "apps", Kind: "Deployment"})).To(BeFalse())
+
+	r = NewStatusReader(nil, HelmReleaseGroupKind)
+	g.Expect(r.Supports(HelmReleaseGroupKind)).To(BeTrue())
+	g.Expect(r.Supports(KustomizationGroupKind)).To(BeFalse())
+}
+
+func condition(t, s string) interface{} {
+	return map[string]interface{}{
+		"type":    t,
+		"status":  s,
+		"reason":  "Test",
+		"message": "test",
+	}
+}
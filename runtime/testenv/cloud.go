@@ -0,0 +1,383 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package testenv
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CloudCredentials are the credentials served by the fake cloud metadata
+// and token servers.
+type CloudCredentials struct {
+	// AccessKeyID is the AWS access key ID.
+	AccessKeyID string
+	// SecretAccessKey is the AWS secret access key.
+	SecretAccessKey string
+	// Token is the AWS session token, or the OAuth2 access token for
+	// GCP and Azure.
+	Token string
+	// Expiration is the expiration time of the credentials.
+	Expiration time.Time
+}
+
+// DefaultCloudCredentials returns fake credentials expiring in one hour.
+func DefaultCloudCredentials() CloudCredentials {
+	return CloudCredentials{
+		AccessKeyID:     "AKIAFAKEACCESSKEYID",
+		SecretAccessKey: "fake-secret-access-key",
+		Token:           "fake-token",
+		Expiration:      time.Now().Add(time.Hour).UTC().Truncate(time.Second),
+	}
+}
+
+// fakeServer records the requests served by a fake cloud server.
+type fakeServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	requests []string
+}
+
+func (s *fakeServer) record(r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, r.Method+" "+r.URL.Path)
+}
+
+// Requests returns the method and path of the requests served so far.
+func (s *fakeServer) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.requests...)
+}
+
+// AWSMetadataServer is a fake AWS EC2 instance metadata service (IMDSv2),
+// serving the credentials of an IAM role.
+type AWSMetadataServer struct {
+	fakeServer
+
+	// Role is the name of the IAM role attached to the instance.
+	Role string
+	// Region is the region of the instance.
+	Region string
+	// AccountID is the AWS account ID of the instance.
+	AccountID string
+	// Credentials are the credentials of the IAM role.
+	Credentials CloudCredentials
+}
+
+// NewAWSMetadataServer starts an AWSMetadataServer, which must be closed
+// when the test finishes.
+func NewAWSMetadataServer() *AWSMetadataServer {
+	s := &AWSMetadataServer{
+		Role:        "fake-role",
+		Region:      "us-east-1",
+		AccountID:   "012345678901",
+		Credentials: DefaultCloudCredentials(),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// Env returns the environment variables pointing the AWS SDKs to the server.
+func (s *AWSMetadataServer) Env() map[string]string {
+	return map[string]string{
+		"AWS_EC2_METADATA_SERVICE_ENDPOINT": s.URL,
+		"AWS_EC2_METADATA_DISABLED":         "false",
+	}
+}
+
+const awsMetadataToken = "fake-imds-token"
+
+func (s *AWSMetadataServer) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	s.record(r)
+
+	if r.Method == http.MethodPut && r.URL.Path == "/latest/api/token" {
+		if r.Header.Get("X-aws-ec2-metadata-token-ttl-seconds") == "" {
+			http.Error(w, "missing token TTL", http.StatusBadRequest)
+			return
+		}
+		fmt.Fprint(w, awsMetadataToken)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if r.Header.Get("X-aws-ec2-metadata-token") != awsMetadataToken {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	credentialsPath := "/latest/meta-data/iam/security-credentials/"
+	switch r.URL.Path {
+	case credentialsPath:
+		fmt.Fprint(w, s.Role)
+	case credentialsPath + s.Role:
+		writeJSON(w, map[string]string{
+			"Code":            "Success",
+			"Type":            "AWS-HMAC",
+			"AccessKeyId":     s.Credentials.AccessKeyID,
+			"SecretAccessKey": s.Credentials.SecretAccessKey,
+			"Token":           s.Credentials.Token,
+			"Expiration":      s.Credentials.Expiration.Format(time.RFC3339),
+			"LastUpdated":     time.Now().UTC().Format(time.RFC3339),
+		})
+	case "/latest/meta-data/placement/region":
+		fmt.Fprint(w, s.Region)
+	case "/latest/dynamic/instance-identity/document":
+		writeJSON(w, map[string]string{
+			"region":    s.Region,
+			"accountId": s.AccountID,
+		})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// AWSSTSServer is a fake AWS Security Token Service, serving the
+// credentials of the AssumeRole and AssumeRoleWithWebIdentity actions.
+type AWSSTSServer struct {
+	fakeServer
+
+	// Credentials are the credentials of the assumed roles.
+	Credentials CloudCredentials
+}
+
+// NewAWSSTSServer starts an AWSSTSServer, which must be closed when the
+// test finishes.
+func NewAWSSTSServer() *AWSSTSServer {
+	s := &AWSSTSServer{
+		Credentials: DefaultCloudCredentials(),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// Env returns the environment variables pointing the AWS SDKs to the server.
+func (s *AWSSTSServer) Env() map[string]string {
+	return map[string]string{
+		"AWS_ENDPOINT_URL_STS": s.URL,
+	}
+}
+
+type stsResponse struct {
+	XMLName   xml.Name
+	Result    stsResult
+	RequestID string `xml:"ResponseMetadata>RequestId"`
+}
+
+type stsResult struct {
+	XMLName         xml.Name
+	Credentials     stsCredentials     `xml:"Credentials"`
+	AssumedRoleUser stsAssumedRoleUser `xml:"AssumedRoleUser"`
+}
+
+type stsCredentials struct {
+	AccessKeyID     string `xml:"AccessKeyId"`
+	SecretAccessKey string `xml:"SecretAccessKey"`
+	SessionToken    string `xml:"SessionToken"`
+	Expiration      string `xml:"Expiration"`
+}
+
+type stsAssumedRoleUser struct {
+	Arn           string `xml:"Arn"`
+	AssumedRoleID string `xml:"AssumedRoleId"`
+}
+
+func (s *AWSSTSServer) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	s.record(r)
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	action := r.Form.Get("Action")
+	switch action {
+	case "AssumeRole":
+	case "AssumeRoleWithWebIdentity":
+		if r.Form.Get("WebIdentityToken") == "" {
+			http.Error(w, "missing web identity token", http.StatusBadRequest)
+			return
+		}
+	default:
+		http.Error(w, fmt.Sprintf("unsupported action '%s'", action), http.StatusBadRequest)
+		return
+	}
+
+	sessionName := r.Form.Get("RoleSessionName")
+	data, err := xml.Marshal(stsResponse{
+		XMLName: xml.Name{Space: "https://sts.amazonaws.com/doc/2011-06-15/", Local: action + "Response"},
+		Result: stsResult{
+			XMLName: xml.Name{Local: action + "Result"},
+			Credentials: stsCredentials{
+				AccessKeyID:     s.Credentials.AccessKeyID,
+				SecretAccessKey: s.Credentials.SecretAccessKey,
+				SessionToken:    s.Credentials.Token,
+				Expiration:      s.Credentials.Expiration.Format(time.RFC3339),
+			},
+			AssumedRoleUser: stsAssumedRoleUser{
+				Arn:           r.Form.Get("RoleArn") + "/" + sessionName,
+				AssumedRoleID: "AROAFAKEROLEID:" + sessionName,
+			},
+		},
+		RequestID: "fake-request-id",
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/xml")
+	w.Write(data)
+}
+
+// GCPMetadataServer is a fake GCE metadata server, serving the access
+// token of the default service account.
+type GCPMetadataServer struct {
+	fakeServer
+
+	// ProjectID is the ID of the project of the instance.
+	ProjectID string
+	// Email is the email of the default service account.
+	Email string
+	// Credentials are the credentials of the default service account,
+	// only the Token and Expiration are used.
+	Credentials CloudCredentials
+}
+
+// NewGCPMetadataServer starts a GCPMetadataServer, which must be closed
+// when the test finishes.
+func NewGCPMetadataServer() *GCPMetadataServer {
+	s := &GCPMetadataServer{
+		ProjectID:   "fake-project",
+		Email:       "fake@fake-project.iam.gserviceaccount.com",
+		Credentials: DefaultCloudCredentials(),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// Env returns the environment variables pointing the GCP SDKs to the server.
+func (s *GCPMetadataServer) Env() map[string]string {
+	return map[string]string{
+		"GCE_METADATA_HOST": strings.TrimPrefix(s.URL, "http://"),
+	}
+}
+
+func (s *GCPMetadataServer) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	s.record(r)
+
+	if r.Header.Get("Metadata-Flavor") != "Google" {
+		http.Error(w, "missing Metadata-Flavor header", http.StatusForbidden)
+		return
+	}
+	w.Header().Set("Metadata-Flavor", "Google")
+
+	switch r.URL.Path {
+	case "/computeMetadata/v1/instance/service-accounts/default/token":
+		writeJSON(w, map[string]interface{}{
+			"access_token": s.Credentials.Token,
+			"expires_in":   int(time.Until(s.Credentials.Expiration).Seconds()),
+			"token_type":   "Bearer",
+		})
+	case "/computeMetadata/v1/instance/service-accounts/default/email":
+		fmt.Fprint(w, s.Email)
+	case "/computeMetadata/v1/project/project-id":
+		fmt.Fprint(w, s.ProjectID)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// AzureMetadataServer is a fake Azure instance metadata service (IMDS),
+// serving the access tokens of a managed identity.
+type AzureMetadataServer struct {
+	fakeServer
+
+	// ClientID is the client ID of the managed identity. If set, the token
+	// requests specifying another client ID are rejected.
+	ClientID string
+	// Credentials are the credentials of the managed identity, only the
+	// Token and Expiration are used.
+	Credentials CloudCredentials
+}
+
+// NewAzureMetadataServer starts an AzureMetadataServer, which must be
+// closed when the test finishes.
+func NewAzureMetadataServer() *AzureMetadataServer {
+	s := &AzureMetadataServer{
+		Credentials: DefaultCloudCredentials(),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// Env returns the environment variables pointing the Azure SDKs to the server.
+func (s *AzureMetadataServer) Env() map[string]string {
+	return map[string]string{
+		"AZURE_POD_IDENTITY_AUTHORITY_HOST": s.URL,
+	}
+}
+
+func (s *AzureMetadataServer) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	s.record(r)
+
+	if r.Header.Get("Metadata") != "true" {
+		http.Error(w, "missing Metadata header", http.StatusBadRequest)
+		return
+	}
+	if r.URL.Path != "/metadata/identity/oauth2/token" {
+		http.NotFound(w, r)
+		return
+	}
+
+	query := r.URL.Query()
+	resource := query.Get("resource")
+	if query.Get("api-version") == "" || resource == "" {
+		http.Error(w, "missing api-version or resource", http.StatusBadRequest)
+		return
+	}
+	if clientID := query.Get("client_id"); s.ClientID != "" && clientID != "" && clientID != s.ClientID {
+		http.Error(w, "identity not found", http.StatusBadRequest)
+		return
+	}
+
+	writeJSON(w, map[string]string{
+		"access_token": s.Credentials.Token,
+		"expires_in":   strconv.Itoa(int(time.Until(s.Credentials.Expiration).Seconds())),
+		"expires_on":   strconv.FormatInt(s.Credentials.Expiration.Unix(), 10),
+		"resource":     resource,
+		"token_type":   "Bearer",
+		"client_id":    s.ClientID,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
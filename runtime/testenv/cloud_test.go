@@ -0,0 +1,149 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package testenv
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestAWSMetadataServer(t *testing.T) {
+	g := NewWithT(t)
+
+	s := NewAWSMetadataServer()
+	defer s.Close()
+
+	req, err := http.NewRequest(http.MethodPut, s.URL+"/latest/api/token", nil)
+	g.Expect(err).ToNot(HaveOccurred())
+	req.Header.Set("X-aws-ec2-metadata-token-ttl-seconds", "21600")
+	token := doRequest(g, req, http.StatusOK)
+
+	req, err = http.NewRequest(http.MethodGet, s.URL+"/latest/meta-data/iam/security-credentials/", nil)
+	g.Expect(err).ToNot(HaveOccurred())
+	doRequest(g, req, http.StatusUnauthorized)
+
+	req.Header.Set("X-aws-ec2-metadata-token", token)
+	g.Expect(doRequest(g, req, http.StatusOK)).To(Equal(s.Role))
+
+	req, err = http.NewRequest(http.MethodGet, s.URL+"/latest/meta-data/iam/security-credentials/"+s.Role, nil)
+	g.Expect(err).ToNot(HaveOccurred())
+	req.Header.Set("X-aws-ec2-metadata-token", token)
+	var creds map[string]string
+	g.Expect(json.Unmarshal([]byte(doRequest(g, req, http.StatusOK)), &creds)).To(Succeed())
+	g.Expect(creds["AccessKeyId"]).To(Equal(s.Credentials.AccessKeyID))
+	g.Expect(creds["Token"]).To(Equal(s.Credentials.Token))
+
+	g.Expect(s.Requests()).To(HaveLen(4))
+	g.Expect(s.Env()).To(HaveKeyWithValue("AWS_EC2_METADATA_SERVICE_ENDPOINT", s.URL))
+}
+
+func TestAWSSTSServer(t *testing.T) {
+	g := NewWithT(t)
+
+	s := NewAWSSTSServer()
+	defer s.Close()
+
+	form := url.Values{
+		"Action":           {"AssumeRoleWithWebIdentity"},
+		"RoleArn":          {"arn:aws:iam::012345678901:role/fake"},
+		"RoleSessionName":  {"session"},
+		"WebIdentityToken": {"jwt"},
+	}
+	req, err := http.NewRequest(http.MethodPost, s.URL, strings.NewReader(form.Encode()))
+	g.Expect(err).ToNot(HaveOccurred())
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	var resp struct {
+		XMLName xml.Name
+		Result  struct {
+			Credentials struct {
+				AccessKeyID  string `xml:"AccessKeyId"`
+				SessionToken string `xml:"SessionToken"`
+			} `xml:"Credentials"`
+		} `xml:"AssumeRoleWithWebIdentityResult"`
+	}
+	g.Expect(xml.Unmarshal([]byte(doRequest(g, req, http.StatusOK)), &resp)).To(Succeed())
+	g.Expect(resp.XMLName.Local).To(Equal("AssumeRoleWithWebIdentityResponse"))
+	g.Expect(resp.Result.Credentials.AccessKeyID).To(Equal(s.Credentials.AccessKeyID))
+	g.Expect(resp.Result.Credentials.SessionToken).To(Equal(s.Credentials.Token))
+
+	form.Del("WebIdentityToken")
+	req, err = http.NewRequest(http.MethodPost, s.URL, strings.NewReader(form.Encode()))
+	g.Expect(err).ToNot(HaveOccurred())
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	doRequest(g, req, http.StatusBadRequest)
+}
+
+func TestGCPMetadataServer(t *testing.T) {
+	g := NewWithT(t)
+
+	s := NewGCPMetadataServer()
+	defer s.Close()
+
+	req, err := http.NewRequest(http.MethodGet, s.URL+"/computeMetadata/v1/instance/service-accounts/default/token", nil)
+	g.Expect(err).ToNot(HaveOccurred())
+	doRequest(g, req, http.StatusForbidden)
+
+	req.Header.Set("Metadata-Flavor", "Google")
+	var token map[string]interface{}
+	g.Expect(json.Unmarshal([]byte(doRequest(g, req, http.StatusOK)), &token)).To(Succeed())
+	g.Expect(token["access_token"]).To(Equal(s.Credentials.Token))
+	g.Expect(token["expires_in"]).To(BeNumerically(">", 0))
+
+	g.Expect(s.Env()).To(HaveKeyWithValue("GCE_METADATA_HOST", strings.TrimPrefix(s.URL, "http://")))
+}
+
+func TestAzureMetadataServer(t *testing.T) {
+	g := NewWithT(t)
+
+	s := NewAzureMetadataServer()
+	s.ClientID = "client-id"
+	defer s.Close()
+
+	tokenURL := s.URL + "/metadata/identity/oauth2/token?api-version=2018-02-01&resource=https://management.azure.com/"
+	req, err := http.NewRequest(http.MethodGet, tokenURL, nil)
+	g.Expect(err).ToNot(HaveOccurred())
+	doRequest(g, req, http.StatusBadRequest)
+
+	req.Header.Set("Metadata", "true")
+	var token map[string]string
+	g.Expect(json.Unmarshal([]byte(doRequest(g, req, http.StatusOK)), &token)).To(Succeed())
+	g.Expect(token["access_token"]).To(Equal(s.Credentials.Token))
+	g.Expect(token["resource"]).To(Equal("https://management.azure.com/"))
+
+	req, err = http.NewRequest(http.MethodGet, tokenURL+"&client_id=other", nil)
+	g.Expect(err).ToNot(HaveOccurred())
+	req.Header.Set("Metadata", "true")
+	doRequest(g, req, http.StatusBadRequest)
+}
+
+func doRequest(g *WithT, req *http.Request, status int) string {
+	resp, err := http.DefaultClient.Do(req)
+	g.Expect(err).ToNot(HaveOccurred())
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(resp.StatusCode).To(Equal(status), string(body))
+	return string(body)
+}
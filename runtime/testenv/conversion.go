@@ -0,0 +1,222 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package testenv
+
+import (
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/conversion"
+)
+
+const (
+	// conversionPath is the path at which the conversion webhook serves
+	// the kinds which are convertible in the scheme of the Environment.
+	conversionPath = "/convert"
+	// stubConversionPath is the path at which the conversion webhook
+	// serves all other kinds with the ConversionFunc.
+	stubConversionPath = "/convert-stub"
+)
+
+// ConversionFunc converts the given object in place to the desired API
+// version. The API version of the object is set to the desired version by
+// the caller after the function returns.
+type ConversionFunc func(obj *unstructured.Unstructured, desiredAPIVersion string) error
+
+// CRDVersion describes a version of a CustomResourceDefinition created with
+// MultiVersionCRD.
+type CRDVersion struct {
+	// Name is the name of the version, e.g. 'v1beta1'.
+	Name string
+	// Storage marks the version as the storage version. Exactly one
+	// version of a CRD must be the storage version.
+	Storage bool
+	// Schema is the OpenAPI schema of the version. When nil, the schema
+	// preserves unknown fields.
+	Schema *apiextensionsv1.JSONSchemaProps
+}
+
+// MultiVersionCRD returns a namespaced CustomResourceDefinition for the
+// given group and kind, which serves all the given versions.
+func MultiVersionCRD(group, kind string, versions ...CRDVersion) *apiextensionsv1.CustomResourceDefinition {
+	singular := strings.ToLower(kind)
+	plural := singular + "s"
+
+	crd := &apiextensionsv1.CustomResourceDefinition{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: apiextensionsv1.SchemeGroupVersion.String(),
+			Kind:       "CustomResourceDefinition",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name: plural + "." + group,
+		},
+		Spec: apiextensionsv1.CustomResourceDefinitionSpec{
+			Group: group,
+			Names: apiextensionsv1.CustomResourceDefinitionNames{
+				Kind:     kind,
+				ListKind: kind + "List",
+				Plural:   plural,
+				Singular: singular,
+			},
+			Scope: apiextensionsv1.NamespaceScoped,
+		},
+	}
+
+	preserveUnknownFields := true
+	for _, v := range versions {
+		s := v.Schema
+		if s == nil {
+			s = &apiextensionsv1.JSONSchemaProps{
+				Type:                   "object",
+				XPreserveUnknownFields: &preserveUnknownFields,
+			}
+		}
+		crd.Spec.Versions = append(crd.Spec.Versions, apiextensionsv1.CustomResourceDefinitionVersion{
+			Name:    v.Name,
+			Served:  true,
+			Storage: v.Storage,
+			Schema:  &apiextensionsv1.CustomResourceValidation{OpenAPIV3Schema: s},
+		})
+	}
+	return crd
+}
+
+// passthroughConversion is the ConversionFunc used when none is configured,
+// which only changes the API version of the objects.
+func passthroughConversion(*unstructured.Unstructured, string) error {
+	return nil
+}
+
+// newConversionServer starts a TLS server which serves the conversion
+// webhook for the kinds which are convertible in the given scheme, and the
+// stub conversion webhook using fn for all other kinds.
+func newConversionServer(scheme *runtime.Scheme, fn ConversionFunc) *httptest.Server {
+	if fn == nil {
+		fn = passthroughConversion
+	}
+	mux := http.NewServeMux()
+	mux.Handle(conversionPath, conversion.NewWebhookHandler(scheme))
+	mux.Handle(stubConversionPath, stubConversionHandler(fn))
+	return httptest.NewTLSServer(mux)
+}
+
+// withConversionWebhook configures the CRDs with more than one version to
+// be converted by the conversion webhook served by the given server.
+func withConversionWebhook(crds []*apiextensionsv1.CustomResourceDefinition, scheme *runtime.Scheme, srv *httptest.Server) error {
+	caBundle := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	for _, crd := range crds {
+		if len(crd.Spec.Versions) < 2 {
+			continue
+		}
+
+		path := stubConversionPath
+		convertible, err := isConvertible(scheme, crd)
+		if err != nil {
+			return err
+		}
+		if convertible {
+			path = conversionPath
+		}
+
+		url := srv.URL + path
+		crd.Spec.Conversion = &apiextensionsv1.CustomResourceConversion{
+			Strategy: apiextensionsv1.WebhookConverter,
+			Webhook: &apiextensionsv1.WebhookConversion{
+				ClientConfig: &apiextensionsv1.WebhookClientConfig{
+					URL:      &url,
+					CABundle: caBundle,
+				},
+				ConversionReviewVersions: []string{"v1"},
+			},
+		}
+	}
+	return nil
+}
+
+// isConvertible returns true if the kind of the CRD is registered in the
+// scheme with a Hub and Convertible types.
+func isConvertible(scheme *runtime.Scheme, crd *apiextensionsv1.CustomResourceDefinition) (bool, error) {
+	for _, v := range crd.Spec.Versions {
+		gvk := schema.GroupVersionKind{Group: crd.Spec.Group, Version: v.Name, Kind: crd.Spec.Names.Kind}
+		if !scheme.Recognizes(gvk) {
+			continue
+		}
+		obj, err := scheme.New(gvk)
+		if err != nil {
+			return false, err
+		}
+		ok, err := conversion.IsConvertible(scheme, obj)
+		if err != nil {
+			return false, fmt.Errorf("failed to check convertibility of %s: %w", gvk, err)
+		}
+		return ok, nil
+	}
+	return false, nil
+}
+
+// stubConversionHandler returns an http.Handler which serves v1
+// ConversionReview requests by converting the objects with fn, and setting
+// their API version to the desired version.
+func stubConversionHandler(fn ConversionFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		review := &apiextensionsv1.ConversionReview{}
+		if err := json.NewDecoder(r.Body).Decode(review); err != nil || review.Request == nil {
+			http.Error(w, "invalid conversion review", http.StatusBadRequest)
+			return
+		}
+
+		resp := &apiextensionsv1.ConversionResponse{
+			UID:    review.Request.UID,
+			Result: metav1.Status{Status: metav1.StatusSuccess},
+		}
+		for _, raw := range review.Request.Objects {
+			converted, err := convertObject(raw.Raw, review.Request.DesiredAPIVersion, fn)
+			if err != nil {
+				resp.ConvertedObjects = nil
+				resp.Result = metav1.Status{Status: metav1.StatusFailure, Message: err.Error()}
+				break
+			}
+			resp.ConvertedObjects = append(resp.ConvertedObjects, runtime.RawExtension{Raw: converted})
+		}
+
+		review.Request = nil
+		review.Response = resp
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(review)
+	})
+}
+
+func convertObject(data []byte, desiredAPIVersion string, fn ConversionFunc) ([]byte, error) {
+	obj := &unstructured.Unstructured{}
+	if err := obj.UnmarshalJSON(data); err != nil {
+		return nil, fmt.Errorf("failed to decode object: %w", err)
+	}
+	if err := fn(obj, desiredAPIVersion); err != nil {
+		return nil, fmt.Errorf("failed to convert %s '%s' to %s: %w", obj.GetKind(), obj.GetName(), desiredAPIVersion, err)
+	}
+	obj.SetAPIVersion(desiredAPIVersion)
+	return obj.MarshalJSON()
+}
@@ -0,0 +1,88 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package testenv
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestMultiVersionCRD(t *testing.T) {
+	g := NewWithT(t)
+
+	crd := MultiVersionCRD("example.fluxcd.io", "Widget",
+		CRDVersion{Name: "v1beta1"},
+		CRDVersion{Name: "v1", Storage: true},
+	)
+	g.Expect(crd.Name).To(Equal("widgets.example.fluxcd.io"))
+	g.Expect(crd.Spec.Versions).To(HaveLen(2))
+	g.Expect(crd.Spec.Versions[1].Storage).To(BeTrue())
+	g.Expect(*crd.Spec.Versions[0].Schema.OpenAPIV3Schema.XPreserveUnknownFields).To(BeTrue())
+
+	srv := newConversionServer(runtime.NewScheme(), nil)
+	defer srv.Close()
+	g.Expect(withConversionWebhook([]*apiextensionsv1.CustomResourceDefinition{crd}, runtime.NewScheme(), srv)).To(Succeed())
+	g.Expect(crd.Spec.Conversion.Strategy).To(Equal(apiextensionsv1.WebhookConverter))
+	g.Expect(*crd.Spec.Conversion.Webhook.ClientConfig.URL).To(Equal(srv.URL + stubConversionPath))
+	g.Expect(crd.Spec.Conversion.Webhook.ClientConfig.CABundle).To(ContainSubstring("BEGIN CERTIFICATE"))
+}
+
+func Test_stubConversionHandler(t *testing.T) {
+	g := NewWithT(t)
+
+	handler := stubConversionHandler(func(obj *unstructured.Unstructured, desiredAPIVersion string) error {
+		return unstructured.SetNestedField(obj.Object, desiredAPIVersion, "spec", "convertedTo")
+	})
+
+	obj := []byte(`{"apiVersion":"example.fluxcd.io/v1beta1","kind":"Widget","metadata":{"name":"test"}}`)
+	review := apiextensionsv1.ConversionReview{
+		TypeMeta: metav1.TypeMeta{APIVersion: "apiextensions.k8s.io/v1", Kind: "ConversionReview"},
+		Request: &apiextensionsv1.ConversionRequest{
+			UID:               types.UID("uid"),
+			DesiredAPIVersion: "example.fluxcd.io/v1",
+			Objects:           []runtime.RawExtension{{Raw: obj}},
+		},
+	}
+	body, err := json.Marshal(review)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, stubConversionPath, bytes.NewReader(body)))
+	g.Expect(rec.Code).To(Equal(http.StatusOK))
+
+	var got apiextensionsv1.ConversionReview
+	g.Expect(json.Unmarshal(rec.Body.Bytes(), &got)).To(Succeed())
+	g.Expect(got.Response).ToNot(BeNil())
+	g.Expect(got.Response.UID).To(Equal(types.UID("uid")))
+	g.Expect(got.Response.Result.Status).To(Equal(metav1.StatusSuccess))
+	g.Expect(got.Response.ConvertedObjects).To(HaveLen(1))
+
+	converted := &unstructured.Unstructured{}
+	g.Expect(converted.UnmarshalJSON(got.Response.ConvertedObjects[0].Raw)).To(Succeed())
+	g.Expect(converted.GetAPIVersion()).To(Equal("example.fluxcd.io/v1"))
+	g.Expect(converted.Object["spec"]).To(HaveKeyWithValue("convertedTo", "example.fluxcd.io/v1"))
+}
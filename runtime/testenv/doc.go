@@ -20,4 +20,7 @@ limitations under the License.
 //
 // For more information about the encapsulated local Kubernetes test environment, see:
 // https://book.kubebuilder.io/reference/envtest.html
+//
+// The package also provides fake cloud metadata and token servers (AWS IMDS and STS, GCE metadata
+// and Azure IMDS) to test the authentication flows of cloud providers without real cloud accounts.
 package testenv
@@ -0,0 +1,273 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package testenv
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	kerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var (
+	restoreBackoff = wait.Backoff{
+		Duration: 100 * time.Millisecond,
+		Factor:   1.5,
+		Steps:    10,
+		Jitter:   0.4,
+	}
+
+	// restoreConcurrency is the maximum number of objects Restore waits
+	// for concurrently.
+	restoreConcurrency = 10
+
+	// snapshotIgnoredResources are the resources which are not restored, as
+	// they are managed by the API server itself.
+	snapshotIgnoredResources = sets.New[schema.GroupResource](
+		schema.GroupResource{Resource: "events"},
+		schema.GroupResource{Group: "events.k8s.io", Resource: "events"},
+		schema.GroupResource{Group: "coordination.k8s.io", Resource: "leases"},
+		schema.GroupResource{Resource: "componentstatuses"},
+	)
+)
+
+// Snapshot records the objects which exist in an Environment at a point in
+// time, to delete the objects created afterwards with Restore.
+type Snapshot struct {
+	client     client.Client
+	namespaces sets.Set[string]
+	gvks       []snapshotGVK
+	uids       sets.Set[types.UID]
+}
+
+type snapshotGVK struct {
+	schema.GroupVersionKind
+	namespaced bool
+}
+
+// Snapshot records the objects which exist in the Environment. When
+// namespaces are given, only the objects in these namespaces are recorded
+// and restored, otherwise all objects, including cluster-scoped objects
+// and namespaces, are.
+//
+// A typical use is to take a snapshot after the shared setup of a test
+// suite, and to restore it at the end of every test case, instead of
+// starting a new Environment per test case:
+//
+//	snapshot, err := testEnv.Snapshot(ctx)
+//	...
+//	t.Cleanup(func() {
+//	    if err := snapshot.Restore(ctx); err != nil {
+//	        t.Error(err)
+//	    }
+//	})
+func (e *Environment) Snapshot(ctx context.Context, namespaces ...string) (*Snapshot, error) {
+	// Use a client which reads from the API server, as objects which are
+	// not yet in the cache would otherwise not be recorded.
+	c, err := client.New(e.Config, client.Options{Scheme: e.GetScheme(), Mapper: e.GetRESTMapper()})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create snapshot client")
+	}
+	gvks, err := snapshotGVKs(e.Config)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Snapshot{
+		client:     c,
+		namespaces: sets.New[string](namespaces...),
+		gvks:       gvks,
+	}
+	objs, err := s.list(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s.uids = sets.New[types.UID]()
+	for _, obj := range objs {
+		s.uids.Insert(obj.GetUID())
+	}
+	return s, nil
+}
+
+// Restore deletes all the objects which were created after the snapshot
+// was taken, and waits for them to be removed concurrently. Objects which
+// are not removed in time, because of finalizers which are not handled,
+// are stripped of their finalizers.
+// Namespaces are deleted, but their removal is not waited for, as the
+// namespace controller does not run in the Environment.
+func (s *Snapshot) Restore(ctx context.Context) error {
+	objs, err := s.list(ctx)
+	if err != nil {
+		return err
+	}
+
+	var created, namespaces []*metav1.PartialObjectMetadata
+	for _, obj := range objs {
+		if s.uids.Has(obj.GetUID()) {
+			continue
+		}
+		if obj.GroupVersionKind().GroupKind() == corev1.SchemeGroupVersion.WithKind("Namespace").GroupKind() {
+			namespaces = append(namespaces, obj)
+			continue
+		}
+		created = append(created, obj)
+	}
+
+	var errs []error
+	for _, obj := range created {
+		if err := s.client.Delete(ctx, obj, client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil && !apierrors.IsNotFound(err) {
+			errs = append(errs, errors.Wrapf(err, "failed to delete %s %s", obj.GroupVersionKind().Kind, client.ObjectKeyFromObject(obj)))
+		}
+	}
+	if len(errs) > 0 {
+		return kerrors.NewAggregate(errs)
+	}
+
+	var (
+		wg  sync.WaitGroup
+		mu  sync.Mutex
+		sem = make(chan struct{}, restoreConcurrency)
+	)
+	for _, obj := range created {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(obj *metav1.PartialObjectMetadata) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			if err := s.waitForDeletion(ctx, obj); err != nil {
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+			}
+		}(obj)
+	}
+	wg.Wait()
+	for _, ns := range namespaces {
+		if err := s.client.Delete(ctx, ns); err != nil && !apierrors.IsNotFound(err) {
+			errs = append(errs, errors.Wrapf(err, "failed to delete namespace %s", ns.GetName()))
+		}
+	}
+	return kerrors.NewAggregate(errs)
+}
+
+// waitForDeletion waits for the object to be removed, and removes its
+// finalizers if it is not removed in time.
+func (s *Snapshot) waitForDeletion(ctx context.Context, obj *metav1.PartialObjectMetadata) error {
+	key := client.ObjectKeyFromObject(obj)
+	gone := func() (bool, error) {
+		current := &metav1.PartialObjectMetadata{}
+		current.SetGroupVersionKind(obj.GroupVersionKind())
+		if err := s.client.Get(ctx, key, current); err != nil {
+			if apierrors.IsNotFound(err) {
+				return true, nil
+			}
+			return false, err
+		}
+		// The name may have been reused by an object created afterwards.
+		return current.GetUID() != obj.GetUID(), nil
+	}
+
+	err := wait.ExponentialBackoff(restoreBackoff, gone)
+	if err == nil || !wait.Interrupted(err) {
+		return errors.Wrapf(err, "failed to wait for deletion of %s %s", obj.GroupVersionKind().Kind, key)
+	}
+
+	patch := client.RawPatch(types.MergePatchType, []byte(`{"metadata":{"finalizers":null}}`))
+	if err := s.client.Patch(ctx, obj, patch); err != nil && !apierrors.IsNotFound(err) {
+		return errors.Wrapf(err, "failed to remove finalizers of %s %s", obj.GroupVersionKind().Kind, key)
+	}
+	return errors.Wrapf(wait.ExponentialBackoff(restoreBackoff, gone),
+		"%s %s is not being deleted", obj.GroupVersionKind().Kind, key)
+}
+
+// list returns the metadata of all the objects in scope of the snapshot.
+func (s *Snapshot) list(ctx context.Context) ([]*metav1.PartialObjectMetadata, error) {
+	var objs []*metav1.PartialObjectMetadata
+	for _, gvk := range s.gvks {
+		if !gvk.namespaced && s.namespaces.Len() > 0 {
+			continue
+		}
+
+		namespaces := []string{""}
+		if gvk.namespaced && s.namespaces.Len() > 0 {
+			namespaces = sets.List(s.namespaces)
+		}
+		for _, ns := range namespaces {
+			list := &metav1.PartialObjectMetadataList{}
+			list.SetGroupVersionKind(gvk.GroupVersion().WithKind(gvk.Kind + "List"))
+			if err := s.client.List(ctx, list, client.InNamespace(ns)); err != nil {
+				if apierrors.IsNotFound(err) || apierrors.IsMethodNotSupported(err) {
+					continue
+				}
+				return nil, errors.Wrapf(err, "failed to list %s", gvk.GroupVersionKind)
+			}
+			for i := range list.Items {
+				obj := &list.Items[i]
+				obj.SetGroupVersionKind(gvk.GroupVersionKind)
+				objs = append(objs, obj)
+			}
+		}
+	}
+	return objs, nil
+}
+
+// snapshotGVKs discovers the kinds served by the API server which can be
+// listed and deleted.
+func snapshotGVKs(cfg *rest.Config) ([]snapshotGVK, error) {
+	dc, err := discovery.NewDiscoveryClientForConfig(cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create discovery client")
+	}
+	resourceLists, err := dc.ServerPreferredResources()
+	if err != nil && len(resourceLists) == 0 {
+		return nil, errors.Wrap(err, "failed to discover API resources")
+	}
+
+	var gvks []snapshotGVK
+	for _, list := range resourceLists {
+		gv, err := schema.ParseGroupVersion(list.GroupVersion)
+		if err != nil {
+			continue
+		}
+		for _, r := range list.APIResources {
+			if strings.Contains(r.Name, "/") || snapshotIgnoredResources.Has(gv.WithResource(r.Name).GroupResource()) {
+				continue
+			}
+			verbs := sets.New[string](r.Verbs...)
+			if !verbs.HasAll("list", "delete") {
+				continue
+			}
+			gvks = append(gvks, snapshotGVK{GroupVersionKind: gv.WithKind(r.Kind), namespaced: r.Namespaced})
+		}
+	}
+	return gvks, nil
+}
@@ -0,0 +1,149 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package testenv
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestSnapshot_Restore(t *testing.T) {
+	g := NewWithT(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	testEnv := New()
+	go func() {
+		_ = testEnv.Start(ctx)
+	}()
+	<-testEnv.Manager.Elected()
+	defer func() {
+		g.Expect(testEnv.Stop()).To(Succeed())
+	}()
+
+	// Give up on finalizers quickly.
+	backoff := restoreBackoff
+	restoreBackoff = wait.Backoff{Duration: 10 * time.Millisecond, Factor: 1.5, Steps: 5}
+	defer func() {
+		restoreBackoff = backoff
+	}()
+
+	t.Run("namespaced", func(t *testing.T) {
+		g := NewWithT(t)
+
+		ns, err := testEnv.CreateNamespace(ctx, "snapshot")
+		g.Expect(err).ToNot(HaveOccurred())
+		existing := configMap(ns.Name, "existing")
+		g.Expect(testEnv.Client.Create(ctx, existing)).To(Succeed())
+
+		snapshot, err := testEnv.Snapshot(ctx, ns.Name)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(snapshot.uids.Has(existing.UID)).To(BeTrue())
+
+		created := configMap(ns.Name, "created")
+		g.Expect(testEnv.Client.Create(ctx, created)).To(Succeed())
+		finalized := configMap(ns.Name, "finalized")
+		finalized.Finalizers = []string{"testenv.fluxcd.io/finalizer"}
+		g.Expect(testEnv.Client.Create(ctx, finalized)).To(Succeed())
+		secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "created", Namespace: ns.Name}}
+		g.Expect(testEnv.Client.Create(ctx, secret)).To(Succeed())
+		deleted := configMap(ns.Name, "deleted")
+		g.Expect(testEnv.Client.Create(ctx, deleted)).To(Succeed())
+		g.Expect(testEnv.Client.Delete(ctx, deleted)).To(Succeed())
+
+		// Objects in other namespaces are not in scope of the snapshot.
+		other, err := testEnv.CreateNamespace(ctx, "other")
+		g.Expect(err).ToNot(HaveOccurred())
+		outOfScope := configMap(other.Name, "created")
+		g.Expect(testEnv.Client.Create(ctx, outOfScope)).To(Succeed())
+
+		g.Expect(snapshot.Restore(ctx)).To(Succeed())
+
+		g.Expect(snapshotUIDs(g, ctx, snapshot)).To(Equal(snapshot.uids))
+		for _, obj := range []client.Object{created, finalized, secret} {
+			err := snapshot.client.Get(ctx, client.ObjectKeyFromObject(obj), obj)
+			g.Expect(apierrors.IsNotFound(err)).To(BeTrue(), "%s should be deleted", obj.GetName())
+		}
+		g.Expect(snapshot.client.Get(ctx, client.ObjectKeyFromObject(existing), &corev1.ConfigMap{})).To(Succeed())
+		g.Expect(snapshot.client.Get(ctx, client.ObjectKeyFromObject(outOfScope), &corev1.ConfigMap{})).To(Succeed())
+	})
+
+	t.Run("cluster", func(t *testing.T) {
+		g := NewWithT(t)
+
+		snapshot, err := testEnv.Snapshot(ctx)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		ns, err := testEnv.CreateNamespace(ctx, "restore")
+		g.Expect(err).ToNot(HaveOccurred())
+		cm := configMap(ns.Name, "created")
+		cm.Finalizers = []string{"testenv.fluxcd.io/finalizer"}
+		g.Expect(testEnv.Client.Create(ctx, cm)).To(Succeed())
+		role := &rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "snapshot-restore"}}
+		g.Expect(testEnv.Client.Create(ctx, role)).To(Succeed())
+
+		g.Expect(snapshot.Restore(ctx)).To(Succeed())
+
+		err = snapshot.client.Get(ctx, client.ObjectKeyFromObject(role), role)
+		g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+		err = snapshot.client.Get(ctx, client.ObjectKeyFromObject(cm), cm)
+		g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+
+		// Namespaces are deleted without waiting for the namespace
+		// controller, which does not run in the Environment.
+		err = snapshot.client.Get(ctx, client.ObjectKeyFromObject(ns), ns)
+		if err == nil {
+			g.Expect(ns.DeletionTimestamp).ToNot(BeNil())
+		} else {
+			g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+		}
+
+		uids := snapshotUIDs(g, ctx, snapshot)
+		uids.Delete(ns.UID)
+		g.Expect(uids).To(Equal(snapshot.uids))
+	})
+}
+
+func configMap(namespace, name string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Data:       map[string]string{"key": "value"},
+	}
+}
+
+// snapshotUIDs returns the UIDs of the objects in scope of the snapshot.
+func snapshotUIDs(g *WithT, ctx context.Context, s *Snapshot) sets.Set[types.UID] {
+	objs, err := s.list(ctx)
+	g.Expect(err).ToNot(HaveOccurred())
+	uids := sets.New[types.UID]()
+	for _, obj := range objs {
+		uids.Insert(obj.GetUID())
+	}
+	return uids
+}
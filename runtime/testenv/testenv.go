@@ -26,11 +26,13 @@ package testenv
 import (
 	"context"
 	"fmt"
+	"net/http/httptest"
 	"sync"
 	"time"
 
 	"github.com/pkg/errors"
 	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -72,10 +74,11 @@ type Environment struct {
 	client.Client
 	Config *rest.Config
 
-	env           *envtest.Environment
-	startOnce     sync.Once
-	stopOnce      sync.Once
-	cancelManager context.CancelFunc
+	env              *envtest.Environment
+	conversionServer *httptest.Server
+	startOnce        sync.Once
+	stopOnce         sync.Once
+	cancelManager    context.CancelFunc
 }
 
 // options holds the configuration options for the Environment.
@@ -83,6 +86,9 @@ type options struct {
 	scheme                  *runtime.Scheme
 	crdDirectoryPaths       []string
 	maxConcurrentReconciles int
+	crds                    []*apiextensionsv1.CustomResourceDefinition
+	conversionWebhook       bool
+	conversionFunc          ConversionFunc
 }
 
 // withDefaults sets the default configuration for missing values.
@@ -113,6 +119,25 @@ func WithCRDPath(path ...string) Option {
 	}
 }
 
+// WithCRDs configures Custom Resource Definitions to install in the Environment, in addition to the ones found in the
+// configured CRD paths.
+func WithCRDs(crds ...*apiextensionsv1.CustomResourceDefinition) Option {
+	return func(o *options) {
+		o.crds = append(o.crds, crds...)
+	}
+}
+
+// WithConversionWebhook configures the Environment to serve a conversion webhook for the CRDs configured using WithCRDs
+// which have more than one version. Kinds which are convertible in the runtime.Scheme of the Environment are converted
+// using their Hub and Convertible implementations, all other kinds are converted with the given ConversionFunc.
+// When fn is nil, objects are converted by only changing their API version.
+func WithConversionWebhook(fn ConversionFunc) Option {
+	return func(o *options) {
+		o.conversionWebhook = true
+		o.conversionFunc = fn
+	}
+}
+
 // WithMaxConcurrentReconciles configures the maximum number of concurrent Reconciles which can be run.
 func WithMaxConcurrentReconciles(max int) Option {
 	return func(o *options) {
@@ -165,6 +190,28 @@ func New(o ...Option) *Environment {
 		panic(err)
 	}
 
+	var conversionServer *httptest.Server
+	if opts.conversionWebhook {
+		conversionServer = newConversionServer(opts.scheme, opts.conversionFunc)
+		if err := withConversionWebhook(opts.crds, opts.scheme, conversionServer); err != nil {
+			conversionServer.Close()
+			err = kerrors.NewAggregate([]error{err, env.Stop()})
+			panic(err)
+		}
+	}
+	// The CRDs are installed after starting the envtest.Environment, as it
+	// would otherwise reset the conversion of the kinds it does not consider
+	// convertible.
+	if len(opts.crds) > 0 {
+		if _, err := envtest.InstallCRDs(env.Config, envtest.CRDInstallOptions{CRDs: opts.crds}); err != nil {
+			if conversionServer != nil {
+				conversionServer.Close()
+			}
+			err = kerrors.NewAggregate([]error{err, env.Stop()})
+			panic(err)
+		}
+	}
+
 	mgr, err := ctrl.NewManager(env.Config, manager.Options{
 		Scheme: opts.scheme,
 		Metrics: metricsserver.Options{
@@ -179,10 +226,11 @@ func New(o ...Option) *Environment {
 	}
 
 	return &Environment{
-		Manager: mgr,
-		Client:  mgr.GetClient(),
-		Config:  mgr.GetConfig(),
-		env:     env,
+		Manager:          mgr,
+		Client:           mgr.GetClient(),
+		Config:           mgr.GetConfig(),
+		env:              env,
+		conversionServer: conversionServer,
 	}
 }
 
@@ -202,6 +250,9 @@ func (e *Environment) Stop() error {
 	err := errAlreadyStopped
 	e.stopOnce.Do(func() {
 		e.cancelManager()
+		if e.conversionServer != nil {
+			e.conversionServer.Close()
+		}
 		err = e.env.Stop()
 	})
 	return err
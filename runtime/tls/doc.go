@@ -14,5 +14,6 @@ See the License for the specific language governing permissions and
 limitations under the License.
 */
 
-// Package tls contains helpers to convert Kubernetes secrets to TLS certificates.
+// Package tls contains helpers to convert Kubernetes secrets to TLS certificates,
+// and to reload TLS certificates on rotation without restarting the controller.
 package tls
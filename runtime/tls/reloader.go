@@ -0,0 +1,367 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tls
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const defaultReloadInterval = 10 * time.Second
+
+// CertSource loads the PEM encoded certificate, key and CA certificate of a
+// TLS configuration. The certificate and key, or the CA certificate, may be
+// empty.
+type CertSource interface {
+	Load(ctx context.Context) (cert, key, ca []byte, err error)
+}
+
+// FileSource loads the TLS configuration from files, e.g. the files of a
+// Secret mounted in the controller pod.
+type FileSource struct {
+	CertFile string
+	KeyFile  string
+	CAFile   string
+}
+
+// Load implements CertSource.
+func (s FileSource) Load(_ context.Context) (cert, key, ca []byte, err error) {
+	read := func(path string) ([]byte, error) {
+		if path == "" {
+			return nil, nil
+		}
+		return os.ReadFile(path)
+	}
+	if cert, err = read(s.CertFile); err != nil {
+		return
+	}
+	if key, err = read(s.KeyFile); err != nil {
+		return
+	}
+	ca, err = read(s.CAFile)
+	return
+}
+
+// SecretSource loads the TLS configuration from a Secret, with the
+// ClientCertIdentifier, ClientKeyIdentifier and CACertIdentifier keys, or the
+// 'tls.crt', 'tls.key' and 'ca.crt' keys of the 'kubernetes.io/tls' Secrets.
+type SecretSource struct {
+	Client client.Reader
+	Key    types.NamespacedName
+}
+
+// Load implements CertSource.
+func (s SecretSource) Load(ctx context.Context) (cert, key, ca []byte, err error) {
+	var secret corev1.Secret
+	if err = s.Client.Get(ctx, s.Key, &secret); err != nil {
+		return
+	}
+	value := func(keys ...string) []byte {
+		for _, k := range keys {
+			if v, ok := secret.Data[k]; ok {
+				return v
+			}
+		}
+		return nil
+	}
+	cert = value(ClientCertIdentifier, corev1.TLSCertKey)
+	key = value(ClientKeyIdentifier, corev1.TLSPrivateKeyKey)
+	ca = value(CACertIdentifier, corev1.ServiceAccountRootCAKey)
+	return
+}
+
+// ReloaderOptions holds the options of a Reloader.
+type ReloaderOptions struct {
+	// Interval is the interval at which the source is checked for changes.
+	// Defaults to 10 seconds.
+	Interval time.Duration
+}
+
+// Reloader provides TLS configurations which use the latest certificates
+// loaded from a CertSource, allowing the certificates to be rotated without
+// restarting the controller. The source is polled at the configured interval
+// once the Reloader is started, e.g. by adding it to the controller manager:
+//
+//	reloader, err := tls.NewReloader(ctx, "webhook", tls.FileSource{
+//		CertFile: "/etc/certs/tls.crt",
+//		KeyFile:  "/etc/certs/tls.key",
+//	}, tls.ReloaderOptions{})
+//	if err != nil {
+//		return err
+//	}
+//	crtlmetrics.Registry.MustRegister(reloader.Collectors()...)
+//	if err := mgr.Add(reloader); err != nil {
+//		return err
+//	}
+//
+// The server configuration is applied to the webhook servers with
+// webhook.Options{TLSOpts: []func(*tls.Config){reloader.ConfigureServer}},
+// and the client configuration to the HTTP transport of the events recorder.
+type Reloader struct {
+	name     string
+	source   CertSource
+	interval time.Duration
+
+	mu       sync.RWMutex
+	cert     *tls.Certificate
+	roots    *x509.CertPool
+	checksum [sha256.Size]byte
+
+	expiryGauge   *prometheus.GaugeVec
+	reloadCounter *prometheus.CounterVec
+}
+
+// NewReloader returns a Reloader with the given name for the source, after
+// loading the certificates of the source.
+func NewReloader(ctx context.Context, name string, source CertSource, opts ReloaderOptions) (*Reloader, error) {
+	if opts.Interval <= 0 {
+		opts.Interval = defaultReloadInterval
+	}
+	r := &Reloader{
+		name:     name,
+		source:   source,
+		interval: opts.Interval,
+		expiryGauge: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name:        "gotk_tls_certificate_expiry_timestamp_seconds",
+				Help:        "The expiry time in seconds since the epoch of the TLS certificates in use by a GitOps Toolkit controller.",
+				ConstLabels: prometheus.Labels{"name": name},
+			},
+			[]string{"certificate"},
+		),
+		reloadCounter: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name:        "gotk_tls_reloads_total",
+				Help:        "The total number of reloads of the TLS certificates of a GitOps Toolkit controller.",
+				ConstLabels: prometheus.Labels{"name": name},
+			},
+			[]string{"result"},
+		),
+	}
+	if _, err := r.Reload(ctx); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Collectors returns a slice of Prometheus collectors, which can be used to
+// register them in a metrics registry.
+func (r *Reloader) Collectors() []prometheus.Collector {
+	return []prometheus.Collector{r.expiryGauge, r.reloadCounter}
+}
+
+// Start polls the source for changes until the context is cancelled. The
+// reload errors are logged, and the previous certificates are kept in use.
+func (r *Reloader) Start(ctx context.Context) error {
+	logger := log.FromContext(ctx).WithValues("tls", r.name)
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			reloaded, err := r.Reload(ctx)
+			if err != nil {
+				logger.Error(err, "failed to reload TLS certificates")
+			} else if reloaded {
+				logger.Info("reloaded TLS certificates")
+			}
+		}
+	}
+}
+
+// Reload loads the certificates of the source, and replaces the certificates
+// in use if they changed. It returns true if the certificates were replaced.
+func (r *Reloader) Reload(ctx context.Context) (bool, error) {
+	certPEM, keyPEM, caPEM, err := r.source.Load(ctx)
+	if err != nil {
+		r.reloadCounter.WithLabelValues("failure").Inc()
+		return false, fmt.Errorf("failed to load TLS certificates: %w", err)
+	}
+	checksum := sha256.Sum256(bytes.Join([][]byte{certPEM, keyPEM, caPEM}, []byte{0}))
+
+	r.mu.RLock()
+	unchanged := r.checksum == checksum && (r.cert != nil || r.roots != nil)
+	r.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	cert, roots, err := parseCertificates(certPEM, keyPEM, caPEM)
+	if err != nil {
+		r.reloadCounter.WithLabelValues("failure").Inc()
+		return false, err
+	}
+
+	r.mu.Lock()
+	r.cert, r.roots, r.checksum = cert, roots, checksum
+	r.mu.Unlock()
+
+	r.expiryGauge.Reset()
+	if cert != nil {
+		r.expiryGauge.WithLabelValues("leaf").Set(float64(cert.Leaf.NotAfter.Unix()))
+	}
+	if roots != nil {
+		if ca := earliestExpiry(caPEM); !ca.IsZero() {
+			r.expiryGauge.WithLabelValues("ca").Set(float64(ca.Unix()))
+		}
+	}
+	r.reloadCounter.WithLabelValues("success").Inc()
+	return true, nil
+}
+
+// Certificate returns the certificate in use, if any.
+func (r *Reloader) Certificate() *tls.Certificate {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert
+}
+
+// ConfigureServer configures the given server TLS configuration to serve the
+// certificate in use.
+func (r *Reloader) ConfigureServer(cfg *tls.Config) {
+	cfg.Certificates = nil
+	cfg.GetCertificate = func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+		if cert := r.Certificate(); cert != nil {
+			return cert, nil
+		}
+		return nil, errors.New("no TLS certificate loaded")
+	}
+}
+
+// ServerConfig returns a server TLS configuration serving the certificate in
+// use.
+func (r *Reloader) ServerConfig() *tls.Config {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	r.ConfigureServer(cfg)
+	return cfg
+}
+
+// ClientConfig returns a client TLS configuration presenting the certificate
+// in use, if any, and verifying the server certificates against the system
+// roots and the CA certificates in use.
+func (r *Reloader) ClientConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			if cert := r.Certificate(); cert != nil {
+				return cert, nil
+			}
+			return &tls.Certificate{}, nil
+		},
+		// The verification is done in VerifyConnection with the CA
+		// certificates in use at the time of the connection.
+		InsecureSkipVerify: true,
+		VerifyConnection: func(cs tls.ConnectionState) error {
+			r.mu.RLock()
+			roots := r.roots
+			r.mu.RUnlock()
+			if roots == nil {
+				var err error
+				if roots, err = x509.SystemCertPool(); err != nil {
+					return err
+				}
+			}
+			if len(cs.PeerCertificates) == 0 {
+				return errors.New("no server certificate")
+			}
+			opts := x509.VerifyOptions{
+				DNSName:       cs.ServerName,
+				Roots:         roots,
+				Intermediates: x509.NewCertPool(),
+			}
+			for _, cert := range cs.PeerCertificates[1:] {
+				opts.Intermediates.AddCert(cert)
+			}
+			_, err := cs.PeerCertificates[0].Verify(opts)
+			return err
+		},
+	}
+}
+
+// parseCertificates parses the PEM encoded key pair and CA certificates.
+func parseCertificates(certPEM, keyPEM, caPEM []byte) (*tls.Certificate, *x509.CertPool, error) {
+	if (len(certPEM) == 0) != (len(keyPEM) == 0) {
+		return nil, nil, fmt.Errorf("found one of %s or %s, and expected both or neither", ClientCertIdentifier, ClientKeyIdentifier)
+	}
+	if len(certPEM) == 0 && len(caPEM) == 0 {
+		return nil, nil, fmt.Errorf("no %s and %s, or %s found", ClientCertIdentifier, ClientKeyIdentifier, CACertIdentifier)
+	}
+
+	var cert *tls.Certificate
+	if len(certPEM) > 0 {
+		pair, err := tls.X509KeyPair(certPEM, keyPEM)
+		if err != nil {
+			return nil, nil, err
+		}
+		if pair.Leaf, err = x509.ParseCertificate(pair.Certificate[0]); err != nil {
+			return nil, nil, err
+		}
+		cert = &pair
+	}
+
+	var roots *x509.CertPool
+	if len(caPEM) > 0 {
+		var err error
+		if roots, err = x509.SystemCertPool(); err != nil {
+			return nil, nil, err
+		}
+		if !roots.AppendCertsFromPEM(caPEM) {
+			return nil, nil, fmt.Errorf("no valid certificate found in %s", CACertIdentifier)
+		}
+	}
+	return cert, roots, nil
+}
+
+// earliestExpiry returns the earliest expiry time of the PEM encoded
+// certificates.
+func earliestExpiry(data []byte) time.Time {
+	var earliest time.Time
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return earliest
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			continue
+		}
+		if earliest.IsZero() || cert.NotAfter.Before(earliest) {
+			earliest = cert.NotAfter
+		}
+	}
+}
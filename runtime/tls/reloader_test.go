@@ -0,0 +1,202 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tls
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// generateCertificates returns a PEM encoded CA certificate, and a PEM
+// encoded key pair for 127.0.0.1 signed by the CA and expiring at notAfter.
+func generateCertificates(t *testing.T, notAfter time.Time) (ca, cert, key []byte) {
+	t.Helper()
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	caTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              notAfter.Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTmpl, caTmpl, &caKey.PublicKey, caKey)
+	require.NoError(t, err)
+	caCert, err := x509.ParseCertificate(caDER)
+	require.NoError(t, err)
+
+	leafKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	leafTmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     notAfter,
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	leafDER, err := x509.CreateCertificate(rand.Reader, leafTmpl, caCert, &leafKey.PublicKey, caKey)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(leafKey)
+	require.NoError(t, err)
+
+	ca = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER})
+	cert = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: leafDER})
+	key = pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	return
+}
+
+func TestReloader_FileSource(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	source := FileSource{
+		CertFile: filepath.Join(dir, "tls.crt"),
+		KeyFile:  filepath.Join(dir, "tls.key"),
+		CAFile:   filepath.Join(dir, "ca.crt"),
+	}
+	write := func(ca, cert, key []byte) {
+		require.NoError(t, os.WriteFile(source.CAFile, ca, 0o600))
+		require.NoError(t, os.WriteFile(source.CertFile, cert, 0o600))
+		require.NoError(t, os.WriteFile(source.KeyFile, key, 0o600))
+	}
+
+	firstExpiry := time.Now().Add(24 * time.Hour).Truncate(time.Second)
+	write(generateCertificates(t, firstExpiry))
+
+	reloader, err := NewReloader(ctx, "test", source, ReloaderOptions{})
+	require.NoError(t, err)
+	require.Equal(t, float64(firstExpiry.Unix()), testutil.ToFloat64(reloader.expiryGauge.WithLabelValues("leaf")))
+	first := reloader.Certificate()
+
+	reloaded, err := reloader.Reload(ctx)
+	require.NoError(t, err)
+	require.False(t, reloaded)
+
+	secondExpiry := firstExpiry.Add(24 * time.Hour)
+	write(generateCertificates(t, secondExpiry))
+	reloaded, err = reloader.Reload(ctx)
+	require.NoError(t, err)
+	require.True(t, reloaded)
+	require.NotEqual(t, first, reloader.Certificate())
+	require.Equal(t, float64(secondExpiry.Unix()), testutil.ToFloat64(reloader.expiryGauge.WithLabelValues("leaf")))
+	require.Equal(t, float64(2), testutil.ToFloat64(reloader.reloadCounter.WithLabelValues("success")))
+
+	require.NoError(t, os.WriteFile(source.KeyFile, []byte("invalid"), 0o600))
+	_, err = reloader.Reload(ctx)
+	require.Error(t, err)
+	require.NotNil(t, reloader.Certificate())
+	require.Equal(t, float64(1), testutil.ToFloat64(reloader.reloadCounter.WithLabelValues("failure")))
+}
+
+func TestReloader_SecretSource(t *testing.T) {
+	ctx := context.Background()
+	ca, cert, key := generateCertificates(t, time.Now().Add(time.Hour))
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "webhook-tls", Namespace: "flux-system"},
+		Type:       corev1.SecretTypeTLS,
+		Data: map[string][]byte{
+			corev1.TLSCertKey:              cert,
+			corev1.TLSPrivateKeyKey:        key,
+			corev1.ServiceAccountRootCAKey: ca,
+		},
+	}
+	c := fake.NewClientBuilder().WithObjects(secret).Build()
+
+	reloader, err := NewReloader(ctx, "test", SecretSource{
+		Client: c,
+		Key:    types.NamespacedName{Name: "webhook-tls", Namespace: "flux-system"},
+	}, ReloaderOptions{})
+	require.NoError(t, err)
+	require.NotNil(t, reloader.Certificate())
+
+	_, err = NewReloader(ctx, "missing", SecretSource{
+		Client: c,
+		Key:    types.NamespacedName{Name: "missing", Namespace: "flux-system"},
+	}, ReloaderOptions{})
+	require.Error(t, err)
+}
+
+func TestReloader_Handshake(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	source := FileSource{
+		CertFile: filepath.Join(dir, "tls.crt"),
+		KeyFile:  filepath.Join(dir, "tls.key"),
+		CAFile:   filepath.Join(dir, "ca.crt"),
+	}
+	write := func(ca, cert, key []byte) {
+		require.NoError(t, os.WriteFile(source.CAFile, ca, 0o600))
+		require.NoError(t, os.WriteFile(source.CertFile, cert, 0o600))
+		require.NoError(t, os.WriteFile(source.KeyFile, key, 0o600))
+	}
+	write(generateCertificates(t, time.Now().Add(time.Hour)))
+
+	reloader, err := NewReloader(ctx, "test", source, ReloaderOptions{})
+	require.NoError(t, err)
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	srv.Listener = tls.NewListener(srv.Listener, reloader.ServerConfig())
+	srv.Start()
+	defer srv.Close()
+	url := "https://" + srv.Listener.Addr().String()
+
+	httpClient := &http.Client{Transport: &http.Transport{TLSClientConfig: reloader.ClientConfig()}}
+	resp, err := httpClient.Get(url)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	// Rotate the CA and the certificates, new connections use both.
+	write(generateCertificates(t, time.Now().Add(time.Hour)))
+	reloaded, err := reloader.Reload(ctx)
+	require.NoError(t, err)
+	require.True(t, reloaded)
+	httpClient.CloseIdleConnections()
+	resp, err = httpClient.Get(url)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	// A client which does not trust the CA rejects the certificate.
+	roots := x509.NewCertPool()
+	untrusted := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots}}}
+	_, err = untrusted.Get(url)
+	require.Error(t, err)
+}
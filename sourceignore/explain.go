@@ -0,0 +1,205 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sourceignore
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
+)
+
+// DefaultRulesSource is the Source of the rules returned by DefaultRules.
+const DefaultRulesSource = "<default>"
+
+// Rule is an ignore pattern with information about its origin.
+type Rule struct {
+	// Pattern is the pattern as written in its source.
+	Pattern string
+	// Source is the path of the file the pattern was read from, or
+	// DefaultRulesSource for the VCS and default patterns.
+	Source string
+	// Line is the 1-based line of the pattern in its source, or zero if
+	// the source is not a file.
+	Line int
+	// Domain is the scope of the pattern.
+	Domain []string
+
+	pattern gitignore.Pattern
+}
+
+// NewRule returns a Rule for the given pattern, source, line and domain.
+func NewRule(pattern, source string, line int, domain []string) Rule {
+	return Rule{
+		Pattern: pattern,
+		Source:  source,
+		Line:    line,
+		Domain:  domain,
+		pattern: gitignore.ParsePattern(pattern, domain),
+	}
+}
+
+// Rules is a list of ignore rules, in which later rules take precedence
+// over earlier ones.
+type Rules []Rule
+
+// Patterns returns the gitignore.Pattern slice of the rules.
+func (rs Rules) Patterns() []gitignore.Pattern {
+	var ps []gitignore.Pattern
+	for _, r := range rs {
+		ps = append(ps, r.pattern)
+	}
+	return ps
+}
+
+// Explanation describes why a path is or is not ignored.
+type Explanation struct {
+	// Path is the explained path.
+	Path []string
+	// Ignored is true if the path is ignored.
+	Ignored bool
+	// Rule is the rule which decided whether the path is ignored, or nil
+	// if no rule matches the path.
+	Rule *Rule
+}
+
+// String returns a human-readable description of the explanation.
+func (e Explanation) String() string {
+	p := strings.Join(e.Path, "/")
+	if e.Rule == nil {
+		return p + ": not matched by any rule"
+	}
+	verb := "included"
+	if e.Ignored {
+		verb = "ignored"
+	}
+	src := e.Rule.Source
+	if e.Rule.Line > 0 {
+		src = src + ":" + strconv.Itoa(e.Rule.Line)
+	}
+	return p + ": " + verb + " by '" + e.Rule.Pattern + "' (" + src + ")"
+}
+
+// Explain returns which rule decides whether the given path is ignored,
+// following the same precedence as the gitignore.Matcher of the patterns.
+func (rs Rules) Explain(path []string, isDir bool) Explanation {
+	for i := len(rs) - 1; i >= 0; i-- {
+		switch rs[i].pattern.Match(path, isDir) {
+		case gitignore.Exclude:
+			return Explanation{Path: path, Ignored: true, Rule: &rs[i]}
+		case gitignore.Include:
+			return Explanation{Path: path, Ignored: false, Rule: &rs[i]}
+		}
+	}
+	return Explanation{Path: path}
+}
+
+// DefaultRules returns the VCSPatterns and DefaultPatterns as rules, in
+// the order in which NewDefaultMatcher applies them.
+func DefaultRules(domain []string) Rules {
+	var rs Rules
+	for _, p := range strings.Split(ExcludeVCS, ",") {
+		rs = append(rs, NewRule(p, DefaultRulesSource, 0, domain))
+	}
+	all := strings.Join([]string{ExcludeExt, ExcludeCI, ExcludeExtra}, ",")
+	for _, p := range strings.Split(all, ",") {
+		rs = append(rs, NewRule(p, DefaultRulesSource, 0, domain))
+	}
+	return rs
+}
+
+// ReadRules collects ignore rules from the given reader, and records the
+// given source as their origin.
+// If a domain is supplied, this is used as the scope of the read rules.
+func ReadRules(reader io.Reader, source string, domain []string) Rules {
+	var rs Rules
+	scanner := bufio.NewScanner(reader)
+	line := 0
+	for scanner.Scan() {
+		line++
+		s := scanner.Text()
+		if !strings.HasPrefix(s, "#") && len(strings.TrimSpace(s)) > 0 {
+			rs = append(rs, NewRule(s, source, line, domain))
+		}
+	}
+	return rs
+}
+
+// ReadIgnoreFileRules attempts to read the file at the given path and
+// returns the read rules.
+func ReadIgnoreFileRules(path string, domain []string) (Rules, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+	return ReadRules(f, path, domain), nil
+}
+
+// LoadIgnoreRules recursively loads the IgnoreFile rules found in the
+// directory. The rules of the files in subdirectories are scoped to these
+// subdirectories, and take precedence over the rules of their parents.
+func LoadIgnoreRules(dir string, domain []string) (Rules, error) {
+	// Make a copy of the domain so that the underlying string array of domain
+	// in the gitignore patterns are unique without any side effects.
+	dom := make([]string, len(domain))
+	copy(dom, domain)
+
+	rs, err := ReadIgnoreFileRules(filepath.Join(dir, IgnoreFile), dom)
+	if err != nil {
+		return nil, err
+	}
+	fis, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, fi := range fis {
+		if fi.IsDir() && fi.Name() != ".git" {
+			subrs, err := LoadIgnoreRules(filepath.Join(dir, fi.Name()), append(dom, fi.Name()))
+			if err != nil {
+				return nil, err
+			}
+			rs = append(rs, subrs...)
+		}
+	}
+	return rs, nil
+}
+
+// Explain loads the DefaultRules and the IgnoreFile rules found in dir,
+// and returns which rule decides whether the file at the given path,
+// relative to dir, is ignored.
+func Explain(dir, path string) (Explanation, error) {
+	rs, err := LoadIgnoreRules(dir, nil)
+	if err != nil {
+		return Explanation{}, err
+	}
+	rs = append(DefaultRules(nil), rs...)
+
+	var isDir bool
+	if fi, err := os.Stat(filepath.Join(dir, path)); err == nil {
+		isDir = fi.IsDir()
+	}
+	rel := strings.Split(filepath.ToSlash(filepath.Clean(path)), "/")
+	return rs.Explain(rel, isDir), nil
+}
@@ -0,0 +1,186 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sourceignore
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
+)
+
+// Exclusion profile names.
+const (
+	// ProfileDefault excludes the metadata of version control systems,
+	// CI configuration files and binary media.
+	ProfileDefault = "default"
+	// ProfileStrict extends ProfileDefault by also excluding documentation,
+	// editor and OS files, archives and binaries, and files which commonly
+	// hold credentials.
+	ProfileStrict = "strict"
+	// ProfileHelmChart extends ProfileDefault with the patterns of the
+	// .helmignore file generated by `helm create`.
+	ProfileHelmChart = "helm-chart"
+	// ProfileDocsHeavy extends ProfileDefault by also excluding
+	// documentation sources and static site generator files.
+	ProfileDocsHeavy = "docs-heavy"
+)
+
+const (
+	// ExcludeOtherVCS are the metadata files and directories of version
+	// control systems other than Git.
+	ExcludeOtherVCS = ".hg/,.hgignore,.hgtags,.svn/,.bzr/,.bzrignore,CVS/,.cvsignore,_darcs/,.pijul/,.fslckout,_FOSSIL_"
+	// ExcludeEditor are the files created by editors and operating systems.
+	ExcludeEditor = ".DS_Store,Thumbs.db,*.swp,*.swo,*~,*.bak,*.tmp,*.orig,.project,.idea/,*.tmproj,.vscode/"
+	// ExcludeBinaries are archives and compiled binaries.
+	ExcludeBinaries = "*.tar,*.tgz,*.gz,*.bz2,*.xz,*.7z,*.rar,*.jar,*.war,*.exe,*.dll,*.so,*.dylib,*.pdf,*.mp4,*.mov,*.svg,*.ico,*.webp"
+	// ExcludeCredentials are files which commonly hold credentials.
+	ExcludeCredentials = "**/.env,**/.env.*,**/*.pem,**/*.key,**/id_rsa,**/id_ecdsa,**/id_ed25519,**/.netrc,**/.npmrc,**/.dockerconfigjson"
+	// ExcludeDocs are documentation sources.
+	ExcludeDocs = "docs/,doc/,*.md,*.markdown,*.rst,*.adoc,LICENSE,NOTICE,AUTHORS,CODEOWNERS"
+	// ExcludeSite are the files of static site generators.
+	ExcludeSite = "site/,website/,mkdocs.yml,mkdocs.yaml,book.toml,hugo.toml,hugo.yaml,netlify.toml,node_modules/"
+)
+
+// profiles holds the built-in exclusion profiles.
+var profiles = map[string]Profile{}
+
+func init() {
+	def := NewProfile(ProfileDefault, splitPatterns(ExcludeVCS, ExcludeOtherVCS, ExcludeExt, ExcludeCI, ExcludeExtra)...)
+	profiles[ProfileDefault] = def
+	profiles[ProfileStrict] = ComposeProfiles(ProfileStrict, def,
+		NewProfile(ProfileStrict, splitPatterns(ExcludeDocs, ExcludeEditor, ExcludeBinaries, ExcludeCredentials)...))
+	profiles[ProfileHelmChart] = ComposeProfiles(ProfileHelmChart, def,
+		NewProfile(ProfileHelmChart, splitPatterns(ExcludeEditor)...))
+	profiles[ProfileDocsHeavy] = ComposeProfiles(ProfileDocsHeavy, def,
+		NewProfile(ProfileDocsHeavy, splitPatterns(ExcludeDocs, ExcludeSite)...))
+}
+
+// Profile is a named list of exclusion patterns, which may be composed of
+// the patterns of other profiles.
+type Profile struct {
+	// Name is the name of the profile.
+	Name string
+
+	patterns []profilePattern
+}
+
+// profilePattern is a pattern of a profile, with the name of the profile
+// it originates from.
+type profilePattern struct {
+	pattern string
+	profile string
+}
+
+// NewProfile returns a Profile with the given name and patterns.
+func NewProfile(name string, patterns ...string) Profile {
+	p := Profile{Name: name}
+	for _, pattern := range patterns {
+		p.patterns = append(p.patterns, profilePattern{pattern: pattern, profile: name})
+	}
+	return p
+}
+
+// ComposeProfiles returns a Profile with the given name, holding the
+// patterns of the given profiles in order. Patterns of later profiles take
+// precedence over the ones of earlier profiles, which allows a profile to
+// re-include paths with negated patterns.
+func ComposeProfiles(name string, ps ...Profile) Profile {
+	p := Profile{Name: name}
+	for _, other := range ps {
+		p.patterns = append(p.patterns, other.patterns...)
+	}
+	return p
+}
+
+// LookupProfile returns the built-in profile with the given name.
+func LookupProfile(name string) (Profile, error) {
+	p, ok := profiles[name]
+	if !ok {
+		return Profile{}, fmt.Errorf("unknown exclusion profile '%s', must be one of: %s",
+			name, strings.Join(ProfileNames(), ", "))
+	}
+	return p, nil
+}
+
+// LookupProfiles composes the built-in profiles with the given names, in
+// order. The name of the returned profile is the comma-separated list of
+// names.
+func LookupProfiles(names ...string) (Profile, error) {
+	ps := make([]Profile, 0, len(names))
+	for _, name := range names {
+		p, err := LookupProfile(name)
+		if err != nil {
+			return Profile{}, err
+		}
+		ps = append(ps, p)
+	}
+	return ComposeProfiles(strings.Join(names, ","), ps...), nil
+}
+
+// ProfileNames returns the sorted names of the built-in profiles.
+func ProfileNames() []string {
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ProfileRulesSource returns the Source of the rules originating from the
+// profile with the given name.
+func ProfileRulesSource(name string) string {
+	return "<profile:" + name + ">"
+}
+
+// Patterns returns the patterns of the profile.
+func (p Profile) Patterns() []string {
+	ps := make([]string, 0, len(p.patterns))
+	for _, pp := range p.patterns {
+		ps = append(ps, pp.pattern)
+	}
+	return ps
+}
+
+// Rules returns the patterns of the profile as rules scoped to the given
+// domain, with the profile each of them originates from as their Source.
+func (p Profile) Rules(domain []string) Rules {
+	var rs Rules
+	for _, pp := range p.patterns {
+		rs = append(rs, NewRule(pp.pattern, ProfileRulesSource(pp.profile), 0, domain))
+	}
+	return rs
+}
+
+// Matcher returns a gitignore.Matcher for the patterns of the profile
+// followed by the given rules, along with the rules it was compiled from,
+// which can be used to explain its decisions.
+func (p Profile) Matcher(domain []string, rs ...Rule) (gitignore.Matcher, Rules) {
+	all := append(p.Rules(domain), rs...)
+	return gitignore.NewMatcher(all.Patterns()), all
+}
+
+// splitPatterns splits the given comma-separated lists of patterns.
+func splitPatterns(lists ...string) []string {
+	var ps []string
+	for _, l := range lists {
+		ps = append(ps, strings.Split(l, ",")...)
+	}
+	return ps
+}
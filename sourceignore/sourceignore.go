@@ -20,7 +20,6 @@ import (
 	"bufio"
 	"io"
 	"os"
-	"path/filepath"
 	"strings"
 
 	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
@@ -103,29 +102,9 @@ func ReadIgnoreFile(path string, domain []string) ([]gitignore.Pattern, error) {
 // LoadIgnorePatterns recursively loads the IgnoreFile patterns found
 // in the directory.
 func LoadIgnorePatterns(dir string, domain []string) ([]gitignore.Pattern, error) {
-	// Make a copy of the domain so that the underlying string array of domain
-	// in the gitignore patterns are unique without any side effects.
-	dom := make([]string, len(domain))
-	copy(dom, domain)
-
-	ps, err := ReadIgnoreFile(filepath.Join(dir, IgnoreFile), dom)
-	if err != nil {
-		return nil, err
-	}
-	fis, err := os.ReadDir(dir)
+	rs, err := LoadIgnoreRules(dir, domain)
 	if err != nil {
 		return nil, err
 	}
-	for _, fi := range fis {
-		if fi.IsDir() && fi.Name() != ".git" {
-			var subps []gitignore.Pattern
-			if subps, err = LoadIgnorePatterns(filepath.Join(dir, fi.Name()), append(dom, fi.Name())); err != nil {
-				return nil, err
-			}
-			if len(subps) > 0 {
-				ps = append(ps, subps...)
-			}
-		}
-	}
-	return ps, nil
+	return rs.Patterns(), nil
 }
@@ -259,3 +259,133 @@ func TestLoadExcludePatterns(t *testing.T) {
 		})
 	}
 }
+
+func TestExplain(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		".sourceignore":     "# comment\n*.txt\n!keep.txt\n",
+		"sub/.sourceignore": "!sub.txt\n",
+		"sub/sub.txt":       "",
+		"sub/other.txt":     "",
+	}
+	for n, c := range files {
+		if err := os.MkdirAll(filepath.Join(tmpDir, filepath.Dir(n)), 0o750); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(tmpDir, n), []byte(c), 0o640); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rootIgnore := filepath.Join(tmpDir, IgnoreFile)
+	subIgnore := filepath.Join(tmpDir, "sub", IgnoreFile)
+
+	tests := []struct {
+		path        string
+		wantIgnored bool
+		wantPattern string
+		wantSource  string
+		wantLine    int
+	}{
+		{path: "a.txt", wantIgnored: true, wantPattern: "*.txt", wantSource: rootIgnore, wantLine: 2},
+		{path: "keep.txt", wantPattern: "!keep.txt", wantSource: rootIgnore, wantLine: 3},
+		{path: "sub/sub.txt", wantPattern: "!sub.txt", wantSource: subIgnore, wantLine: 1},
+		{path: "sub/other.txt", wantIgnored: true, wantPattern: "*.txt", wantSource: rootIgnore, wantLine: 2},
+		{path: "image.png", wantIgnored: true, wantPattern: "*.png", wantSource: DefaultRulesSource},
+		{path: "main.go"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			g := NewWithT(t)
+
+			got, err := Explain(tmpDir, tt.path)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(got.Ignored).To(Equal(tt.wantIgnored))
+			if tt.wantPattern == "" {
+				g.Expect(got.Rule).To(BeNil())
+				g.Expect(got.String()).To(Equal(tt.path + ": not matched by any rule"))
+				return
+			}
+			g.Expect(got.Rule).ToNot(BeNil())
+			g.Expect(got.Rule.Pattern).To(Equal(tt.wantPattern))
+			g.Expect(got.Rule.Source).To(Equal(tt.wantSource))
+			g.Expect(got.Rule.Line).To(Equal(tt.wantLine))
+		})
+	}
+}
+
+func TestLookupProfile(t *testing.T) {
+	g := NewWithT(t)
+
+	g.Expect(ProfileNames()).To(Equal([]string{ProfileDefault, ProfileDocsHeavy, ProfileHelmChart, ProfileStrict}))
+
+	_, err := LookupProfile("unknown")
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.Error()).To(ContainSubstring("must be one of: default, docs-heavy, helm-chart, strict"))
+
+	def, err := LookupProfile(ProfileDefault)
+	g.Expect(err).ToNot(HaveOccurred())
+	for _, p := range strings.Split(ExcludeVCS+","+ExcludeOtherVCS, ",") {
+		g.Expect(def.Patterns()).To(ContainElement(p))
+	}
+}
+
+func TestProfile_Matcher(t *testing.T) {
+	tests := []struct {
+		profiles    []string
+		path        string
+		isDir       bool
+		wantIgnored bool
+		wantSource  string
+	}{
+		{profiles: []string{ProfileDefault}, path: ".hg", isDir: true, wantIgnored: true, wantSource: "<profile:default>"},
+		{profiles: []string{ProfileDefault}, path: "README.md"},
+		{profiles: []string{ProfileStrict}, path: "README.md", wantIgnored: true, wantSource: "<profile:strict>"},
+		{profiles: []string{ProfileStrict}, path: "config/.env", wantIgnored: true, wantSource: "<profile:strict>"},
+		{profiles: []string{ProfileStrict}, path: "image.png", wantIgnored: true, wantSource: "<profile:default>"},
+		{profiles: []string{ProfileHelmChart}, path: ".vscode", isDir: true, wantIgnored: true, wantSource: "<profile:helm-chart>"},
+		{profiles: []string{ProfileHelmChart}, path: "templates/deployment.yaml"},
+		{profiles: []string{ProfileDocsHeavy}, path: "docs", isDir: true, wantIgnored: true, wantSource: "<profile:docs-heavy>"},
+		{profiles: []string{ProfileDocsHeavy, ProfileHelmChart}, path: "chart.tmproj", wantIgnored: true, wantSource: "<profile:helm-chart>"},
+	}
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.profiles, ",")+"/"+tt.path, func(t *testing.T) {
+			g := NewWithT(t)
+
+			p, err := LookupProfiles(tt.profiles...)
+			g.Expect(err).ToNot(HaveOccurred())
+
+			path := strings.Split(tt.path, "/")
+			m, rs := p.Matcher(nil)
+			g.Expect(m.Match(path, tt.isDir)).To(Equal(tt.wantIgnored))
+
+			exp := rs.Explain(path, tt.isDir)
+			g.Expect(exp.Ignored).To(Equal(tt.wantIgnored))
+			if tt.wantSource == "" {
+				g.Expect(exp.Rule).To(BeNil())
+				return
+			}
+			g.Expect(exp.Rule.Source).To(Equal(tt.wantSource))
+		})
+	}
+}
+
+func TestComposeProfiles(t *testing.T) {
+	g := NewWithT(t)
+
+	def, err := LookupProfile(ProfileDefault)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	p := ComposeProfiles("custom", def, NewProfile("extra", "*.md", "!CHANGELOG.md"))
+	g.Expect(p.Name).To(Equal("custom"))
+
+	m, rs := p.Matcher(nil, ReadRules(strings.NewReader("!keep.png\n"), "file", nil)...)
+	g.Expect(m.Match([]string{"README.md"}, false)).To(BeTrue())
+	g.Expect(m.Match([]string{"CHANGELOG.md"}, false)).To(BeFalse())
+	g.Expect(m.Match([]string{"keep.png"}, false)).To(BeFalse())
+	g.Expect(m.Match([]string{"other.png"}, false)).To(BeTrue())
+
+	exp := rs.Explain([]string{"CHANGELOG.md"}, false)
+	g.Expect(exp.Rule).ToNot(BeNil())
+	g.Expect(exp.Rule.Source).To(Equal("<profile:extra>"))
+	g.Expect(exp.Rule.Pattern).To(Equal("!CHANGELOG.md"))
+}
@@ -0,0 +1,71 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ssa
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/fluxcd/pkg/ssa/utils"
+)
+
+// ApprovalRequest describes a potentially destructive action
+// for which the ApprovalPolicy is consulted.
+type ApprovalRequest struct {
+	// Object is the in-cluster object subject to the action.
+	Object *unstructured.Unstructured
+
+	// Action is the action to be performed on the object. It is
+	// ConfiguredAction for in-place updates and DeletedAction for
+	// deletions, including the recreation of objects with immutable
+	// field changes.
+	Action Action
+
+	// Desired is the desired state of the object, as returned by the
+	// server-side dry-run apply. Together with Object, it describes the
+	// diff of the action. Desired is nil for deletions performed by Delete.
+	Desired *unstructured.Unstructured
+}
+
+// ApprovalPolicy decides if the action described by the given request can be
+// performed. Actions which are not approved are skipped and reported in the
+// change set with the PendingAction, so that controllers can surface the
+// objects awaiting approval.
+type ApprovalPolicy func(ctx context.Context, req ApprovalRequest) (bool, error)
+
+// SetApprovalPolicy configures the policy consulted by Apply, ApplyAll, ApplyAllStaged,
+// Delete and DeleteAll before updating, recreating or deleting in-cluster objects,
+// including the cleanup of their metadata.
+// A nil policy approves all actions.
+func (m *ResourceManager) SetApprovalPolicy(policy ApprovalPolicy) {
+	m.approvalPolicy = policy
+}
+
+// approved returns true if the action is approved by the approval policy
+// or if no policy is configured.
+func (m *ResourceManager) approved(ctx context.Context, req ApprovalRequest) (bool, error) {
+	if m.approvalPolicy == nil {
+		return true, nil
+	}
+	ok, err := m.approvalPolicy(ctx, req)
+	if err != nil {
+		return false, fmt.Errorf("%s approval policy failed: %w", utils.FmtUnstructured(req.Object), err)
+	}
+	return ok, nil
+}
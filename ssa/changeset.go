@@ -41,9 +41,15 @@ const (
 	UnchangedAction Action = "unchanged"
 	// DeletedAction represents the deletion of an object.
 	DeletedAction Action = "deleted"
+	// OrphanedAction represents the removal of the ownership metadata from an
+	// object instead of its deletion.
+	OrphanedAction Action = "orphaned"
 	// SkippedAction represents the fact that no action was performed on an object
 	// due to the object being excluded from the reconciliation.
 	SkippedAction Action = "skipped"
+	// PendingAction represents the fact that no action was performed on an object
+	// due to the action awaiting approval from the ApprovalPolicy.
+	PendingAction Action = "pending"
 	// UnknownAction represents an unknown action.
 	UnknownAction Action = "unknown"
 )
@@ -105,6 +111,10 @@ type ChangeSetEntry struct {
 
 	// Action represents the action type taken by the reconciler for this object.
 	Action Action
+
+	// Warnings holds the issues found for this object which did not prevent
+	// the action from being taken, e.g. a CRD with a non-structural schema.
+	Warnings []string
 }
 
 func (e ChangeSetEntry) String() string {
@@ -0,0 +1,164 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ssa
+
+import (
+	"context"
+	"fmt"
+
+	discoveryv1 "k8s.io/api/discovery/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/fluxcd/pkg/ssa/utils"
+)
+
+// CustomResourceDefinitionStatus holds the observed state of an applied CustomResourceDefinition.
+type CustomResourceDefinitionStatus struct {
+	// Name is the name of the CustomResourceDefinition.
+	Name string
+
+	// Established is true when the API server serves the custom resources.
+	Established bool
+
+	// NonStructuralSchema holds the message of the NonStructuralSchema condition,
+	// empty if the schema is structural.
+	NonStructuralSchema string
+
+	// ConversionWebhook holds the 'namespace/name' of the conversion webhook Service,
+	// empty if the conversion strategy is not Webhook, if the webhook is configured by URL,
+	// or if the endpoints of the Service can not be read.
+	ConversionWebhook string
+
+	// ConversionWebhookReady is true when the conversion webhook Service has ready endpoints.
+	ConversionWebhookReady bool
+}
+
+// Warnings returns the issues found for the CustomResourceDefinition
+// which do not prevent the custom resources from being applied.
+func (s CustomResourceDefinitionStatus) Warnings() []string {
+	var warnings []string
+	if s.NonStructuralSchema != "" {
+		warnings = append(warnings, fmt.Sprintf("non-structural schema: %s", s.NonStructuralSchema))
+	}
+	if s.ConversionWebhook != "" && !s.ConversionWebhookReady {
+		warnings = append(warnings, fmt.Sprintf("conversion webhook Service '%s' has no ready endpoints", s.ConversionWebhook))
+	}
+	return warnings
+}
+
+// CustomResourceDefinitionStatuses returns the status of the CustomResourceDefinitions
+// found in the given objects, in the order they are given. For CRDs using a conversion
+// webhook backed by a Service, the availability of the webhook is determined by the
+// presence of ready endpoints in the EndpointSlices of the Service.
+// The CRDs which can not be read are skipped, as well as the webhook availability
+// when the EndpointSlices can not be listed due to missing permissions.
+func (m *ResourceManager) CustomResourceDefinitionStatuses(ctx context.Context, objects []*unstructured.Unstructured) ([]CustomResourceDefinitionStatus, error) {
+	var result []CustomResourceDefinitionStatus
+	for _, object := range objects {
+		if !utils.IsCRD(object) {
+			continue
+		}
+
+		existingObject := &unstructured.Unstructured{}
+		existingObject.SetGroupVersionKind(object.GroupVersionKind())
+		if err := m.client.Get(ctx, client.ObjectKeyFromObject(object), existingObject); err != nil {
+			log.FromContext(ctx).Error(err, "skipping CustomResourceDefinition status",
+				"name", object.GetName())
+			continue
+		}
+
+		crd := &apiextensionsv1.CustomResourceDefinition{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(existingObject.Object, crd); err != nil {
+			return nil, fmt.Errorf("%s conversion failed: %w", utils.FmtUnstructured(object), err)
+		}
+
+		status := CustomResourceDefinitionStatus{Name: crd.GetName()}
+		for _, c := range crd.Status.Conditions {
+			switch c.Type {
+			case apiextensionsv1.Established:
+				status.Established = c.Status == apiextensionsv1.ConditionTrue
+			case apiextensionsv1.NonStructuralSchema:
+				if c.Status == apiextensionsv1.ConditionTrue {
+					status.NonStructuralSchema = c.Message
+				}
+			}
+		}
+
+		if conv := crd.Spec.Conversion; conv != nil && conv.Strategy == apiextensionsv1.WebhookConverter &&
+			conv.Webhook != nil && conv.Webhook.ClientConfig != nil && conv.Webhook.ClientConfig.Service != nil {
+			svc := conv.Webhook.ClientConfig.Service
+			key := client.ObjectKey{Namespace: svc.Namespace, Name: svc.Name}
+
+			ready, err := m.hasReadyEndpoints(ctx, key)
+			switch {
+			case apierrors.IsForbidden(err):
+				log.FromContext(ctx).Info("skipping conversion webhook availability check",
+					"name", crd.GetName(), "service", key.String(), "error", err.Error())
+			case err != nil:
+				return nil, err
+			default:
+				status.ConversionWebhook = key.String()
+				status.ConversionWebhookReady = ready
+			}
+		}
+
+		result = append(result, status)
+	}
+	return result, nil
+}
+
+// hasReadyEndpoints returns true if the Service with the given key has at least one ready
+// endpoint in its EndpointSlices.
+func (m *ResourceManager) hasReadyEndpoints(ctx context.Context, key client.ObjectKey) (bool, error) {
+	slices := &discoveryv1.EndpointSliceList{}
+	if err := m.client.List(ctx, slices, client.InNamespace(key.Namespace),
+		client.MatchingLabels{discoveryv1.LabelServiceName: key.Name}); err != nil {
+		return false, fmt.Errorf("EndpointSlices of Service/%s query failed: %w", key.String(), err)
+	}
+	for _, slice := range slices.Items {
+		for _, endpoint := range slice.Endpoints {
+			// A nil ready condition must be interpreted as ready.
+			if endpoint.Conditions.Ready == nil || *endpoint.Conditions.Ready {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// setCustomResourceDefinitionWarnings adds the warnings of the given CRD statuses
+// to the matching entries of the change set.
+func setCustomResourceDefinitionWarnings(cs *ChangeSet, statuses []CustomResourceDefinitionStatus) {
+	for _, status := range statuses {
+		warnings := status.Warnings()
+		if len(warnings) == 0 {
+			continue
+		}
+		for i, entry := range cs.Entries {
+			if entry.ObjMetadata.GroupKind.Group == apiextensionsv1.GroupName &&
+				entry.ObjMetadata.GroupKind.Kind == "CustomResourceDefinition" &&
+				entry.ObjMetadata.Name == status.Name {
+				cs.Entries[i].Warnings = append(cs.Entries[i].Warnings, warnings...)
+			}
+		}
+	}
+}
@@ -0,0 +1,82 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ssa
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestApplyAllStaged_CustomResourceDefinitionWarnings(t *testing.T) {
+	timeout := 10 * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	id := generateName("crd")
+	objects, err := readManifest("testdata/test12.yaml", id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	crdName := fmt.Sprintf("webhooktests.%s.fluxcd.io", id)
+
+	changeSet, err := manager.ApplyAllStaged(ctx, objects, DefaultApplyOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("reports status", func(t *testing.T) {
+		statuses, err := manager.CustomResourceDefinitionStatuses(ctx, objects)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(statuses) != 1 {
+			t.Fatalf("Expected one CRD status, got %d", len(statuses))
+		}
+
+		status := statuses[0]
+		if status.Name != crdName {
+			t.Errorf("Expected name %s, got %s", crdName, status.Name)
+		}
+		if !status.Established {
+			t.Errorf("Expected CRD to be established")
+		}
+		if status.ConversionWebhook != id+"/webhook" {
+			t.Errorf("Expected conversion webhook %s/webhook, got %s", id, status.ConversionWebhook)
+		}
+		if status.ConversionWebhookReady {
+			t.Errorf("Expected conversion webhook to not be ready")
+		}
+	})
+
+	t.Run("sets change set warnings", func(t *testing.T) {
+		for _, entry := range changeSet.Entries {
+			switch entry.ObjMetadata.Name {
+			case crdName:
+				if len(entry.Warnings) != 1 {
+					t.Errorf("Expected one warning for %s, got %v", entry.Subject, entry.Warnings)
+				}
+			default:
+				if len(entry.Warnings) != 0 {
+					t.Errorf("Expected no warnings for %s, got %v", entry.Subject, entry.Warnings)
+				}
+			}
+		}
+	})
+}
@@ -0,0 +1,226 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ssa
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/openapi"
+)
+
+// SchemaDefaults holds the default values of the fields of Kubernetes kinds,
+// extracted from the OpenAPI v3 schemas served by the API server.
+// It is used in drift detection to ignore the differences between the
+// in-cluster and the dry-run objects which are only due to server-populated
+// defaults, e.g. when a default is added to a CRD schema after the objects
+// have been created.
+type SchemaDefaults struct {
+	mu sync.RWMutex
+	// kinds maps the kinds to their root schema.
+	kinds map[schema.GroupVersionKind]*openAPISchema
+	// refs maps the schema references to their schema.
+	refs map[string]*openAPISchema
+}
+
+// openAPIDocument is the subset of an OpenAPI v3 document used to extract
+// the field defaults.
+type openAPIDocument struct {
+	Components struct {
+		Schemas map[string]*openAPISchema `json:"schemas"`
+	} `json:"components"`
+}
+
+// openAPISchema is the subset of an OpenAPI v3 schema used to extract
+// the field defaults.
+type openAPISchema struct {
+	Ref        string                    `json:"$ref,omitempty"`
+	AllOf      []*openAPISchema          `json:"allOf,omitempty"`
+	Default    json.RawMessage           `json:"default,omitempty"`
+	Properties map[string]*openAPISchema `json:"properties,omitempty"`
+	Items      *openAPISchema            `json:"items,omitempty"`
+	GVK        []schema.GroupVersionKind `json:"x-kubernetes-group-version-kind,omitempty"`
+}
+
+// NewSchemaDefaults returns an empty SchemaDefaults.
+func NewSchemaDefaults() *SchemaDefaults {
+	return &SchemaDefaults{
+		kinds: make(map[schema.GroupVersionKind]*openAPISchema),
+		refs:  make(map[string]*openAPISchema),
+	}
+}
+
+// AddDocument adds the kinds of the given OpenAPI v3 document, as served by
+// the API server at '/openapi/v3/apis/<group>/<version>'.
+func (d *SchemaDefaults) AddDocument(data []byte) error {
+	var doc openAPIDocument
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("failed to parse OpenAPI document: %w", err)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for name, s := range doc.Components.Schemas {
+		d.refs["#/components/schemas/"+name] = s
+		for _, gvk := range s.GVK {
+			d.kinds[gvk] = s
+		}
+	}
+	return nil
+}
+
+// Load downloads the OpenAPI v3 documents of all the group versions served
+// by the API server, e.g. from discovery.DiscoveryClient.OpenAPIV3().
+func (d *SchemaDefaults) Load(client openapi.Client) error {
+	paths, err := client.Paths()
+	if err != nil {
+		return fmt.Errorf("failed to list OpenAPI paths: %w", err)
+	}
+	for path, gv := range paths {
+		data, err := gv.Schema(runtime.ContentTypeJSON)
+		if err != nil {
+			return fmt.Errorf("failed to download OpenAPI document '%s': %w", path, err)
+		}
+		if err := d.AddDocument(data); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// Prune removes from both objects the fields which are either absent or set
+// to their default value in each object. The objects are modified in place.
+// It returns false if the schema of the kind is unknown.
+func (d *SchemaDefaults) Prune(gvk schema.GroupVersionKind, a, b map[string]interface{}) bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	s, ok := d.kinds[gvk]
+	if !ok {
+		return false
+	}
+	d.prune(a, b, s)
+	return true
+}
+
+// prune removes the defaulted fields of the given maps according to the schema.
+func (d *SchemaDefaults) prune(a, b map[string]interface{}, s *openAPISchema) {
+	s = d.resolve(s)
+	for name, ps := range s.Properties {
+		ps = d.resolve(ps)
+		av, aok := a[name]
+		bv, bok := b[name]
+		if !aok && !bok {
+			continue
+		}
+
+		if len(ps.Default) > 0 {
+			if (!aok || isDefault(av, ps.Default)) && (!bok || isDefault(bv, ps.Default)) {
+				delete(a, name)
+				delete(b, name)
+			}
+			continue
+		}
+
+		switch {
+		case len(ps.Properties) > 0:
+			am, aIsMap := asMap(av, aok)
+			bm, bIsMap := asMap(bv, bok)
+			if !aIsMap || !bIsMap {
+				continue
+			}
+			d.prune(am, bm, ps)
+			// remove the objects left empty, e.g. when only one side had
+			// the defaulted fields
+			if len(am) == 0 && len(bm) == 0 {
+				delete(a, name)
+				delete(b, name)
+			}
+		case ps.Items != nil:
+			al, aIsList := av.([]interface{})
+			bl, bIsList := bv.([]interface{})
+			if !aIsList || !bIsList || len(al) != len(bl) {
+				continue
+			}
+			for i := range al {
+				am, aIsMap := al[i].(map[string]interface{})
+				bm, bIsMap := bl[i].(map[string]interface{})
+				if aIsMap && bIsMap {
+					d.prune(am, bm, ps.Items)
+				}
+			}
+		}
+	}
+}
+
+// resolve returns the schema referenced by s, keeping the default of s.
+func (d *SchemaDefaults) resolve(s *openAPISchema) *openAPISchema {
+	for i := 0; s != nil && i < 10; i++ {
+		var target *openAPISchema
+		switch {
+		case s.Ref != "":
+			target = d.refs[s.Ref]
+		case len(s.AllOf) == 1 && len(s.Properties) == 0:
+			target = s.AllOf[0]
+		default:
+			return s
+		}
+		if target == nil {
+			return s
+		}
+		if len(s.Default) > 0 && len(target.Default) == 0 {
+			resolved := *target
+			resolved.Default = s.Default
+			target = &resolved
+		}
+		s = target
+	}
+	if s == nil {
+		return &openAPISchema{}
+	}
+	return s
+}
+
+// asMap returns the given value as a map, or an empty map if it is absent.
+func asMap(v interface{}, ok bool) (map[string]interface{}, bool) {
+	if !ok {
+		return map[string]interface{}{}, true
+	}
+	m, isMap := v.(map[string]interface{})
+	return m, isMap
+}
+
+// isDefault returns true if the JSON encoding of v equals the default value.
+func isDefault(v interface{}, def json.RawMessage) bool {
+	var expected interface{}
+	if err := json.Unmarshal(def, &expected); err != nil {
+		return false
+	}
+	a, err := json.Marshal(v)
+	if err != nil {
+		return false
+	}
+	b, err := json.Marshal(expected)
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(a, b)
+}
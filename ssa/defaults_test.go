@@ -0,0 +1,139 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ssa
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const testOpenAPIDocument = `{
+  "components": {
+    "schemas": {
+      "com.example.v1.Widget": {
+        "type": "object",
+        "x-kubernetes-group-version-kind": [{"group": "example.com", "version": "v1", "kind": "Widget"}],
+        "properties": {
+          "spec": {
+            "type": "object",
+            "properties": {
+              "replicas": {"type": "integer", "default": 1},
+              "name": {"type": "string"},
+              "strategy": {"allOf": [{"$ref": "#/components/schemas/com.example.v1.Strategy"}]},
+              "ports": {
+                "type": "array",
+                "items": {
+                  "type": "object",
+                  "properties": {
+                    "port": {"type": "integer"},
+                    "protocol": {"type": "string", "default": "TCP"}
+                  }
+                }
+              }
+            }
+          }
+        }
+      },
+      "com.example.v1.Strategy": {
+        "type": "object",
+        "properties": {
+          "type": {"type": "string", "default": "RollingUpdate"}
+        }
+      }
+    }
+  }
+}`
+
+func TestSchemaDefaults_Prune(t *testing.T) {
+	gvk := schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Widget"}
+
+	tests := []struct {
+		name    string
+		a       map[string]interface{}
+		b       map[string]interface{}
+		wantA   map[string]interface{}
+		wantB   map[string]interface{}
+		unknown bool
+	}{
+		{
+			name: "prunes defaults missing on one side",
+			a: map[string]interface{}{"spec": map[string]interface{}{
+				"name": "test",
+			}},
+			b: map[string]interface{}{"spec": map[string]interface{}{
+				"name":     "test",
+				"replicas": int64(1),
+				"strategy": map[string]interface{}{"type": "RollingUpdate"},
+			}},
+			wantA: map[string]interface{}{"spec": map[string]interface{}{"name": "test"}},
+			wantB: map[string]interface{}{"spec": map[string]interface{}{"name": "test"}},
+		},
+		{
+			name: "keeps non-default values",
+			a: map[string]interface{}{"spec": map[string]interface{}{
+				"replicas": int64(1),
+			}},
+			b: map[string]interface{}{"spec": map[string]interface{}{
+				"replicas": int64(3),
+			}},
+			wantA: map[string]interface{}{"spec": map[string]interface{}{"replicas": int64(1)}},
+			wantB: map[string]interface{}{"spec": map[string]interface{}{"replicas": int64(3)}},
+		},
+		{
+			name: "prunes defaults of list items",
+			a: map[string]interface{}{"spec": map[string]interface{}{
+				"ports": []interface{}{map[string]interface{}{"port": int64(80)}},
+			}},
+			b: map[string]interface{}{"spec": map[string]interface{}{
+				"ports": []interface{}{map[string]interface{}{"port": int64(80), "protocol": "TCP"}},
+			}},
+			wantA: map[string]interface{}{"spec": map[string]interface{}{
+				"ports": []interface{}{map[string]interface{}{"port": int64(80)}},
+			}},
+			wantB: map[string]interface{}{"spec": map[string]interface{}{
+				"ports": []interface{}{map[string]interface{}{"port": int64(80)}},
+			}},
+		},
+		{
+			name:    "ignores unknown kinds",
+			a:       map[string]interface{}{"spec": map[string]interface{}{}},
+			b:       map[string]interface{}{"spec": map[string]interface{}{"replicas": int64(1)}},
+			wantA:   map[string]interface{}{"spec": map[string]interface{}{}},
+			wantB:   map[string]interface{}{"spec": map[string]interface{}{"replicas": int64(1)}},
+			unknown: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			defaults := NewSchemaDefaults()
+			g.Expect(defaults.AddDocument([]byte(testOpenAPIDocument))).To(Succeed())
+
+			kind := gvk
+			if tt.unknown {
+				kind.Kind = "Unknown"
+			}
+			g.Expect(defaults.Prune(kind, tt.a, tt.b)).To(Equal(!tt.unknown))
+			g.Expect(tt.a).To(Equal(tt.wantA))
+			g.Expect(tt.b).To(Equal(tt.wantB))
+		})
+	}
+}
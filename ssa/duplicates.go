@@ -0,0 +1,135 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ssa
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/fluxcd/cli-utils/pkg/object"
+
+	ssaerrors "github.com/fluxcd/pkg/ssa/errors"
+	"github.com/fluxcd/pkg/ssa/utils"
+)
+
+// DuplicatePolicy defines how the objects defined more than once in a set
+// of objects are handled.
+type DuplicatePolicy string
+
+const (
+	// DuplicatePolicyError rejects the set of objects with a
+	// *errors.DuplicateObjectsErr listing the colliding documents.
+	DuplicatePolicyError DuplicatePolicy = "Error"
+
+	// DuplicatePolicyKeepLast keeps the last definition of each object.
+	DuplicatePolicyKeepLast DuplicatePolicy = "KeepLast"
+
+	// DuplicatePolicyMerge merges the definitions of each object in order,
+	// the maps are merged recursively and the other values of the later
+	// definitions replace the earlier ones.
+	DuplicatePolicyMerge DuplicatePolicy = "Merge"
+)
+
+// FindDuplicates returns the objects which are defined more than once in the
+// given set, identified by group, kind, namespace and name. The indexes of
+// the duplicates are the positions of the colliding objects in the set.
+func FindDuplicates(objects []*unstructured.Unstructured) []ssaerrors.DuplicateObject {
+	var ids []object.ObjMetadata
+	indexes := make(map[object.ObjMetadata][]int)
+	for i, obj := range objects {
+		id := object.UnstructuredToObjMetadata(obj)
+		if _, ok := indexes[id]; !ok {
+			ids = append(ids, id)
+		}
+		indexes[id] = append(indexes[id], i)
+	}
+
+	var duplicates []ssaerrors.DuplicateObject
+	for _, id := range ids {
+		if len(indexes[id]) > 1 {
+			duplicates = append(duplicates, ssaerrors.DuplicateObject{
+				Object:  utils.FmtObjMetadata(id),
+				Indexes: indexes[id],
+			})
+		}
+	}
+	return duplicates
+}
+
+// RemoveDuplicates returns the given set of objects with a single definition
+// of each object according to the policy, and the duplicates found in the set.
+// The remaining definition of an object takes the position of its last
+// definition in the set, and the given objects are not modified.
+// With DuplicatePolicyError, a *errors.DuplicateObjectsErr is returned if the
+// set contains duplicates.
+func RemoveDuplicates(objects []*unstructured.Unstructured, policy DuplicatePolicy) ([]*unstructured.Unstructured, []ssaerrors.DuplicateObject, error) {
+	duplicates := FindDuplicates(objects)
+	if len(duplicates) == 0 {
+		return objects, nil, nil
+	}
+
+	switch policy {
+	case DuplicatePolicyError:
+		return nil, duplicates, ssaerrors.NewDuplicateObjectsErr(duplicates...)
+	case DuplicatePolicyKeepLast, DuplicatePolicyMerge:
+	default:
+		return nil, duplicates, fmt.Errorf("unsupported duplicate policy '%s'", policy)
+	}
+
+	replace := make(map[int]*unstructured.Unstructured, len(duplicates))
+	drop := make(map[int]bool)
+	for _, d := range duplicates {
+		last := d.Indexes[len(d.Indexes)-1]
+		for _, i := range d.Indexes[:len(d.Indexes)-1] {
+			drop[i] = true
+		}
+		if policy == DuplicatePolicyMerge {
+			merged := objects[d.Indexes[0]].DeepCopy()
+			for _, i := range d.Indexes[1:] {
+				mergeValues(merged.Object, objects[i].DeepCopy().Object)
+			}
+			replace[last] = merged
+		}
+	}
+
+	result := make([]*unstructured.Unstructured, 0, len(objects)-len(drop))
+	for i, obj := range objects {
+		if drop[i] {
+			continue
+		}
+		if merged, ok := replace[i]; ok {
+			obj = merged
+		}
+		result = append(result, obj)
+	}
+	return result, duplicates, nil
+}
+
+// mergeValues merges the src map into dst recursively, the values of src
+// which are not maps replace the values of dst.
+func mergeValues(dst, src map[string]interface{}) {
+	for k, v := range src {
+		srcMap, srcOk := v.(map[string]interface{})
+		dstMap, dstOk := dst[k].(map[string]interface{})
+		if srcOk && dstOk {
+			mergeValues(dstMap, srcMap)
+			continue
+		}
+		dst[k] = v
+	}
+}
@@ -0,0 +1,118 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ssa
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	ssaerrors "github.com/fluxcd/pkg/ssa/errors"
+)
+
+func TestRemoveDuplicates(t *testing.T) {
+	configMap := func(name string, data map[string]interface{}) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "ConfigMap",
+			"metadata": map[string]interface{}{
+				"name":      name,
+				"namespace": "default",
+				"labels":    map[string]interface{}{"source": name},
+			},
+			"data": data,
+		}}
+	}
+	objects := []*unstructured.Unstructured{
+		configMap("a", map[string]interface{}{"a": "1", "b": "1"}),
+		configMap("b", map[string]interface{}{"a": "1"}),
+		configMap("a", map[string]interface{}{"b": "2"}),
+	}
+
+	t.Run("finds duplicates", func(t *testing.T) {
+		g := NewWithT(t)
+
+		duplicates := FindDuplicates(objects)
+		g.Expect(duplicates).To(Equal([]ssaerrors.DuplicateObject{
+			{Object: "ConfigMap/default/a", Indexes: []int{0, 2}},
+		}))
+		g.Expect(FindDuplicates(objects[:2])).To(BeEmpty())
+	})
+
+	t.Run("rejects duplicates", func(t *testing.T) {
+		g := NewWithT(t)
+
+		_, duplicates, err := RemoveDuplicates(objects, DuplicatePolicyError)
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("ConfigMap/default/a defined by documents 0, 2"))
+		g.Expect(duplicates).To(HaveLen(1))
+
+		var dupErr *ssaerrors.DuplicateObjectsErr
+		g.Expect(errors.As(err, &dupErr)).To(BeTrue())
+		g.Expect(dupErr.Duplicates()).To(Equal(duplicates))
+	})
+
+	t.Run("keeps the last definition", func(t *testing.T) {
+		g := NewWithT(t)
+
+		result, _, err := RemoveDuplicates(objects, DuplicatePolicyKeepLast)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(result).To(HaveLen(2))
+		g.Expect(result[0].GetName()).To(Equal("b"))
+		g.Expect(result[1]).To(BeIdenticalTo(objects[2]))
+	})
+
+	t.Run("merges the definitions", func(t *testing.T) {
+		g := NewWithT(t)
+
+		result, _, err := RemoveDuplicates(objects, DuplicatePolicyMerge)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(result).To(HaveLen(2))
+		data, _, _ := unstructured.NestedStringMap(result[1].Object, "data")
+		g.Expect(data).To(Equal(map[string]string{"a": "1", "b": "2"}))
+
+		// The given objects are not modified.
+		data, _, _ = unstructured.NestedStringMap(objects[0].Object, "data")
+		g.Expect(data).To(Equal(map[string]string{"a": "1", "b": "1"}))
+	})
+
+	t.Run("rejects unknown policies", func(t *testing.T) {
+		g := NewWithT(t)
+
+		_, _, err := RemoveDuplicates(objects, "First")
+		g.Expect(err).To(HaveOccurred())
+	})
+}
+
+func TestApplyAll_Duplicates(t *testing.T) {
+	g := NewWithT(t)
+
+	id := generateName("duplicates")
+	objects, err := readManifest("testdata/test1.yaml", id)
+	g.Expect(err).ToNot(HaveOccurred())
+	objects = append(objects, objects[0].DeepCopy())
+
+	opts := DefaultApplyOptions()
+	opts.Duplicates = DuplicatePolicyError
+	_, err = manager.ApplyAllStaged(context.Background(), objects, opts)
+	var dupErr *ssaerrors.DuplicateObjectsErr
+	g.Expect(errors.As(err, &dupErr)).To(BeTrue())
+	g.Expect(dupErr.Duplicates()[0].Indexes).To(Equal([]int{0, len(objects) - 1}))
+}
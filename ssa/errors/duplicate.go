@@ -0,0 +1,69 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package errors
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DuplicateObject describes the documents of a set of objects which define
+// the same object.
+type DuplicateObject struct {
+	// Object is the identity of the object, e.g. 'Deployment/default/app'.
+	Object string
+	// Indexes are the positions of the colliding documents in the set.
+	Indexes []int
+}
+
+// String returns a description of the duplicate object.
+func (d DuplicateObject) String() string {
+	indexes := make([]string, 0, len(d.Indexes))
+	for _, i := range d.Indexes {
+		indexes = append(indexes, fmt.Sprint(i))
+	}
+	return fmt.Sprintf("%s defined by documents %s", d.Object, strings.Join(indexes, ", "))
+}
+
+// DuplicateObjectsErr is returned by the duplicates pre-flight check when
+// a set of objects defines the same object more than once.
+type DuplicateObjectsErr struct {
+	duplicates []DuplicateObject
+}
+
+// NewDuplicateObjectsErr returns a new DuplicateObjectsErr, or nil if
+// there are no duplicates.
+func NewDuplicateObjectsErr(duplicates ...DuplicateObject) *DuplicateObjectsErr {
+	if len(duplicates) == 0 {
+		return nil
+	}
+	return &DuplicateObjectsErr{duplicates: duplicates}
+}
+
+// Duplicates returns the duplicate objects.
+func (e *DuplicateObjectsErr) Duplicates() []DuplicateObject {
+	return e.duplicates
+}
+
+// Error returns the error message.
+func (e *DuplicateObjectsErr) Error() string {
+	msgs := make([]string, 0, len(e.duplicates))
+	for _, d := range e.duplicates {
+		msgs = append(msgs, d.String())
+	}
+	return "duplicate objects found: " + strings.Join(msgs, "; ")
+}
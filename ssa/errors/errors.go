@@ -18,6 +18,7 @@ package errors
 
 import (
 	"fmt"
+	"strings"
 
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -76,3 +77,68 @@ func (e *DryRunErr) Error() string {
 func (e *DryRunErr) Unwrap() error {
 	return e.underlyingErr
 }
+
+// ApplyErr is an error that occurs while applying an object of a set.
+type ApplyErr struct {
+	underlyingErr  error
+	involvedObject *unstructured.Unstructured
+}
+
+// NewApplyErr returns a new ApplyErr.
+func NewApplyErr(err error, involvedObject *unstructured.Unstructured) *ApplyErr {
+	return &ApplyErr{
+		underlyingErr:  err,
+		involvedObject: involvedObject,
+	}
+}
+
+// InvolvedObject returns the involved object.
+func (e *ApplyErr) InvolvedObject() *unstructured.Unstructured {
+	return e.involvedObject
+}
+
+// Error returns the error message.
+func (e *ApplyErr) Error() string {
+	return e.underlyingErr.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *ApplyErr) Unwrap() error {
+	return e.underlyingErr
+}
+
+// MultiApplyErr aggregates the errors that occur while applying a set of objects.
+type MultiApplyErr struct {
+	errs []*ApplyErr
+}
+
+// NewMultiApplyErr returns a new MultiApplyErr, or nil if there are no errors.
+func NewMultiApplyErr(errs ...*ApplyErr) *MultiApplyErr {
+	if len(errs) == 0 {
+		return nil
+	}
+	return &MultiApplyErr{errs: errs}
+}
+
+// Errors returns the errors of the individual objects.
+func (e *MultiApplyErr) Errors() []*ApplyErr {
+	return e.errs
+}
+
+// Error returns the error message.
+func (e *MultiApplyErr) Error() string {
+	msgs := make([]string, 0, len(e.errs))
+	for _, err := range e.errs {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Unwrap returns the errors of the individual objects.
+func (e *MultiApplyErr) Unwrap() []error {
+	errs := make([]error, 0, len(e.errs))
+	for _, err := range e.errs {
+		errs = append(errs, err)
+	}
+	return errs
+}
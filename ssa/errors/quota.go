@@ -0,0 +1,77 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package errors
+
+import (
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// QuotaExceeded describes a resource of a ResourceQuota which would be
+// exceeded by applying a set of objects.
+type QuotaExceeded struct {
+	// Namespace is the namespace of the ResourceQuota.
+	Namespace string
+	// Name is the name of the ResourceQuota.
+	Name string
+	// Resource is the name of the exceeded resource, e.g. 'requests.cpu'.
+	Resource corev1.ResourceName
+	// Hard is the limit enforced by the ResourceQuota.
+	Hard resource.Quantity
+	// Used is the current usage recorded by the ResourceQuota.
+	Used resource.Quantity
+	// Requested is the additional amount requested by the objects.
+	Requested resource.Quantity
+}
+
+// String returns a description of the exceeded quota.
+func (q QuotaExceeded) String() string {
+	return fmt.Sprintf("ResourceQuota/%s/%s %s: requested %s, used %s, limited %s",
+		q.Namespace, q.Name, q.Resource, q.Requested.String(), q.Used.String(), q.Hard.String())
+}
+
+// QuotaExceededErr is returned by the quota pre-flight check when applying
+// the objects would exceed the ResourceQuotas of their namespaces.
+type QuotaExceededErr struct {
+	exceeded []QuotaExceeded
+}
+
+// NewQuotaExceededErr returns a new QuotaExceededErr, or nil if no quotas
+// are exceeded.
+func NewQuotaExceededErr(exceeded ...QuotaExceeded) *QuotaExceededErr {
+	if len(exceeded) == 0 {
+		return nil
+	}
+	return &QuotaExceededErr{exceeded: exceeded}
+}
+
+// Exceeded returns the exceeded quotas.
+func (e *QuotaExceededErr) Exceeded() []QuotaExceeded {
+	return e.exceeded
+}
+
+// Error returns the error message.
+func (e *QuotaExceededErr) Error() string {
+	msgs := make([]string, 0, len(e.exceeded))
+	for _, q := range e.exceeded {
+		msgs = append(msgs, q.String())
+	}
+	return "resource quotas exceeded: " + strings.Join(msgs, "; ")
+}
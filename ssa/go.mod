@@ -11,10 +11,12 @@ require (
 	github.com/fluxcd/cli-utils v0.36.0-flux.2
 	github.com/google/go-cmp v0.6.0
 	github.com/onsi/gomega v1.30.0
+	github.com/pmezard/go-difflib v1.0.0
 	// TODO: unpin when https://github.com/wI2L/jsondiff/pull/14 has ended up in a release.
 	github.com/wI2L/jsondiff v0.4.1-0.20230626084051-c85fb8ce3cac
 	golang.org/x/sync v0.5.0
 	k8s.io/api v0.28.4
+	k8s.io/apiextensions-apiserver v0.28.4
 	k8s.io/apimachinery v0.28.4
 	k8s.io/client-go v0.28.4
 	k8s.io/utils v0.0.0-20231127182322-b307cd553661
@@ -76,7 +78,6 @@ require (
 	gopkg.in/inf.v0 v0.9.1 // indirect
 	gopkg.in/yaml.v2 v2.4.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
-	k8s.io/apiextensions-apiserver v0.28.4 // indirect
 	k8s.io/cli-runtime v0.28.4 // indirect
 	k8s.io/component-base v0.28.4 // indirect
 	k8s.io/klog/v2 v2.100.1 // indirect
@@ -0,0 +1,77 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ssa
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/fluxcd/pkg/ssa/jsondiff"
+	"github.com/fluxcd/pkg/ssa/utils"
+)
+
+// ignoredPaths returns the JSON pointers of the ignore rules matching the given object,
+// and true if the object is to be ignored entirely.
+func ignoredPaths(object *unstructured.Unstructured, rules []jsondiff.IgnoreRule) ([]string, bool, error) {
+	var paths []string
+	for _, rule := range rules {
+		sr, err := jsondiff.NewSelectorRegex(rule.Selector)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to create ignore rule selector: %w", err)
+		}
+		if !sr.MatchUnstructured(object) {
+			continue
+		}
+		for _, p := range rule.Paths {
+			if p == jsondiff.IgnorePathRoot {
+				return nil, true, nil
+			}
+			paths = append(paths, p)
+		}
+	}
+	return paths, false, nil
+}
+
+// withoutPaths returns a copy of the given object without the given JSON pointers,
+// or the object itself if there are no paths to remove.
+func withoutPaths(object *unstructured.Unstructured, paths []string) (*unstructured.Unstructured, error) {
+	if len(paths) == 0 {
+		return object, nil
+	}
+
+	result := object.DeepCopy()
+	if err := jsondiff.ApplyPatchToUnstructured(result, jsondiff.GenerateRemovePatch(paths...)); err != nil {
+		return nil, fmt.Errorf("%s failed to remove ignored paths: %w", utils.FmtUnstructured(object), err)
+	}
+	return result, nil
+}
+
+// hasDriftedWithout detects drift between the given objects, excluding the given JSON pointers
+// and annotation keys from the comparison.
+func (m *ResourceManager) hasDriftedWithout(existingObject, dryRunObject *unstructured.Unstructured,
+	paths []string, ignoredAnnotations ...string) (bool, error) {
+	existingObject, err := withoutPaths(existingObject, paths)
+	if err != nil {
+		return false, err
+	}
+	dryRunObject, err = withoutPaths(dryRunObject, paths)
+	if err != nil {
+		return false, err
+	}
+	return m.hasDrifted(existingObject, dryRunObject, ignoredAnnotations...), nil
+}
@@ -0,0 +1,165 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ssa
+
+import (
+	"fmt"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/fluxcd/cli-utils/pkg/object"
+
+	"github.com/fluxcd/pkg/ssa/utils"
+)
+
+// InventoryEntry is the representation of an object in the inventory of a
+// Flux controller, compatible with the ResourceRef format used by
+// kustomize-controller.
+type InventoryEntry struct {
+	// ID is the string representation of the object metadata, in the
+	// format '<namespace>_<name>_<group>_<kind>'.
+	ID string `json:"id"`
+
+	// Version is the API version of the object kind.
+	Version string `json:"v"`
+}
+
+// ObjMetadata parses the ID of the inventory entry.
+func (e InventoryEntry) ObjMetadata() (object.ObjMetadata, error) {
+	meta, err := object.ParseObjMetadata(e.ID)
+	if err != nil {
+		return object.ObjMetadata{}, fmt.Errorf("invalid inventory entry '%s': %w", e.ID, err)
+	}
+	return meta, nil
+}
+
+// GroupVersionKind returns the group, version and kind of the inventory entry.
+func (e InventoryEntry) GroupVersionKind() (schema.GroupVersionKind, error) {
+	meta, err := e.ObjMetadata()
+	if err != nil {
+		return schema.GroupVersionKind{}, err
+	}
+	return meta.GroupKind.WithVersion(e.Version), nil
+}
+
+// GroupVersionKind returns the group, version and kind of the entry.
+// The GroupVersion of the entry may either hold the version of the object,
+// or its API version.
+func (e ChangeSetEntry) GroupVersionKind() schema.GroupVersionKind {
+	gv, err := schema.ParseGroupVersion(e.GroupVersion)
+	if err != nil || gv.Group == "" {
+		return e.ObjMetadata.GroupKind.WithVersion(gv.Version)
+	}
+	return gv.WithKind(e.ObjMetadata.GroupKind.Kind)
+}
+
+// InventoryEntry returns the inventory entry of the object.
+func (e ChangeSetEntry) InventoryEntry() InventoryEntry {
+	return InventoryEntry{
+		ID:      e.ObjMetadata.String(),
+		Version: e.GroupVersionKind().Version,
+	}
+}
+
+// ObjectReference returns a reference to the object.
+func (e ChangeSetEntry) ObjectReference() corev1.ObjectReference {
+	apiVersion, kind := e.GroupVersionKind().ToAPIVersionAndKind()
+	return corev1.ObjectReference{
+		APIVersion: apiVersion,
+		Kind:       kind,
+		Namespace:  e.ObjMetadata.Namespace,
+		Name:       e.ObjMetadata.Name,
+	}
+}
+
+// ToInventoryEntries returns the inventory entries of the ChangeSet,
+// sorted by ID.
+func (c *ChangeSet) ToInventoryEntries() []InventoryEntry {
+	res := make([]InventoryEntry, 0, len(c.Entries))
+	for _, entry := range c.Entries {
+		res = append(res, entry.InventoryEntry())
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].ID < res[j].ID
+	})
+	return res
+}
+
+// ToObjectReferences returns the references to the objects of the ChangeSet.
+func (c *ChangeSet) ToObjectReferences() []corev1.ObjectReference {
+	res := make([]corev1.ObjectReference, 0, len(c.Entries))
+	for _, entry := range c.Entries {
+		res = append(res, entry.ObjectReference())
+	}
+	return res
+}
+
+// NewChangeSetEntry returns a ChangeSetEntry for the object with the given
+// group, version and kind, namespace and name.
+func NewChangeSetEntry(gvk schema.GroupVersionKind, namespace, name string, action Action) ChangeSetEntry {
+	meta := object.ObjMetadata{
+		GroupKind: gvk.GroupKind(),
+		Namespace: namespace,
+		Name:      name,
+	}
+	return ChangeSetEntry{
+		ObjMetadata:  meta,
+		GroupVersion: gvk.Version,
+		Subject:      utils.FmtObjMetadata(meta),
+		Action:       action,
+	}
+}
+
+// ChangeSetEntryFromInventoryEntry returns the ChangeSetEntry for the given
+// inventory entry, or an error if its ID can not be parsed.
+func ChangeSetEntryFromInventoryEntry(e InventoryEntry, action Action) (ChangeSetEntry, error) {
+	gvk, err := e.GroupVersionKind()
+	if err != nil {
+		return ChangeSetEntry{}, err
+	}
+	meta, _ := e.ObjMetadata()
+	return NewChangeSetEntry(gvk, meta.Namespace, meta.Name, action), nil
+}
+
+// ChangeSetEntryFromObjectReference returns the ChangeSetEntry for the given
+// object reference, or an error if its API version can not be parsed.
+func ChangeSetEntryFromObjectReference(ref corev1.ObjectReference, action Action) (ChangeSetEntry, error) {
+	gv, err := schema.ParseGroupVersion(ref.APIVersion)
+	if err != nil {
+		return ChangeSetEntry{}, fmt.Errorf("invalid API version of object reference '%s/%s': %w", ref.Kind, ref.Name, err)
+	}
+	if ref.Kind == "" || ref.Name == "" {
+		return ChangeSetEntry{}, fmt.Errorf("invalid object reference: kind and name are required")
+	}
+	return NewChangeSetEntry(gv.WithKind(ref.Kind), ref.Namespace, ref.Name, action), nil
+}
+
+// ChangeSetFromInventoryEntries returns a ChangeSet with an entry for every
+// inventory entry, or an error if any of them can not be parsed.
+func ChangeSetFromInventoryEntries(entries []InventoryEntry, action Action) (*ChangeSet, error) {
+	cs := NewChangeSet()
+	for _, e := range entries {
+		entry, err := ChangeSetEntryFromInventoryEntry(e, action)
+		if err != nil {
+			return nil, err
+		}
+		cs.Add(entry)
+	}
+	return cs, nil
+}
@@ -0,0 +1,97 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ssa
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestChangeSet_ToInventoryEntries(t *testing.T) {
+	cs := NewChangeSet()
+	cs.Add(NewChangeSetEntry(schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}, "default", "app", CreatedAction))
+	cs.Add(NewChangeSetEntry(schema.GroupVersionKind{Version: "v1", Kind: "Namespace"}, "", "default", UnchangedAction))
+	cs.Add(NewChangeSetEntry(schema.GroupVersionKind{Group: "rbac.authorization.k8s.io", Version: "v1", Kind: "ClusterRole"}, "", "system:test", CreatedAction))
+
+	want := []InventoryEntry{
+		{ID: "_default__Namespace", Version: "v1"},
+		{ID: "_system__test_rbac.authorization.k8s.io_ClusterRole", Version: "v1"},
+		{ID: "default_app_apps_Deployment", Version: "v1"},
+	}
+	entries := cs.ToInventoryEntries()
+	if diff := cmp.Diff(want, entries); diff != "" {
+		t.Errorf("ToInventoryEntries() mismatch (-want +got):\n%s", diff)
+	}
+
+	got, err := ChangeSetFromInventoryEntries(entries, UnknownAction)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, entry := range got.Entries {
+		if entry.InventoryEntry() != want[i] {
+			t.Errorf("entry %d: got %v, want %v", i, entry.InventoryEntry(), want[i])
+		}
+		if entry.Action != UnknownAction {
+			t.Errorf("entry %d: got action %s, want %s", i, entry.Action, UnknownAction)
+		}
+	}
+	if got.Entries[1].Subject != "ClusterRole/system:test" {
+		t.Errorf("got subject %s", got.Entries[1].Subject)
+	}
+
+	if _, err := ChangeSetFromInventoryEntries([]InventoryEntry{{ID: "invalid", Version: "v1"}}, UnknownAction); err == nil {
+		t.Error("expected error for invalid inventory entry")
+	}
+}
+
+func TestChangeSetEntry_ObjectReference(t *testing.T) {
+	tests := []struct {
+		name  string
+		entry ChangeSetEntry
+		want  corev1.ObjectReference
+	}{
+		{
+			name:  "group version",
+			entry: NewChangeSetEntry(schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}, "default", "app", CreatedAction),
+			want:  corev1.ObjectReference{APIVersion: "apps/v1", Kind: "Deployment", Namespace: "default", Name: "app"},
+		},
+		{
+			name:  "core group",
+			entry: NewChangeSetEntry(schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}, "default", "cm", CreatedAction),
+			want:  corev1.ObjectReference{APIVersion: "v1", Kind: "ConfigMap", Namespace: "default", Name: "cm"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ref := tt.entry.ObjectReference()
+			if diff := cmp.Diff(tt.want, ref); diff != "" {
+				t.Errorf("ObjectReference() mismatch (-want +got):\n%s", diff)
+			}
+
+			entry, err := ChangeSetEntryFromObjectReference(ref, tt.entry.Action)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.entry, entry); diff != "" {
+				t.Errorf("ChangeSetEntryFromObjectReference() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -18,7 +18,11 @@ limitations under the License.
 package ssa
 
 import (
+	"context"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/fluxcd/cli-utils/pkg/kstatus/polling"
@@ -33,6 +37,16 @@ type ResourceManager struct {
 	poller      *polling.StatusPoller
 	owner       Owner
 	concurrency int
+
+	// partialMetadata fetches only the metadata of in-cluster objects
+	// when checking for their existence.
+	partialMetadata bool
+
+	// approvalPolicy is consulted before performing destructive actions.
+	approvalPolicy ApprovalPolicy
+
+	// schemaDefaults is used to ignore server-populated defaults in drift detection.
+	schemaDefaults *SchemaDefaults
 }
 
 // NewResourceManager creates a ResourceManager for the given Kubernetes client.
@@ -58,6 +72,21 @@ func (m *ResourceManager) SetConcurrency(c int) {
 	m.concurrency = c
 }
 
+// SetPartialObjectMetadata configures whether only the metadata of in-cluster objects is fetched
+// when checking for their existence in Delete, DeleteAll and WaitForTermination.
+// This reduces the memory usage when the objects are large, e.g. ConfigMaps or CRDs.
+func (m *ResourceManager) SetPartialObjectMetadata(enabled bool) {
+	m.partialMetadata = enabled
+}
+
+// SetSchemaDefaults configures drift detection to ignore the fields set to the default value
+// of their OpenAPI schema, so that objects which only differ by server-populated defaults
+// are reported as unchanged. This is useful for custom resources which are not covered by
+// the normalize package.
+func (m *ResourceManager) SetSchemaDefaults(defaults *SchemaDefaults) {
+	m.schemaDefaults = defaults
+}
+
 // SetOwnerLabels adds the ownership labels to the given objects.
 // The ownership labels are in the format:
 //
@@ -85,6 +114,33 @@ func (m *ResourceManager) GetOwnerLabels(name, namespace string) map[string]stri
 	}
 }
 
+// getExisting returns the in-cluster object matching the given object.
+// If partial object metadata is enabled, the returned object only contains
+// the type and object metadata.
+func (m *ResourceManager) getExisting(ctx context.Context, object *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	existingObject := &unstructured.Unstructured{}
+	existingObject.SetGroupVersionKind(object.GroupVersionKind())
+	if !m.partialMetadata {
+		if err := m.client.Get(ctx, client.ObjectKeyFromObject(object), existingObject); err != nil {
+			return nil, err
+		}
+		return existingObject, nil
+	}
+
+	meta := &metav1.PartialObjectMetadata{}
+	meta.SetGroupVersionKind(object.GroupVersionKind())
+	if err := m.client.Get(ctx, client.ObjectKeyFromObject(object), meta); err != nil {
+		return nil, err
+	}
+	u, err := runtime.DefaultUnstructuredConverter.ToUnstructured(meta)
+	if err != nil {
+		return nil, err
+	}
+	existingObject.Object = u
+	existingObject.SetGroupVersionKind(object.GroupVersionKind())
+	return existingObject, nil
+}
+
 func (m *ResourceManager) changeSetEntry(o *unstructured.Unstructured, action Action) *ChangeSetEntry {
 	return &ChangeSetEntry{
 		ObjMetadata:  object.UnstructuredToObjMetadata(o),
@@ -33,6 +33,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	ssaerrors "github.com/fluxcd/pkg/ssa/errors"
+	"github.com/fluxcd/pkg/ssa/jsondiff"
 	"github.com/fluxcd/pkg/ssa/utils"
 )
 
@@ -63,6 +64,49 @@ type ApplyOptions struct {
 
 	// Cleanup defines which in-cluster metadata entries are to be removed before applying objects.
 	Cleanup ApplyCleanupOptions `json:"cleanup"`
+
+	// Annotations defines the 'metadata.annotations' entries to be set on the applied objects,
+	// e.g. the source revision, the checksum of the manifests and the reconcile time.
+	// The annotations are excluded from drift detection, hence a change of their values alone
+	// does not result in an object being applied, and they are only updated when an object is
+	// created or configured.
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	// ContinueOnError configures ApplyAll and ApplyAllStaged to continue applying the set of objects
+	// when an object fails to apply. The errors are aggregated in a *errors.MultiApplyErr which holds
+	// the error of each failed object, and the change set contains only the successful objects.
+	ContinueOnError bool `json:"continueOnError,omitempty"`
+
+	// IgnorePaths defines the JSON pointers (RFC 6901) to be excluded from both drift detection
+	// and the applied objects, for the objects matching the selector of each rule. For example,
+	// '/spec/replicas' for Deployments managed by a HorizontalPodAutoscaler. A rule containing
+	// the root path excludes the matching objects from apply entirely.
+	// Note that removing a path from the applied object drops its field ownership, hence the field
+	// is removed from the in-cluster object unless it is also owned by another field manager.
+	IgnorePaths []jsondiff.IgnoreRule `json:"ignorePaths,omitempty"`
+
+	// Mutators defines the chain of transformations performed in order on a copy of each object
+	// before the server-side dry-run, e.g. to set default labels or rewrite container images.
+	// The given objects are not modified, and the change set reflects the mutated objects.
+	Mutators []Mutator `json:"-"`
+
+	// CheckQuotas configures ApplyAll and ApplyAllStaged to sum the resources requested by the
+	// Pod templates of the objects and compare them against the ResourceQuotas of their namespaces
+	// before applying. When a quota would be exceeded, ApplyAll returns a *errors.QuotaExceededErr
+	// listing the exceeded quotas without applying the objects.
+	CheckQuotas bool `json:"checkQuotas,omitempty"`
+
+	// CreateNamespace configures Apply, ApplyAll and ApplyAllStaged to create the namespaces of
+	// the namespaced objects before applying them, when the namespaces don't exist in-cluster and
+	// are not part of the applied objects. The namespaces are created with the given metadata,
+	// and a Created entry is recorded in the change set for each of them.
+	CreateNamespace *CreateNamespaceOptions `json:"createNamespace,omitempty"`
+
+	// Duplicates configures ApplyAll and ApplyAllStaged to check the objects for duplicate
+	// definitions before applying them, and to handle them according to the policy, see
+	// RemoveDuplicates. The indexes of the duplicates refer to the given objects after the
+	// expansion of the List objects. When empty, the objects are not checked for duplicates.
+	Duplicates DuplicatePolicy `json:"duplicates,omitempty"`
 }
 
 // ApplyCleanupOptions defines which metadata entries are to be removed before applying objects.
@@ -95,6 +139,15 @@ func DefaultApplyOptions() ApplyOptions {
 // Drift detection is performed by comparing the server-side dry-run result with the existing object.
 // When immutable field changes are detected, the object is recreated if 'force' is set to 'true'.
 func (m *ResourceManager) Apply(ctx context.Context, object *unstructured.Unstructured, opts ApplyOptions) (*ChangeSetEntry, error) {
+	object, err := mutate(object, opts.Mutators)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := m.createNamespaces(ctx, []*unstructured.Unstructured{object}, ApplyOptions{CreateNamespace: opts.CreateNamespace}); err != nil {
+		return nil, err
+	}
+
 	existingObject := &unstructured.Unstructured{}
 	existingObject.SetGroupVersionKind(object.GroupVersionKind())
 	getError := m.client.Get(ctx, client.ObjectKeyFromObject(object), existingObject)
@@ -103,30 +156,72 @@ func (m *ResourceManager) Apply(ctx context.Context, object *unstructured.Unstru
 		return m.changeSetEntry(object, SkippedAction), nil
 	}
 
+	paths, ignored, err := ignoredPaths(object, opts.IgnorePaths)
+	if err != nil {
+		return nil, err
+	}
+	if ignored {
+		return m.changeSetEntry(object, SkippedAction), nil
+	}
+
+	object, err = withoutPaths(withAnnotations(object, opts.Annotations), paths)
+	if err != nil {
+		return nil, err
+	}
 	dryRunObject := object.DeepCopy()
 	if err := m.dryRunApply(ctx, dryRunObject); err != nil {
 		if !errors.IsNotFound(getError) && m.shouldForceApply(object, existingObject, opts, err) {
+			ok, approvalErr := m.approved(ctx, ApprovalRequest{Object: existingObject, Action: DeletedAction, Desired: object})
+			if approvalErr != nil {
+				return nil, approvalErr
+			}
+			if !ok {
+				return m.changeSetEntry(existingObject, PendingAction), nil
+			}
+
 			if err := m.client.Delete(ctx, existingObject, client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil && !errors.IsNotFound(err) {
 				return nil, fmt.Errorf("%s immutable field detected, failed to delete object: %w",
 					utils.FmtUnstructured(dryRunObject), err)
 			}
+			// the object has already been mutated
+			opts.Mutators = nil
 			return m.Apply(ctx, object, opts)
 		}
 
 		return nil, ssaerrors.NewDryRunErr(err, dryRunObject)
 	}
 
-	patched, err := m.cleanupMetadata(ctx, object, existingObject, opts.Cleanup)
+	patches, err := m.cleanupMetadataPatches(object, existingObject, opts.Cleanup)
 	if err != nil {
 		return nil, fmt.Errorf("%s metadata.managedFields cleanup failed: %w",
 			utils.FmtUnstructured(existingObject), err)
 	}
 
+	drifted, err := m.hasDriftedWithout(existingObject, dryRunObject, paths, annotationKeys(opts.Annotations)...)
+	if err != nil {
+		return nil, err
+	}
+
 	// do not apply objects that have not drifted to avoid bumping the resource version
-	if !patched && !m.hasDrifted(existingObject, dryRunObject) {
+	if len(patches) == 0 && !drifted {
 		return m.changeSetEntry(object, UnchangedAction), nil
 	}
 
+	if dryRunObject.GetResourceVersion() != "" {
+		ok, err := m.approved(ctx, ApprovalRequest{Object: existingObject, Action: ConfiguredAction, Desired: dryRunObject})
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return m.changeSetEntry(existingObject, PendingAction), nil
+		}
+	}
+
+	if err := m.cleanupMetadata(ctx, existingObject, patches); err != nil {
+		return nil, fmt.Errorf("%s metadata.managedFields cleanup failed: %w",
+			utils.FmtUnstructured(existingObject), err)
+	}
+
 	appliedObject := object.DeepCopy()
 	if err := m.apply(ctx, appliedObject); err != nil {
 		return nil, fmt.Errorf("%s apply failed: %w", utils.FmtUnstructured(appliedObject), err)
@@ -141,14 +236,39 @@ func (m *ResourceManager) Apply(ctx context.Context, object *unstructured.Unstru
 
 // ApplyAll performs a server-side dry-run of the given objects, and based on the diff result,
 // it applies the objects that are new or modified.
+// If ContinueOnError is set, the objects which fail to apply don't prevent the others from being applied;
+// the change set of the successful objects is returned along with a *errors.MultiApplyErr.
 func (m *ResourceManager) ApplyAll(ctx context.Context, objects []*unstructured.Unstructured, opts ApplyOptions) (*ChangeSet, error) {
+	objects, err := utils.ExpandLists(objects)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Duplicates != "" {
+		if objects, _, err = RemoveDuplicates(objects, opts.Duplicates); err != nil {
+			return nil, err
+		}
+	}
+
 	sort.Sort(SortableUnstructureds(objects))
 
+	if opts.CheckQuotas {
+		if err := m.checkQuotas(ctx, objects, opts.Mutators); err != nil {
+			return nil, err
+		}
+	}
+
+	namespaces, err := m.createNamespaces(ctx, objects, opts)
+	if err != nil {
+		return nil, err
+	}
+
 	// Results are written to the following arrays from the concurrent goroutines. We use arrays
 	// to avoid complex synchronization. toApply is sparse, slots are only popuplated when there
 	// is an object to apply
 	toApply := make([]*unstructured.Unstructured, len(objects))
 	changes := make([]ChangeSetEntry, len(objects))
+	applyErrs := make([]*ssaerrors.ApplyErr, len(objects))
 
 	{
 		g, ctx := errgroup.WithContext(ctx)
@@ -157,66 +277,16 @@ func (m *ResourceManager) ApplyAll(ctx context.Context, objects []*unstructured.
 			i, object := i, object
 
 			g.Go(func() error {
-				existingObject := &unstructured.Unstructured{}
-				existingObject.SetGroupVersionKind(object.GroupVersionKind())
-				getError := m.client.Get(ctx, client.ObjectKeyFromObject(object), existingObject)
-
-				if m.shouldSkipApply(object, existingObject, opts) {
-					changes[i] = *m.changeSetEntry(existingObject, SkippedAction)
-					return nil
-				}
-
-				dryRunObject := object.DeepCopy()
-				if err := m.dryRunApply(ctx, dryRunObject); err != nil {
-					// We cannot have an immutable error (and therefore shouldn't force-apply) if the resource doesn't
-					// exist on the cluster. Note that resource might not exist because we wrongly identified an error
-					// as immutable and deleted it when ApplyAll was called the last time (the check for ImmutableError
-					// returns false positives)
-					if !errors.IsNotFound(getError) && m.shouldForceApply(object, existingObject, opts, err) {
-						if err := m.client.Delete(ctx, existingObject, client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil && !errors.IsNotFound(err) {
-							return fmt.Errorf("%s immutable field detected, failed to delete object: %w",
-								utils.FmtUnstructured(dryRunObject), err)
-						}
-
-						// Wait until deleted (in case of any finalizers).
-						err = wait.PollUntilContextCancel(ctx, opts.WaitInterval, true, func(ctx context.Context) (bool, error) {
-							err := m.client.Get(ctx, client.ObjectKeyFromObject(object), existingObject)
-							if err != nil && errors.IsNotFound(err) {
-								// Object has been deleted.
-								return true, nil
-							}
-							// Object still exists, or we got another error than NotFound.
-							return false, err
-						})
-						if err != nil {
-							return fmt.Errorf("%s immutable field detected, failed to wait for object to be deleted: %w",
-								utils.FmtUnstructured(dryRunObject), err)
-						}
-
-						err = m.dryRunApply(ctx, dryRunObject)
-					}
-
-					if err != nil {
-						return ssaerrors.NewDryRunErr(err, dryRunObject)
-					}
-				}
-
-				patched, err := m.cleanupMetadata(ctx, object, existingObject, opts.Cleanup)
+				obj, entry, err := m.dryRunApplyAllEntry(ctx, object, opts)
 				if err != nil {
-					return fmt.Errorf("%s metadata.managedFields cleanup failed: %w",
-						utils.FmtUnstructured(existingObject), err)
-				}
-
-				if patched || m.hasDrifted(existingObject, dryRunObject) {
-					toApply[i] = object
-					if dryRunObject.GetResourceVersion() == "" {
-						changes[i] = *m.changeSetEntry(dryRunObject, CreatedAction)
-					} else {
-						changes[i] = *m.changeSetEntry(dryRunObject, ConfiguredAction)
+					if opts.ContinueOnError {
+						applyErrs[i] = ssaerrors.NewApplyErr(err, object)
+						return nil
 					}
-				} else {
-					changes[i] = *m.changeSetEntry(dryRunObject, UnchangedAction)
+					return err
 				}
+				toApply[i] = obj
+				changes[i] = *entry
 				return nil
 			})
 		}
@@ -226,26 +296,162 @@ func (m *ResourceManager) ApplyAll(ctx context.Context, objects []*unstructured.
 		}
 	}
 
-	for _, object := range toApply {
+	for i, object := range toApply {
 		if object != nil {
 			appliedObject := object.DeepCopy()
 			if err := m.apply(ctx, appliedObject); err != nil {
-				return nil, fmt.Errorf("%s apply failed: %w", utils.FmtUnstructured(appliedObject), err)
+				err = fmt.Errorf("%s apply failed: %w", utils.FmtUnstructured(appliedObject), err)
+				if !opts.ContinueOnError {
+					return nil, err
+				}
+				applyErrs[i] = ssaerrors.NewApplyErr(err, objects[i])
 			}
 		}
 	}
 
 	changeSet := NewChangeSet()
-	changeSet.Append(changes)
+	changeSet.Append(namespaces)
+	var errs []*ssaerrors.ApplyErr
+	for i := range changes {
+		if applyErrs[i] != nil {
+			errs = append(errs, applyErrs[i])
+			continue
+		}
+		changeSet.Add(changes[i])
+	}
 
+	if len(errs) > 0 {
+		return changeSet, ssaerrors.NewMultiApplyErr(errs...)
+	}
 	return changeSet, nil
 }
 
+// dryRunApplyAllEntry performs the server-side dry-run apply of an object of ApplyAll. It returns the object
+// to apply if it has drifted, and the resulting change set entry.
+func (m *ResourceManager) dryRunApplyAllEntry(ctx context.Context, object *unstructured.Unstructured, opts ApplyOptions) (*unstructured.Unstructured, *ChangeSetEntry, error) {
+	object, err := mutate(object, opts.Mutators)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	existingObject := &unstructured.Unstructured{}
+	existingObject.SetGroupVersionKind(object.GroupVersionKind())
+	getError := m.client.Get(ctx, client.ObjectKeyFromObject(object), existingObject)
+
+	if m.shouldSkipApply(object, existingObject, opts) {
+		return nil, m.changeSetEntry(existingObject, SkippedAction), nil
+	}
+
+	paths, ignored, err := ignoredPaths(object, opts.IgnorePaths)
+	if err != nil {
+		return nil, nil, err
+	}
+	if ignored {
+		return nil, m.changeSetEntry(object, SkippedAction), nil
+	}
+
+	object, err = withoutPaths(withAnnotations(object, opts.Annotations), paths)
+	if err != nil {
+		return nil, nil, err
+	}
+	dryRunObject := object.DeepCopy()
+	if err := m.dryRunApply(ctx, dryRunObject); err != nil {
+		// We cannot have an immutable error (and therefore shouldn't force-apply) if the resource doesn't
+		// exist on the cluster. Note that resource might not exist because we wrongly identified an error
+		// as immutable and deleted it when ApplyAll was called the last time (the check for ImmutableError
+		// returns false positives)
+		if !errors.IsNotFound(getError) && m.shouldForceApply(object, existingObject, opts, err) {
+			ok, approvalErr := m.approved(ctx, ApprovalRequest{Object: existingObject, Action: DeletedAction, Desired: object})
+			if approvalErr != nil {
+				return nil, nil, approvalErr
+			}
+			if !ok {
+				return nil, m.changeSetEntry(existingObject, PendingAction), nil
+			}
+
+			if err := m.client.Delete(ctx, existingObject, client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil && !errors.IsNotFound(err) {
+				return nil, nil, fmt.Errorf("%s immutable field detected, failed to delete object: %w",
+					utils.FmtUnstructured(dryRunObject), err)
+			}
+
+			// Wait until deleted (in case of any finalizers).
+			err = wait.PollUntilContextCancel(ctx, opts.WaitInterval, true, func(ctx context.Context) (bool, error) {
+				err := m.client.Get(ctx, client.ObjectKeyFromObject(object), existingObject)
+				if err != nil && errors.IsNotFound(err) {
+					// Object has been deleted.
+					return true, nil
+				}
+				// Object still exists, or we got another error than NotFound.
+				return false, err
+			})
+			if err != nil {
+				return nil, nil, fmt.Errorf("%s immutable field detected, failed to wait for object to be deleted: %w",
+					utils.FmtUnstructured(dryRunObject), err)
+			}
+
+			err = m.dryRunApply(ctx, dryRunObject)
+		}
+
+		if err != nil {
+			return nil, nil, ssaerrors.NewDryRunErr(err, dryRunObject)
+		}
+	}
+
+	patches, err := m.cleanupMetadataPatches(object, existingObject, opts.Cleanup)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s metadata.managedFields cleanup failed: %w",
+			utils.FmtUnstructured(existingObject), err)
+	}
+
+	drifted, err := m.hasDriftedWithout(existingObject, dryRunObject, paths, annotationKeys(opts.Annotations)...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(patches) == 0 && !drifted {
+		return nil, m.changeSetEntry(dryRunObject, UnchangedAction), nil
+	}
+
+	action := CreatedAction
+	if dryRunObject.GetResourceVersion() != "" {
+		ok, err := m.approved(ctx, ApprovalRequest{Object: existingObject, Action: ConfiguredAction, Desired: dryRunObject})
+		if err != nil {
+			return nil, nil, err
+		}
+		if !ok {
+			return nil, m.changeSetEntry(existingObject, PendingAction), nil
+		}
+		action = ConfiguredAction
+	}
+
+	if err := m.cleanupMetadata(ctx, existingObject, patches); err != nil {
+		return nil, nil, fmt.Errorf("%s metadata.managedFields cleanup failed: %w",
+			utils.FmtUnstructured(existingObject), err)
+	}
+	return object, m.changeSetEntry(dryRunObject, action), nil
+}
+
 // ApplyAllStaged extracts the CRDs and Namespaces, applies them with ApplyAll,
 // waits for CRDs and Namespaces to become ready, then is applies all the other objects.
+// The CRDs with a non-structural schema or with an unavailable conversion webhook
+// are reported with warnings in the change set.
 // This function should be used when the given objects have a mix of custom resource definition and custom resources,
 // or a mix of namespace definitions with namespaced objects.
+// If ContinueOnError is set, the errors of both stages are aggregated in a *errors.MultiApplyErr.
 func (m *ResourceManager) ApplyAllStaged(ctx context.Context, objects []*unstructured.Unstructured, opts ApplyOptions) (*ChangeSet, error) {
+	objects, err := utils.ExpandLists(objects)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check the duplicates across both stages, with the indexes of the given objects.
+	if opts.Duplicates != "" {
+		if objects, _, err = RemoveDuplicates(objects, opts.Duplicates); err != nil {
+			return nil, err
+		}
+		opts.Duplicates = ""
+	}
+
 	changeSet := NewChangeSet()
 
 	// contains only CRDs and Namespaces
@@ -262,27 +468,62 @@ func (m *ResourceManager) ApplyAllStaged(ctx context.Context, objects []*unstruc
 		}
 	}
 
+	var errs []*ssaerrors.ApplyErr
+
 	if len(stageOne) > 0 {
 		cs, err := m.ApplyAll(ctx, stageOne, opts)
 		if err != nil {
-			return nil, err
+			multiErr, ok := err.(*ssaerrors.MultiApplyErr)
+			if !ok {
+				return nil, err
+			}
+			errs = append(errs, multiErr.Errors()...)
+			stageOne = withoutFailed(stageOne, multiErr)
 		}
 		changeSet.Append(cs.Entries)
 
 		if err := m.Wait(stageOne, WaitOptions{opts.WaitInterval, opts.WaitTimeout, false}); err != nil {
 			return nil, err
 		}
+
+		statuses, err := m.CustomResourceDefinitionStatuses(ctx, stageOne)
+		if err != nil {
+			return nil, err
+		}
+		setCustomResourceDefinitionWarnings(changeSet, statuses)
 	}
 
 	cs, err := m.ApplyAll(ctx, stageTwo, opts)
 	if err != nil {
-		return nil, err
+		multiErr, ok := err.(*ssaerrors.MultiApplyErr)
+		if !ok {
+			return nil, err
+		}
+		errs = append(errs, multiErr.Errors()...)
 	}
 	changeSet.Append(cs.Entries)
 
+	if len(errs) > 0 {
+		return changeSet, ssaerrors.NewMultiApplyErr(errs...)
+	}
 	return changeSet, nil
 }
 
+// withoutFailed returns the objects which are not involved in the given errors.
+func withoutFailed(objects []*unstructured.Unstructured, multiErr *ssaerrors.MultiApplyErr) []*unstructured.Unstructured {
+	failed := make(map[*unstructured.Unstructured]bool, len(multiErr.Errors()))
+	for _, err := range multiErr.Errors() {
+		failed[err.InvolvedObject()] = true
+	}
+	result := make([]*unstructured.Unstructured, 0, len(objects))
+	for _, object := range objects {
+		if !failed[object] {
+			result = append(result, object)
+		}
+	}
+	return result
+}
+
 func (m *ResourceManager) dryRunApply(ctx context.Context, object *unstructured.Unstructured) error {
 	opts := []client.PatchOption{
 		client.DryRunAll,
@@ -300,49 +541,81 @@ func (m *ResourceManager) apply(ctx context.Context, object *unstructured.Unstru
 	return m.client.Patch(ctx, object, client.Apply, opts...)
 }
 
-// cleanupMetadata performs an HTTP PATCH request to remove entries from metadata annotations, labels and managedFields.
-func (m *ResourceManager) cleanupMetadata(ctx context.Context,
-	desiredObject *unstructured.Unstructured,
+// withAnnotations returns a copy of the given object with the given annotations set,
+// or the object itself if there are no annotations to set.
+func withAnnotations(object *unstructured.Unstructured, annotations map[string]string) *unstructured.Unstructured {
+	if len(annotations) == 0 {
+		return object
+	}
+
+	result := object.DeepCopy()
+	objAnnotations := result.GetAnnotations()
+	if objAnnotations == nil {
+		objAnnotations = make(map[string]string, len(annotations))
+	}
+	for k, v := range annotations {
+		objAnnotations[k] = v
+	}
+	result.SetAnnotations(objAnnotations)
+	return result
+}
+
+// annotationKeys returns the keys of the given annotations.
+func annotationKeys(annotations map[string]string) []string {
+	keys := make([]string, 0, len(annotations))
+	for k := range annotations {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// cleanupMetadataPatches returns the JSON patches removing entries from metadata annotations, labels and managedFields.
+func (m *ResourceManager) cleanupMetadataPatches(desiredObject *unstructured.Unstructured,
 	object *unstructured.Unstructured,
-	opts ApplyCleanupOptions) (bool, error) {
+	opts ApplyCleanupOptions) ([]jsonPatch, error) {
 	if utils.AnyInMetadata(desiredObject, opts.Exclusions) || utils.AnyInMetadata(object, opts.Exclusions) {
-		return false, nil
+		return nil, nil
 	}
 
 	if object == nil {
-		return false, nil
+		return nil, nil
 	}
-	existingObject := object.DeepCopy()
 	var patches []jsonPatch
 
 	if len(opts.Annotations) > 0 {
-		patches = append(patches, PatchRemoveAnnotations(existingObject, opts.Annotations)...)
+		patches = append(patches, PatchRemoveAnnotations(object, opts.Annotations)...)
 	}
 
 	if len(opts.Labels) > 0 {
-		patches = append(patches, PatchRemoveLabels(existingObject, opts.Labels)...)
+		patches = append(patches, PatchRemoveLabels(object, opts.Labels)...)
 	}
 
 	if len(opts.FieldManagers) > 0 {
-		managedFieldPatch, err := PatchReplaceFieldsManagers(existingObject, opts.FieldManagers, m.owner.Field)
+		managedFieldPatch, err := PatchReplaceFieldsManagers(object, opts.FieldManagers, m.owner.Field)
 		if err != nil {
-			return false, err
+			return nil, err
 		}
 		patches = append(patches, managedFieldPatch...)
 	}
 
+	return patches, nil
+}
+
+// cleanupMetadata performs an HTTP PATCH request with the given patches to remove entries from metadata
+// annotations, labels and managedFields. It must be called only once the change has been approved.
+func (m *ResourceManager) cleanupMetadata(ctx context.Context, object *unstructured.Unstructured, patches []jsonPatch) error {
 	// no patching is needed exit early
 	if len(patches) == 0 {
-		return false, nil
+		return nil
 	}
 
 	rawPatch, err := json.Marshal(patches)
 	if err != nil {
-		return false, err
+		return err
 	}
 	patch := client.RawPatch(types.JSONPatchType, rawPatch)
 
-	return true, m.client.Patch(ctx, existingObject, patch, client.FieldOwner(m.owner.Field))
+	return m.client.Patch(ctx, object.DeepCopy(), patch, client.FieldOwner(m.owner.Field))
 }
 
 // shouldForceApply determines based on the apply error and ApplyOptions if the object should be recreated.
@@ -0,0 +1,154 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ssa
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
+
+	"github.com/fluxcd/pkg/ssa/utils"
+)
+
+// ApplyFieldPath applies only the field at the given JSON pointer (RFC 6901) of the object,
+// e.g. '/spec/replicas' or '/metadata/annotations/example.com~1key', with server-side apply.
+// This allows controllers which own a single field of objects managed by others to set it,
+// without taking ownership of the rest of the object.
+//
+// The fields already owned by the ResourceManager field manager on the in-cluster object are
+// extracted and applied along with the field, so that they are left unchanged. Lists are
+// extracted as a whole, hence applying a field of an object on which the field manager owns
+// list items claims the ownership of the entire list.
+//
+// The path must only traverse maps, and the object must exist in-cluster.
+// It returns an UnchangedAction entry if the field has the desired value already.
+func (m *ResourceManager) ApplyFieldPath(ctx context.Context, object *unstructured.Unstructured, path string) (*ChangeSetEntry, error) {
+	fields, err := parseFieldPath(path)
+	if err != nil {
+		return nil, err
+	}
+	value, found, err := unstructured.NestedFieldNoCopy(object.Object, fields...)
+	if err != nil || !found {
+		return nil, fmt.Errorf("%s field '%s' not found", utils.FmtUnstructured(object), path)
+	}
+
+	existingObject := &unstructured.Unstructured{}
+	existingObject.SetGroupVersionKind(object.GroupVersionKind())
+	if err := m.client.Get(ctx, client.ObjectKeyFromObject(object), existingObject); err != nil {
+		return nil, fmt.Errorf("%s query failed: %w", utils.FmtUnstructured(object), err)
+	}
+
+	applyObject, err := m.extractOwnedFields(existingObject)
+	if err != nil {
+		return nil, fmt.Errorf("%s managed fields extraction failed: %w", utils.FmtUnstructured(object), err)
+	}
+	if err := unstructured.SetNestedField(applyObject.Object, runtime.DeepCopyJSONValue(value), fields...); err != nil {
+		return nil, fmt.Errorf("%s failed to set field '%s': %w", utils.FmtUnstructured(object), path, err)
+	}
+
+	dryRunObject := applyObject.DeepCopy()
+	if err := m.dryRunApply(ctx, dryRunObject); err != nil {
+		return nil, fmt.Errorf("%s dry-run apply of field '%s' failed: %w", utils.FmtUnstructured(object), path, err)
+	}
+	if !m.hasDrifted(existingObject, dryRunObject) {
+		return m.changeSetEntry(object, UnchangedAction), nil
+	}
+
+	if err := m.apply(ctx, applyObject); err != nil {
+		return nil, fmt.Errorf("%s apply of field '%s' failed: %w", utils.FmtUnstructured(object), path, err)
+	}
+	return m.changeSetEntry(object, ConfiguredAction), nil
+}
+
+// extractOwnedFields returns an object with the identity of the given object, and the fields
+// of the object owned by the apply operations of the ResourceManager field manager.
+func (m *ResourceManager) extractOwnedFields(object *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	result := &unstructured.Unstructured{Object: map[string]interface{}{}}
+	for _, entry := range object.GetManagedFields() {
+		if entry.Manager != m.owner.Field || entry.Operation != metav1.ManagedFieldsOperationApply ||
+			entry.Subresource != "" || entry.FieldsV1 == nil {
+			continue
+		}
+		set, err := FieldsToSet(*entry.FieldsV1)
+		if err != nil {
+			return nil, err
+		}
+		set.Leaves().Iterate(func(p fieldpath.Path) {
+			copyFieldPath(result.Object, object.Object, p)
+		})
+	}
+
+	result.SetGroupVersionKind(object.GroupVersionKind())
+	result.SetName(object.GetName())
+	result.SetNamespace(object.GetNamespace())
+	return result, nil
+}
+
+// copyFieldPath copies the value at the given path from src to dst. The lists on the path are
+// copied as a whole, as their items can not be addressed without the schema of the object.
+func copyFieldPath(dst, src map[string]interface{}, p fieldpath.Path) {
+	for i, pe := range p {
+		if pe.FieldName == nil {
+			return
+		}
+		name := *pe.FieldName
+		v, ok := src[name]
+		if !ok {
+			return
+		}
+		srcMap, isMap := v.(map[string]interface{})
+		if i == len(p)-1 || p[i+1].FieldName == nil || !isMap {
+			dst[name] = runtime.DeepCopyJSONValue(v)
+			return
+		}
+		dstMap, ok := dst[name].(map[string]interface{})
+		if !ok {
+			dstMap = map[string]interface{}{}
+			dst[name] = dstMap
+		}
+		dst, src = dstMap, srcMap
+	}
+}
+
+// parseFieldPath returns the field names of the given JSON pointer.
+func parseFieldPath(path string) ([]string, error) {
+	if !strings.HasPrefix(path, "/") || path == "/" {
+		return nil, fmt.Errorf("invalid field path '%s': must be a JSON pointer to a field", path)
+	}
+	fields := strings.Split(path[1:], "/")
+	for i, f := range fields {
+		if f == "" {
+			return nil, fmt.Errorf("invalid field path '%s': empty field name", path)
+		}
+		fields[i] = strings.ReplaceAll(strings.ReplaceAll(f, "~1", "/"), "~0", "~")
+	}
+	switch fields[0] {
+	case "apiVersion", "kind":
+		return nil, fmt.Errorf("invalid field path '%s': the type of the object can not be applied", path)
+	case "metadata":
+		if len(fields) == 1 || (fields[1] != "labels" && fields[1] != "annotations") {
+			return nil, fmt.Errorf("invalid field path '%s': only labels and annotations can be applied in metadata", path)
+		}
+	}
+	return fields, nil
+}
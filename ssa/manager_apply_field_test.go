@@ -0,0 +1,139 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ssa
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/fluxcd/pkg/ssa/normalize"
+)
+
+func TestApplyFieldPath(t *testing.T) {
+	timeout := 10 * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	id := generateName("field")
+	objects, err := readManifest("testdata/test2.yaml", id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, deployObject := getFirstObject(objects, "Deployment", id)
+
+	if err = normalize.UnstructuredList(objects); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("creates objects as helm", func(t *testing.T) {
+		for _, object := range objects {
+			obj := object.DeepCopy()
+			if err := manager.client.Create(ctx, obj, client.FieldOwner("helm")); err != nil {
+				t.Fatal(err)
+			}
+		}
+	})
+
+	t.Run("applies the replicas", func(t *testing.T) {
+		deploy := deployObject.DeepCopy()
+		if err := unstructured.SetNestedField(deploy.Object, int64(3), "spec", "replicas"); err != nil {
+			t.Fatal(err)
+		}
+
+		entry, err := manager.ApplyFieldPath(ctx, deploy, "/spec/replicas")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(ConfiguredAction, entry.Action); diff != "" {
+			t.Errorf("Mismatch from expected value (-want +got):\n%s", diff)
+		}
+
+		existing := deployObject.DeepCopy()
+		if err := manager.Client().Get(ctx, client.ObjectKeyFromObject(existing), existing); err != nil {
+			t.Fatal(err)
+		}
+		replicas, _, _ := unstructured.NestedInt64(existing.Object, "spec", "replicas")
+		if diff := cmp.Diff(int64(3), replicas); diff != "" {
+			t.Errorf("Mismatch from expected value (-want +got):\n%s", diff)
+		}
+
+		for _, mf := range existing.GetManagedFields() {
+			if mf.Manager != manager.owner.Field {
+				continue
+			}
+			if fields := string(mf.FieldsV1.Raw); !strings.Contains(fields, "f:replicas") ||
+				strings.Contains(fields, "f:template") {
+				t.Errorf("unexpected managed fields: %s", fields)
+			}
+		}
+	})
+
+	t.Run("applies an annotation and keeps the replicas", func(t *testing.T) {
+		deploy := deployObject.DeepCopy()
+		deploy.SetAnnotations(map[string]string{"example.com/owner": "test"})
+
+		entry, err := manager.ApplyFieldPath(ctx, deploy, "/metadata/annotations/example.com~1owner")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(ConfiguredAction, entry.Action); diff != "" {
+			t.Errorf("Mismatch from expected value (-want +got):\n%s", diff)
+		}
+
+		existing := deployObject.DeepCopy()
+		if err := manager.Client().Get(ctx, client.ObjectKeyFromObject(existing), existing); err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff("test", existing.GetAnnotations()["example.com/owner"]); diff != "" {
+			t.Errorf("Mismatch from expected value (-want +got):\n%s", diff)
+		}
+		replicas, _, _ := unstructured.NestedInt64(existing.Object, "spec", "replicas")
+		if diff := cmp.Diff(int64(3), replicas); diff != "" {
+			t.Errorf("Mismatch from expected value (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("does not apply an unchanged field", func(t *testing.T) {
+		deploy := deployObject.DeepCopy()
+		if err := unstructured.SetNestedField(deploy.Object, int64(3), "spec", "replicas"); err != nil {
+			t.Fatal(err)
+		}
+
+		entry, err := manager.ApplyFieldPath(ctx, deploy, "/spec/replicas")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(UnchangedAction, entry.Action); diff != "" {
+			t.Errorf("Mismatch from expected value (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("rejects invalid paths", func(t *testing.T) {
+		for _, path := range []string{"", "/", "spec/replicas", "/kind", "/metadata/name", "/spec/missing"} {
+			if _, err := manager.ApplyFieldPath(ctx, deployObject, path); err == nil {
+				t.Errorf("expected an error for path '%s'", path)
+			}
+		}
+	})
+}
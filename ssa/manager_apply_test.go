@@ -20,6 +20,7 @@ package ssa
 import (
 	"context"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"sort"
 	"strings"
@@ -30,8 +31,11 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
+	ssaerrors "github.com/fluxcd/pkg/ssa/errors"
+	"github.com/fluxcd/pkg/ssa/jsondiff"
 	"github.com/fluxcd/pkg/ssa/normalize"
 	"github.com/fluxcd/pkg/ssa/utils"
 )
@@ -447,6 +451,347 @@ func TestApply_NoOp(t *testing.T) {
 	})
 }
 
+func TestApply_Annotations(t *testing.T) {
+	timeout := 10 * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	id := generateName("stamp")
+	objects, err := readManifest("testdata/test1.yaml", id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	manager.SetOwnerLabels(objects, "app1", "default")
+
+	_, configMap := getFirstObject(objects, "ConfigMap", id)
+	revisionKey := manager.owner.Group + "/revision"
+
+	applyWithRevision := func(revision string) *ChangeSet {
+		opts := DefaultApplyOptions()
+		opts.Annotations = map[string]string{revisionKey: revision}
+		changeSet, err := manager.ApplyAllStaged(ctx, objects, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return changeSet
+	}
+
+	getRevision := func() string {
+		configMapClone := configMap.DeepCopy()
+		if err := manager.client.Get(ctx, client.ObjectKeyFromObject(configMapClone), configMapClone); err != nil {
+			t.Fatal(err)
+		}
+		return configMapClone.GetAnnotations()[revisionKey]
+	}
+
+	t.Run("creates objects with annotations", func(t *testing.T) {
+		applyWithRevision("v1")
+
+		if rev := getRevision(); rev != "v1" {
+			t.Errorf("Expected revision %s, got %s", "v1", rev)
+		}
+		if _, ok := configMap.GetAnnotations()[revisionKey]; ok {
+			t.Errorf("Expected desired object to not be mutated")
+		}
+	})
+
+	t.Run("ignores annotations in drift detection", func(t *testing.T) {
+		changeSet := applyWithRevision("v2")
+
+		for _, entry := range changeSet.Entries {
+			if entry.Action != UnchangedAction {
+				t.Errorf("Diff found for %s", entry.String())
+			}
+		}
+		if rev := getRevision(); rev != "v1" {
+			t.Errorf("Expected revision %s, got %s", "v1", rev)
+		}
+
+		opts := DefaultDiffOptions()
+		opts.Annotations = map[string]string{revisionKey: "v2"}
+		cse, _, _, err := manager.Diff(ctx, configMap, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cse.Action != UnchangedAction {
+			t.Errorf("Expected %s, got %s", UnchangedAction, cse.Action)
+		}
+	})
+
+	t.Run("updates annotations of configured objects", func(t *testing.T) {
+		if err := unstructured.SetNestedField(configMap.Object, "private-key", "data", "key"); err != nil {
+			t.Fatal(err)
+		}
+
+		changeSet := applyWithRevision("v3")
+
+		for _, entry := range changeSet.Entries {
+			if entry.Subject == utils.FmtUnstructured(configMap) && entry.Action != ConfiguredAction {
+				t.Errorf("Expected %s, got %s", ConfiguredAction, entry.Action)
+			}
+		}
+		if rev := getRevision(); rev != "v3" {
+			t.Errorf("Expected revision %s, got %s", "v3", rev)
+		}
+	})
+}
+
+func TestApply_IgnorePaths(t *testing.T) {
+	timeout := 10 * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	id := generateName("ignore")
+	objects, err := readManifest("testdata/test2.yaml", id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manager.SetOwnerLabels(objects, "app1", "default")
+
+	_, deployObject := getFirstObject(objects, "Deployment", id)
+	if err := unstructured.SetNestedField(deployObject.Object, int64(1), "spec", "replicas"); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := DefaultApplyOptions()
+	opts.IgnorePaths = []jsondiff.IgnoreRule{
+		{
+			Paths:    []string{"/spec/replicas"},
+			Selector: &jsondiff.Selector{Kind: "Deployment"},
+		},
+	}
+
+	getReplicas := func() int64 {
+		deployClone := deployObject.DeepCopy()
+		if err := manager.client.Get(ctx, client.ObjectKeyFromObject(deployClone), deployClone); err != nil {
+			t.Fatal(err)
+		}
+		replicas, _, _ := unstructured.NestedInt64(deployClone.Object, "spec", "replicas")
+		return replicas
+	}
+
+	t.Run("creates objects", func(t *testing.T) {
+		if _, err := manager.ApplyAllStaged(ctx, objects, DefaultApplyOptions()); err != nil {
+			t.Fatal(err)
+		}
+		if replicas := getReplicas(); replicas != 1 {
+			t.Errorf("Expected %d replicas, got %d", 1, replicas)
+		}
+	})
+
+	t.Run("ignores paths in drift detection", func(t *testing.T) {
+		scaled := deployObject.DeepCopy()
+		patch := client.RawPatch(types.MergePatchType, []byte(`{"spec":{"replicas":2}}`))
+		if err := manager.client.Patch(ctx, scaled, patch, client.FieldOwner("hpa")); err != nil {
+			t.Fatal(err)
+		}
+
+		changeSet, err := manager.ApplyAll(ctx, objects, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, entry := range changeSet.Entries {
+			if entry.Action != UnchangedAction {
+				t.Errorf("Diff found for %s", entry.String())
+			}
+		}
+
+		cse, _, _, err := manager.Diff(ctx, deployObject, DiffOptions{IgnorePaths: opts.IgnorePaths})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cse.Action != UnchangedAction {
+			t.Errorf("Expected %s, got %s", UnchangedAction, cse.Action)
+		}
+
+		if replicas := getReplicas(); replicas != 2 {
+			t.Errorf("Expected %d replicas, got %d", 2, replicas)
+		}
+	})
+
+	t.Run("excludes paths from the applied object", func(t *testing.T) {
+		if err := unstructured.SetNestedField(deployObject.Object, "ignore", "metadata", "annotations", "test"); err != nil {
+			t.Fatal(err)
+		}
+
+		entry, err := manager.Apply(ctx, deployObject, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if entry.Action != ConfiguredAction {
+			t.Errorf("Expected %s, got %s", ConfiguredAction, entry.Action)
+		}
+		if replicas := getReplicas(); replicas != 2 {
+			t.Errorf("Expected %d replicas, got %d", 2, replicas)
+		}
+	})
+
+	t.Run("skips objects matching the root path", func(t *testing.T) {
+		rootOpts := DefaultApplyOptions()
+		rootOpts.IgnorePaths = []jsondiff.IgnoreRule{
+			{
+				Paths:    []string{jsondiff.IgnorePathRoot},
+				Selector: &jsondiff.Selector{Kind: "Deployment"},
+			},
+		}
+
+		entry, err := manager.Apply(ctx, deployObject, rootOpts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if entry.Action != SkippedAction {
+			t.Errorf("Expected %s, got %s", SkippedAction, entry.Action)
+		}
+	})
+}
+
+func TestApply_Mutators(t *testing.T) {
+	timeout := 10 * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	id := generateName("mutators")
+	objects, err := readManifest("testdata/test1.yaml", id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, configMap := getFirstObject(objects, "ConfigMap", id)
+
+	opts := DefaultApplyOptions()
+	opts.Mutators = []Mutator{
+		manager.OwnerLabelsMutator("app1", "default"),
+		SetLabels(map[string]string{"env": "prod"}),
+		MutatorFunc(func(object *unstructured.Unstructured) error {
+			if object.GetKind() == "ConfigMap" {
+				return unstructured.SetNestedField(object.Object, "mutated", "data", "mutator")
+			}
+			return nil
+		}),
+	}
+
+	t.Run("creates mutated objects", func(t *testing.T) {
+		if _, err := manager.ApplyAllStaged(ctx, objects, opts); err != nil {
+			t.Fatal(err)
+		}
+
+		configMapClone := configMap.DeepCopy()
+		if err := manager.client.Get(ctx, client.ObjectKeyFromObject(configMapClone), configMapClone); err != nil {
+			t.Fatal(err)
+		}
+		if v := configMapClone.GetLabels()["env"]; v != "prod" {
+			t.Errorf("Expected label %s, got %s", "prod", v)
+		}
+		if v := configMapClone.GetLabels()[manager.owner.Group+"/name"]; v != "app1" {
+			t.Errorf("Expected owner label %s, got %s", "app1", v)
+		}
+		if v, _, _ := unstructured.NestedString(configMapClone.Object, "data", "mutator"); v != "mutated" {
+			t.Errorf("Expected data %s, got %s", "mutated", v)
+		}
+		if _, ok := configMap.GetLabels()["env"]; ok {
+			t.Errorf("Expected desired object to not be mutated")
+		}
+	})
+
+	t.Run("does not detect drift", func(t *testing.T) {
+		changeSet, err := manager.ApplyAllStaged(ctx, objects, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, entry := range changeSet.Entries {
+			if entry.Action != UnchangedAction {
+				t.Errorf("Diff found for %s", entry.String())
+			}
+		}
+
+		cse, _, _, err := manager.Diff(ctx, configMap, DiffOptions{Mutators: opts.Mutators})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cse.Action != UnchangedAction {
+			t.Errorf("Expected %s, got %s", UnchangedAction, cse.Action)
+		}
+	})
+
+	t.Run("fails on mutation error", func(t *testing.T) {
+		failOpts := DefaultApplyOptions()
+		failOpts.Mutators = []Mutator{
+			MutatorFunc(func(object *unstructured.Unstructured) error {
+				return errors.New("mutation error")
+			}),
+		}
+
+		_, err := manager.Apply(ctx, configMap, failOpts)
+		if err == nil || !strings.Contains(err.Error(), "mutation error") {
+			t.Errorf("Expected mutation error, got %v", err)
+		}
+	})
+}
+
+func TestApplyAllStaged_ContinueOnError(t *testing.T) {
+	timeout := 10 * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	id := generateName("continue")
+	objects, err := readManifest("testdata/test1.yaml", id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	manager.SetOwnerLabels(objects, "app1", "default")
+
+	_, configMap := getFirstObject(objects, "ConfigMap", id)
+	invalid := configMap.DeepCopy()
+	invalid.SetName("Invalid_Name")
+	objects = append(objects, invalid)
+
+	t.Run("aborts on error by default", func(t *testing.T) {
+		changeSet, err := manager.ApplyAllStaged(ctx, objects, DefaultApplyOptions())
+		if err == nil {
+			t.Fatal("Expected error")
+		}
+		if changeSet != nil {
+			t.Errorf("Expected no change set, got %s", changeSet.String())
+		}
+	})
+
+	t.Run("continues on error", func(t *testing.T) {
+		opts := DefaultApplyOptions()
+		opts.ContinueOnError = true
+		changeSet, err := manager.ApplyAllStaged(ctx, objects, opts)
+
+		var multiErr *ssaerrors.MultiApplyErr
+		if !errors.As(err, &multiErr) {
+			t.Fatalf("Expected MultiApplyErr, got %v", err)
+		}
+		if len(multiErr.Errors()) != 1 {
+			t.Fatalf("Expected 1 error, got %d", len(multiErr.Errors()))
+		}
+		if name := multiErr.Errors()[0].InvolvedObject().GetName(); name != invalid.GetName() {
+			t.Errorf("Expected error for %s, got %s", invalid.GetName(), name)
+		}
+		var dryRunErr *ssaerrors.DryRunErr
+		if !errors.As(err, &dryRunErr) {
+			t.Errorf("Expected DryRunErr to be unwrapped, got %v", err)
+		}
+
+		if len(changeSet.Entries) != len(objects)-1 {
+			t.Errorf("Expected %d entries, got %d", len(objects)-1, len(changeSet.Entries))
+		}
+		for _, entry := range changeSet.Entries {
+			if entry.ObjMetadata.Name == invalid.GetName() {
+				t.Errorf("Expected no entry for %s", entry.Subject)
+			}
+		}
+
+		if err := manager.client.Get(ctx, client.ObjectKeyFromObject(configMap), configMap.DeepCopy()); err != nil {
+			t.Errorf("Expected %s to be applied: %v", configMap.GetName(), err)
+		}
+	})
+}
+
 func TestApply_Exclusions(t *testing.T) {
 	timeout := 10 * time.Second
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
@@ -957,3 +1302,89 @@ func containsItemString(s []string, e string) bool {
 	}
 	return false
 }
+
+func TestApply_ApprovalPolicy(t *testing.T) {
+	timeout := 10 * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	id := generateName("approval")
+	objects, err := readManifest("testdata/test1.yaml", id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, configMap := getFirstObject(objects, "ConfigMap", id)
+	configMap.SetLabels(map[string]string{"cleanup": "true"})
+
+	if _, err = manager.ApplyAllStaged(ctx, objects, DefaultApplyOptions()); err != nil {
+		t.Fatal(err)
+	}
+
+	manager.SetApprovalPolicy(func(ctx context.Context, req ApprovalRequest) (bool, error) {
+		return req.Object.GetKind() != "ConfigMap", nil
+	})
+	defer manager.SetApprovalPolicy(nil)
+
+	opts := DefaultApplyOptions()
+	opts.Cleanup = ApplyCleanupOptions{Labels: []string{"cleanup"}}
+
+	assertNotCleaned := func(t *testing.T) {
+		configMapClone := configMap.DeepCopy()
+		if err := manager.client.Get(ctx, client.ObjectKeyFromObject(configMapClone), configMapClone); err != nil {
+			t.Fatal(err)
+		}
+		if v := configMapClone.GetLabels()["cleanup"]; v != "true" {
+			t.Errorf("Expected label to not be removed before approval, got %q", v)
+		}
+	}
+
+	t.Run("Apply does not clean up metadata before approval", func(t *testing.T) {
+		entry, err := manager.Apply(ctx, configMap, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(PendingAction, entry.Action); diff != "" {
+			t.Errorf("Mismatch from expected value (-want +got):\n%s", diff)
+		}
+		assertNotCleaned(t)
+	})
+
+	t.Run("ApplyAll does not clean up metadata before approval", func(t *testing.T) {
+		changeSet, err := manager.ApplyAll(ctx, []*unstructured.Unstructured{configMap}, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(PendingAction, changeSet.Entries[0].Action); diff != "" {
+			t.Errorf("Mismatch from expected value (-want +got):\n%s", diff)
+		}
+		assertNotCleaned(t)
+	})
+
+	t.Run("Apply does not recreate objects before approval", func(t *testing.T) {
+		forceOpts := DefaultApplyOptions()
+		forceOpts.Force = true
+
+		_, secret := getFirstObject(objects, "Secret", id)
+		immutable := secret.DeepCopy()
+		if err := unstructured.SetNestedField(immutable.Object, "changed", "stringData", "key"); err != nil {
+			t.Fatal(err)
+		}
+
+		manager.SetApprovalPolicy(func(ctx context.Context, req ApprovalRequest) (bool, error) {
+			return req.Action != DeletedAction, nil
+		})
+		entry, err := manager.Apply(ctx, immutable, forceOpts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(PendingAction, entry.Action); diff != "" {
+			t.Errorf("Mismatch from expected value (-want +got):\n%s", diff)
+		}
+
+		secretClone := secret.DeepCopy()
+		if err := manager.client.Get(ctx, client.ObjectKeyFromObject(secretClone), secretClone); err != nil {
+			t.Errorf("expected %s to not be deleted: %v", utils.FmtUnstructured(secret), err)
+		}
+	})
+}
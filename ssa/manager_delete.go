@@ -19,13 +19,16 @@ package ssa
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"sort"
+	"strings"
 
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/fluxcd/pkg/ssa/utils"
@@ -48,6 +51,11 @@ type DeleteOptions struct {
 	// A nil Exclusions map means all objects are subject to deletion
 	// irregardless of their metadata labels and annotations.
 	Exclusions map[string]string
+
+	// Orphan determines whether the in-cluster objects are orphaned instead
+	// of deleted. Orphaning removes the labels and annotations prefixed with
+	// the owner group from the objects, leaving them in the cluster.
+	Orphan bool
 }
 
 // DefaultDeleteOptions returns the default delete options where the propagation
@@ -63,9 +71,7 @@ func DefaultDeleteOptions() DeleteOptions {
 // Delete deletes the given object (not found errors are ignored).
 func (m *ResourceManager) Delete(ctx context.Context, object *unstructured.Unstructured, opts DeleteOptions) (*ChangeSetEntry, error) {
 
-	existingObject := &unstructured.Unstructured{}
-	existingObject.SetGroupVersionKind(object.GroupVersionKind())
-	err := m.client.Get(ctx, client.ObjectKeyFromObject(object), existingObject)
+	existingObject, err := m.getExisting(ctx, object)
 	if err != nil {
 		if !apierrors.IsNotFound(err) {
 			return m.changeSetEntry(object, UnknownAction),
@@ -88,6 +94,22 @@ func (m *ResourceManager) Delete(ctx context.Context, object *unstructured.Unstr
 		return m.changeSetEntry(object, SkippedAction), nil
 	}
 
+	if opts.Orphan {
+		if err := m.orphan(ctx, existingObject); err != nil {
+			return m.changeSetEntry(object, UnknownAction),
+				fmt.Errorf("%s orphan failed: %w", utils.FmtUnstructured(object), err)
+		}
+		return m.changeSetEntry(object, OrphanedAction), nil
+	}
+
+	ok, err := m.approved(ctx, ApprovalRequest{Object: existingObject, Action: DeletedAction})
+	if err != nil {
+		return m.changeSetEntry(object, UnknownAction), err
+	}
+	if !ok {
+		return m.changeSetEntry(object, PendingAction), nil
+	}
+
 	if err := m.client.Delete(ctx, existingObject, client.PropagationPolicy(opts.PropagationPolicy)); err != nil {
 		return m.changeSetEntry(object, UnknownAction),
 			fmt.Errorf("%s delete failed: %w", utils.FmtUnstructured(object), err)
@@ -96,8 +118,48 @@ func (m *ResourceManager) Delete(ctx context.Context, object *unstructured.Unstr
 	return m.changeSetEntry(object, DeletedAction), nil
 }
 
+// orphan performs an HTTP PATCH request to remove the labels and annotations
+// prefixed with the owner group from the given in-cluster object.
+func (m *ResourceManager) orphan(ctx context.Context, object *unstructured.Unstructured) error {
+	prefix := m.owner.Group + "/"
+	var labelKeys, annotationKeys []string
+	for key := range object.GetLabels() {
+		if strings.HasPrefix(key, prefix) {
+			labelKeys = append(labelKeys, key)
+		}
+	}
+	for key := range object.GetAnnotations() {
+		if strings.HasPrefix(key, prefix) {
+			annotationKeys = append(annotationKeys, key)
+		}
+	}
+	sort.Strings(labelKeys)
+	sort.Strings(annotationKeys)
+
+	patches := PatchRemoveLabels(object, labelKeys)
+	patches = append(patches, PatchRemoveAnnotations(object, annotationKeys)...)
+
+	// no patching is needed exit early
+	if len(patches) == 0 {
+		return nil
+	}
+
+	rawPatch, err := json.Marshal(patches)
+	if err != nil {
+		return err
+	}
+	patch := client.RawPatch(types.JSONPatchType, rawPatch)
+
+	return m.client.Patch(ctx, object, patch, client.FieldOwner(m.owner.Field))
+}
+
 // DeleteAll deletes the given set of objects (not found errors are ignored).
 func (m *ResourceManager) DeleteAll(ctx context.Context, objects []*unstructured.Unstructured, opts DeleteOptions) (*ChangeSet, error) {
+	objects, err := utils.ExpandLists(objects)
+	if err != nil {
+		return nil, err
+	}
+
 	sort.Sort(sort.Reverse(SortableUnstructureds(objects)))
 	changeSet := NewChangeSet()
 
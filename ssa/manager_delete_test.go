@@ -25,6 +25,7 @@ import (
 
 	"github.com/google/go-cmp/cmp"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/fluxcd/pkg/ssa/utils"
@@ -163,3 +164,171 @@ func TestDelete_Exclusions(t *testing.T) {
 		}
 	})
 }
+
+func TestDelete_Orphan(t *testing.T) {
+	timeout := 10 * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	id := generateName("orphan")
+	objects, err := readManifest("testdata/test1.yaml", id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	manager.SetOwnerLabels(objects, "app1", "default")
+
+	_, configMap := getFirstObject(objects, "ConfigMap", id)
+	annotations := configMap.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[manager.owner.Group+"/checksum"] = "1234"
+	annotations["other.io/keep"] = "true"
+	configMap.SetAnnotations(annotations)
+
+	if _, err = manager.ApplyAllStaged(ctx, objects, DefaultApplyOptions()); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := DefaultDeleteOptions()
+	opts.Inclusions = manager.GetOwnerLabels("app1", "default")
+	opts.Orphan = true
+
+	changeSet, err := manager.DeleteAll(ctx, objects, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, entry := range changeSet.Entries {
+		if diff := cmp.Diff(OrphanedAction, entry.Action); diff != "" {
+			t.Errorf("Mismatch from expected value (-want +got):\n%s", diff)
+		}
+	}
+
+	configMapClone := configMap.DeepCopy()
+	if err := manager.client.Get(ctx, client.ObjectKeyFromObject(configMapClone), configMapClone); err != nil {
+		t.Fatal(err)
+	}
+	for key := range configMapClone.GetLabels() {
+		if strings.HasPrefix(key, manager.owner.Group+"/") {
+			t.Errorf("Expected owner label %s to be removed", key)
+		}
+	}
+	if diff := cmp.Diff(map[string]string{"other.io/keep": "true"}, configMapClone.GetAnnotations()); diff != "" {
+		t.Errorf("Mismatch from expected value (-want +got):\n%s", diff)
+	}
+}
+
+func TestDeleteAll_PartialObjectMetadata(t *testing.T) {
+	timeout := 10 * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	id := generateName("partial")
+	objects, err := readManifest("testdata/test1.yaml", id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	manager.SetOwnerLabels(objects, "app1", "default")
+
+	_, configMap := getFirstObject(objects, "ConfigMap", id)
+
+	// wrap the objects in a List
+	items := make([]interface{}, 0, len(objects))
+	for _, object := range objects {
+		items = append(items, object.Object)
+	}
+	list := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "List",
+		"items":      items,
+	}}
+	listObjects := []*unstructured.Unstructured{list}
+
+	changeSet, err := manager.ApplyAllStaged(ctx, listObjects, DefaultApplyOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changeSet.Entries) != len(objects) {
+		t.Fatalf("Expected %d entries, got %d", len(objects), len(changeSet.Entries))
+	}
+
+	manager.SetPartialObjectMetadata(true)
+	defer manager.SetPartialObjectMetadata(false)
+
+	opts := DefaultDeleteOptions()
+	opts.Inclusions = manager.GetOwnerLabels("app1", "default")
+
+	changeSet, err = manager.DeleteAll(ctx, listObjects, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range changeSet.Entries {
+		if diff := cmp.Diff(DeletedAction, entry.Action); diff != "" {
+			t.Errorf("Mismatch from expected value (-want +got):\n%s", diff)
+		}
+	}
+
+	configMapClone := configMap.DeepCopy()
+	err = manager.client.Get(ctx, client.ObjectKeyFromObject(configMapClone), configMapClone)
+	if !apierrors.IsNotFound(err) {
+		t.Error(err)
+	}
+
+	if err := manager.WaitForTermination(listObjects, WaitOptions{time.Second, 5 * time.Second, false}); err != nil {
+		// workaround for https://github.com/kubernetes-sigs/controller-runtime/issues/880
+		if !strings.Contains(err.Error(), "Namespace/") {
+			t.Error(err)
+		}
+	}
+}
+
+func TestDeleteAll_ApprovalPolicy(t *testing.T) {
+	timeout := 10 * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	id := generateName("approval")
+	objects, err := readManifest("testdata/test1.yaml", id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	manager.SetOwnerLabels(objects, "app1", "default")
+
+	_, configMap := getFirstObject(objects, "ConfigMap", id)
+
+	if _, err = manager.ApplyAllStaged(ctx, objects, DefaultApplyOptions()); err != nil {
+		t.Fatal(err)
+	}
+
+	manager.SetApprovalPolicy(func(ctx context.Context, req ApprovalRequest) (bool, error) {
+		return req.Object.GetKind() != "ConfigMap", nil
+	})
+	defer manager.SetApprovalPolicy(nil)
+
+	opts := DefaultDeleteOptions()
+	opts.Inclusions = manager.GetOwnerLabels("app1", "default")
+
+	changeSet, err := manager.DeleteAll(ctx, objects, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, entry := range changeSet.Entries {
+		expected := DeletedAction
+		if entry.Subject == utils.FmtUnstructured(configMap) {
+			expected = PendingAction
+		}
+		if diff := cmp.Diff(expected, entry.Action); diff != "" {
+			t.Errorf("Mismatch from expected value (-want +got):\n%s", diff)
+		}
+	}
+
+	configMapClone := configMap.DeepCopy()
+	if err := manager.client.Get(ctx, client.ObjectKeyFromObject(configMapClone), configMapClone); err != nil {
+		t.Errorf("expected %s to exist: %v", utils.FmtUnstructured(configMap), err)
+	}
+}
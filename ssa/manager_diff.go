@@ -25,6 +25,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/fluxcd/pkg/ssa/errors"
+	"github.com/fluxcd/pkg/ssa/jsondiff"
 	"github.com/fluxcd/pkg/ssa/normalize"
 	"github.com/fluxcd/pkg/ssa/utils"
 )
@@ -36,6 +37,19 @@ type DiffOptions struct {
 	// A nil Exclusions map means all objects are applied
 	// regardless of their metadata labels and annotations.
 	Exclusions map[string]string `json:"exclusions"`
+
+	// Annotations defines the 'metadata.annotations' entries set on the objects
+	// when applied, see ApplyOptions.Annotations.
+	// The annotations are excluded from drift detection.
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	// IgnorePaths defines the JSON pointers excluded from the dry-run apply
+	// and drift detection, see ApplyOptions.IgnorePaths.
+	IgnorePaths []jsondiff.IgnoreRule `json:"ignorePaths,omitempty"`
+
+	// Mutators defines the transformations performed on a copy of the object
+	// before the dry-run apply, see ApplyOptions.Mutators.
+	Mutators []Mutator `json:"-"`
 }
 
 // DefaultDiffOptions returns the default dry-run apply options.
@@ -53,6 +67,11 @@ func (m *ResourceManager) Diff(ctx context.Context, object *unstructured.Unstruc
 	*unstructured.Unstructured,
 	error,
 ) {
+	object, err := mutate(object, opts.Mutators)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
 	existingObject := &unstructured.Unstructured{}
 	existingObject.SetGroupVersionKind(object.GroupVersionKind())
 	_ = m.client.Get(ctx, client.ObjectKeyFromObject(object), existingObject)
@@ -61,7 +80,19 @@ func (m *ResourceManager) Diff(ctx context.Context, object *unstructured.Unstruc
 		return m.changeSetEntry(existingObject, SkippedAction), nil, nil, nil
 	}
 
-	dryRunObject := object.DeepCopy()
+	paths, ignored, err := ignoredPaths(object, opts.IgnorePaths)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if ignored {
+		return m.changeSetEntry(object, SkippedAction), nil, nil, nil
+	}
+
+	dryRunObject, err := withoutPaths(withAnnotations(object, opts.Annotations), paths)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	dryRunObject = dryRunObject.DeepCopy()
 	if err := m.dryRunApply(ctx, dryRunObject); err != nil {
 		return nil, nil, nil, errors.NewDryRunErr(err, dryRunObject)
 	}
@@ -70,7 +101,15 @@ func (m *ResourceManager) Diff(ctx context.Context, object *unstructured.Unstruc
 		return m.changeSetEntry(dryRunObject, CreatedAction), nil, nil, nil
 	}
 
-	if m.hasDrifted(existingObject, dryRunObject) {
+	// exclude the ignored paths from the returned objects
+	if existingObject, err = withoutPaths(existingObject, paths); err != nil {
+		return nil, nil, nil, err
+	}
+	if dryRunObject, err = withoutPaths(dryRunObject, paths); err != nil {
+		return nil, nil, nil, err
+	}
+
+	if m.hasDrifted(existingObject, dryRunObject, annotationKeys(opts.Annotations)...) {
 		cse := m.changeSetEntry(object, ConfiguredAction)
 
 		unstructured.RemoveNestedField(dryRunObject.Object, "metadata", "managedFields")
@@ -89,7 +128,8 @@ func (m *ResourceManager) Diff(ctx context.Context, object *unstructured.Unstruc
 }
 
 // hasDrifted detects changes to metadata labels, annotations and spec.
-func (m *ResourceManager) hasDrifted(existingObject, dryRunObject *unstructured.Unstructured) bool {
+// Changes to the annotations with the given keys are ignored.
+func (m *ResourceManager) hasDrifted(existingObject, dryRunObject *unstructured.Unstructured, ignoredAnnotations ...string) bool {
 	if dryRunObject.GetResourceVersion() == "" {
 		return true
 	}
@@ -98,18 +138,42 @@ func (m *ResourceManager) hasDrifted(existingObject, dryRunObject *unstructured.
 		return true
 	}
 
-	if !apiequality.Semantic.DeepEqual(dryRunObject.GetAnnotations(), existingObject.GetAnnotations()) {
+	if !apiequality.Semantic.DeepEqual(withoutKeys(dryRunObject.GetAnnotations(), ignoredAnnotations),
+		withoutKeys(existingObject.GetAnnotations(), ignoredAnnotations)) {
 		return true
 	}
 
-	return hasObjectDrifted(dryRunObject, existingObject)
+	return hasObjectDrifted(dryRunObject, existingObject, m.schemaDefaults)
+}
+
+// withoutKeys returns a copy of the given map without the given keys,
+// or the map itself if there are no keys to remove.
+func withoutKeys(m map[string]string, keys []string) map[string]string {
+	if len(keys) == 0 || len(m) == 0 {
+		return m
+	}
+
+	result := make(map[string]string, len(m))
+	for k, v := range m {
+		result[k] = v
+	}
+	for _, k := range keys {
+		delete(result, k)
+	}
+	return result
 }
 
-// hasObjectDrifted performs a semantic equality check of the given objects' spec
-func hasObjectDrifted(existingObject, dryRunObject *unstructured.Unstructured) bool {
+// hasObjectDrifted performs a semantic equality check of the given objects' spec.
+// If schema defaults are given, the fields set to their default value are ignored.
+func hasObjectDrifted(existingObject, dryRunObject *unstructured.Unstructured, defaults *SchemaDefaults) bool {
 	existingObj := prepareObjectForDiff(existingObject)
 	dryRunObj := prepareObjectForDiff(dryRunObject)
 
+	if defaults != nil {
+		existingObj, dryRunObj = existingObj.DeepCopy(), dryRunObj.DeepCopy()
+		defaults.Prune(dryRunObject.GroupVersionKind(), existingObj.Object, dryRunObj.Object)
+	}
+
 	return !apiequality.Semantic.DeepEqual(dryRunObj.Object, existingObj.Object)
 }
 
@@ -0,0 +1,129 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ssa
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/fluxcd/pkg/ssa/utils"
+)
+
+// TransferOwnership transfers the ownership of the fields managed by fromManager to the
+// field manager of the ResourceManager, for the in-cluster objects matching the given objects.
+// The managedFields entries of the fromManager, for both the apply and update operations,
+// are merged into the apply entry of the ResourceManager with a single patch per object,
+// without changing the objects spec. This allows taking over resources created by kubectl
+// or Helm, without conflicts on the next apply.
+// The fromManager name is matched exactly, e.g. 'helm' does not match 'helmfile'.
+// The patch is rejected if the object was changed since it was read, in which case an
+// error is returned for the object.
+// Objects not found in-cluster are skipped, and the objects without managedFields entries
+// of the fromManager are reported as unchanged.
+func (m *ResourceManager) TransferOwnership(ctx context.Context, objects []*unstructured.Unstructured, fromManager string) (*ChangeSet, error) {
+	if fromManager == "" || fromManager == m.owner.Field {
+		return nil, fmt.Errorf("invalid field manager '%s'", fromManager)
+	}
+
+	objects, err := utils.ExpandLists(objects)
+	if err != nil {
+		return nil, err
+	}
+
+	managers := []FieldManager{
+		{Name: fromManager, OperationType: metav1.ManagedFieldsOperationApply},
+		{Name: fromManager, OperationType: metav1.ManagedFieldsOperationUpdate},
+	}
+
+	changeSet := NewChangeSet()
+	var errors string
+	for _, object := range objects {
+		cse, err := m.transferOwnership(ctx, object, managers)
+		changeSet.Add(*cse)
+		if err != nil {
+			errors += err.Error() + ";"
+		}
+	}
+
+	if errors != "" {
+		return changeSet, fmt.Errorf("ownership transfer failed, errors: %s", errors)
+	}
+
+	return changeSet, nil
+}
+
+// transferOwnership performs an HTTP PATCH request to replace the managedFields entries
+// of the given managers with the ResourceManager field manager. The patch tests the
+// resourceVersion of the object, so that concurrent changes of the managedFields are
+// not overwritten.
+func (m *ResourceManager) transferOwnership(ctx context.Context, object *unstructured.Unstructured, managers []FieldManager) (*ChangeSetEntry, error) {
+	existingObject, err := m.getExisting(ctx, object)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return m.changeSetEntry(object, SkippedAction), nil
+		}
+		return m.changeSetEntry(object, UnknownAction),
+			fmt.Errorf("%s query failed: %w", utils.FmtUnstructured(object), err)
+	}
+
+	patches, err := patchReplaceFieldsManagers(existingObject, managers, m.owner.Field,
+		func(entryManager, manager string) bool { return entryManager == manager })
+	if err != nil {
+		return m.changeSetEntry(object, UnknownAction),
+			fmt.Errorf("%s ownership transfer failed: %w", utils.FmtUnstructured(object), err)
+	}
+	if len(patches) == 0 {
+		return m.changeSetEntry(object, UnchangedAction), nil
+	}
+
+	ops := make([]interface{}, 0, len(patches)+1)
+	ops = append(ops, resourceVersionTest{
+		Operation: "test",
+		Path:      "/metadata/resourceVersion",
+		Value:     existingObject.GetResourceVersion(),
+	})
+	for _, p := range patches {
+		ops = append(ops, p)
+	}
+
+	rawPatch, err := json.Marshal(ops)
+	if err != nil {
+		return m.changeSetEntry(object, UnknownAction), err
+	}
+	patch := client.RawPatch(types.JSONPatchType, rawPatch)
+
+	if err := m.client.Patch(ctx, existingObject, patch, client.FieldOwner(m.owner.Field)); err != nil {
+		return m.changeSetEntry(object, UnknownAction),
+			fmt.Errorf("%s ownership transfer failed: %w", utils.FmtUnstructured(object), err)
+	}
+
+	return m.changeSetEntry(object, ConfiguredAction), nil
+}
+
+// resourceVersionTest is a JSON patch operation testing the resourceVersion of an object.
+type resourceVersionTest struct {
+	Operation string `json:"op"`
+	Path      string `json:"path"`
+	Value     string `json:"value"`
+}
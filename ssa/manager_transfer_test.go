@@ -0,0 +1,154 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ssa
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/fluxcd/pkg/ssa/normalize"
+)
+
+func TestTransferOwnership(t *testing.T) {
+	timeout := 10 * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	id := generateName("transfer")
+	objects, err := readManifest("testdata/test2.yaml", id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, deployObject := getFirstObject(objects, "Deployment", id)
+
+	if err = normalize.UnstructuredList(objects); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("creates objects as helm", func(t *testing.T) {
+		for _, object := range objects {
+			obj := object.DeepCopy()
+			if err := manager.client.Create(ctx, obj, client.FieldOwner("helm")); err != nil {
+				t.Fatal(err)
+			}
+		}
+	})
+
+	t.Run("transfers ownership from helm", func(t *testing.T) {
+		changeSet, err := manager.TransferOwnership(ctx, objects, "helm")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, entry := range changeSet.Entries {
+			if diff := cmp.Diff(ConfiguredAction, entry.Action); diff != "" {
+				t.Errorf("Mismatch from expected value (-want +got):\n%s", diff)
+			}
+		}
+
+		deploy := deployObject.DeepCopy()
+		if err := manager.Client().Get(ctx, client.ObjectKeyFromObject(deploy), deploy); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, entry := range deploy.GetManagedFields() {
+			if diff := cmp.Diff(manager.owner.Field, entry.Manager); diff != "" {
+				t.Errorf("Mismatch from expected value (-want +got):\n%s", diff)
+			}
+		}
+	})
+
+	t.Run("does not change objects without helm fields", func(t *testing.T) {
+		changeSet, err := manager.TransferOwnership(ctx, objects, "helm")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, entry := range changeSet.Entries {
+			if diff := cmp.Diff(UnchangedAction, entry.Action); diff != "" {
+				t.Errorf("Mismatch from expected value (-want +got):\n%s", diff)
+			}
+		}
+	})
+
+	t.Run("applies objects without conflicts", func(t *testing.T) {
+		if _, err := manager.ApplyAllStaged(ctx, objects, DefaultApplyOptions()); err != nil {
+			t.Fatal(err)
+		}
+
+		deploy := deployObject.DeepCopy()
+		if err := manager.Client().Get(ctx, client.ObjectKeyFromObject(deploy), deploy); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, entry := range deploy.GetManagedFields() {
+			if diff := cmp.Diff(manager.owner.Field, entry.Manager); diff != "" {
+				t.Errorf("Mismatch from expected value (-want +got):\n%s", diff)
+			}
+		}
+	})
+
+	t.Run("does not transfer fields of managers sharing the prefix", func(t *testing.T) {
+		deploy := deployObject.DeepCopy()
+		if err := manager.Client().Get(ctx, client.ObjectKeyFromObject(deploy), deploy); err != nil {
+			t.Fatal(err)
+		}
+		labels := deploy.GetLabels()
+		if labels == nil {
+			labels = make(map[string]string)
+		}
+		labels["helmfile"] = "true"
+		deploy.SetLabels(labels)
+		if err := manager.client.Update(ctx, deploy, client.FieldOwner("helmfile")); err != nil {
+			t.Fatal(err)
+		}
+
+		changeSet, err := manager.TransferOwnership(ctx, objects, "helm")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, entry := range changeSet.Entries {
+			if diff := cmp.Diff(UnchangedAction, entry.Action); diff != "" {
+				t.Errorf("Mismatch from expected value (-want +got):\n%s", diff)
+			}
+		}
+
+		if err := manager.Client().Get(ctx, client.ObjectKeyFromObject(deploy), deploy); err != nil {
+			t.Fatal(err)
+		}
+		found := false
+		for _, entry := range deploy.GetManagedFields() {
+			if entry.Manager == "helmfile" {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("expected the helmfile manager to be kept, got %v", deploy.GetManagedFields())
+		}
+	})
+
+	t.Run("rejects the own field manager", func(t *testing.T) {
+		if _, err := manager.TransferOwnership(ctx, objects, manager.owner.Field); err == nil {
+			t.Error("expected error")
+		}
+	})
+}
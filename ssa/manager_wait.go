@@ -19,14 +19,15 @@ package ssa
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
 
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/util/wait"
-	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/fluxcd/cli-utils/pkg/kstatus/polling"
 	"github.com/fluxcd/cli-utils/pkg/kstatus/polling/aggregator"
@@ -62,6 +63,11 @@ func DefaultWaitOptions() WaitOptions {
 
 // Wait checks if the given set of objects has been fully reconciled.
 func (m *ResourceManager) Wait(objects []*unstructured.Unstructured, opts WaitOptions) error {
+	objects, err := utils.ExpandLists(objects)
+	if err != nil {
+		return err
+	}
+
 	objectsMeta := object.UnstructuredSetToObjMetadataSet(objects)
 	if len(objectsMeta) == 0 {
 		return nil
@@ -153,26 +159,84 @@ func (m *ResourceManager) WaitForSet(set object.ObjMetadataSet, opts WaitOptions
 	return nil
 }
 
+// ErrObjectRecreated is returned when an object is recreated by another actor
+// while waiting for its termination.
+var ErrObjectRecreated = errors.New("object recreated while waiting for termination")
+
 // WaitForTermination waits for the given objects to be deleted from the cluster.
+// The objects are tracked by UID, if an object is deleted and recreated while waiting,
+// the returned error wraps ErrObjectRecreated.
 func (m *ResourceManager) WaitForTermination(objects []*unstructured.Unstructured, opts WaitOptions) error {
+	objects, err := utils.ExpandLists(objects)
+	if err != nil {
+		return err
+	}
+
+	return m.waitForTermination(objects, opts, false)
+}
+
+// WaitForSetTermination waits for the objects of the given ChangeSet which have been
+// deleted to be removed from the cluster. The objects are tracked by UID, an object
+// found without a deletion timestamp, or with a different UID than the terminating one,
+// is considered recreated and reported with an error which wraps ErrObjectRecreated.
+func (m *ResourceManager) WaitForSetTermination(cs ChangeSet, opts WaitOptions) error {
+	var objects []*unstructured.Unstructured
+	for _, entry := range cs.Entries {
+		if entry.Action != DeletedAction {
+			continue
+		}
+		u := &unstructured.Unstructured{}
+		u.SetGroupVersionKind(schema.FromAPIVersionAndKind(entry.GroupVersion, entry.ObjMetadata.GroupKind.Kind))
+		u.SetName(entry.ObjMetadata.Name)
+		u.SetNamespace(entry.ObjMetadata.Namespace)
+		objects = append(objects, u)
+	}
+
+	return m.waitForTermination(objects, opts, true)
+}
+
+// waitForTermination polls the cluster until the given objects are deleted or recreated.
+// If terminating is true, the objects are expected to have a deletion timestamp set.
+func (m *ResourceManager) waitForTermination(objects []*unstructured.Unstructured, opts WaitOptions, terminating bool) error {
 	ctx, cancel := context.WithTimeout(context.Background(), opts.Timeout)
 	defer cancel()
 
+	var recreated []error
 	for _, object := range objects {
-		if err := wait.PollUntilContextCancel(ctx, opts.Interval, true, m.isDeleted(object)); err != nil {
-			return fmt.Errorf("%s termination timeout: %w", utils.FmtUnstructured(object), err)
+		err := wait.PollUntilContextCancel(ctx, opts.Interval, true, m.isDeleted(object, terminating))
+		if err == nil {
+			continue
+		}
+		if errors.Is(err, ErrObjectRecreated) {
+			recreated = append(recreated, err)
+			continue
 		}
+		return fmt.Errorf("%s termination timeout: %w", utils.FmtUnstructured(object), err)
 	}
-	return nil
+	return errors.Join(recreated...)
 }
 
-func (m *ResourceManager) isDeleted(object *unstructured.Unstructured) wait.ConditionWithContextFunc {
+func (m *ResourceManager) isDeleted(object *unstructured.Unstructured, terminating bool) wait.ConditionWithContextFunc {
+	uid := object.GetUID()
 	return func(ctx context.Context) (bool, error) {
-		obj := object.DeepCopy()
-		err := m.client.Get(ctx, client.ObjectKeyFromObject(obj), obj)
+		existingObject, err := m.getExisting(ctx, object)
 		if apierrors.IsNotFound(err) {
 			return true, nil
 		}
-		return false, err
+		if err != nil {
+			return false, err
+		}
+
+		switch {
+		case uid == "" && terminating && existingObject.GetDeletionTimestamp() == nil:
+			return false, fmt.Errorf("%s %w: uid %s is not terminating",
+				utils.FmtUnstructured(object), ErrObjectRecreated, existingObject.GetUID())
+		case uid == "":
+			uid = existingObject.GetUID()
+		case uid != existingObject.GetUID():
+			return false, fmt.Errorf("%s %w: uid changed from %s to %s",
+				utils.FmtUnstructured(object), ErrObjectRecreated, uid, existingObject.GetUID())
+		}
+		return false, nil
 	}
 }
@@ -19,6 +19,7 @@ package ssa
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
 	"testing"
@@ -28,6 +29,7 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
@@ -264,3 +266,64 @@ func TestWaitForSet_failFast(t *testing.T) {
 		}
 	})
 }
+
+func TestWaitForTermination_Recreated(t *testing.T) {
+	timeout := 10 * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	id := generateName("recreate")
+	cm := &corev1.ConfigMap{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "ConfigMap",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      id,
+			Namespace: "default",
+		},
+	}
+	if err := manager.client.Create(ctx, cm); err != nil {
+		t.Fatal(err)
+	}
+
+	u, err := runtime.DefaultUnstructuredConverter.ToUnstructured(cm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	object := &unstructured.Unstructured{Object: u}
+	object.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("ConfigMap"))
+
+	changeSet, err := manager.DeleteAll(ctx, []*unstructured.Unstructured{object.DeepCopy()}, DefaultDeleteOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// recreate the object as if another actor did it
+	recreated := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      id,
+			Namespace: "default",
+		},
+	}
+	if err := manager.client.Create(ctx, recreated); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("detects recreation by UID", func(t *testing.T) {
+		err := manager.WaitForTermination([]*unstructured.Unstructured{object}, WaitOptions{time.Second, 3 * time.Second, false})
+		if !errors.Is(err, ErrObjectRecreated) {
+			t.Fatalf("expected recreated error, got: %v", err)
+		}
+		if !strings.Contains(err.Error(), string(cm.GetUID())) {
+			t.Errorf("expected error to contain the previous UID, got: %v", err)
+		}
+	})
+
+	t.Run("detects recreation of change set entries", func(t *testing.T) {
+		err := manager.WaitForSetTermination(*changeSet, WaitOptions{time.Second, 3 * time.Second, false})
+		if !errors.Is(err, ErrObjectRecreated) {
+			t.Fatalf("expected recreated error, got: %v", err)
+		}
+	})
+}
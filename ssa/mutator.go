@@ -0,0 +1,110 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ssa
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/fluxcd/pkg/ssa/utils"
+)
+
+// Mutator transforms an object before it is applied, e.g. to set default
+// labels, annotations or namespace, rewrite container images or add
+// tolerations.
+type Mutator interface {
+	// Mutate modifies the given object in place.
+	Mutate(object *unstructured.Unstructured) error
+}
+
+// MutatorFunc is a function implementing Mutator.
+type MutatorFunc func(object *unstructured.Unstructured) error
+
+// Mutate calls f(object).
+func (f MutatorFunc) Mutate(object *unstructured.Unstructured) error {
+	return f(object)
+}
+
+// SetLabels returns a Mutator which sets the given 'metadata.labels' entries
+// on the objects, overriding the existing values.
+func SetLabels(labels map[string]string) Mutator {
+	return MutatorFunc(func(object *unstructured.Unstructured) error {
+		object.SetLabels(mergeMaps(object.GetLabels(), labels))
+		return nil
+	})
+}
+
+// SetAnnotations returns a Mutator which sets the given 'metadata.annotations'
+// entries on the objects, overriding the existing values. Unlike
+// ApplyOptions.Annotations, the annotations are subject to drift detection.
+func SetAnnotations(annotations map[string]string) Mutator {
+	return MutatorFunc(func(object *unstructured.Unstructured) error {
+		object.SetAnnotations(mergeMaps(object.GetAnnotations(), annotations))
+		return nil
+	})
+}
+
+// OwnerLabelsMutator returns a Mutator which sets the owner labels for the
+// specified name and namespace on the objects, see SetOwnerLabels.
+func (m *ResourceManager) OwnerLabelsMutator(name, namespace string) Mutator {
+	return SetLabels(m.GetOwnerLabels(name, namespace))
+}
+
+// DefaultNamespaceMutator returns a Mutator which sets the given namespace on
+// the namespaced objects which don't specify one.
+func (m *ResourceManager) DefaultNamespaceMutator(namespace string) Mutator {
+	return MutatorFunc(func(object *unstructured.Unstructured) error {
+		if object.GetNamespace() != "" {
+			return nil
+		}
+		namespaced, err := m.client.IsObjectNamespaced(object)
+		if err != nil {
+			return err
+		}
+		if namespaced {
+			object.SetNamespace(namespace)
+		}
+		return nil
+	})
+}
+
+// mutate returns a copy of the given object transformed by the mutators
+// in order, or the object itself if there are no mutators.
+func mutate(object *unstructured.Unstructured, mutators []Mutator) (*unstructured.Unstructured, error) {
+	if len(mutators) == 0 {
+		return object, nil
+	}
+
+	result := object.DeepCopy()
+	for _, mutator := range mutators {
+		if err := mutator.Mutate(result); err != nil {
+			return nil, fmt.Errorf("%s mutation failed: %w", utils.FmtUnstructured(object), err)
+		}
+	}
+	return result, nil
+}
+
+func mergeMaps(dst, src map[string]string) map[string]string {
+	if dst == nil {
+		dst = make(map[string]string, len(src))
+	}
+	for k, v := range src {
+		dst[k] = v
+	}
+	return dst
+}
@@ -0,0 +1,115 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ssa
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/fluxcd/pkg/ssa/utils"
+)
+
+// CreateNamespaceOptions defines the metadata of the namespaces created
+// for the namespaced objects whose namespace does not exist.
+type CreateNamespaceOptions struct {
+	// Labels defines the 'metadata.labels' entries to be set on the created namespaces.
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Annotations defines the 'metadata.annotations' entries to be set on the created namespaces.
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	// OwnerName and OwnerNamespace, when set, add the ownership labels of the
+	// resource manager to the created namespaces, as done by SetOwnerLabels.
+	OwnerName      string `json:"ownerName,omitempty"`
+	OwnerNamespace string `json:"ownerNamespace,omitempty"`
+}
+
+// createNamespaces creates the namespaces of the given namespaced objects
+// which don't exist in-cluster, unless the namespace is defined in the objects.
+// It returns a change set entry with the Created action for each new namespace.
+func (m *ResourceManager) createNamespaces(ctx context.Context, objects []*unstructured.Unstructured, opts ApplyOptions) ([]ChangeSetEntry, error) {
+	if opts.CreateNamespace == nil {
+		return nil, nil
+	}
+
+	defined := make(map[string]bool)
+	required := make(map[string]bool)
+	for _, object := range objects {
+		object, err := mutate(object, opts.Mutators)
+		if err != nil {
+			return nil, err
+		}
+		if utils.IsNamespace(object) {
+			defined[object.GetName()] = true
+			continue
+		}
+		if ns := object.GetNamespace(); ns != "" {
+			required[ns] = true
+		}
+	}
+
+	var names []string
+	for ns := range required {
+		if !defined[ns] {
+			names = append(names, ns)
+		}
+	}
+	sort.Strings(names)
+
+	var entries []ChangeSetEntry
+	for _, name := range names {
+		namespace := &unstructured.Unstructured{}
+		namespace.SetGroupVersionKind(schema.GroupVersionKind{Version: "v1", Kind: "Namespace"})
+		namespace.SetName(name)
+
+		err := m.client.Get(ctx, client.ObjectKeyFromObject(namespace), namespace.DeepCopy())
+		if err == nil {
+			continue
+		}
+		if !apierrors.IsNotFound(err) {
+			return nil, fmt.Errorf("%s query failed: %w", utils.FmtUnstructured(namespace), err)
+		}
+
+		labels := make(map[string]string, len(opts.CreateNamespace.Labels))
+		for k, v := range opts.CreateNamespace.Labels {
+			labels[k] = v
+		}
+		if opts.CreateNamespace.OwnerName != "" {
+			for k, v := range m.GetOwnerLabels(opts.CreateNamespace.OwnerName, opts.CreateNamespace.OwnerNamespace) {
+				labels[k] = v
+			}
+		}
+		if len(labels) > 0 {
+			namespace.SetLabels(labels)
+		}
+		if len(opts.CreateNamespace.Annotations) > 0 {
+			namespace.SetAnnotations(opts.CreateNamespace.Annotations)
+		}
+
+		if err := m.apply(ctx, namespace); err != nil {
+			return nil, fmt.Errorf("%s apply failed: %w", utils.FmtUnstructured(namespace), err)
+		}
+		entries = append(entries, *m.changeSetEntry(namespace, CreatedAction))
+	}
+	return entries, nil
+}
@@ -0,0 +1,98 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ssa
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestApplyAll_CreateNamespace(t *testing.T) {
+	timeout := 10 * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	id := generateName("create-ns")
+	configMap := &unstructured.Unstructured{}
+	configMap.SetAPIVersion("v1")
+	configMap.SetKind("ConfigMap")
+	configMap.SetName(id)
+	configMap.SetNamespace(id)
+
+	opts := DefaultApplyOptions()
+	opts.CreateNamespace = &CreateNamespaceOptions{
+		Labels:         map[string]string{"env": "test"},
+		Annotations:    map[string]string{"owner": "team"},
+		OwnerName:      "app",
+		OwnerNamespace: "flux-system",
+	}
+
+	t.Run("creates missing namespace", func(t *testing.T) {
+		changeSet, err := manager.ApplyAll(ctx, []*unstructured.Unstructured{configMap}, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(changeSet.Entries) != 2 {
+			t.Fatalf("expected 2 entries, got %v", changeSet.Entries)
+		}
+		for _, entry := range changeSet.Entries {
+			if entry.Action != CreatedAction {
+				t.Errorf("expected %s to be created, got %s", entry.Subject, entry.Action)
+			}
+		}
+		if kind := changeSet.Entries[0].ObjMetadata.GroupKind.Kind; kind != "Namespace" {
+			t.Errorf("expected Namespace entry, got %s", kind)
+		}
+
+		ns := &corev1.Namespace{}
+		if err := manager.client.Get(ctx, client.ObjectKey{Name: id}, ns); err != nil {
+			t.Fatal(err)
+		}
+		for k, v := range map[string]string{
+			"env":                           "test",
+			"resource-manager.io/name":      "app",
+			"resource-manager.io/namespace": "flux-system",
+		} {
+			if ns.Labels[k] != v {
+				t.Errorf("expected label %s=%s, got %q", k, v, ns.Labels[k])
+			}
+		}
+		if ns.Annotations["owner"] != "team" {
+			t.Errorf("expected annotation owner=team, got %v", ns.Annotations)
+		}
+	})
+
+	t.Run("skips existing namespace", func(t *testing.T) {
+		changeSet, err := manager.ApplyAll(ctx, []*unstructured.Unstructured{configMap}, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(changeSet.Entries) != 1 {
+			t.Fatalf("expected 1 entry, got %v", changeSet.Entries)
+		}
+		if action := changeSet.Entries[0].Action; action != UnchangedAction {
+			t.Errorf("expected unchanged action, got %s", action)
+		}
+	})
+}
@@ -98,6 +98,13 @@ func PatchRemoveFieldsManagers(object *unstructured.Unstructured, managers []Fie
 // PatchReplaceFieldsManagers returns a jsonPatch array for replacing the managers with matching prefix and operation type
 // with the specified manager name and an apply operation.
 func PatchReplaceFieldsManagers(object *unstructured.Unstructured, managers []FieldManager, name string) ([]jsonPatch, error) {
+	return patchReplaceFieldsManagers(object, managers, name, strings.HasPrefix)
+}
+
+// patchReplaceFieldsManagers returns a jsonPatch array for replacing the managers matching the given function
+// and operation type with the specified manager name and an apply operation.
+func patchReplaceFieldsManagers(object *unstructured.Unstructured, managers []FieldManager, name string,
+	match func(entryManager, manager string) bool) ([]jsonPatch, error) {
 	objEntries := object.GetManagedFields()
 
 	var prevManagedFields metav1.ManagedFieldsEntry
@@ -124,7 +131,7 @@ each_entry:
 		}
 
 		for _, manager := range managers {
-			if strings.HasPrefix(entry.Manager, manager.Name) &&
+			if match(entry.Manager, manager.Name) &&
 				entry.Operation == manager.OperationType &&
 				entry.Subresource == "" {
 
@@ -0,0 +1,281 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ssa
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	ssaerrors "github.com/fluxcd/pkg/ssa/errors"
+	"github.com/fluxcd/pkg/ssa/utils"
+)
+
+// quotaComputeResources maps the compute resources of containers to the
+// ResourceQuota names accounting for their requests and limits.
+var quotaComputeResources = map[corev1.ResourceName]struct {
+	requests []corev1.ResourceName
+	limits   corev1.ResourceName
+}{
+	corev1.ResourceCPU: {
+		requests: []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceRequestsCPU},
+		limits:   corev1.ResourceLimitsCPU,
+	},
+	corev1.ResourceMemory: {
+		requests: []corev1.ResourceName{corev1.ResourceMemory, corev1.ResourceRequestsMemory},
+		limits:   corev1.ResourceLimitsMemory,
+	},
+	corev1.ResourceEphemeralStorage: {
+		requests: []corev1.ResourceName{corev1.ResourceEphemeralStorage, corev1.ResourceRequestsEphemeralStorage},
+		limits:   corev1.ResourceLimitsEphemeralStorage,
+	},
+}
+
+// CheckQuotas sums the resources requested by the Pod templates of the given objects
+// and compares them against the hard limits of the ResourceQuotas in their namespaces.
+// For objects that exist in-cluster, only the increase over the current Pod templates
+// is accounted for. Scoped ResourceQuotas are not evaluated.
+// If any quota would be exceeded, a *errors.QuotaExceededErr is returned.
+func (m *ResourceManager) CheckQuotas(ctx context.Context, objects []*unstructured.Unstructured) error {
+	return m.checkQuotas(ctx, objects, nil)
+}
+
+func (m *ResourceManager) checkQuotas(ctx context.Context, objects []*unstructured.Unstructured, mutators []Mutator) error {
+	requested := make(map[string]corev1.ResourceList)
+	for _, object := range objects {
+		object, err := mutate(object, mutators)
+		if err != nil {
+			return err
+		}
+
+		if object.GetNamespace() == "" {
+			continue
+		}
+
+		usage, ok, err := quotaUsage(object)
+		if err != nil {
+			return fmt.Errorf("%s quota check failed: %w", utils.FmtUnstructured(object), err)
+		}
+		if !ok {
+			continue
+		}
+
+		existingObject := &unstructured.Unstructured{}
+		existingObject.SetGroupVersionKind(object.GroupVersionKind())
+		err = m.client.Get(ctx, client.ObjectKeyFromObject(object), existingObject)
+		switch {
+		case err == nil:
+			current, _, err := quotaUsage(existingObject)
+			if err != nil {
+				return fmt.Errorf("%s quota check failed: %w", utils.FmtUnstructured(existingObject), err)
+			}
+			for name, quantity := range current {
+				if q, ok := usage[name]; ok {
+					q.Sub(quantity)
+					usage[name] = q
+				}
+			}
+		case apierrors.IsNotFound(err):
+		default:
+			return fmt.Errorf("%s query failed: %w", utils.FmtUnstructured(object), err)
+		}
+
+		list, ok := requested[object.GetNamespace()]
+		if !ok {
+			list = make(corev1.ResourceList)
+			requested[object.GetNamespace()] = list
+		}
+		for name, quantity := range usage {
+			if quantity.Sign() <= 0 {
+				continue
+			}
+			q := list[name]
+			q.Add(quantity)
+			list[name] = q
+		}
+	}
+
+	namespaces := make([]string, 0, len(requested))
+	for ns := range requested {
+		namespaces = append(namespaces, ns)
+	}
+	sort.Strings(namespaces)
+
+	var exceeded []ssaerrors.QuotaExceeded
+	for _, ns := range namespaces {
+		quotas := &corev1.ResourceQuotaList{}
+		if err := m.client.List(ctx, quotas, client.InNamespace(ns)); err != nil {
+			return fmt.Errorf("failed to list ResourceQuotas in namespace '%s': %w", ns, err)
+		}
+
+		names := make([]string, 0, len(requested[ns]))
+		for name := range requested[ns] {
+			names = append(names, string(name))
+		}
+		sort.Strings(names)
+
+		for _, quota := range quotas.Items {
+			if len(quota.Spec.Scopes) > 0 || quota.Spec.ScopeSelector != nil {
+				continue
+			}
+			for _, n := range names {
+				name := corev1.ResourceName(n)
+				hard, ok := quota.Status.Hard[name]
+				if !ok {
+					if hard, ok = quota.Spec.Hard[name]; !ok {
+						continue
+					}
+				}
+				used := quota.Status.Used[name]
+				total := used.DeepCopy()
+				total.Add(requested[ns][name])
+				if total.Cmp(hard) > 0 {
+					exceeded = append(exceeded, ssaerrors.QuotaExceeded{
+						Namespace: quota.GetNamespace(),
+						Name:      quota.GetName(),
+						Resource:  name,
+						Hard:      hard,
+						Used:      used,
+						Requested: requested[ns][name],
+					})
+				}
+			}
+		}
+	}
+
+	if len(exceeded) > 0 {
+		return ssaerrors.NewQuotaExceededErr(exceeded...)
+	}
+	return nil
+}
+
+// quotaUsage returns the resources accounted by ResourceQuotas for the Pods
+// created from the given object, and false if the object does not create Pods.
+func quotaUsage(object *unstructured.Unstructured) (corev1.ResourceList, bool, error) {
+	spec, replicas, ok, err := podTemplate(object)
+	if err != nil || !ok {
+		return nil, false, err
+	}
+
+	usage := podQuotaUsage(spec)
+	for name, quantity := range usage {
+		usage[name] = scaleQuantity(quantity, replicas)
+	}
+	return usage, true, nil
+}
+
+// scaleQuantity returns the given quantity multiplied by n.
+func scaleQuantity(q resource.Quantity, n int64) resource.Quantity {
+	if v, ok := q.AsInt64(); ok {
+		return *resource.NewQuantity(v*n, q.Format)
+	}
+	return *resource.NewMilliQuantity(q.MilliValue()*n, q.Format)
+}
+
+// podTemplate extracts the Pod spec and the number of Pods of the workload
+// kinds with a known replica count. DaemonSets are ignored since their
+// number of Pods depends on the cluster nodes, and CronJobs since their
+// Pods are only created when the Jobs are scheduled.
+func podTemplate(object *unstructured.Unstructured) (*corev1.PodSpec, int64, bool, error) {
+	var specPath, replicasPath []string
+	switch object.GroupVersionKind().GroupKind() {
+	case schema.GroupKind{Kind: "Pod"}:
+		specPath = []string{"spec"}
+	case schema.GroupKind{Kind: "ReplicationController"},
+		schema.GroupKind{Group: "apps", Kind: "Deployment"},
+		schema.GroupKind{Group: "apps", Kind: "ReplicaSet"},
+		schema.GroupKind{Group: "apps", Kind: "StatefulSet"}:
+		specPath = []string{"spec", "template", "spec"}
+		replicasPath = []string{"spec", "replicas"}
+	case schema.GroupKind{Group: "batch", Kind: "Job"}:
+		specPath = []string{"spec", "template", "spec"}
+		replicasPath = []string{"spec", "parallelism"}
+	default:
+		return nil, 0, false, nil
+	}
+
+	replicas := int64(1)
+	if replicasPath != nil {
+		r, found, err := unstructured.NestedInt64(object.Object, replicasPath...)
+		if err != nil {
+			return nil, 0, false, err
+		}
+		if found {
+			replicas = r
+		}
+	}
+
+	m, found, err := unstructured.NestedMap(object.Object, specPath...)
+	if err != nil || !found {
+		return nil, 0, false, err
+	}
+
+	spec := &corev1.PodSpec{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(m, spec); err != nil {
+		return nil, 0, false, err
+	}
+	return spec, replicas, true, nil
+}
+
+// podQuotaUsage returns the resources accounted by ResourceQuotas for a Pod,
+// where the requirement of each compute resource is the greater of the sum
+// of the containers and the largest init container, plus the Pod overhead.
+func podQuotaUsage(spec *corev1.PodSpec) corev1.ResourceList {
+	usage := corev1.ResourceList{
+		corev1.ResourcePods: *resource.NewQuantity(1, resource.DecimalSI),
+	}
+
+	effective := func(list func(corev1.ResourceRequirements) corev1.ResourceList, name corev1.ResourceName) resource.Quantity {
+		var total resource.Quantity
+		for _, c := range spec.Containers {
+			if q, ok := list(c.Resources)[name]; ok {
+				total.Add(q)
+			}
+		}
+		for _, c := range spec.InitContainers {
+			if q, ok := list(c.Resources)[name]; ok && q.Cmp(total) > 0 {
+				total = q.DeepCopy()
+			}
+		}
+		if q, ok := spec.Overhead[name]; ok && !total.IsZero() {
+			total.Add(q)
+		}
+		return total
+	}
+	requests := func(r corev1.ResourceRequirements) corev1.ResourceList { return r.Requests }
+	limits := func(r corev1.ResourceRequirements) corev1.ResourceList { return r.Limits }
+
+	for name, quota := range quotaComputeResources {
+		if q := effective(requests, name); !q.IsZero() {
+			for _, n := range quota.requests {
+				usage[n] = q.DeepCopy()
+			}
+		}
+		if q := effective(limits, name); !q.IsZero() {
+			usage[quota.limits] = q
+		}
+	}
+	return usage
+}
@@ -0,0 +1,124 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ssa
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	ssaerrors "github.com/fluxcd/pkg/ssa/errors"
+)
+
+func TestApplyAll_CheckQuotas(t *testing.T) {
+	timeout := 10 * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	id := generateName("quota")
+	objects, err := readManifest("testdata/test11.yaml", id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, deployment := getFirstObject(objects, "Deployment", id)
+	var quota []*unstructured.Unstructured
+	for _, object := range objects {
+		if object.GetKind() != "Deployment" {
+			quota = append(quota, object)
+		}
+	}
+
+	opts := DefaultApplyOptions()
+	opts.CheckQuotas = true
+
+	if _, err := manager.ApplyAllStaged(ctx, quota, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	setReplicas := func(replicas int64) {
+		if err := unstructured.SetNestedField(deployment.Object, replicas, "spec", "replicas"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("fails when quotas are exceeded", func(t *testing.T) {
+		_, err := manager.ApplyAll(ctx, []*unstructured.Unstructured{deployment}, opts)
+		var quotaErr *ssaerrors.QuotaExceededErr
+		if !errors.As(err, &quotaErr) {
+			t.Fatalf("Expected QuotaExceededErr, got %v", err)
+		}
+
+		exceeded := map[corev1.ResourceName]string{}
+		for _, q := range quotaErr.Exceeded() {
+			exceeded[q.Resource] = q.Requested.String()
+		}
+		expected := map[corev1.ResourceName]string{
+			corev1.ResourcePods:        "3",
+			corev1.ResourceRequestsCPU: "1500m",
+		}
+		if len(exceeded) != len(expected) {
+			t.Fatalf("Expected %v exceeded, got %v", expected, exceeded)
+		}
+		for name, requested := range expected {
+			if exceeded[name] != requested {
+				t.Errorf("Expected %s requested %s, got %s", name, requested, exceeded[name])
+			}
+		}
+
+		if err := manager.client.Get(ctx, client.ObjectKeyFromObject(deployment), deployment.DeepCopy()); !apierrors.IsNotFound(err) {
+			t.Errorf("Expected Deployment to not be applied, got %v", err)
+		}
+	})
+
+	t.Run("accounts for the namespace set by mutators", func(t *testing.T) {
+		object := deployment.DeepCopy()
+		object.SetNamespace("")
+
+		mutators := []Mutator{MutatorFunc(func(object *unstructured.Unstructured) error {
+			object.SetNamespace(deployment.GetNamespace())
+			return nil
+		})}
+		err := manager.checkQuotas(ctx, []*unstructured.Unstructured{object}, mutators)
+		var quotaErr *ssaerrors.QuotaExceededErr
+		if !errors.As(err, &quotaErr) {
+			t.Fatalf("Expected QuotaExceededErr, got %v", err)
+		}
+	})
+
+	t.Run("applies within quotas", func(t *testing.T) {
+		setReplicas(1)
+		if _, err := manager.ApplyAll(ctx, []*unstructured.Unstructured{deployment}, opts); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("accounts only for the increase of existing objects", func(t *testing.T) {
+		// The quota status is not populated in the test environment, hence scaling
+		// from one to three replicas requests exactly the hard limit.
+		setReplicas(3)
+		if _, err := manager.ApplyAll(ctx, []*unstructured.Unstructured{deployment}, opts); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
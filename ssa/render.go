@@ -0,0 +1,107 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ssa
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/pmezard/go-difflib/difflib"
+	"github.com/wI2L/jsondiff"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+
+	"github.com/fluxcd/pkg/ssa/utils"
+)
+
+// DiffFormat represents the format in which RenderDiff renders differences.
+type DiffFormat string
+
+const (
+	// UnifiedDiffFormat renders the differences as a unified diff of the
+	// YAML representations of the objects.
+	UnifiedDiffFormat DiffFormat = "unified"
+	// JSONPatchDiffFormat renders the differences as an RFC 6902 JSON patch
+	// which transforms the live object into the desired object.
+	JSONPatchDiffFormat DiffFormat = "jsonpatch"
+)
+
+// RenderDiff renders the differences between the live and desired objects
+// returned by Diff for the given entry, in the given format. It returns an
+// empty string for entries other than ConfiguredAction. The managed fields
+// are excluded, and the data values of Secrets are masked.
+func RenderDiff(entry *ChangeSetEntry, live, desired *unstructured.Unstructured, format DiffFormat) (string, error) {
+	if entry == nil || entry.Action != ConfiguredAction {
+		return "", nil
+	}
+	if live == nil || desired == nil {
+		return "", fmt.Errorf("%s diff failed: live and desired objects are required", entry.Subject)
+	}
+
+	live, desired = live.DeepCopy(), desired.DeepCopy()
+	unstructured.RemoveNestedField(live.Object, "metadata", "managedFields")
+	unstructured.RemoveNestedField(desired.Object, "metadata", "managedFields")
+	if utils.IsSecret(desired) {
+		if err := SanitizeUnstructuredData(live, desired); err != nil {
+			return "", err
+		}
+	}
+
+	switch format {
+	case UnifiedDiffFormat:
+		return renderUnifiedDiff(entry.Subject, live, desired)
+	case JSONPatchDiffFormat:
+		return renderJSONPatch(entry.Subject, live, desired)
+	default:
+		return "", fmt.Errorf("unsupported diff format '%s'", format)
+	}
+}
+
+func renderUnifiedDiff(subject string, live, desired *unstructured.Unstructured) (string, error) {
+	liveYAML, err := yaml.Marshal(live.Object)
+	if err != nil {
+		return "", fmt.Errorf("%s diff failed: %w", subject, err)
+	}
+	desiredYAML, err := yaml.Marshal(desired.Object)
+	if err != nil {
+		return "", fmt.Errorf("%s diff failed: %w", subject, err)
+	}
+
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(string(liveYAML)),
+		B:        difflib.SplitLines(string(desiredYAML)),
+		FromFile: subject + " (live)",
+		ToFile:   subject + " (desired)",
+		Context:  3,
+	})
+}
+
+func renderJSONPatch(subject string, live, desired *unstructured.Unstructured) (string, error) {
+	patch, err := jsondiff.Compare(live.Object, desired.Object)
+	if err != nil {
+		return "", fmt.Errorf("%s diff failed: %w", subject, err)
+	}
+	if len(patch) == 0 {
+		return "[]", nil
+	}
+
+	data, err := json.Marshal(patch)
+	if err != nil {
+		return "", fmt.Errorf("%s diff failed: %w", subject, err)
+	}
+	return string(data), nil
+}
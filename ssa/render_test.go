@@ -0,0 +1,120 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ssa
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestRenderDiff(t *testing.T) {
+	newObject := func(kind, value string) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       kind,
+			"metadata": map[string]interface{}{
+				"name":          "test",
+				"namespace":     "default",
+				"managedFields": []interface{}{map[string]interface{}{"manager": "test"}},
+			},
+			"data": map[string]interface{}{
+				"key":   value,
+				"other": "same",
+			},
+		}}
+	}
+
+	tests := []struct {
+		name    string
+		kind    string
+		action  Action
+		format  DiffFormat
+		want    string
+		wantErr bool
+	}{
+		{
+			name:   "unified diff",
+			kind:   "ConfigMap",
+			action: ConfiguredAction,
+			format: UnifiedDiffFormat,
+			want: `--- ConfigMap/default/test (live)
++++ ConfigMap/default/test (desired)
+@@ -1,6 +1,6 @@
+ apiVersion: v1
+ data:
+-  key: a
++  key: b
+   other: same
+ kind: ConfigMap
+ metadata:
+`,
+		},
+		{
+			name:   "JSON patch",
+			kind:   "ConfigMap",
+			action: ConfiguredAction,
+			format: JSONPatchDiffFormat,
+			want:   `[{"op":"replace","path":"/data/key","value":"b"}]`,
+		},
+		{
+			name:   "masked Secret",
+			kind:   "Secret",
+			action: ConfiguredAction,
+			format: JSONPatchDiffFormat,
+			want:   `[{"op":"replace","path":"/data/key","value":"*** (after)"}]`,
+		},
+		{
+			name:   "unchanged entry",
+			kind:   "ConfigMap",
+			action: UnchangedAction,
+			format: UnifiedDiffFormat,
+			want:   "",
+		},
+		{
+			name:    "unsupported format",
+			kind:    "ConfigMap",
+			action:  ConfiguredAction,
+			format:  "html",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			live, desired := newObject(tt.kind, "a"), newObject(tt.kind, "b")
+			entry := &ChangeSetEntry{
+				Subject: strings.Join([]string{tt.kind, "default", "test"}, "/"),
+				Action:  tt.action,
+			}
+
+			got, err := RenderDiff(entry, live, desired, tt.format)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("RenderDiff() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("RenderDiff() mismatch (-want +got):\n%s", diff)
+			}
+
+			if v, _, _ := unstructured.NestedString(live.Object, "data", "key"); v != "a" {
+				t.Errorf("Expected live object to not be modified, got %s", v)
+			}
+		})
+	}
+}
@@ -18,6 +18,7 @@ package utils
 
 import (
 	"encoding/json"
+	"fmt"
 	"io"
 	"strings"
 
@@ -76,6 +77,45 @@ func ReadObjects(r io.Reader) ([]*unstructured.Unstructured, error) {
 	return objects, nil
 }
 
+// ExpandLists returns the given objects with the items of List objects in place of the lists,
+// nested lists are expanded recursively. The items share their content with the lists.
+// If there are no List objects, the given slice is returned as is.
+func ExpandLists(objects []*unstructured.Unstructured) ([]*unstructured.Unstructured, error) {
+	hasLists := false
+	for _, obj := range objects {
+		if obj.IsList() {
+			hasLists = true
+			break
+		}
+	}
+	if !hasLists {
+		return objects, nil
+	}
+
+	result := make([]*unstructured.Unstructured, 0, len(objects))
+	for _, obj := range objects {
+		if !obj.IsList() {
+			result = append(result, obj)
+			continue
+		}
+
+		var items []*unstructured.Unstructured
+		err := obj.EachListItem(func(item runtime.Object) error {
+			items = append(items, item.(*unstructured.Unstructured))
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("%s items decoding failed: %w", FmtUnstructured(obj), err)
+		}
+		items, err = ExpandLists(items)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, items...)
+	}
+	return result, nil
+}
+
 // ObjectToYAML encodes the given Kubernetes API object to YAML.
 func ObjectToYAML(object *unstructured.Unstructured) string {
 	var builder strings.Builder
@@ -90,3 +90,59 @@ stringData:
 		})
 	}
 }
+
+func TestExpandLists(t *testing.T) {
+	objects, err := ReadObjects(strings.NewReader(`
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: first
+  namespace: default
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	list, err := ReadObject(strings.NewReader(`
+apiVersion: v1
+kind: List
+items:
+- apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: second
+    namespace: default
+- apiVersion: v1
+  kind: List
+  items:
+  - apiVersion: v1
+    kind: ConfigMap
+    metadata:
+      name: third
+      namespace: default
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expanded, err := ExpandLists(objects)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if &expanded[0] != &objects[0] {
+		t.Errorf("expected objects without lists to be returned as is")
+	}
+
+	expanded, err = ExpandLists(append(objects, list))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, obj := range expanded {
+		names = append(names, obj.GetName())
+	}
+	if got, want := strings.Join(names, ","), "first,second,third"; got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+}
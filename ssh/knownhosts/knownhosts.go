@@ -25,6 +25,7 @@ import (
 	"io"
 	"net"
 	"strings"
+	"time"
 
 	"golang.org/x/crypto/ssh"
 	"golang.org/x/crypto/ssh/knownhosts"
@@ -119,11 +120,13 @@ func (p *hostPattern) match(a addr) bool {
 type inMemoryHostKeyDB struct {
 	hostKeys []hostKey
 	revoked  map[string]*ssh.PublicKey
+	now      func() time.Time
 }
 
 func newInMemoryHostKeyDB() *inMemoryHostKeyDB {
 	db := &inMemoryHostKeyDB{
 		revoked: make(map[string]*ssh.PublicKey),
+		now:     time.Now,
 	}
 
 	return db
@@ -137,6 +140,13 @@ type hostKey struct {
 	matcher matcher
 	cert    bool
 	key     ssh.PublicKey
+	// notAfter is the end of the rotation window in which the key is
+	// still accepted. The zero value means the key does not expire.
+	notAfter time.Time
+}
+
+func (l *hostKey) expired(now time.Time) bool {
+	return !l.notAfter.IsZero() && now.After(l.notAfter)
 }
 
 func (l *hostKey) match(a addr) bool {
@@ -208,7 +218,7 @@ func parseLine(line []byte) (marker, host string, key ssh.PublicKey, err error)
 	return marker, host, key, nil
 }
 
-func (db *inMemoryHostKeyDB) parseLine(line []byte) error {
+func (db *inMemoryHostKeyDB) parseLine(line []byte, notAfter time.Time) error {
 	marker, pattern, key, err := parseLine(line)
 	if err != nil {
 		return err
@@ -220,8 +230,9 @@ func (db *inMemoryHostKeyDB) parseLine(line []byte) error {
 	}
 
 	entry := hostKey{
-		key:  key,
-		cert: marker == markerCert,
+		key:      key,
+		cert:     marker == markerCert,
+		notAfter: notAfter,
 	}
 
 	if pattern[0] == '|' {
@@ -284,47 +295,91 @@ func (db *inMemoryHostKeyDB) check(address string, remote net.Addr, remoteKey ss
 		return &knownhosts.RevokedError{Revoked: knownhosts.KnownKey{Key: *revoked}}
 	}
 
+	a, err := hostToCheck(address, remote)
+	if err != nil {
+		return err
+	}
+	return db.checkAddr(a, remoteKey)
+}
+
+// hostKeyFallback checks a key against the host database like check,
+// and wraps the returned *knownhosts.KeyError in a KeyMismatchError
+// describing the presented and the expected keys.
+func (db *inMemoryHostKeyDB) hostKeyFallback(address string, remote net.Addr, remoteKey ssh.PublicKey) error {
+	err := db.check(address, remote, remoteKey)
+	keyErr, ok := err.(*knownhosts.KeyError)
+	if !ok {
+		return err
+	}
+	a, err := hostToCheck(address, remote)
+	if err != nil {
+		return err
+	}
+	return newKeyMismatchError(a.String(), remoteKey, keyErr, db.expiredKeys(a))
+}
+
+// hostToCheck returns the address to check the key against, giving
+// preference to the hostname if available.
+func hostToCheck(address string, remote net.Addr) (addr, error) {
 	host, port, err := net.SplitHostPort(remote.String())
 	if err != nil {
-		return fmt.Errorf("knownhosts: SplitHostPort(%s): %v", remote, err)
+		return addr{}, fmt.Errorf("knownhosts: SplitHostPort(%s): %v", remote, err)
 	}
 
-	hostToCheck := addr{host, port}
+	a := addr{host, port}
 	if address != "" {
 		// Give preference to the hostname if available.
 		host, port, err := net.SplitHostPort(address)
 		if err != nil {
-			return fmt.Errorf("knownhosts: SplitHostPort(%s): %v", address, err)
+			return addr{}, fmt.Errorf("knownhosts: SplitHostPort(%s): %v", address, err)
 		}
 
-		hostToCheck = addr{host, port}
+		a = addr{host, port}
 	}
-
-	return db.checkAddr(hostToCheck, remoteKey)
+	return a, nil
 }
 
 // checkAddr checks if we can find the given public key for the
 // given address.  If we only find an entry for the IP address,
-// or only the hostname, then this still succeeds.
+// or only the hostname, then this still succeeds. Keys of different
+// types may be known for the same host, in which case the first key
+// of each type is accepted. Keys added for a rotation window are
+// accepted in addition to these until the window has ended.
 func (db *inMemoryHostKeyDB) checkAddr(a addr, remoteKey ssh.PublicKey) error {
 	// TODO(hanwen): are these the right semantics? What if there
 	// is just a key for the IP address, but not for the
 	// hostname?
 
-	// Algorithm => key.
-	knownKeys := map[string]ssh.PublicKey{}
+	now := db.now()
+	// Keys in order of appearance, with at most one key per algorithm.
+	var knownKeys, rotated []ssh.PublicKey
 	for _, l := range db.hostKeys {
-		if l.match(a) {
-			typ := l.key.Type()
-			if _, ok := knownKeys[typ]; !ok {
-				knownKeys[typ] = l.key
+		if !l.match(a) {
+			continue
+		}
+		if !l.notAfter.IsZero() {
+			if !l.expired(now) {
+				rotated = appendKey(rotated, l.key)
 			}
+			continue
+		}
+		if keyOfType(knownKeys, l.key.Type()) == nil {
+			knownKeys = append(knownKeys, l.key)
+		}
+	}
+
+	for _, k := range rotated {
+		if keyEq(k, remoteKey) {
+			return nil
 		}
 	}
 
 	keyErr := &knownhosts.KeyError{}
-	for _, v := range knownKeys {
-		keyErr.Want = append(keyErr.Want, knownhosts.KnownKey{Key: v})
+	for _, k := range knownKeys {
+		keyErr.Want = append(keyErr.Want, knownhosts.KnownKey{Key: k})
+	}
+	for _, k := range rotated {
+		keyErr.Want = append(keyErr.Want, knownhosts.KnownKey{Key: k})
 	}
 
 	// Unknown remote host.
@@ -334,15 +389,53 @@ func (db *inMemoryHostKeyDB) checkAddr(a addr, remoteKey ssh.PublicKey) error {
 
 	// If the remote host starts using a different, unknown key type, we
 	// also interpret that as a mismatch.
-	if known, ok := knownKeys[remoteKey.Type()]; !ok || !keyEq(known, remoteKey) {
+	if known := keyOfType(knownKeys, remoteKey.Type()); known == nil || !keyEq(known, remoteKey) {
 		return keyErr
 	}
 
 	return nil
 }
 
+// expiredKeys returns the keys known for the given address whose
+// rotation window has ended.
+func (db *inMemoryHostKeyDB) expiredKeys(a addr) []ssh.PublicKey {
+	now := db.now()
+	var expired []ssh.PublicKey
+	for _, l := range db.hostKeys {
+		if l.match(a) && l.expired(now) {
+			expired = appendKey(expired, l.key)
+		}
+	}
+	return expired
+}
+
+// keyOfType returns the first key of the given type, or nil.
+func keyOfType(keys []ssh.PublicKey, typ string) ssh.PublicKey {
+	for _, k := range keys {
+		if k.Type() == typ {
+			return k
+		}
+	}
+	return nil
+}
+
+// appendKey appends key to keys if it is not already present.
+func appendKey(keys []ssh.PublicKey, key ssh.PublicKey) []ssh.PublicKey {
+	for _, k := range keys {
+		if keyEq(k, key) {
+			return keys
+		}
+	}
+	return append(keys, key)
+}
+
 // The Read function parses file contents.
 func (db *inMemoryHostKeyDB) Read(r io.Reader) error {
+	return db.read(r, time.Time{})
+}
+
+// read parses file contents, accepting the keys until notAfter.
+func (db *inMemoryHostKeyDB) read(r io.Reader, notAfter time.Time) error {
 	scanner := bufio.NewScanner(r)
 
 	lineNum := 0
@@ -354,7 +447,7 @@ func (db *inMemoryHostKeyDB) Read(r io.Reader) error {
 			continue
 		}
 
-		if err := db.parseLine(line); err != nil {
+		if err := db.parseLine(line, notAfter); err != nil {
 			return fmt.Errorf("knownhosts: %v", err)
 		}
 	}
@@ -367,17 +460,25 @@ func (db *inMemoryHostKeyDB) Read(r io.Reader) error {
 // operates on the hostname if available, i.e. if a server changes its
 // IP address, the host key check will still succeed, even though a
 // record of the new IP address is not available.
-func New(b []byte) (ssh.HostKeyCallback, error) {
+//
+// Options can be given to accept additional keys for a limited
+// period of time, e.g. while a host key is being rotated.
+func New(b []byte, opts ...Option) (ssh.HostKeyCallback, error) {
 	db := newInMemoryHostKeyDB()
 	r := bytes.NewReader(b)
 	if err := db.Read(r); err != nil {
 		return nil, err
 	}
+	for _, opt := range opts {
+		if err := opt(db); err != nil {
+			return nil, err
+		}
+	}
 
 	var certChecker ssh.CertChecker
 	certChecker.IsHostAuthority = db.IsHostAuthority
 	certChecker.IsRevoked = db.IsRevoked
-	certChecker.HostKeyFallback = db.check
+	certChecker.HostKeyFallback = db.hostKeyFallback
 
 	return certChecker.CheckHostKey, nil
 }
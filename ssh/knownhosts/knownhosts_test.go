@@ -16,10 +16,12 @@ package knownhosts
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"net"
 	"reflect"
 	"testing"
+	"time"
 
 	"golang.org/x/crypto/ssh"
 	"golang.org/x/crypto/ssh/knownhosts"
@@ -325,3 +327,105 @@ func TestHashedHostkeyCheck(t *testing.T) {
 		t.Errorf("got error %v, want %v", got, want)
 	}
 }
+
+func TestMultipleKeyTypes(t *testing.T) {
+	str := fmt.Sprintf("server.org %s\nserver.org %s", edKeyStr, ecKeyStr)
+	db := testDB(t, str)
+
+	for _, key := range []ssh.PublicKey{edKey, ecKey} {
+		if err := db.check("server.org:22", testAddr, key); err != nil {
+			t.Errorf("got error %v, want none", err)
+		}
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	now := time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)
+	current := fmt.Sprintf("server.org %s", alternateEdKeyStr)
+	previous := fmt.Sprintf("server.org %s", edKeyStr)
+
+	for _, tt := range []struct {
+		name    string
+		until   time.Time
+		key     ssh.PublicKey
+		wantErr bool
+	}{
+		{name: "new key", until: now.Add(time.Hour), key: alternateEdKey},
+		{name: "previous key within window", until: now.Add(time.Hour), key: edKey},
+		{name: "new key after window", until: now.Add(-time.Hour), key: alternateEdKey},
+		{name: "previous key after window", until: now.Add(-time.Hour), key: edKey, wantErr: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			db := testDB(t, current)
+			db.now = func() time.Time { return now }
+			if err := WithKeyRotation([]byte(previous), tt.until)(db); err != nil {
+				t.Fatalf("WithKeyRotation: %v", err)
+			}
+
+			err := db.check("server.org:22", testAddr, tt.key)
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if !tt.wantErr {
+				return
+			}
+			if _, ok := err.(*knownhosts.KeyError); !ok {
+				t.Fatalf("got %T, want *KeyError", err)
+			}
+
+			var mismatch *KeyMismatchError
+			if err := db.hostKeyFallback("server.org:22", testAddr, tt.key); !errors.As(err, &mismatch) {
+				t.Fatalf("got %T, want *KeyMismatchError", err)
+			}
+			if got, want := mismatch.Expired, []string{ssh.FingerprintSHA256(edKey)}; !reflect.DeepEqual(got, want) {
+				t.Errorf("got expired %v, want %v", got, want)
+			}
+		})
+	}
+
+	if _, err := New([]byte(current), WithKeyRotation([]byte(previous), time.Time{})); err == nil {
+		t.Error("no error for zero rotation window end")
+	}
+}
+
+func TestKeyMismatchError(t *testing.T) {
+	db := testDB(t, fmt.Sprintf("server.org %s\nserver.org %s", edKeyStr, ecKeyStr))
+
+	err := db.hostKeyFallback("server.org:22", testAddr, alternateEdKey)
+	var mismatch *KeyMismatchError
+	if !errors.As(err, &mismatch) {
+		t.Fatalf("got %T, want *KeyMismatchError", err)
+	}
+	if ke := new(knownhosts.KeyError); !errors.As(err, &ke) {
+		t.Errorf("got %T, want it to wrap *KeyError", err)
+	} else if len(ke.Want) != 2 {
+		t.Errorf("got %v, want 2 entries", ke)
+	}
+	if mismatch.IsUnknownHost() {
+		t.Error("got unknown host, want known host")
+	}
+	if got, want := mismatch.Presented, ssh.FingerprintSHA256(alternateEdKey); got != want {
+		t.Errorf("got presented %s, want %s", got, want)
+	}
+	want := []string{ssh.FingerprintSHA256(edKey), ssh.FingerprintSHA256(ecKey)}
+	if !reflect.DeepEqual(mismatch.Want, want) {
+		t.Errorf("got want %v, want %v", mismatch.Want, want)
+	}
+	wantMsg := fmt.Sprintf("knownhosts: key mismatch for host server.org:22: presented %s, want one of [%s, %s]",
+		mismatch.Presented, want[0], want[1])
+	if err.Error() != wantMsg {
+		t.Errorf("got message %q, want %q", err.Error(), wantMsg)
+	}
+
+	err = db.hostKeyFallback("unknown.org:22", testAddr, edKey)
+	if !errors.As(err, &mismatch) {
+		t.Fatalf("got %T, want *KeyMismatchError", err)
+	}
+	if !mismatch.IsUnknownHost() {
+		t.Error("got known host, want unknown host")
+	}
+
+	if err := db.hostKeyFallback("server.org:22", testAddr, edKey); err != nil {
+		t.Errorf("got error %v, want none", err)
+	}
+}
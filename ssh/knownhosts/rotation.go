@@ -0,0 +1,106 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package knownhosts
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// Option configures the host key database created by New.
+type Option func(db *inMemoryHostKeyDB) error
+
+// WithKeyRotation adds the host keys from the given known_hosts
+// contents as valid until the given time. This allows a host to
+// present either its previous or its new key during a rotation
+// window: the previous keys are given here, while the new keys are
+// given to New. Once the window has ended, presenting a previous key
+// results in a KeyMismatchError listing it as expired.
+func WithKeyRotation(previous []byte, until time.Time) Option {
+	return func(db *inMemoryHostKeyDB) error {
+		if until.IsZero() {
+			return fmt.Errorf("knownhosts: rotation window end must be set")
+		}
+		return db.read(bytes.NewReader(previous), until)
+	}
+}
+
+// KeyMismatchError is returned by the callback created by New when
+// the key presented by a host is not one of the keys known for it.
+// It wraps the *knownhosts.KeyError returned by the key check, and
+// holds the SHA256 fingerprints of the presented and the expected
+// keys.
+type KeyMismatchError struct {
+	// Host is the address the key was checked against.
+	Host string
+	// Presented is the fingerprint of the key presented by the host.
+	Presented string
+	// Want holds the fingerprints of the keys accepted for the host.
+	// It is empty if the host is unknown.
+	Want []string
+	// Expired holds the fingerprints of keys known for the host whose
+	// rotation window has ended.
+	Expired []string
+
+	keyErr *knownhosts.KeyError
+}
+
+func newKeyMismatchError(host string, presented ssh.PublicKey, keyErr *knownhosts.KeyError, expired []ssh.PublicKey) *KeyMismatchError {
+	e := &KeyMismatchError{
+		Host:      host,
+		Presented: ssh.FingerprintSHA256(presented),
+		keyErr:    keyErr,
+	}
+	for _, k := range keyErr.Want {
+		e.Want = append(e.Want, ssh.FingerprintSHA256(k.Key))
+	}
+	for _, k := range expired {
+		e.Expired = append(e.Expired, ssh.FingerprintSHA256(k))
+	}
+	return e
+}
+
+// Error implements the error interface.
+func (e *KeyMismatchError) Error() string {
+	if e.IsUnknownHost() {
+		return fmt.Sprintf("knownhosts: host %s is unknown, presented key %s", e.Host, e.Presented)
+	}
+	msg := fmt.Sprintf("knownhosts: key mismatch for host %s: presented %s", e.Host, e.Presented)
+	if len(e.Want) > 0 {
+		msg += fmt.Sprintf(", want one of [%s]", strings.Join(e.Want, ", "))
+	}
+	if len(e.Expired) > 0 {
+		msg += fmt.Sprintf(", expired [%s]", strings.Join(e.Expired, ", "))
+	}
+	return msg
+}
+
+// IsUnknownHost returns true if no keys are known for the host.
+func (e *KeyMismatchError) IsUnknownHost() bool {
+	return len(e.Want) == 0 && len(e.Expired) == 0
+}
+
+// Unwrap returns the underlying knownhosts.KeyError, for callers
+// relying on the golang.org/x/crypto/ssh/knownhosts error type.
+func (e *KeyMismatchError) Unwrap() error {
+	return e.keyErr
+}
@@ -16,6 +16,7 @@ import (
 	"io"
 	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
 	"runtime"
 	"strings"
@@ -42,6 +43,20 @@ type tarOpts struct {
 
 	// skipSymlinks ignores symlinks instead of failing the decompression.
 	skipSymlinks bool
+
+	// maxFiles represents the limit for the number of entries in archives being decompressed by Untar.
+	// When max is zero or a negative value the check is disabled.
+	maxFiles int
+
+	// maxEntrySize represents the limit size (bytes) for each entry of archives being decompressed by Untar.
+	// When max is zero or a negative value the check is disabled.
+	maxEntrySize int64
+
+	// includePaths are the patterns of the entry paths to extract. When empty, all entries are extracted.
+	includePaths []string
+
+	// excludePaths are the patterns of the entry paths to skip.
+	excludePaths []string
 }
 
 // Untar reads the gzip-compressed tar file from r and writes it into dir.
@@ -84,6 +99,7 @@ func Untar(r io.Reader, dir string, inOpts ...TarOption) (err error) {
 	}
 	tr := tar.NewReader(zr)
 	processedBytes := 0
+	processedFiles := 0
 	t0 := time.Now()
 
 	// For improved concurrency, this could be optimised by sourcing
@@ -102,9 +118,21 @@ func Untar(r io.Reader, dir string, inOpts ...TarOption) (err error) {
 			processedBytes > opts.maxUntarSize {
 			return fmt.Errorf("tar %q is bigger than max archive size of %d bytes", f.Name, opts.maxUntarSize)
 		}
+		processedFiles++
+		if opts.maxFiles > 0 && processedFiles > opts.maxFiles {
+			return fmt.Errorf("tar contains more than the max number of %d entries", opts.maxFiles)
+		}
+		if opts.maxEntrySize > 0 && f.Size > opts.maxEntrySize {
+			return fmt.Errorf("tar entry %q is bigger than max entry size of %d bytes", f.Name, opts.maxEntrySize)
+		}
 		if !validRelPath(f.Name) {
 			return fmt.Errorf("tar contained invalid name error %q", f.Name)
 		}
+		if skip, err := opts.skipPath(f.Name); err != nil {
+			return err
+		} else if skip {
+			continue
+		}
 		rel := filepath.FromSlash(f.Name)
 		abs := filepath.Join(dir, rel)
 
@@ -223,6 +251,38 @@ func copyBuffer(dst io.Writer, src io.Reader, buf []byte) (written int64, err er
 	return written, err
 }
 
+// skipPath returns true if the entry with the given name must not be
+// extracted according to the include and exclude patterns.
+func (t *tarOpts) skipPath(name string) (bool, error) {
+	name = strings.TrimSuffix(path.Clean(name), "/")
+	excluded, err := matchPath(t.excludePaths, name)
+	if err != nil || excluded {
+		return excluded, err
+	}
+	if len(t.includePaths) == 0 {
+		return false, nil
+	}
+	included, err := matchPath(t.includePaths, name)
+	return !included, err
+}
+
+// matchPath returns true if the name, or one of its parent directories,
+// matches one of the patterns.
+func matchPath(patterns []string, name string) (bool, error) {
+	for _, pattern := range patterns {
+		for p := name; p != "." && p != "/"; p = path.Dir(p) {
+			ok, err := path.Match(pattern, p)
+			if err != nil {
+				return false, fmt.Errorf("invalid path pattern %q: %w", pattern, err)
+			}
+			if ok {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
 func validRelPath(p string) bool {
 	if p == "" || strings.Contains(p, `\`) || strings.HasPrefix(p, "/") || strings.Contains(p, "../") {
 		return false
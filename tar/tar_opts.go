@@ -34,6 +34,40 @@ func WithSkipSymlinks() TarOption {
 	}
 }
 
+// WithMaxFiles sets the limit for the number of entries in archives being decompressed by Untar.
+// When max is equal or less than 0 disables the check.
+func WithMaxFiles(max int) TarOption {
+	return func(t *tarOpts) {
+		t.maxFiles = max
+	}
+}
+
+// WithMaxEntrySize sets the limit size (bytes) for each entry of archives being decompressed by Untar.
+// When max is equal or less than 0 disables the check.
+func WithMaxEntrySize(max int64) TarOption {
+	return func(t *tarOpts) {
+		t.maxEntrySize = max
+	}
+}
+
+// WithIncludePaths only extracts the entries which path, or the path of one of their parent
+// directories, matches one of the given patterns. The patterns use the syntax of path.Match,
+// and are matched against the slash separated path of the entries in the archive.
+func WithIncludePaths(patterns ...string) TarOption {
+	return func(t *tarOpts) {
+		t.includePaths = append(t.includePaths, patterns...)
+	}
+}
+
+// WithExcludePaths skips the entries which path, or the path of one of their parent
+// directories, matches one of the given patterns. Exclusions take precedence over
+// inclusions. The patterns use the syntax of path.Match.
+func WithExcludePaths(patterns ...string) TarOption {
+	return func(t *tarOpts) {
+		t.excludePaths = append(t.excludePaths, patterns...)
+	}
+}
+
 func (t *tarOpts) applyOpts(tarOpts ...TarOption) {
 	for _, clientOpt := range tarOpts {
 		clientOpt(t)
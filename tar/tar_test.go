@@ -22,8 +22,11 @@ import (
 	"compress/gzip"
 	"crypto/rand"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"reflect"
+	"sort"
 	"testing"
 )
 
@@ -178,6 +181,118 @@ func TestUntar(t *testing.T) {
 	}
 }
 
+func TestUntar_LimitsAndFilters(t *testing.T) {
+	entries := map[string]int{
+		"manifests/app.yaml":     16,
+		"manifests/sub/db.yaml":  32,
+		"manifests/README.md":    8,
+		"charts/app/values.yaml": 64,
+	}
+
+	cases := []struct {
+		name      string
+		opts      []TarOption
+		wantErr   string
+		wantFiles []string
+	}{
+		{
+			name:      "no filters",
+			wantFiles: []string{"charts/app/values.yaml", "manifests/README.md", "manifests/app.yaml", "manifests/sub/db.yaml"},
+		},
+		{
+			name:      "include directory",
+			opts:      []TarOption{WithIncludePaths("manifests")},
+			wantFiles: []string{"manifests/README.md", "manifests/app.yaml", "manifests/sub/db.yaml"},
+		},
+		{
+			name:      "include glob and exclude directory",
+			opts:      []TarOption{WithIncludePaths("*/*.yaml", "manifests/*/*.yaml"), WithExcludePaths("manifests/sub")},
+			wantFiles: []string{"manifests/app.yaml"},
+		},
+		{
+			name:    "invalid pattern",
+			opts:    []TarOption{WithExcludePaths("[")},
+			wantErr: `invalid path pattern "[": syntax error in pattern`,
+		},
+		{
+			name:    "breach max files",
+			opts:    []TarOption{WithMaxFiles(3)},
+			wantErr: "tar contains more than the max number of 3 entries",
+		},
+		{
+			name:    "breach max entry size",
+			opts:    []TarOption{WithMaxEntrySize(63)},
+			wantErr: `tar entry "charts/app/values.yaml" is bigger than max entry size of 63 bytes`,
+		},
+		{
+			name:    "breach max entry size with skipped entry",
+			opts:    []TarOption{WithMaxEntrySize(16), WithIncludePaths("manifests/app.yaml")},
+			wantErr: `tar entry "charts/app/values.yaml" is bigger than max entry size of 16 bytes`,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			gzw := gzip.NewWriter(&buf)
+			tw := tar.NewWriter(gzw)
+			for _, name := range sortedKeys(entries) {
+				content := geRandomContent(entries[name])
+				if err := tw.WriteHeader(&tar.Header{Name: name, Size: int64(len(content)), Mode: 0o600}); err != nil {
+					t.Fatalf("write header: %v", err)
+				}
+				if _, err := tw.Write(content); err != nil {
+					t.Fatalf("write content: %v", err)
+				}
+			}
+			if err := tw.Close(); err != nil {
+				t.Fatalf("close tar: %v", err)
+			}
+			if err := gzw.Close(); err != nil {
+				t.Fatalf("close gzip: %v", err)
+			}
+
+			dir := t.TempDir()
+			err := Untar(&buf, dir, tt.opts...)
+			var got string
+			if err != nil {
+				got = err.Error()
+			}
+			if tt.wantErr != got {
+				t.Fatalf("wanted error: '%s' got: '%v'", tt.wantErr, err)
+			}
+			if tt.wantErr != "" {
+				return
+			}
+
+			var files []string
+			err = filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+				if err != nil || d.IsDir() {
+					return err
+				}
+				rel, err := filepath.Rel(dir, p)
+				files = append(files, filepath.ToSlash(rel))
+				return err
+			})
+			if err != nil {
+				t.Fatalf("walk: %v", err)
+			}
+			if !reflect.DeepEqual(files, tt.wantFiles) {
+				t.Errorf("files wanted: %v got: %v", tt.wantFiles, files)
+			}
+		})
+	}
+}
+
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 func Fuzz_Untar(f *testing.F) {
 	tf, err := createTestTar(untarTestCase{
 		name:     "file at root",
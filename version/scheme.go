@@ -0,0 +1,177 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package version
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+// Scheme is the versioning scheme used to parse versions.
+type Scheme string
+
+const (
+	// SchemeSemVer parses versions as strict semantic versions,
+	// allowing a preceding "v".
+	SchemeSemVer Scheme = "semver"
+	// SchemeCalVer parses versions as calendar versions in the
+	// YYYY.MM.PATCH format, e.g. 2023.09.1. The year, month and patch
+	// map to the major, minor and patch components of the version,
+	// which allows calendar versions to be used with semver
+	// constraints.
+	SchemeCalVer Scheme = "calver"
+)
+
+var calVerRegex = regexp.MustCompile(`^v?([0-9]{4})\.([0-9]{1,2})\.(0|[1-9][0-9]*)` +
+	`(-[0-9A-Za-z-]+(\.[0-9A-Za-z-]+)*)?(\+[0-9A-Za-z-]+(\.[0-9A-Za-z-]+)*)?$`)
+
+type options struct {
+	scheme        Scheme
+	buildMetadata bool
+}
+
+// Option configures how versions are parsed and ordered.
+type Option func(*options)
+
+// WithScheme sets the versioning scheme, defaults to SchemeSemVer.
+func WithScheme(scheme Scheme) Option {
+	return func(o *options) {
+		o.scheme = scheme
+	}
+}
+
+// WithBuildMetadataOrdering orders versions with equal precedence by
+// their build metadata, which is otherwise ignored by semver. The
+// metadata identifiers are compared like pre-release identifiers,
+// and a version without metadata has a lower precedence than one
+// with metadata.
+func WithBuildMetadataOrdering() Option {
+	return func(o *options) {
+		o.buildMetadata = true
+	}
+}
+
+func makeOptions(opts []Option) options {
+	o := options{scheme: SchemeSemVer}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// Parse parses the given version according to the configured scheme.
+func Parse(v string, opts ...Option) (*semver.Version, error) {
+	o := makeOptions(opts)
+	switch o.scheme {
+	case SchemeSemVer:
+		return ParseVersion(v)
+	case SchemeCalVer:
+		return parseCalVer(v)
+	default:
+		return nil, fmt.Errorf("unsupported version scheme '%s'", o.scheme)
+	}
+}
+
+func parseCalVer(v string) (*semver.Version, error) {
+	m := calVerRegex.FindStringSubmatch(v)
+	if m == nil {
+		return nil, fmt.Errorf("invalid calendar version '%s': must be in the YYYY.MM.PATCH format", v)
+	}
+	if month, _ := strconv.Atoi(m[2]); month < 1 || month > 12 {
+		return nil, fmt.Errorf("invalid calendar version '%s': month must be between 1 and 12", v)
+	}
+	return semver.NewVersion(v)
+}
+
+// Compare compares a to b, returning -1, 0 or 1 if a is respectively
+// lower than, equal to or greater than b.
+func Compare(a, b *semver.Version, opts ...Option) int {
+	if c := a.Compare(b); c != 0 || !makeOptions(opts).buildMetadata {
+		return c
+	}
+	return compareMetadata(a.Metadata(), b.Metadata())
+}
+
+// Sort sorts the given versions in ascending order.
+func Sort(versions []*semver.Version, opts ...Option) {
+	sort.SliceStable(versions, func(i, j int) bool {
+		return Compare(versions[i], versions[j], opts...) < 0
+	})
+}
+
+// compareMetadata compares the dot separated identifiers of build
+// metadata following the pre-release precedence rules of semver.
+func compareMetadata(a, b string) int {
+	switch {
+	case a == b:
+		return 0
+	case a == "":
+		return -1
+	case b == "":
+		return 1
+	}
+
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		if c := compareIdentifier(as[i], bs[i]); c != 0 {
+			return c
+		}
+	}
+	switch {
+	case len(as) < len(bs):
+		return -1
+	case len(as) > len(bs):
+		return 1
+	}
+	return 0
+}
+
+// compareIdentifier compares numeric identifiers numerically and other
+// identifiers lexically. Numeric identifiers have a lower precedence
+// than non-numeric ones.
+func compareIdentifier(a, b string) int {
+	aNum, bNum := isNumeric(a), isNumeric(b)
+	switch {
+	case aNum && bNum:
+		a, b = strings.TrimLeft(a, "0"), strings.TrimLeft(b, "0")
+		if len(a) != len(b) {
+			if len(a) < len(b) {
+				return -1
+			}
+			return 1
+		}
+	case aNum:
+		return -1
+	case bNum:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+func isNumeric(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
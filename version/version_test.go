@@ -18,6 +18,8 @@ package version
 
 import (
 	"testing"
+
+	"github.com/Masterminds/semver/v3"
 )
 
 func TestParseVersion(t *testing.T) {
@@ -47,3 +49,92 @@ func TestParseVersion(t *testing.T) {
 		}
 	}
 }
+
+func TestParse_CalVer(t *testing.T) {
+	tests := []struct {
+		version string
+		want    string
+		err     bool
+	}{
+		{"2023.09.1", "2023.9.1", false},
+		{"v2023.12.0", "2023.12.0", false},
+		{"2023.1.0-rc.1+build.5", "2023.1.0-rc.1+build.5", false},
+		{"2023.13.0", "", true},
+		{"2023.00.0", "", true},
+		{"23.01.0", "", true},
+		{"2023.01", "", true},
+		{"2023.01.01", "", true},
+		{"1.2.3", "", true},
+	}
+	for _, tc := range tests {
+		v, err := Parse(tc.version, WithScheme(SchemeCalVer))
+		if tc.err {
+			if err == nil {
+				t.Fatalf("expected error for version: %s", tc.version)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("error for version %s: %s", tc.version, err)
+		}
+		if v.String() != tc.want {
+			t.Fatalf("expected %s for version %s, got %s", tc.want, tc.version, v)
+		}
+	}
+
+	c, err := semver.NewConstraint(">=2023.06.0 <2024.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for version, want := range map[string]bool{"2023.05.3": false, "2023.11.0": true, "2024.01.0": false} {
+		v, err := Parse(version, WithScheme(SchemeCalVer))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := c.Check(v); got != want {
+			t.Fatalf("expected constraint check for %s to be %v", version, want)
+		}
+	}
+
+	if _, err := Parse("1.2.3", WithScheme("unknown")); err == nil {
+		t.Fatal("expected error for unknown scheme")
+	}
+}
+
+func TestSort_BuildMetadata(t *testing.T) {
+	input := []string{"1.0.0+build.10", "1.0.0+build.9", "1.0.0", "0.9.0+build.11", "1.0.0+build.9.1", "1.0.0+build.a"}
+
+	tests := []struct {
+		name string
+		opts []Option
+		want []string
+	}{
+		{
+			name: "metadata ignored",
+			want: []string{"0.9.0+build.11", "1.0.0+build.10", "1.0.0+build.9", "1.0.0", "1.0.0+build.9.1", "1.0.0+build.a"},
+		},
+		{
+			name: "metadata ordering",
+			opts: []Option{WithBuildMetadataOrdering()},
+			want: []string{"0.9.0+build.11", "1.0.0", "1.0.0+build.9", "1.0.0+build.9.1", "1.0.0+build.10", "1.0.0+build.a"},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			versions := make([]*semver.Version, 0, len(input))
+			for _, s := range input {
+				v, err := Parse(s, tc.opts...)
+				if err != nil {
+					t.Fatal(err)
+				}
+				versions = append(versions, v)
+			}
+			Sort(versions, tc.opts...)
+			for i, v := range versions {
+				if v.Original() != tc.want[i] {
+					t.Fatalf("expected %v at index %d, got %s", tc.want[i], i, v)
+				}
+			}
+		})
+	}
+}